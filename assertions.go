@@ -0,0 +1,217 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Assertion is a rule checked against every page it applies to as the page is crawled, for example "every
+// page under /docs must link to /docs/index" or "no page may link to *.staging.example.com".
+//
+// Link patterns starting with / are path globs matched against links to pages on the site being crawled.
+// Any other pattern is a host glob (optionally followed by a path glob) matched against every link, so
+// *.staging.example.com or staging.example.com/admin/** match links to other domains. In globs * matches
+// any characters except / and ** matches any characters.
+type Assertion struct {
+	Name          string `json:"name"`                    // name the assertion is reported with
+	Pages         string `json:"pages,omitempty"`         // path glob of the pages checked (all pages if empty)
+	MustLinkTo    string `json:"mustLinkTo,omitempty"`    // link pattern each page must have a link matching
+	MustNotLinkTo string `json:"mustNotLinkTo,omitempty"` // link pattern no page may have a link matching
+}
+
+// AssertionViolation is a page which failed an assertion
+type AssertionViolation struct {
+	Assertion string // name of the assertion failed
+	URL       string // URL of the page
+	Link      string // link which must not be present (empty if a required link is missing)
+	Pattern   string // link pattern of the assertion
+}
+
+// linkPattern is a compiled link pattern (see Assertion)
+type linkPattern struct {
+	host *regexp.Regexp // host glob, nil to only match links on the site being crawled
+	path *regexp.Regexp // path glob, nil to match any path
+}
+
+// compiledAssertion is an assertion with its globs compiled
+type compiledAssertion struct {
+	Assertion
+	pages         *regexp.Regexp // nil for all pages
+	mustLinkTo    *linkPattern
+	mustNotLinkTo *linkPattern
+}
+
+// AssertionChecker checks crawled pages against a set of assertions, recording any violations. Its OnPage
+// method can be used as an OnPageFunc to check each page as it is crawled.
+type AssertionChecker struct {
+	assertions []compiledAssertion
+	logger     Logger
+	mutex      sync.Mutex
+	violations []AssertionViolation
+}
+
+// LoadAssertions reads a JSON file containing an array of assertions and creates a checker for them
+func LoadAssertions(fileName string) (*AssertionChecker, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var assertions []Assertion
+	if err := json.Unmarshal(data, &assertions); err != nil {
+		return nil, fmt.Errorf("invalid assertions file %s: %v", fileName, err)
+	}
+	return CreateAssertionChecker(assertions)
+}
+
+// CreateAssertionChecker creates a checker for the supplied assertions, returning an error if any of them
+// are invalid
+func CreateAssertionChecker(assertions []Assertion) (*AssertionChecker, error) {
+	checker := &AssertionChecker{logger: defaultLogger()}
+	for _, assertion := range assertions {
+		if len(assertion.Name) == 0 {
+			return nil, fmt.Errorf("assertion has no name: %+v", assertion)
+		}
+		if len(assertion.MustLinkTo) == 0 && len(assertion.MustNotLinkTo) == 0 {
+			return nil, fmt.Errorf("assertion %q has no mustLinkTo or mustNotLinkTo pattern", assertion.Name)
+		}
+		compiled := compiledAssertion{Assertion: assertion}
+		var err error
+		if len(assertion.Pages) != 0 {
+			if compiled.pages, err = CompilePathGlob(assertion.Pages); err != nil {
+				return nil, fmt.Errorf("assertion %q has an invalid pages pattern: %v", assertion.Name, err)
+			}
+		}
+		if compiled.mustLinkTo, err = compileLinkPattern(assertion.MustLinkTo); err != nil {
+			return nil, fmt.Errorf("assertion %q has an invalid mustLinkTo pattern: %v", assertion.Name, err)
+		}
+		if compiled.mustNotLinkTo, err = compileLinkPattern(assertion.MustNotLinkTo); err != nil {
+			return nil, fmt.Errorf("assertion %q has an invalid mustNotLinkTo pattern: %v", assertion.Name, err)
+		}
+		checker.assertions = append(checker.assertions, compiled)
+	}
+	return checker, nil
+}
+
+// Check checks a page against every assertion applying to it, returning the violations found (none if the
+// page passes them all)
+func (checker *AssertionChecker) Check(page *WebPage) []AssertionViolation {
+	var links []string
+	for link := range page.InternalLinks {
+		links = append(links, link)
+	}
+	for link := range page.ExternalLinks {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+
+	var violations []AssertionViolation
+	for _, assertion := range checker.assertions {
+		if assertion.pages != nil && !assertion.pages.MatchString(pagePath(page)) {
+			continue
+		}
+		// links from a page to itself aren't recorded, so a page matching the pattern satisfies it itself
+		if assertion.mustLinkTo != nil && !assertion.mustLinkTo.matchesAny(append(links, page.URL.String()), page.URL) {
+			violations = append(violations, AssertionViolation{assertion.Name, page.URL.String(), "", assertion.MustLinkTo})
+		}
+		if assertion.mustNotLinkTo != nil {
+			for _, link := range links {
+				if assertion.mustNotLinkTo.matches(link, page.URL) {
+					violations = append(violations, AssertionViolation{assertion.Name, page.URL.String(), link, assertion.MustNotLinkTo})
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// OnPage checks a crawled page, recording and logging any violations. The page is always kept. See OnPageFunc.
+func (checker *AssertionChecker) OnPage(visit *PageVisit) bool {
+	violations := checker.Check(visit.Page)
+	for _, violation := range violations {
+		if len(violation.Link) != 0 {
+			checker.logger.Warn("Assertion failed", "assertion", violation.Assertion, "url", violation.URL,
+				"link", violation.Link)
+		} else {
+			checker.logger.Warn("Assertion failed", "assertion", violation.Assertion, "url", violation.URL,
+				"missing", violation.Pattern)
+		}
+	}
+	checker.mutex.Lock()
+	checker.violations = append(checker.violations, violations...)
+	checker.mutex.Unlock()
+	return true
+}
+
+// Violations returns the violations recorded by OnPage, sorted by assertion then URL
+func (checker *AssertionChecker) Violations() []AssertionViolation {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	violations := append([]AssertionViolation(nil), checker.violations...)
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Assertion != violations[j].Assertion {
+			return violations[i].Assertion < violations[j].Assertion
+		} else if violations[i].URL != violations[j].URL {
+			return violations[i].URL < violations[j].URL
+		}
+		return violations[i].Link < violations[j].Link
+	})
+	return violations
+}
+
+// compileLinkPattern compiles a link pattern (see Assertion), returning nil for an empty pattern
+func compileLinkPattern(pattern string) (*linkPattern, error) {
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+	compiled := &linkPattern{}
+	pathGlob := pattern
+	if !strings.HasPrefix(pattern, "/") {
+		host, rest, hasPath := strings.Cut(pattern, "/")
+		hostGlob, err := CompilePathGlob(strings.ToLower(host))
+		if err != nil {
+			return nil, err
+		}
+		compiled.host, pathGlob = hostGlob, ""
+		if hasPath {
+			pathGlob = "/" + rest
+		}
+	}
+	if len(pathGlob) != 0 {
+		pathRegexp, err := CompilePathGlob(pathGlob)
+		if err != nil {
+			return nil, err
+		}
+		compiled.path = pathRegexp
+	}
+	return compiled, nil
+}
+
+// matches checks if a link on the supplied page matches the pattern
+func (pattern *linkPattern) matches(link string, page *url.URL) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	if pattern.host != nil && !pattern.host.MatchString(strings.ToLower(u.Hostname())) {
+		return false
+	} else if pattern.host == nil && !isSameSite(u, page) {
+		return false
+	}
+	return pattern.path == nil || pattern.path.MatchString(urlPath(u))
+}
+
+// matchesAny checks if any of the links on the supplied page match the pattern
+func (pattern *linkPattern) matchesAny(links []string, page *url.URL) bool {
+	for _, link := range links {
+		if pattern.matches(link, page) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssertionChecker(t *testing.T) {
+	checker, err := CreateAssertionChecker([]Assertion{
+		{Name: "docs index", Pages: "/docs/**", MustLinkTo: "/docs/index"},
+		{Name: "no staging", MustNotLinkTo: "*.staging.test.com"},
+		{Name: "no admin", MustNotLinkTo: "/admin/**"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := map[string][]string{
+		"":            {"https://test.com/docs/index", "https://test.com/admin/users"},
+		"/docs/a":     {"https://test.com/docs/index", "https://www.staging.test.com/docs/a"},
+		"/docs/b":     {"https://test.com/docs/a"},
+		"/docs/index": {"https://staging.test.com/admin/x"},
+	}
+	for path, links := range pages {
+		page := createWebPage(t, "https://test.com"+path, path)
+		for _, link := range links {
+			if strings.HasPrefix(link, "https://test.com") {
+				page.AddLink(link, Link{})
+			} else {
+				page.AddExternalLink(link)
+			}
+		}
+		checker.OnPage(&PageVisit{Page: page})
+	}
+	expected := "[{docs index https://test.com/docs/b  /docs/index} " +
+		"{no admin https://test.com https://test.com/admin/users /admin/**} " +
+		"{no staging https://test.com/docs/a https://www.staging.test.com/docs/a *.staging.test.com}]"
+	violations := checker.Violations()
+	if got := fmt.Sprint(violations); got != expected {
+		t.Errorf("Incorrect violations: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintAssertionViolations(&buf, violations, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Assertion violations (3)", " docs index:\n     https://test.com/docs/b (no link matching /docs/index)",
+		" no admin:\n     https://test.com (links to https://test.com/admin/users)"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Incorrect assertion report: expected %q in %s", line, buf.String())
+		}
+	}
+}
+
+func TestLoadAssertions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"valid.json":    `[{"name": "index", "pages": "/docs/**", "mustLinkTo": "/"}]`,
+		"invalid.json":  `{"name": "index"}`,
+		"unnamed.json":  `[{"mustLinkTo": "/"}]`,
+		"no-links.json": `[{"name": "index", "pages": "/docs/**"}]`,
+	}
+	valid := map[string]bool{"valid.json": true}
+	for name, contents := range files {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadAssertions(fileName); (err == nil) != valid[name] {
+			t.Errorf("Incorrect result loading %s: expected valid %v, got error %v", name, valid[name], err)
+		}
+	}
+	if _, err := LoadAssertions(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Missing expected error loading missing file")
+	}
+}
@@ -0,0 +1,158 @@
+package sitemap
+
+import (
+	"net/http"
+	"sort"
+)
+
+// AssetFailed is the status recorded for a static asset which could not be requested
+const AssetFailed = -1
+
+// hintRels are the rel values of <link> elements asking browsers to fetch a resource early
+var hintRels = []string{"preload", "modulepreload", "prefetch", "prerender"}
+
+// ResourceHint is a resource a page asks browsers to fetch early with a <link> element (e.g. rel="preload")
+type ResourceHint struct {
+	URL    string // URL of the resource
+	Rel    string // type of hint: preload, modulepreload, prefetch or prerender
+	Status int    // HTTP status of the resource (redirects aren't followed), 0 if not checked or AssetFailed
+}
+
+// Redirected checks if the resource was found to redirect, so browsers fetch it twice
+func (hint ResourceHint) Redirected() bool {
+	return hint.Status >= http.StatusMultipleChoices && hint.Status < http.StatusBadRequest
+}
+
+// Broken checks if the resource was found to be missing or could not be requested
+func (hint ResourceHint) Broken() bool {
+	return hint.Status == AssetFailed || hint.Status >= http.StatusBadRequest
+}
+
+// PageHint is a resource hint along with the page it is on
+type PageHint struct {
+	Page string // URL of the page
+	ResourceHint
+}
+
+// AddHint records a resource hint on the page, unless it has already been recorded
+func (page *WebPage) AddHint(urlStr string, rel string) {
+	for _, hint := range page.Hints {
+		if hint.URL == urlStr && hint.Rel == rel {
+			return
+		}
+	}
+	page.Hints = append(page.Hints, ResourceHint{URL: urlStr, Rel: rel})
+}
+
+// HintIssues returns the resource hints found to be broken or redirected, sorted by page then hint URL
+func (site *SiteMap) HintIssues() []PageHint {
+	var issues []PageHint
+	for _, page := range site.Pages {
+		for _, hint := range page.Hints {
+			if hint.Broken() || hint.Redirected() {
+				issues = append(issues, PageHint{page.URL.String(), hint})
+			}
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Page != issues[j].Page {
+			return issues[i].Page < issues[j].Page
+		} else if issues[i].URL != issues[j].URL {
+			return issues[i].URL < issues[j].URL
+		}
+		return issues[i].Rel < issues[j].Rel
+	})
+	return issues
+}
+
+// AssetUsage is a static asset (image, script or stylesheet) and the pages using it
+type AssetUsage struct {
+	URL    string   // URL of the asset
+	Status int      // HTTP status of the asset, 0 if not checked or AssetFailed if it could not be requested
+	Pages  []string // URLs of the pages using the asset, sorted
+}
+
+// Broken checks if the asset was found to be missing or could not be requested
+func (usage AssetUsage) Broken() bool {
+	return usage.Status == AssetFailed || usage.Status >= http.StatusBadRequest
+}
+
+// AssetUsage returns every static asset used by the pages in the site map, sorted by URL
+func (site *SiteMap) AssetUsage() []AssetUsage {
+	usage := make(map[string]*AssetUsage)
+	for _, page := range site.Pages {
+		for asset, status := range page.Assets {
+			if usage[asset] == nil {
+				usage[asset] = &AssetUsage{URL: asset}
+			}
+			if status != 0 {
+				usage[asset].Status = status
+			}
+			usage[asset].Pages = append(usage[asset].Pages, page.URL.String())
+		}
+	}
+	result := make([]AssetUsage, 0, len(usage))
+	for _, asset := range sortedKeys(usage) {
+		sort.Strings(usage[asset].Pages)
+		result = append(result, *usage[asset])
+	}
+	return result
+}
+
+// checkAssets sets the status of each of a page's assets and resource hints, requesting each once across
+// all pages. Redirects are followed for assets, but not for resource hints so redirected hints can be found.
+func (loader *DocLoader) checkAssets(page *WebPage) {
+	for asset := range page.Assets {
+		page.Assets[asset] = loader.cachedAssetStatus(asset, true)
+	}
+	for i, hint := range page.Hints {
+		page.Hints[i].Status = loader.cachedAssetStatus(hint.URL, false)
+	}
+}
+
+// ResetAssetStatus clears the cached status of each asset, so assets are requested again when next checked
+func (loader *DocLoader) ResetAssetStatus() {
+	loader.assetMutex.Lock()
+	defer loader.assetMutex.Unlock()
+	loader.assetStatus = make(map[string]int)
+}
+
+// cachedAssetStatus returns the status of an asset, only requesting it if it hasn't been requested before
+func (loader *DocLoader) cachedAssetStatus(urlStr string, followRedirects bool) int {
+	key := urlStr
+	if !followRedirects {
+		key = "nofollow " + urlStr
+	}
+	loader.assetMutex.Lock()
+	status, found := loader.assetStatus[key]
+	loader.assetMutex.Unlock()
+	if !found {
+		status = loader.requestAsset(urlStr, followRedirects)
+		loader.assetMutex.Lock()
+		loader.assetStatus[key] = status
+		loader.assetMutex.Unlock()
+	}
+	return status
+}
+
+// requestAsset requests an asset with a HEAD request (falling back to GET if the server doesn't support
+// HEAD), returning its status or AssetFailed if the request fails
+func (loader *DocLoader) requestAsset(urlStr string, followRedirects bool) int {
+	client := loader.Client
+	if !followRedirects {
+		noFollow := *loader.Client
+		noFollow.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		client = &noFollow
+	}
+	resp, err := client.Head(urlStr)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = client.Get(urlStr)
+	}
+	if err != nil {
+		loader.logger.Debug("Asset request failed", "url", urlStr, "error", err)
+		return AssetFailed
+	}
+	resp.Body.Close() // we only need the status
+	return resp.StatusCode
+}
@@ -0,0 +1,173 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDocumentLoaderAssetCheck(t *testing.T) {
+
+	// mock server - HEAD requests are only supported for the stylesheet
+	requests := make(map[string]int)
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		requests[req.Method+" "+req.URL.Path]++
+		switch {
+		case req.URL.Path == "/page" || req.URL.Path == "/other":
+			rw.Header().Add("Content-Type", "text/html")
+			fmt.Fprint(rw, `<HTML><BODY><link rel="stylesheet" href="/site.css"><img src="/logo.png"><img src="/missing.png"></BODY></HTML>`)
+		case req.URL.Path == "/site.css":
+			rw.WriteHeader(http.StatusOK)
+		case req.Method == http.MethodHead:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+		case req.URL.Path == "/logo.png":
+			rw.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(rw, req)
+		}
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	parser := CreateDocumentParser()
+	parser.Assets = true
+	docLoader := CreateDocumentLoader(parser)
+	docLoader.AssetCheck = true
+	site := CreateSiteMap(mustParseURL(t, mockServer.URL))
+	for _, path := range []string{"/page", "/other"} {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each asset is only requested once
+	expectedRequests := "map[GET /logo.png:1 GET /missing.png:1 GET /other:1 GET /page:1 HEAD /logo.png:1 HEAD /missing.png:1 HEAD /site.css:1]"
+	if got := fmt.Sprint(requests); got != expectedRequests {
+		t.Errorf("Incorrect requests: expected %s, got %s", expectedRequests, got)
+	}
+	usage := site.AssetUsage()
+	expected := fmt.Sprintf("[{%[1]s/logo.png 200 [%[1]s/other %[1]s/page]} {%[1]s/missing.png 404 [%[1]s/other %[1]s/page]} "+
+		"{%[1]s/site.css 200 [%[1]s/other %[1]s/page]}]", mockServer.URL)
+	if got := fmt.Sprint(usage); got != expected {
+		t.Errorf("Incorrect asset usage: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintAssets(&buf, site, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Static assets (3)", " " + mockServer.URL + "/page:\n     " + mockServer.URL + "/logo.png (200)\n",
+		"Broken assets (1)", " " + mockServer.URL + "/missing.png (404):\n     " + mockServer.URL + "/other\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Incorrect asset report: expected %q in %s", line, buf.String())
+		}
+	}
+}
+
+func TestDocumentLoaderHintCheck(t *testing.T) {
+
+	// mock server - the old font redirects to the new one
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/page":
+			rw.Header().Add("Content-Type", "text/html")
+			fmt.Fprint(rw, `<HTML><HEAD><link rel="preload" href="/font.woff2"><link rel="preload" href="/old.woff2">`+
+				`<link rel="prefetch" href="/gone"></HEAD></HTML>`)
+		case "/old.woff2":
+			http.Redirect(rw, req, "/font.woff2", http.StatusMovedPermanently)
+		case "/font.woff2":
+			rw.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(rw, req)
+		}
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	parser := CreateDocumentParser()
+	parser.Assets = true
+	docLoader := CreateDocumentLoader(parser)
+	docLoader.AssetCheck = true
+	site := CreateSiteMap(mustParseURL(t, mockServer.URL))
+	page, err := docLoader.LoadURL(mockServer.URL + "/page")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := site.AddPage(page); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("[{%[1]s/page {%[1]s/gone prefetch 404}} {%[1]s/page {%[1]s/old.woff2 preload 301}}]", mockServer.URL)
+	if got := fmt.Sprint(site.HintIssues()); got != expected {
+		t.Errorf("Incorrect hint issues: expected %s, got %s", expected, got)
+	}
+
+	findings := CollectFindings(site, AuditInputs{})
+	var checks []string
+	for _, finding := range findings {
+		if strings.HasPrefix(finding.Check, "hints.") {
+			checks = append(checks, finding.Check+" "+finding.Detail)
+		}
+	}
+	expected = fmt.Sprintf("[hints.missing prefetch %[1]s/gone hints.redirected preload %[1]s/old.woff2]", mockServer.URL)
+	if got := fmt.Sprint(checks); got != expected {
+		t.Errorf("Incorrect hint findings: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintAssets(&buf, site, nil); err != nil {
+		t.Fatal(err)
+	}
+	line := "Broken or redirected resource hints (2)"
+	if !strings.Contains(buf.String(), line) {
+		t.Errorf("Incorrect asset report: expected %q in %s", line, buf.String())
+	}
+}
+
+func TestDocumentLoaderFetchChecksAssets(t *testing.T) {
+
+	var requests atomic.Int32
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		if req.URL.Path == "/page" {
+			rw.Header().Add("Content-Type", "text/html")
+			fmt.Fprint(rw, `<HTML><BODY><img src="/logo.png"></BODY></HTML>`)
+		}
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	parser := CreateDocumentParser()
+	parser.Assets = true
+	docLoader := CreateDocumentLoader(parser)
+	docLoader.AssetCheck = true
+	fetched, err := docLoader.Fetch(mockServer.URL + "/page")
+	if err != nil {
+		t.Fatalf("Unexpected error from Fetch: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Incorrect number of requests after Fetch: expected 2, got %d", got)
+	}
+
+	// the asset was checked when fetched, so parsing makes no requests
+	page, err := docLoader.Parse(fetched)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Incorrect number of requests after Parse: expected 2, got %d", got)
+	}
+	expected := map[string]int{mockServer.URL + "/logo.png": http.StatusOK}
+	if got := page.Assets; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Incorrect assets: expected %v, got %v", expected, got)
+	}
+}
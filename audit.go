@@ -0,0 +1,379 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity is how serious an audit finding is. Findings with a severity of SeverityOff are not reported.
+type Severity int
+
+const (
+	SeverityOff     Severity = iota // not reported
+	SeverityInfo                    // worth knowing about
+	SeverityWarning                 // should be fixed
+	SeverityError                   // must be fixed
+)
+
+// ParseSeverity converts a severity name (off, info, warning or error) into a Severity
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(name) {
+	case "off":
+		return SeverityOff, nil
+	case "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return SeverityOff, fmt.Errorf("unknown severity %q (expected off, info, warning or error)", name)
+}
+
+// String returns the name of the severity
+func (severity Severity) String() string {
+	switch severity {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "off"
+	}
+}
+
+// MarshalText encodes the severity as its name, so severities are written to JSON as names
+func (severity Severity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+// UnmarshalText decodes a severity name
+func (severity *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	*severity = parsed
+	return err
+}
+
+// Finding is a single problem found by one of the audit checks
+type Finding struct {
+	Check    string   // audit check which found the problem (e.g. cache.short), see defaultSeverities
+	URL      string   // URL of the page with the problem
+	Detail   string   // details of the problem (e.g. the header or link at fault)
+	Severity Severity // severity of the finding
+}
+
+// defaultSeverities are the severities of the audit checks unless configured otherwise, keyed by check (which
+// may be a glob). Checks with no severity here are warnings.
+var defaultSeverities = map[string]Severity{
+	"redirects.loop":      SeverityError,
+	"assets.missing":      SeverityError,
+	"icons.missing":       SeverityError,
+	"icons.none":          SeverityInfo,
+	"auth.required":       SeverityInfo,
+	"manifest.page":       SeverityInfo,
+	"assertion.*":         SeverityError,
+	"cache.short":         SeverityInfo,
+	"vary.cookie":         SeverityInfo,
+	"vary.unusual":        SeverityInfo,
+	"encoding.lowercase":  SeverityInfo,
+	"encoding.unreserved": SeverityInfo,
+	"canonical.other":     SeverityInfo,
+}
+
+// Suppression hides the findings of a check for matching URLs, so legacy problems can be accepted while
+// new ones are still reported
+type Suppression struct {
+	Check  string `json:"check,omitempty"`  // glob of the checks suppressed (e.g. cache.*), all checks if empty
+	URL    string `json:"url,omitempty"`    // URL, or path glob starting with / (e.g. /legacy/**), all URLs if empty
+	Reason string `json:"reason,omitempty"` // why the findings are accepted
+}
+
+// BaselineFinding is a finding recorded in an audit baseline
+type BaselineFinding struct {
+	Check  string `json:"check"`
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditBaseline configures how audit findings are reported: the severity of each check, and the findings
+// suppressed. It can also record the findings of a crawl, so later crawls only report new findings (see
+// NewFindings). It is read from a JSON file so it can be kept alongside the site it audits.
+type AuditBaseline struct {
+	Severities   map[string]Severity `json:"severities,omitempty"`   // severities keyed by check glob
+	Suppressions []Suppression       `json:"suppressions,omitempty"` // findings not reported
+	Findings     []BaselineFinding   `json:"findings,omitempty"`     // findings recorded (see Record), sorted
+
+	suppressionURLs []*regexp.Regexp // compiled URL globs of the suppressions (nil to match all URLs)
+}
+
+// volatileDetails are the checks whose details change between crawls without the problem changing (such as
+// Expires dates or content hashes), so their details are not used to match findings against a baseline
+var volatileDetails = []string{"cache.*", "vary.inconsistent"}
+
+// LoadAuditBaseline reads an audit baseline from a JSON file
+func LoadAuditBaseline(fileName string) (*AuditBaseline, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	baseline := &AuditBaseline{}
+	if err := json.Unmarshal(data, baseline); err != nil {
+		return nil, fmt.Errorf("invalid audit baseline %s: %v", fileName, err)
+	}
+	if err := baseline.compile(); err != nil {
+		return nil, fmt.Errorf("invalid audit baseline %s: %v", fileName, err)
+	}
+	return baseline, nil
+}
+
+// compile validates the baseline's globs, compiling the suppression URL globs
+func (baseline *AuditBaseline) compile() error {
+	for check := range baseline.Severities {
+		if _, err := path.Match(check, ""); err != nil {
+			return fmt.Errorf("invalid check pattern %q: %v", check, err)
+		}
+	}
+	baseline.suppressionURLs = make([]*regexp.Regexp, len(baseline.Suppressions))
+	for i, suppression := range baseline.Suppressions {
+		if _, err := path.Match(suppression.Check, ""); err != nil {
+			return fmt.Errorf("invalid check pattern %q: %v", suppression.Check, err)
+		}
+		if len(suppression.URL) != 0 {
+			urlGlob, err := CompilePathGlob(suppression.URL)
+			if err != nil {
+				return err
+			}
+			baseline.suppressionURLs[i] = urlGlob
+		}
+	}
+	return nil
+}
+
+// Record replaces the findings recorded in the baseline with those supplied
+func (baseline *AuditBaseline) Record(findings []Finding) {
+	baseline.Findings = make([]BaselineFinding, 0, len(findings))
+	for _, finding := range findings {
+		baseline.Findings = append(baseline.Findings, BaselineFinding{finding.Check, finding.URL, finding.Detail})
+	}
+	sort.Slice(baseline.Findings, func(i, j int) bool {
+		a, b := baseline.Findings[i], baseline.Findings[j]
+		if a.Check != b.Check {
+			return a.Check < b.Check
+		} else if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		return a.Detail < b.Detail
+	})
+}
+
+// NewFindings returns the findings supplied which are not recorded in the baseline (in the same order). A
+// finding matches a recorded one with the same check, URL and (unless they change between crawls) details.
+func (baseline *AuditBaseline) NewFindings(findings []Finding) []Finding {
+	recorded := make(map[BaselineFinding]bool, len(baseline.Findings))
+	for _, finding := range baseline.Findings {
+		recorded[baselineKey(finding)] = true
+	}
+	var result []Finding
+	for _, finding := range findings {
+		if !recorded[baselineKey(BaselineFinding{finding.Check, finding.URL, finding.Detail})] {
+			result = append(result, finding)
+		}
+	}
+	return result
+}
+
+// Save writes the baseline to a JSON file
+func (baseline *AuditBaseline) Save(fileName string) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// baselineKey returns the key a finding is matched against a baseline with, which excludes the details
+// for checks where they are volatile
+func baselineKey(finding BaselineFinding) BaselineFinding {
+	for _, pattern := range volatileDetails {
+		if matched, _ := path.Match(pattern, finding.Check); matched {
+			finding.Detail = ""
+		}
+	}
+	return finding
+}
+
+// Apply sets the severity of each finding, returning the findings to report (sorted by severity, most
+// severe first, then check and URL) and the number suppressed. A nil baseline only applies the default
+// severities.
+func (baseline *AuditBaseline) Apply(findings []Finding) ([]Finding, int) {
+	var severities map[string]Severity
+	if baseline != nil {
+		severities = baseline.Severities
+	}
+	var reported []Finding
+	suppressed := 0
+	for _, finding := range findings {
+		if baseline.suppresses(finding) {
+			suppressed++
+			continue
+		}
+		finding.Severity = SeverityWarning
+		if severity, found := matchSeverity(defaultSeverities, finding.Check); found {
+			finding.Severity = severity
+		}
+		if severity, found := matchSeverity(severities, finding.Check); found {
+			finding.Severity = severity
+		}
+		if finding.Severity != SeverityOff {
+			reported = append(reported, finding)
+		}
+	}
+	sort.SliceStable(reported, func(i, j int) bool {
+		if reported[i].Severity != reported[j].Severity {
+			return reported[i].Severity > reported[j].Severity
+		} else if reported[i].Check != reported[j].Check {
+			return reported[i].Check < reported[j].Check
+		}
+		return reported[i].URL < reported[j].URL
+	})
+	return reported, suppressed
+}
+
+// suppresses checks if a finding is suppressed by the baseline
+func (baseline *AuditBaseline) suppresses(finding Finding) bool {
+	if baseline == nil {
+		return false
+	}
+	for i, suppression := range baseline.Suppressions {
+		if matched, _ := path.Match(suppression.Check, finding.Check); len(suppression.Check) != 0 && !matched {
+			continue
+		}
+		if urlGlob := baseline.suppressionURLs[i]; urlGlob != nil && !urlGlob.MatchString(findingTarget(finding, suppression.URL)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// findingTarget returns the part of a finding's URL a suppression URL glob is matched against: the path
+// for a glob starting with /, otherwise the whole URL
+func findingTarget(finding Finding, urlGlob string) string {
+	if !strings.HasPrefix(urlGlob, "/") {
+		return finding.URL
+	}
+	if u, err := url.Parse(finding.URL); err == nil {
+		return urlPath(u)
+	}
+	return finding.URL
+}
+
+// matchSeverity returns the severity for a check, using an exact match if there is one, otherwise the
+// longest matching glob
+func matchSeverity(severities map[string]Severity, check string) (Severity, bool) {
+	if severity, found := severities[check]; found {
+		return severity, true
+	}
+	best, found := "", false
+	for pattern := range severities {
+		if matched, _ := path.Match(pattern, check); matched && (!found || len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best)) {
+			best, found = pattern, true
+		}
+	}
+	return severities[best], found
+}
+
+// AuditInputs are the results of the optional checks made during a crawl which CollectFindings includes in
+// its findings. The zero value of each field disables the checks using it.
+type AuditInputs struct {
+	RedirectLoops []*RedirectLoopError // redirect loops found by the crawler
+	AuthRequired  []*AuthRequiredError // URLs found redirecting to a login page
+	Icons         *IconAudit           // results of checking the favicon, icons and web app manifests
+	Violations    []AssertionViolation // assertions pages failed
+	MinTTL        time.Duration        // minimum time pages should be cacheable for, with shorter TTLs reported
+	MinWords      int                  // minimum words of visible text, if word counts were recorded
+	Now           time.Time            // time the caching headers are checked at (the current time if zero)
+}
+
+// CollectFindings returns the findings of the audit checks on a crawl: caching headers (using the minimum
+// TTL supplied), Vary headers (and pages varying between requests, if rechecked), href encoding, soft 404s
+// (if probed), broken assets and resource hints (if checked) and canonical URL problems, plus the results
+// of the optional checks supplied: redirect loops, URLs redirecting to a login page, favicon, icon and web
+// app manifest problems, pages with fewer words than the minimum and assertion violations. Severities are
+// not set (see Apply).
+func CollectFindings(site *SiteMap, inputs AuditInputs) []Finding {
+	now := inputs.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	var findings []Finding
+	for _, group := range site.CacheAudit(inputs.MinTTL, now) {
+		for _, issue := range group.Issues {
+			findings = append(findings, Finding{Check: issue.Problem.messageKey(), URL: issue.URL, Detail: issue.Detail})
+		}
+	}
+	for _, issue := range site.VaryAudit() {
+		findings = append(findings, Finding{Check: issue.Problem.messageKey(), URL: issue.URL, Detail: issue.Detail})
+	}
+	for _, page := range site.HrefIssues() {
+		for _, issue := range page.Issues {
+			for _, problem := range issue.Problems {
+				findings = append(findings, Finding{Check: problem.messageKey(), URL: page.URL, Detail: issue.Href})
+			}
+		}
+	}
+	for _, page := range site.Soft404Pages() {
+		findings = append(findings, Finding{Check: "soft404", URL: page.URL.String(), Detail: page.Title})
+	}
+	for _, loop := range inputs.RedirectLoops {
+		findings = append(findings, Finding{Check: "redirects.loop", URL: loop.URL, Detail: strings.Join(loop.Cycle, " -> ")})
+	}
+	for _, authErr := range inputs.AuthRequired {
+		findings = append(findings, Finding{Check: "auth.required", URL: authErr.URL, Detail: authErr.LoginURL})
+	}
+	for _, asset := range site.AssetUsage() {
+		if asset.Broken() {
+			for _, page := range asset.Pages {
+				findings = append(findings, Finding{Check: "assets.missing", URL: page, Detail: asset.URL})
+			}
+		}
+	}
+	for _, issue := range site.HintIssues() {
+		check := "hints.redirected"
+		if issue.Broken() {
+			check = "hints.missing"
+		}
+		findings = append(findings, Finding{Check: check, URL: issue.Page, Detail: issue.Rel + " " + issue.URL})
+	}
+	if inputs.Icons != nil {
+		findings = append(findings, inputs.Icons.findings()...)
+	}
+	for _, issue := range site.CanonicalIssues() {
+		detail := issue.Canonical
+		if len(issue.Detail) != 0 {
+			detail += " -> " + issue.Detail
+		}
+		findings = append(findings, Finding{Check: issue.Problem.messageKey(), URL: issue.URL, Detail: detail})
+	}
+	for _, page := range site.ThinPages(inputs.MinWords) {
+		findings = append(findings, Finding{Check: "content.thin", URL: page.URL.String(), Detail: strconv.Itoa(page.WordCount)})
+	}
+	for _, violation := range inputs.Violations {
+		detail := violation.Link
+		if len(detail) == 0 {
+			detail = violation.Pattern
+		}
+		findings = append(findings, Finding{Check: "assertion." + violation.Assertion, URL: violation.URL, Detail: detail})
+	}
+	return findings
+}
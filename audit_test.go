@@ -0,0 +1,140 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	for name, expected := range map[string]Severity{"off": SeverityOff, "Info": SeverityInfo, "warning": SeverityWarning, "ERROR": SeverityError} {
+		if severity, err := ParseSeverity(name); err != nil || severity != expected {
+			t.Errorf("Incorrect severity for %s: expected %v, got %v (%v)", name, expected, severity, err)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Errorf("Missing expected error for unknown severity")
+	}
+}
+
+func TestCollectFindings(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	page := createWebPage(t, "https://test.com/blog", "Blog")
+	page.Header = http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Cookie"}}
+	page.HrefIssues = []HrefIssue{{"/a b", "/a%20b", []EncodingProblem{EncodingUnencodedSpace}}}
+	page.Assets = map[string]int{"https://test.com/logo.png": 404, "https://test.com/site.css": 200}
+	if _, err := site.AddPage(page); err != nil {
+		t.Fatal(err)
+	}
+	loops := []*RedirectLoopError{{URL: "https://test.com/loop", Cycle: []string{"https://test.com/loop", "https://test.com/loop"}}}
+	violations := []AssertionViolation{{"index", "https://test.com/blog", "", "/index"}}
+	findings := CollectFindings(site, AuditInputs{RedirectLoops: loops, Violations: violations, MinTTL: DefaultMinTTL})
+	expected := "[{cache.short https://test.com/blog max-age=60 off} {vary.cookie https://test.com/blog Vary: Cookie off} " +
+		"{encoding.space https://test.com/blog /a b off} " +
+		"{redirects.loop https://test.com/loop https://test.com/loop -> https://test.com/loop off} " +
+		"{assets.missing https://test.com/blog https://test.com/logo.png off} {assertion.index https://test.com/blog /index off}]"
+	if got := fmt.Sprint(findings); got != expected {
+		t.Errorf("Incorrect findings: expected %s, got %s", expected, got)
+	}
+}
+
+func TestAuditBaseline(t *testing.T) {
+	findings := []Finding{
+		{Check: "cache.short", URL: "https://test.com/a"},
+		{Check: "cache.none", URL: "https://test.com/legacy/page"},
+		{Check: "cache.none", URL: "https://test.com/b"},
+		{Check: "vary.unusual", URL: "https://test.com/b"},
+		{Check: "redirects.loop", URL: "https://test.com/c"},
+		{Check: "encoding.space", URL: "https://test.com/d"},
+	}
+
+	// default severities
+	reported, suppressed := (*AuditBaseline)(nil).Apply(findings)
+	expected := "[{redirects.loop https://test.com/c  error} {cache.none https://test.com/b  warning} " +
+		"{cache.none https://test.com/legacy/page  warning} {encoding.space https://test.com/d  warning} " +
+		"{cache.short https://test.com/a  info} {vary.unusual https://test.com/b  info}]"
+	if got := fmt.Sprint(reported); got != expected || suppressed != 0 {
+		t.Errorf("Incorrect default findings: expected %s, got %s (%d suppressed)", expected, got, suppressed)
+	}
+
+	// configured severities and suppressions
+	fileName := filepath.Join(t.TempDir(), "audit.json")
+	contents := `{"severities": {"cache.*": "error", "cache.short": "info", "vary.unusual": "off"},
+		"suppressions": [{"check": "cache.*", "url": "/legacy/**", "reason": "old CMS"}, {"url": "https://test.com/d"}]}`
+	if err := os.WriteFile(fileName, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := LoadAuditBaseline(fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error loading baseline: %v", err)
+	}
+	reported, suppressed = baseline.Apply(findings)
+	expected = "[{cache.none https://test.com/b  error} {redirects.loop https://test.com/c  error} {cache.short https://test.com/a  info}]"
+	if got := fmt.Sprint(reported); got != expected || suppressed != 2 {
+		t.Errorf("Incorrect findings: expected %s with 2 suppressed, got %s (%d suppressed)", expected, got, suppressed)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintAuditFindings(&buf, reported, suppressed, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Audit findings (3, 2 suppressed)", " Error:\n     no caching headers: https://test.com/b [cache.none]\n",
+		" Info:\n     short TTL: https://test.com/a [cache.short]\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Incorrect audit report: expected %q in %s", line, buf.String())
+		}
+	}
+
+	// invalid baselines are rejected
+	for _, invalid := range []string{`{"severities": {"cache.*": "fatal"}}`, `{"suppressions": [{"check": "[cache"}]}`, `[]`} {
+		if err := os.WriteFile(fileName, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadAuditBaseline(fileName); err == nil {
+			t.Errorf("Missing expected error loading invalid baseline %s", invalid)
+		}
+	}
+}
+
+func TestAuditBaselineNewFindings(t *testing.T) {
+	baseline := &AuditBaseline{Severities: map[string]Severity{"cache.*": SeverityInfo}}
+	baseline.Record([]Finding{
+		{Check: "encoding.space", URL: "https://test.com/a", Detail: "/a b", Severity: SeverityWarning},
+		{Check: "cache.none", URL: "https://test.com/b", Detail: "Expires: Fri, 01 Mar 2024 12:00:00 GMT", Severity: SeverityInfo},
+	})
+	fileName := filepath.Join(t.TempDir(), "audit.json")
+	if err := baseline.Save(fileName); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAuditBaseline(fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error loading baseline: %v", err)
+	}
+	if fmt.Sprint(loaded.Findings) != fmt.Sprint(baseline.Findings) || loaded.Severities["cache.*"] != SeverityInfo {
+		t.Errorf("Incorrect baseline loaded: expected %+v, got %+v", baseline, loaded)
+	}
+
+	// volatile details (e.g. cache headers) are ignored, but other details must match
+	findings := []Finding{
+		{Check: "encoding.space", URL: "https://test.com/a", Detail: "/a b"},
+		{Check: "encoding.space", URL: "https://test.com/a", Detail: "/c d"},
+		{Check: "cache.none", URL: "https://test.com/b", Detail: "Expires: Sat, 02 Mar 2024 12:00:00 GMT"},
+		{Check: "cache.none", URL: "https://test.com/c"},
+	}
+	expected := "[{encoding.space https://test.com/a /c d off} {cache.none https://test.com/c  off}]"
+	if got := fmt.Sprint(loaded.NewFindings(findings)); got != expected {
+		t.Errorf("Incorrect new findings: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintAuditFindings(&buf, nil, 0, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Audit findings (0, 0 suppressed) -----\n (2 findings recorded in the baseline not shown)") {
+		t.Errorf("Incorrect audit report: got %s", buf.String())
+	}
+}
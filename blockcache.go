@@ -0,0 +1,128 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// BlockCache stores URLs which consistently fail to load because access is denied (401 and 403 responses),
+// so later crawls of the same site don't retry them every run. The cache is persisted to a JSON file
+// between runs.
+//
+// A URL is blocked once it has failed in at least threshold separate crawls, with only the first failure of
+// a URL in each crawl (see StartCrawl) counted, however many times it is requested. Blocks expire after the
+// expiry period from that failure, at which point the URL is retried; if it fails again it is immediately
+// blocked again and if it loads successfully it is removed from the cache.
+//
+// The BlockCache is safe for concurrent use.
+type BlockCache struct {
+	fileName  string        // file the cache is loaded from and saved to
+	threshold int           // number of failed crawls before a URL is blocked
+	expiry    time.Duration // time after the last failure that a URL is retried
+
+	mutex   sync.Mutex
+	entries map[string]*BlockEntry // failure details for each URL
+	failed  map[string]bool        // URLs whose failure has been counted in the current crawl
+}
+
+// BlockEntry records the failures for a single URL
+type BlockEntry struct {
+	StatusCode   int       `json:"status"`       // status code returned by the last failure
+	Failures     int       `json:"failures"`     // number of crawls in which the URL failed
+	FirstFailure time.Time `json:"firstFailure"` // time of the first recorded failure
+	LastFailure  time.Time `json:"lastFailure"`  // time of the most recent failure
+}
+
+// blockableStatus is the set of status codes which indicate we are not allowed to load a URL
+var blockableStatus = map[int]bool{401: true, 403: true}
+
+// LoadBlockCache loads the block cache from the supplied file. If the file does not exist an empty
+// cache is returned, which will be created when saved.
+func LoadBlockCache(fileName string, threshold int, expiry time.Duration) (*BlockCache, error) {
+	cache := &BlockCache{
+		fileName:  fileName,
+		threshold: threshold,
+		expiry:    expiry,
+		entries:   make(map[string]*BlockEntry),
+		failed:    make(map[string]bool),
+	}
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, fmt.Errorf("invalid block cache file %s: %v", fileName, err)
+	}
+	return cache, nil
+}
+
+// Save writes the block cache back to its file
+func (cache *BlockCache) Save() error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	data, err := json.MarshalIndent(cache.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cache.fileName, data, 0644)
+}
+
+// IsBlocked checks if a URL should be skipped at the supplied time
+func (cache *BlockCache) IsBlocked(urlStr string, now time.Time) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, found := cache.entries[urlStr]
+	if !found || entry.Failures < cache.threshold {
+		return false
+	}
+	return cache.expiry == 0 || now.Before(entry.LastFailure.Add(cache.expiry))
+}
+
+// StartCrawl starts counting the failures of a new crawl, so the next failure of each URL is counted again
+func (cache *BlockCache) StartCrawl() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.failed = make(map[string]bool)
+}
+
+// RecordFailure records a failed load of a URL. Only failures with a blockable status code are recorded,
+// returning true if this was one. Later failures of the URL in the same crawl aren't counted again.
+func (cache *BlockCache) RecordFailure(urlStr string, statusCode int, now time.Time) bool {
+	if !blockableStatus[statusCode] {
+		return false
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.failed[urlStr] {
+		return true
+	}
+	cache.failed[urlStr] = true
+	entry, found := cache.entries[urlStr]
+	if !found {
+		entry = &BlockEntry{FirstFailure: now}
+		cache.entries[urlStr] = entry
+	}
+	entry.StatusCode = statusCode
+	entry.Failures++
+	entry.LastFailure = now
+	return true
+}
+
+// RecordSuccess records a successful load of a URL, removing it from the cache
+func (cache *BlockCache) RecordSuccess(urlStr string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	delete(cache.entries, urlStr)
+}
+
+// Len returns the number of URLs in the cache (whether or not they are currently blocked)
+func (cache *BlockCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return len(cache.entries)
+}
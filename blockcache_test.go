@@ -0,0 +1,106 @@
+package sitemap
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlockCache(t *testing.T) {
+
+	cache, err := LoadBlockCache(filepath.Join(t.TempDir(), "blocked.json"), 2, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error loading missing block cache: %v", err)
+	}
+	now := time.Now()
+	URL := "http://example.com/private"
+
+	// only access denied failures are recorded
+	if cache.RecordFailure(URL, http.StatusNotFound, now) || cache.Len() != 0 {
+		t.Fatal("Unexpected failure recorded for status 404")
+	}
+
+	// blocked after failing in 2 crawls
+	if !cache.RecordFailure(URL, http.StatusForbidden, now) {
+		t.Fatal("Failure not recorded for status 403")
+	}
+	if cache.IsBlocked(URL, now) {
+		t.Fatal("URL blocked after a single failure")
+	}
+
+	// failing again in the same crawl isn't counted
+	cache.RecordFailure(URL, http.StatusForbidden, now)
+	if cache.IsBlocked(URL, now) {
+		t.Fatal("URL blocked after failing twice in a single crawl")
+	}
+	cache.StartCrawl()
+	cache.RecordFailure(URL, http.StatusUnauthorized, now)
+	if !cache.IsBlocked(URL, now.Add(time.Minute)) {
+		t.Fatal("URL not blocked after 2 failures")
+	}
+
+	// the block expires, then is renewed by another failure
+	if cache.IsBlocked(URL, now.Add(2*time.Hour)) {
+		t.Fatal("URL still blocked after expiry")
+	}
+	cache.StartCrawl()
+	cache.RecordFailure(URL, http.StatusForbidden, now.Add(2*time.Hour))
+	if !cache.IsBlocked(URL, now.Add(2*time.Hour)) {
+		t.Fatal("URL not blocked after failing again")
+	}
+
+	// success removes the URL
+	cache.RecordSuccess(URL)
+	if cache.IsBlocked(URL, now) || cache.Len() != 0 {
+		t.Fatal("URL still blocked after success")
+	}
+}
+
+func TestBlockCacheSaveLoad(t *testing.T) {
+
+	fileName := filepath.Join(t.TempDir(), "blocked.json")
+	cache, err := LoadBlockCache(fileName, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	cache.RecordFailure("http://example.com/a", http.StatusForbidden, now)
+	cache.RecordFailure("http://example.com/b", http.StatusForbidden, now)
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Unexpected error saving block cache: %v", err)
+	}
+
+	loaded, err := LoadBlockCache(fileName, 1, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error loading block cache: %v", err)
+	}
+	if loaded.Len() != 2 || !loaded.IsBlocked("http://example.com/a", now) || !loaded.IsBlocked("http://example.com/b", now) {
+		t.Fatalf("Incorrect block cache loaded: got %v", loaded.entries)
+	}
+}
+
+func TestCrawlBlockCache(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":  {"/a"},
+		"/a": {},
+	})
+	defer server.Close()
+
+	// block the only linked page
+	cache, err := LoadBlockCache(filepath.Join(t.TempDir(), "blocked.json"), 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.RecordFailure(server.URL+"/a", http.StatusForbidden, time.Now())
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithBlockCache(cache))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	if len(siteMap.Pages) != 1 {
+		t.Fatalf("Incorrect number of pages crawled: expected %d, got %d", 1, len(siteMap.Pages))
+	}
+}
@@ -0,0 +1,183 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// BreadcrumbProblem is a way the breadcrumb trail a page declares in its structured data is inconsistent
+// with the site structure found by crawling
+type BreadcrumbProblem int
+
+const (
+	BreadcrumbDepth       BreadcrumbProblem = iota // the trail's depth differs from the click depth of the page
+	BreadcrumbNotPage                              // the trail doesn't end at the page itself
+	BreadcrumbUnknown                              // an item of the trail isn't a page found by crawling
+	BreadcrumbNoLink                               // the parent in the trail doesn't link to the page
+	BreadcrumbUnreachable                          // the page isn't reachable by following links from the home page
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem BreadcrumbProblem) messageKey() string {
+	switch problem {
+	case BreadcrumbDepth:
+		return "breadcrumbs.depth"
+	case BreadcrumbNotPage:
+		return "breadcrumbs.notpage"
+	case BreadcrumbUnknown:
+		return "breadcrumbs.unknown"
+	case BreadcrumbNoLink:
+		return "breadcrumbs.nolink"
+	default:
+		return "breadcrumbs.unreachable"
+	}
+}
+
+// BreadcrumbIssue is a page whose declared breadcrumb trail is inconsistent with the site structure
+type BreadcrumbIssue struct {
+	URL      string              // URL of the page
+	Declared int                 // depth declared by the trail (the number of items before the page)
+	Depth    int                 // click depth of the page found by crawling (-1 if not reachable)
+	Problems []BreadcrumbProblem // problems found, in the order of BreadcrumbProblem
+	Detail   string              // the unknown item or parent, for BreadcrumbUnknown and BreadcrumbNoLink
+}
+
+// parseBreadcrumbs returns the URLs of the items of the first BreadcrumbList in a JSON-LD script as they are
+// written, ordered by position, with an empty string for an item without a URL (which is taken to be the page
+// itself, as is common for the last item). Returns nil if the script has no BreadcrumbList or isn't valid JSON.
+func parseBreadcrumbs(script string) []string {
+	var data any
+	if err := json.Unmarshal([]byte(script), &data); err != nil {
+		return nil
+	}
+	list := findBreadcrumbList(data)
+	if list == nil {
+		return nil
+	}
+	elements, _ := list["itemListElement"].([]any)
+	type crumb struct {
+		position float64
+		ref      string
+	}
+	crumbs := make([]crumb, 0, len(elements))
+	for i, element := range elements {
+		item, ok := element.(map[string]any)
+		if !ok {
+			continue
+		}
+		position, found := item["position"].(float64)
+		if !found {
+			position = float64(i + 1)
+		}
+		var ref string
+		switch target := item["item"].(type) {
+		case string:
+			ref = target
+		case map[string]any:
+			if ref, _ = target["@id"].(string); len(ref) == 0 {
+				ref, _ = target["url"].(string)
+			}
+		}
+		crumbs = append(crumbs, crumb{position, ref})
+	}
+	sort.SliceStable(crumbs, func(i, j int) bool { return crumbs[i].position < crumbs[j].position })
+	refs := make([]string, len(crumbs))
+	for i, crumb := range crumbs {
+		refs[i] = crumb.ref
+	}
+	return refs
+}
+
+// findBreadcrumbList returns the first object with the BreadcrumbList type in decoded JSON-LD, searching
+// arrays and @graph lists, or nil if there is none
+func findBreadcrumbList(data any) map[string]any {
+	switch value := data.(type) {
+	case []any:
+		for _, item := range value {
+			if list := findBreadcrumbList(item); list != nil {
+				return list
+			}
+		}
+	case map[string]any:
+		if hasJSONLDType(value, "BreadcrumbList") {
+			return value
+		}
+		return findBreadcrumbList(value["@graph"])
+	}
+	return nil
+}
+
+// hasJSONLDType checks if a JSON-LD object has the type, which may be one of a list of types
+func hasJSONLDType(object map[string]any, name string) bool {
+	switch types := object["@type"].(type) {
+	case string:
+		return types == name || types == "https://schema.org/"+name || types == "http://schema.org/"+name
+	case []any:
+		for _, t := range types {
+			if s, ok := t.(string); ok && hasJSONLDType(map[string]any{"@type": s}, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BreadcrumbIssues compares the breadcrumb trail declared by each page (see WebPage.Breadcrumbs) with the
+// site structure found by crawling, returning the pages where they are inconsistent sorted by URL. A trail
+// from the home page down is expected to have the click depth of the page, end at the page itself, contain
+// only pages on the site and have a parent which links to the page.
+func (site *SiteMap) BreadcrumbIssues() []BreadcrumbIssue {
+	heights := site.getMinimumHeights()
+	var issues []BreadcrumbIssue
+	for key, page := range site.Pages {
+		if len(page.Breadcrumbs) == 0 {
+			continue
+		}
+		issue := BreadcrumbIssue{URL: page.URL.String(), Declared: len(page.Breadcrumbs) - 1, Depth: -1}
+		if height, found := heights[key]; found {
+			issue.Depth = height
+			if height != issue.Declared {
+				issue.Problems = append(issue.Problems, BreadcrumbDepth)
+			}
+		}
+		if site.lookupKey(page.Breadcrumbs[len(page.Breadcrumbs)-1]) != key {
+			issue.Problems = append(issue.Problems, BreadcrumbNotPage)
+		}
+		for _, crumb := range page.Breadcrumbs {
+			if _, found := site.Pages[site.lookupKey(crumb)]; !found {
+				issue.Problems = append(issue.Problems, BreadcrumbUnknown)
+				issue.Detail = crumb
+				break
+			}
+		}
+		if len(issue.Problems) == 0 && len(page.Breadcrumbs) > 1 {
+			parent := page.Breadcrumbs[len(page.Breadcrumbs)-2]
+			if !site.linksTo(site.lookupKey(parent), key) {
+				issue.Problems = append(issue.Problems, BreadcrumbNoLink)
+				issue.Detail = parent
+			}
+		}
+		if issue.Depth < 0 {
+			issue.Problems = append(issue.Problems, BreadcrumbUnreachable)
+		}
+		if len(issue.Problems) != 0 {
+			issues = append(issues, issue)
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].URL < issues[j].URL })
+	return issues
+}
+
+// linksTo checks if the page stored under key links to the page stored under target
+func (site *SiteMap) linksTo(key string, target string) bool {
+	page, found := site.Pages[key]
+	if !found {
+		return false
+	}
+	for link := range page.InternalLinks {
+		if site.lookupKey(link) == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseBreadcrumbs(t *testing.T) {
+
+	tests := map[string]struct {
+		script   string
+		expected string
+	}{
+		"urls": {`{"@context": "https://schema.org", "@type": "BreadcrumbList", "itemListElement": [
+			{"@type": "ListItem", "position": 2, "name": "Blog", "item": "https://test.com/blog"},
+			{"@type": "ListItem", "position": 1, "name": "Home", "item": "https://test.com/"},
+			{"@type": "ListItem", "position": 3, "name": "Post"}]}`, "[https://test.com/ https://test.com/blog ]"},
+		"graph": {`{"@graph": [{"@type": "WebPage"}, {"@type": ["Thing", "BreadcrumbList"], "itemListElement": [
+			{"item": {"@id": "/docs"}}, {"item": {"url": "/docs/api"}}]}]}`, "[/docs /docs/api]"},
+		"no list": {`[{"@type": "Article"}]`, "[]"},
+		"invalid": {`{"@type": "BreadcrumbList",`, "[]"},
+	}
+	for name, test := range tests {
+		if got := fmt.Sprint(parseBreadcrumbs(test.script)); got != test.expected {
+			t.Errorf("Incorrect breadcrumbs for %s: expected %v, got %v", name, test.expected, got)
+		}
+	}
+}
+
+func TestParseDocumentBreadcrumbs(t *testing.T) {
+
+	doc := `<html><head><script type="application/ld+json">
+		{"@type": "BreadcrumbList", "itemListElement": [{"position": 1, "item": "/"}, {"position": 2, "item": "blog/"},
+		{"position": 3}]}</script></head><body></body></html>`
+	parser := CreateDocumentParser()
+	parser.Breadcrumbs = true
+	page, err := parser.ParseDocument("https://test.com/blog/post", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if expected := "[https://test.com https://test.com/blog/blog https://test.com/blog/post]"; fmt.Sprint(page.Breadcrumbs) != expected {
+		t.Errorf("Incorrect breadcrumbs: expected %v, got %v", expected, page.Breadcrumbs)
+	}
+
+	if page, _ = CreateDocumentParser().ParseDocument("https://test.com/blog/post", strings.NewReader(doc)); page.Breadcrumbs != nil {
+		t.Errorf("Incorrect breadcrumbs when not requested: expected none, got %v", page.Breadcrumbs)
+	}
+}
+
+func TestBreadcrumbIssues(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	pages := map[string]struct {
+		links       []string
+		breadcrumbs []string
+	}{
+		"":           {[]string{"/blog", "/blog/post", "/about"}, nil},
+		"/blog":      {[]string{"/blog/post"}, []string{"", "/blog"}},
+		"/blog/post": {nil, []string{"", "/blog", "/blog/post"}},
+		"/about":     {[]string{"/team"}, []string{"", "/company", "/about"}},
+		"/team":      {nil, []string{"", "/about", "/other"}},
+		"/orphan":    {nil, []string{"", "/orphan"}},
+	}
+	for path, details := range pages {
+		page := createWebPage(t, "https://test.com"+path, path)
+		for _, link := range details.links {
+			page.AddLink("https://test.com"+link, Link{})
+		}
+		for _, crumb := range details.breadcrumbs {
+			page.Breadcrumbs = append(page.Breadcrumbs, "https://test.com"+crumb)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	issues := site.BreadcrumbIssues()
+	expected := "[{https://test.com/about 2 1 [0 2] https://test.com/company} " +
+		"{https://test.com/blog/post 2 1 [0] } " +
+		"{https://test.com/orphan 1 -1 [3 4] https://test.com} " +
+		"{https://test.com/team 2 2 [1 2] https://test.com/other}]"
+	if got := fmt.Sprint(issues); got != expected {
+		t.Errorf("Incorrect breadcrumb issues: expected %v, got %v", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintBreadcrumbIssues(&buf, issues[:2], nil); err != nil {
+		t.Fatalf("Failed to print breadcrumb issues: %v", err)
+	}
+	expectedReport := "\n\n ----- Pages with inconsistent breadcrumbs (2) -----\n" +
+		" https://test.com/about: breadcrumb depth 2 but 1 clicks from the home page, breadcrumb https://test.com/company not found by crawling\n" +
+		" https://test.com/blog/post: breadcrumb depth 2 but 1 clicks from the home page\n"
+	if buf.String() != expectedReport {
+		t.Errorf("Incorrect breadcrumb report: expected %q, got %q", expectedReport, buf.String())
+	}
+}
@@ -0,0 +1,142 @@
+package sitemap
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMinTTL is the default time a page must be cacheable for to not be reported as having a short TTL
+const DefaultMinTTL = 5 * time.Minute
+
+// CacheProblem is a problem with the caching headers (Cache-Control and Expires) a page is served with
+type CacheProblem int
+
+const (
+	CacheNoHeaders   CacheProblem = iota // neither Cache-Control nor Expires is set
+	CacheUncacheable                     // caching is disabled (no-store, no-cache, max-age=0 or expired)
+	CacheShortTTL                        // cacheable for less than the minimum TTL
+	CacheConflicting                     // directives which contradict each other
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem CacheProblem) messageKey() string {
+	switch problem {
+	case CacheNoHeaders:
+		return "cache.none"
+	case CacheUncacheable:
+		return "cache.uncacheable"
+	case CacheShortTTL:
+		return "cache.short"
+	default:
+		return "cache.conflicting"
+	}
+}
+
+// CacheIssue is a page served with a problem in its caching headers
+type CacheIssue struct {
+	URL     string       // URL of the page
+	Problem CacheProblem // problem found
+	Detail  string       // header values showing the problem
+}
+
+// CacheGroup is the pages with caching problems sharing the same path prefix (first path segment)
+type CacheGroup struct {
+	Prefix string       // path prefix, e.g. /blog
+	Issues []CacheIssue // pages with caching problems, sorted by URL
+}
+
+// CheckCaching checks the caching headers of a response, returning the problems found (none if caching is
+// as expected). Expires is compared with the Date header, or the supplied time if there isn't one.
+func CheckCaching(header http.Header, minTTL time.Duration, now time.Time) []CacheProblem {
+	cacheControl := strings.Join(header.Values("Cache-Control"), ",")
+	expires := header.Get("Expires")
+	if len(strings.TrimSpace(cacheControl)) == 0 && len(expires) == 0 {
+		return []CacheProblem{CacheNoHeaders}
+	}
+
+	// collect the directives (which are case insensitive), keeping every value given for each
+	directives := make(map[string][]string)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name = strings.ToLower(strings.TrimSpace(name)); len(name) != 0 {
+			directives[name] = append(directives[name], strings.Trim(strings.TrimSpace(value), `"`))
+		}
+	}
+	_, noStore := directives["no-store"]
+	_, noCache := directives["no-cache"]
+	_, public := directives["public"]
+	_, private := directives["private"]
+
+	// the TTL comes from s-maxage (for shared caches such as CDNs), then max-age, then Expires
+	ttl, ttlSet, conflicting := time.Duration(0), false, public && private
+	for _, name := range []string{"s-maxage", "max-age"} {
+		values := directives[name]
+		for _, value := range values[min(1, len(values)):] {
+			conflicting = conflicting || value != values[0]
+		}
+		if len(values) != 0 && !ttlSet {
+			seconds, err := strconv.Atoi(values[0])
+			ttl, ttlSet = time.Duration(max(seconds, 0))*time.Second, true
+			if err != nil {
+				ttl = 0
+			}
+		}
+	}
+	if !ttlSet && len(expires) != 0 {
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+		ttlSet = true
+		if expiry, err := http.ParseTime(expires); err == nil && expiry.After(now) {
+			ttl = expiry.Sub(now)
+		}
+	}
+	conflicting = conflicting || ((noStore || noCache) && ttl > 0)
+
+	var problems []CacheProblem
+	switch {
+	case noStore || noCache || (ttlSet && ttl == 0):
+		problems = append(problems, CacheUncacheable)
+	case ttlSet && ttl < minTTL:
+		problems = append(problems, CacheShortTTL)
+	}
+	if conflicting {
+		problems = append(problems, CacheConflicting)
+	}
+	return problems
+}
+
+// CacheAudit checks the caching headers of every page loaded, returning the pages with problems grouped by
+// their path prefix (sorted). Pages with no recorded headers are skipped.
+func (site *SiteMap) CacheAudit(minTTL time.Duration, now time.Time) []CacheGroup {
+	groups := make(map[string][]CacheIssue)
+	for _, page := range site.Pages {
+		if page.Header == nil {
+			continue
+		}
+		detail := strings.Join(page.Header.Values("Cache-Control"), ", ")
+		if expires := page.Header.Get("Expires"); len(expires) != 0 {
+			detail = strings.TrimPrefix(detail+"; Expires: "+expires, "; ")
+		}
+		for _, problem := range CheckCaching(page.Header, minTTL, now) {
+			prefix := pathPrefix(page.URL.Path)
+			groups[prefix] = append(groups[prefix], CacheIssue{page.URL.String(), problem, detail})
+		}
+	}
+	result := make([]CacheGroup, 0, len(groups))
+	for _, prefix := range sortedKeys(groups) {
+		issues := groups[prefix]
+		sort.SliceStable(issues, func(i, j int) bool { return issues[i].URL < issues[j].URL })
+		result = append(result, CacheGroup{prefix, issues})
+	}
+	return result
+}
+
+// pathPrefix returns the first segment of a URL path (e.g. /blog for /blog/2024/post), or / for the root
+func pathPrefix(urlPath string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	return "/" + segment
+}
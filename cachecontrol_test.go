@@ -0,0 +1,79 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckCaching(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		headers  map[string]string
+		expected []CacheProblem
+	}{
+		{map[string]string{}, []CacheProblem{CacheNoHeaders}},
+		{map[string]string{"Cache-Control": "public, max-age=3600"}, nil},
+		{map[string]string{"Cache-Control": "max-age=60, s-maxage=86400"}, nil},
+		{map[string]string{"Cache-Control": "max-age=60"}, []CacheProblem{CacheShortTTL}},
+		{map[string]string{"Cache-Control": "No-Store"}, []CacheProblem{CacheUncacheable}},
+		{map[string]string{"Cache-Control": "max-age=0, must-revalidate"}, []CacheProblem{CacheUncacheable}},
+		{map[string]string{"Cache-Control": "no-store, max-age=3600"}, []CacheProblem{CacheUncacheable, CacheConflicting}},
+		{map[string]string{"Cache-Control": "public, private, max-age=3600"}, []CacheProblem{CacheConflicting}},
+		{map[string]string{"Cache-Control": "max-age=3600, max-age=600"}, []CacheProblem{CacheConflicting}},
+		{map[string]string{"Expires": "Fri, 01 Mar 2024 13:00:00 GMT"}, nil},
+		{map[string]string{"Expires": "Fri, 01 Mar 2024 12:01:00 GMT"}, []CacheProblem{CacheShortTTL}},
+		{map[string]string{"Expires": "0"}, []CacheProblem{CacheUncacheable}},
+		{map[string]string{"Expires": "Fri, 01 Mar 2024 13:00:00 GMT", "Date": "Fri, 01 Mar 2024 12:58:00 GMT"}, []CacheProblem{CacheShortTTL}},
+	}
+	for _, test := range tests {
+		header := make(http.Header)
+		for name, value := range test.headers {
+			header.Set(name, value)
+		}
+		if got := CheckCaching(header, DefaultMinTTL, now); fmt.Sprint(got) != fmt.Sprint(test.expected) {
+			t.Errorf("Incorrect caching problems for %v: expected %v, got %v", test.headers, test.expected, got)
+		}
+	}
+}
+
+func TestCacheAudit(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	headers := map[string]string{
+		"":             "",
+		"/blog/b":      "no-cache",
+		"/blog/a":      "max-age=10",
+		"/about":       "max-age=86400",
+		"/shop/basket": "no-store, max-age=60",
+	}
+	for path, cacheControl := range headers {
+		page := createWebPage(t, "https://test.com"+path, path)
+		page.Header = make(http.Header)
+		if len(cacheControl) != 0 {
+			page.Header.Set("Cache-Control", cacheControl)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addPage(t, site, true, "https://test.com/unknown", "No headers recorded")
+
+	var buf bytes.Buffer
+	if err := PrintCacheAudit(&buf, site.CacheAudit(DefaultMinTTL, time.Now()), nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\n\n ----- Caching problems (5) -----\n" +
+		" / (1):\n" +
+		"     no caching headers: https://test.com\n" +
+		" /blog (2):\n" +
+		"     short TTL: https://test.com/blog/a (max-age=10)\n" +
+		"     caching disabled: https://test.com/blog/b (no-cache)\n" +
+		" /shop (2):\n" +
+		"     caching disabled: https://test.com/shop/basket (no-store, max-age=60)\n" +
+		"     conflicting directives: https://test.com/shop/basket (no-store, max-age=60)\n"
+	if buf.String() != expected {
+		t.Errorf("Incorrect cache report: expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,84 @@
+package sitemap
+
+import (
+	"sort"
+	"strconv"
+)
+
+// CanonicalProblem is a problem with the canonical URL a page declares with <link rel="canonical">
+type CanonicalProblem int
+
+const (
+	CanonicalOtherPage CanonicalProblem = iota // the canonical is a different page found by crawling
+	CanonicalChain                             // the canonical page declares a canonical of its own
+	CanonicalRedirect                          // the canonical redirects to another URL
+	CanonicalError                             // the canonical failed to load, e.g. with a 404
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem CanonicalProblem) messageKey() string {
+	switch problem {
+	case CanonicalOtherPage:
+		return "canonical.other"
+	case CanonicalChain:
+		return "canonical.chain"
+	case CanonicalRedirect:
+		return "canonical.redirect"
+	default:
+		return "canonical.error"
+	}
+}
+
+// CanonicalIssue is a page whose declared canonical URL is a problem
+type CanonicalIssue struct {
+	URL       string           // URL of the page declaring the canonical
+	Canonical string           // canonical URL declared
+	Problem   CanonicalProblem // problem found
+	Detail    string           // canonical of the canonical page, URL redirected to or status (or error class) failed with
+}
+
+// CanonicalIssues checks the canonical URL declared by each page crawled, returning those pointing to another
+// page found by crawling (so the page is folded into that one), to a page which isn't self-canonical as it
+// declares a canonical of its own (a chain), to a URL which redirects, or to a URL which failed to load.
+// Canonicals to URLs which were not requested are not reported. Issues are sorted by URL.
+func (site *SiteMap) CanonicalIssues() []CanonicalIssue {
+	declared := make(map[string]string, len(site.canonicals))
+	for urlStr, canonical := range site.canonicals {
+		declared[site.pageKey(urlStr)] = canonical
+	}
+	loaded := make(map[string]bool, len(site.variants))
+	for urlStr := range site.variants {
+		loaded[site.pageKey(urlStr)] = true
+	}
+	failures := make(map[string]LoadFailure, len(site.Errors))
+	for _, failure := range site.Errors {
+		failures[site.pageKey(failure.URL)] = failure
+	}
+
+	var issues []CanonicalIssue
+	for urlStr, canonical := range site.canonicals {
+		key := site.pageKey(canonical)
+		if key == site.pageKey(urlStr) {
+			continue // the page itself, written differently
+		}
+		issue := CanonicalIssue{URL: urlStr, Canonical: canonical}
+		if next, found := declared[key]; found && site.pageKey(next) != key {
+			issue.Problem, issue.Detail = CanonicalChain, next
+		} else if loaded[key] {
+			issue.Problem = CanonicalOtherPage
+		} else if failure, found := failures[key]; found {
+			issue.Problem, issue.Detail = CanonicalError, failure.Class.String()
+			if failure.StatusCode != 0 {
+				issue.Detail = strconv.Itoa(failure.StatusCode)
+			}
+		} else if page, found := site.Pages[site.Aliases[key]]; found {
+			// an alias of a page which wasn't loaded itself, so was redirected to the page
+			issue.Problem, issue.Detail = CanonicalRedirect, page.URL.String()
+		} else {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].URL < issues[j].URL })
+	return issues
+}
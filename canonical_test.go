@@ -0,0 +1,98 @@
+package sitemap
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// createCanonicalTestSite creates a site map of pages declaring canonicals: another page, a chain, a URL
+// which failed to load, a URL which redirects, a URL not requested and the page itself
+func createCanonicalTestSite(t *testing.T) *SiteMap {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	for _, page := range []struct {
+		url, canonical string
+		aliases        []string
+	}{
+		{"https://test.com/b", "", nil},
+		{"https://test.com/a", "https://test.com/b", nil},
+		{"https://test.com/e", "", nil},
+		{"https://test.com/d", "https://test.com/e", nil},
+		{"https://test.com/c", "https://test.com/d", nil},
+		{"https://test.com/f", "https://test.com/gone", nil},
+		{"https://test.com/new", "", []string{"https://test.com/old"}},
+		{"https://test.com/h", "https://test.com/old", nil},
+		{"https://test.com/i", "https://test.com/unknown", nil},
+		{"https://test.com/j", "https://test.com/j/", nil},
+	} {
+		webPage := createWebPage(t, page.url, "")
+		webPage.Canonical = page.canonical
+		for _, alias := range page.aliases {
+			webPage.Aliases[alias] = true
+		}
+		if _, err := site.AddPage(webPage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	site.AddErrors([]LoadFailure{{URL: "https://test.com/gone", Class: LoadErrorStatus, StatusCode: 404, Attempts: 1}})
+	return site
+}
+
+func TestCanonicalIssues(t *testing.T) {
+
+	site := createCanonicalTestSite(t)
+	expected := []CanonicalIssue{
+		{"https://test.com/a", "https://test.com/b", CanonicalOtherPage, ""},
+		{"https://test.com/c", "https://test.com/d", CanonicalChain, "https://test.com/e"},
+		{"https://test.com/d", "https://test.com/e", CanonicalOtherPage, ""},
+		{"https://test.com/f", "https://test.com/gone", CanonicalError, "404"},
+		{"https://test.com/h", "https://test.com/old", CanonicalRedirect, "https://test.com/new"},
+	}
+	if issues := site.CanonicalIssues(); !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Incorrect canonical issues: expected %v, got %v", expected, issues)
+	}
+
+	// the canonicals declared are saved with the site map
+	var buf bytes.Buffer
+	if err := site.Save(&buf); err != nil {
+		t.Fatalf("Failed to save site map: %v", err)
+	}
+	loaded, err := LoadSiteMap(&buf)
+	if err != nil {
+		t.Fatalf("Failed to load site map: %v", err)
+	}
+	if issues := loaded.CanonicalIssues(); !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Incorrect canonical issues after loading: expected %v, got %v", expected, issues)
+	}
+
+	buf.Reset()
+	if err := PrintCanonicalIssues(&buf, expected[:3], nil); err != nil {
+		t.Fatalf("Failed to print canonical issues: %v", err)
+	}
+	expectedReport := "\n\n ----- Pages with canonical problems (3) -----\n" +
+		" https://test.com/a -> https://test.com/b (another page crawled)\n" +
+		" https://test.com/c -> https://test.com/d (declares canonical https://test.com/e)\n" +
+		" https://test.com/d -> https://test.com/e (another page crawled)\n"
+	if buf.String() != expectedReport {
+		t.Errorf("Incorrect canonical report: expected %q, got %q", expectedReport, buf.String())
+	}
+}
+
+func TestCanonicalFindings(t *testing.T) {
+
+	findings, _ := (*AuditBaseline)(nil).Apply(CollectFindings(createCanonicalTestSite(t), AuditInputs{}))
+	severities := make(map[string]Severity)
+	for _, finding := range findings {
+		if finding.URL == "https://test.com/c" || finding.URL == "https://test.com/f" || finding.URL == "https://test.com/a" {
+			severities[finding.Check+" "+finding.Detail] = finding.Severity
+		}
+	}
+	expected := map[string]Severity{
+		"canonical.other https://test.com/b":                       SeverityInfo,
+		"canonical.chain https://test.com/d -> https://test.com/e": SeverityWarning,
+		"canonical.error https://test.com/gone -> 404":             SeverityWarning,
+	}
+	if !reflect.DeepEqual(severities, expected) {
+		t.Errorf("Incorrect canonical findings: expected %v, got %v", expected, severities)
+	}
+}
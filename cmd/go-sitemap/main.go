@@ -0,0 +1,1673 @@
+//
+// Application site-map
+//
+// **************************************************************************************************************
+// PLEASE NOTE: THIS IS MY FIRST ATTEMPT AT A GOLANG APPLICATION. SOME CODING STYLES, USE OF LIBRARIES AND USE OF
+// STANDARD PATTERNS (OR LACK THERE OF) MAY NOT NOT BE IDEAL!
+// **************************************************************************************************************
+//
+// Overview:
+// Crawls a website starting at the supplied url or domain name and generates a site map of all the internal links.
+// Only links on the same domain are displayed.
+//
+// The site map is shown in a heirarchical view, with each page listing it's outgoing links.
+// Note that links can form cycles and individual pages are linked to from many pages. To reduce the size of the
+// display and avoid loops, the display is limited in 3 ways:
+//		1. 	No links to a page at a higher depth are displayed (where depth is defined as the the shortest number of
+//			links to a page from the root page). For example, a link from a page to it's parent would not be displayed,
+//			but links to its children or to another page at the same depth are.
+//		2. 	Links from a page back to itself are ignored
+//		3.	Each page is only expanded once, and at the highest level at which it occurs. This means if a page appears
+//			multiple times at the same level, its children will only be displayed the first time it appears
+//
+// When run in a terminal, a progress bar showing the number of pages loaded, queued and failed is displayed
+// while crawling.
+//
+// By default, some throttling is done to avoid loading pages from a website too quickly. This is purely to limit
+// any problems with the site. In addition, a limit to the number of simultaneous requests is also
+// set. Both of these are controllable with command lime switches.
+//
+// Limits can also be set on how many pages will be loaded in total and/or the depth to crawl the website. By default
+// no limits are applied.
+//
+// Usage:
+// 			Usage of go-sitemap
+//				-assertions string
+//					JSON file of assertions checked against each page as it is crawled (e.g. every page under /docs
+//					must link to /docs/index), with violations reported (default: None)
+//				-assets
+//					set to record the static assets (images, scripts, stylesheets and srcset images) and resource
+//					hints (preload, modulepreload, prefetch and prerender) on the domain used by each page, listed
+//					per page in the text and json output
+//				-assets-check
+//					set to check the static assets and resource hints recorded with -assets exist with a HEAD
+//					request to each, with missing (broken) assets and broken or redirected hints reported. The
+//					site's /favicon.ico, page icons and web app manifests are also checked, with pages missing
+//					them reported
+//				-audit
+//					set to report the findings of every audit check (caching and Vary headers, href encoding,
+//					redirect loops, canonical URLs, plus broken assets, thin content and assertions when enabled) in one
+//					list by severity
+//				-auth-report
+//					set to report the URLs redirecting to a login page (see -login-pattern), which need
+//					authentication to be crawled
+//				-baseline string
+//					JSON audit baseline setting the severity of each audit check and suppressing accepted
+//					findings by check and URL (default: None)
+//				-block-after int
+//					number of crawls a URL must be denied access (401 or 403) in before it is blocked (default 2)
+//				-block-cache string
+//					file storing URLs denied access in previous crawls, which are skipped (default: None)
+//				-block-expiry duration
+//					time after which a blocked URL is retried (default 168h0m0s)
+//				-breadcrumb-report
+//					set to record the breadcrumb trail each page declares in JSON-LD BreadcrumbList structured data
+//					and report pages where it is inconsistent with the site structure found by crawling: a trail
+//					depth differing from the click depth of the page, a trail not ending at the page or including
+//					pages not found, or a parent in the trail not linking to the page
+//				-byte-budget int
+//					maximum megabytes of pages to download, after which no more pages are loaded (with the URLs left
+//					saved to -state), 0 means no limit (default 0)
+//				-ca-cert string
+//					PEM file of CA certificates trusted as well as the system's, to crawl sites with certificates
+//					issued by an internal CA (default: None)
+//				-cache-report
+//					set to report pages served with no caching headers, caching disabled, a TTL shorter than
+//					-min-ttl or conflicting Cache-Control directives, grouped by the first segment of their path
+//				-canonical-host string
+//					hosts the site is mapped on: apex or www to treat example.com and www.example.com as the same site with
+//					every URL rewritten to that host, or exact to only map the host crawled (default: both hosts, as found)
+//				-canonical-report
+//					set to report pages whose canonical URL (declared with <link rel="canonical">) is another page
+//					crawled, a page which isn't self-canonical as it declares a canonical of its own (a chain), a URL
+//					which redirects or a URL which failed to load. These are also audit checks (canonical.*)
+//				-client-cert string
+//					PEM file of a client certificate presented to servers requesting one (mutual TLS), with its
+//					private key in -client-key (default: None)
+//				-client-key string
+//					PEM file of the private key of the -client-cert (default: None)
+//				-command-concurrency int
+//					maximum number of page commands run at once (default 1)
+//				-command-failure string
+//					what happens to a page when its page command fails: ignore, discard (the page and its links)
+//					or abort (stop crawling and exit with an error) (default "ignore")
+//				-command-retries int
+//					number of times a failed page or end command is retried (default 0)
+//				-command-timeout duration
+//					maximum time for each run of a page or end command, 0 means no limit (default 30s)
+//				-conditional
+//					set to request the pages recorded in the -previous crawl conditionally (If-None-Match and
+//					If-Modified-Since), reusing their previous details rather than reparsing them when the server
+//					responds 304 Not Modified
+//				-cpuprofile file
+//					write a CPU profile of the crawl to the file, for analysis with "go tool pprof" (not with -daemon)
+//				-daemon
+//					set to keep running, recrawling the site every -interval and serving the latest site map over
+//					HTTP on -listen at /sitemap.json (the JSON crawl document), /sitemap.xml and /status. Reports and
+//					output files are not written in this mode
+//				-daily-quota int
+//					maximum number of pages loaded per day, with the crawl resumed from -state on the next
+//					run once the quota is used up, 0 means no limit (default 0)
+//				-delay int
+//					minimum separation (in ms) between initiating loads from the server (default 100)
+//				-delta-sitemap string
+//					file a sitemap.xml is written to listing only the pages new or changed since the -previous
+//					crawl, with their lastmod set to when they were last modified (from the Last-Modified header,
+//					or the time of this crawl if not known), an xhtml:link for each of their hreflang language
+//					variants and a video:video for each video recorded with -videos (default: None)
+//				-deep-threshold int
+//					number of clicks from the starting page beyond which pages are reported as deep by the depth
+//					statistics (default 3)
+//				-depth int
+//					maximum depth to crawl to, 0 means no limit (default 0)
+//				-depth-report
+//					set to report depth statistics: pages at each depth, the average and maximum click distance
+//					from the starting page, leaf pages and pages deeper than -deep-threshold
+//				-deterministic
+//					alias for -stable-output
+//				-directory-report
+//					set to check each directory containing pages (e.g. /blog/ and /blog/2024/ for /blog/2024/post)
+//					has an index page, requesting those not crawled and reporting directories exposing a listing of
+//					their files generated by the web server or returning errors
+//				-dns-cache-ttl duration
+//					how long the addresses host names resolve to are cached for, so large crawls don't look up the
+//					same name for every connection. 0 looks names up for every connection (default 5m0s)
+//				-drop-params string
+//					comma separated query parameters removed from links, where a trailing * matches any suffix
+//					and "default" adds common tracking parameters (e.g. utm_*,fbclid) (default: None)
+//				-drop-query
+//					set to remove query strings from links
+//				-duplicates-report
+//					set to report groups of pages with identical content or near identical text
+//				-encoding-report
+//					set to report internal links whose href is not in its canonical encoding (e.g. unencoded
+//					spaces or lower case percent-encodings), which can create duplicate URLs for the same page
+//				-end-command string
+//					command run once crawling is complete, with the JSON crawl document on stdin (default: None)
+//				-fail-on-violation
+//					set to exit with an error once the site map is written if any -assertions failed
+//				-fail-on string
+//					exit with an error once the site map is written if there are -audit findings of this
+//					severity or higher: info, warning or error (default: None)
+//				-feed-links
+//					set to request the RSS and Atom feeds on the site declared by pages, following the items in each
+//					feed as links from the page declaring it, so pages only linked from feeds are mapped. Each feed is
+//					only requested once
+//				-feed-report
+//					set to report the RSS and Atom feeds declared by pages (with <link rel="alternate">), with the
+//					section of the site declaring each: the longest path shared by the pages declaring it
+//				-flush-interval duration
+//					how often the pages written to -stream-dir are synced to disk and recorded in its manifest,
+//					with later pages written to a new part file (default 30s)
+//				-format string
+//					output format: text, json, csv (one row per page, listing the pages linking to it), html
+//					(a table of pages with their PageRank), sql (a dump creating pages and links tables, which
+//					loads into PostgreSQL, MySQL and SQLite), gexf or graphml (the link graph, with the depth,
+//					title and status of each page and the anchor text of each link, for network analysis in
+//					Gephi or Cytoscape), xml (a sitemap.xml listing every page, with the changefreq and priority
+//					set by -sitemap-rules) or template (rendered with -template). Every format other than xml and
+//					template lists the URLs which failed to load, with the class of error and the pages linking to
+//					them. Reports which only add to the text output (-audit, -sitemap-xml and the -*-report flags
+//					other than -breadcrumb-report and -schema-report, which also record details of each page) are
+//					rejected with other formats (default "text")
+//				-h2-headings
+//					set to also record the text of the H2 headings of each page, written to the JSON crawl document with
+//					its H1 headings
+//				-hash-routes
+//					set to keep #/ and #!/ fragments in URLs, mapping each route of a hash-routed single page app as a
+//					separate page rather than a single entry. Routes are usually only linked to by scripts, so use with
+//					-render js
+//				-heading-report
+//					set to report pages with no H1 heading or more than one, with the text of each H1
+//				-hreflang-report
+//					set to report the language variants of pages declared with <link rel="alternate" hreflang>,
+//					grouping pages which are variants of each other
+//				-http2
+//					use HTTP/2 with servers supporting it, multiplexing the concurrent loads over one connection.
+//					Set -http2=false to only use HTTP/1.1, with a connection for each concurrent load (default true)
+//				-idle-timeout duration
+//					how long idle connections to the server are kept open for reuse (default 1m30s)
+//				-inlinks-report int
+//					number of most and least linked to pages to report, 0 means no report (default 0)
+//				-insecure-skip-verify
+//					set to accept any server certificate, including self-signed and expired ones. This is insecure,
+//					so only use it for internal staging hosts
+//				-interval duration
+//					time between the start of each crawl with -daemon (default 6h0m0s)
+//				-lang string
+//					language reports are written in: en, de, es or fr (default "en")
+//				-listen string
+//					address the latest site map is served on with -daemon (default "localhost:8080")
+//				-load string
+//					file of a site map saved with -save, which is written (in any output format) instead of crawling
+//					the site. The site is the one the site map was saved from, so -s is ignored (default: None)
+//				-local-dir string
+//					directory of a static site build (e.g. Hugo's public/ or Jekyll's _site/) to map rather than
+//					the live site, without deploying it first. Files are served as the site at the -s URL (which it
+//					will be deployed at), with a directory served by its index.html and a URL without an extension
+//					by its .html file, and every HTML file in the directory is mapped even if nothing links to it.
+//					Pages are loaded without throttling and links to other hosts fail (default: None)
+//				-log-format string
+//					format of the log written to stderr: text, or json to write a JSON object per line (with "time",
+//					"level" and "msg" fields plus the fields of the event) for log processing tools. JSON logs include
+//					every crawl event, as if -verbose: each URL loaded ("event" fetch-start and fetch-finish), queued
+//					(enqueue), skipped (skip) and failed (error). The progress bar isn't shown (default "text")
+//				-login-pattern string
+//					regular expression matching the URLs of login pages, with links redirecting to one reported
+//					as requiring authentication rather than mapped, empty for none (default matches typical login
+//					paths and hosts such as /login, /sign-in and sso.example.com)
+//				-max-duration duration
+//					maximum time for the whole crawl (e.g. 30m), 0 means no limit (default 0)
+//				-max-idle-per-host int
+//					idle connections kept open to the server for reuse. By default one is kept for each of the -t
+//					concurrent loads, so connections are reused rather than a new one opened (and TLS handshake
+//					made) for most requests
+//				-max-memory int
+//					memory use (in MB) at which the crawl stops, writing the output for the pages loaded so far (marked as
+//					truncated) rather than risk being killed for running out of memory. Nearing the limit, the crawl switches
+//					to the low memory mode of -memory-threshold, then pauses loading pages until memory use falls. The
+//					URLs not loaded are kept in the -state file, so the crawl can be resumed. 0 means no limit (default 0)
+//				-max-per-depth int
+//					maximum URLs crawled at each depth (e.g. 500 pages per level), so wide levels of a site such as
+//					thousands of category pages don't use up a crawl limited by -pages or -max-duration, 0 means no limit
+//					(default 0)
+//				-max-retry-after int
+//					longest time (in seconds) requests to a host are held off for when its server asks the crawler to
+//					slow down: a 429 or 503 response with a Retry-After header (or any 429) holds the host for the time
+//					asked, with the URL retried (up to 3 times) once the hold expires, and a response with X-RateLimit-Remaining
+//					(or RateLimit-Remaining) 0 holds it until X-RateLimit-Reset. URLs on other hosts are loaded as usual.
+//					0 ignores these headers, so rate limited URLs fail (default 60)
+//				-memory-threshold int
+//					memory use (in MB) above which the crawl switches to a low memory mode rather than risk running
+//					out of memory: the queue of URLs to load spills to a temporary file, the URLs already seen are
+//					stored as hashes and URLs are only passed to the loaders as they become free. 0 means no
+//					threshold (default 0)
+//				-memprofile file
+//					write a heap profile to the file once the crawl completes, showing the memory held by the site map
+//					and the allocations made during the crawl, for analysis with "go tool pprof" (not with -daemon)
+//				-meta-report
+//					set to report pages with a missing, duplicate or too long title or meta description (longer than 60
+//					and 160 characters, which search engines truncate), listing the pages with each problem
+//				-min-ttl duration
+//					minimum time pages should be cacheable for, with shorter TTLs reported by -cache-report
+//					(default 5m0s)
+//				-near-duplicate-bits int
+//					maximum number of bits the text similarity hashes of near identical pages differ by, with -1
+//					only reporting identical content (default 3)
+//				-new-findings
+//					set to only report -audit findings not recorded in the -baseline (see -write-baseline), so
+//					the audit can be used as a CI gate on a site with existing problems
+//				-no-keepalive
+//					set to open a new connection for every request rather than reusing connections
+//				-normalize string
+//					comma separated normalizations applied to URLs on the site before they are loaded and stored: https
+//					(rewrite http to https, unlike -scheme-policy which merges the variants once both are loaded),
+//					lowercase-host, lowercase-path (for case insensitive servers) and escapes (decode needlessly
+//					percent-encoded characters and uppercase the rest) (default: None)
+//				-order string
+//					order pages are written in: dfs (showing the link structure), bfs (grouped by depth), alpha
+//					(sorted by URL) or inlinks (most linked to first) (default "dfs")
+//				-out string
+//					site map destination, with none meaning write to console. Either a file name or an object in
+//					cloud storage: s3://bucket/key (using the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION
+//					and optionally AWS_SESSION_TOKEN and AWS_ENDPOINT_URL_S3 environment variables) or
+//					gs://bucket/key (using an access token in GOOGLE_OAUTH_ACCESS_TOKEN) (default: None)
+//				-page-command string
+//					command run for each crawled page, with the page's JSON record on stdin, its URL and depth in
+//					the GO_SITEMAP_URL and GO_SITEMAP_DEPTH environment variables and the URL requested (which
+//					differs from its URL if redirected) in GO_SITEMAP_REQUEST_URL (default: None)
+//				-pages int
+//					maximum number pages to load, 0 means no limit. URLs which fail to load don't count towards
+//					the limit, so exactly this many pages are mapped if the site has enough (default 0)
+//				-parse-workers int
+//					number of goroutines parsing the pages downloaded, separately from the -t goroutines making requests,
+//					so parsing large pages doesn't hold up downloads; 0 parses each page as it is downloaded (ignored with
+//					-stable-output or -render js) (default 0)
+//				-plugin string
+//					WebAssembly module filtering URLs and/or extracting page metadata, requiring a build with
+//					the wasmplugins tag (default: None)
+//				-precheck string
+//					checks made before loading a URL: none, ext (skip non-HTML file extensions) or head (ext
+//					plus a HEAD request to check the content type) (default "none")
+//				-previous string
+//					JSON crawl document (written with -format json) from a previous crawl of the site, which
+//					pages are compared with for -delta-sitemap and requested conditionally with -conditional
+//					(default: None)
+//				-priority string
+//					order URLs are crawled in: depth (closest to the starting page first) or path (fewest path segments
+//					first, e.g. /docs before /docs/intro). When -pages limits the crawl, the pages crawled are the
+//					highest priority URLs found rather than the first found (default: the order found)
+//				-probe-types string
+//					comma separated content types to request each page in, recording which the server
+//					provides (e.g. application/json,application/xml) (default: None)
+//				-protocol-report
+//					set to report the HTTP protocols (HTTP/1.1 or HTTP/2.0) and TLS versions pages were loaded with,
+//					listing the pages not loaded with the most common combination, to check a CDN or edge serves the
+//					whole site consistently. HTTP/3 isn't negotiated, so is only seen in Alt-Svc headers
+//				-proxy string
+//					URL of a proxy every request is sent through: http://, https://, socks5:// (host names
+//					resolved locally) or socks5h:// (resolved by the proxy), optionally with user:password@.
+//					Without -proxy or -proxy-list the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+//					are used (default: None)
+//				-proxy-list string
+//					file listing a proxy URL on each line (blank lines and lines starting with # are ignored),
+//					with requests sent through each of them (and any -proxy) in turn. Useful for large crawls of
+//					sites rate limiting each client address (default: None)
+//				-query-report
+//					set to report URLs returning identical content with and without their query string
+//				-redirect-report
+//					set to report URLs which redirect to themselves or form a redirect cycle, showing the cycle
+//				-render string
+//					how pages are read: html (the HTML served) or js (the DOM once the page is rendered in headless
+//					Chrome, so links added by JavaScript are found, e.g. on single page apps). Pages are still
+//					requested first to check their status and headers. Requires a build with the headless tag, and
+//					requests made by the browser don't use -proxy, -resolve or the TLS options (default "html")
+//				-render-pool int
+//					number of browser tabs pages are rendered in concurrently with -render js (default 2)
+//				-render-timeout duration
+//					maximum time to wait for a page to render with -render js (default 30s)
+//				-resolve string
+//					comma separated overrides of the address connections to a host are made to, each host:ip or
+//					host:port:ip as for curl --resolve (e.g. example.com:203.0.113.7). Useful for crawling a site
+//					before DNS cutover or behind split-horizon DNS, with the host name still used for TLS and the
+//					Host header (default: None)
+//				-root-path string
+//					only crawl URLs whose path is this prefix or below it (e.g. /docs, which doesn't include /docsearch),
+//					starting from the prefix if -s isn't under it. Links to the rest of the site are listed as boundary
+//					links rather than crawled (default: None)
+//				-s string
+//					site to crawl (default "en.wikipedia.org")
+//				-save string
+//					file the site map is saved to after the crawl, in a versioned binary format, so it can be
+//					reloaded with -load and written in other formats without crawling the site again (default: None)
+//				-schema
+//					print the JSON schema for the json output format and exit
+//				-schema-report
+//					set to record the schema.org types of the JSON-LD and microdata structured data on each page (written
+//					to the JSON crawl document) and report how many pages use each type, plus the pages missing types
+//					expected by -schema-rules
+//				-schema-rules string
+//					file of rules listing the structured data types expected on pages, one per line as a path glob (where
+//					** matches any characters including /), a colon, then the types separated by commas, e.g.
+//					/products/**: Product,BreadcrumbList. Every rule matching a page applies, and blank lines and lines
+//					starting with # are ignored. Requires -schema-report (default: None)
+//				-scheme-policy string
+//					how http and https variants of a page are mapped: distinct, https or http (default "distinct")
+//				-select-linking-to string
+//					only write pages linking to this URL (default: None)
+//				-select-max-depth int
+//					only write pages at most this many links from the starting page, 0 means no limit (default 0)
+//				-select-min-depth int
+//					only write pages at least this many links from the starting page (default 0)
+//				-select-orphans
+//					only write pages which can't be reached by following links from the starting page
+//				-select-path string
+//					only write pages whose path matches this glob, where ** matches any characters including /
+//					(e.g. /blog/**) (default: None)
+//				-sitemap-rules string
+//					file of rules setting the changefreq and priority of pages in the sitemap.xml files written
+//					(-format xml, -delta-sitemap and the -daemon's /sitemap.xml), one per line as a path glob
+//					(where ** matches any characters including /) or a click depth (depth=N, depth>=N or depth<=N),
+//					a colon, then a changefreq and/or priority separated by a comma, e.g. /blog/**: weekly,0.6 or
+//					depth>=3: monthly. The first rule matching a page which sets each value is used, and blank lines
+//					and lines starting with # are ignored
+//					(default: None)
+//				-sitemap-xml string
+//					URL or file of the site's sitemap.xml ("auto" for /sitemap.xml on the site) to report pages
+//					listed in it but not reachable by following links, and reachable pages missing from it (default: None)
+//				-soft-404
+//					set to request a nonexistent page before crawling to learn how the site responds to missing
+//					pages, reporting pages loaded successfully which look like its not found page (soft 404s)
+//				-sort-query
+//					set to sort the query parameters of links so parameter order doesn't create duplicates
+//				-stable-output
+//					set to crawl in a deterministic order, loading one page at a time (so -t is ignored) with the
+//					links out of each page followed in sorted order, and only take when pages were modified from
+//					their Last-Modified header (not the Date of the response), so crawls of an unchanged site give
+//					byte-identical output which can be diffed in version control (a crawl stopped by -max-duration
+//					is still timing dependent)
+//				-state string
+//					file storing the progress of the crawl, which is resumed from it on the next run if it was
+//					stopped by -pages, -max-duration or -daily-quota (default: None)
+//				-stream-dir string
+//					directory each page is written to as it is crawled, so a crash loses at most the last
+//					-flush-interval of results. Pages are written to numbered part files, with manifest.json listing
+//					the parts synced to disk (with their page count and SHA-256) and whether the crawl completed.
+//					Only details known as each page is loaded are written (no depth, inlinks or PageRank). WARC
+//					isn't supported as responses aren't kept (default: None)
+//				-stream-format string
+//					format pages are written to -stream-dir in: jsonl (one JSON page record per line) or csv
+//					(default "jsonl")
+//				-structured-links
+//					set to also follow same-domain URLs found outside hrefs: in the url, @id, item and other link
+//					properties of JSON-LD structured data, the target of meta refresh tags and the data-href,
+//					data-url and data-link attributes scripts make elements navigate with
+//				-t int
+//					maximum number of concurrent loads from the server (default 10)
+//				-template string
+//					text/template file the site map is rendered with for -format template. It is executed once with
+//					.Site (the SiteMap) and .Pages, the pages in the -order traversal with every WebPage field plus
+//					.Depth and .Inlinks (the pages linking to it). The join, repeat and indent (four spaces per depth)
+//					functions are available as well as the text/template builtins (default: None)
+//				-text-version int
+//					text output format version: 1 (original layout) or 2 (adds depth and status columns) (default 1)
+//				-thin-words int
+//					set to count the words of visible text on each page (written to the JSON crawl document), with pages
+//					with fewer words reported by -audit as thin content (content.thin). Word counts aren't recorded unless
+//					set (default: 0)
+//				-timeout int
+//					maximum time (in seconds) to load and parse a single page, 0 means no limit (default 60)
+//				-trace-endpoint string
+//					URL of an OpenTelemetry collector (OTLP over HTTP, e.g. http://localhost:4318) each URL crawled is
+//					exported to as a trace, with spans for fetching, parsing and ingesting the page. Requires a build with
+//					the otel tag (default: None)
+//				-trace-propagate
+//					add W3C trace context headers (traceparent) to the requests for each page, so the site's own
+//					spans join the crawler's traces (with -trace-endpoint) (default false)
+//				-trap-dates int
+//					maximum URLs loaded differing only by the dates in them (e.g. calendar pages), where a date is
+//					a year and month (e.g. /2024/03 or 2024-03-15), with further matching URLs skipped as a crawl
+//					trap, 0 means no limit (default 100)
+//				-trap-pages int
+//					maximum URLs loaded differing only by a page number or offset (e.g. ?page=12), with further
+//					matching URLs skipped as a crawl trap, 0 means no limit (default 200)
+//				-trap-repeats int
+//					maximum times a path segment may appear in a URL before it is treated as a crawl trap and
+//					skipped (e.g. /a/b/a/b/a/b), 0 means no limit (default 3)
+//				-vary-report
+//					set to request each page twice, reporting pages whose contents differ between the identical
+//					requests or which set suspicious Vary headers (e.g. User-Agent, Cookie or *), often caused by
+//					A/B testing or broken caching
+//				-verbose
+//					set to show extra logging
+//				-videos
+//					set to record the videos on each page: <video> elements (with their poster as thumbnail), embedded
+//					YouTube, Vimeo, Dailymotion, Wistia, Brightcove and Loom players, and the title, description, thumbnail
+//					and duration of JSON-LD VideoObject structured data. They are listed with the video sitemap extension
+//					in sitemap.xml files written and included in the JSON crawl document
+//				-write-baseline string
+//					file the audit findings are recorded in as a baseline for -new-findings on later runs,
+//					keeping the severities and suppressions of -baseline (default: None)
+//
+//			go-sitemap version [-check-update]
+//				shows the version and build details. With -check-update it also checks GitHub for a newer
+//				release (the only time anything other than the site being crawled is contacted)
+//
+//			go-sitemap serve [-listen addr] [-allow hosts] [-max-crawls n] [-pages n] [-depth n] [-delay ms]
+//							 [-t n] [-timeout s] [-max-duration d]
+//				runs an HTTP API crawling sites on demand, so other services can request site maps:
+//					POST /crawl?site=example.com	starts a crawl in the background, returning its id
+//					GET /status?id=...				the state of the crawl (running, complete or failed), or of
+//													every recent crawl if no id is given
+//					GET /sitemap.json?id=...		the JSON crawl document of a completed crawl
+//					GET /sitemap.xml?id=...			a sitemap.xml of a completed crawl
+//				Up to -max-crawls (default 2) crawls run at once, each limited to -pages (default 10000) pages
+//				and -max-duration (default 30m). Use -allow to restrict the hosts which may be crawled, as
+//				otherwise any site reachable from the server can be requested. The API listens on
+//				localhost:8080 by default and has no authentication, so should not be exposed publicly.
+//
+//			go-sitemap query <crawl.json> [command]
+//				loads a JSON crawl document (written with -format json) and runs the command, or reads commands
+//				interactively if none is given, printing the matching pages as a table:
+//					pages [where <conditions>] [limit <n>]	e.g. pages where depth > 3 and title contains "TODO"
+//					count [where <conditions>]				e.g. count where inlinks = 0
+//					fields									lists the fields which can be queried
+//				Conditions compare a field (url, path, title, depth, links, inlinks, pagerank, ...) with a value
+//				using =, !=, <, <=, >, >= or contains, joined by "and". Only pages loaded successfully are
+//				recorded in a crawl document, so failed URLs can't be queried.
+//
+// 	Example:
+//  			./go-sitemap -out monzo.txt -s monzo.com -delay 250
+//						Maps whole monzo.com domain, with a minimum 250 ms delay between starting each page load
+//						and a maximum of 10 concurrent loads. Resultong site map is written to mozo.txt file.
+//  			./go-sitemap -s example.com -previous last.json -delta-sitemap delta.xml -format json -out next.json
+//						Maps example.com writing the JSON crawl document to next.json, and a sitemap.xml of pages
+//						which are new or have changed since the crawl in last.json to delta.xml.
+//  			./go-sitemap -s example.com -videos -previous last.json -delta-sitemap delta.xml -format json -out next.json
+//						As above, also listing the videos on each page written to delta.xml with the video sitemap
+//						extension, for video search.
+//  			./go-sitemap -s example.com -previous last.json -delta-sitemap delta.xml -sitemap-rules rules.txt
+//						As above, setting the changefreq and priority of the pages in delta.xml from the rules in
+//						rules.txt, e.g. a line "/blog/**: weekly,0.6" for the blog and "depth=0: daily,1.0" for the
+//						home page.
+//  			./go-sitemap -s example.com -format xml -sitemap-rules rules.txt -out sitemap.xml
+//						Maps example.com writing a sitemap.xml of every page to sitemap.xml, with the changefreq
+//						and priority of the pages set from the rules in rules.txt.
+//  			./go-sitemap -s example.com -previous last.json -conditional -format json -out next.json
+//						Recrawls example.com, only reparsing pages which the server reports have changed since the
+//						crawl in last.json, and writes the updated JSON crawl document to next.json.
+//  			./go-sitemap -s example.com -daemon -interval 6h -listen :8080
+//						Keeps running, recrawling example.com every 6 hours, with the latest site map served at
+//						http://localhost:8080/sitemap.json (or /sitemap.xml) and the crawl status at /status.
+//  			AWS_REGION=eu-west-2 ./go-sitemap -s example.com -format json -out s3://my-bucket/sitemaps/example.json
+//						Maps example.com writing the JSON crawl document to my-bucket in S3, with the AWS credentials
+//						read from the environment.
+//  			./go-sitemap -s example.com -stable-output -format json -out sitemap/example.json
+//						Maps example.com in a deterministic order, so example.json can be committed to version control
+//						with each recrawl only showing the pages which changed.
+//  			./go-sitemap -s https://example.com -local-dir public -format json -out sitemap.json
+//						Maps the static site built in the public directory, as it will be when deployed to
+//						https://example.com, without deploying it first (e.g. in a CI pipeline).
+//  			./go-sitemap -s example.com -render js -render-pool 4 -t 4
+//						Maps the single page app at example.com, rendering each page in one of 4 headless Chrome
+//						tabs so links added by JavaScript are followed (requires a build with the headless tag).
+//  			./go-sitemap -s example.com -root-path /docs
+//						Maps only the documentation section of example.com, listing the links from it to the rest of
+//						the site at the end of the report.
+//  			./go-sitemap -s example.com -cpuprofile cpu.pprof -memprofile heap.pprof
+//						Maps example.com, writing CPU and heap profiles of the crawl, e.g. to view the functions using
+//						the most CPU with "go tool pprof -top go-sitemap cpu.pprof".
+//  			./go-sitemap -s example.com -log-format json 2> crawl.log
+//						Maps example.com, writing a JSON object to crawl.log for every crawl event, e.g. to count the
+//						URLs which failed to load with: jq 'select(.event == "error")' crawl.log
+//  			./go-sitemap -s example.com -trace-endpoint http://localhost:4318 -trace-propagate
+//						Maps example.com, exporting a trace of each URL crawled to the local OpenTelemetry collector,
+//						with the trace headers sent so the site's own spans appear in the same traces (requires a
+//						build with the otel tag).
+//  			./go-sitemap -s example.com -save example.gob -format json -out sitemap.json
+//  			./go-sitemap -load example.gob -format html -out sitemap.html
+//						Maps example.com writing a JSON site map and saving the crawl results to example.gob, then
+//						writes the same site map as HTML from the saved results without crawling the site again.
+//  			./go-sitemap -s example.com -format template -template report.tmpl -out report.md
+//						Maps example.com writing report.md laid out by report.tmpl, which could list each page with
+//						{{range .Pages}}{{indent .Depth}}- [{{.Title}}]({{.URL}}) {{len .Inlinks}} inlinks
+//						{{end}}
+//  			./go-sitemap -s example.com -format gexf -out example.gexf
+//						Maps example.com writing the link graph to example.gexf, to be opened in Gephi.
+//  			./go-sitemap -s example.com -feed-links -feed-report
+//						Maps example.com including the pages only linked from its RSS and Atom feeds, reporting
+//						each feed with the section of the site declaring it.
+//  			./go-sitemap -s example.com -meta-report
+//						Maps example.com reporting pages with a missing, duplicate or too long title or meta
+//						description.
+//  			./go-sitemap -s example.com -heading-report
+//						Maps example.com reporting pages with no H1 heading or more than one.
+//  			./go-sitemap -s example.com -canonical-report
+//						Maps example.com reporting pages whose canonical URL is another page, part of a chain, a
+//						redirect or a URL which failed to load.
+//  			./go-sitemap -s example.com -schema-report -schema-rules expected.txt
+//						Maps example.com reporting the schema.org types of the structured data on its pages, and the
+//						pages missing the types expected for their path in expected.txt.
+//  			./go-sitemap -s example.com -audit -thin-words 250
+//						Maps example.com reporting every audit finding, including the pages with fewer than 250 words
+//						of visible text.
+//  			./go-sitemap -s example.com -state example.json -daily-quota 5000
+//						Maps example.com loading at most 5000 pages a day. Run it again each day to continue the
+//						crawl until it is complete, with the site map so far written each time.
+//  			./go-sitemap -s example.com -assertions rules.json -fail-on-violation
+//						Maps example.com checking each page against the assertions in rules.json, exiting with an
+//						error if any fail. For example:
+//							[{"name": "docs index", "pages": "/docs/**", "mustLinkTo": "/docs/index"},
+//							 {"name": "no staging links", "mustNotLinkTo": "*.staging.example.com"}]
+//  			./go-sitemap -s example.com -audit -baseline audit.json -write-baseline audit.json
+//  			./go-sitemap -s example.com -audit -baseline audit.json -new-findings -fail-on warning
+//						Records the current audit findings for example.com in audit.json, then on later runs
+//						(e.g. in CI) fails if there are any new warnings or errors.
+//  			./go-sitemap -s example.com -audit -baseline audit.json -fail-on error
+//						Maps example.com reporting the audit findings, with the severities and suppressions in
+//						audit.json applied, exiting with an error if any errors are found. For example:
+//							{"severities": {"cache.*": "info", "vary.unusual": "off"},
+//							 "suppressions": [{"check": "encoding.*", "url": "/legacy/**", "reason": "old CMS"}]}
+//
+// Build Instructions:
+//		1. Dependencies are listed in go.mod, and downloaded by the go command when building
+//		2. Run unit tests
+//			 > go test ./...
+//		3. Build / Install
+//			 > go install ./cmd/go-sitemap
+//		   or, without a copy of the source
+//			 > go install github.com/markamb/go-sitemap/cmd/go-sitemap@latest
+//		4. Optionally, to support WASM plugins (-plugin), build with the wasmplugins tag (see wasmplugin.go in
+//		   the module root for the interface a plugin module must implement)
+//			 > go install -tags wasmplugins ./cmd/go-sitemap
+//		5. Optionally, to render pages with JavaScript (-render js), build with the headless tag (Chrome or
+//		   Chromium must be installed where the crawler is run)
+//			 > go install -tags headless ./cmd/go-sitemap
+//		6. Optionally, to export traces of the crawl (-trace-endpoint), build with the otel tag (tags can be
+//		   combined, e.g. -tags otel,headless)
+//			 > go install -tags otel ./cmd/go-sitemap
+//
+// Design Notes:
+//		The crawler and the reports are implemented by the github.com/markamb/go-sitemap package, whose
+//		documentation describes its main types and the processing pipeline used to crawl a site. This package
+//		only parses the command line and wires the crawler, site map and output together.
+//
+// Versioning and API Stability:
+//		See the github.com/markamb/go-sitemap package documentation. The command line flags, their defaults and
+//		the exit status are covered by the same policy as the Go API.
+//
+// Known Issues / Missing Features
+//		1. 	Add support for robots.txt (load and parse for the domain then use any filters requested)
+//		2. 	Improve display of the site map. For example, it may be useful to see the structure based on the URL path
+//			rather than based on the links present in each page
+//		3.	Add retry logic on HTTP requests where appropriate (e.g. 503 response code returned). Only rate limited
+//			URLs (see -max-retry-after) are currently retried.
+//		4.  Add support for the <BASE> tag on a page
+//
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/markamb/go-sitemap"
+)
+
+//
+// Defaults
+//
+const (
+	DftSite         string        = "en.wikipedia.org"
+	DftNumLoaders   int           = 10         // number of page loading and parsing threads
+	DftMinLoadDelay int           = 100        // minimum delay, in milliseconds, between each load
+	DftMaxPages     int           = 0          // number of pages to load
+	DftMaxDepth     int           = 0          // max depth to crawl site to
+	DftVerbose      bool          = false      // true to add extra logging
+	DftSchemePolicy string        = "distinct" // keep http and https variants of a page as separate pages
+	DftPreCheck     string        = "none"     // checks made before loading a URL
+	DftLoadTimeout  int           = 60         // maximum time, in seconds, to load and parse a single page
+	DftMaxDuration  time.Duration = 0          // maximum time for the whole crawl
+
+	// output
+	DftFormat      string = "text"       // output format
+	DftTextVersion int    = sitemap.TextVersion1 // plain text output format version
+	DftOrder       string = "dfs"        // order pages are written in
+
+	// duplicate content
+	DftNearDuplicateBits int = 3 // maximum bits the similarity hashes of near identical pages differ by
+
+	// external commands
+	DftCommandConcurrency int           = 1                // maximum number of page commands run at once
+	DftCommandFailure     string        = "ignore"         // what happens to a page when its command fails
+	DftCommandTimeout     time.Duration = 30 * time.Second // maximum time for each run of a command
+
+	// block cache
+	DftBlockAfter  int           = 2                  // number of failed crawls before a URL is blocked
+	DftBlockExpiry time.Duration = 7 * 24 * time.Hour // time after which a blocked URL is retried
+)
+
+func main() {
+
+	// subcommands
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		if err := runVersion(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runServe(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		if err := runQuery(os.Args[2:], os.Stdin, os.Stdout, isTerminal(os.Stdin)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	//
+	// Configuration
+	//
+	startURLStr := flag.String("s", DftSite, "site to crawl")
+	fileName := flag.String("out", "", "site map destination file, s3://bucket/key or gs://bucket/key, with none meaning write to console")
+	minLoadDelay := flag.Int("delay", DftMinLoadDelay, "minimum separation (in ms) between initiating loads from the server")
+	numLoaders := flag.Int("t", DftNumLoaders, "maximum number of concurrent loads from the server")
+	maxPages := flag.Int("pages", DftMaxPages, "maximum number pages to load, 0 means no limit (default: 0)")
+	maxDepth := flag.Int("depth", DftMaxDepth, "maximum depth to crawl to, 0 means no limit (default: 0)")
+	verbose := flag.Bool("verbose", DftVerbose, "set to show extra logging")
+	schemePolicyStr := flag.String("scheme-policy", DftSchemePolicy, "how http and https variants of a page are mapped: distinct, https or http")
+	preCheckStr := flag.String("precheck", DftPreCheck, "checks made before loading a URL: none, ext (skip non-HTML file extensions) or head (ext plus a HEAD request)")
+	loadTimeout := flag.Int("timeout", DftLoadTimeout, "maximum time (in seconds) to load and parse a single page, 0 means no limit")
+	maxDuration := flag.Duration("max-duration", DftMaxDuration, "maximum time for the whole crawl (e.g. 30m), 0 means no limit")
+	blockCacheFile := flag.String("block-cache", "", "file storing URLs denied access in previous crawls, which are skipped")
+	blockAfter := flag.Int("block-after", DftBlockAfter, "number of crawls a URL must be denied access (401 or 403) in before it is blocked")
+	blockExpiry := flag.Duration("block-expiry", DftBlockExpiry, "time after which a blocked URL is retried")
+	probeTypes := flag.String("probe-types", "", "comma separated content types to request each page in, recording which the server provides (e.g. application/json,application/xml)")
+	dropQuery := flag.Bool("drop-query", false, "set to remove query strings from links")
+	dropParams := flag.String("drop-params", "", "comma separated query parameters removed from links, where a trailing * matches any suffix and \"default\" adds common tracking parameters (e.g. utm_*,fbclid)")
+	sortQuery := flag.Bool("sort-query", false, "set to sort the query parameters of links so parameter order doesn't create duplicates")
+	lang := flag.String("lang", sitemap.DefaultLocale, "language reports are written in: "+strings.Join(sitemap.Locales(), ", "))
+	orderStr := flag.String("order", DftOrder, "order pages are written in: dfs (showing the link structure), bfs (grouped by depth), alpha (sorted by URL) or inlinks (most linked to first)")
+	format := flag.String("format", DftFormat, "output format: text, json, csv (one row per page, listing the pages linking to it), html (a table of pages with their PageRank), sql (a dump creating pages and links tables), gexf or graphml (the link graph with page and link attributes, for Gephi or Cytoscape), xml (a sitemap.xml of every page, using -sitemap-rules) or template (rendered with -template)")
+	textVersion := flag.Int("text-version", DftTextVersion, "text output format version: 1 (original layout) or 2 (adds depth and status columns)")
+	printSchema := flag.Bool("schema", false, "print the JSON schema for the json output format and exit")
+	selectPath := flag.String("select-path", "", "only write pages whose path matches this glob, where ** matches any characters including / (e.g. /blog/**)")
+	selectMinDepth := flag.Int("select-min-depth", 0, "only write pages at least this many links from the starting page")
+	selectMaxDepth := flag.Int("select-max-depth", 0, "only write pages at most this many links from the starting page, 0 means no limit")
+	selectLinkingTo := flag.String("select-linking-to", "", "only write pages linking to this URL")
+	selectOrphans := flag.Bool("select-orphans", false, "only write pages which can't be reached by following links from the starting page")
+	sitemapXML := flag.String("sitemap-xml", "", "URL or file of the site's sitemap.xml (\"auto\" for /sitemap.xml on the site) to report pages listed in it but not reachable by following links, and reachable pages missing from it")
+	pageCommand := flag.String("page-command", "", "command run for each crawled page, with the page's JSON record on stdin, its URL and depth in the GO_SITEMAP_URL and GO_SITEMAP_DEPTH environment variables and the URL requested (which differs if redirected) in GO_SITEMAP_REQUEST_URL")
+	endCommand := flag.String("end-command", "", "command run once crawling is complete, with the JSON crawl document on stdin")
+	commandConcurrency := flag.Int("command-concurrency", DftCommandConcurrency, "maximum number of page commands run at once")
+	commandTimeout := flag.Duration("command-timeout", DftCommandTimeout, "maximum time for each run of a page or end command, 0 means no limit")
+	commandRetries := flag.Int("command-retries", 0, "number of times a failed page or end command is retried")
+	commandFailureStr := flag.String("command-failure", DftCommandFailure, "what happens to a page when its page command fails: ignore, discard (the page and its links) or abort (stop crawling and exit with an error)")
+	duplicatesReport := flag.Bool("duplicates-report", false, "set to report groups of pages with identical content or near identical text")
+	nearDuplicateBits := flag.Int("near-duplicate-bits", DftNearDuplicateBits, "maximum number of bits the text similarity hashes of near identical pages differ by, with -1 only reporting identical content")
+	pluginFile := flag.String("plugin", "", "WebAssembly module filtering URLs and/or extracting page metadata, requiring a build with the wasmplugins tag")
+	stateFile := flag.String("state", "", "file storing the progress of the crawl, which is resumed from it on the next run if it was stopped by -pages, -max-duration or -daily-quota")
+	dailyQuota := flag.Int("daily-quota", 0, "maximum number of pages loaded per day, with the crawl resumed from -state on the next run once the quota is used up, 0 means no limit")
+	previousFile := flag.String("previous", "", "JSON crawl document (written with -format json) from a previous crawl of the site, which pages are compared with for -delta-sitemap and requested conditionally with -conditional")
+	deltaSitemap := flag.String("delta-sitemap", "", "file a sitemap.xml is written to listing only the pages new or changed since the -previous crawl, with their lastmod set to when they were last modified (from the Last-Modified header, or the time of this crawl if not known), an xhtml:link for each of their hreflang language variants and a video:video for each video recorded with -videos")
+	inlinksReport := flag.Int("inlinks-report", 0, "number of most and least linked to pages to report, 0 means no report")
+	encodingReport := flag.Bool("encoding-report", false, "set to report internal links whose href is not in its canonical encoding (e.g. unencoded spaces or lower case percent-encodings), which can create duplicate URLs for the same page")
+	redirectReport := flag.Bool("redirect-report", false, "set to report URLs which redirect to themselves or form a redirect cycle, showing the cycle")
+	depthReport := flag.Bool("depth-report", false, "set to report depth statistics: pages at each depth, the average and maximum click distance from the starting page, leaf pages and pages deeper than -deep-threshold")
+	deepThreshold := flag.Int("deep-threshold", sitemap.DefaultDeepThreshold, "number of clicks from the starting page beyond which pages are reported as deep by the depth statistics")
+	cacheReport := flag.Bool("cache-report", false, "set to report pages served with no caching headers, caching disabled, a TTL shorter than -min-ttl or conflicting Cache-Control directives, grouped by the first segment of their path")
+	minTTL := flag.Duration("min-ttl", sitemap.DefaultMinTTL, "minimum time pages should be cacheable for, with shorter TTLs reported by -cache-report")
+	trapRepeats := flag.Int("trap-repeats", sitemap.DefaultTrapLimits.MaxSegmentRepeats, "maximum times a path segment may appear in a URL before it is treated as a crawl trap and skipped (e.g. /a/b/a/b/a/b), 0 means no limit")
+	trapDates := flag.Int("trap-dates", sitemap.DefaultTrapLimits.MaxDateVariants, "maximum URLs loaded differing only by the dates in them (e.g. calendar pages), with further matching URLs skipped as a crawl trap, 0 means no limit")
+	trapPages := flag.Int("trap-pages", sitemap.DefaultTrapLimits.MaxPageVariants, "maximum URLs loaded differing only by a page number or offset (e.g. ?page=12), with further matching URLs skipped as a crawl trap, 0 means no limit")
+	varyReport := flag.Bool("vary-report", false, "set to request each page twice, reporting pages whose contents differ between the identical requests or which set suspicious Vary headers (e.g. User-Agent, Cookie or *), often caused by A/B testing or broken caching")
+	assertionsFile := flag.String("assertions", "", "JSON file of assertions checked against each page as it is crawled (e.g. every page under /docs must link to /docs/index), with violations reported")
+	failOnViolation := flag.Bool("fail-on-violation", false, "set to exit with an error once the site map is written if any -assertions failed")
+	assets := flag.Bool("assets", false, "set to record the static assets (images, scripts, stylesheets and srcset images) and resource hints (preload, modulepreload, prefetch and prerender) on the domain used by each page, listed per page in the text and json output")
+	assetsCheck := flag.Bool("assets-check", false, "set to check the static assets and resource hints recorded with -assets exist with a HEAD request to each, with missing (broken) assets and broken or redirected hints reported. The site's /favicon.ico, page icons and web app manifests are also checked, with pages missing them reported")
+	audit := flag.Bool("audit", false, "set to report the findings of every audit check (caching and Vary headers, href encoding, redirect loops, canonical URLs, plus broken assets, thin content and assertions when enabled) in one list by severity")
+	baselineFile := flag.String("baseline", "", "JSON audit baseline setting the severity of each audit check and suppressing accepted findings by check and URL")
+	failOnStr := flag.String("fail-on", "", "exit with an error once the site map is written if there are -audit findings of this severity or higher: info, warning or error")
+	newFindings := flag.Bool("new-findings", false, "set to only report -audit findings not recorded in the -baseline (see -write-baseline), so the audit can be used as a CI gate on a site with existing problems")
+	writeBaseline := flag.String("write-baseline", "", "file the audit findings are recorded in as a baseline for -new-findings on later runs, keeping the severities and suppressions of -baseline")
+	hreflangReport := flag.Bool("hreflang-report", false, "set to report the language variants of pages declared with <link rel=\"alternate\" hreflang>, grouping pages which are variants of each other")
+	soft404 := flag.Bool("soft-404", false, "set to request a nonexistent page before crawling to learn how the site responds to missing pages, reporting pages loaded successfully which look like its not found page (soft 404s)")
+	conditional := flag.Bool("conditional", false, "set to request the pages recorded in the -previous crawl conditionally (If-None-Match and If-Modified-Since), reusing their previous details rather than reparsing them when the server responds 304 Not Modified")
+	authReport := flag.Bool("auth-report", false, "set to report the URLs redirecting to a login page (see -login-pattern), which need authentication to be crawled")
+	loginPatternStr := flag.String("login-pattern", sitemap.DefaultLoginPattern, "regular expression matching the URLs of login pages, with links redirecting to one reported as requiring authentication rather than mapped, empty for none")
+	daemon := flag.Bool("daemon", false, "set to keep running, recrawling the site every -interval and serving the latest site map over HTTP on -listen")
+	interval := flag.Duration("interval", 6*time.Hour, "time between the start of each crawl with -daemon")
+	listen := flag.String("listen", "localhost:8080", "address the latest site map is served on with -daemon")
+	memoryThreshold := flag.Int("memory-threshold", 0, "memory use (in MB) above which the crawl switches to a low memory mode, spilling the queue of URLs to disk, rather than risk running out of memory, 0 means no threshold")
+	streamDir := flag.String("stream-dir", "", "directory each page is written to as it is crawled, in part files which are synced to disk and recorded in a manifest every -flush-interval, so a crash loses at most the last interval of results")
+	streamFormat := flag.String("stream-format", "jsonl", "format pages are written to -stream-dir in: jsonl (one JSON page record per line) or csv")
+	flushInterval := flag.Duration("flush-interval", 30*time.Second, "how often the pages written to -stream-dir are synced to disk, starting a new part file")
+	stableOutput := stableOutputFlag(flag.CommandLine)
+	proxyStr := flag.String("proxy", "", "URL of the proxy requests are sent through (http://, https://, socks5:// or socks5h://, optionally with user:password@), empty to use the HTTP_PROXY and HTTPS_PROXY environment variables")
+	proxyList := flag.String("proxy-list", "", "file listing a proxy URL on each line, with requests rotated between them (and any -proxy) in turn")
+	protocolReport := flag.Bool("protocol-report", false, "set to report the HTTP protocols and TLS versions pages were loaded with, listing the pages not loaded with the most common")
+	caCert := flag.String("ca-cert", "", "PEM file of CA certificates to trust as well as the system's, e.g. an internal CA")
+	clientCert := flag.String("client-cert", "", "PEM file of a client certificate to present to the server (requires -client-key)")
+	clientKey := flag.String("client-key", "", "PEM file of the private key of the -client-cert")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "set to accept any server certificate, including self-signed and expired ones (insecure, only use for internal staging hosts)")
+	maxIdlePerHost := flag.Int("max-idle-per-host", 0, "idle connections kept open to the server for reuse, 0 means one for each of the -t concurrent loads")
+	idleTimeout := flag.Duration("idle-timeout", sitemap.DefaultIdleConnTimeout, "how long idle connections to the server are kept open for reuse")
+	noKeepAlives := flag.Bool("no-keepalive", false, "set to open a new connection for every request rather than reusing connections")
+	http2 := flag.Bool("http2", true, "use HTTP/2 with servers supporting it, set -http2=false to only use HTTP/1.1")
+	directoryReport := flag.Bool("directory-report", false, "set to check each directory containing pages has an index page, reporting directories exposing listings of their files or returning errors")
+	breadcrumbReport := flag.Bool("breadcrumb-report", false, "set to compare the breadcrumb trail each page declares in JSON-LD structured data with its click depth and links, reporting pages where they are inconsistent")
+	resolveStr := flag.String("resolve", "", "comma separated host:ip (or host:port:ip) overrides of the address connections to a host are made to, like curl --resolve, e.g. to crawl a site before DNS cutover")
+	dnsCacheTTL := flag.Duration("dns-cache-ttl", sitemap.DefaultDNSCacheTTL, "how long the addresses host names resolve to are cached for, 0 to look them up for every connection")
+	localDir := flag.String("local-dir", "", "directory of a static site build (e.g. Hugo's public/) to map rather than the live site, served from -s, which is the URL it will be deployed at")
+	render := flag.String("render", "html", "how pages are read: html (the HTML served) or js (the DOM once rendered in headless Chrome, for sites whose links are added by JavaScript, requiring a build with the headless tag)")
+	renderPool := flag.Int("render-pool", 2, "number of browser tabs pages are rendered in concurrently with -render js")
+	renderTimeout := flag.Duration("render-timeout", sitemap.DefaultRenderTimeout, "maximum time to wait for a page to render with -render js")
+	structuredLinks := flag.Bool("structured-links", false, "set to also follow same-domain URLs in JSON-LD structured data, meta refresh tags and data-href, data-url and data-link attributes, which links are often encoded in for scripts")
+	canonicalHost := flag.String("canonical-host", "", "hosts the site is mapped on: apex or www (rewrite URLs to that host) or exact (only the host crawled), default both as found")
+	normalize := flag.String("normalize", "", "comma separated URL normalizations: https, lowercase-host, lowercase-path and escapes")
+	hashRoutes := flag.Bool("hash-routes", false, "set to map each #/ or #!/ route of a hash-routed single page app as a separate page")
+	rootPath := flag.String("root-path", "", "only crawl URLs under this path prefix (e.g. /docs), reporting links out of it as boundary links")
+	priority := flag.String("priority", "", "order URLs are crawled in: depth (closest to the start first) or path (fewest path segments first), default the order found")
+	maxPerDepth := flag.Int("max-per-depth", 0, "maximum URLs crawled at each depth, 0 means no limit")
+	byteBudget := flag.Int("byte-budget", 0, "maximum megabytes of pages to download, 0 means no limit")
+	parseWorkers := flag.Int("parse-workers", 0, "number of goroutines parsing downloaded pages (0 to parse as each page is downloaded)")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the crawl to the file")
+	memProfile := flag.String("memprofile", "", "write a heap profile to the file once the crawl completes")
+	maxMemory := flag.Int("max-memory", 0, "memory use (in MB) at which the crawl stops with the pages loaded so far, 0 means no limit")
+	logFormat := flag.String("log-format", "text", "format of the log written to stderr: text, or json for a JSON object per line including every crawl event")
+	maxRetryAfter := flag.Int("max-retry-after", int(sitemap.DefaultMaxRetryAfter/time.Second), "longest time (in seconds) requests to a host are held off for when the server asks (with Retry-After or X-RateLimit headers), 0 to fail rate limited URLs")
+	saveFile := flag.String("save", "", "file the site map is saved to after the crawl, so it can be reloaded with -load and written in other formats without crawling the site again")
+	loadFile := flag.String("load", "", "file of a site map saved with -save, which is written (in any output format) instead of crawling the site")
+	templateFile := flag.String("template", "", "text/template file the site map is rendered with for -format template, executed with the site map and its pages in the -order traversal")
+	feedLinks := flag.Bool("feed-links", false, "set to request the RSS and Atom feeds on the site declared by pages, following the items in each feed as links from the page declaring it")
+	feedReport := flag.Bool("feed-report", false, "set to report the RSS and Atom feeds declared by pages, with the section of the site declaring each")
+	videos := flag.Bool("videos", false, "set to record the videos on each page (<video> elements, embedded players and JSON-LD VideoObject structured data), listing them with the video sitemap extension in sitemap.xml files written")
+	sitemapRulesFile := flag.String("sitemap-rules", "", "file of rules setting the changefreq and priority of pages in the sitemap.xml files written (-format xml, -delta-sitemap and the -daemon's /sitemap.xml), one per line as a path glob or depth then the values, e.g. /blog/**: weekly,0.6 or depth>=3: monthly,0.3")
+	metaReport := flag.Bool("meta-report", false, "set to report pages with a missing, duplicate or too long title or meta description")
+	headingReport := flag.Bool("heading-report", false, "set to report pages with no H1 heading or more than one")
+	h2Headings := flag.Bool("h2-headings", false, "set to also record the text of the H2 headings of each page, written to the JSON crawl document with its H1 headings")
+	canonicalReport := flag.Bool("canonical-report", false, "set to report pages whose canonical URL is another page crawled, a page declaring a canonical of its own (a chain), a redirect or a URL which failed to load")
+	schemaReport := flag.Bool("schema-report", false, "set to record the schema.org types of the JSON-LD and microdata structured data on each page and report how many pages use each type")
+	schemaRulesFile := flag.String("schema-rules", "", "file of rules listing the structured data types expected on pages, one per line as a path glob, a colon, then the types separated by commas, e.g. /products/**: Product,BreadcrumbList. Pages missing any are reported by -schema-report")
+	thinWords := flag.Int("thin-words", 0, "set to count the words of visible text on each page, with pages with fewer words reported by -audit as thin content (content.thin)")
+	traceEndpoint := flag.String("trace-endpoint", "", "URL of an OpenTelemetry collector (OTLP over HTTP) to export a trace of each URL crawled to, requiring a build with the otel tag")
+	tracePropagate := flag.Bool("trace-propagate", false, "set to add W3C trace context headers to the requests for each page (with -trace-endpoint)")
+	queryReport := flag.Bool("query-report", false, "set to report URLs returning identical content with and without their query string")
+	flag.Parse()
+	if *printSchema {
+		os.Stdout.Write(sitemap.JSONSchema)
+		return
+	}
+	if !slices.Contains(sitemap.DocumentFormats(), *format) && *format != "text" && *format != "template" && *format != "xml" {
+		log.Fatalf("Invalid output format supplied: %s", *format)
+	}
+	messages, err := sitemap.LoadCatalog(*lang)
+	if err != nil {
+		log.Fatalf("Invalid language supplied: %v", err)
+	}
+	if *textVersion != sitemap.TextVersion1 && *textVersion != sitemap.TextVersion2 {
+		log.Fatalf("Invalid text format version supplied: %d", *textVersion)
+	}
+	var outputTemplate *template.Template
+	if *format == "template" {
+		if len(*templateFile) == 0 {
+			log.Fatalf("A template file (-template) is required for the template output format")
+		}
+		if outputTemplate, err = sitemap.LoadTemplate(*templateFile); err != nil {
+			log.Fatalf("Failed to load template: %v", err)
+		}
+	} else if len(*templateFile) != 0 {
+		log.Fatalf("A template file (-template) can only be used with -format template")
+	}
+	if *format != "text" {
+		// these reports are only written after the plain text site map, so would silently do nothing
+		var reports []string
+		for _, report := range []struct {
+			flag string
+			set  bool
+		}{
+			{"audit", *audit}, {"auth-report", *authReport}, {"cache-report", *cacheReport},
+			{"canonical-report", *canonicalReport}, {"depth-report", *depthReport}, {"directory-report", *directoryReport},
+			{"duplicates-report", *duplicatesReport}, {"encoding-report", *encodingReport}, {"feed-report", *feedReport},
+			{"heading-report", *headingReport}, {"hreflang-report", *hreflangReport}, {"inlinks-report", *inlinksReport > 0},
+			{"meta-report", *metaReport}, {"protocol-report", *protocolReport}, {"query-report", *queryReport},
+			{"redirect-report", *redirectReport}, {"sitemap-xml", len(*sitemapXML) != 0}, {"vary-report", *varyReport},
+		} {
+			if report.set {
+				reports = append(reports, "-"+report.flag)
+			}
+		}
+		if len(reports) != 0 {
+			log.Fatalf("%s can only be used with -format text", strings.Join(reports, ", "))
+		}
+	}
+	var sitemapRules []sitemap.SitemapRule
+	if len(*sitemapRulesFile) != 0 {
+		if sitemapRules, err = sitemap.LoadSitemapRules(*sitemapRulesFile); err != nil {
+			log.Fatalf("Failed to load sitemap rules: %v", err)
+		}
+	}
+	var schemaRules []sitemap.SchemaRule
+	if len(*schemaRulesFile) != 0 {
+		if !*schemaReport {
+			log.Fatalf("Structured data rules (-schema-rules) can only be used with -schema-report")
+		}
+		if schemaRules, err = sitemap.LoadSchemaRules(*schemaRulesFile); err != nil {
+			log.Fatalf("Failed to load structured data rules: %v", err)
+		}
+	}
+	query := sitemap.PageQuery{Path: *selectPath, MinDepth: *selectMinDepth, MaxDepth: *selectMaxDepth, LinkingTo: *selectLinkingTo, Orphans: *selectOrphans}
+	if len(query.Path) != 0 {
+		if _, err := sitemap.CompilePathGlob(query.Path); err != nil {
+			log.Fatalf("Invalid path pattern supplied: %v", err)
+		}
+	}
+	if flag.NArg() > 0 || *numLoaders < 0 || *maxPages < 0 || *maxDepth < 0 || *minLoadDelay < 0 || *loadTimeout < 0 ||
+		*maxDuration < 0 || *blockAfter < 1 || *blockExpiry < 0 || query.MinDepth < 0 || query.MaxDepth < 0 ||
+		*commandTimeout < 0 || *commandRetries < 0 || *dailyQuota < 0 || *inlinksReport < 0 ||
+		*deepThreshold < 0 || *minTTL < 0 || *trapRepeats < 0 || *trapDates < 0 || *trapPages < 0 || *memoryThreshold < 0 ||
+		*maxIdlePerHost < 0 || *idleTimeout < 0 || *dnsCacheTTL < 0 || *maxPerDepth < 0 || *byteBudget < 0 ||
+		*parseWorkers < 0 || *maxMemory < 0 || *maxRetryAfter < 0 || *thinWords < 0 {
+		flag.Usage()
+		return
+	}
+	schemePolicy, err := sitemap.ParseSchemePolicy(*schemePolicyStr)
+	if err != nil {
+		log.Fatalf("Invalid scheme policy supplied: %v", err)
+	}
+	urlPolicy, err := sitemap.ParseURLPolicy(*canonicalHost, *normalize)
+	if err != nil {
+		log.Fatalf("Invalid URL normalization supplied: %v", err)
+	}
+	preCheck, err := sitemap.ParsePreCheckMode(*preCheckStr)
+	if err != nil {
+		log.Fatalf("Invalid pre-check mode supplied: %v", err)
+	}
+	if *dailyQuota > 0 && len(*stateFile) == 0 {
+		log.Fatalf("A state file (-state) is required to use a daily quota")
+	}
+	if *daemon && len(*stateFile) != 0 {
+		log.Fatalf("A state file (-state) cannot be used with -daemon, as each scheduled crawl starts afresh")
+	}
+	if len(*loadFile) != 0 && (len(*stateFile) != 0 || *daemon || len(*streamDir) != 0) {
+		log.Fatalf("A saved site map (-load) cannot be used with -state, -daemon or -stream-dir, as the site isn't crawled")
+	}
+	if *daemon && len(*streamDir) != 0 {
+		log.Fatalf("A stream directory (-stream-dir) cannot be used with -daemon")
+	}
+	if len(*streamDir) != 0 && *flushInterval <= 0 {
+		log.Fatalf("The flush interval (-flush-interval) must be positive")
+	}
+	if *tracePropagate && len(*traceEndpoint) == 0 {
+		log.Fatalf("A trace endpoint (-trace-endpoint) is required to propagate traces")
+	}
+	var previous *sitemap.CrawlDocument
+	if len(*deltaSitemap) != 0 || *conditional {
+		if len(*previousFile) == 0 {
+			log.Fatalf("A previous crawl (-previous) is required to write a delta sitemap or make conditional requests")
+		}
+		if previous, err = sitemap.LoadCrawlDocument(*previousFile); err != nil {
+			log.Fatalf("Failed to load previous crawl: %v", err)
+		}
+	}
+	var loginPattern *regexp.Regexp
+	if len(*loginPatternStr) != 0 {
+		if loginPattern, err = regexp.Compile(*loginPatternStr); err != nil {
+			log.Fatalf("Invalid login pattern supplied: %v", err)
+		}
+	}
+	var proxies *sitemap.ProxyRotator
+	if len(*proxyStr) != 0 || len(*proxyList) != 0 {
+		var proxyURLs []string
+		if len(*proxyStr) != 0 {
+			proxyURLs = append(proxyURLs, *proxyStr)
+		}
+		if len(*proxyList) != 0 {
+			listed, err := sitemap.LoadProxyList(*proxyList)
+			if err != nil {
+				log.Fatalf("Failed to load proxy list: %v", err)
+			}
+			proxyURLs = append(proxyURLs, listed...)
+		}
+		if proxies, err = sitemap.CreateProxyRotator(proxyURLs); err != nil {
+			log.Fatalf("Invalid proxy supplied: %v", err)
+		}
+	}
+	tlsConfig, err := sitemap.TLSOptions{CAFile: *caCert, CertFile: *clientCert, KeyFile: *clientKey, InsecureSkipVerify: *insecureSkipVerify}.Config()
+	if err != nil {
+		log.Fatalf("Invalid TLS options supplied: %v", err)
+	}
+	overrides, err := sitemap.ParseResolveOverrides(*resolveStr)
+	if err != nil {
+		log.Fatalf("Invalid resolve override supplied: %v", err)
+	}
+	order, err := sitemap.ParseTraversalOrder(*orderStr)
+	if err != nil {
+		log.Fatalf("Invalid order supplied: %v", err)
+	}
+	failOn := sitemap.SeverityOff
+	if len(*failOnStr) != 0 {
+		if failOn, err = sitemap.ParseSeverity(*failOnStr); err != nil || failOn == sitemap.SeverityOff {
+			log.Fatalf("Invalid -fail-on severity supplied: %s", *failOnStr)
+		}
+	}
+	var baseline *sitemap.AuditBaseline
+	if len(*baselineFile) != 0 {
+		if baseline, err = sitemap.LoadAuditBaseline(*baselineFile); err != nil {
+			log.Fatalf("Failed to load audit baseline: %v", err)
+		}
+	} else if *newFindings {
+		log.Fatalf("An audit baseline (-baseline) is required to only report new findings")
+	}
+	commandFailure, err := sitemap.ParseCommandFailurePolicy(*commandFailureStr)
+	if err != nil {
+		log.Fatalf("Invalid command failure policy supplied: %v", err)
+	}
+	var pageHook, endHook *sitemap.CommandHook
+	if len(*pageCommand) != 0 {
+		if pageHook, err = sitemap.CreateCommandHook(*pageCommand, *commandConcurrency); err != nil {
+			log.Fatalf("Invalid page command supplied: %v", err)
+		}
+		pageHook.Timeout, pageHook.Retries, pageHook.Policy = *commandTimeout, *commandRetries, commandFailure
+	}
+	if len(*endCommand) != 0 {
+		if endHook, err = sitemap.CreateCommandHook(*endCommand, 1); err != nil {
+			log.Fatalf("Invalid end command supplied: %v", err)
+		}
+		endHook.Timeout, endHook.Retries = *commandTimeout, *commandRetries
+	}
+
+	//
+	// Saved site map, which is written instead of crawling the site it was saved from
+	//
+	var loadedSiteMap *sitemap.SiteMap
+	if len(*loadFile) != 0 {
+		if loadedSiteMap, err = sitemap.LoadSiteMapFile(*loadFile); err != nil {
+			log.Fatalf("Failed to load saved site map: %v", err)
+		}
+		*startURLStr = loadedSiteMap.RootPage
+		log.Printf("INFO: Loaded site map of %d pages from %s", len(loadedSiteMap.Pages), *loadFile)
+	}
+
+	//
+	// Starting URL
+	//
+	startURL, err := url.Parse(*startURLStr)
+	if err != nil {
+		log.Fatalf("Invalid starting URL supplied: %s", *startURLStr)
+	}
+	if len(startURL.Scheme) == 0 {
+		startURL.Scheme = "http"
+	}
+	startURL.Host = sitemap.ASCIIHost(startURL.Host)
+	urlPolicy.Apply(startURL, startURL.Host)
+	if len(*rootPath) != 0 {
+		if !strings.HasPrefix(*rootPath, "/") {
+			log.Fatalf("Invalid root path supplied (must start with /): %s", *rootPath)
+		}
+		if prefix := strings.TrimRight(*rootPath, "/"); !sitemap.InPathPrefix(startURL.Path, prefix) {
+			startURL.Path = prefix // start from the top of the section
+		}
+	}
+
+	//
+	// Logging: the crawler and loader use the default slog logger, with extra (debug) logging if verbose
+	//
+	if err := sitemap.ConfigureLogging(*logFormat, *verbose, os.Stderr); err != nil {
+		log.Fatalf("Invalid log format supplied: %v", err)
+	}
+
+	//
+	// Create and setup the site map and crawler
+	//
+	siteMap := loadedSiteMap
+	if siteMap == nil {
+		siteMap = sitemap.CreateSiteMap(startURL)
+		siteMap.SchemePolicy = schemePolicy
+		siteMap.URLPolicy = urlPolicy
+	}
+	docParser := sitemap.CreateDocumentParser()
+	docParser.Policy = urlPolicy
+	docParser.Query = sitemap.QueryNormalizer{DropAll: *dropQuery, Drop: sitemap.ParseDropParams(*dropParams), Sort: *sortQuery}
+	docParser.TextHash = (*duplicatesReport && *nearDuplicateBits >= 0) || *soft404
+	docParser.Assets = *assets || *assetsCheck
+	docParser.Breadcrumbs = *breadcrumbReport
+	docParser.StructuredLinks = *structuredLinks
+	docParser.HashRoutes = *hashRoutes
+	docParser.Videos = *videos
+	docParser.H2 = *h2Headings
+	docParser.SchemaTypes = *schemaReport
+	docParser.WordCount = *thinWords > 0
+	docLoader := sitemap.CreateDocumentLoader(docParser)
+	docLoader.PreCheck = preCheck
+	docLoader.Client.Timeout = time.Duration(*loadTimeout) * time.Second
+	docLoader.Recheck = *varyReport
+	docLoader.AssetCheck = *assetsCheck
+	docLoader.FeedLinks = *feedLinks
+	docLoader.LoginPattern = loginPattern
+	docLoader.IgnoreDate = *stableOutput
+	docLoader.MaxBytes = int64(*byteBudget) << 20
+	docLoader.Client.Transport = sitemap.CreateTransport(sitemap.TransportOptions{
+		Workers:         *numLoaders,
+		MaxIdlePerHost:  *maxIdlePerHost,
+		IdleConnTimeout: *idleTimeout,
+		NoKeepAlives:    *noKeepAlives,
+		NoHTTP2:         !*http2,
+		Proxies:         proxies,
+		TLS:             tlsConfig,
+		Resolver:        sitemap.CreateDNSResolver(overrides, *dnsCacheTTL),
+	})
+	var localSite *sitemap.LocalSite
+	if len(*localDir) != 0 {
+		if localSite, err = sitemap.CreateLocalSite(*localDir, startURL); err != nil {
+			log.Fatalf("Invalid local site directory: %v", err)
+		}
+		docLoader.Client.Transport = localSite
+	}
+	for _, override := range overrides {
+		target := override.Host
+		if len(override.Port) != 0 {
+			target += ":" + override.Port
+		}
+		log.Printf("INFO: Connecting to %s at %s", target, override.IP)
+	}
+	if proxies != nil {
+		log.Printf("INFO: Sending requests through %s", proxies)
+	}
+	if *insecureSkipVerify {
+		log.Printf("WARN: Server certificates are not verified (-insecure-skip-verify)")
+	}
+	if *conditional {
+		docLoader.UsePrevious(previous)
+	}
+	for _, probeType := range strings.Split(*probeTypes, ",") {
+		if probeType = strings.TrimSpace(probeType); len(probeType) != 0 {
+			docLoader.ProbeTypes = append(docLoader.ProbeTypes, probeType)
+		}
+	}
+
+	//
+	// Resume from the state of a previous crawl, loading no more than the pages left in today's quota
+	//
+	var state *sitemap.CrawlState
+	pagesToLoad, crawlNeeded := *maxPages, loadedSiteMap == nil
+	if len(*stateFile) != 0 {
+		if state, err = sitemap.LoadCrawlState(*stateFile, startURL.String()); err != nil {
+			log.Fatalf("Failed to load crawl state: %v", err)
+		}
+		if err := state.Restore(siteMap); err != nil {
+			log.Fatalf("Failed to restore crawl state: %v", err)
+		}
+		if state.Complete() {
+			log.Printf("INFO: Crawl of %s is already complete (remove %s to crawl it again)", startURL, *stateFile)
+			crawlNeeded = false
+		} else if state.Started() {
+			log.Printf("INFO: Resuming crawl with %d pages loaded and %d to load", len(state.Visited), len(state.Frontier))
+		}
+		if *dailyQuota > 0 && crawlNeeded {
+			remaining := state.RemainingQuota(*dailyQuota, time.Now())
+			if remaining == 0 {
+				log.Printf("INFO: Daily quota of %d pages used up, run again tomorrow to continue the crawl", *dailyQuota)
+				crawlNeeded = false
+			} else if pagesToLoad == 0 || remaining < pagesToLoad {
+				pagesToLoad = remaining
+			}
+		}
+	}
+	opts := []sitemap.Option{
+		sitemap.WithLoader(docLoader),
+		sitemap.WithSink(siteMap),
+		sitemap.WithThrottle(time.Duration(*minLoadDelay) * time.Millisecond),
+		sitemap.WithWorkers(*numLoaders),
+		sitemap.WithMaxPages(pagesToLoad),
+		sitemap.WithMaxDepth(*maxDepth),
+		sitemap.WithLoadTimeout(time.Duration(*loadTimeout) * time.Second),
+		sitemap.WithMaxDuration(*maxDuration),
+		sitemap.WithTrapLimits(sitemap.TrapLimits{MaxSegmentRepeats: *trapRepeats, MaxDateVariants: *trapDates, MaxPageVariants: *trapPages}),
+		sitemap.WithMemoryThreshold(uint64(*memoryThreshold)<<20, ""),
+		sitemap.WithMaxMemory(uint64(*maxMemory)<<20),
+		sitemap.WithMaxRetryAfter(time.Duration(*maxRetryAfter) * time.Second),
+	}
+	if *stableOutput {
+		opts = append(opts, sitemap.WithStableOrder())
+	}
+	if len(*rootPath) != 0 {
+		opts = append(opts, sitemap.WithRootPath(*rootPath))
+	}
+	if *maxPerDepth > 0 {
+		opts = append(opts, sitemap.WithMaxPagesPerDepth(*maxPerDepth))
+	}
+	if *parseWorkers > 0 {
+		opts = append(opts, sitemap.WithParseWorkers(*parseWorkers))
+	}
+	if score, err := sitemap.ParseFrontierScore(*priority); err != nil {
+		log.Fatalf("Invalid priority supplied: %v", err)
+	} else if score != nil {
+		opts = append(opts, sitemap.WithPriority(score))
+	}
+	if localSite != nil {
+		// seed every page in the directory (so unlinked pages are mapped) and load them without throttling
+		pageURLs, err := localSite.PageURLs()
+		if err != nil {
+			log.Fatalf("Failed to read local site directory: %v", err)
+		}
+		log.Printf("INFO: Mapping %d pages in %s as %s", len(pageURLs), *localDir, startURL)
+		opts = append(opts, sitemap.WithThrottle(0), sitemap.WithSeeds(pageURLs...))
+	}
+	switch {
+	case *render == "js" && localSite != nil:
+		log.Fatalf("Pages of a -local-dir site can't be rendered with -render js")
+	case *render == "js":
+		renderLoader, err := sitemap.CreateRenderLoader(docLoader, *renderPool, *renderTimeout, *insecureSkipVerify)
+		if err != nil {
+			log.Fatalf("Failed to create the render loader: %v", err)
+		}
+		defer renderLoader.Close()
+		opts = append(opts, sitemap.WithLoader(renderLoader))
+	case *render != "html":
+		log.Fatalf("Invalid render mode supplied: %s (expected html or js)", *render)
+	}
+	var blockCache *sitemap.BlockCache
+	if len(*blockCacheFile) != 0 {
+		if blockCache, err = sitemap.LoadBlockCache(*blockCacheFile, *blockAfter, *blockExpiry); err != nil {
+			log.Fatalf("Failed to load block cache: %v", err)
+		}
+		opts = append(opts, sitemap.WithBlockCache(blockCache))
+	}
+	if len(*pluginFile) != 0 {
+		plugin, err := sitemap.LoadWasmPlugin(*pluginFile)
+		if err != nil {
+			log.Fatalf("Failed to load plugin: %v", err)
+		}
+		defer plugin.Close()
+		if plugin.HasFilter() {
+			opts = append(opts, sitemap.WithURLFilter(plugin.FilterURL))
+		}
+		if plugin.HasExtractor() {
+			opts = append(opts, sitemap.WithOnPage(sitemap.ExtractMetadataOnPage(plugin, slog.Default())))
+		}
+	}
+	if len(*traceEndpoint) != 0 {
+		tracer, err := sitemap.CreateOTelTracer(*traceEndpoint, *tracePropagate)
+		if err != nil {
+			log.Fatalf("Failed to create tracer: %v", err)
+		}
+		defer func() {
+			if err := tracer.Close(); err != nil {
+				log.Printf("WARN: %v", err)
+			}
+		}()
+		opts = append(opts, sitemap.WithTracer(tracer))
+	}
+	var assertions *sitemap.AssertionChecker
+	if len(*assertionsFile) != 0 {
+		if assertions, err = sitemap.LoadAssertions(*assertionsFile); err != nil {
+			log.Fatalf("Failed to load assertions: %v", err)
+		}
+		opts = append(opts, sitemap.WithOnPage(assertions.OnPage))
+	}
+	if pageHook != nil {
+		// aborting on a command failure stops the crawl, rather than loading the pages already queued
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		pageHook.Cancel = cancel
+		opts = append(opts, sitemap.WithOnPage(pageHook.OnPage), sitemap.WithContext(ctx))
+	}
+	var stream *sitemap.StreamWriter
+	if len(*streamDir) != 0 {
+		if stream, err = sitemap.CreateStreamWriter(*streamDir, *streamFormat, startURL.String()); err != nil {
+			log.Fatalf("Failed to create stream: %v", err)
+		}
+		opts = append(opts, sitemap.WithOnPage(stream.OnPage)) // last, so only pages kept are written
+	}
+	if *daemon {
+		config := daemonConfig{
+			start:        startURL,
+			schemePolicy: schemePolicy,
+			urlPolicy:    urlPolicy,
+			loader:       docLoader,
+			opts:         opts,
+			soft404:      *soft404,
+			interval:     *interval,
+			listen:       *listen,
+			sitemapRules: sitemapRules,
+		}
+		if err := runDaemon(config); err != nil {
+			log.Fatalf("Daemon stopped: %v", err)
+		}
+		return
+	}
+	if state != nil && state.Started() {
+		opts = append(opts, sitemap.WithResume(state.Frontier, state.Visited))
+	}
+	if isTerminal(os.Stdout) && *logFormat != "json" {
+		// show a live progress bar (on stderr, alongside the logging)
+		opts = append(opts, sitemap.WithProgress(func(progress sitemap.CrawlProgress) {
+			sitemap.RenderProgressBar(os.Stderr, progress)
+		}, time.Second))
+	}
+	crawler, err := sitemap.CreateCrawler(startURL, opts...)
+	if err != nil {
+		log.Fatalf("Invalid crawler configuration: %v", err)
+	}
+
+	//
+	// Crawl the website (this will block until crawling is complete)
+	//
+	var notFound *sitemap.NotFoundSignature
+	if *soft404 && crawlNeeded {
+		if notFound, err = docLoader.ProbeNotFound(startURL); err != nil {
+			log.Printf("WARN: Soft 404 detection disabled as probing for missing pages failed: %v", err)
+		} else if notFound.Soft() {
+			log.Printf("WARN: Missing pages return status %d (%s), so will be detected by their contents", notFound.StatusCode, notFound.URL)
+		}
+		docLoader.NotFound = notFound
+	}
+	start := time.Now()
+	streamDone := make(chan bool)
+	if stream != nil {
+		go stream.Run(*flushInterval, streamDone)
+	}
+	profiler, err := sitemap.StartProfiler(*cpuProfile, *memProfile)
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	if crawlNeeded {
+		if err := crawler.Crawl(); err != nil {
+			log.Fatalf("FATAL: Failed to crawl website: %v", err)
+		}
+	}
+	if err := profiler.Stop(); err != nil {
+		log.Printf("WARN: %v", err)
+	}
+	close(streamDone)
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			log.Fatalf("Failed to complete stream: %v", err)
+		}
+	}
+	crawlTime := time.Since(start).Seconds()
+	if blockCache != nil {
+		if err := blockCache.Save(); err != nil {
+			log.Fatalf("Failed to save block cache: %v", err)
+		}
+	}
+	siteMap.AddErrors(crawler.Errors())
+	siteMap.AddBoundaryLinks(crawler.BoundaryLinks())
+	if crawler.Truncated() {
+		siteMap.Truncated = true
+		log.Printf("WARN: Crawl truncated after reaching the maximum crawl duration of %v", *maxDuration)
+	}
+	if crawler.OverBudget() {
+		siteMap.Truncated = true
+		log.Printf("WARN: Crawl truncated after downloading %d MB of pages (-byte-budget)", docLoader.BytesRead()>>20)
+	}
+	if crawler.MemoryLimited() {
+		siteMap.Truncated = true
+		log.Printf("WARN: Crawl truncated after reaching the memory limit of %d MB (-max-memory)", *maxMemory)
+	} else if crawler.LowMemory() && *memoryThreshold == 0 {
+		log.Printf("WARN: Crawl switched to low memory mode as memory use neared the limit of %d MB", *maxMemory)
+	}
+	if crawler.LowMemory() && *memoryThreshold != 0 {
+		log.Printf("WARN: Crawl switched to low memory mode after exceeding the memory threshold of %d MB", *memoryThreshold)
+	}
+	for _, trap := range crawler.Traps() {
+		log.Printf("WARN: Skipped %d URLs matching crawl trap %s (%v)", trap.Skipped, trap.Pattern, trap.Kind)
+	}
+	if state != nil {
+		if crawlNeeded {
+			state.Update(siteMap, crawler, time.Now())
+			if err := state.Save(); err != nil {
+				log.Fatalf("Failed to save crawl state: %v", err)
+			}
+		}
+		if len(state.Frontier) != 0 {
+			siteMap.Truncated = true
+			log.Printf("INFO: Crawl paused with %d pages still to load, run again to continue it", len(state.Frontier))
+		}
+	}
+	if len(*saveFile) != 0 {
+		if err := sitemap.SaveSiteMapFile(siteMap, *saveFile); err != nil {
+			log.Fatalf("Failed to save site map: %v", err)
+		}
+		log.Printf("INFO: Saved site map to %s", *saveFile)
+	}
+	log.Printf("INFO: Crawled %d pages from %s in %v seconds", len(siteMap.Pages), siteMap.Domain, crawlTime)
+	depthStats := siteMap.DepthStats(*deepThreshold)
+	log.Printf("INFO: %d pages reachable with an average depth of %.2f (maximum %d), %d deeper than %d", depthStats.Reachable,
+		depthStats.AverageDepth, depthStats.MaxDepth, len(depthStats.DeepPages), depthStats.Threshold)
+	if siteMap.SchemePolicy != sitemap.SchemeDistinct {
+		log.Printf("INFO: Merged %d http/https duplicate page pairs", siteMap.SchemeDuplicates)
+	}
+	if *conditional {
+		log.Printf("INFO: %d pages unchanged since the previous crawl", len(siteMap.UnchangedPages()))
+	}
+	if authRequired := crawler.AuthRequired(); len(authRequired) != 0 {
+		log.Printf("INFO: %d URLs redirect to a login page and need authentication to crawl", len(authRequired))
+	}
+	var icons *sitemap.IconAudit
+	if *assetsCheck {
+		icons = docLoader.CheckIcons(siteMap)
+	}
+
+	//
+	// Write the site map (and any reports) to the screen or output file
+	//
+	var file io.Writer = os.Stdout
+	var dest io.WriteCloser
+	if len(*fileName) != 0 {
+		log.Printf("INFO: Writing Site Map to %s....\n", *fileName)
+		if dest, err = sitemap.CreateDestination(*fileName); err != nil {
+			log.Fatalf("Failed to create %s: %v", *fileName, err)
+		}
+		file = dest
+	}
+	var renderer sitemap.Renderer = sitemap.TextRenderer{Root: startURL.String(), Options: sitemap.TextOptions{Version: *textVersion, Order: order, Messages: messages}}
+	if outputTemplate != nil {
+		renderer = sitemap.TemplateRenderer{Template: outputTemplate, Order: order}
+	} else if *format == "xml" {
+		renderer = sitemap.SitemapXMLRenderer{Rules: sitemapRules, Query: query}
+	} else if *format != "text" {
+		renderer = sitemap.DocumentRenderer{Format: *format, DepthStats: &depthStats, Query: query}
+	} else if query != (sitemap.PageQuery{}) {
+		renderer = sitemap.PagesRenderer{Query: query, Messages: messages}
+	}
+	if err := renderer.Render(file, siteMap); err != nil {
+		log.Fatalf("Failed to write site map: %v", err)
+	}
+	if *queryReport && *format == "text" {
+		if err := sitemap.PrintQueryDuplicates(file, siteMap, messages); err != nil {
+			log.Fatalf("Failed to write query string report: %v", err)
+		}
+	}
+	if *depthReport && *format == "text" {
+		if err := sitemap.PrintDepthStats(file, depthStats, messages); err != nil {
+			log.Fatalf("Failed to write depth statistics: %v", err)
+		}
+	}
+	if *inlinksReport > 0 && *format == "text" {
+		if err := sitemap.PrintLinkPopularity(file, siteMap.LinkPopularity(), *inlinksReport, messages); err != nil {
+			log.Fatalf("Failed to write link popularity report: %v", err)
+		}
+	}
+	if *redirectReport && *format == "text" {
+		if err := sitemap.PrintRedirectLoops(file, crawler.RedirectLoops(), messages); err != nil {
+			log.Fatalf("Failed to write redirect loop report: %v", err)
+		}
+	}
+	if *cacheReport && *format == "text" {
+		if err := sitemap.PrintCacheAudit(file, siteMap.CacheAudit(*minTTL, time.Now()), messages); err != nil {
+			log.Fatalf("Failed to write cache report: %v", err)
+		}
+	}
+	if assertions != nil && *format == "text" {
+		if err := sitemap.PrintAssertionViolations(file, assertions.Violations(), messages); err != nil {
+			log.Fatalf("Failed to write assertion report: %v", err)
+		}
+	}
+	var findings []sitemap.Finding
+	if *audit || failOn != sitemap.SeverityOff || len(*writeBaseline) != 0 {
+		inputs := sitemap.AuditInputs{
+			RedirectLoops: crawler.RedirectLoops(),
+			AuthRequired:  crawler.AuthRequired(),
+			Icons:         icons,
+			MinTTL:        *minTTL,
+			MinWords:      *thinWords,
+		}
+		if assertions != nil {
+			inputs.Violations = assertions.Violations()
+		}
+		var suppressed, known int
+		findings, suppressed = baseline.Apply(sitemap.CollectFindings(siteMap, inputs))
+		if len(*writeBaseline) != 0 {
+			recorded := &sitemap.AuditBaseline{}
+			if baseline != nil {
+				*recorded = *baseline
+			}
+			recorded.Record(findings)
+			if err := recorded.Save(*writeBaseline); err != nil {
+				log.Fatalf("Failed to write audit baseline: %v", err)
+			}
+		}
+		if *newFindings {
+			all := len(findings)
+			findings = baseline.NewFindings(findings)
+			known = all - len(findings)
+		}
+		if *audit && *format == "text" {
+			if err := sitemap.PrintAuditFindings(file, findings, suppressed, known, messages); err != nil {
+				log.Fatalf("Failed to write audit report: %v", err)
+			}
+		}
+	}
+	if (*assets || *assetsCheck) && *format == "text" {
+		if err := sitemap.PrintAssets(file, siteMap, messages); err != nil {
+			log.Fatalf("Failed to write asset report: %v", err)
+		}
+	}
+	if icons != nil && *format == "text" {
+		if err := sitemap.PrintIconAudit(file, icons, messages); err != nil {
+			log.Fatalf("Failed to write icon report: %v", err)
+		}
+	}
+	if *hreflangReport && *format == "text" {
+		if err := sitemap.PrintLanguageGroups(file, siteMap.LanguageGroups(), messages); err != nil {
+			log.Fatalf("Failed to write hreflang report: %v", err)
+		}
+	}
+	if *soft404 && *format == "text" {
+		if err := sitemap.PrintSoft404Pages(file, notFound, siteMap.Soft404Pages(), messages); err != nil {
+			log.Fatalf("Failed to write soft 404 report: %v", err)
+		}
+	}
+	if *authReport && *format == "text" {
+		if err := sitemap.PrintAuthRequired(file, crawler.AuthRequired(), messages); err != nil {
+			log.Fatalf("Failed to write authentication report: %v", err)
+		}
+	}
+	if *varyReport && *format == "text" {
+		if err := sitemap.PrintVaryAudit(file, siteMap.VaryAudit(), messages); err != nil {
+			log.Fatalf("Failed to write vary report: %v", err)
+		}
+	}
+	if *protocolReport && *format == "text" {
+		if err := sitemap.PrintProtocolUsage(file, siteMap.ProtocolUsage(), messages); err != nil {
+			log.Fatalf("Failed to write protocol report: %v", err)
+		}
+	}
+	if *directoryReport && *format == "text" {
+		if err := sitemap.PrintDirectoryChecks(file, docLoader.CheckDirectories(siteMap), messages); err != nil {
+			log.Fatalf("Failed to write directory report: %v", err)
+		}
+	}
+	if *breadcrumbReport && *format == "text" {
+		if err := sitemap.PrintBreadcrumbIssues(file, siteMap.BreadcrumbIssues(), messages); err != nil {
+			log.Fatalf("Failed to write breadcrumb report: %v", err)
+		}
+	}
+	if *metaReport && *format == "text" {
+		if err := sitemap.PrintMetaIssues(file, siteMap.MetaAudit(), messages); err != nil {
+			log.Fatalf("Failed to write title and meta description report: %v", err)
+		}
+	}
+	if *headingReport && *format == "text" {
+		if err := sitemap.PrintHeadingIssues(file, siteMap.HeadingIssues(), messages); err != nil {
+			log.Fatalf("Failed to write heading report: %v", err)
+		}
+	}
+	if *canonicalReport && *format == "text" {
+		if err := sitemap.PrintCanonicalIssues(file, siteMap.CanonicalIssues(), messages); err != nil {
+			log.Fatalf("Failed to write canonical report: %v", err)
+		}
+	}
+	if *schemaReport && *format == "text" {
+		if err := sitemap.PrintSchemaTypes(file, siteMap.SchemaTypes(), siteMap.MissingSchemaTypes(schemaRules), len(schemaRules) != 0, messages); err != nil {
+			log.Fatalf("Failed to write structured data report: %v", err)
+		}
+	}
+	if *feedReport && *format == "text" {
+		if err := sitemap.PrintFeeds(file, siteMap.Feeds(), messages); err != nil {
+			log.Fatalf("Failed to write feed report: %v", err)
+		}
+	}
+	if *encodingReport && *format == "text" {
+		if err := sitemap.PrintHrefIssues(file, siteMap.HrefIssues(), messages); err != nil {
+			log.Fatalf("Failed to write encoding report: %v", err)
+		}
+	}
+	if *duplicatesReport && *format == "text" {
+		if err := sitemap.PrintDuplicateContent(file, siteMap.DuplicateContent(*nearDuplicateBits), messages); err != nil {
+			log.Fatalf("Failed to write duplicate content report: %v", err)
+		}
+	}
+	if len(*sitemapXML) != 0 && *format == "text" {
+		location := *sitemapXML
+		if location == "auto" {
+			location = startURL.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String()
+		}
+		listed, err := sitemap.LoadSitemapXML(&http.Client{Timeout: docLoader.Client.Timeout, Transport: docLoader.Client.Transport}, location)
+		if err != nil {
+			log.Fatalf("Failed to load sitemap.xml: %v", err)
+		}
+		if err := sitemap.PrintSitemapCoverage(file, siteMap.CompareSitemap(listed), messages); err != nil {
+			log.Fatalf("Failed to write sitemap.xml coverage report: %v", err)
+		}
+	}
+	if previous != nil {
+		changed := siteMap.ChangedSince(previous)
+		log.Printf("INFO: Writing %d new or changed pages to delta sitemap %s", len(changed), *deltaSitemap)
+		lastMod := start
+		if *stableOutput {
+			lastMod = time.Time{} // only use modification times from the site
+		}
+		var priorities *sitemap.SitemapPriorities
+		if len(sitemapRules) != 0 {
+			priorities = sitemap.CreateSitemapPriorities(sitemapRules, siteMap)
+		}
+		if err := writeSitemapXMLFile(*deltaSitemap, changed, lastMod, priorities); err != nil {
+			log.Fatalf("Failed to write delta sitemap: %v", err)
+		}
+	}
+	if endHook != nil {
+		if err := endHook.Run(sitemap.CreateCrawlDocument(siteMap)); err != nil {
+			log.Fatalf("End command failed: %v", err)
+		}
+	}
+	if pageHook != nil && pageHook.Err() != nil {
+		log.Fatalf("Crawling aborted: %v", pageHook.Err())
+	}
+	if failOn != sitemap.SeverityOff && len(findings) != 0 && findings[0].Severity >= failOn {
+		log.Fatalf("FATAL: Audit findings with severity %v or higher found", failOn)
+	}
+	if assertions != nil && *failOnViolation && len(assertions.Violations()) != 0 {
+		log.Fatalf("FATAL: %d assertion violations found", len(assertions.Violations()))
+	}
+	if dest != nil {
+		if err := dest.Close(); err != nil {
+			log.Fatalf("Failed to write site map to %s: %v", *fileName, err)
+		}
+		log.Print("INFO: Done\n")
+	}
+}
+
+// stableOutputFlag defines the -stable-output flag, along with -deterministic as an alias for it
+func stableOutputFlag(flags *flag.FlagSet) *bool {
+	stableOutput := flags.Bool("stable-output", false, "set to crawl in a deterministic order (loading one page at a time) and ignore the Date of responses without a Last-Modified header, so crawls of an unchanged site give byte-identical output")
+	flags.BoolVar(stableOutput, "deterministic", false, "alias for -stable-output")
+	return stableOutput
+}
+
+// daemonConfig is the configuration of the -daemon mode, built from the command line flags
+type daemonConfig struct {
+	start        *url.URL      // URL each crawl starts from
+	schemePolicy sitemap.SchemePolicy  // scheme policy of each site map
+	urlPolicy    sitemap.URLPolicy     // URL policy of each site map
+	loader       *sitemap.DocLoader    // loader used by every crawl
+	opts         []sitemap.Option      // options for each crawl, to which the site map is added as the sink
+	soft404      bool          // set to probe for the site's not found page before each crawl
+	interval     time.Duration // time between the start of each crawl
+	listen       string        // address the latest site map is served on
+	sitemapRules []sitemap.SitemapRule // rules setting the changefreq and priority of pages in the served sitemap.xml
+}
+
+// crawl crawls the site once with a fresh site map, clearing the loader's cache of asset statuses so assets
+// are checked again
+func (config daemonConfig) crawl() (*sitemap.SiteMap, error) {
+	site := sitemap.CreateSiteMap(config.start)
+	site.SchemePolicy = config.schemePolicy
+	site.URLPolicy = config.urlPolicy
+	config.loader.ResetAssetStatus()
+	if config.soft404 {
+		notFound, err := config.loader.ProbeNotFound(config.start)
+		if err != nil {
+			log.Printf("WARN: Soft 404 detection disabled for this crawl as probing for missing pages failed: %v", err)
+		}
+		config.loader.NotFound = notFound
+	}
+	crawler, err := sitemap.CreateCrawler(config.start, append(config.opts[:len(config.opts):len(config.opts)], sitemap.WithSink(site))...)
+	if err != nil {
+		return nil, err
+	}
+	if err := crawler.Crawl(); err != nil {
+		return nil, err
+	}
+	site.Truncated = crawler.Truncated() || crawler.OverBudget()
+	site.AddErrors(crawler.Errors())
+	site.AddBoundaryLinks(crawler.BoundaryLinks())
+	return site, nil
+}
+
+// runDaemon recrawls the site every interval until interrupted, serving the latest site map on the listen
+// address
+func runDaemon(config daemonConfig) error {
+	daemon, err := sitemap.CreateDaemon(config.start.String(), config.interval, config.crawl)
+	if err != nil {
+		return fmt.Errorf("invalid daemon configuration: %v", err)
+	}
+	daemon.SitemapRules = config.sitemapRules
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go daemon.Run(ctx)
+	log.Printf("INFO: Recrawling %s every %v, serving the latest site map on http://%s/sitemap.json", config.start, config.interval, config.listen)
+	if err := serveUntilDone(ctx, config.listen, daemon.Handler()); err != nil {
+		return fmt.Errorf("failed to serve site map: %v", err)
+	}
+	return nil
+}
+
+// writeSitemapXMLFile writes a sitemap.xml listing the supplied pages to a file
+func writeSitemapXMLFile(fileName string, pages []*sitemap.WebPage, lastMod time.Time, priorities *sitemap.SitemapPriorities) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := sitemap.WriteSitemapXML(file, pages, lastMod, priorities); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+
+// isTerminal checks if the file is a terminal (character device) rather than a file or pipe
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/markamb/go-sitemap"
+)
+
+func TestStableOutputFlag(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"-stable-output"}, true},
+		{[]string{"-deterministic"}, true},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		stableOutput := stableOutputFlag(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("Failed to parse %v: %v", test.args, err)
+		}
+		if *stableOutput != test.expected {
+			t.Errorf("Incorrect stable output for %v: expected %v, got %v", test.args, test.expected, *stableOutput)
+		}
+	}
+}
+
+func TestDaemonConfigCrawl(t *testing.T) {
+
+	// mock server - the home page links to /a and a missing page
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		links := map[string]string{"/": `<a href="/a">A</a><a href="/missing">Missing</a>`, "/a": `<a href="/">Home</a>`}
+		body, found := links[req.URL.Path]
+		if !found {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.Header().Add("Content-Type", "text/html")
+		fmt.Fprintf(rw, "<HTML><BODY>%s</BODY></HTML>", body)
+	}
+	server := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer server.Close()
+	start, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loader := sitemap.CreateDocumentLoader(sitemap.CreateDocumentParser())
+	opts := make([]sitemap.Option, 0, 10)
+	opts = append(opts, sitemap.WithLoader(loader), sitemap.WithThrottle(0), sitemap.WithMaxPages(0))
+	config := daemonConfig{start: start, loader: loader, opts: opts}
+
+	// each crawl uses a fresh site map, leaving the configured options unchanged
+	first, err := config.crawl()
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	second, err := config.crawl()
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if first == second || len(first.Pages) != 2 || len(second.Pages) != 2 {
+		t.Errorf("Incorrect site maps: expected 2 distinct maps of 2 pages, got %d and %d pages", len(first.Pages), len(second.Pages))
+	}
+	if len(second.Errors) != 1 || len(config.opts) != 3 {
+		t.Errorf("Incorrect crawl: expected 1 error and 3 options, got %v and %d options", second.Errors, len(config.opts))
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/markamb/go-sitemap"
+)
+
+// runQuery implements the query subcommand, loading a JSON crawl document then running the command in the
+// remaining arguments or, if there are none, an interactive shell
+func runQuery(args []string, in io.Reader, out io.Writer, interactive bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: go-sitemap query <crawl.json> [command]")
+	}
+	doc, err := sitemap.LoadCrawlDocument(args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return sitemap.RunQueryCommand(doc, strings.Join(args[1:], " "), out)
+	}
+	if interactive {
+		fmt.Fprintf(out, "%d pages of %s loaded, enter help for the commands\n", len(doc.Pages), doc.Site)
+	}
+	return sitemap.RunQueryShell(doc, in, out, interactive)
+}
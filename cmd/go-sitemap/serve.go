@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/markamb/go-sitemap"
+)
+
+// runServe implements the serve subcommand, running a CrawlServer until it fails
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := flags.String("listen", "localhost:8080", "address the API is served on")
+	allow := flags.String("allow", "", "comma separated hosts which may be crawled, empty for any")
+	maxCrawls := flags.Int("max-crawls", 2, "maximum number of crawls run at once")
+	minLoadDelay := flags.Int("delay", DftMinLoadDelay, "minimum separation (in ms) between initiating loads from a server")
+	numLoaders := flags.Int("t", DftNumLoaders, "maximum number of concurrent loads from a server")
+	maxPages := flags.Int("pages", 10000, "maximum number of pages loaded by each crawl, 0 means no limit")
+	maxDepth := flags.Int("depth", DftMaxDepth, "maximum depth to crawl to, 0 means no limit")
+	loadTimeout := flags.Int("timeout", DftLoadTimeout, "maximum time (in seconds) to load and parse a single page, 0 means no limit")
+	maxDuration := flags.Duration("max-duration", 30*time.Minute, "maximum time for each crawl, 0 means no limit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var allowed []string
+	for _, host := range strings.Split(*allow, ",") {
+		if host = strings.TrimSpace(host); len(host) != 0 {
+			allowed = append(allowed, host)
+		}
+	}
+	crawl := func(start *url.URL) (*sitemap.SiteMap, error) {
+		site := sitemap.CreateSiteMap(start)
+		loader := sitemap.CreateDocumentLoader(sitemap.CreateDocumentParser())
+		loader.Client.Timeout = time.Duration(*loadTimeout) * time.Second
+		loader.Client.Transport = sitemap.CreateTransport(sitemap.TransportOptions{Workers: *numLoaders})
+		crawler, err := sitemap.CreateCrawler(start,
+			sitemap.WithLoader(loader),
+			sitemap.WithSink(site),
+			sitemap.WithThrottle(time.Duration(*minLoadDelay)*time.Millisecond),
+			sitemap.WithWorkers(*numLoaders),
+			sitemap.WithMaxPages(*maxPages),
+			sitemap.WithMaxDepth(*maxDepth),
+			sitemap.WithLoadTimeout(time.Duration(*loadTimeout)*time.Second),
+			sitemap.WithMaxDuration(*maxDuration))
+		if err != nil {
+			return nil, err
+		}
+		if err := crawler.Crawl(); err != nil {
+			return nil, err
+		}
+		site.Truncated = crawler.Truncated()
+		return site, nil
+	}
+	server, err := sitemap.CreateCrawlServer(crawl, *maxCrawls, allowed)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	log.Printf("INFO: Serving the crawl API on http://%s (POST /crawl?site=example.com to start a crawl)", *listen)
+	return serveUntilDone(ctx, *listen, server.Handler())
+}
+
+// serveUntilDone serves HTTP requests on the listen address until the context is done, returning nil once
+// the server is closed
+func serveUntilDone(ctx context.Context, listen string, handler http.Handler) error {
+	server := &http.Server{Addr: listen, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markamb/go-sitemap"
+)
+
+// runVersion implements the version subcommand, writing the build details and optionally checking
+// whether a newer release is available. The update check is opt-in as it contacts GitHub.
+func runVersion(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	checkUpdate := flags.Bool("check-update", false, "check GitHub for a newer release")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	info := sitemap.ReadBuildInfo()
+	fmt.Fprintf(w, "go-sitemap %s (%s)\n", info.Version, info.GoVersion)
+	if info.Revision != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		fmt.Fprintf(w, "revision %s %s%s\n", info.Revision, info.Time, modified)
+	}
+	if !*checkUpdate {
+		return nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	latest, err := sitemap.LatestRelease(client, sitemap.ReleasesURL)
+	if err != nil {
+		return fmt.Errorf("update check failed: %v", err)
+	}
+	if newer, known := isNewerVersion(latest, info.Version); !known {
+		fmt.Fprintf(w, "latest release is %s\n", latest)
+	} else if newer {
+		fmt.Fprintf(w, "a newer release (%s) is available from https://github.com/markamb/go-sitemap/releases\n", latest)
+	} else {
+		fmt.Fprintln(w, "up to date")
+	}
+	return nil
+}
+
+// isNewerVersion compares two semantic versions (e.g. v1.2.3), returning true if latest is newer than
+// current. known is false if either version can't be parsed (e.g. a development build).
+func isNewerVersion(latest string, current string) (newer bool, known bool) {
+	l, lok := parseVersion(latest)
+	c, cok := parseVersion(current)
+	if !lok || !cok {
+		return false, false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i], true
+		}
+	}
+	return false, true
+}
+
+// parseVersion parses the major, minor and patch numbers from a version such as v1.2.3 (ignoring any
+// pre-release or build suffix)
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	fields := strings.Split(version, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
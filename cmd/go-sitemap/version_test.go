@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		newer, known    bool
+	}{
+		{"v1.4.0", "v1.3.9", true, true},
+		{"v1.4.0", "v1.4.0", false, true},
+		{"v1.4.0", "v2.0.0", false, true},
+		{"v1.10.0", "v1.9.0", true, true},
+		{"v1.4.1", "v1.4.0-rc.1", true, true},
+		{"v1.4.0", "(devel)", false, false},
+		{"latest", "v1.0.0", false, false},
+	}
+	for _, test := range tests {
+		newer, known := isNewerVersion(test.latest, test.current)
+		if newer != test.newer || known != test.known {
+			t.Errorf("Incorrect comparison of %s with %s: expected %v/%v, got %v/%v",
+				test.latest, test.current, test.newer, test.known, newer, known)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandFailurePolicy controls what happens to a page when the command run for it fails
+type CommandFailurePolicy int
+
+const (
+	CommandFailureIgnore  CommandFailurePolicy = iota // log the failure and keep the page
+	CommandFailureDiscard                             // log the failure and discard the page (its links are not followed)
+	CommandFailureAbort                               // stop crawling (see CommandHook.Cancel), discarding every page from then on
+)
+
+// ParseCommandFailurePolicy converts a policy name (ignore, discard or abort) into a CommandFailurePolicy
+func ParseCommandFailurePolicy(name string) (CommandFailurePolicy, error) {
+	switch strings.ToLower(name) {
+	case "ignore":
+		return CommandFailureIgnore, nil
+	case "discard":
+		return CommandFailureDiscard, nil
+	case "abort":
+		return CommandFailureAbort, nil
+	}
+	return CommandFailureIgnore, fmt.Errorf("unknown command failure policy %q (expected ignore, discard or abort)", name)
+}
+
+// CommandHook runs an external command, passing it a JSON document on stdin. This allows crawling to be
+// integrated with other systems without writing Go code.
+//
+// Its OnPage method can be used as an OnPageFunc to run the command for every crawled page, with the page's
+// PageRecord as JSON on stdin. The page URL, requested URL and crawl depth are also passed in the
+// GO_SITEMAP_URL, GO_SITEMAP_REQUEST_URL and GO_SITEMAP_DEPTH environment variables. A command fails if
+// it can't be run, exits with a non-zero status or takes longer than the timeout. Anything the command
+// writes to stdout is discarded, and anything written to stderr is included in the failure.
+type CommandHook struct {
+	Command []string             // command and its arguments (run directly, not through a shell)
+	Timeout time.Duration        // maximum time for each run of the command, 0 for no limit
+	Retries int                  // number of times a failed command is retried
+	Policy  CommandFailurePolicy // what happens to a page when its command fails
+
+	// called once when a failure aborts crawling, to cancel the crawl's context (see WithContext) so the pages
+	// queued aren't loaded
+	Cancel context.CancelFunc
+
+	logger    Logger
+	semaphore chan struct{} // limits the number of commands run concurrently
+	mutex     sync.Mutex
+	err       error // first failure when aborting
+}
+
+// CreateCommandHook creates a hook for the supplied command line (split on whitespace) running at most
+// concurrency commands at once
+func CreateCommandHook(commandLine string, concurrency int) (*CommandHook, error) {
+	command := strings.Fields(commandLine)
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no command supplied")
+	}
+	if concurrency < 1 {
+		return nil, fmt.Errorf("command concurrency must be at least 1, got %d", concurrency)
+	}
+	return &CommandHook{
+		Command:   command,
+		logger:    defaultLogger(),
+		semaphore: make(chan struct{}, concurrency),
+	}, nil
+}
+
+// OnPage runs the command for a crawled page, applying the failure policy if it fails. See OnPageFunc.
+func (hook *CommandHook) OnPage(visit *PageVisit) bool {
+	if hook.Err() != nil {
+		return false // aborted
+	}
+	record := CreatePageRecord(visit.Page)
+	env := []string{
+		"GO_SITEMAP_URL=" + record.URL,
+		"GO_SITEMAP_REQUEST_URL=" + visit.RequestURL,
+		"GO_SITEMAP_DEPTH=" + strconv.Itoa(visit.Depth),
+	}
+	err := hook.Run(record, env...)
+	if err == nil {
+		return true
+	}
+	hook.logger.Warn("Page command failed", "url", record.URL, "error", err)
+	switch hook.Policy {
+	case CommandFailureDiscard:
+		return false
+	case CommandFailureAbort:
+		hook.mutex.Lock()
+		aborting := hook.err == nil
+		if aborting {
+			hook.err = fmt.Errorf("page command failed for URL (%v): %v", record.URL, err)
+		}
+		hook.mutex.Unlock()
+		if aborting && hook.Cancel != nil {
+			hook.Cancel()
+		}
+		return false
+	}
+	return true
+}
+
+// Err returns the failure which aborted crawling, or nil if crawling was not aborted
+func (hook *CommandHook) Err() error {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	return hook.err
+}
+
+// Run runs the command with the value supplied encoded as JSON on stdin, plus any extra environment
+// variables supplied, retrying if it fails
+func (hook *CommandHook) Run(value any, env ...string) error {
+	input, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	hook.semaphore <- struct{}{}
+	defer func() { <-hook.semaphore }()
+	for attempt := 0; ; attempt++ {
+		err = hook.run(input, env)
+		if err == nil || attempt >= hook.Retries {
+			return err
+		}
+		hook.logger.Debug("Retrying command", "command", hook.Command[0], "error", err)
+	}
+}
+
+// run runs the command once
+func (hook *CommandHook) run(input []byte, env []string) error {
+	ctx := context.Background()
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // don't wait for output from any processes it started once it is killed
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %v", hook.Timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); len(message) != 0 {
+			return fmt.Errorf("%v: %s", err, message)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createShellHook creates a command hook running a shell script
+func createShellHook(t *testing.T, script string, concurrency int) *CommandHook {
+	hook, err := CreateCommandHook("sh", concurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Command = []string{"sh", "-c", script}
+	hook.logger = &RecordingLogger{}
+	return hook
+}
+
+func TestCommandHookOnPage(t *testing.T) {
+	dir := t.TempDir()
+	hook := createShellHook(t, `cat > "$OUT/$GO_SITEMAP_DEPTH.json"`, 2)
+	t.Setenv("OUT", dir)
+
+	page := createWebPage(t, "https://test.com/a", "Page A")
+	page.AddLink("https://test.com/b", Link{})
+	if !hook.OnPage(&PageVisit{Page: page, RequestURL: "https://test.com/a", Depth: 2}) {
+		t.Fatalf("Page discarded by successful command")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "2.json"))
+	if err != nil {
+		t.Fatalf("Command not run with page details: %v", err)
+	}
+	var record PageRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Invalid JSON passed to command: %v", err)
+	}
+	if record.URL != "https://test.com/a" || record.Title != "Page A" || len(record.Links) != 1 {
+		t.Errorf("Incorrect page record passed to command: %+v", record)
+	}
+}
+
+func TestCommandHookFailurePolicy(t *testing.T) {
+	tests := []struct {
+		policy   CommandFailurePolicy
+		keep     bool
+		aborted  bool
+		keepNext bool
+	}{
+		{CommandFailureIgnore, true, false, true},
+		{CommandFailureDiscard, false, false, true},
+		{CommandFailureAbort, false, true, false},
+	}
+	for _, test := range tests {
+		// fails for page /fail only
+		hook := createShellHook(t, `echo "bad page" >&2; [ "$GO_SITEMAP_URL" != "https://test.com/fail" ]`, 1)
+		hook.Policy = test.policy
+		cancelled := 0
+		hook.Cancel = func() { cancelled++ }
+
+		keep := hook.OnPage(&PageVisit{Page: createWebPage(t, "https://test.com/fail", ""), Depth: 1})
+		if keep != test.keep {
+			t.Errorf("Incorrect result for failed command with policy %v: expected %v, got %v", test.policy, test.keep, keep)
+		}
+		if aborted := hook.Err() != nil; aborted != test.aborted {
+			t.Errorf("Incorrect abort state for policy %v: expected %v, got %v", test.policy, test.aborted, aborted)
+		} else if aborted && !strings.Contains(hook.Err().Error(), "bad page") {
+			t.Errorf("Command stderr missing from error: %v", hook.Err())
+		}
+		keep = hook.OnPage(&PageVisit{Page: createWebPage(t, "https://test.com/ok", ""), Depth: 1})
+		if keep != test.keepNext {
+			t.Errorf("Incorrect result for next page with policy %v: expected %v, got %v", test.policy, test.keepNext, keep)
+		}
+		hook.OnPage(&PageVisit{Page: createWebPage(t, "https://test.com/fail", ""), Depth: 1})
+		if expected := map[bool]int{false: 0, true: 1}[test.aborted]; cancelled != expected {
+			t.Errorf("Incorrect cancellations for policy %v: expected %v, got %v", test.policy, expected, cancelled)
+		}
+	}
+}
+
+func TestCommandHookRetryAndTimeout(t *testing.T) {
+	// fails the first time it is run only
+	marker := filepath.Join(t.TempDir(), "marker")
+	hook := createShellHook(t, `[ -f "$MARKER" ] || { touch "$MARKER"; exit 1; }`, 1)
+	t.Setenv("MARKER", marker)
+	if err := hook.Run("value"); err == nil {
+		t.Errorf("Missing expected error without retries")
+	}
+	os.Remove(marker)
+	hook.Retries = 1
+	if err := hook.Run("value"); err != nil {
+		t.Errorf("Unexpected error with retries: %v", err)
+	}
+
+	hook = createShellHook(t, `sleep 5`, 1)
+	hook.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	if err := hook.Run("value"); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Missing expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Command not stopped after timeout: took %v", elapsed)
+	}
+}
+
+func TestCommandHookConcurrency(t *testing.T) {
+	// each command records the number running at once
+	dir := t.TempDir()
+	hook := createShellHook(t, `touch "$DIR/$$"; ls "$DIR" | wc -l >> "$DIR.counts"; sleep 0.1; rm "$DIR/$$"`, 2)
+	t.Setenv("DIR", dir)
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hook.Run(i)
+		}()
+	}
+	wg.Wait()
+	data, err := os.ReadFile(dir + ".counts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, count := range strings.Fields(string(data)) {
+		if count != "1" && count != "2" {
+			t.Errorf("Incorrect number of concurrent commands: expected at most 2, got %s", count)
+		}
+	}
+}
+
+func TestParseCommandFailurePolicy(t *testing.T) {
+	for name, expected := range map[string]CommandFailurePolicy{
+		"ignore": CommandFailureIgnore, "Discard": CommandFailureDiscard, "abort": CommandFailureAbort} {
+		if policy, err := ParseCommandFailurePolicy(name); err != nil || policy != expected {
+			t.Errorf("Incorrect policy for %s: expected %v, got %v (%v)", name, expected, policy, err)
+		}
+	}
+	if _, err := ParseCommandFailurePolicy("retry"); err == nil {
+		t.Errorf("Missing expected error for unknown policy")
+	}
+	if _, err := CreateCommandHook("  ", 1); err == nil {
+		t.Errorf("Missing expected error for empty command")
+	}
+}
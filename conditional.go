@@ -0,0 +1,62 @@
+package sitemap
+
+import (
+	"net/http"
+)
+
+// UsePrevious sets the loader to request the pages recorded in a previous crawl of the site conditionally,
+// using the ETag and last modified time recorded for each. Pages the server reports are unchanged (304 Not
+// Modified) are recreated from their records rather than loaded and parsed again.
+func (loader *DocLoader) UsePrevious(previous *CrawlDocument) {
+	loader.previous = make(map[string]*PageRecord, len(previous.Pages))
+	for i := range previous.Pages {
+		record := &previous.Pages[i]
+		loader.previous[record.URL] = record
+		for _, alias := range record.Aliases {
+			loader.previous[alias] = record
+		}
+	}
+}
+
+// conditionalHeader returns the headers making a request for a page conditional on it having changed since
+// its record in a previous crawl, or nil if the record is nil or has no ETag or last modified time
+func conditionalHeader(record *PageRecord) http.Header {
+	if record == nil {
+		return nil
+	}
+	header := make(http.Header)
+	if len(record.ETag) != 0 {
+		header.Set("If-None-Match", record.ETag)
+	}
+	if record.LastModified != nil && !record.LastModified.IsZero() {
+		header.Set("If-Modified-Since", record.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return header
+}
+
+// unchangedPage recreates a page from its record in a previous crawl following a 304 Not Modified response,
+// updating the details the response includes
+func unchangedPage(record *PageRecord, resp *http.Response) (*WebPage, error) {
+	page, err := CreateWebPageFromRecord(*record)
+	if err != nil {
+		return nil, err
+	}
+	page.StatusCode = resp.StatusCode
+	page.Header = resp.Header
+	page.Protocol, page.TLSVersion = responseProtocol(resp)
+	if etag := resp.Header.Get("ETag"); len(etag) != 0 {
+		page.ETag = etag
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		page.LastModified = modified.UTC()
+	}
+	return page, nil
+}
+
+// UnchangedPages returns the pages reported unchanged since a previous crawl (see UsePrevious), sorted by URL
+func (site *SiteMap) UnchangedPages() []*WebPage {
+	return site.selectPages(func(key string, page *WebPage) bool { return page.StatusCode == http.StatusNotModified })
+}
@@ -0,0 +1,89 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDocumentLoaderConditional(t *testing.T) {
+
+	// mock server - /fixed never changes, /dated was last modified at a fixed time and /changing has a new
+	// ETag on every request
+	requests := 0
+	conditional := make(map[string]string)
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		rw.Header().Add("Content-Type", "text/html")
+		switch req.URL.Path {
+		case "/fixed":
+			conditional[req.URL.Path] = req.Header.Get("If-None-Match")
+			rw.Header().Set("ETag", `"v1"`)
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/dated":
+			conditional[req.URL.Path] = req.Header.Get("If-Modified-Since")
+			rw.Header().Set("Last-Modified", "Sun, 05 Nov 2023 08:00:00 GMT")
+			if req.Header.Get("If-Modified-Since") == "Sun, 05 Nov 2023 08:00:00 GMT" {
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/changing":
+			conditional[req.URL.Path] = req.Header.Get("If-None-Match")
+			rw.Header().Set("ETag", fmt.Sprintf(`"r%d"`, requests))
+			fmt.Fprintf(rw, "<!-- request %d -->", requests)
+		}
+		fmt.Fprintf(rw, `<HTML><HEAD><title>Page %s</title></HEAD><BODY><a href="/next%s">Next</a></BODY></HTML>`, req.URL.Path, req.URL.Path)
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	// first crawl
+	paths := []string{"/fixed", "/dated", "/changing"}
+	site := CreateSiteMap(mustParseURL(t, mockServer.URL))
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	for _, path := range paths {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(conditional) != "map[/changing: /dated: /fixed:]" {
+		t.Errorf("Incorrect conditional headers on first crawl: expected none, got %v", conditional)
+	}
+	previous := CreateCrawlDocument(site)
+
+	// recrawl - unchanged pages are recreated from the previous crawl
+	site = CreateSiteMap(mustParseURL(t, mockServer.URL))
+	docLoader = CreateDocumentLoader(CreateDocumentParser())
+	docLoader.UsePrevious(previous)
+	for _, path := range paths {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if page.Title != "Page "+path || len(page.InternalLinks[mockServer.URL+"/next"+path]) != 1 {
+			t.Errorf("Incorrect page for %s: expected its title and link, got %s %v", path, page.Title, page.InternalLinks)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := `map[/changing:"r3" /dated:Sun, 05 Nov 2023 08:00:00 GMT /fixed:"v1"]`
+	if fmt.Sprint(conditional) != expected {
+		t.Errorf("Incorrect conditional headers on recrawl: expected %s, got %v", expected, conditional)
+	}
+	if got := pagePaths(site.UnchangedPages()); got != "[/dated /fixed]" {
+		t.Errorf("Incorrect unchanged pages: expected %s, got %s", "[/dated /fixed]", got)
+	}
+	expected = "[/changing]"
+	if got := pagePaths(site.ChangedSince(previous)); got != expected {
+		t.Errorf("Incorrect changed pages: expected %s, got %s", expected, got)
+	}
+}
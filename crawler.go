@@ -1,83 +1,284 @@
-package main
+package sitemap
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// PageSink is used by the Crawler to ingest each page as it is crawled. This is the part of the SiteMapper
+// interface needed for crawling, allowing pages to be processed without building a site map.
+type PageSink interface {
+
+	// AddPage ingests a crawled page. See SiteMapper interface for details.
+	AddPage(page *WebPage) (bool, error)
+}
+
+// PageHandler is a function implementing the PageSink interface, used to process each page with a callback
+// as it is crawled
+type PageHandler func(page *WebPage) error
+
+// AddPage passes the page to the handler function. See PageSink interface for details.
+func (handler PageHandler) AddPage(page *WebPage) (bool, error) {
+	if err := handler(page); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PageVisit describes a page which has just been loaded, and is passed to each OnPage callback
+type PageVisit struct {
+	Page       *WebPage      // the loaded page (including the response status and headers)
+	RequestURL string        // URL requested, which differs from the page URL if redirected
+	Depth      int           // depth of the page in the crawl (1 for the starting page)
+	Duration   time.Duration // time taken to load and parse the page
+}
+
+// OnPageFunc is called with each loaded page before it is added to the site map (or other sink). Callbacks
+// may inspect or modify the page (for example extracting extra details, or editing the links to follow)
+// and return false to discard it, in which case the page is not added and its links are not followed.
+// Callbacks are called concurrently from the page loading goroutines, so must be thread safe.
+type OnPageFunc func(visit *PageVisit) bool
+
+// URLFilter decides whether a newly discovered URL is crawled. It is called with the (absolute) URL and
+// its depth (1 for the starting page) before the URL is queued, and returns false to skip it.
+// It is only called from a single goroutine. A URL skipped because of its depth (rejected by the filter, or
+// beyond the crawl's depth limits) is checked again each time it is found nearer the start, so the filter
+// may be called several times for the same URL with decreasing depths, and can accept a URL it rejected
+// deeper in the site.
+type URLFilter func(u *url.URL, depth int) bool
+
+// documentFetcher is implemented by document loaders which can download a page separately from parsing it,
+// allowing the crawler to parse pages in a separate pool of goroutines (see WithParseWorkers)
+type documentFetcher interface {
+	FetchContext(ctx context.Context, urlStr string) (*FetchedDocument, error)
+	Parse(doc *FetchedDocument) (*WebPage, error)
+}
+
+// contextLoader is implemented by document loaders which can load a page as part of a trace, recording the
+// stages of loading it as spans (see WithTracer)
+type contextLoader interface {
+	LoadURLContext(ctx context.Context, urlStr string) (*WebPage, error)
+}
+
+// fetchedLoad is a page downloaded by a loading goroutine waiting to be parsed
+type fetchedLoad struct {
+	load  Hyperlink        // URL loaded
+	doc   *FetchedDocument // document downloaded
+	start time.Time        // when the load started
+	ctx   context.Context  // context holding the span tracing the load
+	end   func(err error)  // ends the span tracing the load
+}
+
+// crawledPage is a page loaded by the crawler waiting to be ingested into the site map
+type crawledPage struct {
+	page      *WebPage        // page loaded
+	endIngest func(err error) // ends the span tracing the page being ingested
+}
+
 // Crawler Type stores a domain to be crawled and the results of doing so.
 // Initialised with a DocumentLoader interface for retrieving and parsing URLs
 type Crawler struct {
 
 	// Interfaces used to load documents
 	docLoader DocumentLoader
+	client    *http.Client // client for the default document loader (nil to use its default)
 
-	// Site Map used to store results
-	siteMap SiteMapper
+	// Site Map (or other sink) used to store results
+	siteMap PageSink
 
 	// url to start crawling from
 	startURL *url.URL
 
 	// configuration
-	minLoadDelay   int  // default minimum delay between starting each load
-	numLoaders     int  // number of goroutines used for loading (= maximum number of concurrent requests)
-	maxPagesToLoad int  // Limits the number of pages loaded for testing on large sites. 0 to load all available pages.
-	maxCrawlDepth  int  // maximum depth to crawl on large sites (0 to load all available pages)
-	verbose        bool // true for extra logging
+	minLoadDelay   time.Duration   // default minimum delay between starting each load
+	numLoaders     int             // number of goroutines used for loading (= maximum number of concurrent requests)
+	maxPagesToLoad int             // Limits the number of pages loaded for testing on large sites. 0 to load all available pages.
+	maxCrawlDepth  int             // maximum depth to crawl on large sites (0 to load all available pages)
+	loadTimeout    time.Duration   // maximum time to wait for a single page to be loaded and parsed (0 for no limit)
+	maxDuration    time.Duration   // maximum time for the whole crawl (0 for no limit)
+	blockCache     *BlockCache     // URLs blocked from previous crawls, updated with this crawl (nil for none)
+	urlFilter      URLFilter       // policy deciding which discovered URLs are crawled (nil to crawl all)
+	onPage         []OnPageFunc    // callbacks called in order with each loaded page
+	seeds          []Hyperlink     // URLs to start crawling from when resuming a crawl (empty to start from startURL)
+	visited        map[string]bool // URLs loaded by a previous crawl being resumed, which are not loaded again
+	extraSeeds     []string        // further URLs crawled from as well as the start URL (see WithSeeds)
+	trapLimits     TrapLimits      // thresholds used to detect crawl traps
+	rootPath       string          // path prefix URLs must be under to be crawled (empty to crawl the whole site)
+	priority       FrontierScore   // order queued URLs are loaded in (nil to load them in the order found)
+	maxPerDepth    int             // maximum URLs queued at each depth (0 for no limit)
+	parseWorkers   int             // goroutines parsing pages downloaded by the loaders (0 to parse in the loaders)
+	maxRetryAfter  time.Duration   // longest a host is held off for when its server asks (0 to fail rate limited URLs)
+
+	// low memory mode (see WithMemoryThreshold) and the memory limit (see WithMaxMemory)
+	memoryThreshold uint64 // memory use (in bytes) above which the crawl switches to low memory mode (0 for none)
+	spillDir        string // directory the URL queue spills to in low memory mode (empty for the default)
+	maxMemory       uint64 // memory use (in bytes) at which the crawl is stopped (0 for no limit)
+
+	// deterministic crawling (see WithStableOrder)
+	stableOrder bool
+
+	// context stopping the crawl when it is cancelled (see WithContext)
+	ctx context.Context
+
+	// progress reporting (the progress function is called periodically with a snapshot, if set)
+	progressFunc     func(CrawlProgress)
+	progressInterval time.Duration
+
+	// crawl state
+	startTime   time.Time    // time crawling started
+	endTime     time.Time    // time crawling completed (only valid once finished is set)
+	stopTime    time.Time    // time after which no more URLs are loaded (zero if there is no limit)
+	truncated   atomic.Bool  // set if URLs were skipped as the maximum crawl duration was reached, it was cancelled or they were lost
+	overBudget  atomic.Bool  // set once the loader's download budget is used up (see errByteBudget)
+	pagesLoaded atomic.Int64 // number of pages loaded successfully
+	loadErrors  atomic.Int64 // number of URLs which failed to load
+	inFlight    atomic.Int64 // number of URLs currently being loaded
+	discovered  atomic.Int64 // number of URLs queued for loading
+	pagesKept   atomic.Int64 // number of pages loaded successfully and not discarded, counted against maxPagesToLoad
+	dispatched  atomic.Int64 // number of URLs sent to be loaded whose result isn't yet known
+	estimator   progressEstimator
+	finished    atomic.Bool  // set once crawling is complete
+	lowMemory   atomic.Bool // set once the memory threshold is exceeded (see degrade)
+	memoryPause atomic.Bool // set while new loads are paused as memory use is near the limit (see checkMemory)
+	memoryStop  atomic.Bool // set once memory use reaches the limit, so no more URLs are loaded
+	queued      []string    // URLs queued for loading (only accessed by enqueueNewUrls until finished)
+	deferred    []Hyperlink // URLs not loaded because a page or time limit was reached
+	deferMutex  sync.Mutex
+	holds       hostHolds // hosts held off as their servers asked, with the URLs waiting for them (see holdRateLimited)
+
+	// results reported after crawling
+	redirectLoops []*RedirectLoopError // URLs found in redirect loops
+	authRequired  []*AuthRequiredError // URLs redirecting to a login page
+	traps         *TrapDetector        // crawl traps detected, and the URLs skipped because of them
+	resultsMutex  sync.Mutex
+
+	// URLs which failed to load, recorded with the results (see Errors)
+	failures map[string]*LoadFailure
+
+	// URLs outside the root path which were linked to but not crawled (see BoundaryLinks)
+	boundary map[string]bool
+
+	// logging (debug level gives extra logging for each URL)
+	logger Logger
 
-	// an in-memory queue for storing our URLs to be crawled
-	urlQueue HyperlinkQueue
+	// tracing (see WithTracer), with the context each URL's spans are started from holding the tracer. It is
+	// derived from ctx, so cancelling the crawl also cancels the requests in flight.
+	tracer   Tracer
+	traceCtx context.Context
+
+	// an in-memory queue for storing our URLs to be crawled, and the depth each is waiting to be loaded at
+	// (see requeue)
+	urlQueue     HyperlinkQueue
+	queuedDepths queuedDepths
+
+	// items of work outstanding across all channels and the internal queue (see WorkTracker)
+	work *WorkTracker
 
 	// channels
-	pagesChan         chan *WebPage  // pages to be ingested into the Site Map
-	urlLoadChan       chan Hyperlink // URLs to be loaded by our pool of page loading workers
-	linksChan         chan Hyperlink // Internal links read off processed pages
-	pendingItemsChan  chan int       // Track total number of items queued, or being processed across all channels
-	finishedEventChan chan bool      // used to signal that crawling is complete
-}
-
-// CreateCrawler creates a new Crawler type for the supplied starting URL (start).
-// Documents are loaded and parsed into WebPage instances using the loader interface, and saved
-// into the site map using the mapper interface.
-func CreateCrawler(start *url.URL, loader DocumentLoader, mapper SiteMapper) *Crawler {
-	return &Crawler{
-		docLoader:      loader,
+	pagesChan         chan crawledPage // pages to be ingested into the Site Map
+	urlLoadChan       chan Hyperlink   // URLs to be loaded by our pool of page loading workers
+	linksChan         chan Hyperlink   // Internal links read off processed pages
+	parseChan         chan fetchedLoad // pages downloaded waiting to be parsed (nil unless using parse workers)
+	finishedEventChan chan bool        // used to signal that crawling is complete
+}
+
+// CreateCrawler creates a new Crawler type for the supplied starting URL (start), configured using the
+// supplied options. See options.go for the available options.
+// Unless otherwise configured, documents are loaded and parsed into WebPage instances using a DocLoader
+// and DocParser. Pages must be added to a site map (or any other sink) using the WithSink option, unless
+// they are read using CrawlPages.
+func CreateCrawler(start *url.URL, opts ...Option) (*Crawler, error) {
+	if start == nil || !start.IsAbs() {
+		return nil, fmt.Errorf("crawler requires an absolute starting URL, got %v", start)
+	}
+	c := &Crawler{
 		startURL:       start,
-		siteMap:        mapper,
-		minLoadDelay:   1000,
+		minLoadDelay:   time.Second,
 		numLoaders:     5,
 		maxPagesToLoad: 25,
 		maxCrawlDepth:  0,
+		maxRetryAfter:  DefaultMaxRetryAfter,
+		trapLimits:     DefaultTrapLimits,
+		logger:         defaultLogger(),
+		ctx:            context.Background(),
+
+		progressInterval: time.Second,
 
-		pagesChan:         make(chan *WebPage, 20),
+		pagesChan:         make(chan crawledPage, 20),
 		urlLoadChan:       make(chan Hyperlink, 20),
 		linksChan:         make(chan Hyperlink),
-		pendingItemsChan:  make(chan int),
 		finishedEventChan: make(chan bool),
+		work:              CreateWorkTracker(),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	c.traps = CreateTrapDetector(c.trapLimits)
+	if c.traceCtx = c.ctx; c.tracer != nil {
+		c.traceCtx = withTracer(c.traceCtx, c.tracer)
+	}
+	if c.priority != nil {
+		// URLs wait in the queue, in priority order, until a loader is ready for them
+		c.urlQueue.SetPriority(linkScorer(c.priority))
+		c.urlLoadChan = make(chan Hyperlink)
 	}
-}
 
-// Starts concurrent crawling process. This method will block until crawling is complete
-func (c *Crawler) crawl() error {
+	if c.docLoader == nil {
+		loader := CreateDocumentLoader(CreateDocumentParser())
+		loader.logger = c.logger
+		if c.client != nil {
+			loader.Client = c.client
+		}
+		c.docLoader = loader
+	} else if c.client != nil {
+		return nil, fmt.Errorf("HTTP client cannot be set when a document loader is supplied")
+	}
+	return c, nil
+}
 
-	log.Printf("INFO: Starting crawl process...\n")
-	log.Printf("INFO:    start = %v\n", c.startURL)
-	log.Printf("INFO:    throttle (minimum time between request) = %v ms\n", c.minLoadDelay)
-	log.Printf("INFO:    load/parse thread count = %v\n", c.numLoaders)
-	if c.maxPagesToLoad == 0 {
-		log.Print("INFO:    max pages to load = None\n")
-	} else {
-		log.Printf("INFO:    max pages to load = %d\n", c.maxPagesToLoad)
+// Crawl starts the concurrent crawling process. This method will block until crawling is complete
+func (c *Crawler) Crawl() error {
+	if c.siteMap == nil {
+		return fmt.Errorf("no site map or other page sink supplied for crawling")
 	}
-	if c.maxCrawlDepth == 0 {
-		log.Print("INFO:    maximum crawl depth = None\n", c.maxCrawlDepth)
-	} else {
-		log.Printf("INFO:    maximum crawl depth = %d\n", c.maxCrawlDepth)
+
+	// Note a value of 0 for any limit means no limit is applied
+	c.logger.Info("Starting crawl process",
+		"start", c.startURL.String(),
+		"throttle", c.minLoadDelay,
+		"loaders", c.loaders(),
+		"maxPages", c.maxPagesToLoad,
+		"maxDepth", c.maxCrawlDepth,
+		"loadTimeout", c.loadTimeout,
+		"maxDuration", c.maxDuration)
+
+	//
+	// If the crawl duration is limited, we stop loading new URLs early enough for any loads in
+	// progress to complete (or time out) before the deadline
+	//
+	if c.maxDuration > 0 {
+		stopAfter := c.maxDuration
+		if c.loadTimeout > 0 && c.loadTimeout < c.maxDuration {
+			stopAfter -= c.loadTimeout
+		}
+		c.stopTime = time.Now().Add(stopAfter)
 	}
-	log.Printf("INFO:    extra logging = %v\n", c.verbose)
 
+	c.startTime = time.Now()
+	if c.blockCache != nil {
+		c.blockCache.StartCrawl()
+	}
 	var wg sync.WaitGroup
 
 	//
@@ -87,14 +288,31 @@ func (c *Crawler) crawl() error {
 	//
 	var loadTicker *time.Ticker
 	if c.minLoadDelay != 0 {
-		loadTicker = time.NewTicker(time.Duration(c.minLoadDelay) * time.Millisecond)
+		loadTicker = time.NewTicker(c.minLoadDelay)
 		defer loadTicker.Stop()
 	}
-	for i := 0; i < c.numLoaders; i++ {
+	// With parse workers, the loaders only download pages, leaving them to be parsed by a separate pool of
+	// goroutines so parsing CPU heavy pages doesn't hold up the network requests
+	fetcher, staged := c.docLoader.(documentFetcher)
+	if staged = staged && c.parseWorkers > 0 && !c.stableOrder; staged {
+		c.parseChan = make(chan fetchedLoad, 20)
+		for i := 0; i < c.parseWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.parsePages(fetcher)
+			}()
+		}
+	}
+	for i := 0; i < c.loaders(); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			c.loadPages(loadTicker)
+			if staged {
+				c.fetchPages(fetcher, loadTicker)
+			} else {
+				c.loadPages(loadTicker)
+			}
 		}()
 	}
 
@@ -139,36 +357,157 @@ func (c *Crawler) crawl() error {
 	}()
 
 	//
-	// Add our start URL to start the crawling process
+	// Optionally start a goroutine to periodically report progress. This isn't part of the pipeline so
+	// is stopped separately once crawling is complete.
 	//
-	c.pendingItemsChan <- 1
-	c.linksChan <- Hyperlink{c.startURL.String(), 1}
+	var progressWg sync.WaitGroup
+	progressDone := make(chan bool)
+	if c.progressFunc != nil {
+		progressWg.Add(1)
+		go func() {
+			defer progressWg.Done()
+			c.reportProgress(progressDone)
+		}()
+	}
+
+	//
+	// Optionally start a goroutine to watch memory use, switching to low memory mode if it gets too high and
+	// stopping the crawl before reaching the memory limit
+	//
+	memoryDone := make(chan bool)
+	if c.maxMemory > 0 {
+		// the garbage collector works harder as the limit nears, rather than let the heap grow to twice the
+		// memory in use
+		defer debug.SetMemoryLimit(debug.SetMemoryLimit(int64(c.maxMemory)))
+	}
+	if c.memoryThreshold > 0 || c.maxMemory > 0 {
+		progressWg.Add(1)
+		go func() {
+			defer progressWg.Done()
+			c.monitorMemory(memoryDone)
+		}()
+	}
+
+	//
+	// Add our start URL (or the URLs a previous crawl had still to load) and any extra seeds to start the
+	// crawling process
+	//
+	seeds := c.seeds
+	if len(seeds) == 0 {
+		seeds = []Hyperlink{{c.startURL.String(), 1}}
+	}
+	for _, seed := range c.extraSeeds {
+		seeds = append(seeds, Hyperlink{seed, 1})
+	}
+	c.work.Add(len(seeds)) // all added up front, so the crawl can't finish before the last seed is sent
+	for _, seed := range seeds {
+		c.linksChan <- seed
+	}
 
 	// Wait for the crawling to complete
 	wg.Wait()
-	close(c.pendingItemsChan)
+	c.endTime = time.Now()
+	c.finished.Store(true)
+	close(progressDone)
+	close(memoryDone)
+	progressWg.Wait()
+	if err := c.urlQueue.Close(); err != nil {
+		c.logger.Warn("Failed to remove URL queue spill file", "error", err)
+	}
 	return nil
 }
 
+// Progress returns a snapshot of the progress of the current (or last) crawl
+func (c *Crawler) Progress() CrawlProgress {
+	progress := CrawlProgress{
+		PagesLoaded: int(c.pagesLoaded.Load()),
+		Errors:      int(c.loadErrors.Load()),
+		InFlight:    int(c.inFlight.Load()),
+		Queued:      c.urlQueue.Len() + len(c.urlLoadChan) + c.holds.Waiting(),
+		Discovered:  int(c.discovered.Load()),
+		Done:        c.finished.Load(),
+	}
+	if progress.Done {
+		progress.Elapsed = c.endTime.Sub(c.startTime)
+	} else if !c.startTime.IsZero() {
+		progress.Elapsed = time.Since(c.startTime)
+	}
+
+	// crawling ends at the maximum crawl duration
+	var deadline time.Time
+	if c.maxDuration > 0 && !c.startTime.IsZero() {
+		deadline = c.startTime.Add(c.maxDuration)
+	}
+	c.estimator.estimate(&progress, time.Now(), c.maxPagesToLoad, deadline)
+	return progress
+}
+
+// reportProgress calls the progress function with a snapshot every progress interval until done is closed,
+// then once more with the final snapshot
+func (c *Crawler) reportProgress(done <-chan bool) {
+	ticker := time.NewTicker(c.progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.progressFunc(c.Progress())
+		case <-done:
+			c.progressFunc(c.Progress())
+			return
+		}
+	}
+}
+
+// CrawlPages crawls the website, sending each page to the supplied channel as it is loaded rather than
+// adding it to the site map. The channel is closed once crawling is complete. This method will block until
+// crawling is complete, so the channel must be read from another goroutine.
+func (c *Crawler) CrawlPages(ch chan<- *WebPage) error {
+	defer close(ch)
+	c.siteMap = PageHandler(func(page *WebPage) error {
+		ch <- page
+		return nil
+	})
+	return c.Crawl()
+}
+
+// OverBudget returns true if URLs weren't loaded because the document loader's download budget was used up
+func (c *Crawler) OverBudget() bool {
+	return c.overBudget.Load()
+}
+
+// Truncated returns true if the last crawl stopped before all pages were loaded because the maximum
+// crawl duration was reached or its context was cancelled (see WithContext), or because URLs queued on disk
+// in low memory mode couldn't be read back
+func (c *Crawler) Truncated() bool {
+	return c.truncated.Load()
+}
+
+// pastStopTime checks if we have reached the time to stop loading new URLs
+func (c *Crawler) pastStopTime() bool {
+	return !c.stopTime.IsZero() && time.Now().After(c.stopTime)
+}
+
+// cancelled checks if the crawl's context has been cancelled, so no more URLs are loaded (see WithContext)
+func (c *Crawler) cancelled() bool {
+	return c.ctx.Err() != nil
+}
+
 // monitorProgress: keep track of the number of items being processed or queued across all
 // the channels. When this count reaches zero we have completed the crawling process and should
 // close the channels so the crawling goroutines will complete. This is needed because our channels
 // form a loop so none can detect running out of work in isolation
 func (c *Crawler) monitorProgress() {
-	itemCount := 0
-	for delta := range c.pendingItemsChan {
-		itemCount += delta
-		if itemCount <= 0 {
-			// All channels are empty, and no work is in progress
-			log.Printf("INFO: Total number of queued items = %d, closing channels\n", itemCount)
-			c.finishedEventChan <- true
-			close(c.pagesChan)
-			close(c.urlLoadChan)
-			close(c.linksChan)
-			close(c.finishedEventChan)
-			return
-		}
+	<-c.work.Finished()
+	// All channels are empty, and no work is in progress
+	c.logger.Info("All queued items processed, closing channels", "queued", c.work.Pending())
+	c.finishedEventChan <- true
+	close(c.pagesChan)
+	close(c.urlLoadChan)
+	close(c.linksChan)
+	if c.parseChan != nil {
+		close(c.parseChan)
 	}
+	close(c.finishedEventChan)
 }
 
 // Read urls to be loaded from urlLoadChan, load and parse them, then send results to
@@ -177,18 +516,35 @@ func (c *Crawler) monitorProgress() {
 // to throttle our rate of loading)
 func (c *Crawler) loadPages(loadTicker *time.Ticker) {
 	for load := range c.urlLoadChan {
-		page, err := c.docLoader.LoadURL(load.urlStr)
-		if page != nil {
-			for link := range page.InternalLinks {
-				c.pendingItemsChan <- 1
-				c.linksChan <- Hyperlink{link, load.depth + 1} // send the links back to the crawler to keep going
-			}
-			c.pagesChan <- page // send page details to be ingested into site map
+		c.inFlight.Add(1)
+		start := time.Now()
+		ctx, end := startSpan(c.traceCtx, spanCrawl, load.urlStr)
+		c.logger.Debug("Loading URL", "event", eventFetchStart, "url", load.urlStr, "depth", load.depth)
+		page, err := c.loadURL(ctx, load.urlStr)
+		c.inFlight.Add(-1)
+		c.processPage(ctx, load, page, err, start)
+		end(err)
+		if loadTicker != nil {
+			<-loadTicker.C // make sure we have required delay between last load starting
+		}
+	}
+}
+
+// fetchPages: downloads urls read from urlLoadChan, sending them to parseChan to be parsed by the parse workers
+// (see parsePages). Throttled by loadTicker (if not nil) as for loadPages.
+func (c *Crawler) fetchPages(fetcher documentFetcher, loadTicker *time.Ticker) {
+	for load := range c.urlLoadChan {
+		c.inFlight.Add(1)
+		start := time.Now()
+		ctx, end := startSpan(c.traceCtx, spanCrawl, load.urlStr)
+		c.logger.Debug("Loading URL", "event", eventFetchStart, "url", load.urlStr, "depth", load.depth)
+		doc, err := withLoadContext(c, ctx, load.urlStr, fetcher.FetchContext)
+		if err != nil {
+			c.inFlight.Add(-1)
+			c.processPage(ctx, load, nil, err, start)
+			end(err)
 		} else {
-			if c.verbose {
-				log.Printf("TRACE : Ignoring URL : %v", err)
-			}
-			c.pendingItemsChan <- -1
+			c.parseChan <- fetchedLoad{load, doc, start, ctx, end}
 		}
 		if loadTicker != nil {
 			<-loadTicker.C // make sure we have required delay between last load starting
@@ -196,53 +552,557 @@ func (c *Crawler) loadPages(loadTicker *time.Ticker) {
 	}
 }
 
+// parsePages: parses the pages read from parseChan, then sends results to output channels
+func (c *Crawler) parsePages(fetcher documentFetcher) {
+	for fetched := range c.parseChan {
+		page, err := withLoadTimeout(c, fetched.load.urlStr, func() (*WebPage, error) { return fetcher.Parse(fetched.doc) })
+		c.inFlight.Add(-1)
+		c.processPage(fetched.ctx, fetched.load, page, err, fetched.start)
+		fetched.end(err)
+	}
+}
+
+// processPage: records the result of loading a url, sending the page (if loaded) and the links out of it
+// to the output channels. The page is ingested as part of the trace in ctx.
+func (c *Crawler) processPage(ctx context.Context, load Hyperlink, page *WebPage, err error, start time.Time) {
+	if errors.Is(err, errByteBudget) {
+		// not loaded, so left for a resumed crawl
+		if !c.overBudget.Swap(true) {
+			c.logger.Warn("Download budget used up, no more pages will be loaded", "url", load.urlStr)
+		}
+		c.deferURL(load)
+		c.work.Done()
+		c.dispatched.Add(-1)
+		return
+	}
+	if err != nil && c.cancelled() {
+		// stopped by the crawl being cancelled, so left for a resumed crawl
+		c.truncated.Store(true)
+		c.deferURL(load)
+		c.work.Done()
+		c.dispatched.Add(-1)
+		return
+	}
+	if c.holdRateLimited(load, page, err) {
+		// loaded again once the host's hold expires, so still outstanding work
+		c.logger.Debug("Rate limited URL waiting to be retried", "event", eventHold, "url", load.urlStr, "depth", load.depth)
+		c.dispatched.Add(-1)
+		return
+	}
+	c.recordLoadResult(load.urlStr, err)
+	if page != nil && !c.visitPage(&PageVisit{page, load.urlStr, load.depth, time.Since(start)}) {
+		// discarded by a callback
+		c.pagesLoaded.Add(1)
+		c.logger.Debug("Page discarded", "event", eventSkip, "url", load.urlStr, "depth", load.depth)
+		c.work.Done()
+	} else if page != nil {
+		c.pagesLoaded.Add(1)
+		c.pagesKept.Add(1) // before the load is no longer dispatched, so the page budget is never overcommitted
+		for _, link := range c.linksToFollow(page) {
+			c.work.Add(1)
+			c.linksChan <- Hyperlink{link, load.depth + 1} // send the links back to the crawler to keep going
+		}
+		_, endIngest := startSpan(ctx, spanIngest, load.urlStr)
+		c.pagesChan <- crawledPage{page, endIngest} // send page details to be ingested into site map
+	} else {
+		var authErr *AuthRequiredError
+		if !errors.As(err, &authErr) {
+			c.loadErrors.Add(1) // pages needing authentication aren't errors
+		}
+		c.logger.Debug("Ignoring URL", "event", eventError, "url", load.urlStr, "depth", load.depth, "error", err)
+		c.work.Done()
+	}
+	c.dispatched.Add(-1)
+}
+
+// loaders returns the number of goroutines used to load pages, which is always 1 for a stable crawl order
+func (c *Crawler) loaders() int {
+	if c.stableOrder {
+		return 1
+	}
+	return c.numLoaders
+}
+
+// linksToFollow returns the internal links out of a page to queue for loading, sorted for a stable crawl order
+func (c *Crawler) linksToFollow(page *WebPage) []string {
+	if c.stableOrder {
+		return sortedKeys(page.InternalLinks)
+	}
+	links := make([]string, 0, len(page.InternalLinks))
+	for link := range page.InternalLinks {
+		links = append(links, link)
+	}
+	return links
+}
+
+// visitPage calls each OnPage callback in turn, returning false if the page is to be discarded
+func (c *Crawler) visitPage(visit *PageVisit) bool {
+	for _, onPage := range c.onPage {
+		if !onPage(visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadURL loads a single URL using the document loader, as part of the trace in ctx if the loader supports
+// tracing. If a load timeout is set, the load is cancelled once the timeout expires so a single pathological
+// page can't permanently occupy a loading goroutine. Loaders without LoadURLContext can't be cancelled, so a
+// watchdog abandons their loads instead, with an abandoned load completing in the background and its results
+// discarded.
+func (c *Crawler) loadURL(ctx context.Context, urlStr string) (*WebPage, error) {
+	if loader, ok := c.docLoader.(contextLoader); ok {
+		return withLoadContext(c, ctx, urlStr, loader.LoadURLContext)
+	}
+	return withLoadTimeout(c, urlStr, func() (*WebPage, error) { return c.docLoader.LoadURL(urlStr) })
+}
+
+// withLoadContext calls load (a stage of loading urlStr) with a context derived from ctx which is cancelled
+// once the crawler's load timeout (if any) expires, so the request and anything else the load is doing is
+// stopped rather than left running
+func withLoadContext[T any](c *Crawler, ctx context.Context, urlStr string, load func(ctx context.Context, urlStr string) (T, error)) (T, error) {
+	if c.loadTimeout == 0 {
+		return load(ctx, urlStr)
+	}
+	loadCtx, cancel := context.WithTimeout(ctx, c.loadTimeout)
+	defer cancel()
+	value, err := load(loadCtx, urlStr)
+	if err != nil && ctx.Err() == nil && errors.Is(loadCtx.Err(), context.DeadlineExceeded) {
+		c.logger.Warn("Cancelled page load", "event", eventError, "url", urlStr, "duration", c.loadTimeout)
+		var none T
+		return none, &loadTimeoutError{urlStr, c.loadTimeout}
+	}
+	return value, err
+}
+
+// withLoadTimeout calls load (a stage of loading urlStr which can't be cancelled), abandoning it once the
+// crawler's load timeout (if any) expires as for loadURL
+func withLoadTimeout[T any](c *Crawler, urlStr string, load func() (T, error)) (T, error) {
+	if c.loadTimeout == 0 {
+		return load()
+	}
+
+	type loadResult struct {
+		value T
+		err   error
+	}
+	resultChan := make(chan loadResult, 1) // buffered so an abandoned load can always complete
+	go func() {
+		value, err := load()
+		resultChan <- loadResult{value, err}
+	}()
+
+	watchdog := time.NewTimer(c.loadTimeout)
+	defer watchdog.Stop()
+	select {
+	case result := <-resultChan:
+		return result.value, result.err
+	case <-watchdog.C:
+		c.logger.Warn("Abandoned page load", "event", eventError, "url", urlStr, "duration", c.loadTimeout)
+		var none T
+		return none, &loadTimeoutError{urlStr, c.loadTimeout}
+	}
+}
+
+// recordLoadResult updates the block cache (if any), the redirect loops, the URLs requiring authentication
+// and the failures found with the result of loading a URL
+func (c *Crawler) recordLoadResult(urlStr string, err error) {
+	var loopErr *RedirectLoopError
+	var authErr *AuthRequiredError
+	if err != nil && !errors.As(err, &authErr) {
+		c.recordFailure(urlStr, err)
+	}
+	if errors.As(err, &loopErr) {
+		c.logger.Warn("Redirect loop", "event", eventError, "url", urlStr, "cycle", strings.Join(loopErr.Cycle, " -> "))
+		c.resultsMutex.Lock()
+		c.redirectLoops = append(c.redirectLoops, loopErr)
+		c.resultsMutex.Unlock()
+	} else if errors.As(err, &authErr) {
+		c.logger.Debug("Authentication required", "url", urlStr, "login", authErr.LoginURL)
+		c.resultsMutex.Lock()
+		c.authRequired = append(c.authRequired, authErr)
+		c.resultsMutex.Unlock()
+	}
+	if c.blockCache == nil {
+		return
+	}
+	var statusErr *StatusError
+	if err == nil {
+		c.blockCache.RecordSuccess(urlStr)
+	} else if errors.As(err, &statusErr) && c.blockCache.RecordFailure(urlStr, statusErr.StatusCode, time.Now()) {
+		c.logger.Debug("Recorded blocked URL", "url", urlStr, "status", statusErr.StatusCode)
+	}
+}
+
+// recordFailure records a failed attempt to load a URL
+func (c *Crawler) recordFailure(urlStr string, err error) {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	if c.failures == nil {
+		c.failures = make(map[string]*LoadFailure)
+	}
+	failure, found := c.failures[urlStr]
+	if !found {
+		failure = &LoadFailure{URL: urlStr}
+		c.failures[urlStr] = failure
+	}
+	failure.Class, failure.StatusCode, failure.Error = ClassifyLoadError(err), 0, err.Error()
+	if statusErr := (*StatusError)(nil); errors.As(err, &statusErr) {
+		failure.StatusCode = statusErr.StatusCode
+	}
+	failure.Attempts++
+}
+
 // enqueueNewUrls: reads URLS extracted from web pages (from linksChan) and add them into the
-// queue after checking for duplicates
+// queue after checking for duplicates.
+// URLs are recorded at the smallest depth they are found at. A URL skipped because of its depth (by the URL
+// filter, or the maximum crawl depth or number of URLs at its depth) is checked again if found nearer the
+// start, and a URL still waiting to be loaded is queued again at the smaller depth (see requeue).
 func (c *Crawler) enqueueNewUrls() {
-	count := 0
-	seen := make(map[string]bool)
+	seen := createSeenSet(c.visited)
+	depthCounts := make(map[int]int) // URLs queued at each depth
+
+	// URLs skipped as their depth was full are only deferred once all links are read, as they are loaded
+	// after all if found nearer the start
+	var depthDeferred []Hyperlink
+	depthDeferredIndex := make(map[string]int)
+	defer func() {
+		for _, link := range depthDeferred {
+			if len(link.urlStr) != 0 {
+				c.deferURL(link)
+			}
+		}
+	}()
+
 	for link := range c.linksChan {
+		if c.lowMemory.Load() {
+			seen.Compact()
+		}
+		depth, skipped, found := seen.Lookup(link.urlStr)
+		if found && skipped && link.depth < depth {
+			// skipped at a greater depth, so checked again now a shorter path to it has been found
+			c.logger.Debug("Found skipped URL nearer the start", "url", link.urlStr, "depth", link.depth, "previousDepth", depth)
+			if i, deferred := depthDeferredIndex[link.urlStr]; deferred {
+				depthDeferred[i].urlStr = ""
+				delete(depthDeferredIndex, link.urlStr)
+			}
+		}
+
 		// if we have seen this url before skip it otherwise add it to channel to be loaded
-		if _, skip := seen[link.urlStr]; skip {
+		if found && !skipped && link.depth < depth && c.requeue(link, depthCounts) {
+			// still waiting to be loaded, so queued again at the smaller depth
+			seen.Add(link.urlStr, link.depth)
+		} else if found && (!skipped || link.depth >= depth) {
 			// already seen this url - ignore it
-			c.pendingItemsChan <- -1
-		} else if c.maxPagesToLoad > 0 && count >= c.maxPagesToLoad {
-			// stop crawling as we've reached our page load limit
-			seen[link.urlStr] = true
-			c.pendingItemsChan <- -1
+			c.work.Done()
+		} else if !c.underRootPath(link) {
+			// a boundary link out of the section of the site being crawled
+			c.logger.Debug("Skipping URL outside root path", "event", eventSkip, "url", link.urlStr, "depth", link.depth)
+			seen.Add(link.urlStr, link.depth)
+			c.recordBoundaryLink(link.urlStr)
+			c.work.Done()
+		} else if !c.allowURL(link) {
+			// rejected by the url filter (which may accept the URL at a smaller depth)
+			c.logger.Debug("Skipping filtered URL", "event", eventSkip, "url", link.urlStr, "depth", link.depth)
+			seen.Skip(link.urlStr, link.depth)
+			c.work.Done()
+		} else if c.inCrawlTrap(link) {
+			// part of a runaway url pattern (e.g. an infinite calendar)
+			seen.Add(link.urlStr, link.depth)
+			c.work.Done()
+		} else if c.maxPerDepth > 0 && depthCounts[link.depth] >= c.maxPerDepth {
+			// stop crawling this level as we've reached its limit
+			c.logger.Debug("Skipping URL as depth limit reached", "event", eventSkip, "url", link.urlStr, "depth", link.depth)
+			seen.Skip(link.urlStr, link.depth)
+			depthDeferredIndex[link.urlStr] = len(depthDeferred)
+			depthDeferred = append(depthDeferred, link)
+			c.work.Done()
 		} else if c.maxCrawlDepth > 0 && link.depth > c.maxCrawlDepth {
 			// stop crawling as we've reached the maximum crawl depth
-			seen[link.urlStr] = true
-			c.pendingItemsChan <- -1
+			seen.Skip(link.urlStr, link.depth)
+			c.work.Done()
+		} else if c.blockCache != nil && c.blockCache.IsBlocked(link.urlStr, time.Now()) {
+			// skip urls which have consistently been blocked in previous crawls
+			c.logger.Debug("Skipping blocked URL", "event", eventSkip, "url", link.urlStr, "depth", link.depth)
+			seen.Add(link.urlStr, link.depth)
+			c.work.Done()
+		} else if c.pastStopTime() {
+			// stop crawling as we've reached the maximum crawl duration
+			seen.Add(link.urlStr, link.depth)
+			c.truncated.Store(true)
+			c.deferURL(link)
+			c.work.Done()
 		} else {
 			// add url it to our in-memory queue to be crawled
-			if c.verbose {
-				log.Printf("TRACE: Queuing up URL %v\n", link)
+			c.logger.Debug("Queuing up URL", "event", eventEnqueue, "url", link.urlStr, "depth", link.depth)
+			seen.Add(link.urlStr, link.depth)
+			depthCounts[link.depth]++
+			c.discovered.Add(1)
+			c.queued = append(c.queued, link.urlStr)
+			if !c.lowMemory.Load() {
+				c.queuedDepths.Add(link) // not tracked in low memory mode, so URLs aren't queued again
 			}
-			seen[link.urlStr] = true
-			count++
 			c.urlQueue.Push(link)
 		}
 	}
 }
 
+// requeue queues a URL found at a smaller depth again if it is still waiting to be loaded at a greater depth
+// (and the smaller depth isn't full), so it is loaded at the right depth with the links out of it a level
+// nearer the start. The deeper entry is dropped when it is dequeued. Returns false if the URL isn't queued
+// again, as it has already been loaded (or is being loaded).
+func (c *Crawler) requeue(link Hyperlink, depthCounts map[int]int) bool {
+	if c.maxPerDepth > 0 && depthCounts[link.depth] >= c.maxPerDepth {
+		return false
+	}
+	previous, waiting := c.queuedDepths.Shorten(link)
+	if !waiting {
+		return false
+	}
+	c.logger.Debug("Queuing up URL again nearer the start", "event", eventEnqueue, "url", link.urlStr, "depth", link.depth, "previousDepth", previous)
+	depthCounts[previous]--
+	depthCounts[link.depth]++
+	c.urlQueue.Push(link)
+	return true
+}
+
+// inCrawlTrap checks if a link is in a crawl trap which has exceeded its limits, logging a warning the first
+// time each trap is detected
+func (c *Crawler) inCrawlTrap(link Hyperlink) bool {
+	u, err := url.Parse(link.urlStr)
+	if err != nil {
+		return false
+	}
+	c.resultsMutex.Lock()
+	trap := c.traps.Check(u)
+	var skipped int
+	if trap != nil {
+		skipped = trap.Skipped
+	}
+	c.resultsMutex.Unlock()
+	if trap == nil {
+		return false
+	}
+	if skipped == 1 {
+		c.logger.Warn("Crawl trap detected, skipping further matching URLs", "kind", trap.Kind.String(),
+			"pattern", trap.Pattern, "url", link.urlStr)
+	} else {
+		c.logger.Debug("Skipping URL in crawl trap", "event", eventSkip, "url", link.urlStr, "pattern", trap.Pattern)
+	}
+	return true
+}
+
+// allowURL: returns true if the link passes the url filter (if any)
+func (c *Crawler) allowURL(link Hyperlink) bool {
+	if c.urlFilter == nil {
+		return true
+	}
+	u, err := url.Parse(link.urlStr)
+	if err != nil {
+		return false
+	}
+	return c.urlFilter(u, link.depth)
+}
+
+// underRootPath: returns true if the link is under the root path (if any)
+func (c *Crawler) underRootPath(link Hyperlink) bool {
+	if len(c.rootPath) == 0 {
+		return true
+	}
+	u, err := url.Parse(link.urlStr)
+	return err != nil || InPathPrefix(u.Path, c.rootPath)
+}
+
+// recordBoundaryLink: records a URL outside the root path
+func (c *Crawler) recordBoundaryLink(urlStr string) {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	if c.boundary == nil {
+		c.boundary = make(map[string]bool)
+	}
+	c.boundary[urlStr] = true
+}
+
 // populateSiteMap: reads pages off the pagesChan and add them to the site map
 func (c *Crawler) populateSiteMap() {
-	for page := range c.pagesChan {
-		if _, err := c.siteMap.AddPage(page); err != nil {
-			log.Printf("WARN: %v\n", err)
+	for crawled := range c.pagesChan {
+		_, err := c.siteMap.AddPage(crawled.page)
+		if err != nil {
+			c.logger.Warn("Failed to add page to site map", "url", crawled.page.URL.String(), "error", err)
 		}
-		c.pendingItemsChan <- -1
+		crawled.endIngest(err)
+		c.work.Done()
+	}
+}
+
+// deferURL: records a URL which was not loaded because a page, depth, time, download or memory limit was
+// reached
+func (c *Crawler) deferURL(link Hyperlink) {
+	c.deferMutex.Lock()
+	defer c.deferMutex.Unlock()
+	c.deferred = append(c.deferred, link)
+}
+
+// Frontier returns the URLs found by the last crawl which were not loaded because the maximum number of
+// pages (overall or at their depth), crawl duration or download budget was reached (or the crawl was
+// cancelled), so crawling can be resumed later with WithResume
+func (c *Crawler) Frontier() []FrontierURL {
+	c.deferMutex.Lock()
+	defer c.deferMutex.Unlock()
+	frontier := make([]FrontierURL, 0, len(c.deferred))
+	for _, link := range c.deferred {
+		frontier = append(frontier, FrontierURL{link.urlStr, link.depth})
 	}
+	return frontier
+}
+
+// Visited returns the URLs loaded (or which failed to load) by the last crawl, including any loaded by a
+// crawl it resumed. Only valid once crawling is complete.
+func (c *Crawler) Visited() []string {
+	if !c.finished.Load() {
+		return nil
+	}
+	c.deferMutex.Lock()
+	defer c.deferMutex.Unlock()
+	deferred := make(map[string]bool, len(c.deferred))
+	for _, link := range c.deferred {
+		deferred[link.urlStr] = true
+	}
+	visited := make([]string, 0, len(c.visited)+len(c.queued))
+	for urlStr := range c.visited {
+		visited = append(visited, urlStr)
+	}
+	for _, urlStr := range c.queued {
+		if !deferred[urlStr] {
+			visited = append(visited, urlStr)
+		}
+	}
+	sort.Strings(visited)
+	return visited
+}
+
+// Errors returns the URLs which failed to load, sorted by URL. URLs redirecting to a login page (see
+// AuthRequired) aren't included.
+func (c *Crawler) Errors() []LoadFailure {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	failures := make([]LoadFailure, 0, len(c.failures))
+	for _, failure := range c.failures {
+		failures = append(failures, *failure)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].URL < failures[j].URL })
+	return failures
+}
+
+// BoundaryLinks returns the URLs outside the root path (see WithRootPath) which were linked to but not crawled,
+// sorted by URL
+func (c *Crawler) BoundaryLinks() []string {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	return sortedKeys(c.boundary)
+}
+
+// AuthRequired returns the URLs found to redirect to a login page, sorted by URL
+func (c *Crawler) AuthRequired() []*AuthRequiredError {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	authRequired := append([]*AuthRequiredError(nil), c.authRequired...)
+	sort.Slice(authRequired, func(i, j int) bool { return authRequired[i].URL < authRequired[j].URL })
+	return authRequired
+}
+
+// RedirectLoops returns the URLs found to redirect to themselves or in a redirect cycle, sorted by URL
+func (c *Crawler) RedirectLoops() []*RedirectLoopError {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	loops := append([]*RedirectLoopError(nil), c.redirectLoops...)
+	sort.Slice(loops, func(i, j int) bool { return loops[i].URL < loops[j].URL })
+	return loops
+}
+
+// Traps returns the crawl traps detected, along with the number of URLs skipped in each, sorted by pattern
+func (c *Crawler) Traps() []CrawlTrap {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	return c.traps.Traps()
+}
+
+// dispatchURL: sends a url to be loaded, unless the maximum number of pages has been loaded. While the loads
+// in progress could reach the maximum, it waits for them to complete as any which fail (or are discarded)
+// free up their share of the limit, so exactly the maximum number of pages are loaded when there are enough.
+func (c *Crawler) dispatchURL(next Hyperlink) {
+	if c.overBudget.Load() {
+		c.deferURL(next)
+		c.work.Done()
+		return
+	}
+	if c.cancelled() {
+		// stop crawling as the crawl has been cancelled
+		c.truncated.Store(true)
+		c.deferURL(next)
+		c.work.Done()
+		return
+	}
+	for c.maxPagesToLoad > 0 {
+		dispatched := c.dispatched.Load() // read first, as a load stops being dispatched after its page is kept
+		kept := c.pagesKept.Load()
+		if kept >= int64(c.maxPagesToLoad) {
+			// stop crawling as we've reached our page load limit
+			c.deferURL(next)
+			c.work.Done()
+			return
+		}
+		if kept+dispatched < int64(c.maxPagesToLoad) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// block until channel accepts next url
+	c.dispatched.Add(1)
+	c.urlLoadChan <- next
 }
 
 // dequeuUrls: removes urls to be crawled from the internal queue and sends them to the urlLoadChan
+// Once the maximum crawl duration, number of pages or memory limit is reached the queue is drained without
+// loading the remaining urls.
 func (c *Crawler) dequeueUrls() {
 	for {
+		if (c.lowMemory.Load() && len(c.urlLoadChan) > 0) || (c.memoryPause.Load() && !c.memoryStop.Load()) {
+			// in low memory mode, URLs stay in the (disk backed) queue until a loader is ready for them, and
+			// none are loaded while paused near the memory limit
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		// URLs waiting for a host's hold to expire are queued again once it has (or straight away once no more
+		// URLs are to be loaded, so they are deferred)
+		for _, link := range c.holds.Release(time.Now(), c.pastStopTime() || c.memoryStop.Load() || c.cancelled()) {
+			c.urlQueue.Push(link)
+		}
 		next, ok := c.urlQueue.Pop()
-		if ok {
-			// block until channel accepts next url
-			c.urlLoadChan <- next
+		if lost, err := c.urlQueue.TakeLost(); lost > 0 {
+			// URLs spilled to disk which can't be read back are never loaded, so the crawl finishes without them
+			c.logger.Error("Failed to read URLs queued on disk, so they won't be loaded", "count", lost, "error", err)
+			c.truncated.Store(true)
+			for ; lost > 0; lost-- {
+				c.work.Done()
+			}
+		}
+		if ok && !c.queuedDepths.Take(next) {
+			// out of date, as the URL was queued again nearer the start
+			c.work.Done()
+		} else if ok && c.pastStopTime() {
+			c.truncated.Store(true)
+			c.deferURL(next)
+			c.work.Done()
+		} else if ok && c.memoryStop.Load() {
+			// stop crawling as we've reached the memory limit
+			c.deferURL(next)
+			c.work.Done()
+		} else if host := urlHost(next.urlStr); ok && c.holds.Held(host, time.Now()) {
+			// only URLs on a held host wait, so the rest of the crawl continues
+			c.holds.Wait(host, next)
+		} else if ok {
+			c.dispatchURL(next)
 		} else {
 			select {
 			case <-c.finishedEventChan:
@@ -0,0 +1,874 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createTestSite creates a mock web server hosting the supplied pages, where each page is a path
+// mapped to the paths of the pages it links to
+func createTestSite(pages map[string][]string) *httptest.Server {
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		links, found := pages[req.URL.Path]
+		if !found {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		fmt.Fprintf(rw, "<HTML><HEAD><TITLE>Page %s</TITLE></HEAD><BODY>", req.URL.Path)
+		for _, link := range links {
+			fmt.Fprintf(rw, `<a href="%s">Link</a>`, link)
+		}
+		fmt.Fprint(rw, "</BODY></HTML>")
+	}
+	return httptest.NewServer(http.HandlerFunc(mockHandler))
+}
+
+// createTestCrawler creates a crawler for the mock server with no throttling or page limits, plus any
+// additional options supplied
+func createTestCrawler(t *testing.T, server *httptest.Server, opts ...Option) *Crawler {
+	opts = append([]Option{WithThrottle(0), WithMaxPages(0)}, opts...)
+	crawler, err := CreateCrawler(mustParseURL(t, server.URL), opts...)
+	if err != nil {
+		t.Fatalf("Failed to create crawler: %v", err)
+	}
+	return crawler
+}
+
+func TestCrawlPages(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":    {"/a", "/b"},
+		"/a":   {"/", "/b", "/a/1"},
+		"/b":   {"/missing"},
+		"/a/1": {"/a"},
+	})
+	defer server.Close()
+
+	crawler := createTestCrawler(t, server)
+	ch := make(chan *WebPage)
+	done := make(chan error)
+	go func() {
+		done <- crawler.CrawlPages(ch)
+	}()
+
+	var got []string
+	for page := range ch {
+		got = append(got, page.URL.Path)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error from CrawlPages: %v", err)
+	}
+
+	sort.Strings(got)
+	expected := []string{"", "/a", "/a/1", "/b"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Incorrect pages crawled: expected %v, got %v", expected, got)
+	}
+}
+
+// HangingLoader is a mock document loader which never returns when loading the hanging URL
+type HangingLoader struct {
+	loader  DocumentLoader
+	hangURL string
+}
+
+// LoadURL blocks forever for the hanging URL, otherwise it uses the wrapped loader
+func (h *HangingLoader) LoadURL(urlStr string) (*WebPage, error) {
+	if urlStr == h.hangURL {
+		select {}
+	}
+	return h.loader.LoadURL(urlStr)
+}
+
+func TestCrawlURLFilter(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":      {"/a", "/b?utm_source=x"},
+		"/a":     {"/a/1", "/a/1/x"},
+		"/b":     {},
+		"/a/1":   {},
+		"/a/1/x": {},
+	})
+	defer server.Close()
+
+	// skip query strings and anything more than one link from the starting page
+	filter := func(u *url.URL, depth int) bool {
+		return u.RawQuery == "" && depth <= 2
+	}
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithURLFilter(filter))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	var got []string
+	for key := range siteMap.Pages {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	expected := []string{server.URL, server.URL + "/a"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Incorrect pages crawled: expected %v, got %v", expected, got)
+	}
+}
+
+func TestCrawlOnPage(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":    {"/a", "/b"},
+		"/a":   {},
+		"/b":   {"/b/1"},
+		"/b/1": {},
+	})
+	defer server.Close()
+
+	// record every page visited, and discard /b (and so never crawl /b/1)
+	var mutex sync.Mutex
+	visited := make(map[string]int)
+	record := func(visit *PageVisit) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if visit.Page.StatusCode != http.StatusOK || visit.Page.Header.Get("Content-Type") != "text/html" {
+			t.Errorf("Missing response metadata for %s: status %d, headers %v", visit.RequestURL, visit.Page.StatusCode, visit.Page.Header)
+		}
+		visited[visit.Page.URL.Path] = visit.Depth
+		return true
+	}
+	discard := func(visit *PageVisit) bool {
+		return visit.Page.URL.Path != "/b"
+	}
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithOnPage(record, discard))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	expectedVisits := map[string]int{"": 1, "/a": 2, "/b": 2}
+	if fmt.Sprint(visited) != fmt.Sprint(expectedVisits) {
+		t.Errorf("Incorrect pages visited: expected %v, got %v", expectedVisits, visited)
+	}
+	var got []string
+	for key := range siteMap.Pages {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+	expected := []string{server.URL, server.URL + "/a"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Incorrect pages added: expected %v, got %v", expected, got)
+	}
+}
+
+func TestCrawlRedirectLoops(t *testing.T) {
+	site := createTestSite(map[string][]string{
+		"/":   {"/loop", "/ok"},
+		"/ok": {},
+	})
+	defer site.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/loop" {
+			http.Redirect(rw, req, "/loop", http.StatusFound)
+			return
+		}
+		site.Config.Handler.ServeHTTP(rw, req)
+	}))
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatal(err)
+	}
+	loops := crawler.RedirectLoops()
+	if len(loops) != 1 || loops[0].URL != server.URL+"/loop" || len(siteMap.Pages) != 2 {
+		t.Fatalf("Incorrect redirect loops: expected %s, got %v with %d pages", server.URL+"/loop", loops, len(siteMap.Pages))
+	}
+
+	var buf strings.Builder
+	if err := PrintRedirectLoops(&buf, loops, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("\n\n ----- URLs in redirect loops (1) -----\n %s/loop:\n     %s/loop -> %s/loop\n",
+		server.URL, server.URL, server.URL)
+	if buf.String() != expected {
+		t.Errorf("Incorrect redirect loop report: expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestCrawlAuthRequired(t *testing.T) {
+	site := createTestSite(map[string][]string{
+		"/":      {"/account", "/orders", "/ok"},
+		"/ok":    {},
+		"/login": {},
+	})
+	defer site.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/account" || req.URL.Path == "/orders" {
+			http.Redirect(rw, req, "/login?next="+req.URL.Path, http.StatusFound)
+			return
+		}
+		site.Config.Handler.ServeHTTP(rw, req)
+	}))
+	defer server.Close()
+
+	loader := CreateDocumentLoader(CreateDocumentParser())
+	loader.LoginPattern = regexp.MustCompile(DefaultLoginPattern)
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithLoader(loader))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatal(err)
+	}
+	authRequired := crawler.AuthRequired()
+	if len(authRequired) != 2 || authRequired[0].URL != server.URL+"/account" || len(siteMap.Pages) != 2 {
+		t.Fatalf("Incorrect URLs requiring authentication: expected /account and /orders, got %v with %d pages", authRequired, len(siteMap.Pages))
+	}
+
+	var buf strings.Builder
+	if err := PrintAuthRequired(&buf, authRequired, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("\n\n ----- URLs requiring authentication (2) -----\n %[1]s/login:\n     %[1]s/account\n     %[1]s/orders\n",
+		server.URL)
+	if buf.String() != expected {
+		t.Errorf("Incorrect authentication report: expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestCrawlTraps(t *testing.T) {
+
+	// a calendar linking to the next month forever, unbounded pagination and a link which keeps adding to
+	// the path
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var next string
+		switch {
+		case req.URL.Path == "/":
+			next = `<a href="/cal/2024-01">Calendar</a><a href="/list/page/1">List</a><a href="/loop">Loop</a>`
+		case strings.HasPrefix(req.URL.Path, "/cal/"):
+			month, _ := time.Parse("2006-01", strings.TrimPrefix(req.URL.Path, "/cal/"))
+			next = `<a href="/cal/` + month.AddDate(0, 1, 0).Format("2006-01") + `">Next</a>`
+		case strings.HasPrefix(req.URL.Path, "/list/page/"):
+			page, _ := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/list/page/"))
+			next = `<a href="/list/page/` + strconv.Itoa(page+1) + `">Next</a>`
+		default:
+			next = `<a href="` + req.URL.Path + `/loop">Loop</a>`
+		}
+		rw.Header().Add("Content-Type", "text/html")
+		fmt.Fprintf(rw, "<HTML><BODY>%s</BODY></HTML>", next)
+	}))
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	limits := TrapLimits{MaxSegmentRepeats: 2, MaxDateVariants: 5, MaxPageVariants: 3}
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithTrapLimits(limits))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	if len(siteMap.Pages) != 11 {
+		t.Errorf("Incorrect number of pages crawled: expected %d, got %d", 11, len(siteMap.Pages))
+	}
+	host := mustParseURL(t, server.URL).Host
+	expected := fmt.Sprintf("[{repeated path segment %[1]s loop 1} {calendar dates %[1]s/cal/{date} 1} "+
+		"{pagination %[1]s/list/page/{n} 1}]", host)
+	if got := fmt.Sprint(crawler.Traps()); got != expected {
+		t.Errorf("Incorrect crawl traps: expected %s, got %s", expected, got)
+	}
+}
+
+func TestCrawlNumericIDsNotTrapped(t *testing.T) {
+
+	// product IDs which look like years aren't taken to be dates by the default trap limits
+	pages := map[string][]string{"/": nil}
+	for id := 1999; id <= 2150; id++ {
+		path := fmt.Sprintf("/products/%d", id)
+		pages["/"] = append(pages["/"], path)
+		pages[path] = nil
+	}
+	server := createTestSite(pages)
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	if len(siteMap.Pages) != len(pages) || len(crawler.Traps()) != 0 {
+		t.Errorf("Incorrect crawl: expected %d pages and no traps, got %d pages and %v", len(pages), len(siteMap.Pages), crawler.Traps())
+	}
+}
+
+func TestCrawlLoadTimeout(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":  {"/a", "/b"},
+		"/a": {},
+		"/b": {},
+	})
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	loader := &HangingLoader{CreateDocumentLoader(CreateDocumentParser()), server.URL + "/a"}
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithLoader(loader), WithWorkers(1),
+		WithLoadTimeout(50*time.Millisecond))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	// the hanging page is abandoned, the worker moves on to the other pages
+	if len(siteMap.Pages) != 2 {
+		t.Fatalf("Incorrect number of pages crawled: expected %d, got %d", 2, len(siteMap.Pages))
+	}
+	if _, found := siteMap.Pages[server.URL+"/b"]; !found {
+		t.Fatalf("Page not crawled after timeout: %s", server.URL+"/b")
+	}
+}
+
+func TestCrawlLoadTimeoutCancels(t *testing.T) {
+
+	// /a stalls until its request is cancelled
+	cancelled := make(chan bool, 1)
+	site := createTestSite(map[string][]string{
+		"/":  {"/a", "/b"},
+		"/b": {},
+	})
+	defer site.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/a" {
+			<-req.Context().Done()
+			cancelled <- true
+			return
+		}
+		site.Config.Handler.ServeHTTP(rw, req)
+	}))
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithWorkers(1), WithLoadTimeout(50*time.Millisecond))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Request for page not cancelled after timeout")
+	}
+	if _, found := siteMap.Pages[server.URL+"/b"]; !found {
+		t.Fatalf("Page not crawled after timeout: %s", server.URL+"/b")
+	}
+	if errs := crawler.Errors(); len(errs) != 1 || errs[0].Class != LoadErrorTimeout {
+		t.Errorf("Incorrect load errors: expected a timeout for %s/a, got %v", server.URL, errs)
+	}
+}
+
+func mustParseURL(t *testing.T, rawurl string) *url.URL {
+	URL, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatalf("Invalid URL supplied in test case: %v", err)
+	}
+	return URL
+}
+
+// SlowLoader is a mock document loader which takes a fixed time to load each page
+type SlowLoader struct {
+	loader DocumentLoader
+	delay  time.Duration
+}
+
+// LoadURL waits for the delay then uses the wrapped loader
+func (s *SlowLoader) LoadURL(urlStr string) (*WebPage, error) {
+	time.Sleep(s.delay)
+	return s.loader.LoadURL(urlStr)
+}
+
+func TestCrawlMaxDuration(t *testing.T) {
+
+	// a long chain of pages
+	pages := make(map[string][]string)
+	pages["/"] = []string{"/1"}
+	for i := 1; i < 100; i++ {
+		pages[fmt.Sprintf("/%d", i)] = []string{fmt.Sprintf("/%d", i+1)}
+	}
+	server := createTestSite(pages)
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	loader := &SlowLoader{CreateDocumentLoader(CreateDocumentParser()), 20 * time.Millisecond}
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithLoader(loader), WithMaxDuration(200*time.Millisecond))
+	start := time.Now()
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	// validate
+	// Crawling should stop promptly after the deadline, with only part of the site loaded
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Crawl took too long: %v", elapsed)
+	}
+	if !crawler.Truncated() {
+		t.Error("Crawl not marked as truncated")
+	}
+	if len(siteMap.Pages) == 0 || len(siteMap.Pages) >= 100 {
+		t.Errorf("Incorrect number of pages crawled: got %d", len(siteMap.Pages))
+	}
+}
+
+func TestCrawlCancelled(t *testing.T) {
+
+	// a long chain of pages, with the crawl cancelled once the third is loaded
+	pages := make(map[string][]string)
+	pages["/"] = []string{"/1"}
+	for i := 1; i < 100; i++ {
+		pages[fmt.Sprintf("/%d", i)] = []string{fmt.Sprintf("/%d", i+1)}
+	}
+	server := createTestSite(pages)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := func(visit *PageVisit) bool {
+		if visit.Page.URL.Path == "/2" {
+			cancel()
+		}
+		return true
+	}
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithOnPage(stop), WithContext(ctx))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	// validate
+	// The pages queued when the crawl was cancelled aren't loaded, but can be resumed
+	if !crawler.Truncated() {
+		t.Error("Crawl not marked as truncated")
+	}
+	if len(siteMap.Pages) != 3 {
+		t.Errorf("Incorrect pages crawled: expected 3, got %v", sortedKeys(siteMap.Pages))
+	}
+	if frontier := crawler.Frontier(); len(frontier) != 1 || frontier[0].URL != server.URL+"/3" {
+		t.Errorf("Incorrect frontier: expected %v, got %v", server.URL+"/3", frontier)
+	}
+}
+
+func TestCrawlCancelledInFlight(t *testing.T) {
+
+	// /a stalls until its request is cancelled, with the crawl cancelled once it has been requested
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	site := createTestSite(map[string][]string{"/": {"/a"}})
+	defer site.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/a" {
+			cancel()
+			<-req.Context().Done()
+			return
+		}
+		site.Config.Handler.ServeHTTP(rw, req)
+	}))
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithContext(ctx))
+	done := make(chan error)
+	go func() {
+		done <- crawler.Crawl()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error from crawl: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request in flight not cancelled with the crawl")
+	}
+
+	// the page being loaded is left for a resumed crawl rather than recorded as failing
+	if !crawler.Truncated() || len(crawler.Errors()) != 0 {
+		t.Errorf("Incorrect crawl: expected truncated with no errors, got %v and %v", crawler.Truncated(), crawler.Errors())
+	}
+	if frontier := crawler.Frontier(); len(frontier) != 1 || frontier[0].URL != server.URL+"/a" {
+		t.Errorf("Incorrect frontier: expected %v, got %v", server.URL+"/a", frontier)
+	}
+}
+
+func TestCrawlProgress(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":  {"/a", "/b", "/missing"},
+		"/a": {"/b"},
+		"/b": {},
+	})
+	defer server.Close()
+
+	var snapshots []CrawlProgress
+	progressFunc := func(progress CrawlProgress) {
+		snapshots = append(snapshots, progress)
+	}
+	crawler := createTestCrawler(t, server, WithSink(CreateSiteMap(mustParseURL(t, server.URL))),
+		WithProgress(progressFunc, 10*time.Millisecond))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	// the final snapshot should be complete, with all pages processed
+	if len(snapshots) == 0 {
+		t.Fatal("No progress reported")
+	}
+	final := snapshots[len(snapshots)-1]
+	if !final.Done || final.PagesLoaded != 3 || final.Errors != 1 || final.Queued != 0 || final.InFlight != 0 {
+		t.Errorf("Incorrect final progress: got %+v", final)
+	}
+	if final != crawler.Progress() {
+		t.Errorf("Final progress doesn't match crawler: expected %+v, got %+v", crawler.Progress(), final)
+	}
+}
+
+func TestCreateCrawlerOptions(t *testing.T) {
+
+	start := mustParseURL(t, "http://example.com")
+	crawler, err := CreateCrawler(start, WithWorkers(3), WithThrottle(time.Millisecond), WithMaxPages(10),
+		WithMaxDepth(2), WithClient(&http.Client{Timeout: time.Second}))
+	if err != nil {
+		t.Fatalf("Unexpected error creating crawler: %v", err)
+	}
+	if crawler.numLoaders != 3 || crawler.minLoadDelay != time.Millisecond || crawler.maxPagesToLoad != 10 ||
+		crawler.maxCrawlDepth != 2 {
+		t.Errorf("Options not applied to crawler: got %+v", crawler)
+	}
+	if loader, ok := crawler.docLoader.(*DocLoader); !ok || loader.Client.Timeout != time.Second {
+		t.Errorf("Client not applied to default document loader: got %+v", crawler.docLoader)
+	}
+
+	// invalid values are rejected up front
+	invalid := map[string][]Option{
+		"workers":        {WithWorkers(0)},
+		"throttle":       {WithThrottle(-time.Second)},
+		"pages":          {WithMaxPages(-1)},
+		"depth":          {WithMaxDepth(-1)},
+		"sink":           {WithSink(nil)},
+		"url filter":     {WithURLFilter(nil)},
+		"page callback":  {WithOnPage(nil)},
+		"resume depth":   {WithResume([]FrontierURL{{"http://example.com/a", 0}}, nil)},
+		"trap limits":    {WithTrapLimits(TrapLimits{MaxDateVariants: -1})},
+		"progress":       {WithProgress(func(CrawlProgress) {}, 0)},
+		"client+loader":  {WithClient(&http.Client{}), WithLoader(CreateDocumentLoader(CreateDocumentParser()))},
+		"relative start": nil,
+	}
+	for name, opts := range invalid {
+		URL := start
+		if name == "relative start" {
+			URL = mustParseURL(t, "example.com")
+		}
+		if _, err := CreateCrawler(URL, opts...); err == nil {
+			t.Errorf("Missing expected error for invalid option: %s", name)
+		}
+	}
+}
+
+func TestExtractMetadataOnPage(t *testing.T) {
+	server := createTestSite(map[string][]string{
+		"/":  {"/a"},
+		"/a": {"/fail"},
+	})
+	defer server.Close()
+
+	extractor := ExtractorFunc(func(page *WebPage) (map[string]string, error) {
+		if page.URL.Path == "/a" {
+			return nil, fmt.Errorf("extraction failed")
+		}
+		return map[string]string{"title": page.Title}, nil
+	})
+	logger := &RecordingLogger{}
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithLogger(logger),
+		WithOnPage(ExtractMetadataOnPage(extractor, logger)))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	root := siteMap.Pages[server.URL]
+	if root == nil || root.Metadata["title"] != "Page /" {
+		t.Errorf("Incorrect metadata for root page: %v", root)
+	}
+	page := siteMap.Pages[server.URL+"/a"]
+	if page == nil || page.Metadata != nil {
+		t.Errorf("Incorrect metadata for page with failed extraction: %v", page)
+	}
+}
+
+func TestStableOrderCrawl(t *testing.T) {
+	server := createTestSite(map[string][]string{
+		"/":  {"/e", "/d", "/c", "/b", "/a"},
+		"/a": {"/a/2", "/a/1"},
+		"/b": {"/b/1"},
+		"/c": {"/a/1"},
+	})
+	defer server.Close()
+
+	var expected []string
+	var expectedJSON string
+	for run := 0; run < 3; run++ {
+		var loaded []string
+		record := func(visit *PageVisit) bool {
+			loaded = append(loaded, visit.Page.URL.Path)
+			return true
+		}
+		loader := CreateDocumentLoader(CreateDocumentParser())
+		loader.IgnoreDate = true // the test server sets the Date of each response
+		siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+		crawler := createTestCrawler(t, server, WithSink(siteMap), WithLoader(loader), WithWorkers(5), WithMaxPages(5),
+			WithOnPage(record), WithStableOrder())
+		if run != 0 {
+			time.Sleep(time.Second) // so the Date of each crawl differs
+		}
+		if err := crawler.Crawl(); err != nil {
+			t.Fatalf("Unexpected error from crawl: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := (DocumentRenderer{Format: "json"}).Render(&buf, siteMap); err != nil {
+			t.Fatalf("Failed to render site map: %v", err)
+		}
+		if run == 0 {
+			expected, expectedJSON = loaded, buf.String()
+			if fmt.Sprint(loaded) != "[ /a /b /c]" {
+				t.Errorf("Incorrect load order: expected %v, got %v", "[ /a /b /c]", loaded)
+			}
+		} else if fmt.Sprint(loaded) != fmt.Sprint(expected) || buf.String() != expectedJSON {
+			t.Errorf("Incorrect crawl %d: expected the same pages and output as the first, got %v\n%s", run, loaded, buf.String())
+		}
+	}
+}
+
+func TestCrawlMaxPagesLoaded(t *testing.T) {
+	server := createTestSite(map[string][]string{
+		"/":  {"/gone/1", "/gone/2", "/a", "/gone/3", "/b", "/c", "/gone/4", "/d"},
+		"/a": {"/a/1"},
+		"/b": {"/gone/5"},
+		"/c": {},
+		"/d": {},
+	})
+	defer server.Close()
+
+	// failed loads and discarded pages don't count towards the limit
+	for _, workers := range []int{1, 5} {
+		discard := func(visit *PageVisit) bool { return visit.Page.URL.Path != "/c" }
+		siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+		crawler := createTestCrawler(t, server, WithSink(siteMap), WithWorkers(workers), WithMaxPages(3), WithOnPage(discard))
+		if err := crawler.Crawl(); err != nil {
+			t.Fatalf("Unexpected error from crawl: %v", err)
+		}
+		if len(siteMap.Pages) != 3 {
+			t.Errorf("Incorrect number of pages with %d workers: expected 3, got %v", workers, sortedKeys(siteMap.Pages))
+		}
+		if progress := crawler.Progress(); progress.PagesLoaded-len(siteMap.Pages) > 1 {
+			t.Errorf("Incorrect pages loaded with %d workers: expected at most 4 (one discarded), got %d", workers, progress.PagesLoaded)
+		}
+	}
+}
+
+func TestCrawlMaxPagesPerDepth(t *testing.T) {
+	server := createTestSite(map[string][]string{
+		"/":  {"/a", "/b", "/c", "/d"},
+		"/a": {"/a/1", "/a/2"},
+		"/b": {"/b/1"},
+		"/c": {"/c/1"},
+		"/d": {"/d/1"},
+	})
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithStableOrder(), WithMaxPagesPerDepth(2))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	expected := fmt.Sprint([]string{server.URL, server.URL + "/a", server.URL + "/b"})
+	if got := fmt.Sprint(sortedKeys(siteMap.Pages)); got != expected {
+		t.Errorf("Incorrect pages crawled: expected %v, got %v", expected, got)
+	}
+	var deferred []string
+	for _, u := range crawler.Frontier() {
+		deferred = append(deferred, strings.TrimPrefix(u.URL, server.URL))
+	}
+	sort.Strings(deferred)
+	if expected := "[/b/1 /c /d]"; fmt.Sprint(deferred) != expected {
+		t.Errorf("Incorrect frontier: expected %v, got %v", expected, deferred)
+	}
+	if _, err := CreateCrawler(mustParseURL(t, server.URL), WithMaxPagesPerDepth(-1)); err == nil {
+		t.Errorf("Incorrect result for a negative limit: expected an error")
+	}
+}
+
+func TestEnqueueShorterPath(t *testing.T) {
+
+	// links are sent to the crawler's enqueuing goroutine directly, so the order URLs are found in is fixed
+	start := mustParseURL(t, "https://test.com")
+	crawler, err := CreateCrawler(start, WithMaxDepth(3), WithMaxPagesPerDepth(2))
+	if err != nil {
+		t.Fatalf("Failed to create crawler: %v", err)
+	}
+	links := []Hyperlink{
+		{"https://test.com/deep", 4},   // beyond the maximum depth
+		{"https://test.com/queued", 3}, // queued, waiting to be loaded
+		{"https://test.com/filler", 3}, // queued, filling depth 3
+		{"https://test.com/full", 3},   // over the limit at depth 3
+		{"https://test.com/queued", 2}, // queued again nearer the start
+		{"https://test.com/deep", 3},   // found within the maximum depth
+		{"https://test.com/full", 2},   // found at a depth with room
+		{"https://test.com/queued", 3}, // already queued at a smaller depth
+		{"https://test.com/other", 2},  // over the limit at depth 2
+	}
+	done := make(chan bool)
+	go func() {
+		crawler.enqueueNewUrls()
+		close(done)
+	}()
+	crawler.work.Add(len(links))
+	for _, link := range links {
+		crawler.linksChan <- link
+	}
+	close(crawler.linksChan)
+	<-done
+
+	var queued []string
+	for link, ok := crawler.urlQueue.Pop(); ok; link, ok = crawler.urlQueue.Pop() {
+		if crawler.queuedDepths.Take(link) {
+			queued = append(queued, fmt.Sprintf("%s@%d", strings.TrimPrefix(link.urlStr, start.String()), link.depth))
+		}
+	}
+	if expected := "[/filler@3 /queued@2 /deep@3 /full@2]"; fmt.Sprint(queued) != expected {
+		t.Errorf("Incorrect URLs queued: expected %v, got %v", expected, queued)
+	}
+	if frontier := crawler.Frontier(); len(frontier) != 1 || frontier[0].URL != "https://test.com/other" {
+		t.Errorf("Incorrect frontier: expected only https://test.com/other, got %v", frontier)
+	}
+	if pending := crawler.work.Pending(); pending != 5 {
+		t.Errorf("Incorrect work outstanding: expected the 5 queue entries (one out of date), got %d", pending)
+	}
+}
+
+func TestCrawlByteBudget(t *testing.T) {
+	server := createTestSite(map[string][]string{
+		"/":  {"/a", "/b"},
+		"/a": {"/a/1"},
+		"/b": {},
+	})
+	defer server.Close()
+
+	// the budget is used up by the start page, so the pages it links to aren't loaded
+	loader := CreateDocumentLoader(CreateDocumentParser())
+	loader.MaxBytes = 10
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithLoader(loader), WithStableOrder())
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	if len(siteMap.Pages) != 1 || !crawler.OverBudget() {
+		t.Errorf("Incorrect crawl: expected only the start page over budget, got %v (%v)", sortedKeys(siteMap.Pages), crawler.OverBudget())
+	}
+	if frontier := crawler.Frontier(); len(frontier) != 2 {
+		t.Errorf("Incorrect frontier: expected 2 URLs, got %v", frontier)
+	}
+	if errors := crawler.Errors(); len(errors) != 0 {
+		t.Errorf("Incorrect errors: expected none, got %v", errors)
+	}
+	if read := loader.BytesRead(); read <= 10 {
+		t.Errorf("Incorrect bytes read: expected the start page, got %d", read)
+	}
+}
+
+func TestCrawlParseWorkers(t *testing.T) {
+	server := createTestSite(map[string][]string{
+		"/":      {"/a", "/b", "/c", "/missing"},
+		"/a":     {"/", "/a/1", "/a/2"},
+		"/b":     {"/a/1", "/b/1"},
+		"/c":     {},
+		"/a/1":   {"/a/1/x"},
+		"/a/2":   {},
+		"/b/1":   {},
+		"/a/1/x": {"/"},
+	})
+	defer server.Close()
+
+	expected := CreateSiteMap(mustParseURL(t, server.URL))
+	crawlWithin(t, createTestCrawler(t, server, WithSink(expected)))
+	for _, opts := range [][]Option{{WithParseWorkers(1)}, {WithParseWorkers(3), WithWorkers(2)}} {
+		siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+		crawler := createTestCrawler(t, server, append(opts, WithSink(siteMap))...)
+		crawlWithin(t, crawler)
+		if got := fmt.Sprint(sortedKeys(siteMap.Pages)); got != fmt.Sprint(sortedKeys(expected.Pages)) {
+			t.Errorf("Incorrect pages crawled with %d parse workers: expected %v, got %v", crawler.parseWorkers,
+				sortedKeys(expected.Pages), got)
+		}
+		if errors := crawler.Errors(); len(errors) != 1 || errors[0].StatusCode != 404 {
+			t.Errorf("Incorrect errors with %d parse workers: expected /missing not found, got %v", crawler.parseWorkers, errors)
+		}
+	}
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawlWithin(t, createTestCrawler(t, server, WithSink(siteMap), WithParseWorkers(2), WithMaxPages(4)))
+	if len(siteMap.Pages) != 4 {
+		t.Errorf("Incorrect pages crawled with a page limit: expected 4, got %v", sortedKeys(siteMap.Pages))
+	}
+	if _, err := CreateCrawler(mustParseURL(t, server.URL), WithParseWorkers(-1)); err == nil {
+		t.Errorf("Incorrect result for negative parse workers: expected an error")
+	}
+}
+
+// benchmarkCrawl measures crawling a site of 500 pages (each linking to the home page, 2 child pages and a
+// page elsewhere in the site) with the given crawler options
+func benchmarkCrawl(b *testing.B, opts ...Option) {
+	const pages = 500
+	site := make(map[string][]string)
+	for i := 0; i < pages; i++ {
+		var links []string
+		for _, child := range []int{2*i + 1, 2*i + 2, (i * 7919) % pages} {
+			if child < pages {
+				links = append(links, "/item/"+strconv.Itoa(child))
+			}
+		}
+		site["/item/"+strconv.Itoa(i)] = append(links, "/item/0")
+	}
+	server := createTestSite(site)
+	defer server.Close()
+
+	start, _ := url.Parse(server.URL + "/item/0")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil)) // so logging isn't measured
+	opts = append([]Option{WithThrottle(0), WithMaxPages(0), WithLogger(logger)}, opts...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		siteMap := CreateSiteMap(start)
+		crawler, err := CreateCrawler(start, append(opts, WithSink(siteMap))...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := crawler.Crawl(); err != nil {
+			b.Fatal(err)
+		}
+		if len(siteMap.Pages) != pages {
+			b.Fatalf("Incorrect pages crawled: expected %d, got %d", pages, len(siteMap.Pages))
+		}
+	}
+}
+
+func BenchmarkCrawl(b *testing.B) {
+	benchmarkCrawl(b)
+}
+
+func BenchmarkCrawlParseWorkers(b *testing.B) {
+	benchmarkCrawl(b, WithParseWorkers(4))
+}
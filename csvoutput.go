@@ -0,0 +1,61 @@
+package sitemap
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader is the header row written by WriteCSV
+var csvHeader = []string{"url", "title", "depth", "outlinks", "inlinks", "linked_from", "pagerank", "lastmod", "error", "status"}
+
+// WriteCSV writes the pages in a crawl document as CSV, with a header row then one row per page. The depth
+// is empty for pages not reachable from the starting page, linked_from lists the URLs of the pages linking
+// to each page separated by spaces, and lastmod is when the page was last modified (empty if not known).
+// A row for each URL which failed to load follows, with only the url, inlinks and linked_from set along with
+// the class of error (e.g. status or timeout) and the HTTP status returned (if any), which are empty for pages.
+func WriteCSV(w io.Writer, doc *CrawlDocument) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, record := range doc.Pages {
+		depth := ""
+		if record.Depth != nil {
+			depth = strconv.Itoa(*record.Depth)
+		}
+		lastMod := ""
+		if record.LastModified != nil {
+			lastMod = record.LastModified.Format(time.RFC3339)
+		}
+		row := []string{
+			record.URL,
+			record.Title,
+			depth,
+			strconv.Itoa(len(record.Links)),
+			strconv.Itoa(len(record.Inlinks)),
+			strings.Join(record.Inlinks, " "),
+			strconv.FormatFloat(record.PageRank, 'f', 6, 64),
+			lastMod,
+			"",
+			"",
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	for _, record := range doc.Errors {
+		status := ""
+		if record.Status != 0 {
+			status = strconv.Itoa(record.Status)
+		}
+		row := []string{record.URL, "", "", "", strconv.Itoa(len(record.Referrers)), strings.Join(record.Referrers, " "), "", "", record.Class, status}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,37 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWriteCSV(t *testing.T) {
+	site := createQueryTestSite(t)
+	site.Pages["https://test.com/blog"].LastModified = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	site.Errors = []LoadFailure{{URL: "https://test.com/missing", Class: LoadErrorStatus, StatusCode: 404, Attempts: 1,
+		Referrers: []string{"https://test.com", "https://test.com/blog"}}}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, CreateCrawlDocument(site)); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV written: %v", err)
+	}
+	expected := [][]string{
+		{"url", "title", "depth", "outlinks", "inlinks", "linked_from", "pagerank", "lastmod", "error", "status"},
+		{"https://test.com", "Page ", "0", "2", "0", "", "0.106089", "", "", ""},
+		{"https://test.com/about", "Page /about", "1", "0", "2", "https://test.com https://test.com/blog", "0.215427", "", "", ""},
+		{"https://test.com/blog", "Page /blog", "1", "2", "1", "https://test.com", "0.151177", "2024-03-01T12:30:00Z", "", ""},
+		{"https://test.com/blog/2024", "Page /blog/2024", "2", "1", "1", "https://test.com/blog", "0.170339", "", "", ""},
+		{"https://test.com/blog/2024/post", "Page /blog/2024/post", "3", "0", "1", "https://test.com/blog/2024", "0.250878", "", "", ""},
+		{"https://test.com/orphan", "Page /orphan", "", "0", "0", "", "0.106089", "", "", ""},
+		{"https://test.com/missing", "", "", "", "2", "https://test.com https://test.com/blog", "", "", "status", "404"},
+	}
+	if fmt.Sprint(rows) != fmt.Sprint(expected) {
+		t.Errorf("Incorrect CSV rows: expected %v, got %v", expected, rows)
+	}
+}
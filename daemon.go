@@ -0,0 +1,187 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CrawlFunc crawls the site once, returning the site map of the completed crawl
+type CrawlFunc func() (*SiteMap, error)
+
+// DaemonStatus describes the crawls made by a Daemon, as served by its /status endpoint
+type DaemonStatus struct {
+	Site      string     `json:"site"`
+	Interval  string     `json:"interval"`            // time between the start of each crawl
+	Crawling  bool       `json:"crawling"`            // set while a crawl is in progress
+	Crawls    int        `json:"crawls"`              // number of crawls completed
+	Failures  int        `json:"failures"`            // number of crawls which failed
+	Pages     int        `json:"pages"`               // pages in the latest site map
+	LastCrawl *time.Time `json:"lastCrawl,omitempty"` // when the latest site map was completed
+	Duration  string     `json:"duration,omitempty"`  // time taken by the latest completed crawl
+	LastError string     `json:"lastError,omitempty"` // error from the most recent crawl, if it failed
+	NextCrawl *time.Time `json:"nextCrawl,omitempty"` // when the next crawl is due to start
+	Truncated bool       `json:"truncated"`           // set if the latest crawl was truncated
+}
+
+// Daemon recrawls a site on a schedule, keeping the site map of the latest completed crawl in memory and
+// serving it over HTTP (see Handler). A failed crawl leaves the previous site map in place. SitemapRules must
+// be set before Run is called.
+type Daemon struct {
+	site     string
+	interval time.Duration
+	crawl    CrawlFunc
+	logger   Logger
+
+	// rules setting the changefreq and priority of pages in /sitemap.xml (none if empty)
+	SitemapRules []SitemapRule
+
+	mutex    sync.RWMutex
+	latest   *SiteMap
+	status   DaemonStatus
+	crawling bool
+}
+
+// CreateDaemon creates a Daemon for the site with the supplied root URL, calling crawl every interval
+func CreateDaemon(site string, interval time.Duration, crawl CrawlFunc) (*Daemon, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("daemon interval must be positive, got %v", interval)
+	} else if crawl == nil {
+		return nil, fmt.Errorf("daemon requires a crawl function")
+	}
+	return &Daemon{
+		site:     site,
+		interval: interval,
+		crawl:    crawl,
+		logger:   defaultLogger(),
+		status:   DaemonStatus{Site: site, Interval: interval.String()},
+	}, nil
+}
+
+// Run crawls the site immediately then every interval until the context is cancelled. Crawls never overlap:
+// if a crawl takes longer than the interval the next one starts as soon as it completes.
+func (d *Daemon) Run(ctx context.Context) {
+	for {
+		started := time.Now()
+		d.recrawl()
+		next := started.Add(d.interval)
+		d.mutex.Lock()
+		d.status.NextCrawl = &next
+		d.mutex.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// recrawl crawls the site once, replacing the latest site map if the crawl succeeds
+func (d *Daemon) recrawl() {
+	d.mutex.Lock()
+	d.crawling = true
+	d.status.NextCrawl = nil
+	d.mutex.Unlock()
+
+	start := time.Now()
+	site, err := d.crawl()
+	finished := time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.crawling = false
+	if err != nil {
+		d.status.Failures++
+		d.status.LastError = err.Error()
+		d.logger.Error("Scheduled crawl failed", "url", d.site, "error", err)
+		return
+	}
+	d.latest = site
+	d.status.Crawls++
+	d.status.LastError = ""
+	d.status.LastCrawl = &finished
+	d.status.Duration = finished.Sub(start).Round(time.Millisecond).String()
+	d.logger.Info("Scheduled crawl complete", "url", d.site, "pages", len(site.Pages), "duration", finished.Sub(start))
+}
+
+// Latest returns the site map of the latest completed crawl, or nil if no crawl has completed yet. The
+// site map returned is never changed once a crawl is complete, so may be read concurrently.
+func (d *Daemon) Latest() *SiteMap {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.latest
+}
+
+// Status returns the current status of the daemon's crawls
+func (d *Daemon) Status() DaemonStatus {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	status := d.status
+	status.Crawling = d.crawling
+	if d.latest != nil {
+		status.Pages = len(d.latest.Pages)
+		status.Truncated = d.latest.Truncated
+	}
+	return status
+}
+
+// Handler returns the HTTP handler serving the latest site map:
+//
+//	/sitemap.json	the JSON crawl document (as written with -format json)
+//	/sitemap.xml	a sitemap.xml listing every page
+//	/status			the DaemonStatus as JSON
+//
+// The site map endpoints return 503 Service Unavailable until the first crawl completes.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sitemap.json", d.serveSiteMap(writeSiteMapJSON))
+	mux.HandleFunc("GET /sitemap.xml", d.serveSiteMap(sitemapXMLWriter(d.SitemapRules)))
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(d.Status()); err != nil {
+			d.logger.Debug("Failed to write status", "error", err)
+		}
+	})
+	return mux
+}
+
+// serveSiteMap returns a handler writing the latest site map using write, or 503 if there isn't one yet
+func (d *Daemon) serveSiteMap(write func(w http.ResponseWriter, site *SiteMap) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		site := d.Latest()
+		if site == nil {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "first crawl still in progress", http.StatusServiceUnavailable)
+			return
+		}
+		if status := d.Status(); status.LastCrawl != nil {
+			w.Header().Set("Last-Modified", status.LastCrawl.UTC().Format(http.TimeFormat))
+		}
+		if err := write(w, site); err != nil {
+			d.logger.Debug("Failed to write site map", "error", err)
+		}
+	}
+}
+
+// writeSiteMapJSON writes a site map as a JSON crawl document response
+func writeSiteMapJSON(w http.ResponseWriter, site *SiteMap) error {
+	w.Header().Set("Content-Type", "application/json")
+	return WriteJSON(w, CreateCrawlDocument(site))
+}
+
+// sitemapXMLWriter returns a function writing a sitemap.xml response listing every page of a site map, with
+// the changefreq and priority of the pages set by the rules
+func sitemapXMLWriter(rules []SitemapRule) func(w http.ResponseWriter, site *SiteMap) error {
+	return func(w http.ResponseWriter, site *SiteMap) error {
+		w.Header().Set("Content-Type", "application/xml")
+		return SitemapXMLRenderer{Rules: rules}.Render(w, site)
+	}
+}
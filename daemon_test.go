@@ -0,0 +1,124 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDaemonServesLatestSiteMap(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":  {"/a"},
+		"/a": {"/"},
+	})
+	defer server.Close()
+
+	var mutex sync.Mutex
+	failNext := false
+	crawl := func() (*SiteMap, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if failNext {
+			return nil, errors.New("site unavailable")
+		}
+		site := CreateSiteMap(mustParseURL(t, server.URL))
+		if err := createTestCrawler(t, server, WithSink(site)).Crawl(); err != nil {
+			return nil, err
+		}
+		return site, nil
+	}
+	daemon, err := CreateDaemon(server.URL, time.Hour, crawl)
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	api := httptest.NewServer(daemon.Handler())
+	defer api.Close()
+
+	// nothing is served until the first crawl completes
+	if resp, err := http.Get(api.URL + "/sitemap.json"); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Incorrect status before first crawl: expected %v, got %v", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	daemon.recrawl()
+	resp, err := http.Get(api.URL + "/sitemap.json")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var doc CrawlDocument
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode crawl document: %v", err)
+	}
+	if len(doc.Pages) != 2 {
+		t.Errorf("Incorrect pages served: expected %v, got %v", 2, len(doc.Pages))
+	}
+	if resp, err := http.Get(api.URL + "/sitemap.xml"); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	} else {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), "<loc>"+server.URL+"/a</loc>") {
+			t.Errorf("Incorrect sitemap.xml: expected %v listed, got %s", server.URL+"/a", body)
+		}
+	}
+
+	// a failed crawl keeps the previous site map
+	mutex.Lock()
+	failNext = true
+	mutex.Unlock()
+	daemon.recrawl()
+	if site := daemon.Latest(); site == nil || len(site.Pages) != 2 {
+		t.Errorf("Incorrect site map after failed crawl: expected the previous site map to be kept")
+	}
+	status := daemon.Status()
+	if status.Crawls != 1 || status.Failures != 1 || status.LastError != "site unavailable" || status.Pages != 2 {
+		t.Errorf("Incorrect status: expected 1 crawl, 1 failure and 2 pages, got %+v", status)
+	}
+}
+
+func TestDaemonRun(t *testing.T) {
+
+	crawls := make(chan bool, 10)
+	crawl := func() (*SiteMap, error) {
+		crawls <- true
+		return CreateSiteMap(mustParseURL(t, "http://example.com")), nil
+	}
+	daemon, err := CreateDaemon("http://example.com", 10*time.Millisecond, crawl)
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		daemon.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-crawls:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Incorrect schedule: expected crawl %d within the interval", i+1)
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Incorrect shutdown: expected Run to return once cancelled")
+	}
+
+	if _, err := CreateDaemon("http://example.com", 0, crawl); err == nil {
+		t.Errorf("Incorrect result for zero interval: expected an error, got none")
+	}
+}
@@ -0,0 +1,62 @@
+package sitemap
+
+import (
+	"sort"
+)
+
+// DefaultDeepThreshold is the default click distance beyond which pages are reported as deep by DepthStats.
+// Pages more than 3 clicks from the home page are commonly considered hard for users (and search engines)
+// to find.
+const DefaultDeepThreshold = 3
+
+// DepthStats summarises the click distance (depth) of pages from the starting page of a site map
+type DepthStats struct {
+	Threshold    int      `json:"threshold"`    // depth beyond which pages are reported as deep
+	PagesByDepth []int    `json:"pagesByDepth"` // number of pages at each depth, indexed by depth
+	Reachable    int      `json:"reachable"`    // number of pages reachable by following links from the starting page
+	Unreachable  int      `json:"unreachable"`  // number of pages not reachable from the starting page
+	AverageDepth float64  `json:"averageDepth"` // average depth of the reachable pages
+	MaxDepth     int      `json:"maxDepth"`     // depth of the deepest reachable page
+	LeafPages    int      `json:"leafPages"`    // number of reachable pages with no links to other pages
+	DeepPages    []string `json:"deepPages"`    // reachable pages deeper than the threshold (sorted)
+}
+
+// DepthStats calculates the depth statistics of the site map, where pages deeper than threshold are
+// reported as deep pages
+func (site *SiteMap) DepthStats(threshold int) DepthStats {
+	stats := DepthStats{Threshold: threshold, PagesByDepth: []int{}, DeepPages: []string{}}
+	heights := site.getMinimumHeights()
+	total := 0
+	for key, height := range heights {
+		for len(stats.PagesByDepth) <= height {
+			stats.PagesByDepth = append(stats.PagesByDepth, 0)
+		}
+		stats.PagesByDepth[height]++
+		total += height
+		stats.MaxDepth = max(stats.MaxDepth, height)
+		if height > threshold {
+			stats.DeepPages = append(stats.DeepPages, site.Pages[key].URL.String())
+		}
+		if site.isLeaf(key) {
+			stats.LeafPages++
+		}
+	}
+	stats.Reachable = len(heights)
+	stats.Unreachable = len(site.Pages) - len(heights)
+	if stats.Reachable != 0 {
+		stats.AverageDepth = float64(total) / float64(stats.Reachable)
+	}
+	sort.Strings(stats.DeepPages)
+	return stats
+}
+
+// isLeaf checks if the page stored under key has no links to other pages in the site map
+func (site *SiteMap) isLeaf(key string) bool {
+	for link := range site.Pages[key].InternalLinks {
+		linkKey := site.lookupKey(link)
+		if _, found := site.Pages[linkKey]; found && linkKey != key {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestDepthStats(t *testing.T) {
+	site := createQueryTestSite(t)
+	stats := site.DepthStats(2)
+	expected := "{Threshold:2 PagesByDepth:[1 2 1 1] Reachable:5 Unreachable:1 AverageDepth:1.4 MaxDepth:3 LeafPages:2 " +
+		"DeepPages:[https://test.com/blog/2024/post]}"
+	if got := fmt.Sprintf("%+v", stats); got != expected {
+		t.Errorf("Incorrect depth statistics: expected %s, got %s", expected, got)
+	}
+
+	// an empty site map has no reachable pages
+	stats = CreateSiteMap(mustParseURL(t, "https://test.com")).DepthStats(DefaultDeepThreshold)
+	if stats.Reachable != 0 || stats.AverageDepth != 0 || len(stats.PagesByDepth) != 0 {
+		t.Errorf("Incorrect depth statistics for empty site map: got %+v", stats)
+	}
+}
+
+func TestPrintDepthStats(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintDepthStats(&buf, createQueryTestSite(t).DepthStats(2), nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\n\n ----- Depth statistics -----\n" +
+		" Pages reachable from the starting page: 5 (1 not reachable)\n" +
+		" Average click distance: 1.40, maximum: 3\n" +
+		" Leaf pages (no links to other pages): 2\n" +
+		" Pages at each depth:\n" +
+		"       0: 1\n" +
+		"       1: 2\n" +
+		"       2: 1\n" +
+		"       3: 1\n" +
+		" Pages more than 2 clicks from the starting page (1):\n" +
+		"     https://test.com/blog/2024/post\n"
+	if buf.String() != expected {
+		t.Errorf("Incorrect depth statistics report: expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,272 @@
+package sitemap
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CreateDestination creates the writer the site map (and any reports) are written to. The destination is
+// either a local file name or the URI of an object in cloud storage:
+//
+//	s3://bucket/key		Amazon S3 (or any S3 compatible store), see S3Object
+//	gs://bucket/key		Google Cloud Storage, see GCSObject
+//
+// Objects are buffered in memory and only uploaded when the writer is closed, so the error returned by Close
+// must be checked.
+func CreateDestination(dest string) (io.WriteCloser, error) {
+	scheme, rest, found := strings.Cut(dest, "://")
+	if !found {
+		return os.Create(dest)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if len(bucket) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("invalid destination %s, expected %s://bucket/key", dest, scheme)
+	}
+	switch scheme {
+	case "s3":
+		return CreateS3Object(bucket, key)
+	case "gs":
+		return CreateGCSObject(bucket, key)
+	default:
+		return nil, fmt.Errorf("unsupported destination %s, expected a file name, s3://bucket/key or gs://bucket/key", dest)
+	}
+}
+
+// objectContentType returns the content type an object is uploaded with, from the extension of its key
+func objectContentType(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); len(contentType) != 0 {
+		return contentType
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// uploadObject sends a request uploading an object, returning an error unless it succeeds
+func uploadObject(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload to %s failed with status %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// S3Object writes an object to Amazon S3, uploading it with a single PUT (limited to 5 GB) when closed.
+// Credentials are read from the standard environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// (for temporary credentials) AWS_SESSION_TOKEN, and the region from AWS_REGION or AWS_DEFAULT_REGION
+// (default us-east-1). Setting AWS_ENDPOINT_URL_S3 (or AWS_ENDPOINT_URL) uploads to an S3 compatible store
+// such as MinIO instead, using path style URLs.
+type S3Object struct {
+	bytes.Buffer
+	Bucket       string
+	Key          string
+	Region       string
+	Endpoint     string // endpoint of an S3 compatible store, empty for Amazon S3
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	client       *http.Client
+}
+
+// CreateS3Object creates the writer for an object in S3, configured from the environment
+func CreateS3Object(bucket string, key string) (*S3Object, error) {
+	object := &S3Object{
+		Bucket:       bucket,
+		Key:          key,
+		Region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		Endpoint:     firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: 5 * time.Minute},
+	}
+	if len(object.Region) == 0 {
+		object.Region = "us-east-1"
+	}
+	if len(object.AccessKey) == 0 || len(object.SecretKey) == 0 {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to write to s3://%s/%s", bucket, key)
+	}
+	return object, nil
+}
+
+// URL returns the URL the object is uploaded to
+func (object *S3Object) URL() string {
+	if len(object.Endpoint) != 0 {
+		return strings.TrimSuffix(object.Endpoint, "/") + "/" + object.Bucket + "/" + awsURIEncode(object.Key, false)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", object.Bucket, object.Region, awsURIEncode(object.Key, false))
+}
+
+// Close uploads the object
+func (object *S3Object) Close() error {
+	req, err := http.NewRequest(http.MethodPut, object.URL(), bytes.NewReader(object.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", objectContentType(object.Key))
+	if len(object.SessionToken) != 0 {
+		req.Header.Set("X-Amz-Security-Token", object.SessionToken)
+	}
+	signAWSRequest(req, object.Bytes(), object.AccessKey, object.SecretKey, object.Region, "s3", time.Now())
+	return uploadObject(object.client, req)
+}
+
+// signAWSRequest signs a request with AWS Signature Version 4, setting its Authorization, X-Amz-Date and
+// X-Amz-Content-Sha256 headers. Every header already set on the request is signed, along with the host.
+// See https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func signAWSRequest(req *http.Request, payload []byte, accessKey string, secretKey string, region string, service string, now time.Time) {
+	payloadHash := sha256.Sum256(payload)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	// canonical headers, sorted by lower case name with values trimmed
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// canonical query string, sorted by encoded name then value
+	var params []string
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path, false),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := now.UTC().Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), now.UTC().Format("20060102"))
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode percent-encodes every byte other than the unreserved characters (A-Z, a-z, 0-9, -, ., _
+// and ~), as required by AWS signatures. The / separating path segments is only encoded if encodeSlash is set.
+func awsURIEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+// GCSObject writes an object to Google Cloud Storage, uploading it with a single media upload when closed.
+// An OAuth 2.0 access token is read from GOOGLE_OAUTH_ACCESS_TOKEN (e.g. from gcloud auth print-access-token),
+// and setting STORAGE_EMULATOR_HOST uploads to an emulator instead.
+type GCSObject struct {
+	bytes.Buffer
+	Bucket   string
+	Key      string
+	Endpoint string // base URL of the storage API
+	Token    string // OAuth 2.0 access token, empty for none (e.g. with an emulator)
+	client   *http.Client
+}
+
+// CreateGCSObject creates the writer for an object in Google Cloud Storage, configured from the environment
+func CreateGCSObject(bucket string, key string) (*GCSObject, error) {
+	object := &GCSObject{
+		Bucket:   bucket,
+		Key:      key,
+		Endpoint: "https://storage.googleapis.com",
+		Token:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}
+	if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); len(emulator) != 0 {
+		if !strings.Contains(emulator, "://") {
+			emulator = "http://" + emulator
+		}
+		object.Endpoint = strings.TrimSuffix(emulator, "/")
+	} else if len(object.Token) == 0 {
+		return nil, fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN must be set to write to gs://%s/%s", bucket, key)
+	}
+	return object, nil
+}
+
+// URL returns the URL the object is uploaded to
+func (object *GCSObject) URL() string {
+	return fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", object.Endpoint,
+		url.PathEscape(object.Bucket), url.QueryEscape(object.Key))
+}
+
+// Close uploads the object
+func (object *GCSObject) Close() error {
+	req, err := http.NewRequest(http.MethodPost, object.URL(), bytes.NewReader(object.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", objectContentType(object.Key))
+	if len(object.Token) != 0 {
+		req.Header.Set("Authorization", "Bearer "+object.Token)
+	}
+	return uploadObject(object.client, req)
+}
+
+// firstEnv returns the value of the first of the environment variables which is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); len(value) != 0 {
+			return value
+		}
+	}
+	return ""
+}
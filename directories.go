@@ -0,0 +1,94 @@
+package sitemap
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// directoryListingMarkers are found near the start of the directory listings generated by common web
+// servers (Apache, nginx, IIS, lighttpd and Python's http.server), compared in lower case
+var directoryListingMarkers = []string{"<title>index of /", "<h1>index of /", "directory listing for /", "[to parent directory]"}
+
+// directoryPreviewSize is how much of the response for a directory is read to look for a listing
+const directoryPreviewSize = 4096
+
+// DirectoryCheck is the result of checking a directory of the site for an index page
+type DirectoryCheck struct {
+	URL     string // URL of the directory, ending in /
+	Status  int    // HTTP status of the directory, or AssetFailed if it could not be requested
+	Mapped  bool   // set if the directory's index page was found by crawling (so wasn't requested)
+	Listing bool   // set if the directory returned a listing of its files generated by the web server
+}
+
+// HasIndex checks if the directory has an index page, rather than a listing or an error
+func (check DirectoryCheck) HasIndex() bool {
+	return check.Mapped || (check.Status == http.StatusOK && !check.Listing)
+}
+
+// Failed checks if requesting the directory failed or returned an error status
+func (check DirectoryCheck) Failed() bool {
+	return !check.Mapped && (check.Status == AssetFailed || check.Status >= http.StatusBadRequest)
+}
+
+// siteDirectories returns the URLs of the directories (other than the root) containing the site's pages,
+// found from their paths, sorted. For example a page /blog/2024/post is in the directories /blog/ and
+// /blog/2024/.
+func siteDirectories(site *SiteMap) []string {
+	directories := make(map[string]bool)
+	for _, page := range site.Pages {
+		dir := page.URL.Path
+		for {
+			dir = path.Dir(strings.TrimSuffix(dir, "/"))
+			if dir == "/" || dir == "." {
+				break
+			}
+			dirURL := url.URL{Scheme: page.URL.Scheme, Host: page.URL.Host, Path: dir + "/"}
+			directories[dirURL.String()] = true
+		}
+	}
+	return sortedKeys(directories)
+}
+
+// CheckDirectories checks each directory of the site (see siteDirectories) has an index page, as would be
+// expected of a well structured URL space where users can remove the end of a URL to move up the site.
+// Directories whose index page was crawled aren't requested. The others are requested to find which return
+// an index page which isn't linked to, a listing of their files (which may expose files not meant to be
+// public) or an error. Directories are returned sorted by URL.
+func (loader *DocLoader) CheckDirectories(site *SiteMap) []DirectoryCheck {
+	var checks []DirectoryCheck
+	for _, dirURL := range siteDirectories(site) {
+		if _, found := site.Pages[site.lookupKey(strings.TrimSuffix(dirURL, "/"))]; found {
+			checks = append(checks, DirectoryCheck{URL: dirURL, Status: http.StatusOK, Mapped: true})
+			continue
+		}
+		checks = append(checks, loader.checkDirectory(dirURL))
+	}
+	return checks
+}
+
+// checkDirectory requests a directory, checking whether it returns a listing of its files
+func (loader *DocLoader) checkDirectory(dirURL string) DirectoryCheck {
+	check := DirectoryCheck{URL: dirURL, Status: AssetFailed}
+	resp, err := loader.get(dirURL)
+	if err != nil {
+		loader.logger.Debug("Directory request failed", "url", dirURL, "error", err)
+		return check
+	}
+	defer resp.Body.Close()
+	check.Status = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, directoryPreviewSize))
+		preview = bytes.ToLower(preview)
+		for _, marker := range directoryListingMarkers {
+			if bytes.Contains(preview, []byte(marker)) {
+				check.Listing = true
+				break
+			}
+		}
+	}
+	return check
+}
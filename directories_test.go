@@ -0,0 +1,50 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckDirectories(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/blog/":
+			fmt.Fprint(rw, "<html><head><title>Blog</title></head></html>")
+		case "/docs/api/":
+			fmt.Fprint(rw, "<html><head><title>Index of /docs/api</title></head><body><h1>Index of /docs/api</h1></body></html>")
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer server.Close()
+
+	site := CreateSiteMap(mustParseURL(t, server.URL))
+	for _, path := range []string{"", "/blog/2024/post", "/docs", "/docs/api/v1"} {
+		if _, err := site.AddPage(createWebPage(t, server.URL+path, path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checks := CreateDocumentLoader(CreateDocumentParser()).CheckDirectories(site)
+	expected := fmt.Sprintf("[{%[1]s/blog/ 200 false false} {%[1]s/blog/2024/ 404 false false} "+
+		"{%[1]s/docs/ 200 true false} {%[1]s/docs/api/ 200 false true}]", server.URL)
+	if got := fmt.Sprint(checks); got != expected {
+		t.Errorf("Incorrect directory checks: expected %v, got %v", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDirectoryChecks(&buf, checks, nil); err != nil {
+		t.Fatalf("Failed to print directory checks: %v", err)
+	}
+	expected = "\n\n ----- Directories (4, 2 with index pages) -----\n" +
+		" Directories exposing listings of their files (1):\n" +
+		"     " + server.URL + "/docs/api/\n" +
+		" Directories returning errors (1):\n" +
+		"     " + server.URL + "/blog/2024/ (404)\n"
+	if buf.String() != expected {
+		t.Errorf("Incorrect directory report: expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,137 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDNSCacheTTL is how long the addresses a host name resolves to are cached by default
+const DefaultDNSCacheTTL = 5 * time.Minute
+
+// ResolveOverride fixes the address connections to a host are made to, bypassing DNS (like curl --resolve)
+type ResolveOverride struct {
+	Host string // host name
+	Port string // port the override applies to (empty for every port)
+	IP   string // address connected to
+}
+
+// ParseResolveOverrides parses a comma separated list of overrides, each host:ip or host:port:ip (with an
+// IPv6 address optionally in brackets), e.g. "example.com:203.0.113.7,example.com:443:[2001:db8::7]"
+func ParseResolveOverrides(value string) ([]ResolveOverride, error) {
+	var overrides []ResolveOverride
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); len(entry) == 0 {
+			continue
+		}
+		host, rest, found := strings.Cut(entry, ":")
+		override := ResolveOverride{Host: strings.ToLower(host), IP: strings.Trim(rest, "[]")}
+		if net.ParseIP(override.IP) == nil {
+			port, ip, _ := strings.Cut(rest, ":")
+			override.Port, override.IP = port, strings.Trim(ip, "[]")
+			if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+				found = false
+			}
+		}
+		if !found || len(host) == 0 || net.ParseIP(override.IP) == nil {
+			return nil, fmt.Errorf("invalid resolve override %q, expected host:ip or host:port:ip", entry)
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, nil
+}
+
+// dnsEntry is a cached DNS lookup
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// DNSResolver resolves the host names connections are made to, applying any overrides then caching the
+// results of DNS lookups so a crawl making many connections to the same server (and any asset hosts) only
+// looks each name up once per TTL. Its DialContext method is used by the transport (see CreateTransport).
+type DNSResolver struct {
+	overrides []ResolveOverride
+	ttl       time.Duration // how long lookups are cached for (0 for no caching)
+	dialer    net.Dialer
+
+	// lookup resolves a host name to its addresses (net.DefaultResolver.LookupHost, replaced in tests)
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	mutex sync.Mutex
+	cache map[string]dnsEntry
+}
+
+// CreateDNSResolver creates a resolver applying the overrides and caching lookups for ttl (0 for no caching).
+// Connections are made with the same timeouts as the default HTTP transport.
+func CreateDNSResolver(overrides []ResolveOverride, ttl time.Duration) *DNSResolver {
+	return &DNSResolver{
+		overrides: overrides,
+		ttl:       ttl,
+		dialer:    net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		lookup:    net.DefaultResolver.LookupHost,
+		cache:     make(map[string]dnsEntry),
+	}
+}
+
+// Resolve returns the addresses a connection to host:port is attempted to, in order
+func (resolver *DNSResolver) Resolve(ctx context.Context, host string, port string) ([]string, error) {
+	for _, override := range resolver.overrides {
+		if strings.EqualFold(override.Host, host) && (len(override.Port) == 0 || override.Port == port) {
+			return []string{override.IP}, nil
+		}
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	key := strings.ToLower(host)
+	resolver.mutex.Lock()
+	entry, found := resolver.cache[key]
+	resolver.mutex.Unlock()
+	if found && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+	addrs, err := resolver.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if resolver.ttl > 0 {
+		resolver.mutex.Lock()
+		resolver.cache[key] = dnsEntry{addrs, time.Now().Add(resolver.ttl)}
+		resolver.mutex.Unlock()
+	}
+	return addrs, nil
+}
+
+// DialContext connects to the address (host:port), trying each address the host resolves to in turn
+func (resolver *DNSResolver) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := resolver.Resolve(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := resolver.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, firstErr
+}
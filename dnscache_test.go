@@ -0,0 +1,82 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseResolveOverrides(t *testing.T) {
+
+	overrides, err := ParseResolveOverrides("Example.com:203.0.113.7, example.com:443:[2001:db8::7],test.com:::1")
+	if err != nil {
+		t.Fatalf("Failed to parse overrides: %v", err)
+	}
+	expected := "[{example.com  203.0.113.7} {example.com 443 2001:db8::7} {test.com  ::1}]"
+	if got := fmt.Sprint(overrides); got != expected {
+		t.Errorf("Incorrect overrides: expected %v, got %v", expected, got)
+	}
+	for _, value := range []string{"example.com", "example.com:host", ":1.2.3.4", "example.com:http:1.2.3.4", "example.com:99999:1.2.3.4"} {
+		if _, err := ParseResolveOverrides(value); err == nil {
+			t.Errorf("Incorrect result for %v: expected an error, got none", value)
+		}
+	}
+}
+
+func TestDNSResolverCache(t *testing.T) {
+
+	lookups := 0
+	resolver := CreateDNSResolver([]ResolveOverride{{Host: "staging.test.com", Port: "443", IP: "10.0.0.5"}}, time.Minute)
+	resolver.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return []string{"192.0.2.1", "192.0.2.2"}, nil
+	}
+	for i := 0; i < 3; i++ {
+		addrs, err := resolver.Resolve(context.Background(), "Test.com", "443")
+		if err != nil || fmt.Sprint(addrs) != "[192.0.2.1 192.0.2.2]" {
+			t.Errorf("Incorrect addresses: expected [192.0.2.1 192.0.2.2], got %v (%v)", addrs, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("Incorrect number of lookups: expected %v, got %v", 1, lookups)
+	}
+	if addrs, _ := resolver.Resolve(context.Background(), "staging.test.com", "443"); fmt.Sprint(addrs) != "[10.0.0.5]" {
+		t.Errorf("Incorrect addresses for override: expected [10.0.0.5], got %v", addrs)
+	}
+	if resolver.Resolve(context.Background(), "staging.test.com", "80"); lookups != 2 {
+		t.Errorf("Incorrect lookup for another port: expected the override to be ignored")
+	}
+
+	resolver.ttl = 0
+	resolver.Resolve(context.Background(), "uncached.test.com", "443")
+	resolver.Resolve(context.Background(), "uncached.test.com", "443")
+	if lookups != 4 {
+		t.Errorf("Incorrect number of lookups without caching: expected %v, got %v", 4, lookups)
+	}
+}
+
+func TestDNSResolverOverrideDial(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, req.Host)
+	}))
+	defer server.Close()
+
+	// the site's name doesn't resolve, so can only be reached through the override
+	_, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "http://"), ":")
+	resolver := CreateDNSResolver([]ResolveOverride{{Host: "new-site.invalid", IP: "127.0.0.1"}}, time.Minute)
+	client := &http.Client{Transport: CreateTransport(TransportOptions{Resolver: resolver})}
+	resp, err := client.Get("http://new-site.invalid:" + port + "/")
+	if err != nil {
+		t.Fatalf("Failed to request site through override: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "new-site.invalid:"+port {
+		t.Errorf("Incorrect Host header: expected %v, got %s", "new-site.invalid:"+port, body)
+	}
+}
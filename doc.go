@@ -0,0 +1,86 @@
+// Package sitemap crawls a website, building a site map of its pages and the internal links between them, and
+// writes reports and audits of the site map in a number of formats. It implements the go-sitemap command line
+// application (see cmd/go-sitemap), and can be used as a library to crawl sites from other programs:
+//
+//	start, _ := url.Parse("https://example.com")
+//	site := sitemap.CreateSiteMap(start)
+//	crawler, err := sitemap.CreateCrawler(start, sitemap.WithSink(site), sitemap.WithMaxPages(100))
+//	if err != nil {
+//		return err
+//	}
+//	if err := crawler.Crawl(); err != nil {
+//		return err
+//	}
+//	return sitemap.WriteJSON(os.Stdout, sitemap.CreateCrawlDocument(site))
+//
+// Design Notes:
+//		The package consists of the following main types:
+//			SiteMap 		- stores a sites pages and hyperlinks in a tree structure and iterates over the site map.
+//			DocumentParser	- interface (with DocParser implementation) to convert a HTML document it into a WebPage
+//			DocumentLoader	- interface (with DocLoader implementation) to load URLs then parse the documents returned
+//							  using a supplied DocumentParser, or RenderLoader to parse pages once rendered in headless
+//							  Chrome (with -render js)
+//			Crawler			- Web crawler type used to build the processing pipeline used to crawl the website and
+//							  ingest the loaded WebPage documents into the SiteMap (or any other PageSink, such as
+//							  a PageHandler callback or a channel via CrawlPages)
+//			Logger			- interface used for all (structured) logging from the Crawler and DocLoader. Any slog
+//							  logger can be used, with the default slog logger used by default.
+//			Option			- functional options used to configure (and validate the configuration of) a Crawler
+//							  when it is created with CreateCrawler
+//			CrawlDocument	- versioned JSON output written with -format json. The JSON schema for this is in
+//							  schema/crawl.schema.json, embedded in the binary and printed with -schema
+//			CommandHook		- runs an external command for each crawled page (as an OnPage callback) or once
+//							  crawling is complete, passing it JSON on stdin
+//			CrawlState		- progress of a crawl run over multiple invocations (with -state), saved to a JSON
+//							  file so the next invocation resumes crawling where the last stopped
+//			TrapDetector	- used by the Crawler to detect crawl traps (URL patterns such as calendars or pagination
+//							  which generate URLs without end), skipping URLs once a pattern exceeds its limit
+//			AuditBaseline	- severities and suppressions applied to the findings of the audit checks (with -audit),
+//							  read from a JSON file so a site can adopt the audit incrementally
+//			Catalog			- messages used in reports for a single language, from the catalogs in locales/ which
+//							  are embedded in the binary (selected with -lang)
+//
+// 		The following shows the structure of the processing pipeline. Note this forms a loop which continues until
+//		all pages are crawled, the maximum number of pages are loaded, or we have crawled all pages to the maximum
+//		depth. Numbers in [] indicate number of concurrent goroutines processing
+//
+//   |---> urlLoadChan[1] --> DocumentLoader (plus DocumentParser)[>=1] |-------- pagesChan ----> SiteMap[1]
+//   |                                                                  |---- linksChan ->|
+//	 |	  	                                                                              |
+//   |<-------------------Crawler (URL Filtering & queuing)[1] <--------------------------|
+//
+// The following channels are used
+//		pagesChan:			pages to be ingested into the Site Map
+//		urlLoadChan:		URLs to be loaded by our pool of page loading workers
+//		linksChan:			all internal links read off processed pages
+//		parseChan:			pages downloaded waiting to be parsed, only used with -parse-workers, in which case the
+//							DocumentLoader stage is split into separate download and parse pools joined by this channel
+//
+// In addition, the following are used to monitor progress to detect and signal completion:
+//		WorkTracker:		counts the items queued or being processed across all channels, signalling when
+//							none remain
+//		finishedEventChan:	used to signal that crawling is complete
+//
+// An in-memory queue is used to store the urls waiting to be loaded (inside the Crawler)
+//
+// Versioning and API Stability:
+//		Releases follow semantic versioning (vMAJOR.MINOR.PATCH, reported by "go-sitemap version"). The following
+//		form the stable interface of a release, and are only changed incompatibly in a new major version:
+//			- the command line flags, their defaults and the exit status (e.g. with -fail-on)
+//			- the JSON crawl document, versioned separately by JSONSchemaVersion (see schema/crawl.schema.json),
+//			  the CSV columns (new columns are only ever appended) and each text format version (-text-version)
+//			- the audit check names used in baselines and the message keys of the catalogs in locales/
+//			- the types listed in the Design Notes, along with the exported fields and methods of WebPage,
+//			  SiteMap and Crawler and the DocumentLoader, DocumentParser, SiteMapper, PageSink and Logger
+//			  interfaces
+//		Anything unexported, and any exported identifier not covered above, may change in any release.
+//
+//		Anything to be removed is first deprecated for at least one minor release: flags are marked deprecated
+//		in their usage (with a WARN logged when used) and Go identifiers with a "Deprecated:" doc comment. It is
+//		then only removed in the next major version.
+//
+//		The Go API is this package, github.com/markamb/go-sitemap, with the command line application in
+//		cmd/go-sitemap. From v2 the module path has the major version as a suffix (github.com/markamb/go-sitemap/v2),
+//		as Go requires, so code importing an earlier major version keeps building.
+//
+package sitemap
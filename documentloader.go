@@ -1,10 +1,27 @@
-package main
+package sitemap
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,37 +33,528 @@ type DocumentLoader interface {
 	LoadURL(urlStr string) (*WebPage, error)
 }
 
+// StatusError is returned by LoadURL when the server responds with an unsuccessful status code
+type StatusError struct {
+	URL        string        // URL requested
+	StatusCode int           // HTTP status code returned
+	Status     string        // HTTP status returned
+	RetryAfter time.Duration // how long the server asked for requests to be held off (e.g. with Retry-After), 0 if it didn't
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status code, status code %d (%s) for URL (%v)", e.StatusCode, e.Status, e.URL)
+}
+
+// RedirectLoopError is returned by LoadURL when following the redirects from a URL leads back to a URL
+// already visited, including a URL which redirects to itself
+type RedirectLoopError struct {
+	URL   string   // URL requested
+	Cycle []string // URLs in the cycle, starting and ending with the URL first repeated
+}
+
+func (e *RedirectLoopError) Error() string {
+	return fmt.Sprintf("redirect loop %s for URL (%v)", strings.Join(e.Cycle, " -> "), e.URL)
+}
+
+// AuthRequiredError is returned by LoadURL when a URL redirects to a login page (see DefaultLoginPattern),
+// so the page needs authentication to be crawled
+type AuthRequiredError struct {
+	URL      string // URL requested
+	LoginURL string // URL of the login page redirected to
+}
+
+func (e *AuthRequiredError) Error() string {
+	return fmt.Sprintf("authentication required, redirected to login page %s for URL (%v)", e.LoginURL, e.URL)
+}
+
+// DefaultLoginPattern matches the URLs of typical login pages, either with a login path (e.g. /login or
+// /account/sign-in) or on a login host (e.g. sso.example.com)
+const DefaultLoginPattern = `(?i)(//(login|signin|sso|auth)\.|/(log-?in|sign-?in|sso|auth|cas/login)([/.?]|$))`
+
+// charsetPreviewSize is the number of bytes at the start of a document searched for a <meta> charset
+const charsetPreviewSize = 1024
+
+// maxRedirects is the number of redirects followed before giving up, as for the default http.Client
+const maxRedirects = 10
+
+// PreCheckMode controls what checks are made on a URL before its document is loaded. These are used to
+// avoid downloading large non-HTML resources (PDFs, archives, videos) only to reject them.
+type PreCheckMode int
+
+const (
+	PreCheckNone      PreCheckMode = iota // always load the document
+	PreCheckExtension                     // skip URLs with a file extension known to be a non-HTML resource
+	PreCheckHead                          // as PreCheckExtension, then check the content type with a HEAD request
+)
+
+// ParsePreCheckMode converts a mode name (none, ext or head) into a PreCheckMode
+func ParsePreCheckMode(name string) (PreCheckMode, error) {
+	switch strings.ToLower(name) {
+	case "none":
+		return PreCheckNone, nil
+	case "ext":
+		return PreCheckExtension, nil
+	case "head":
+		return PreCheckHead, nil
+	}
+	return PreCheckNone, fmt.Errorf("unknown pre-check mode %q (expected none, ext or head)", name)
+}
+
+// nonHTMLExtensions is the set of file extensions we assume never contain an HTML document
+var nonHTMLExtensions = map[string]bool{
+	".7z": true, ".avi": true, ".bmp": true, ".css": true, ".csv": true, ".dmg": true, ".doc": true,
+	".docx": true, ".exe": true, ".flac": true, ".gif": true, ".gz": true, ".ico": true, ".iso": true,
+	".jpeg": true, ".jpg": true, ".js": true, ".json": true, ".mkv": true, ".mov": true, ".mp3": true,
+	".mp4": true, ".mpeg": true, ".ogg": true, ".pdf": true, ".png": true, ".ppt": true, ".pptx": true,
+	".rar": true, ".svg": true, ".tar": true, ".tgz": true, ".tif": true, ".tiff": true, ".txt": true,
+	".wav": true, ".webm": true, ".webp": true, ".wmv": true, ".xls": true, ".xlsx": true, ".xml": true,
+	".zip": true,
+}
+
 // DocLoader implements the DocumentLoader interface using HTTP to fetch the document and parses
-// it using the supplied DocumentParser interface.
+// it using the supplied DocumentParser interface. Its exported fields configure the requests made, and must
+// be set before it is used.
 type DocLoader struct {
-	parser DocumentParser // store the interface used to parse pages as they are loaded
+	parser   DocumentParser // store the interface used to parse pages as they are loaded
+	PreCheck PreCheckMode   // checks made before loading a document
+	Client   *http.Client   // client used for all requests (its timeout covers loading and parsing a page)
+	logger   Logger         // logger for load events
+
+	// content types requested (via the Accept header) after loading each page to find which alternate
+	// representations the server provides for it. Empty for no probing.
+	ProbeTypes []string
+
+	// set to request each page a second time, recording the hash of the contents returned so pages whose
+	// responses vary between identical requests can be found
+	Recheck bool
+
+	// set to check that the static assets of each page exist, with the status of each asset cached so it
+	// is only requested once
+	AssetCheck  bool
+	assetStatus map[string]int
+	assetMutex  sync.Mutex
+
+	// set to request the RSS and Atom feeds on the domain declared by each page, following the items in each
+	// as links from the page, with each feed only requested once
+	FeedLinks      bool
+	feedsRequested map[string]bool
+	feedMutex      sync.Mutex
+
+	// how the site responds to a request for a missing page (see ProbeNotFound), with pages matching it
+	// marked as soft 404s. Nil for no soft 404 detection.
+	NotFound *NotFoundSignature
+
+	// pattern matching the URLs of login pages, with URLs redirecting to one reported as requiring
+	// authentication rather than mapped. Nil for no detection.
+	LoginPattern *regexp.Regexp
+
+	// records of the pages from a previous crawl, by URL and alias, which are requested conditionally (see
+	// UsePrevious). Nil for no conditional requests.
+	previous map[string]*PageRecord
+
+	// set to only take when pages were last modified from their Last-Modified header, ignoring the Date of
+	// responses without one, so the modification times recorded don't change each time a site is crawled
+	IgnoreDate bool
+
+	// maximum bytes of page content downloaded (0 for no limit), after which further loads fail with
+	// errByteBudget, and the bytes downloaded so far
+	MaxBytes  int64
+	bytesRead atomic.Int64
 }
 
 // CreateDocumentLoader creates a document loader using the supplied DocumentParser interface
 func CreateDocumentLoader(p DocumentParser) *DocLoader {
-	return &DocLoader{parser: p}
+	return &DocLoader{parser: p, Client: &http.Client{}, logger: defaultLogger(), assetStatus: make(map[string]int)}
+}
+
+// lastModified returns when a response was last modified from its Last-Modified header, falling back to its
+// Date header (unless ignoreDate is set), or the zero time if neither is set to a valid time
+func lastModified(header http.Header, ignoreDate bool) time.Time {
+	names := []string{"Last-Modified", "Date"}
+	if ignoreDate {
+		names = names[:1]
+	}
+	for _, name := range names {
+		if modified, err := http.ParseTime(header.Get(name)); err == nil {
+			return modified.UTC()
+		}
+	}
+	return time.Time{}
 }
 
 // LoadURL loads then parses a web document. See DocumentLoader interface for details.
 func (loader *DocLoader) LoadURL(urlStr string) (*WebPage, error) {
+	return loader.LoadURLContext(context.Background(), urlStr)
+}
+
+// LoadURLContext loads then parses a web document as LoadURL does, as part of the trace in ctx (if any).
+// Fetching and parsing the document are recorded as child spans of the span in ctx, with the trace
+// propagated on the request for the page if the tracer does so (see Tracer).
+func (loader *DocLoader) LoadURLContext(ctx context.Context, urlStr string) (*WebPage, error) {
+	doc, err := loader.FetchContext(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	return loader.Parse(doc)
+}
+
+// FetchedDocument is a web document downloaded by Fetch, waiting to be parsed by Parse
+
+type FetchedDocument struct {
+	urlStr   string          // URL requested
+	finalURL *url.URL        // URL the document was loaded from, once any redirects were followed
+	resp     *http.Response  // response, with its body already read
+	body     []byte          // contents of the document
+	readErr  error           // error reading the whole body (the contents read are still parsed)
+	start    time.Time       // when loading started
+	page     *WebPage        // page unchanged since a previous crawl, which needs no parsing (nil otherwise)
+	ctx      context.Context // trace the document is loaded as part of, which parsing it is added to
+}
+
+// Fetch downloads a web document, checking its status and content type, without parsing it. The document
+// is parsed by Parse, which can be called from another goroutine.
+func (loader *DocLoader) Fetch(urlStr string) (*FetchedDocument, error) {
+	return loader.FetchContext(context.Background(), urlStr)
+}
+
+// FetchContext downloads a web document as Fetch does, as part of the trace in ctx (if any). Parsing the
+// document is added to the same trace.
+func (loader *DocLoader) FetchContext(ctx context.Context, urlStr string) (*FetchedDocument, error) {
+	fetchCtx, end := startSpan(ctx, spanFetch, urlStr)
+	doc, err := loader.fetch(fetchCtx, urlStr)
+	end(err)
+	if doc != nil {
+		doc.ctx = ctx
+	}
+	return doc, err
+}
+
+// fetch downloads a web document for FetchContext, with ctx holding the span tracing the download
+func (loader *DocLoader) fetch(ctx context.Context, urlStr string) (*FetchedDocument, error) {
 	start := time.Now()
-	resp, err := http.Get(urlStr)
+	if loader.MaxBytes > 0 && loader.bytesRead.Load() >= loader.MaxBytes {
+		return nil, fmt.Errorf("%w after %d bytes, not loading URL (%v)", errByteBudget, loader.bytesRead.Load(), urlStr)
+	}
+	if err := loader.checkURL(urlStr); err != nil {
+		return nil, err
+	}
+	record := loader.previous[urlStr]
+	resp, err := loader.getWithHeader(ctx, urlStr, conditionalHeader(record))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
-		return nil, fmt.Errorf("unsupported content type %v for URL (%v)", contentType, urlStr)
+	if resp.StatusCode == http.StatusNotModified && record != nil {
+		page, err := unchangedPage(record, resp)
+		if err != nil {
+			return nil, err
+		}
+		loader.logger.Info("Page not modified since previous crawl", "event", eventFetchFinish, "url", urlStr, "duration", time.Since(start))
+		return &FetchedDocument{urlStr: urlStr, resp: resp, start: start, page: page}, nil
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status code, status code %d (%s) for URL (%v)", resp.StatusCode, resp.Status, urlStr)
+		return nil, &StatusError{URL: urlStr, StatusCode: resp.StatusCode, Status: resp.Status,
+			RetryAfter: retryAfter(resp.Header, resp.StatusCode, time.Now())}
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		return nil, fmt.Errorf("%w %v for URL (%v)", errUnsupportedType, contentType, urlStr)
+	}
+
+	// if we were redirected, the page is parsed using the URL we ended up at with the requested URL
+	// recorded as an alias. Pages redirected off the site are never mapped.
+	finalURL := resp.Request.URL
+	redirected := finalURL.String() != urlStr
+	if redirected && loader.LoginPattern != nil && loader.LoginPattern.MatchString(finalURL.String()) {
+		return nil, &AuthRequiredError{URL: urlStr, LoginURL: finalURL.String()}
+	}
+	if requestURL, err := url.Parse(urlStr); redirected && err == nil && !sameHost(finalURL.Host, requestURL.Host) {
+		return nil, fmt.Errorf("%w (%v) for URL (%v)", errOffsiteRedirect, finalURL, urlStr)
+	}
+	body, readErr := io.ReadAll(&countingReader{resp.Body, &loader.bytesRead})
+	doc := &FetchedDocument{urlStr, finalURL, resp, body, readErr, start, nil, nil}
+	if loader.requestsForPage() {
+		// the further requests depend on the page's contents, so it's parsed here, leaving the parse stage
+		// free of network I/O (which would otherwise escape the crawler's limits on concurrent requests)
+		page, err := loader.parse(doc)
+		if err != nil {
+			return nil, err
+		}
+		loader.requestForPage(ctx, page, finalURL.String())
+		doc.page = page
+	}
+	return doc, nil
+}
+
+// requestsForPage returns true if the loader makes further requests for each page it loads (e.g. to check
+// its assets), in which case pages are parsed by Fetch rather than Parse
+func (loader *DocLoader) requestsForPage() bool {
+	return len(loader.ProbeTypes) != 0 || loader.Recheck || loader.AssetCheck || loader.FeedLinks
+}
+
+// requestForPage makes the further requests for a page loaded from urlStr, adding the results to the page
+func (loader *DocLoader) requestForPage(ctx context.Context, page *WebPage, urlStr string) {
+	if page == nil {
+		return
+	}
+	if len(loader.ProbeTypes) != 0 {
+		page.Alternates = loader.probeAlternates(ctx, urlStr)
+	}
+	if loader.Recheck && len(page.ContentHash) != 0 {
+		page.RecheckHash = loader.recheckHash(ctx, urlStr)
+	}
+	if loader.AssetCheck {
+		loader.checkAssets(page)
+	}
+	if loader.FeedLinks {
+		loader.addFeedLinks(ctx, page)
 	}
-	page, err := loader.parser.ParseDocument(urlStr, resp.Body)
+}
+
+// Parse parses a document downloaded by Fetch. Parse makes no requests: pages needing further requests
+// (e.g. to check their assets) are parsed by Fetch, which makes the requests, so Parse only returns them.
+func (loader *DocLoader) Parse(doc *FetchedDocument) (*WebPage, error) {
+	ctx := doc.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, end := startSpan(ctx, spanParse, doc.urlStr)
+	page, err := loader.parse(doc)
+	end(err)
+	return page, err
+}
+
+// parse parses a document for Parse, or for fetch when further requests are made for the page
+func (loader *DocLoader) parse(doc *FetchedDocument) (*WebPage, error) {
+	if doc.page != nil {
+		return doc.page, nil
+	}
+	urlStr, finalURL, resp := doc.urlStr, doc.finalURL, doc.resp
+	page, err := loader.parser.ParseDocument(finalURL.String(), decodeContent(bytes.NewReader(doc.body), resp.Header.Get("Content-Type")))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse contents for URL %s :%v", urlStr, err)
 	}
+	if doc.readErr == nil && page != nil {
+		hash := sha256.Sum256(doc.body)
+		page.ContentHash = hex.EncodeToString(hash[:])
+	}
+	if page != nil {
+		page.StatusCode = resp.StatusCode
+		page.Header = resp.Header
+		page.Protocol, page.TLSVersion = responseProtocol(resp)
+		page.LastModified = lastModified(resp.Header, loader.IgnoreDate)
+		page.ETag = resp.Header.Get("ETag")
+		page.Soft404 = loader.NotFound != nil && loader.NotFound.Matches(page)
+	}
+	if finalURL.String() != urlStr && page != nil && page.URL != nil && page.URL.String() != urlStr && page.Aliases != nil {
+		page.Aliases[urlStr] = true
+	}
 
-	loadSecs := time.Since(start).Seconds()
-	log.Printf("INFO: Loaded and parsed %s in %f secs", urlStr, loadSecs)
+	loader.logger.Info("Loaded and parsed page", "event", eventFetchFinish, "url", urlStr, "status", resp.StatusCode, "duration", time.Since(doc.start))
 	return page, nil
 }
+
+// BytesRead returns the bytes of page content downloaded so far
+func (loader *DocLoader) BytesRead() int64 {
+	return loader.bytesRead.Load()
+}
+
+// countingReader adds the number of bytes read from a reader to a counter
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+// decodeContent returns a reader converting an HTML document to UTF-8, using the charset from the
+// Content-Type header or a <meta> tag near the start of the document (as a browser would)
+func decodeContent(body io.Reader, contentType string) io.Reader {
+	reader := bufio.NewReaderSize(body, charsetPreviewSize)
+	preview, _ := reader.Peek(charsetPreviewSize)
+	if encoding, name, _ := charset.DetermineEncoding(preview, contentType); name != "utf-8" {
+		return encoding.NewDecoder().Reader(reader)
+	}
+	return reader
+}
+
+// get requests a URL, detecting redirect loops. The loader's client is used, with any redirect policy it
+// has applied once no loop is found.
+func (loader *DocLoader) get(urlStr string) (*http.Response, error) {
+	return loader.getWithHeader(context.Background(), urlStr, nil)
+}
+
+// getWithHeader requests a URL as get does, adding the supplied headers (if any) to the request along with
+// the headers propagating the trace in ctx (see Tracer.Inject)
+func (loader *DocLoader) getWithHeader(ctx context.Context, urlStr string, header http.Header) (*http.Response, error) {
+	client := *loader.Client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		next := req.URL.String()
+		for i, previous := range via {
+			if previous.URL.String() == next {
+				cycle := make([]string, 0, len(via)-i+1)
+				for _, step := range via[i:] {
+					cycle = append(cycle, step.URL.String())
+				}
+				return &RedirectLoopError{URL: urlStr, Cycle: append(cycle, next)}
+			}
+		}
+		if loader.Client.CheckRedirect != nil {
+			return loader.Client.CheckRedirect(req, via)
+		} else if len(via) >= maxRedirects {
+			return errTooManyRedirects
+		}
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	injectTrace(ctx, req.Header)
+	resp, err := client.Do(req)
+	var loopErr *RedirectLoopError
+	if errors.As(err, &loopErr) {
+		return nil, loopErr
+	} else if err != nil {
+		return nil, err
+	}
+	if err := decodeBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%v for URL (%v)", err, urlStr)
+	}
+	return resp, nil
+}
+
+// acceptEncoding is the Accept-Encoding header sent when loading pages. As this is set explicitly, net/http
+// doesn't decompress responses itself, so every encoding listed is decoded by decodeBody.
+const acceptEncoding = "gzip, deflate, br"
+
+// decodeBody replaces the body of a response with a reader decompressing it using the Content-Encoding of
+// the response. Compressed responses are decoded even if they weren't requested, as some servers compress
+// regardless of the Accept-Encoding header.
+func decodeBody(resp *http.Response) error {
+	encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+	body := resp.Body
+	var reader io.Reader = body
+
+	// encodings are listed in the order applied, so are removed in reverse order
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encoding := strings.ToLower(strings.TrimSpace(encodings[i])); encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			gzipReader, err := gzip.NewReader(reader)
+			if err != nil {
+				return fmt.Errorf("invalid gzip content: %v", err)
+			}
+			reader = gzipReader
+		case "deflate":
+			reader = newDeflateReader(reader)
+		case "br":
+			reader = brotli.NewReader(reader)
+		default:
+			return fmt.Errorf("unsupported content encoding %v", encoding)
+		}
+	}
+	if reader != io.Reader(body) {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{reader, body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	return nil
+}
+
+// newDeflateReader returns a reader decompressing deflate content. This should be zlib wrapped, but some
+// servers send raw deflate data so that is accepted too.
+func newDeflateReader(r io.Reader) io.Reader {
+	buffered := bufio.NewReader(r)
+	if header, err := buffered.Peek(2); err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+		if zlibReader, err := zlib.NewReader(buffered); err == nil {
+			return zlibReader
+		}
+	}
+	return flate.NewReader(buffered)
+}
+
+// checkURL applies the configured pre-checks to a URL before it is loaded, returning an error if the
+// URL should not be loaded
+func (loader *DocLoader) checkURL(urlStr string) error {
+	if loader.PreCheck == PreCheckNone {
+		return nil
+	}
+
+	// first the (cheap) file extension check
+	if parsedURL, err := url.Parse(urlStr); err == nil {
+		if ext := strings.ToLower(path.Ext(parsedURL.Path)); nonHTMLExtensions[ext] {
+			return fmt.Errorf("%w %v for URL (%v)", errUnsupportedExtension, ext, urlStr)
+		}
+	}
+	if loader.PreCheck != PreCheckHead {
+		return nil
+	}
+
+	// then ask the server for the content type. Some servers don't support HEAD requests (or don't return
+	// a content type for them) so we only reject the URL if we're given a content type which isn't HTML
+	resp, err := loader.Client.Head(urlStr)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	if contentType := resp.Header.Get("Content-Type"); len(contentType) != 0 && !strings.HasPrefix(contentType, "text/html") {
+		return fmt.Errorf("%w %v for URL (%v)", errUnsupportedType, contentType, urlStr)
+	}
+	return nil
+}
+
+// recheckHash requests the URL again, returning the hash of the contents (empty if the request fails)
+func (loader *DocLoader) recheckHash(ctx context.Context, urlStr string) string {
+	resp, err := loader.getWithHeader(ctx, urlStr, nil)
+	if err != nil {
+		loader.logger.Debug("Recheck request failed", "url", urlStr, "error", err)
+		return ""
+	}
+	defer resp.Body.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil || resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// probeAlternates requests the URL with each of the probe content types in the Accept header, returning
+// those the server responds with (in the order probed)
+func (loader *DocLoader) probeAlternates(ctx context.Context, urlStr string) []string {
+	var alternates []string
+	for _, probeType := range loader.ProbeTypes {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+		if err != nil {
+			return alternates
+		}
+		req.Header.Set("Accept", probeType)
+		resp, err := loader.Client.Do(req)
+		if err != nil {
+			loader.logger.Debug("Content negotiation probe failed", "url", urlStr, "type", probeType, "error", err)
+			continue
+		}
+		resp.Body.Close() // we only need the headers
+		mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err == nil && resp.StatusCode == http.StatusOK && strings.EqualFold(mediaType, probeType) {
+			alternates = append(alternates, probeType)
+		}
+	}
+	return alternates
+}
@@ -1,11 +1,23 @@
-package main
+package sitemap
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/brotli"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 )
 
 //
@@ -29,6 +41,36 @@ func (m *MockParser) ParseDocument(urlStr string, reader io.Reader) (*WebPage, e
 	return m.result, m.err
 }
 
+//
+// Create mock logger
+//
+type RecordingLogger struct {
+	mutex   sync.Mutex
+	entries []LogEntry // everything logged, in order
+}
+
+// LogEntry is a single message recorded by the RecordingLogger
+type LogEntry struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+func (l *RecordingLogger) record(level string, msg string, args []any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fields := make(map[string]any)
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.entries = append(l.entries, LogEntry{level, msg, fields})
+}
+
+func (l *RecordingLogger) Debug(msg string, args ...any) { l.record("DEBUG", msg, args) }
+func (l *RecordingLogger) Info(msg string, args ...any)  { l.record("INFO", msg, args) }
+func (l *RecordingLogger) Warn(msg string, args ...any)  { l.record("WARN", msg, args) }
+func (l *RecordingLogger) Error(msg string, args ...any) { l.record("ERROR", msg, args) }
+
 func TestDocumentLoader(t *testing.T) {
 
 	doc := "My Test Document Contents"
@@ -68,6 +110,9 @@ func TestDocumentLoader(t *testing.T) {
 	if page != mockParser.result {
 		t.Errorf("Incorrect result from LoadURL: expected %v, got %v", mockParser.result, page)
 	}
+	if hash := sha256.Sum256([]byte(doc)); page.ContentHash != hex.EncodeToString(hash[:]) {
+		t.Errorf("Incorrect content hash: expected %x, got %s", hash, page.ContentHash)
+	}
 }
 
 func TestDocumentLoaderBadContentType(t *testing.T) {
@@ -128,4 +173,448 @@ func TestDocumentLoaderBadResponseCode(t *testing.T) {
 	if err == nil {
 		t.Error("Missing expected error from LoadURL")
 	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Incorrect error from LoadURL: expected status %d, got %v", http.StatusNotFound, err)
+	}
+}
+
+func TestDocumentLoaderHeadPreCheck(t *testing.T) {
+	gets := 0
+
+	// mock server request handler - HEAD requests report a PDF document
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			gets++
+		}
+		rw.Header().Add("Content-Type", "application/pdf")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	mockParser := &MockParser{}
+	docLoader := CreateDocumentLoader(mockParser)
+	docLoader.PreCheck = PreCheckHead
+	page, err := docLoader.LoadURL(mockServer.URL + "/document")
+
+	// validate
+	// Unsupported content type reported by HEAD - document should never have been requested
+	if gets != 0 {
+		t.Errorf("Incorrect number of GET requests to mock server: expected %d, got %d", 0, gets)
+	}
+	if mockParser.calls != 0 {
+		t.Errorf("Incorrect number of calls to mock parser: expected %d, got %d", 0, mockParser.calls)
+	}
+	if page != nil {
+		t.Errorf("Incorrect result from LoadURL: expected %v, got %v", nil, page)
+	}
+	if err == nil {
+		t.Error("Missing expected error from LoadURL")
+	}
+}
+
+func TestDocumentLoaderExtensionPreCheck(t *testing.T) {
+	requests := 0
+
+	// mock server request handler
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	mockParser := &MockParser{result: &WebPage{Title: "My Web Page Title"}}
+	docLoader := CreateDocumentLoader(mockParser)
+	docLoader.PreCheck = PreCheckExtension
+
+	// a known media extension is rejected without contacting the server
+	if page, err := docLoader.LoadURL(mockServer.URL + "/videos/movie.MP4"); page != nil || err == nil {
+		t.Errorf("Incorrect result from LoadURL: expected (nil, error), got (%v, %v)", page, err)
+	}
+	if requests != 0 {
+		t.Errorf("Incorrect number of requests to mock server: expected %d, got %d", 0, requests)
+	}
+
+	// anything else is loaded as normal
+	if page, err := docLoader.LoadURL(mockServer.URL + "/pages/index.html"); page != mockParser.result || err != nil {
+		t.Errorf("Incorrect result from LoadURL: expected (%v, nil), got (%v, %v)", mockParser.result, page, err)
+	}
+	if requests != 1 {
+		t.Errorf("Incorrect number of requests to mock server: expected %d, got %d", 1, requests)
+	}
+}
+
+func TestDocumentLoaderRedirect(t *testing.T) {
+
+	// mock server request handler - redirect /old to /new
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/old" {
+			http.Redirect(rw, req, "/new", http.StatusMovedPermanently)
+			return
+		}
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("<HTML></HTML>"))
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	page, err := docLoader.LoadURL(mockServer.URL + "/old")
+
+	// validate
+	// The page should be mapped at the URL we were redirected to, with the original as an alias
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if page.URL.String() != mockServer.URL+"/new" {
+		t.Errorf("Incorrect page URL: expected %s, got %s", mockServer.URL+"/new", page.URL)
+	}
+	if len(page.Aliases) != 1 || !page.Aliases[mockServer.URL+"/old"] {
+		t.Errorf("Incorrect page aliases: expected [%s], got %v", mockServer.URL+"/old", page.Aliases)
+	}
+}
+
+func TestDocumentLoaderRedirectLoop(t *testing.T) {
+
+	// mock server request handler - /self redirects to itself, /a -> /b -> /c -> /b, /long redirects 20 times
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/self":
+			http.Redirect(rw, req, "/self", http.StatusFound)
+		case "/a":
+			http.Redirect(rw, req, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(rw, req, "/c", http.StatusMovedPermanently)
+		case "/c":
+			http.Redirect(rw, req, "/b", http.StatusMovedPermanently)
+		default:
+			var n int
+			if _, err := fmt.Sscanf(req.URL.Path, "/long/%d", &n); err == nil && n < 20 {
+				http.Redirect(rw, req, fmt.Sprintf("/long/%d", n+1), http.StatusFound)
+				return
+			}
+			http.NotFound(rw, req)
+		}
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+
+	tests := map[string][]string{
+		"/self": {"/self", "/self"},
+		"/a":    {"/b", "/c", "/b"},
+	}
+	for path, cycle := range tests {
+		_, err := docLoader.LoadURL(mockServer.URL + path)
+		var loopErr *RedirectLoopError
+		if !errors.As(err, &loopErr) {
+			t.Fatalf("Incorrect error for %s: expected redirect loop, got %v", path, err)
+		}
+		var expected []string
+		for _, step := range cycle {
+			expected = append(expected, mockServer.URL+step)
+		}
+		if loopErr.URL != mockServer.URL+path || fmt.Sprint(loopErr.Cycle) != fmt.Sprint(expected) {
+			t.Errorf("Incorrect redirect loop for %s: expected %v, got %v", path, expected, loopErr.Cycle)
+		}
+	}
+
+	// long chains without a loop still stop at the redirect limit
+	var loopErr *RedirectLoopError
+	if _, err := docLoader.LoadURL(mockServer.URL + "/long/0"); err == nil || errors.As(err, &loopErr) {
+		t.Errorf("Incorrect error for long redirect chain: expected redirect limit, got %v", err)
+	}
+}
+
+func TestDocumentLoaderLoginRedirect(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/account":
+			http.Redirect(rw, req, "/users/sign-in?return=/account", http.StatusFound)
+		case "/moved":
+			http.Redirect(rw, req, "/blog/login-tips", http.StatusMovedPermanently)
+		default:
+			rw.Header().Add("Content-Type", "text/html")
+			fmt.Fprint(rw, "<HTML><BODY>Page</BODY></HTML>")
+		}
+	}))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	docLoader.LoginPattern = regexp.MustCompile(DefaultLoginPattern)
+	_, err := docLoader.LoadURL(mockServer.URL + "/account")
+	var authErr *AuthRequiredError
+	if !errors.As(err, &authErr) || authErr.LoginURL != mockServer.URL+"/users/sign-in?return=/account" {
+		t.Errorf("Incorrect error for login redirect: expected authentication required, got %v", err)
+	}
+
+	// only redirects are checked, and the pattern must match a whole path segment
+	for _, path := range []string{"/users/sign-in", "/moved"} {
+		if _, err := docLoader.LoadURL(mockServer.URL + path); err != nil {
+			t.Errorf("Unexpected error loading %s: %v", path, err)
+		}
+	}
+
+	// detection can be disabled
+	docLoader.LoginPattern = nil
+	if _, err := docLoader.LoadURL(mockServer.URL + "/account"); err != nil {
+		t.Errorf("Unexpected error with no login pattern: %v", err)
+	}
+}
+
+func TestDocumentLoaderLogger(t *testing.T) {
+
+	// mock server request handler
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	logger := &RecordingLogger{}
+	docLoader := CreateDocumentLoader(&MockParser{result: &WebPage{}})
+	docLoader.logger = logger
+	URL := mockServer.URL + "/path"
+	if _, err := docLoader.LoadURL(URL); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// validate
+	// A single structured message should be logged for the load
+	if len(logger.entries) != 1 {
+		t.Fatalf("Incorrect number of log entries: expected %d, got %d", 1, len(logger.entries))
+	}
+	entry := logger.entries[0]
+	if entry.level != "INFO" || entry.fields["url"] != URL || entry.fields["status"] != http.StatusOK {
+		t.Errorf("Incorrect log entry: got %v", entry)
+	}
+	if _, found := entry.fields["duration"]; !found {
+		t.Errorf("Missing duration field in log entry: got %v", entry)
+	}
+}
+
+func TestDocumentLoaderProbeAlternates(t *testing.T) {
+
+	// mock server request handler - supports JSON as well as HTML
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") == "application/json" {
+			rw.Header().Add("Content-Type", "application/json; charset=utf-8")
+		} else {
+			rw.Header().Add("Content-Type", "text/html")
+		}
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	docLoader.ProbeTypes = []string{"application/xml", "application/json"}
+	page, err := docLoader.LoadURL(mockServer.URL + "/api")
+
+	// validate
+	// Only the JSON representation is available
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(page.Alternates) != 1 || page.Alternates[0] != "application/json" {
+		t.Errorf("Incorrect alternates: expected [application/json], got %v", page.Alternates)
+	}
+}
+
+func TestDocumentLoaderRecheck(t *testing.T) {
+
+	// mock server request handler - the contents of /random change on every request
+	requests := 0
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		if req.URL.Path == "/random" {
+			fmt.Fprintf(rw, "<HTML><BODY>Request %d</BODY></HTML>", requests)
+		} else {
+			fmt.Fprint(rw, "<HTML><BODY>Fixed</BODY></HTML>")
+		}
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	docLoader.Recheck = true
+	for path, changed := range map[string]bool{"/random": true, "/fixed": false} {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(page.RecheckHash) == 0 || (page.RecheckHash != page.ContentHash) != changed {
+			t.Errorf("Incorrect recheck hash for %s: expected changed %v, got %s (content %s)", path, changed,
+				page.RecheckHash, page.ContentHash)
+		}
+	}
+}
+
+func TestDocumentLoaderLastModified(t *testing.T) {
+
+	// mock server request handler - only /modified sets a Last-Modified header
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Content-Type", "text/html")
+		rw.Header().Set("Date", "Fri, 01 Mar 2024 12:30:00 GMT")
+		if req.URL.Path == "/modified" {
+			rw.Header().Set("Last-Modified", "Sun, 05 Nov 2023 08:00:00 GMT")
+		}
+		fmt.Fprint(rw, "<HTML><BODY>Page</BODY></HTML>")
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	for path, expected := range map[string]string{"/modified": "2023-11-05T08:00:00Z", "/dated": "2024-03-01T12:30:00Z"} {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := page.LastModified.Format(time.RFC3339); got != expected {
+			t.Errorf("Incorrect last modified time for %s: expected %s, got %s", path, expected, got)
+		}
+	}
+}
+
+func TestDocumentLoaderCharset(t *testing.T) {
+
+	// mock server request handler - pages in ISO-8859-1 (declared in the Content-Type) and Shift-JIS
+	// (declared in a meta tag)
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/latin1":
+			rw.Header().Add("Content-Type", "text/html; charset=ISO-8859-1")
+			fmt.Fprint(rw, "<HTML><HEAD><TITLE>Caf\xe9 cr\xe8me</TITLE></HEAD></HTML>")
+		case "/sjis":
+			rw.Header().Add("Content-Type", "text/html")
+			fmt.Fprint(rw, `<HTML><HEAD><META charset="Shift_JIS"><TITLE>`+"\x93\xfa\x96\x7b"+`</TITLE></HEAD></HTML>`)
+		default:
+			rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(rw, "<HTML><HEAD><TITLE>Café</TITLE></HEAD></HTML>")
+		}
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	for path, expected := range map[string]string{"/latin1": "Café crème", "/sjis": "日本", "/utf8": "Café"} {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error loading %s: %v", path, err)
+		}
+		if page.Title != expected {
+			t.Errorf("Incorrect title for %s: expected %q, got %q", path, expected, page.Title)
+		}
+	}
+}
+
+func TestDocumentLoaderCompression(t *testing.T) {
+
+	// mock server request handler - the page is compressed with the encoding in its path, whatever
+	// encodings were requested
+	const content = "<HTML><HEAD><TITLE>Compressed</TITLE></HEAD></HTML>"
+	var acceptEncodings []string
+	var mutex sync.Mutex
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		acceptEncodings = append(acceptEncodings, req.Header.Get("Accept-Encoding"))
+		mutex.Unlock()
+		var buf bytes.Buffer
+		var writer io.WriteCloser
+		encoding := req.URL.Path[1:]
+		switch encoding {
+		case "gzip":
+			writer = gzip.NewWriter(&buf)
+		case "deflate":
+			writer = zlib.NewWriter(&buf)
+		case "raw-deflate":
+			writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+			encoding = "deflate"
+		case "br":
+			writer = brotli.NewWriter(&buf)
+		default:
+			buf.WriteString(content)
+		}
+		if writer != nil {
+			io.WriteString(writer, content)
+			writer.Close()
+		}
+		rw.Header().Add("Content-Type", "text/html")
+		if encoding != "identity" {
+			rw.Header().Add("Content-Encoding", encoding)
+		}
+		rw.Write(buf.Bytes())
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	expectedHash := sha256.Sum256([]byte(content))
+	for _, encoding := range []string{"identity", "gzip", "deflate", "raw-deflate", "br"} {
+		page, err := docLoader.LoadURL(mockServer.URL + "/" + encoding)
+		if err != nil {
+			t.Fatalf("Unexpected error loading %s content: %v", encoding, err)
+		}
+		if page.Title != "Compressed" || page.ContentHash != hex.EncodeToString(expectedHash[:]) {
+			t.Errorf("Incorrect page for %s content: got title %q, hash %s", encoding, page.Title, page.ContentHash)
+		}
+	}
+	for _, accept := range acceptEncodings {
+		if accept != acceptEncoding {
+			t.Errorf("Incorrect Accept-Encoding: expected %s, got %s", acceptEncoding, accept)
+		}
+	}
+
+	// unsupported encodings are rejected
+	if _, err := docLoader.LoadURL(mockServer.URL + "/compress"); err == nil {
+		t.Errorf("Missing expected error for unsupported content encoding")
+	}
+}
+
+func TestDocumentLoaderFetchAndParse(t *testing.T) {
+
+	doc := "My Test Document Contents"
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte(doc))
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	mockParser := &MockParser{result: &WebPage{Title: "My Web Page Title"}}
+	docLoader := CreateDocumentLoader(mockParser)
+	fetched, err := docLoader.Fetch(mockServer.URL + "/page")
+	if err != nil {
+		t.Fatalf("Unexpected error from Fetch: %v", err)
+	}
+	if mockParser.calls != 0 {
+		t.Errorf("Incorrect number of calls to mock parser after Fetch: expected 0, got %d", mockParser.calls)
+	}
+	page, err := docLoader.Parse(fetched)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	if mockParser.calls != 1 || mockParser.recievedDoc != doc {
+		t.Errorf("Incorrect document parsed: expected %s, got %s (%d calls)", doc, mockParser.recievedDoc, mockParser.calls)
+	}
+	if page != mockParser.result || page.StatusCode != http.StatusOK {
+		t.Errorf("Incorrect result from Parse: expected %v with status 200, got %v", mockParser.result, page)
+	}
 }
@@ -1,8 +1,9 @@
-package main
+package sitemap
 
 import (
 	"fmt"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 	"io"
 	"net/url"
 	"strings"
@@ -17,8 +18,36 @@ type DocumentParser interface {
 	ParseDocument(urlStr string, reader io.Reader) (*WebPage, error)
 }
 
-// DocParser type implements the DocumentParser interface
+// DocParser type implements the DocumentParser interface. Its exported fields select what is recorded for each
+// page, and must be set before it is used.
 type DocParser struct {
+	Query    QueryNormalizer // normalization applied to the query strings of links
+	TextHash bool            // set to calculate a similarity hash (SimHash) of the text of each page
+	Assets   bool            // set to record the static assets (images, scripts and stylesheets) on the domain
+
+	// set to record the breadcrumb trail declared by each page in JSON-LD structured data
+	Breadcrumbs bool
+
+	// set to also take links from JSON-LD structured data, meta refresh tags and data-href attributes
+	StructuredLinks bool
+
+	// normalization applied to links to the site, and which hosts are part of it
+	Policy URLPolicy
+
+	// set to keep #/ and #!/ fragments, treating each route of a hash-routed single page app as a page
+	HashRoutes bool
+
+	// set to record the videos on each page, from <video> elements, embedded players and JSON-LD structured data
+	Videos bool
+
+	// set to record the H2 headings of each page as well as its H1 headings
+	H2 bool
+
+	// set to record the schema.org types of the structured data on each page, from JSON-LD and microdata
+	SchemaTypes bool
+
+	// set to count the words of the visible text of each page
+	WordCount bool
 }
 
 // CreateDocumentParser creates a new DocParser for parsing HTML and returning a WebPage
@@ -26,7 +55,9 @@ func CreateDocumentParser() *DocParser {
 	return &DocParser{}
 }
 
-// ParseDocument parses an HTML document and extracts a WebPage. See DocumentParser interface for details
+// ParseDocument parses an HTML document and extracts a WebPage. See DocumentParser interface for details.
+// The document is streamed through a tokenizer rather than building its DOM, so large pages can be parsed
+// with few allocations.
 func (p *DocParser) ParseDocument(urlStr string, reader io.Reader) (*WebPage, error) {
 
 	// first parse the URL to allow relative href links to be correctly calculated
@@ -35,62 +66,465 @@ func (p *DocParser) ParseDocument(urlStr string, reader io.Reader) (*WebPage, er
 		return nil, err
 	}
 
-	rootNode, err := html.Parse(reader)
-	if err != nil {
+	scanner := &documentScanner{p: p, parentURL: parentURL, page: CreateWebPage(parentURL, "")}
+	if err := scanner.scan(html.NewTokenizer(reader)); err != nil {
 		return nil, err
 	}
+	if p.TextHash {
+		scanner.page.TextHash = SimHash(scanner.text.String())
+	}
+	return scanner.page, nil
+}
 
-	page := CreateWebPage(parentURL, "")
-	err = p.parseNode(rootNode, parentURL, page)
-	if err != nil {
-		return nil, err
+// documentScanner holds the state of a DocParser streaming through the tokens of a single document
+type documentScanner struct {
+	p          *DocParser
+	parentURL  *url.URL
+	page       *WebPage
+	open       []openElement     // elements whose end tag hasn't been read yet, outermost first
+	collectors []*linkCollector  // links waiting for the end tag of their element, outermost first
+	anchors    int               // number of open <a> elements (only assets are recorded inside a link)
+	text       strings.Builder   // text displayed to the reader (only collected for the text hash)
+	video      *Video            // video of the open <video> element, added to the page when it ends (nil if none)
+	heading    *headingCollector // text of the open heading, added to the page when it ends (nil if none)
+}
+
+// openElement is an element whose start tag has been read but not its end tag
+type openElement struct {
+	tag     atom.Atom      // element name (0 for elements unknown to the atom package, e.g. custom elements)
+	context LinkContext    // context of the links inside the element
+	hidden  bool           // set if the element's text isn't displayed, e.g. a script or anything in the <head>
+	jsonLD  bool           // set for JSON-LD structured data
+	links   *linkCollector // links using the text of the element (nil if none)
+}
+
+// linkCollector holds the links of an element whose text is used as their link text, e.g. an <a>, collecting
+// the text (plus the alt text of any images) until the element ends
+type linkCollector struct {
+	hrefs   []string    // hrefs of the links, in the order found
+	context LinkContext // part of the page the element is in
+	text    strings.Builder
+}
+
+// Sets of elements used when scanning a document
+var (
+	// elements with no content (and so no end tag)
+	voidElements = map[atom.Atom]bool{
+		atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true, atom.Embed: true, atom.Hr: true,
+		atom.Img: true, atom.Input: true, atom.Link: true, atom.Meta: true, atom.Param: true,
+		atom.Source: true, atom.Track: true, atom.Wbr: true,
+	}
+
+	// elements whose text isn't displayed to the reader
+	hiddenElements = map[atom.Atom]bool{
+		atom.Head: true, atom.Title: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+		atom.Template: true,
+	}
+
+	// elements which may be in the <head>, any other element implicitly ending it
+	headElements = map[atom.Atom]bool{
+		atom.Base: true, atom.Link: true, atom.Meta: true, atom.Noscript: true, atom.Script: true,
+		atom.Style: true, atom.Template: true, atom.Title: true,
+	}
+
+	// elements whose attributes are all read, as they are processed by the parser (only the attributes
+	// deciding the context of links, or encoding links, are read for other elements)
+	parsedElements = map[atom.Atom]bool{
+		atom.A: true, atom.Area: true, atom.Iframe: true, atom.Frame: true, atom.Link: true, atom.Script: true,
+		atom.Meta: true, atom.Img: true, atom.Source: true, atom.Video: true, atom.Embed: true,
+	}
+)
+
+// scan reads the tokens of the document, recording its details in the page
+func (s *documentScanner) scan(z *html.Tokenizer) error {
+	for {
+		switch tokenType := z.Next(); tokenType {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			return s.closeTo(0) // end any elements left open
+		case html.TextToken:
+			if err := s.addText(z.Text()); err != nil {
+				return err
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag := s.readTag(z)
+			if err := s.startTag(&tag, tokenType == html.SelfClosingTagToken); err != nil {
+				return err
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if err := s.endTag(atom.Lookup(name)); err != nil {
+				return err
+			}
+		}
 	}
-	return page, nil
 }
 
-// parseNode recursively parses the details of the node into the page structure
-func (p *DocParser) parseNode(node *html.Node, parentURL *url.URL, page *WebPage) error {
+// readTag returns the start tag just read by the tokenizer. Only the attributes used by the parser are kept,
+// avoiding allocating strings for the rest (e.g. the classes and styles of every element).
+func (s *documentScanner) readTag(z *html.Tokenizer) html.Token {
+	name, more := z.TagName()
+	tag := html.Token{Type: html.StartTagToken, DataAtom: atom.Lookup(name)}
+	if tag.Data = tag.DataAtom.String(); tag.DataAtom == 0 {
+		tag.Data = string(name)
+	}
+	for more {
+		var key, val []byte
+		key, val, more = z.TagAttr()
+		if parsedElements[tag.DataAtom] || string(key) == "role" || (s.p.StructuredLinks && isDataLinkAttribute(key)) ||
+			(s.p.SchemaTypes && string(key) == "itemtype") {
+			tag.Attr = append(tag.Attr, html.Attribute{Key: string(key), Val: string(val)})
+		}
+	}
+	return tag
+}
 
-	// is this a link?
-	if node.Type == html.ElementNode && node.Data == "a" {
-		for _, attr := range node.Attr {
-			if strings.EqualFold(attr.Key, "href") {
-				internal, absURL, err := p.parseURL(parentURL, attr.Val)
-				if err != nil {
-					return err
-				} else if internal {
-					page.InternalLinks[absURL] = true
-				}
-				break
+// context returns the context of links at the current position in the document
+func (s *documentScanner) context() LinkContext {
+	if len(s.open) == 0 {
+		return LinkBody
+	}
+	return s.open[len(s.open)-1].context
+}
+
+// startTag records the details of an element from its start tag, with the links which use the text of the
+// element being added once it ends
+func (s *documentScanner) startTag(tag *html.Token, selfClosing bool) error {
+	p, parentURL, page, context := s.p, s.parentURL, s.page, s.context()
+
+	// an <a> can't be inside another (so ends any open one), and the body ends the head
+	if tag.DataAtom == atom.A && s.anchors > 0 {
+		if err := s.endTag(atom.A); err != nil {
+			return err
+		}
+	} else if tag.DataAtom == atom.Body || (len(s.open) != 0 && s.open[len(s.open)-1].tag == atom.Head && !headElements[tag.DataAtom]) {
+		if err := s.endTag(atom.Head); err != nil {
+			return err
+		}
+	}
+
+	// is it a static asset? These are only recorded if requested
+	if p.Assets {
+		p.addAssets(tag, parentURL, page)
+	}
+
+	// is it a video? These are only recorded if requested
+	if p.Videos {
+		s.addVideo(tag)
+	}
+
+	// is it a microdata item? Its types are only recorded if requested
+	if p.SchemaTypes {
+		addMicrodataTypes(tag, page)
+	}
+
+	// the first meta description is the page's description
+	if tag.DataAtom == atom.Meta && len(page.Description) == 0 {
+		if name, _ := attrValue(tag, "name"); strings.EqualFold(strings.TrimSpace(name), "description") {
+			content, _ := attrValue(tag, "content")
+			page.Description = collapseSpace(content)
+		}
+	}
+
+	// links read so far inside a link aren't followed (but the images in it are part of its text)
+	var links *linkCollector
+	if s.anchors == 0 {
+		var err error
+		if links, err = s.addLinks(tag, context); err != nil {
+			return err
+		}
+	}
+	if links != nil {
+		s.collectors = append(s.collectors, links)
+	}
+	if alt, found := attrValue(tag, "alt"); found && tag.DataAtom == atom.Img {
+		for _, collector := range s.collectors {
+			collector.text.WriteString(" " + alt + " ")
+		}
+		if s.heading != nil {
+			s.heading.text.WriteString(" " + alt + " ")
+		}
+	}
+	s.startHeading(tag.DataAtom)
+
+	element := openElement{tag: tag.DataAtom, context: linkContext(tag, context), links: links}
+	if len(s.open) != 0 {
+		element.hidden = s.open[len(s.open)-1].hidden
+	}
+	element.hidden = element.hidden || hiddenElements[tag.DataAtom]
+	if tag.DataAtom == atom.Script {
+		scriptType, _ := attrValue(tag, "type")
+		element.jsonLD = strings.EqualFold(strings.TrimSpace(scriptType), "application/ld+json")
+	}
+	s.open = append(s.open, element)
+	if tag.DataAtom == atom.A {
+		s.anchors++
+	}
+	if voidElements[tag.DataAtom] || selfClosing {
+		return s.closeTo(len(s.open) - 1) // no content, so ends immediately
+	}
+	return nil
+}
+
+// addLinks records the links of an element which don't depend on its content, returning a collector for
+// those which use its text (nil if none)
+func (s *documentScanner) addLinks(tag *html.Token, context LinkContext) (*linkCollector, error) {
+	p, parentURL, page := s.p, s.parentURL, s.page
+	var hrefs []string
+
+	// does it encode links outside an href (in structured data or attributes used by scripts)? These are only
+	// recorded if requested
+	if p.StructuredLinks {
+		if err := p.addMetaRefresh(tag, parentURL, page, context); err != nil {
+			return nil, err
+		}
+		hrefs = dataLinks(tag)
+	}
+
+	// is this a link? Besides <a> this includes image map areas and frames, so framed sites and image map
+	// navigation are mapped too
+	switch tag.DataAtom {
+	case atom.A:
+		if href, found := attrValue(tag, "href"); found {
+			hrefs = append(hrefs, href)
+		}
+	case atom.Area:
+		if href, found := attrValue(tag, "href"); found {
+			alt, _ := attrValue(tag, "alt")
+			return nil, p.addLink(parentURL, page, href, Link{collapseSpace(alt), context})
+		}
+	case atom.Iframe, atom.Frame:
+		if src, found := attrValue(tag, "src"); found {
+			return nil, p.addLink(parentURL, page, src, Link{frameTitle(tag), context})
+		}
+	case atom.Link:
+		return nil, s.addLinkElement(tag, context)
+	}
+	if len(hrefs) == 0 {
+		return nil, nil
+	}
+	return &linkCollector{hrefs: hrefs, context: context}, nil
+}
+
+// addLinkElement records the page a <link> element refers to. Canonical links are only recorded if they refer
+// to a different page on the same domain, while RSS and Atom feeds are recorded wherever they are. Other <link>
+// elements pointing to related pages (e.g. the next page of a series) are treated as links.
+func (s *documentScanner) addLinkElement(tag *html.Token, context LinkContext) error {
+	p, parentURL, page := s.p, s.parentURL, s.page
+	if isCanonicalLink(tag) {
+		if href, found := attrValue(tag, "href"); found {
+			canonical, err := p.resolveURL(parentURL, href)
+			if err != nil {
+				return err
+			} else if canonical != nil && canonical.String() != page.URL.String() {
+				page.Canonical = canonical.String()
+			}
+		}
+	} else if lang, found := attrValue(tag, "hreflang"); found && hasRel(tag, "alternate") {
+		if href, found := attrValue(tag, "href"); found {
+			return p.addLanguage(parentURL, page, strings.TrimSpace(lang), href, context)
+		}
+	} else if isFeedLink(tag) {
+		if href, found := attrValue(tag, "href"); found {
+			if feed, err := parentURL.Parse(strings.TrimSpace(href)); err == nil && (feed.Scheme == "http" || feed.Scheme == "https") {
+				page.AddFeed(feed.String())
 			}
 		}
+	} else if isNavigableLink(tag) {
+		if href, found := attrValue(tag, "href"); found {
+			title, _ := attrValue(tag, "title")
+			return p.addLink(parentURL, page, href, Link{collapseSpace(title), context})
+		}
+	}
+	return nil
+}
+
+// addText records the details of a text token: the title, JSON-LD structured data, and the text of the page
+// and of the open elements with links
+func (s *documentScanner) addText(text []byte) error {
+	for _, collector := range s.collectors {
+		collector.text.Write(text)
+	}
+	if s.heading != nil {
+		s.heading.text.Write(text)
+	}
+	if len(s.open) == 0 {
+		s.addVisibleText(text)
 		return nil
 	}
+	element := s.open[len(s.open)-1]
+	if !element.hidden {
+		s.addVisibleText(text)
+	}
+	switch {
+	case element.tag == atom.Title && s.anchors == 0:
+		// trim whitespace then take the first line as the title
+		title := strings.TrimSpace(string(text))
+		if idx := strings.Index(title, "\n"); idx >= 0 {
+			title = strings.Split(title, "\n")[0]
+		}
+		s.page.Title = title
+	case element.jsonLD && s.anchors == 0:
+		// links are only taken from JSON-LD if requested, and only the first breadcrumb trail found is recorded
+		if s.p.StructuredLinks {
+			if err := s.p.addJSONLDLinks(s.parentURL, s.page, string(text), element.context); err != nil {
+				return err
+			}
+		}
+		if s.p.Breadcrumbs && s.page.Breadcrumbs == nil {
+			s.p.addBreadcrumbs(s.parentURL, s.page, string(text))
+		}
+		if s.p.Videos {
+			s.p.addJSONLDVideos(s.parentURL, s.page, string(text))
+		}
+		if s.p.SchemaTypes {
+			for _, schemaType := range parseJSONLDTypes(string(text)) {
+				s.page.AddSchemaType(schemaType)
+			}
+		}
+	}
+	return nil
+}
+
+// addVisibleText adds text displayed on the page to its text for the similarity hash, and to its word count
+func (s *documentScanner) addVisibleText(text []byte) {
+	if s.p.TextHash {
+		s.text.Write(text)
+		s.text.WriteByte(' ')
+	}
+	if s.p.WordCount {
+		s.page.WordCount += countWords(text)
+	}
+}
+
+// endTag ends the innermost open element with a tag, along with any elements inside it left open. End tags
+// without a matching start tag are ignored.
+func (s *documentScanner) endTag(tag atom.Atom) error {
+	for i := len(s.open) - 1; i >= 0; i-- {
+		if s.open[i].tag == tag {
+			return s.closeTo(i)
+		}
+	}
+	return nil
+}
 
-	// is it the title?
-	if node.Type == html.ElementNode && strings.EqualFold(node.Data, "title") {
-		if node.FirstChild != nil && node.FirstChild.Type == html.TextNode {
-			// trim whitespace then take the first line as the title
-			title := strings.TrimSpace(node.FirstChild.Data)
-			if idx := strings.Index(title, "\n"); idx >= 0 {
-				title = strings.Split(title, "\n")[0]
+// closeTo ends the open elements until only n are left, adding the links which use their text
+func (s *documentScanner) closeTo(n int) error {
+	for len(s.open) > n {
+		element := s.open[len(s.open)-1]
+		s.open = s.open[:len(s.open)-1]
+		if element.tag == atom.A {
+			s.anchors--
+		} else if element.tag == atom.Video && s.video != nil {
+			s.page.AddVideo(*s.video)
+			s.video = nil
+		}
+		s.endHeading(element.tag)
+		if element.links == nil {
+			continue
+		}
+		s.collectors = s.collectors[:len(s.collectors)-1]
+		text := collapseSpace(element.links.text.String())
+		for _, href := range element.links.hrefs {
+			if err := s.p.addLink(s.parentURL, s.page, href, Link{text, element.links.context}); err != nil {
+				return err
 			}
-			page.Title = title
 		}
-		return nil
 	}
+	return nil
+}
 
-	// no, recursively process its children
-	for child := node.FirstChild; child != nil; child = child.NextSibling {
-		err := p.parseNode(child, parentURL, page)
-		if err != nil {
-			return err
+// addBreadcrumbs records the breadcrumb trail declared in a JSON-LD script, with the URL of each item
+// normalised as links are (items which can't be normalised, such as those on other domains, are recorded as
+// written)
+func (p *DocParser) addBreadcrumbs(parentURL *url.URL, page *WebPage, script string) {
+	refs := parseBreadcrumbs(script)
+	if refs == nil {
+		return
+	}
+	page.Breadcrumbs = make([]string, len(refs))
+	for i, ref := range refs {
+		page.Breadcrumbs[i] = ref
+		if len(ref) == 0 {
+			page.Breadcrumbs[i] = page.URL.String()
+		} else if absolute, err := parentURL.Parse(ref); err == nil {
+			if normalized, err := p.normalizeURL(parentURL, absolute.String()); err == nil && normalized != nil {
+				page.Breadcrumbs[i] = normalized.String()
+			}
 		}
 	}
+}
 
+// addLink adds a link to the page if the href is a page on the same domain, recording any problems with
+// the encoding of the href
+func (p *DocParser) addLink(parentURL *url.URL, page *WebPage, href string, link Link) error {
+	internal, absURL, err := p.parseURL(parentURL, href)
+	if err != nil {
+		return err
+	} else if internal {
+		page.AddLink(absURL, link)
+		if issue := CheckHrefEncoding(strings.TrimSpace(href)); issue != nil {
+			page.HrefIssues = append(page.HrefIssues, *issue)
+		}
+	} else if external, err := p.normalizeURL(parentURL, href); err == nil && external != nil && !p.Policy.sameSite(external, parentURL) {
+		page.AddExternalLink(external.String())
+	}
 	return nil
 }
 
+// addAssets records the static assets on the same domain referenced by an element: image and script sources
+// (including each image in a srcset) and stylesheets
+func (p *DocParser) addAssets(tag *html.Token, parentURL *url.URL, page *WebPage) {
+	var refs []string
+	switch tag.DataAtom {
+	case atom.Img, atom.Source:
+		if src, found := attrValue(tag, "src"); found {
+			refs = append(refs, src)
+		}
+		if srcset, found := attrValue(tag, "srcset"); found {
+			refs = append(refs, parseSrcset(srcset)...)
+		}
+	case atom.Script:
+		if src, found := attrValue(tag, "src"); found {
+			refs = append(refs, src)
+		}
+	case atom.Link:
+		if href, found := attrValue(tag, "href"); found && hasRel(tag, "stylesheet") {
+			refs = append(refs, href)
+		} else if target, err := p.resolveURL(parentURL, strings.TrimSpace(href)); found && err == nil && target != nil {
+			for _, rel := range hintRels {
+				if hasRel(tag, rel) {
+					page.AddHint(target.String(), rel)
+				}
+			}
+			if isIconLink(tag) {
+				page.AddIcon(target.String())
+			}
+			if hasRel(tag, "manifest") {
+				page.Manifest = target.String()
+			}
+		}
+	}
+	for _, ref := range refs {
+		if asset, err := p.resolveURL(parentURL, strings.TrimSpace(ref)); err == nil && asset != nil {
+			page.AddAsset(asset.String())
+		}
+	}
+}
+
+// parseSrcset returns the URLs of the image candidates in a srcset attribute (e.g. "a.png 1x, b.png 2x")
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) != 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
 // parseURL parses the url and tests if it is a valid link to a page on the same domain as the parent.
 // Returns 3 fields:
 //		bool	is this a valid url on the same domain as the parent
@@ -99,21 +533,60 @@ func (p *DocParser) parseNode(node *html.Node, parentURL *url.URL, page *WebPage
 //
 func (p *DocParser) parseURL(parent *url.URL, href string) (bool, string, error) {
 
+	result, err := p.resolveURL(parent, href)
+	if err != nil || result == nil {
+		return false, "", err
+	}
+
+	// If they resolve to the same URL as the parent we ignore it
+	// Note we only care about the path (not scheme or query) and any hash route
+	if result.Path == parent.Path && result.Fragment == parent.Fragment {
+		return false, "", nil
+	}
+
+	return true, result.String(), nil
+}
+
+// resolveURL resolves href against the parent URL and returns it in a normalised form, or nil if it
+// is not a page on the same domain as the parent.
+// An error is returned if invalid inputs are supplied (note invalid href string is not considered an error)
+func (p *DocParser) resolveURL(parent *url.URL, href string) (*url.URL, error) {
+	result, err := p.normalizeURL(parent, href)
+	if err != nil || result == nil || !p.Policy.sameSite(result, parent) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// normalizeURL resolves href against the parent URL and returns it in a normalised form, or nil if it is
+// not an http or https URL. Unlike resolveURL, URLs on other domains are returned.
+func (p *DocParser) normalizeURL(parent *url.URL, href string) (*url.URL, error) {
+
 	// first a sanity check - the parent must be an absolute url
 	if !parent.IsAbs() {
-		return false, "", fmt.Errorf("cannot resolve href as relative URL passed as parent: %v", href)
+		return nil, fmt.Errorf("cannot resolve href as relative URL passed as parent: %v", href)
 	}
 
 	strURL := href
 	if strings.HasPrefix(href, "/") {
 		// relative url - create one based off the parent
 		tempURL := *parent
-		tempURL.Path = href
+		tempURL.Path, tempURL.RawPath, tempURL.RawQuery = href, "", ""
+		tempURL.Fragment, tempURL.RawFragment = "", ""
+		if ref, err := url.Parse(href); err == nil && len(ref.Host) == 0 {
+			// keep any query string and fragment (e.g. a hash route) out of the path
+			tempURL.Path, tempURL.RawPath, tempURL.RawQuery, tempURL.Fragment = ref.Path, ref.RawPath, ref.RawQuery, ref.Fragment
+		}
 		strURL = tempURL.String()
+	} else if p.HashRoutes && strings.HasPrefix(href, "#") {
+		// a route within the parent page
+		tempURL := *parent
+		tempURL.Fragment, tempURL.RawFragment = "", ""
+		strURL = tempURL.String() + href
 	}
 	result, err := url.Parse(strURL)
 	if err != nil {
-		return false, "", err
+		return nil, err
 	}
 
 	// use same scheme as parent on a relative URL
@@ -123,40 +596,148 @@ func (p *DocParser) parseURL(parent *url.URL, href string) (bool, string, error)
 
 	// is it a supported scheme
 	if len(result.Scheme) != 0 && result.Scheme != "http" && result.Scheme != "https" {
-		return false, "", nil
+		return nil, nil
 	}
 
 	// we remove any training / to ensure equivilent URLS match and ignore fragments
 	result.Path = strings.TrimSuffix(result.Path, "/")
-	result.Fragment = ""
+	if !p.HashRoutes || !isHashRoute(result.Fragment) {
+		result.Fragment = ""
+	}
+	result.Fragment = strings.TrimSuffix(result.Fragment, "/")
+	result.RawFragment = ""
+	p.Query.Normalize(result)
 
-	// normalise it
+	// normalise it, with internationalized domain names in their ASCII form
+	result.Host = ASCIIHost(result.Host)
 	result, err = url.Parse(result.String())
 	if err != nil || len(result.Host) == 0 {
-		return false, "", err
+		return nil, err
 	}
+	p.Policy.Apply(result, parent.Host)
+	return result, nil
+}
 
-	// check the domain
-	if !sameHost(result.Host, parent.Host) {
-		return false, "", nil // different domain
+// addLanguage records a language variant of the page declared with hreflang, which may be on another domain.
+// Variants on the same domain are also followed as links so all languages of the site are mapped.
+func (p *DocParser) addLanguage(parentURL *url.URL, page *WebPage, lang string, href string, context LinkContext) error {
+	variant, err := p.normalizeURL(parentURL, strings.TrimSpace(href))
+	if err != nil || variant == nil || len(lang) == 0 {
+		return err
 	}
+	page.AddLanguage(lang, variant.String())
+	if !p.Policy.sameSite(variant, parentURL) {
+		return nil
+	}
+	return p.addLink(parentURL, page, href, Link{lang, context})
+}
 
-	if len(result.Port()) != 0 && result.Port() != parent.Port() {
-		return false, "", nil // different port
+// isHashRoute checks if a fragment is a route of a hash-routed single page app (e.g. #/about or #!/about)
+// other than its root route
+func isHashRoute(fragment string) bool {
+	route := strings.TrimPrefix(fragment, "!")
+	return strings.HasPrefix(route, "/") && len(strings.TrimSuffix(route, "/")) != 0
+}
+
+// isSameSite checks if a URL is on the same domain (and port) as the parent URL
+func isSameSite(u *url.URL, parent *url.URL) bool {
+	if !sameHost(u.Host, parent.Host) {
+		return false // different domain
 	}
+	return len(u.Port()) == 0 || u.Port() == parent.Port()
+}
 
-	// If they resolve to the same URL as the parent we ignore it
-	// Note we only care about the path (not scheme, fragment or query)
-	if result.Path == parent.Path {
-		return false, "", nil
+// frameTitle returns the text used for a link to the page in a frame: its title, or its name if it has none
+func frameTitle(tag *html.Token) string {
+	if title, found := attrValue(tag, "title"); found && len(strings.TrimSpace(title)) != 0 {
+		return collapseSpace(title)
+	}
+	name, _ := attrValue(tag, "name")
+	return collapseSpace(name)
+}
+
+// collapseSpace trims whitespace from text, and replaces each run of whitespace within it by a single space
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// attrValue returns the value of a tag's attribute (matched case insensitively), and whether it was found
+func attrValue(tag *html.Token, key string) (string, bool) {
+	for _, attr := range tag.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val, true
+		}
 	}
+	return "", false
+}
 
-	return true, result.String(), nil
+// linkContext returns the context of links inside an element, given the context the element itself is in.
+// The innermost <nav> or <footer> element (or ARIA navigation or contentinfo role) a link is inside decides
+// its context.
+func linkContext(tag *html.Token, context LinkContext) LinkContext {
+	switch tag.DataAtom {
+	case atom.Nav:
+		return LinkNav
+	case atom.Footer:
+		return LinkFooter
+	}
+	for _, attr := range tag.Attr {
+		if strings.EqualFold(attr.Key, "role") {
+			switch strings.ToLower(strings.TrimSpace(attr.Val)) {
+			case "navigation":
+				return LinkNav
+			case "contentinfo":
+				return LinkFooter
+			}
+		}
+	}
+	return context
+}
+
+// isCanonicalLink checks if a <link> element has a rel of canonical
+func isCanonicalLink(tag *html.Token) bool {
+	return hasRel(tag, "canonical")
+}
+
+// isIconLink checks if a <link> element declares an icon for the page (including "shortcut icon")
+func isIconLink(tag *html.Token) bool {
+	return hasRel(tag, "icon") || hasRel(tag, "apple-touch-icon")
+}
+
+// hasRel checks if a tag's rel attribute includes the supplied value
+func hasRel(tag *html.Token, value string) bool {
+	rels, _ := attrValue(tag, "rel")
+	for _, rel := range strings.Fields(rels) {
+		if strings.EqualFold(rel, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// navigableRels are the rel values of <link> elements which refer to other pages a user could navigate to
+var navigableRels = map[string]bool{
+	"next": true, "prev": true, "previous": true, "first": true, "last": true, "up": true,
+	"index": true, "contents": true, "start": true,
+}
+
+// isNavigableLink checks if a <link> element has a rel referring to another page a user could navigate to (e.g.
+// the next page of a series), rather than a resource used by the page such as a stylesheet
+func isNavigableLink(tag *html.Token) bool {
+	rels, _ := attrValue(tag, "rel")
+	for _, rel := range strings.Fields(rels) {
+		if navigableRels[strings.ToLower(rel)] {
+			return true
+		}
+	}
+	return false
 }
 
 // sameHost checks if 2 hosts represent the same domain.
-// We consider  example.com and www.example.com to be the same domain.
+// We consider  example.com and www.example.com to be the same domain, and internationalized domain names
+// the same whether written in Unicode or punycode.
 func sameHost(h1 string, h2 string) bool {
+	h1, h2 = ASCIIHost(h1), ASCIIHost(h2)
 	h1 = strings.TrimPrefix(h1, "www.")
 	h2 = strings.TrimPrefix(h2, "www.")
 	return strings.EqualFold(h1, h2)
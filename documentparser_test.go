@@ -1,9 +1,12 @@
-package main
+package sitemap
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
 	"testing"
+
+	"golang.org/x/net/html"
 )
 
 // validatePage helper function which validates a parsed page is correct
@@ -122,6 +125,39 @@ func TestParseMultiLineTitle(t *testing.T) {
 	validatePage(t, err, page, URL, "Page Title 2", nil)
 }
 
+func TestParseCanonicalLink(t *testing.T) {
+
+	URL := "http://example.com/page/index.html"
+	html := `
+<HTML>
+	<HEAD>
+		<TITLE>Page Title</TITLE>
+		<LINK rel="stylesheet" href="/style.css">
+		<LINK rel="Canonical" href="/page">
+	</HEAD>
+	<BODY>
+		<a href="/other">Link</a>
+	</BODY>
+</HTML>`
+
+	parser := CreateDocumentParser()
+	page, err := parser.ParseDocument(URL, strings.NewReader(html))
+	validatePage(t, err, page, URL, "Page Title", []string{"http://example.com/other"})
+	if page.Canonical != "http://example.com/page" {
+		t.Fatalf("Incorrect canonical URL: expected %s, got %s", "http://example.com/page", page.Canonical)
+	}
+
+	// canonical links to the page itself or another domain are ignored
+	for _, href := range []string{URL, "http://anotherdomain.com/page"} {
+		html := `<HTML><HEAD><LINK rel="canonical" href="` + href + `"></HEAD></HTML>`
+		page, err := parser.ParseDocument(URL, strings.NewReader(html))
+		validatePage(t, err, page, URL, "", nil)
+		if len(page.Canonical) != 0 {
+			t.Fatalf("Unexpected canonical URL for href %s: got %s", href, page.Canonical)
+		}
+	}
+}
+
 func doTestURLParsing(t *testing.T, parser *DocParser, parent *url.URL, testURL string, expectedInternal bool, expectedURL string) {
 
 	internal, newURL, err := parser.parseURL(parent, testURL)
@@ -182,3 +218,299 @@ func TestURLParser(t *testing.T) {
 	doTestURLParsing(t, parser, parent, "en.wikipedia.com/path", false, "") // resolves to same path
 	doTestURLParsing(t, parser, parent, "ftp://en.wikipedia.com/doc", false, "")
 }
+
+// Test query strings of links are normalized as configured
+func TestURLParserQueryNormalization(t *testing.T) {
+	parser := CreateDocumentParser()
+	parser.Query = QueryNormalizer{Drop: ParseDropParams("default"), Sort: true}
+	parent, _ := url.Parse("http://en.wikipedia.com/path")
+	doTestURLParsing(t, parser, parent, "http://en.wikipedia.com/a?utm_source=news&b=2&a=1", true, "http://en.wikipedia.com/a?a=1&b=2")
+	doTestURLParsing(t, parser, parent, "http://en.wikipedia.com/a?fbclid=123", true, "http://en.wikipedia.com/a")
+}
+
+// Test hash routes are kept as separate pages only when enabled
+func TestURLParserHashRoutes(t *testing.T) {
+	parser := CreateDocumentParser()
+	parent, _ := url.Parse("http://en.wikipedia.com/app#!/home")
+	doTestURLParsing(t, parser, parent, "#/about", false, "")
+	doTestURLParsing(t, parser, parent, "/other#/about", true, "http://en.wikipedia.com/other")
+
+	parser.HashRoutes = true
+	doTestURLParsing(t, parser, parent, "#/about", true, "http://en.wikipedia.com/app#/about")
+	doTestURLParsing(t, parser, parent, "#!/users/1/", true, "http://en.wikipedia.com/app#!/users/1")
+	doTestURLParsing(t, parser, parent, "/other#/about", true, "http://en.wikipedia.com/other#/about")
+	doTestURLParsing(t, parser, parent, "/other?q=1#/about", true, "http://en.wikipedia.com/other?q=1#/about")
+	doTestURLParsing(t, parser, parent, "http://en.wikipedia.com/app#!/home", false, "") // same route as the parent
+	doTestURLParsing(t, parser, parent, "#/", true, "http://en.wikipedia.com/app")
+	doTestURLParsing(t, parser, parent, "#section", true, "http://en.wikipedia.com/app")
+	doTestURLParsing(t, parser, parent, "/other#section", true, "http://en.wikipedia.com/other")
+}
+
+// Test the text similarity hash is only calculated when enabled
+func TestParseDocumentTextHash(t *testing.T) {
+	doc := "<html><head><title>Title</title></head><body><p>Some text on the page</p></body></html>"
+	parser := CreateDocumentParser()
+	page, err := parser.ParseDocument("https://test.com", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TextHash != 0 {
+		t.Errorf("Incorrect text hash when disabled: expected 0, got %x", page.TextHash)
+	}
+	parser.TextHash = true
+	if page, err = parser.ParseDocument("https://test.com", strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := SimHash("Some text on the page"); page.TextHash != expected {
+		t.Errorf("Incorrect text hash: expected %x, got %x", expected, page.TextHash)
+	}
+}
+
+func TestParseDocumentAnchors(t *testing.T) {
+	doc := `<html><body>
+		<header><nav><a href="/">Home</a> <a href="/about">About
+			us</a></nav></header>
+		<div role="navigation"><a href="/blog"><img src="b.png" alt="Blog"></a></div>
+		<main>
+			<p>Read <a href="/about">more <b>about</b> us</a> or <a href="/blog"></a></p>
+			<footer>Posted by <a href="/about">us</a></footer>
+		</main>
+		<div role="contentinfo"><nav><a href="/sitemap">Site map</a></nav><a href="/terms">Terms</a></div>
+	</body></html>`
+	page, err := CreateDocumentParser().ParseDocument("https://test.com/page", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"https://test.com":         "[{Home nav}]",
+		"https://test.com/about":   "[{About us nav} {more about us body} {us footer}]",
+		"https://test.com/blog":    "[{Blog nav} { body}]",
+		"https://test.com/sitemap": "[{Site map nav}]",
+		"https://test.com/terms":   "[{Terms footer}]",
+	}
+	if len(page.InternalLinks) != len(expected) {
+		t.Errorf("Incorrect links: expected %d, got %v", len(expected), page.InternalLinks)
+	}
+	for link, occurrences := range expected {
+		if got := fmt.Sprint(page.InternalLinks[link]); got != occurrences {
+			t.Errorf("Incorrect occurrences of link %s: expected %s, got %s", link, occurrences, got)
+		}
+	}
+}
+
+func TestParseDocumentMalformed(t *testing.T) {
+	doc := `<html><head><title>Title</title>
+		<div role="navigation"><div class="menu"><a href="/one">One<a href="/two">Two</div>
+		<ul><li><a href="/three">Three<li><a href="/four">Four</ul></div>
+		<p>Text <a href="/five">Five <span>unclosed</a> <a href="/six">Six`
+	page, err := CreateDocumentParser().ParseDocument("https://test.com/page", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"https://test.com/one":   "[{One nav}]",
+		"https://test.com/two":   "[{Two nav}]",
+		"https://test.com/three": "[{Three nav}]",
+		"https://test.com/four":  "[{Four nav}]",
+		"https://test.com/five":  "[{Five unclosed body}]",
+		"https://test.com/six":   "[{Six body}]",
+	}
+	if len(page.InternalLinks) != len(expected) {
+		t.Errorf("Incorrect links: expected %d, got %v", len(expected), page.InternalLinks)
+	}
+	for link, occurrences := range expected {
+		if got := fmt.Sprint(page.InternalLinks[link]); got != occurrences {
+			t.Errorf("Incorrect occurrences of link %s: expected %s, got %s", link, occurrences, got)
+		}
+	}
+	if page.Title != "Title" {
+		t.Errorf("Incorrect title: expected Title, got %s", page.Title)
+	}
+}
+
+func TestParseDocumentLinkSources(t *testing.T) {
+	docs := map[string]string{
+		"image map and iframe": `<html><head>
+			<link rel="stylesheet" href="/style.css"><link rel="Next" href="/page/2" title="Page 2">
+			<link rel="prev" href="/page/0"></head><body>
+			<img src="map.png" usemap="#nav"><map name="nav"><area href="/about" alt="About  us"><area nohref></map>
+			<iframe src="/embed" title="Embedded"></iframe><iframe src="https://other.com/widget"></iframe>
+		</body></html>`,
+		"frameset": `<html><head></head><frameset cols="20%,80%">
+			<frame src="/menu" name="menu"><frame src="/content" title="Content"></frameset></html>`,
+	}
+	expected := map[string]map[string]string{
+		"image map and iframe": {
+			"https://test.com/page/2": "[{Page 2 body}]",
+			"https://test.com/page/0": "[{ body}]",
+			"https://test.com/about":  "[{About us body}]",
+			"https://test.com/embed":  "[{Embedded body}]",
+		},
+		"frameset": {
+			"https://test.com/menu":    "[{menu body}]",
+			"https://test.com/content": "[{Content body}]",
+		},
+	}
+	for name, doc := range docs {
+		page, err := CreateDocumentParser().ParseDocument("https://test.com/page", strings.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.InternalLinks) != len(expected[name]) {
+			t.Errorf("Incorrect links for %s: expected %d, got %v", name, len(expected[name]), page.InternalLinks)
+		}
+		for link, occurrences := range expected[name] {
+			if got := fmt.Sprint(page.InternalLinks[link]); got != occurrences {
+				t.Errorf("Incorrect occurrences of link %s for %s: expected %s, got %s", link, name, occurrences, got)
+			}
+		}
+	}
+}
+
+func TestParseDocumentExternalLinks(t *testing.T) {
+	doc := `<html><body><a href="/about">About</a><a href="https://other.com/x/">Other</a>
+		<a href="https://test.com:8080/admin">Admin</a><a href="mailto:us@test.com">Mail</a>
+		<a href="https://www.test.com/team">Team</a></body></html>`
+	page, err := CreateDocumentParser().ParseDocument("https://test.com/page", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "map[https://other.com/x:true https://test.com:8080/admin:true]"
+	if got := fmt.Sprint(page.ExternalLinks); got != expected {
+		t.Errorf("Incorrect external links: expected %s, got %s", expected, got)
+	}
+	if len(page.InternalLinks) != 2 {
+		t.Errorf("Incorrect internal links: expected 2, got %v", page.InternalLinks)
+	}
+}
+
+func TestParseDocumentAssets(t *testing.T) {
+	doc := `<html><head><link rel="stylesheet" href="/css/site.css"><link rel="icon" href="/favicon.ico">
+		<script src="/js/app.js"></script><script>inline()</script></head><body>
+		<a href="/about"><img src="/img/about.png" alt="About"></a>
+		<picture><source srcset="/img/hero.webp 1x, /img/hero@2x.webp 2x"><img src="/img/hero.png"
+			srcset="/img/hero.png 1x,/img/hero@2x.png 2x"></picture>
+		<img src="https://cdn.other.com/logo.png"></body></html>`
+	parser := CreateDocumentParser()
+	page, err := parser.ParseDocument("https://test.com/page", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Assets != nil {
+		t.Errorf("Incorrect assets when not requested: expected none, got %v", page.Assets)
+	}
+
+	parser.Assets = true
+	if page, err = parser.ParseDocument("https://test.com/page", strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[https://test.com/css/site.css https://test.com/img/about.png https://test.com/img/hero.png " +
+		"https://test.com/img/hero.webp https://test.com/img/hero@2x.png https://test.com/img/hero@2x.webp " +
+		"https://test.com/js/app.js]"
+	if got := fmt.Sprint(sortedKeys(page.Assets)); got != expected {
+		t.Errorf("Incorrect assets: expected %s, got %s", expected, got)
+	}
+	if len(page.InternalLinks) != 1 {
+		t.Errorf("Incorrect links: expected 1, got %v", page.InternalLinks)
+	}
+}
+
+func TestParseDocumentHints(t *testing.T) {
+	doc := `<html><head><link rel="preload" href="/fonts/main.woff2" as="font">
+		<link rel="modulepreload" href="/js/module.js"><link rel="prefetch" href="/next">
+		<link rel="prerender" href="/next"><link rel="preload" href="/fonts/main.woff2" as="font">
+		<link rel="preload" href="https://cdn.other.com/lib.js"><link rel="dns-prefetch" href="/dns"></head></html>`
+	parser := CreateDocumentParser()
+	parser.Assets = true
+	page, err := parser.ParseDocument("https://test.com/page", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[{https://test.com/fonts/main.woff2 preload 0} {https://test.com/js/module.js modulepreload 0} " +
+		"{https://test.com/next prefetch 0} {https://test.com/next prerender 0}]"
+	if got := fmt.Sprint(page.Hints); got != expected {
+		t.Errorf("Incorrect hints: expected %s, got %s", expected, got)
+	}
+}
+
+func TestParseDocumentLanguages(t *testing.T) {
+	doc := `<html><head><link rel="alternate" hreflang="en" href="/en/about">
+		<link rel="alternate" hreflang="fr-FR" href="/fr/a-propos"><link rel="alternate" hreflang="de" href="https://test.de/ueber">
+		<link rel="alternate" hreflang="x-default" href="/about"><link rel="alternate" type="application/rss+xml" href="/feed">
+		<link rel="stylesheet" hreflang="en" href="/site.css"></head></html>`
+	parser := CreateDocumentParser()
+	page, err := parser.ParseDocument("https://test.com/en/about", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[{de https://test.de/ueber} {en https://test.com/en/about} {fr-fr https://test.com/fr/a-propos} " +
+		"{x-default https://test.com/about}]"
+	if got := fmt.Sprint(page.LanguageVariants()); got != expected {
+		t.Errorf("Incorrect languages: expected %s, got %s", expected, got)
+	}
+
+	// variants on the same domain are followed
+	expected = "[https://test.com/about https://test.com/fr/a-propos]"
+	if got := fmt.Sprint(sortedKeys(page.InternalLinks)); got != expected {
+		t.Errorf("Incorrect links: expected %s, got %s", expected, got)
+	}
+}
+
+// createLargePage returns a page with the given number of sections, each with a paragraph of text, links in
+// and out of navigation and an image, similar to a long article or category listing
+func createLargePage(sections int) string {
+	var page strings.Builder
+	page.WriteString(`<!DOCTYPE html><html><head><title>Large Page</title><link rel="canonical" href="/large">
+<link rel="stylesheet" href="/site.css"><script src="/site.js"></script></head><body>
+<nav><ul><li><a href="/">Home</a><li><a href="/about">About</a><li><a href="/contact">Contact</a></ul></nav><main>`)
+	for i := 0; i < sections; i++ {
+		fmt.Fprintf(&page, `<section class="item" id="item-%[1]d"><h2>Section %[1]d</h2>
+<p>Lorem ipsum dolor sit amet, <em>consectetur</em> adipiscing elit &amp; sed do eiusmod tempor incididunt ut
+labore et dolore magna aliqua. See <a href="/items/%[1]d?ref=list" class="more">item %[1]d</a> or
+<a href="https://other.com/page/%[1]d">elsewhere</a>.</p><img src="/images/%[1]d.png" alt="Item %[1]d"></section>
+`, i)
+	}
+	page.WriteString(`</main><footer><a href="/privacy">Privacy</a></footer></body></html>`)
+	return page.String()
+}
+
+func BenchmarkParseDocument(b *testing.B) {
+	doc := createLargePage(2000)
+	parser := CreateDocumentParser()
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ParseDocument("http://www.test.com/large", strings.NewReader(doc)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseDocumentAllOptions(b *testing.B) {
+	doc := createLargePage(2000)
+	parser := CreateDocumentParser()
+	parser.TextHash, parser.Assets, parser.Breadcrumbs, parser.StructuredLinks = true, true, true, true
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ParseDocument("http://www.test.com/large", strings.NewReader(doc)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParseDOM measures building the DOM of the same page as BenchmarkParseDocument, which the parser
+// previously did before extracting the page's details, for comparison
+func BenchmarkParseDOM(b *testing.B) {
+	doc := createLargePage(2000)
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := html.Parse(strings.NewReader(doc)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
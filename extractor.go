@@ -0,0 +1,36 @@
+package sitemap
+
+// MetadataExtractor extracts extra details from each crawled page, which are stored in the page's Metadata
+type MetadataExtractor interface {
+
+	// ExtractMetadata returns the details extracted from the page as name/value pairs (nil for none).
+	// This is called concurrently from the page loading goroutines, so must be thread safe.
+	ExtractMetadata(page *WebPage) (map[string]string, error)
+}
+
+// ExtractorFunc is a function implementing the MetadataExtractor interface
+type ExtractorFunc func(page *WebPage) (map[string]string, error)
+
+// ExtractMetadata calls the extractor function. See MetadataExtractor interface for details.
+func (extractor ExtractorFunc) ExtractMetadata(page *WebPage) (map[string]string, error) {
+	return extractor(page)
+}
+
+// ExtractMetadataOnPage returns an OnPageFunc adding the metadata returned by the extractor to each page.
+// Pages are never discarded, with any extraction error being logged.
+func ExtractMetadataOnPage(extractor MetadataExtractor, logger Logger) OnPageFunc {
+	return func(visit *PageVisit) bool {
+		metadata, err := extractor.ExtractMetadata(visit.Page)
+		if err != nil {
+			logger.Warn("Failed to extract page metadata", "url", visit.Page.URL.String(), "error", err)
+			return true
+		}
+		if len(metadata) != 0 && visit.Page.Metadata == nil {
+			visit.Page.Metadata = make(map[string]string, len(metadata))
+		}
+		for name, value := range metadata {
+			visit.Page.Metadata[name] = value
+		}
+		return true
+	}
+}
@@ -0,0 +1,200 @@
+package sitemap
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// feedTypes are the content types of the feeds recorded from <link rel="alternate"> elements
+var feedTypes = map[string]bool{"application/rss+xml": true, "application/atom+xml": true}
+
+// maxFeedSize is the most of a feed read when following the links to its items
+const maxFeedSize = 10 << 20
+
+// isFeedLink checks if a <link> element declares an RSS or Atom feed of the page
+func isFeedLink(tag *html.Token) bool {
+	feedType, _ := attrValue(tag, "type")
+	return hasRel(tag, "alternate") && feedTypes[strings.ToLower(strings.TrimSpace(feedType))]
+}
+
+// AddFeed records an RSS or Atom feed declared by the page
+func (page *WebPage) AddFeed(urlStr string) {
+	for _, feed := range page.Feeds {
+		if feed == urlStr {
+			return
+		}
+	}
+	page.Feeds = append(page.Feeds, urlStr)
+}
+
+// FeedUsage is an RSS or Atom feed declared by pages of the site
+type FeedUsage struct {
+	URL     string   // URL of the feed
+	Section string   // longest path (of whole path segments) shared by the pages declaring the feed
+	Pages   []string // URLs of the pages declaring the feed, sorted
+}
+
+// Feeds returns the RSS and Atom feeds declared by the pages of the site, sorted by URL, with the section
+// of the site each is declared in
+func (site *SiteMap) Feeds() []FeedUsage {
+	usage := make(map[string]*FeedUsage)
+	for _, page := range site.Pages {
+		for _, feed := range page.Feeds {
+			if _, found := usage[feed]; !found {
+				usage[feed] = &FeedUsage{URL: feed, Section: page.URL.Path}
+			}
+			usage[feed].Pages = append(usage[feed].Pages, page.URL.String())
+			usage[feed].Section = commonPath(usage[feed].Section, page.URL.Path)
+		}
+	}
+	result := make([]FeedUsage, 0, len(usage))
+	for _, feed := range sortedKeys(usage) {
+		sort.Strings(usage[feed].Pages)
+		if len(usage[feed].Section) == 0 {
+			usage[feed].Section = "/"
+		}
+		result = append(result, *usage[feed])
+	}
+	return result
+}
+
+// commonPath returns the longest path made of whole path segments which both paths start with, e.g. /blog for
+// /blog/a and /blog/b (empty if they only share the root)
+func commonPath(path1 string, path2 string) string {
+	segments1, segments2 := strings.Split(strings.Trim(path1, "/"), "/"), strings.Split(strings.Trim(path2, "/"), "/")
+	var common []string
+	for i := 0; i < len(segments1) && i < len(segments2) && segments1[i] == segments2[i] && len(segments1[i]) != 0; i++ {
+		common = append(common, segments1[i])
+	}
+	if len(common) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(common, "/")
+}
+
+// feedLinkParser is implemented by document parsers which can take the links to pages on the site out of a
+// feed (see DocParser.AddFeedLinks)
+type feedLinkParser interface {
+	AddFeedLinks(page *WebPage, feedURL string, reader io.Reader) error
+}
+
+// AddFeedLinks reads an RSS or Atom feed, adding a link from the page to each item in the feed on the same
+// domain, with the item's title as the link text. RSS items link with a <link> element and Atom entries with
+// the href of a <link> which is an alternate (or has no rel).
+func (p *DocParser) AddFeedLinks(page *WebPage, feedURL string, reader io.Reader) error {
+	parentURL, err := url.Parse(feedURL)
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	var inItem, inTitle, inLink bool
+	var title, href strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(token.Name.Local) {
+			case "item", "entry":
+				inItem = true
+				title.Reset()
+				href.Reset()
+			case "title":
+				inTitle = inItem
+			case "link":
+				if !inItem || href.Len() != 0 {
+					break
+				}
+				link, rel := xmlAttrValue(token, "href"), xmlAttrValue(token, "rel")
+				if len(link) != 0 && (len(rel) == 0 || strings.EqualFold(rel, "alternate")) {
+					href.WriteString(link)
+				} else if len(link) == 0 {
+					inLink = true
+				}
+			}
+		case xml.CharData:
+			if inTitle {
+				title.Write(token)
+			} else if inLink {
+				href.Write(token)
+			}
+		case xml.EndElement:
+			switch strings.ToLower(token.Name.Local) {
+			case "title":
+				inTitle = false
+			case "link":
+				inLink = false
+			case "item", "entry":
+				if internal, absURL, err := p.parseURL(parentURL, strings.TrimSpace(href.String())); err == nil && internal {
+					page.AddLink(absURL, Link{collapseSpace(title.String()), LinkFeed})
+				}
+				inItem = false
+			}
+		}
+	}
+}
+
+// xmlAttrValue returns the value of an attribute of an XML element (empty if it isn't set)
+func xmlAttrValue(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return strings.TrimSpace(attr.Value)
+		}
+	}
+	return ""
+}
+
+// addFeedLinks requests each feed on the domain declared by a page which hasn't been requested for another
+// page, adding a link from the page to each item in it so pages only linked from feeds are mapped too
+func (loader *DocLoader) addFeedLinks(ctx context.Context, page *WebPage) {
+	parser, ok := loader.parser.(feedLinkParser)
+	if !ok {
+		return
+	}
+	for _, feed := range page.Feeds {
+		feedURL, err := url.Parse(feed)
+		if err != nil || !sameHost(feedURL.Host, page.URL.Host) || !loader.firstFeedRequest(feed) {
+			continue
+		}
+		resp, err := loader.getWithHeader(ctx, feed, nil)
+		if err != nil {
+			loader.logger.Debug("Feed request failed", "url", feed, "error", err)
+			continue
+		}
+		if resp.StatusCode == http.StatusOK {
+			err = parser.AddFeedLinks(page, resp.Request.URL.String(), io.LimitReader(resp.Body, maxFeedSize))
+		}
+		resp.Body.Close()
+		if err != nil {
+			loader.logger.Debug("Failed to read feed", "url", feed, "error", err)
+		}
+	}
+}
+
+// firstFeedRequest records a feed is being requested, returning false if it has been requested before
+func (loader *DocLoader) firstFeedRequest(urlStr string) bool {
+	loader.feedMutex.Lock()
+	defer loader.feedMutex.Unlock()
+	if loader.feedsRequested == nil {
+		loader.feedsRequested = make(map[string]bool)
+	}
+	if loader.feedsRequested[urlStr] {
+		return false
+	}
+	loader.feedsRequested[urlStr] = true
+	return true
+}
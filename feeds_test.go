@@ -0,0 +1,134 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseFeeds(t *testing.T) {
+
+	const doc = `<html><head>
+<link rel="alternate" type="application/rss+xml" title="Blog" href="/blog/feed/?format=rss">
+<link rel="alternate" type="application/atom+xml" href="https://feeds.example.org/test">
+<link rel="alternate" type="application/rss+xml" href="/blog/feed/?format=rss">
+<link rel="alternate" type="application/pdf" href="/page.pdf">
+<link rel="stylesheet" type="application/rss+xml" href="/not-a-feed">
+</head><body></body></html>`
+	page, err := CreateDocumentParser().ParseDocument("https://test.com/blog/post", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	expected := []string{"https://test.com/blog/feed/?format=rss", "https://feeds.example.org/test"}
+	if !reflect.DeepEqual(page.Feeds, expected) {
+		t.Errorf("Incorrect feeds: expected %v, got %v", expected, page.Feeds)
+	}
+	if len(page.InternalLinks) != 0 {
+		t.Errorf("Incorrect links: expected feeds not to be followed as links, got %v", sortedKeys(page.InternalLinks))
+	}
+}
+
+func TestSiteFeeds(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	pages := map[string][]string{
+		"https://test.com":                {"https://test.com/feed"},
+		"https://test.com/blog":           {"https://test.com/blog/feed"},
+		"https://test.com/blog/2024/post": {"https://test.com/blog/feed", "https://test.com/blog/2024/comments"},
+		"https://test.com/blog/2025/post": {"https://test.com/blog/feed"},
+		"https://test.com/blogging":       {"https://test.com/feed"},
+		"https://test.com/about":          nil,
+	}
+	for urlStr, feeds := range pages {
+		page := createWebPage(t, urlStr, "")
+		for _, feed := range feeds {
+			page.AddFeed(feed)
+		}
+		site.AddPage(page)
+	}
+	expected := []FeedUsage{
+		{"https://test.com/blog/2024/comments", "/blog/2024/post", []string{"https://test.com/blog/2024/post"}},
+		{"https://test.com/blog/feed", "/blog", []string{"https://test.com/blog", "https://test.com/blog/2024/post", "https://test.com/blog/2025/post"}},
+		{"https://test.com/feed", "/", []string{"https://test.com", "https://test.com/blogging"}},
+	}
+	if feeds := site.Feeds(); !reflect.DeepEqual(feeds, expected) {
+		t.Errorf("Incorrect feeds: expected %v, got %v", expected, feeds)
+	}
+}
+
+func TestAddFeedLinks(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		feed     string
+		expected map[string][]Link
+	}{
+		{"rss", `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+<title>Blog</title><link>https://test.com/blog</link>
+<atom:link href="https://test.com/feed" rel="self" type="application/rss+xml"/>
+<item><title>First  post</title><link>https://test.com/blog/first</link></item>
+<item><title>Caf` + "\xe9" + `</title><link> /blog/cafe </link></item>
+<item><title>Elsewhere</title><link>https://other.com/post</link></item>
+</channel></rss>`, map[string][]Link{
+			"https://test.com/blog/first": {{"First post", LinkFeed}},
+			"https://test.com/blog/cafe":  {{"Café", LinkFeed}},
+		}},
+		{"atom", `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title><link href="https://test.com/blog"/>
+<entry><title type="html">Atom &amp; post</title><link rel="edit" href="/edit/1"/><link href="/blog/atom"/></entry>
+<entry><title>Enclosure</title><link rel="enclosure" href="/audio.mp3"/></entry>
+</feed>`, map[string][]Link{
+			"https://test.com/blog/atom": {{"Atom & post", LinkFeed}},
+		}},
+	}
+	for _, test := range tests {
+		page := createWebPage(t, "https://test.com", "")
+		if err := CreateDocumentParser().AddFeedLinks(page, "https://test.com/feed", strings.NewReader(test.feed)); err != nil {
+			t.Fatalf("Failed to read %s feed: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(page.InternalLinks, test.expected) {
+			t.Errorf("Incorrect links from %s feed: expected %v, got %v", test.name, test.expected, page.InternalLinks)
+		}
+	}
+}
+
+func TestCrawlFeedLinks(t *testing.T) {
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/feed.xml":
+			requests.Add(1)
+			rw.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprint(rw, `<rss><channel><item><title>Hidden</title><link>/hidden</link></item></channel></rss>`)
+		case "/", "/item/1", "/hidden":
+			rw.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(rw, `<html><head><link rel="alternate" type="application/rss+xml" href="/feed.xml"></head>
+<body><a href="/item/1">Item</a></body></html>`)
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer server.Close()
+
+	loader := CreateDocumentLoader(CreateDocumentParser())
+	loader.FeedLinks = true
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawlWithin(t, createTestCrawler(t, server, WithSink(siteMap), WithLoader(loader)))
+
+	expected := []string{server.URL, server.URL + "/hidden", server.URL + "/item/1"}
+	if urls := sortedKeys(siteMap.Pages); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Incorrect pages: expected %v, got %v", expected, urls)
+	}
+	if requests := requests.Load(); requests != 1 {
+		t.Errorf("Incorrect feed requests: expected 1, got %d", requests)
+	}
+	if links := siteMap.Pages[server.URL].InternalLinks[server.URL+"/hidden"]; len(links) != 1 || links[0] != (Link{"Hidden", LinkFeed}) {
+		t.Errorf("Incorrect link to the page in the feed: expected a feed link, got %v", links)
+	}
+}
@@ -0,0 +1,85 @@
+package sitemap
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+)
+
+// FrontierScore scores a URL queued for crawling, with URLs with lower scores loaded first. It is called with
+// the (absolute) URL and its depth (1 for the starting page) as the URL is queued, and is only called from a
+// single goroutine.
+type FrontierScore func(u *url.URL, depth int) float64
+
+// ScoreByDepth crawls the site breadth first, loading URLs closer to the starting page first
+func ScoreByDepth(u *url.URL, depth int) float64 {
+	return float64(depth)
+}
+
+// ScoreByPath loads URLs with fewer path segments first (e.g. /docs before /docs/intro), then shorter URLs
+// first, as pages higher in a site's hierarchy are usually the more important
+func ScoreByPath(u *url.URL, depth int) float64 {
+	path := strings.Trim(u.EscapedPath(), "/")
+	segments := 0
+	if len(path) != 0 {
+		segments = strings.Count(path, "/") + 1
+	}
+	length := len(path) + len(u.RawQuery)
+	return float64(segments) + float64(length)/(float64(length)+1)
+}
+
+// ParseFrontierScore converts a priority name (empty, depth or path) into a FrontierScore, returning nil for
+// the default (empty) order, in which URLs are loaded in the order they are found
+func ParseFrontierScore(name string) (FrontierScore, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return nil, nil
+	case "depth":
+		return ScoreByDepth, nil
+	case "path":
+		return ScoreByPath, nil
+	}
+	return nil, fmt.Errorf("unknown priority %q (expected depth or path)", name)
+}
+
+// scoredLink is a Hyperlink queued with its priority
+type scoredLink struct {
+	link  Hyperlink
+	score float64 // score of the link (lower is popped first)
+	seq   uint64  // order the link was pushed in, breaking ties between equal scores
+}
+
+// linkHeap is a min-heap of scored links (see container/heap)
+type linkHeap []scoredLink
+
+func (h linkHeap) Len() int { return len(h) }
+
+func (h linkHeap) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score < h[j].score
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h linkHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *linkHeap) Push(x any) { *h = append(*h, x.(scoredLink)) }
+
+func (h *linkHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// linkScorer adapts a FrontierScore to score queued links, scoring URLs which can't be parsed last
+func linkScorer(score FrontierScore) func(Hyperlink) float64 {
+	return func(link Hyperlink) float64 {
+		u, err := url.Parse(link.urlStr)
+		if err != nil {
+			return math.Inf(1)
+		}
+		return score(u, link.depth)
+	}
+}
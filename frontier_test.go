@@ -0,0 +1,63 @@
+package sitemap
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestScoreByPath(t *testing.T) {
+
+	urls := []string{"https://test.com/docs/intro/setup", "https://test.com/docs/api", "https://test.com/blog?page=2",
+		"https://test.com/docs", "https://test.com/", "https://test.com/a/b"}
+	sort.SliceStable(urls, func(i, j int) bool {
+		return ScoreByPath(mustParseURL(t, urls[i]), 1) < ScoreByPath(mustParseURL(t, urls[j]), 1)
+	})
+	expected := "[https://test.com/ https://test.com/docs https://test.com/blog?page=2 https://test.com/a/b " +
+		"https://test.com/docs/api https://test.com/docs/intro/setup]"
+	if got := fmt.Sprint(urls); got != expected {
+		t.Errorf("Incorrect order: expected %s, got %s", expected, got)
+	}
+}
+
+func TestParseFrontierScore(t *testing.T) {
+
+	for _, name := range []string{"depth", "Path"} {
+		if score, err := ParseFrontierScore(name); err != nil || score == nil {
+			t.Errorf("Incorrect result for %s: expected a score, got %v", name, err)
+		}
+	}
+	if score, err := ParseFrontierScore(""); err != nil || score != nil {
+		t.Errorf("Incorrect result for the default order: expected no score, got %v", err)
+	}
+	if _, err := ParseFrontierScore("random"); err == nil {
+		t.Errorf("Incorrect result for unknown priority: expected an error")
+	}
+}
+
+func TestCrawlPriorityMaxPages(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":      {"/a/b/c", "/a/b", "/a", "/d"},
+		"/a":     {"/a/1"},
+		"/a/b":   {},
+		"/a/b/c": {},
+		"/d":     {},
+		"/a/1":   {},
+	})
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithPriority(ScoreByPath), WithStableOrder(), WithMaxPages(3))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	// the first link found is loaded as soon as the loader is free, the rest in priority order
+	expected := fmt.Sprint([]string{server.URL, server.URL + "/a", server.URL + "/d"})
+	if got := fmt.Sprint(sortedKeys(siteMap.Pages)); got != expected {
+		t.Errorf("Incorrect pages crawled: expected %v, got %v", expected, got)
+	}
+	if frontier := crawler.Frontier(); len(frontier) != 3 {
+		t.Errorf("Incorrect number of URLs deferred: expected 3, got %v", frontier)
+	}
+}
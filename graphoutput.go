@@ -0,0 +1,281 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// graphAttribute is an attribute of the nodes or edges written by WriteGEXF and WriteGraphML, with its GEXF
+// type (integer, double or string)
+type graphAttribute struct {
+	Name string
+	Type string
+}
+
+// graphNodeAttributes are the attributes of each node, a page or a URL which failed to load. Attributes
+// which aren't known for a node (e.g. the depth of a page not reachable from the starting page) are omitted.
+var graphNodeAttributes = []graphAttribute{
+	{"url", "string"},      // URL of the page
+	{"title", "string"},    // HTML title, for pages
+	{"depth", "integer"},   // shortest number of links from the starting page
+	{"status", "integer"},  // HTTP status code the URL was loaded (or failed) with
+	{"pagerank", "double"}, // PageRank, for pages
+	{"inlinks", "integer"}, // number of pages linking to the URL
+	{"error", "string"},    // class of error, for URLs which failed to load
+}
+
+// graphEdgeAttributes are the attributes of each edge, the links from one page to another URL
+var graphEdgeAttributes = []graphAttribute{
+	{"anchor", "string"},  // distinct anchor texts of the links, separated by " | "
+	{"context", "string"}, // part of the page the first link is in: body, nav or footer
+}
+
+// graphValue is the value of an attribute of a node or edge
+type graphValue struct {
+	Name  string
+	Value string
+}
+
+// graphElement is a node or an edge of the crawl graph
+type graphElement struct {
+	ID     string
+	Label  string // label of a node, its title (or URL if it has none)
+	Source string // node the edge is from
+	Target string // node the edge is to
+	Weight int    // number of links the edge represents
+	Values []graphValue
+}
+
+// createCrawlGraph creates the graph of a crawl document, with a node for each page then each URL which
+// failed to load, and an edge for each pair of pages (or page and failed URL) linked. Links to URLs which
+// were not loaded (e.g. beyond a crawl limit) are not included.
+func createCrawlGraph(doc *CrawlDocument) (nodes []graphElement, edges []graphElement) {
+	ids := make(map[string]string, len(doc.Pages)+len(doc.Errors))
+	for i, record := range doc.Pages {
+		ids[record.URL] = "n" + strconv.Itoa(i+1)
+		for _, alias := range record.Aliases {
+			ids[alias] = ids[record.URL]
+		}
+	}
+	var failed []ErrorRecord
+	for _, record := range doc.Errors {
+		if _, found := ids[record.URL]; !found {
+			failed = append(failed, record)
+			ids[record.URL] = "n" + strconv.Itoa(len(doc.Pages)+len(failed))
+		}
+	}
+
+	for _, record := range doc.Pages {
+		node := graphElement{ID: ids[record.URL], Label: record.Title, Values: []graphValue{{"url", record.URL}, {"title", record.Title}}}
+		if len(node.Label) == 0 {
+			node.Label = record.URL
+		}
+		if record.Depth != nil {
+			node.Values = append(node.Values, graphValue{"depth", strconv.Itoa(*record.Depth)})
+		}
+		if record.Status != 0 {
+			node.Values = append(node.Values, graphValue{"status", strconv.Itoa(record.Status)})
+		}
+		node.Values = append(node.Values, graphValue{"pagerank", strconv.FormatFloat(record.PageRank, 'f', 6, 64)},
+			graphValue{"inlinks", strconv.Itoa(len(record.Inlinks))})
+		nodes = append(nodes, node)
+	}
+	for _, record := range failed {
+		node := graphElement{ID: ids[record.URL], Label: record.URL, Values: []graphValue{{"url", record.URL}}}
+		if record.Status != 0 {
+			node.Values = append(node.Values, graphValue{"status", strconv.Itoa(record.Status)})
+		}
+		node.Values = append(node.Values, graphValue{"inlinks", strconv.Itoa(len(record.Referrers))}, graphValue{"error", record.Class})
+		nodes = append(nodes, node)
+	}
+
+	for _, record := range doc.Pages {
+		anchors := make(map[string][]AnchorRecord)
+		for _, anchor := range record.Anchors {
+			anchors[anchor.URL] = append(anchors[anchor.URL], anchor)
+		}
+		for _, link := range record.Links {
+			target, found := ids[link]
+			if !found {
+				continue
+			}
+			edge := graphElement{ID: "e" + strconv.Itoa(len(edges)+1), Source: ids[record.URL], Target: target, Weight: max(len(anchors[link]), 1)}
+			var texts []string
+			seen := make(map[string]bool)
+			for _, anchor := range anchors[link] {
+				if len(anchor.Text) != 0 && !seen[anchor.Text] {
+					seen[anchor.Text] = true
+					texts = append(texts, anchor.Text)
+				}
+			}
+			if len(texts) != 0 {
+				edge.Values = append(edge.Values, graphValue{"anchor", strings.Join(texts, " | ")})
+			}
+			if len(anchors[link]) != 0 {
+				edge.Values = append(edge.Values, graphValue{"context", anchors[link][0].Context})
+			}
+			edges = append(edges, edge)
+		}
+	}
+	return nodes, edges
+}
+
+// gexfDocument is the root element of a GEXF (version 1.3) file
+type gexfDocument struct {
+	XMLName     xml.Name  `xml:"gexf"`
+	Namespace   string    `xml:"xmlns,attr"`
+	Version     string    `xml:"version,attr"`
+	Creator     string    `xml:"meta>creator"`
+	Description string    `xml:"meta>description"`
+	Graph       gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	EdgeType   string           `xml:"defaultedgetype,attr"`
+	Attributes []gexfAttributes `xml:"attributes"`
+	Nodes      []gexfElement    `xml:"nodes>node"`
+	Edges      []gexfElement    `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfElement struct {
+	ID     string      `xml:"id,attr"`
+	Label  string      `xml:"label,attr,omitempty"`
+	Source string      `xml:"source,attr,omitempty"`
+	Target string      `xml:"target,attr,omitempty"`
+	Weight int         `xml:"weight,attr,omitempty"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// WriteGEXF writes the link graph of a crawl document as GEXF, for network analysis tools such as Gephi.
+// Each page and each URL which failed to load is a node, with its URL, title, depth, status, PageRank,
+// inlinks and error class as attributes, and each pair of URLs linked is a directed edge, weighted by the
+// number of links and with their anchor text and context as attributes.
+func WriteGEXF(w io.Writer, doc *CrawlDocument) error {
+	nodes, edges := createCrawlGraph(doc)
+	gexf := gexfDocument{
+		Namespace:   "http://gexf.net/1.3",
+		Version:     "1.3",
+		Creator:     "go-sitemap",
+		Description: fmt.Sprintf("Crawl of %s", doc.Site),
+		Graph: gexfGraph{
+			EdgeType:   "directed",
+			Attributes: []gexfAttributes{{Class: "node"}, {Class: "edge"}},
+		},
+	}
+	for _, attribute := range graphNodeAttributes {
+		gexf.Graph.Attributes[0].Attributes = append(gexf.Graph.Attributes[0].Attributes, gexfAttribute{attribute.Name, attribute.Name, attribute.Type})
+	}
+	for _, attribute := range graphEdgeAttributes {
+		gexf.Graph.Attributes[1].Attributes = append(gexf.Graph.Attributes[1].Attributes, gexfAttribute{attribute.Name, attribute.Name, attribute.Type})
+	}
+	gexf.Graph.Nodes, gexf.Graph.Edges = gexfElements(nodes), gexfElements(edges)
+	return writeXMLDocument(w, gexf)
+}
+
+// gexfElements converts the nodes or edges of the crawl graph to GEXF elements
+func gexfElements(elements []graphElement) []gexfElement {
+	converted := make([]gexfElement, 0, len(elements))
+	for _, element := range elements {
+		gexf := gexfElement{ID: element.ID, Label: element.Label, Source: element.Source, Target: element.Target, Weight: element.Weight}
+		for _, value := range element.Values {
+			gexf.Values = append(gexf.Values, gexfValue{value.Name, value.Value})
+		}
+		converted = append(converted, gexf)
+	}
+	return converted
+}
+
+// graphMLDocument is the root element of a GraphML file
+type graphMLDocument struct {
+	XMLName   xml.Name     `xml:"graphml"`
+	Namespace string       `xml:"xmlns,attr"`
+	Keys      []graphMLKey `xml:"key"`
+	Graph     graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string           `xml:"id,attr"`
+	EdgeDefault string           `xml:"edgedefault,attr"`
+	Nodes       []graphMLElement `xml:"node"`
+	Edges       []graphMLElement `xml:"edge"`
+}
+
+type graphMLElement struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr,omitempty"`
+	Target string        `xml:"target,attr,omitempty"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the link graph of a crawl document as GraphML, for network analysis tools such as
+// Cytoscape. The nodes and edges are those written by WriteGEXF, with node labels in a label attribute and
+// edge weights in a weight attribute.
+func WriteGraphML(w io.Writer, doc *CrawlDocument) error {
+	nodes, edges := createCrawlGraph(doc)
+	graphML := graphMLDocument{
+		Namespace: "http://graphml.graphdrawing.org/xmlns",
+		Keys:      []graphMLKey{{"label", "node", "label", "string"}},
+		Graph:     graphMLGraph{ID: "crawl", EdgeDefault: "directed"},
+	}
+	for _, attribute := range graphNodeAttributes {
+		graphML.Keys = append(graphML.Keys, graphMLKey{attribute.Name, "node", attribute.Name, graphMLType(attribute.Type)})
+	}
+	graphML.Keys = append(graphML.Keys, graphMLKey{"weight", "edge", "weight", "int"})
+	for _, attribute := range graphEdgeAttributes {
+		graphML.Keys = append(graphML.Keys, graphMLKey{attribute.Name, "edge", attribute.Name, graphMLType(attribute.Type)})
+	}
+	for _, node := range nodes {
+		converted := graphMLElement{ID: node.ID, Data: []graphMLData{{"label", node.Label}}}
+		for _, value := range node.Values {
+			converted.Data = append(converted.Data, graphMLData{value.Name, value.Value})
+		}
+		graphML.Graph.Nodes = append(graphML.Graph.Nodes, converted)
+	}
+	for _, edge := range edges {
+		converted := graphMLElement{ID: edge.ID, Source: edge.Source, Target: edge.Target, Data: []graphMLData{{"weight", strconv.Itoa(edge.Weight)}}}
+		for _, value := range edge.Values {
+			converted.Data = append(converted.Data, graphMLData{value.Name, value.Value})
+		}
+		graphML.Graph.Edges = append(graphML.Graph.Edges, converted)
+	}
+	return writeXMLDocument(w, graphML)
+}
+
+// graphMLType returns the GraphML type of an attribute with a GEXF type
+func graphMLType(gexfType string) string {
+	if gexfType == "integer" {
+		return "int"
+	}
+	return gexfType
+}
@@ -0,0 +1,100 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// createGraphTestDocument creates a crawl document with two pages, one linking to the other (under an alias)
+// twice and to a URL which failed to load
+func createGraphTestDocument() *CrawlDocument {
+	depth := 0
+	return &CrawlDocument{SchemaVersion: JSONSchemaVersion, Site: "https://test.com", Pages: []PageRecord{
+		{URL: "https://test.com", Title: "Home & <Away>", Depth: &depth, Status: 200, PageRank: 0.5,
+			Links: []string{"https://test.com/a", "https://test.com/missing", "https://test.com/skipped"},
+			Anchors: []AnchorRecord{{"https://test.com/a", "About", "nav"}, {"https://test.com/a", "About", "footer"},
+				{"https://test.com/a", "More", "body"}, {"https://test.com/missing", "", "body"}}},
+		{URL: "https://test.com/about", Aliases: []string{"https://test.com/a"}, Status: 200, Inlinks: []string{"https://test.com"}},
+	}, Errors: []ErrorRecord{{URL: "https://test.com/missing", Class: "status", Status: 404, Error: "bad status code", Attempts: 1,
+		Referrers: []string{"https://test.com"}}}}
+}
+
+func TestCreateCrawlGraph(t *testing.T) {
+
+	nodes, edges := createCrawlGraph(createGraphTestDocument())
+	expectedNodes := []graphElement{
+		{ID: "n1", Label: "Home & <Away>", Values: []graphValue{{"url", "https://test.com"}, {"title", "Home & <Away>"},
+			{"depth", "0"}, {"status", "200"}, {"pagerank", "0.500000"}, {"inlinks", "0"}}},
+		{ID: "n2", Label: "https://test.com/about", Values: []graphValue{{"url", "https://test.com/about"}, {"title", ""},
+			{"status", "200"}, {"pagerank", "0.000000"}, {"inlinks", "1"}}},
+		{ID: "n3", Label: "https://test.com/missing", Values: []graphValue{{"url", "https://test.com/missing"}, {"status", "404"},
+			{"inlinks", "1"}, {"error", "status"}}},
+	}
+	if !reflect.DeepEqual(nodes, expectedNodes) {
+		t.Errorf("Incorrect nodes: expected %v, got %v", expectedNodes, nodes)
+	}
+	expectedEdges := []graphElement{
+		{ID: "e1", Source: "n1", Target: "n2", Weight: 3, Values: []graphValue{{"anchor", "About | More"}, {"context", "nav"}}},
+		{ID: "e2", Source: "n1", Target: "n3", Weight: 1, Values: []graphValue{{"context", "body"}}},
+	}
+	if !reflect.DeepEqual(edges, expectedEdges) {
+		t.Errorf("Incorrect edges: expected %v, got %v", expectedEdges, edges)
+	}
+}
+
+func TestWriteGEXF(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteGEXF(&buf, createGraphTestDocument()); err != nil {
+		t.Fatalf("Failed to write GEXF: %v", err)
+	}
+	var gexf gexfDocument
+	if err := xml.Unmarshal(buf.Bytes(), &gexf); err != nil {
+		t.Fatalf("Failed to parse GEXF: %v\n%s", err, buf.String())
+	}
+	if gexf.Version != "1.3" || gexf.Graph.EdgeType != "directed" || len(gexf.Graph.Attributes) != 2 {
+		t.Errorf("Incorrect GEXF graph: expected a directed graph with node and edge attributes, got %+v", gexf.Graph)
+	}
+	if len(gexf.Graph.Nodes) != 3 || gexf.Graph.Nodes[0].Label != "Home & <Away>" || len(gexf.Graph.Nodes[0].Values) != 6 {
+		t.Errorf("Incorrect GEXF nodes: expected 3 with the home page first, got %+v", gexf.Graph.Nodes)
+	}
+	expected := gexfElement{ID: "e1", Source: "n1", Target: "n2", Weight: 3, Values: []gexfValue{{"anchor", "About | More"}, {"context", "nav"}}}
+	if len(gexf.Graph.Edges) != 2 || !reflect.DeepEqual(gexf.Graph.Edges[0], expected) {
+		t.Errorf("Incorrect GEXF edges: expected 2 starting with %+v, got %+v", expected, gexf.Graph.Edges)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) || !strings.Contains(buf.String(), `<gexf xmlns="http://gexf.net/1.3" version="1.3">`) {
+		t.Errorf("Incorrect GEXF header: got\n%s", buf.String())
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteGraphML(&buf, createGraphTestDocument()); err != nil {
+		t.Fatalf("Failed to write GraphML: %v", err)
+	}
+	var graphML graphMLDocument
+	if err := xml.Unmarshal(buf.Bytes(), &graphML); err != nil {
+		t.Fatalf("Failed to parse GraphML: %v\n%s", err, buf.String())
+	}
+	keys := make(map[string]string)
+	for _, key := range graphML.Keys {
+		keys[key.ID] = fmt.Sprintf("%s %s", key.For, key.Type)
+	}
+	for id, expected := range map[string]string{"label": "node string", "depth": "node int", "pagerank": "node double", "weight": "edge int", "anchor": "edge string"} {
+		if keys[id] != expected {
+			t.Errorf("Incorrect GraphML key %s: expected %s, got %q", id, expected, keys[id])
+		}
+	}
+	if graphML.Graph.EdgeDefault != "directed" || len(graphML.Graph.Nodes) != 3 || len(graphML.Graph.Edges) != 2 {
+		t.Fatalf("Incorrect GraphML graph: expected a directed graph with 3 nodes and 2 edges, got %+v", graphML.Graph)
+	}
+	expected := []graphMLData{{"weight", "3"}, {"anchor", "About | More"}, {"context", "nav"}}
+	if edge := graphML.Graph.Edges[0]; edge.Source != "n1" || edge.Target != "n2" || !reflect.DeepEqual(edge.Data, expected) {
+		t.Errorf("Incorrect GraphML edge: expected n1 to n2 with %v, got %+v", expected, edge)
+	}
+}
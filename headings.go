@@ -0,0 +1,79 @@
+package sitemap
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html/atom"
+)
+
+// HeadingProblem is a problem with the H1 headings of a page
+type HeadingProblem int
+
+const (
+	HeadingMissing  HeadingProblem = iota // the page has no H1
+	HeadingMultiple                       // the page has more than one H1
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem HeadingProblem) messageKey() string {
+	if problem == HeadingMissing {
+		return "headings.missing"
+	}
+	return "headings.multiple"
+}
+
+// HeadingIssue is a page with a problem with its H1 headings
+type HeadingIssue struct {
+	URL      string         // URL of the page
+	Problem  HeadingProblem // problem found
+	Headings []string       // text of the page's H1 headings, in the order found
+}
+
+// headingCollector collects the text of a heading (plus the alt text of any images) until the heading ends
+type headingCollector struct {
+	tag  atom.Atom // H1 or H2
+	text strings.Builder
+}
+
+// startHeading starts collecting the text of an H1, or an H2 if those are recorded. Headings inside another
+// heading are part of its text.
+func (s *documentScanner) startHeading(tag atom.Atom) {
+	if s.heading == nil && (tag == atom.H1 || (tag == atom.H2 && s.p.H2)) {
+		s.heading = &headingCollector{tag: tag}
+	}
+}
+
+// endHeading records the heading collected if the element ending is the heading
+func (s *documentScanner) endHeading(tag atom.Atom) {
+	if s.heading == nil || s.heading.tag != tag {
+		return
+	}
+	text := collapseSpace(s.heading.text.String())
+	if tag == atom.H1 {
+		s.page.H1 = append(s.page.H1, text)
+	} else {
+		s.page.H2 = append(s.page.H2, text)
+	}
+	s.heading = nil
+}
+
+// HeadingIssues returns the pages with no H1 heading or more than one, sorted by URL. Soft 404 pages are not
+// checked.
+func (site *SiteMap) HeadingIssues() []HeadingIssue {
+	var issues []HeadingIssue
+	for _, page := range site.Pages {
+		if page.Soft404 {
+			continue
+		}
+		switch len(page.H1) {
+		case 0:
+			issues = append(issues, HeadingIssue{page.URL.String(), HeadingMissing, nil})
+		case 1:
+		default:
+			issues = append(issues, HeadingIssue{page.URL.String(), HeadingMultiple, page.H1})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].URL < issues[j].URL })
+	return issues
+}
@@ -0,0 +1,80 @@
+package sitemap
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeadings(t *testing.T) {
+
+	const doc = `<html><head><title>Test</title></head><body>
+<h1>  Welcome to
+	<span>the <em>site</em></span></h1>
+<h2>About</h2>
+<a href="/news"><h2>Latest <img src="/new.png" alt="new"> news</h2></a>
+<h1><img src="/logo.png" alt="Logo"></h1>
+<h3>Details</h3>
+<h2>Unclosed
+</body></html>`
+	tests := []struct {
+		h2       bool
+		expected []string
+	}{
+		{false, nil},
+		{true, []string{"About", "Latest new news", "Unclosed"}},
+	}
+	for _, test := range tests {
+		parser := CreateDocumentParser()
+		parser.H2 = test.h2
+		page, err := parser.ParseDocument("https://test.com", strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("Failed to parse document: %v", err)
+		}
+		if expected := []string{"Welcome to the site", "Logo"}; !reflect.DeepEqual(page.H1, expected) {
+			t.Errorf("Incorrect H1 headings: expected %q, got %q", expected, page.H1)
+		}
+		if !reflect.DeepEqual(page.H2, test.expected) {
+			t.Errorf("Incorrect H2 headings: expected %q, got %q", test.expected, page.H2)
+		}
+		if links := page.InternalLinks["https://test.com/news"]; len(links) != 1 || links[0].Text != "Latest new news" {
+			t.Errorf("Incorrect link around a heading: expected its text to be the heading, got %v", links)
+		}
+	}
+}
+
+func TestHeadingIssues(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	for urlStr, h1 := range map[string][]string{
+		"https://test.com":         {"Home"},
+		"https://test.com/a":       nil,
+		"https://test.com/b":       {"First", "Second"},
+		"https://test.com/missing": nil,
+	} {
+		page := createWebPage(t, urlStr, "")
+		page.H1 = h1
+		page.Soft404 = urlStr == "https://test.com/missing"
+		site.AddPage(page)
+	}
+	issues := site.HeadingIssues()
+	expected := []HeadingIssue{
+		{"https://test.com/a", HeadingMissing, nil},
+		{"https://test.com/b", HeadingMultiple, []string{"First", "Second"}},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Incorrect heading issues: expected %v, got %v", expected, issues)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintHeadingIssues(&buf, issues, nil); err != nil {
+		t.Fatalf("Failed to print heading issues: %v", err)
+	}
+	expectedReport := "\n\n ----- Pages with H1 heading problems (2) -----\n" +
+		" https://test.com/a: no H1 heading\n" +
+		" https://test.com/b: 2 H1 headings: \"First\", \"Second\"\n"
+	if buf.String() != expectedReport {
+		t.Errorf("Incorrect heading report: expected %q, got %q", expectedReport, buf.String())
+	}
+}
@@ -0,0 +1,92 @@
+package sitemap
+
+import (
+	"sort"
+	"strings"
+)
+
+// LanguageVariant is one language version of a page, declared with <link rel="alternate" hreflang="...">
+type LanguageVariant struct {
+	Lang string // language code in lower case (e.g. "en-gb"), or "x-default" for the fallback page
+	URL  string // absolute URL of the page in that language, which may be on another domain
+}
+
+// AddLanguage records a language variant of the page declared with hreflang. A later declaration for the
+// same language replaces an earlier one.
+func (page *WebPage) AddLanguage(lang string, urlStr string) {
+	if page.Languages == nil {
+		page.Languages = make(map[string]string)
+	}
+	page.Languages[strings.ToLower(lang)] = urlStr
+}
+
+// LanguageVariants returns the language variants declared by the page, sorted by language
+func (page *WebPage) LanguageVariants() []LanguageVariant {
+	var variants []LanguageVariant
+	for lang, urlStr := range page.Languages {
+		variants = append(variants, LanguageVariant{lang, urlStr})
+	}
+	sortLanguageVariants(variants)
+	return variants
+}
+
+// LanguageGroups groups the pages of the site which are language variants of each other. Pages are in the
+// same group if either declares the other as a variant (directly or through other pages), and each group
+// lists every variant declared by the pages in it, sorted by language then URL. Groups are sorted by the
+// URL of their first variant.
+func (site *SiteMap) LanguageGroups() [][]LanguageVariant {
+
+	// join the URLs of each page and its variants into groups
+	parent := make(map[string]string)
+	var find func(urlStr string) string
+	find = func(urlStr string) string {
+		if next, found := parent[urlStr]; found && next != urlStr {
+			root := find(next)
+			parent[urlStr] = root
+			return root
+		}
+		parent[urlStr] = urlStr
+		return urlStr
+	}
+	for key, page := range site.Pages {
+		for _, urlStr := range page.Languages {
+			parent[find(site.lookupKey(urlStr))] = find(key)
+		}
+	}
+
+	// then collect the variants declared within each group
+	variants := make(map[string]map[LanguageVariant]bool)
+	for key, page := range site.Pages {
+		if len(page.Languages) == 0 {
+			continue
+		}
+		root := find(key)
+		if variants[root] == nil {
+			variants[root] = make(map[LanguageVariant]bool)
+		}
+		for lang, urlStr := range page.Languages {
+			variants[root][LanguageVariant{lang, urlStr}] = true
+		}
+	}
+	groups := make([][]LanguageVariant, 0, len(variants))
+	for _, set := range variants {
+		group := make([]LanguageVariant, 0, len(set))
+		for variant := range set {
+			group = append(group, variant)
+		}
+		sortLanguageVariants(group)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0].URL < groups[j][0].URL })
+	return groups
+}
+
+// sortLanguageVariants sorts variants by language then URL
+func sortLanguageVariants(variants []LanguageVariant) {
+	sort.Slice(variants, func(i, j int) bool {
+		if variants[i].Lang != variants[j].Lang {
+			return variants[i].Lang < variants[j].Lang
+		}
+		return variants[i].URL < variants[j].URL
+	})
+}
@@ -0,0 +1,45 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLanguageGroups(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	variants := map[string]map[string]string{
+		"/en/about":    {"en": "https://test.com/en/about", "fr": "https://test.com/fr/a-propos"},
+		"/fr/a-propos": {"fr": "https://test.com/fr/a-propos", "en": "https://test.com/en/about", "de": "https://test.de/ueber"},
+		"/en/contact":  {"en": "https://test.com/en/contact", "x-default": "https://test.com/contact"},
+		"/contact":     {"x-default": "https://test.com/contact"},
+		"/blog":        nil,
+	}
+	for path, languages := range variants {
+		page := createWebPage(t, "https://test.com"+path, "Page "+path)
+		for lang, urlStr := range languages {
+			page.AddLanguage(lang, urlStr)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups := site.LanguageGroups()
+	expected := "[[{en https://test.com/en/contact} {x-default https://test.com/contact}] " +
+		"[{de https://test.de/ueber} {en https://test.com/en/about} {fr https://test.com/fr/a-propos}]]"
+	if got := fmt.Sprint(groups); got != expected {
+		t.Errorf("Incorrect language groups: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintLanguageGroups(&buf, groups, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Language variants (2 groups)", " x-default  https://test.com/contact\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Incorrect hreflang report: expected %q in %s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package sitemap
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+)
+
+// htmlTemplate renders a crawl document as a single HTML page with a table of the pages crawled
+var htmlTemplate = template.Must(template.New("sitemap").Funcs(template.FuncMap{
+	"depth": func(depth *int) string {
+		if depth == nil {
+			return "-"
+		}
+		return strconv.Itoa(*depth)
+	},
+	"rank": func(rank float64) string {
+		return strconv.FormatFloat(rank, 'f', 6, 64)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Site Map for {{.Site}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+td.number { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Site Map for {{.Site}}{{if .Truncated}} (truncated){{end}}</h1>
+<table>
+<tr><th>URL</th><th>Title</th><th>Depth</th><th>Links</th><th>Inlinks</th><th>PageRank</th></tr>
+{{- range .Pages}}
+<tr><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{.Title}}</td><td class="number">{{depth .Depth}}</td><td class="number">{{len .Links}}</td><td class="number">{{len .Inlinks}}</td><td class="number">{{rank .PageRank}}</td></tr>
+{{- end}}
+</table>
+{{- if .Errors}}
+<h2>Errors</h2>
+<table>
+<tr><th>URL</th><th>Error</th><th>Status</th><th>Attempts</th><th>Linked From</th></tr>
+{{- range .Errors}}
+<tr><td>{{.URL}}</td><td>{{.Class}}: {{.Error}}</td><td class="number">{{if .Status}}{{.Status}}{{end}}</td><td class="number">{{.Attempts}}</td><td>{{range $i, $referrer := .Referrers}}{{if $i}}<br>{{end}}<a href="{{$referrer}}">{{$referrer}}</a>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the pages in a crawl document as an HTML page, with a table listing each page's depth,
+// number of links in and out, and PageRank, then a table of the URLs which failed to load (if any)
+func WriteHTML(w io.Writer, doc *CrawlDocument) error {
+	return htmlTemplate.Execute(w, doc)
+}
@@ -0,0 +1,35 @@
+package sitemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	site := createQueryTestSite(t)
+	addPage(t, site, true, "https://test.com/<script>", "Tom & Jerry")
+	site.Truncated = true
+	site.Errors = []LoadFailure{{URL: "https://test.com/missing", Class: LoadErrorStatus, StatusCode: 404, Error: "bad status code",
+		Attempts: 1, Referrers: []string{"https://test.com/about"}}}
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, CreateCrawlDocument(site)); err != nil {
+		t.Fatalf("Failed to write HTML: %v", err)
+	}
+	for _, expected := range []string{
+		"<h1>Site Map for https://test.com (truncated)</h1>",
+		`<tr><td><a href="https://test.com/about">https://test.com/about</a></td><td>Page /about</td>` +
+			`<td class="number">1</td><td class="number">0</td><td class="number">2</td><td class="number">0.`,
+		`<td class="number">-</td>`,
+		"Tom &amp; Jerry",
+		`<tr><td>https://test.com/missing</td><td>status: bad status code</td><td class="number">404</td><td class="number">1</td>` +
+			`<td><a href="https://test.com/about">https://test.com/about</a></td></tr>`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Incorrect HTML: expected it to contain %s, got %s", expected, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Errorf("Incorrect HTML: page URL not escaped in %s", buf.String())
+	}
+}
@@ -1,48 +1,278 @@
-package main
+package sitemap
 
 import (
-	"container/list"
+	"bufio"
+	"container/heap"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 )
 
+// spillHeadSize is the number of items a queue spilling to disk keeps in memory before writing further
+// items to its spill file
+const spillHeadSize = 1000
+
 // Hyperlink is a type for storing a pages hyperlink and associated metadata on a queue for crawling
 type Hyperlink struct {
 	urlStr string
 	depth  int
 }
 
-// HyperlinkQueue is an an in-memory, thread-safe queue of Hyperlink entries.
+// HyperlinkQueue is an an in-memory, thread-safe queue of Hyperlink entries, held in a ring buffer (see
+// linkRing) so items don't need an allocation each.
+//
+// To limit memory use, the queue can be switched to spill to disk (see SpillToDisk), after which items beyond
+// the first spillHeadSize are written to a temporary file and read back as the queue is emptied.
 //
-// Note: We're using a linked list as a queue. This could be made more efficient using a more complex data structure
-// such as a list of arrays or a single array working as a ring buffer (with re-allocations as required)
+// Items are popped in the order they were pushed unless a priority is set (see SetPriority).
 type HyperlinkQueue struct {
-	queue list.List
+	queue linkRing
 	mutex sync.Mutex
+
+	// items ordered by priority, used in place of queue once a priority function is set
+	priority    func(Hyperlink) float64
+	prioritized linkHeap
+	pushed      uint64 // number of items pushed, used to pop items with equal priority in the order pushed
+
+	// spill file (nil until spilling) written to and read from with separate handles, and the number of
+	// items in it still to be read
+	spillWriter *bufio.Writer
+	spillReader *bufio.Reader
+	spillFiles  []*os.File
+	spilled     int
+
+	// items lost as the spill file couldn't be read, with the error reading it (see TakeLost)
+	lost    int
+	lostErr error
 }
 
 // Push pushes a new item onto the end of the queue
 func (q *HyperlinkQueue) Push(item Hyperlink) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
+	if q.spillWriter != nil && (q.spilled > 0 || q.memLen() >= spillHeadSize) {
+		// once items are spilled, later items must be too so they are popped in order
+		if _, err := fmt.Fprintf(q.spillWriter, "%d %s\n", item.depth, item.urlStr); err == nil {
+			q.spilled++
+			return
+		}
+	}
+	if q.priority != nil {
+		q.pushed++
+		heap.Push(&q.prioritized, scoredLink{item, q.priority(item), q.pushed})
+		return
+	}
 	q.queue.PushBack(item)
 }
 
+// SetPriority switches the queue to popping the item with the lowest score first, with items with equal
+// scores popped in the order they were pushed. Items already queued are reordered. Once spilling to disk,
+// items beyond the first spillHeadSize are still popped in the order they were pushed, after those in memory.
+func (q *HyperlinkQueue) SetPriority(score func(Hyperlink) float64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.priority = score
+	for item, found := q.queue.PopFront(); found; item, found = q.queue.PopFront() {
+		q.pushed++
+		heap.Push(&q.prioritized, scoredLink{item, score(item), q.pushed})
+	}
+}
+
+// memLen returns the number of items held in memory (the mutex must be held)
+func (q *HyperlinkQueue) memLen() int {
+	return q.queue.Len() + len(q.prioritized)
+}
+
 // Pop removes the top item from the queue (if present)
 // Returns the top item if present and a flag to indicate success
 func (q *HyperlinkQueue) Pop() (Hyperlink, bool) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
-	if q.queue.Len() == 0 {
+	if q.memLen() == 0 && q.spilled > 0 {
+		return q.popSpilled()
+	} else if q.memLen() == 0 {
 		return Hyperlink{}, false
 	}
-	f := q.queue.Front()
-	q.queue.Remove(f)
-	return f.Value.(Hyperlink), true
+	if len(q.prioritized) != 0 {
+		return heap.Pop(&q.prioritized).(scoredLink).link, true
+	}
+	return q.queue.PopFront()
 }
 
 // Len returns the number of items in the queue
 func (q *HyperlinkQueue) Len() int {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
-	return q.queue.Len()
+	return q.memLen() + q.spilled
+}
+
+// TakeLost returns the number of items lost since it was last called as the spill file couldn't be read, with
+// the error reading it, so the caller can account for items which will never be popped
+func (q *HyperlinkQueue) TakeLost() (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	lost, err := q.lost, q.lostErr
+	q.lost, q.lostErr = 0, nil
+	return lost, err
+}
+
+// SpillToDisk switches the queue to writing items beyond the first spillHeadSize to a temporary file in dir
+// (the default temporary directory if empty), to reduce its memory use. Call Close to remove the file.
+func (q *HyperlinkQueue) SpillToDisk(dir string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.spillWriter != nil {
+		return nil
+	}
+	writer, err := os.CreateTemp(dir, "go-sitemap-queue-*")
+	if err != nil {
+		return err
+	}
+	reader, err := os.Open(writer.Name())
+	if err != nil {
+		writer.Close()
+		os.Remove(writer.Name())
+		return err
+	}
+	q.spillFiles = []*os.File{writer, reader}
+	q.spillWriter, q.spillReader = bufio.NewWriter(writer), bufio.NewReader(reader)
+	return nil
+}
+
+// Spilling returns true if the queue has been switched to spill to disk
+func (q *HyperlinkQueue) Spilling() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.spillWriter != nil
+}
+
+// Close removes the spill file (if any), discarding any items in it. The queue can't be used afterwards.
+func (q *HyperlinkQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.spillFiles) == 0 {
+		return nil
+	}
+	for _, file := range q.spillFiles {
+		file.Close()
+	}
+	err := os.Remove(q.spillFiles[0].Name())
+	q.spillFiles, q.spillWriter, q.spillReader, q.spilled = nil, nil, nil, 0
+	return err
+}
+
+// popSpilled reads the next item from the spill file (the mutex must be held). If it can't be read, every
+// item still in the file is lost, and is counted as lost so it can be accounted for (see TakeLost).
+func (q *HyperlinkQueue) popSpilled() (Hyperlink, bool) {
+	err := q.spillWriter.Flush()
+	var line string
+	if err == nil {
+		line, err = q.spillReader.ReadString('\n')
+	}
+	if err != nil {
+		q.lost, q.lostErr = q.lost+q.spilled, err
+		q.spilled = 0
+		return Hyperlink{}, false
+	}
+	q.spilled--
+	depthStr, urlStr, _ := strings.Cut(strings.TrimSuffix(line, "\n"), " ")
+	depth, _ := strconv.Atoi(depthStr)
+	return Hyperlink{urlStr, depth}, true
+}
+
+// queuedDepths records the depth each URL waiting in the crawler's queue is to be loaded at, so a URL found
+// nearer the start once queued can be queued again at the smaller depth, with the deeper entry dropped when
+// it's dequeued. It is safe for concurrent use. The zero value records no URLs.
+type queuedDepths struct {
+	mutex  sync.Mutex
+	depths map[string]int
+}
+
+// Add records a URL being queued
+func (q *queuedDepths) Add(link Hyperlink) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.depths == nil {
+		q.depths = make(map[string]int)
+	}
+	q.depths[link.urlStr] = link.depth
+}
+
+// Shorten records a URL still waiting in the queue being queued again at a smaller depth, returning the depth
+// it was queued at. Returns false (recording nothing) if the URL isn't waiting at a greater depth.
+func (q *queuedDepths) Shorten(link Hyperlink) (int, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	depth, found := q.depths[link.urlStr]
+	if !found || depth <= link.depth {
+		return 0, false
+	}
+	q.depths[link.urlStr] = link.depth
+	return depth, true
+}
+
+// Take records a URL being taken from the queue to be loaded, returning false if the entry is out of date as
+// the URL was queued again at a smaller depth. URLs not recorded are always loaded.
+func (q *queuedDepths) Take(link Hyperlink) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	depth, found := q.depths[link.urlStr]
+	if found && depth < link.depth {
+		return false
+	}
+	delete(q.depths, link.urlStr)
+	return true
+}
+
+// minRingSize is the smallest buffer a linkRing allocates, and the size below which it isn't shrunk
+const minRingSize = 64
+
+// linkRing is a growable ring buffer of Hyperlinks used as a FIFO queue. The buffer doubles in size when full
+// and halves once it's a quarter full, so the memory held after a large backlog is emptied is released.
+// It isn't thread-safe. The zero value is an empty ring.
+type linkRing struct {
+	items []Hyperlink // buffer, whose length is 0 or a power of 2
+	head  int         // index of the first item in the buffer
+	count int         // number of items in the ring
+}
+
+// Len returns the number of items in the ring
+func (r *linkRing) Len() int {
+	return r.count
+}
+
+// PushBack adds an item to the end of the ring
+func (r *linkRing) PushBack(item Hyperlink) {
+	if r.count == len(r.items) {
+		r.resize(max(2*len(r.items), minRingSize))
+	}
+	r.items[(r.head+r.count)&(len(r.items)-1)] = item
+	r.count++
+}
+
+// PopFront removes the first item from the ring, returning it and a flag to indicate success
+func (r *linkRing) PopFront() (Hyperlink, bool) {
+	if r.count == 0 {
+		return Hyperlink{}, false
+	}
+	item := r.items[r.head]
+	r.items[r.head] = Hyperlink{} // so the URL can be garbage collected
+	r.head = (r.head + 1) & (len(r.items) - 1)
+	r.count--
+	if len(r.items) > minRingSize && r.count <= len(r.items)/4 {
+		r.resize(len(r.items) / 2)
+	}
+	return item, true
+}
+
+// resize moves the items into a new buffer of the given size (a power of 2 at least the number of items)
+func (r *linkRing) resize(size int) {
+	items := make([]Hyperlink, size)
+	if r.count != 0 {
+		n := copy(items, r.items[r.head:min(r.head+r.count, len(r.items))])
+		copy(items[n:], r.items[:r.count-n])
+	}
+	r.items, r.head = items, 0
 }
@@ -1,6 +1,8 @@
-package main
+package sitemap
 
 import (
+	"container/list"
+	"fmt"
 	"strconv"
 	"sync"
 	"testing"
@@ -163,3 +165,248 @@ func TestConcurrentQueueInterleave(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestQueueSpillToDisk(t *testing.T) {
+
+	q := HyperlinkQueue{}
+	q.Push(Hyperlink{"before", 1})
+	if err := q.SpillToDisk(t.TempDir()); err != nil {
+		t.Fatalf("Failed to spill queue to disk: %v", err)
+	}
+	total := spillHeadSize + 50
+	for i := 1; i < total; i++ {
+		q.Push(Hyperlink{"https://test.com/" + strconv.Itoa(i), i % 7})
+	}
+	if l := q.Len(); l != total || q.spilled != 50 {
+		t.Errorf("Incorrect length on spilled queue: expected %d (%d spilled), got %d (%d spilled)", total, 50, l, q.spilled)
+	}
+
+	// items are popped in order, reading the spilled items back once the rest are popped
+	for i := 0; i < total; i++ {
+		expected := Hyperlink{"before", 1}
+		if i > 0 {
+			expected = Hyperlink{"https://test.com/" + strconv.Itoa(i), i % 7}
+		}
+		if top, found := q.Pop(); !found || top != expected {
+			t.Fatalf("Pop returned incorrect result: expected (%v, true), got (%v, %v)", expected, top, found)
+		}
+		if i == spillHeadSize {
+			q.Push(Hyperlink{"last", 2}) // pushed while reading back
+		}
+	}
+	if top, found := q.Pop(); !found || top.urlStr != "last" {
+		t.Errorf(`Pop returned incorrect result: expected ("last", true), got (%s, %v)`, top.urlStr, found)
+	}
+	if _, found := q.Pop(); found {
+		t.Errorf("Pop from emptied queue returned an item")
+	}
+	if err := q.Close(); err != nil {
+		t.Errorf("Failed to remove spill file: %v", err)
+	}
+}
+
+func TestQueueSpillReadError(t *testing.T) {
+
+	q := HyperlinkQueue{}
+	if err := q.SpillToDisk(t.TempDir()); err != nil {
+		t.Fatalf("Failed to spill queue to disk: %v", err)
+	}
+	total := spillHeadSize + 50
+	for i := 0; i < total; i++ {
+		q.Push(Hyperlink{"https://test.com/" + strconv.Itoa(i), 1})
+	}
+	for i := 0; i < spillHeadSize; i++ {
+		q.Pop()
+	}
+	if lost, err := q.TakeLost(); lost != 0 || err != nil {
+		t.Errorf("Incorrect items lost before reading the spill file: expected (0, nil), got (%d, %v)", lost, err)
+	}
+
+	// the spilled items are lost once the file can't be read, and are only reported once
+	q.spillFiles[1].Close()
+	if top, found := q.Pop(); found {
+		t.Errorf("Pop returned an item from an unreadable spill file: %v", top)
+	}
+	if lost, err := q.TakeLost(); lost != 50 || err == nil {
+		t.Errorf("Incorrect items lost reading the spill file: expected (50, error), got (%d, %v)", lost, err)
+	}
+	if lost, err := q.TakeLost(); lost != 0 || err != nil {
+		t.Errorf("Incorrect items lost once reported: expected (0, nil), got (%d, %v)", lost, err)
+	}
+	if l := q.Len(); l != 0 {
+		t.Errorf("Incorrect length once spilled items are lost: expected 0, got %d", l)
+	}
+	q.Close()
+}
+
+func TestPriorityQueue(t *testing.T) {
+
+	q := HyperlinkQueue{}
+	q.Push(Hyperlink{"a", 3})
+	q.Push(Hyperlink{"b", 1})
+	q.SetPriority(func(link Hyperlink) float64 { return float64(link.depth) })
+	q.Push(Hyperlink{"c", 2})
+	q.Push(Hyperlink{"d", 1})
+	q.Push(Hyperlink{"e", 3})
+
+	if l := q.Len(); l != 5 {
+		t.Errorf("Incorrect length on queue: expected %d, got %d", 5, l)
+	}
+	got := ""
+	for top, found := q.Pop(); found; top, found = q.Pop() {
+		got += top.urlStr
+	}
+	if expected := "bdcae"; got != expected {
+		t.Errorf("Incorrect order popped: expected %s, got %s", expected, got)
+	}
+}
+
+func TestLinkRing(t *testing.T) {
+
+	var ring linkRing
+	pushed, popped := 0, 0
+	push := func(n int) {
+		for i := 0; i < n; i++ {
+			ring.PushBack(Hyperlink{strconv.Itoa(pushed), pushed})
+			pushed++
+		}
+	}
+	pop := func(n int) {
+		for i := 0; i < n; i++ {
+			item, found := ring.PopFront()
+			if !found || item.depth != popped {
+				t.Fatalf("Incorrect item popped: expected %d, got %v (%v)", popped, item, found)
+			}
+			popped++
+		}
+	}
+
+	// wrap around the buffer, then grow it while wrapped and shrink it again
+	push(50)
+	pop(40)
+	push(50)
+	if len(ring.items) != minRingSize {
+		t.Errorf("Incorrect buffer size: expected %d, got %d", minRingSize, len(ring.items))
+	}
+	push(1000)
+	if ring.Len() != 1060 || len(ring.items) != 2048 {
+		t.Errorf("Incorrect ring: expected 1060 items in 2048, got %d in %d", ring.Len(), len(ring.items))
+	}
+	pop(1000)
+	if len(ring.items) != 128 {
+		t.Errorf("Incorrect buffer size after popping: expected 128, got %d", len(ring.items))
+	}
+	pop(60)
+	if item, found := ring.PopFront(); found || ring.Len() != 0 {
+		t.Errorf("Incorrect result popping an empty ring: expected nothing, got %v with %d items", item, ring.Len())
+	}
+}
+
+// linkQueue is a queue of Hyperlinks, implemented by HyperlinkQueue and listQueue
+type linkQueue interface {
+	Push(item Hyperlink)
+	Pop() (Hyperlink, bool)
+}
+
+// listQueue is a queue held in a linked list, as HyperlinkQueue was before using a ring buffer, used as a
+// baseline by the benchmarks
+type listQueue struct {
+	queue list.List
+	mutex sync.Mutex
+}
+
+func (q *listQueue) Push(item Hyperlink) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.queue.PushBack(item)
+}
+
+func (q *listQueue) Pop() (Hyperlink, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.queue.Len() == 0 {
+		return Hyperlink{}, false
+	}
+	return q.queue.Remove(q.queue.Front()).(Hyperlink), true
+}
+
+// benchmarkQueue measures pushing then popping a batch of items, keeping a backlog queued as a crawl does
+func benchmarkQueue(b *testing.B, q linkQueue) {
+	links := make([]Hyperlink, 1000)
+	for i := range links {
+		links[i] = Hyperlink{"https://test.com/page/" + strconv.Itoa(i), i % 10}
+	}
+	for _, link := range links {
+		q.Push(link)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, link := range links {
+			q.Push(link)
+		}
+		for range links {
+			if _, found := q.Pop(); !found {
+				b.Fatal("Queue unexpectedly empty")
+			}
+		}
+	}
+}
+
+func BenchmarkQueue(b *testing.B) {
+	benchmarkQueue(b, &HyperlinkQueue{})
+}
+
+func BenchmarkQueueList(b *testing.B) {
+	benchmarkQueue(b, &listQueue{})
+}
+
+// benchmarkQueueConcurrent measures passing items through a queue from a number of producers to the same
+// number of consumers, as the crawler's goroutines queuing and loading URLs do
+func benchmarkQueueConcurrent(b *testing.B, newQueue func() linkQueue) {
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("%dx%d", workers, workers), func(b *testing.B) {
+			q := newQueue()
+			link := Hyperlink{"https://test.com/page", 1}
+			b.ReportAllocs()
+			b.ResetTimer()
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				items := b.N / workers
+				if w == 0 {
+					items += b.N % workers
+				}
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < items; i++ {
+						q.Push(link)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					for i := 0; i < items; {
+						if _, found := q.Pop(); found {
+							i++
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkQueueConcurrent(b *testing.B) {
+	benchmarkQueueConcurrent(b, func() linkQueue { return &HyperlinkQueue{} })
+}
+
+func BenchmarkQueueConcurrentList(b *testing.B) {
+	benchmarkQueueConcurrent(b, func() linkQueue { return &listQueue{} })
+}
+
+func BenchmarkQueuePriority(b *testing.B) {
+	q := &HyperlinkQueue{}
+	q.SetPriority(func(link Hyperlink) float64 { return float64(link.depth) })
+	benchmarkQueue(b, q)
+}
@@ -0,0 +1,232 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxManifestSize limits how much of a web app manifest is read
+const maxManifestSize = 1 << 20
+
+// ManifestProblem is a problem found with a web app manifest
+type ManifestProblem int
+
+const (
+	ManifestMissing    ManifestProblem = iota // the manifest could not be loaded
+	ManifestInvalid                           // the manifest is not a valid JSON object
+	ManifestNoName                            // neither name nor short_name is set
+	ManifestNoStartURL                        // start_url is not set
+	ManifestNoIcons                           // no icons are listed
+	ManifestBrokenIcon                        // an icon listed on the domain could not be loaded
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem ManifestProblem) messageKey() string {
+	switch problem {
+	case ManifestMissing:
+		return "manifest.missing"
+	case ManifestInvalid:
+		return "manifest.invalid"
+	case ManifestNoName:
+		return "manifest.name"
+	case ManifestNoStartURL:
+		return "manifest.starturl"
+	case ManifestNoIcons:
+		return "manifest.icons"
+	default:
+		return "manifest.icon"
+	}
+}
+
+// webAppManifest is the part of a web app manifest which is validated, see https://www.w3.org/TR/appmanifest/
+type webAppManifest struct {
+	Name      string `json:"name"`
+	ShortName string `json:"short_name"`
+	StartURL  string `json:"start_url"`
+	Icons     []struct {
+		Src string `json:"src"`
+	} `json:"icons"`
+}
+
+// ManifestCheck is the result of validating a web app manifest declared by the pages of a site
+type ManifestCheck struct {
+	URL      string            // URL of the manifest
+	Status   int               // HTTP status of the manifest, or AssetFailed if it could not be requested
+	Problems []ManifestProblem // problems found (none if the manifest is valid)
+	Detail   []string          // detail of each problem (e.g. the URL of a broken icon)
+}
+
+// IconAudit is the result of checking the favicon, icons and web app manifests of a site
+type IconAudit struct {
+	Favicon     string          // URL of /favicon.ico on the site
+	FaviconType string          // content type /favicon.ico was served with (empty if not found)
+	Status      int             // HTTP status of /favicon.ico, or AssetFailed if it could not be requested
+	Icons       []AssetUsage    // icons declared by pages, with their status, sorted by URL
+	Manifests   []ManifestCheck // web app manifests declared by pages, sorted by URL
+	NoIcon      []string        // pages declaring no icon, when /favicon.ico can't be used either (sorted)
+	NoManifest  []string        // pages declaring no manifest, when other pages do (sorted)
+}
+
+// FaviconValid checks if /favicon.ico was found and served as an image
+func (audit *IconAudit) FaviconValid() bool {
+	return audit.Status == http.StatusOK && strings.HasPrefix(audit.FaviconType, "image/")
+}
+
+// AddIcon records an icon declared by the page, unless it has already been recorded
+func (page *WebPage) AddIcon(urlStr string) {
+	for _, icon := range page.Icons {
+		if icon == urlStr {
+			return
+		}
+	}
+	page.Icons = append(page.Icons, urlStr)
+}
+
+// CheckIcons checks the site's /favicon.ico, along with the icons and web app manifests declared by its
+// pages (recorded when parsing with assets). Pages are reported as missing an icon if they declare none and
+// /favicon.ico is missing or not an image, and as missing a manifest if other pages declare one.
+func (loader *DocLoader) CheckIcons(site *SiteMap) *IconAudit {
+	audit := &IconAudit{}
+	if root, err := url.Parse(site.RootPage); err == nil {
+		audit.Favicon = root.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+		audit.Status, audit.FaviconType = loader.requestFavicon(audit.Favicon)
+	}
+
+	icons := make(map[string]*AssetUsage)
+	manifests := make(map[string]bool)
+	for _, page := range site.Pages {
+		for _, icon := range page.Icons {
+			if icons[icon] == nil {
+				icons[icon] = &AssetUsage{URL: icon, Status: loader.cachedAssetStatus(icon, true)}
+			}
+			icons[icon].Pages = append(icons[icon].Pages, page.URL.String())
+		}
+		if len(page.Manifest) != 0 {
+			manifests[page.Manifest] = true
+		}
+	}
+	for _, icon := range sortedKeys(icons) {
+		sort.Strings(icons[icon].Pages)
+		audit.Icons = append(audit.Icons, *icons[icon])
+	}
+	for _, manifest := range sortedKeys(manifests) {
+		audit.Manifests = append(audit.Manifests, loader.checkManifest(manifest))
+	}
+
+	for key, page := range site.Pages {
+		if len(page.Icons) == 0 && !audit.FaviconValid() {
+			audit.NoIcon = append(audit.NoIcon, key)
+		}
+		if len(page.Manifest) == 0 && len(manifests) != 0 {
+			audit.NoManifest = append(audit.NoManifest, key)
+		}
+	}
+	sort.Strings(audit.NoIcon)
+	sort.Strings(audit.NoManifest)
+	return audit
+}
+
+// requestFavicon requests a favicon, returning its status (or AssetFailed) and content type
+func (loader *DocLoader) requestFavicon(urlStr string) (int, string) {
+	resp, err := loader.Client.Get(urlStr)
+	if err != nil {
+		loader.logger.Debug("Favicon request failed", "url", urlStr, "error", err)
+		return AssetFailed, ""
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, ""
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return resp.StatusCode, contentType
+}
+
+// checkManifest loads and validates a web app manifest, checking the icons it lists on the same domain exist
+func (loader *DocLoader) checkManifest(urlStr string) ManifestCheck {
+	check := ManifestCheck{URL: urlStr, Status: AssetFailed}
+	resp, err := loader.get(urlStr)
+	if err != nil {
+		loader.logger.Debug("Manifest request failed", "url", urlStr, "error", err)
+		check.add(ManifestMissing, err.Error())
+		return check
+	}
+	defer resp.Body.Close()
+	if check.Status = resp.StatusCode; check.Status != http.StatusOK {
+		check.add(ManifestMissing, resp.Status)
+		return check
+	}
+	var manifest webAppManifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&manifest); err != nil {
+		check.add(ManifestInvalid, err.Error())
+		return check
+	}
+	if len(strings.TrimSpace(manifest.Name)) == 0 && len(strings.TrimSpace(manifest.ShortName)) == 0 {
+		check.add(ManifestNoName, "")
+	}
+	if len(strings.TrimSpace(manifest.StartURL)) == 0 {
+		check.add(ManifestNoStartURL, "")
+	}
+	if len(manifest.Icons) == 0 {
+		check.add(ManifestNoIcons, "")
+	}
+	base, _ := url.Parse(urlStr)
+	for _, icon := range manifest.Icons {
+		src, err := base.Parse(strings.TrimSpace(icon.Src))
+		if err != nil || !isSameSite(src, base) {
+			continue // only icons on the domain are checked
+		}
+		if status := loader.cachedAssetStatus(src.String(), true); status == AssetFailed || status >= http.StatusBadRequest {
+			check.add(ManifestBrokenIcon, src.String())
+		}
+	}
+	return check
+}
+
+// findings returns the audit findings for the problems found, see CollectFindings
+func (audit *IconAudit) findings() []Finding {
+	var findings []Finding
+	if !audit.FaviconValid() {
+		findings = append(findings, Finding{Check: "icons.favicon", URL: audit.Favicon, Detail: faviconDetail(audit)})
+	}
+	for _, icon := range audit.Icons {
+		if icon.Broken() {
+			for _, page := range icon.Pages {
+				findings = append(findings, Finding{Check: "icons.missing", URL: page, Detail: icon.URL})
+			}
+		}
+	}
+	for _, manifest := range audit.Manifests {
+		for i, problem := range manifest.Problems {
+			findings = append(findings, Finding{Check: problem.messageKey(), URL: manifest.URL, Detail: manifest.Detail[i]})
+		}
+	}
+	for _, page := range audit.NoIcon {
+		findings = append(findings, Finding{Check: "icons.none", URL: page})
+	}
+	for _, page := range audit.NoManifest {
+		findings = append(findings, Finding{Check: "manifest.page", URL: page})
+	}
+	return findings
+}
+
+// faviconDetail describes the status of /favicon.ico, along with its content type if it was found
+func faviconDetail(audit *IconAudit) string {
+	if audit.Status == AssetFailed {
+		return "failed"
+	} else if audit.Status != http.StatusOK {
+		return strconv.Itoa(audit.Status)
+	}
+	return strconv.Itoa(audit.Status) + " " + audit.FaviconType
+}
+
+// add records a problem found with a manifest
+func (check *ManifestCheck) add(problem ManifestProblem, detail string) {
+	check.Problems = append(check.Problems, problem)
+	check.Detail = append(check.Detail, detail)
+}
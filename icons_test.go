@@ -0,0 +1,123 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckIcons(t *testing.T) {
+
+	// mock server - no /favicon.ico, with a manifest missing its start_url and listing a missing icon
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/page":
+			rw.Header().Add("Content-Type", "text/html")
+			fmt.Fprint(rw, `<HTML><HEAD><link rel="shortcut icon" href="/icon.png"><link rel="apple-touch-icon" href="/touch.png">`+
+				`<link rel="manifest" href="/app.webmanifest"></HEAD></HTML>`)
+		case "/other":
+			rw.Header().Add("Content-Type", "text/html")
+			fmt.Fprint(rw, `<HTML><HEAD><title>Other</title></HEAD></HTML>`)
+		case "/app.webmanifest":
+			rw.Header().Add("Content-Type", "application/manifest+json")
+			fmt.Fprint(rw, `{"name": "Test", "icons": [{"src": "icon.png"}, {"src": "/missing-192.png"}, {"src": "https://cdn.other.com/a.png"}]}`)
+		case "/icon.png":
+			rw.Header().Add("Content-Type", "image/png")
+		default:
+			http.NotFound(rw, req)
+		}
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	parser := CreateDocumentParser()
+	parser.Assets = true
+	docLoader := CreateDocumentLoader(parser)
+	site := CreateSiteMap(mustParseURL(t, mockServer.URL))
+	for _, path := range []string{"/page", "/other"} {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	audit := docLoader.CheckIcons(site)
+	if audit.FaviconValid() || audit.Status != http.StatusNotFound {
+		t.Errorf("Incorrect favicon status: expected 404, got %d (%s)", audit.Status, audit.FaviconType)
+	}
+	expected := fmt.Sprintf("[{%[1]s/icon.png 200 [%[1]s/page]} {%[1]s/touch.png 404 [%[1]s/page]}]", mockServer.URL)
+	if got := fmt.Sprint(audit.Icons); got != expected {
+		t.Errorf("Incorrect icons: expected %s, got %s", expected, got)
+	}
+	expected = fmt.Sprintf("[{%[1]s/app.webmanifest 200 [%[2]d %[3]d] [ %[1]s/missing-192.png]}]", mockServer.URL,
+		ManifestNoStartURL, ManifestBrokenIcon)
+	if got := fmt.Sprint(audit.Manifests); got != expected {
+		t.Errorf("Incorrect manifests: expected %s, got %s", expected, got)
+	}
+	expected = fmt.Sprintf("[%s/other]", mockServer.URL)
+	if got := fmt.Sprint(audit.NoIcon); got != expected {
+		t.Errorf("Incorrect pages missing an icon: expected %s, got %s", expected, got)
+	}
+	if got := fmt.Sprint(audit.NoManifest); got != expected {
+		t.Errorf("Incorrect pages missing a manifest: expected %s, got %s", expected, got)
+	}
+
+	var checks []string
+	for _, finding := range CollectFindings(site, AuditInputs{Icons: audit}) {
+		if strings.HasPrefix(finding.Check, "icons.") || strings.HasPrefix(finding.Check, "manifest.") {
+			checks = append(checks, finding.Check)
+		}
+	}
+	expected = "[icons.favicon icons.missing manifest.starturl manifest.icon icons.none manifest.page]"
+	if got := fmt.Sprint(checks); got != expected {
+		t.Errorf("Incorrect icon findings: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintIconAudit(&buf, audit, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{mockServer.URL + "/favicon.ico (404): missing or not an image",
+		"broken icon: " + mockServer.URL + "/touch.png (404):", "     manifest has no start_url\n", "pages with no icon (1):"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Incorrect icon report: expected %q in %s", line, buf.String())
+		}
+	}
+}
+
+func TestCheckIconsFavicon(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/favicon.ico" {
+			rw.Header().Add("Content-Type", "image/x-icon")
+			return
+		}
+		rw.Header().Add("Content-Type", "text/html")
+		fmt.Fprint(rw, `<HTML><HEAD><title>Home</title></HEAD></HTML>`)
+	}))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	site := CreateSiteMap(mustParseURL(t, mockServer.URL))
+	page, err := docLoader.LoadURL(mockServer.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := site.AddPage(page); err != nil {
+		t.Fatal(err)
+	}
+
+	// pages without icons fall back to the favicon, and no pages need a manifest if none have one
+	audit := docLoader.CheckIcons(site)
+	if !audit.FaviconValid() || len(audit.NoIcon) != 0 || len(audit.NoManifest) != 0 {
+		t.Errorf("Incorrect icon audit: expected a valid favicon and no pages missing icons, got %+v", audit)
+	}
+	if findings := audit.findings(); len(findings) != 0 {
+		t.Errorf("Incorrect icon findings: expected none, got %v", findings)
+	}
+}
@@ -0,0 +1,316 @@
+package sitemap
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSONSchemaVersion is the version of the JSON output schema (schema/crawl.schema.json) written by
+// WriteJSON. This follows semantic versioning: minor versions only add optional fields, major versions
+// may remove or change existing fields.
+const JSONSchemaVersion = "1.23"
+
+// JSONSchema is the JSON schema describing the output of WriteJSON
+//
+//go:embed schema/crawl.schema.json
+var JSONSchema []byte
+
+// CrawlDocument is the top level JSON document written for a crawl. See schema/crawl.schema.json.
+type CrawlDocument struct {
+	SchemaVersion string       `json:"schemaVersion"`
+	Site          string       `json:"site"`
+	Domain        string       `json:"domain"`
+	Truncated     bool         `json:"truncated"`
+	Pages         []PageRecord `json:"pages"`
+	DepthStats    *DepthStats  `json:"depthStats,omitempty"`
+
+	// URLs which failed to load, sorted by URL
+	Errors []ErrorRecord `json:"errors,omitempty"`
+
+	// links out of the section of the site crawled (see WithRootPath), sorted by URL
+	BoundaryLinks []BoundaryRecord `json:"boundaryLinks,omitempty"`
+}
+
+// PageRecord is the JSON record written for each page. See schema/crawl.schema.json.
+type PageRecord struct {
+	URL          string            `json:"url"`
+	Title        string            `json:"title"`
+	Description  string            `json:"description,omitempty"`
+	H1           []string          `json:"h1,omitempty"`
+	H2           []string          `json:"h2,omitempty"`
+	SchemaTypes  []string          `json:"schemaTypes,omitempty"`
+	WordCount    int               `json:"wordCount,omitempty"`
+	Depth        *int              `json:"depth,omitempty"`
+	Links        []string          `json:"links"`
+	Canonical    string            `json:"canonical,omitempty"`
+	Aliases      []string          `json:"aliases,omitempty"`
+	Alternates   []string          `json:"alternates,omitempty"`
+	ContentHash  string            `json:"contentHash,omitempty"`
+	LastModified *time.Time        `json:"lastModified,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	Soft404      bool              `json:"soft404,omitempty"`
+	Status       int               `json:"status,omitempty"`
+	Protocol     string            `json:"protocol,omitempty"`
+	TLSVersion   string            `json:"tlsVersion,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Inlinks      []string          `json:"inlinks,omitempty"`
+	PageRank     float64           `json:"pageRank,omitempty"`
+	Anchors      []AnchorRecord    `json:"anchors,omitempty"`
+	Assets       []AssetRecord     `json:"assets,omitempty"`
+	Hints        []HintRecord      `json:"hints,omitempty"`
+	Languages    []LanguageRecord  `json:"languages,omitempty"`
+	Icons        []string          `json:"icons,omitempty"`
+	Manifest     string            `json:"manifest,omitempty"`
+	Breadcrumbs  []string          `json:"breadcrumbs,omitempty"`
+	Feeds        []string          `json:"feeds,omitempty"`
+	Videos       []VideoRecord     `json:"videos,omitempty"`
+}
+
+// AnchorRecord is the JSON record written for each occurrence of a link on a page. See
+// schema/crawl.schema.json.
+type AnchorRecord struct {
+	URL     string `json:"url"`
+	Text    string `json:"text"`
+	Context string `json:"context"`
+}
+
+// AssetRecord is the JSON record written for each static asset used by a page. See schema/crawl.schema.json.
+type AssetRecord struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+}
+
+// HintRecord is the JSON record written for each resource hint on a page. See schema/crawl.schema.json.
+type HintRecord struct {
+	URL    string `json:"url"`
+	Rel    string `json:"rel"`
+	Status int    `json:"status,omitempty"`
+}
+
+// LanguageRecord is the JSON record written for each language variant of a page. See schema/crawl.schema.json.
+type LanguageRecord struct {
+	Lang string `json:"lang"`
+	URL  string `json:"url"`
+}
+
+// VideoRecord is the JSON record written for each video on a page. See schema/crawl.schema.json.
+type VideoRecord struct {
+	ContentURL   string `json:"contentUrl,omitempty"`
+	PlayerURL    string `json:"playerUrl,omitempty"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+	Duration     int    `json:"duration,omitempty"`
+}
+
+// ErrorRecord is the JSON record written for each URL which failed to load. See schema/crawl.schema.json.
+type ErrorRecord struct {
+	URL       string   `json:"url"`
+	Class     string   `json:"class"`
+	Status    int      `json:"status,omitempty"`
+	Error     string   `json:"error"`
+	Attempts  int      `json:"attempts"`
+	Referrers []string `json:"referrers,omitempty"`
+}
+
+// BoundaryRecord is the JSON record written for each link out of the section of the site crawled. See
+// schema/crawl.schema.json.
+type BoundaryRecord struct {
+	URL       string   `json:"url"`
+	Referrers []string `json:"referrers"`
+}
+
+// CreatePageRecord creates the JSON record for a page. The depth, inlinks and PageRank are not set as they
+// are only known once the site map is complete.
+func CreatePageRecord(page *WebPage) PageRecord {
+	record := PageRecord{
+		URL:         page.URL.String(),
+		Title:       page.Title,
+		Description: page.Description,
+		H1:          page.H1,
+		H2:          page.H2,
+		SchemaTypes: page.SchemaTypes,
+		WordCount:   page.WordCount,
+		Links:       sortedKeys(page.InternalLinks),
+		Canonical:   page.Canonical,
+		Alternates:  page.Alternates,
+		ContentHash: page.ContentHash,
+		ETag:        page.ETag,
+		Metadata:    page.Metadata,
+		Icons:       page.Icons,
+		Manifest:    page.Manifest,
+		Breadcrumbs: page.Breadcrumbs,
+		Feeds:       page.Feeds,
+		Soft404:     page.Soft404,
+		Status:      page.StatusCode,
+		Protocol:    page.Protocol,
+		TLSVersion:  page.TLSVersion,
+	}
+	if !page.LastModified.IsZero() {
+		record.LastModified = &page.LastModified
+	}
+	if len(page.Aliases) != 0 {
+		record.Aliases = sortedKeys(page.Aliases)
+	}
+	for _, link := range record.Links {
+		for _, occurrence := range page.InternalLinks[link] {
+			record.Anchors = append(record.Anchors, AnchorRecord{link, occurrence.Text, occurrence.Context.String()})
+		}
+	}
+	for _, asset := range sortedKeys(page.Assets) {
+		record.Assets = append(record.Assets, AssetRecord{asset, page.Assets[asset]})
+	}
+	for _, hint := range page.Hints {
+		record.Hints = append(record.Hints, HintRecord{hint.URL, hint.Rel, hint.Status})
+	}
+	for _, variant := range page.LanguageVariants() {
+		record.Languages = append(record.Languages, LanguageRecord{variant.Lang, variant.URL})
+	}
+	for _, video := range page.Videos {
+		record.Videos = append(record.Videos, VideoRecord(video))
+	}
+	return record
+}
+
+// CreateWebPageFromRecord recreates a page from its JSON record. Details not included in the record, such
+// as the HTTP headers, are not set.
+func CreateWebPageFromRecord(record PageRecord) (*WebPage, error) {
+	pageURL, err := url.Parse(record.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL %s: %v", record.URL, err)
+	}
+	page := CreateWebPage(pageURL, record.Title)
+	for _, link := range record.Links {
+		page.InternalLinks[link] = nil
+	}
+	for _, anchor := range record.Anchors {
+		context, err := ParseLinkContext(anchor.Context)
+		if err != nil {
+			return nil, err
+		}
+		page.AddLink(anchor.URL, Link{anchor.Text, context})
+	}
+	for _, alias := range record.Aliases {
+		page.Aliases[alias] = true
+	}
+	for _, asset := range record.Assets {
+		page.AddAsset(asset.URL)
+		page.Assets[asset.URL] = asset.Status
+	}
+	for _, hint := range record.Hints {
+		page.Hints = append(page.Hints, ResourceHint{hint.URL, hint.Rel, hint.Status})
+	}
+	for _, variant := range record.Languages {
+		page.AddLanguage(variant.Lang, variant.URL)
+	}
+	for _, video := range record.Videos {
+		page.AddVideo(Video(video))
+	}
+	page.Icons, page.Manifest = record.Icons, record.Manifest
+	page.Breadcrumbs, page.Feeds = record.Breadcrumbs, record.Feeds
+	page.Description, page.H1, page.H2 = record.Description, record.H1, record.H2
+	page.SchemaTypes, page.WordCount = record.SchemaTypes, record.WordCount
+	page.Canonical = record.Canonical
+	page.Alternates = record.Alternates
+	page.ContentHash = record.ContentHash
+	if record.LastModified != nil {
+		page.LastModified = *record.LastModified
+	}
+	page.ETag = record.ETag
+	page.Soft404, page.StatusCode = record.Soft404, record.Status
+	page.Protocol, page.TLSVersion = record.Protocol, record.TLSVersion
+	page.Metadata = record.Metadata
+	return page, nil
+}
+
+// CreateCrawlDocument creates the JSON document for a site map, with pages sorted by URL. The depth
+// statistics use the DefaultDeepThreshold.
+func CreateCrawlDocument(site *SiteMap) *CrawlDocument {
+	doc := &CrawlDocument{
+		SchemaVersion: JSONSchemaVersion,
+		Site:          site.RootPage,
+		Domain:        site.Domain,
+		Truncated:     site.Truncated,
+		Pages:         make([]PageRecord, 0, len(site.Pages)),
+	}
+	depths := site.getMinimumHeights()
+	inlinks := site.Inlinks()
+	ranks := site.ComputePageRank()
+	for key, page := range site.Pages {
+		record := CreatePageRecord(page)
+		if depth, found := depths[key]; found {
+			record.Depth = &depth
+		}
+		record.Inlinks = inlinks[key]
+		record.PageRank = ranks[key]
+		doc.Pages = append(doc.Pages, record)
+	}
+	sort.Slice(doc.Pages, func(i, j int) bool { return doc.Pages[i].URL < doc.Pages[j].URL })
+	stats := site.DepthStats(DefaultDeepThreshold)
+	doc.DepthStats = &stats
+	for _, failure := range site.Errors {
+		doc.Errors = append(doc.Errors, ErrorRecord{failure.URL, failure.Class.String(), failure.StatusCode,
+			failure.Error, failure.Attempts, failure.Referrers})
+	}
+	for _, link := range site.Boundary {
+		doc.BoundaryLinks = append(doc.BoundaryLinks, BoundaryRecord{link.URL, link.Referrers})
+	}
+	return doc
+}
+
+// Select removes all pages from the document except those supplied
+func (doc *CrawlDocument) Select(pages []*WebPage) {
+	selected := make(map[string]bool, len(pages))
+	for _, page := range pages {
+		selected[page.URL.String()] = true
+	}
+	kept := doc.Pages[:0]
+	for _, record := range doc.Pages {
+		if selected[record.URL] {
+			kept = append(kept, record)
+		}
+	}
+	doc.Pages = kept
+}
+
+// LoadCrawlDocument reads a JSON crawl document (as written by WriteJSON) from a file. Documents written
+// with a different major schema version are rejected.
+func LoadCrawlDocument(fileName string) (*CrawlDocument, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var doc CrawlDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid crawl document %s: %v", fileName, err)
+	}
+	major, _, _ := strings.Cut(JSONSchemaVersion, ".")
+	if docMajor, _, _ := strings.Cut(doc.SchemaVersion, "."); docMajor != major {
+		return nil, fmt.Errorf("unsupported schema version %q in crawl document %s (expected %s.x)", doc.SchemaVersion, fileName, major)
+	}
+	return &doc, nil
+}
+
+// WriteJSON writes the crawl document as indented JSON to the supplied writer
+func WriteJSON(w io.Writer, doc *CrawlDocument) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// sortedKeys returns the keys of a map (usually a set of strings) in sorted order
+func sortedKeys[V any](set map[string]V) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,135 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Test the embedded schema is valid JSON and matches the version written
+func TestJSONSchema(t *testing.T) {
+	var schema struct {
+		Properties struct {
+			SchemaVersion struct {
+				Const string `json:"const"`
+			} `json:"schemaVersion"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(JSONSchema, &schema); err != nil {
+		t.Fatalf("Invalid JSON schema: %v", err)
+	}
+	if schema.Properties.SchemaVersion.Const != JSONSchemaVersion {
+		t.Errorf("Incorrect schema version: expected %s, got %s", JSONSchemaVersion, schema.Properties.SchemaVersion.Const)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	URL, err := url.Parse("https://test.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := CreateSiteMap(URL)
+	root := createWebPage(t, "https://test.com", "Home")
+	root.AddLink("https://test.com/b", Link{"B", LinkNav})
+	root.AddLink("https://test.com/a", Link{"A", LinkBody})
+	root.AddLink("https://test.com/a", Link{"More", LinkFooter})
+	root.ContentHash = "abc"
+	root.StatusCode = 200
+	if _, err := site.AddPage(root); err != nil {
+		t.Fatal(err)
+	}
+	addPage(t, site, true, "https://test.com/a", "A")
+	addPage(t, site, true, "https://test.com/orphan", "Orphan")
+	site.Truncated = true
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, CreateCrawlDocument(site)); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	var doc CrawlDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid JSON written: %v", err)
+	}
+	if doc.SchemaVersion != JSONSchemaVersion || doc.Domain != "test.com" || !doc.Truncated {
+		t.Errorf("Incorrect document header: %+v", doc)
+	}
+	var urls []string
+	for _, page := range doc.Pages {
+		urls = append(urls, page.URL)
+	}
+	expected := []string{"https://test.com", "https://test.com/a", "https://test.com/orphan"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Fatalf("Incorrect pages: expected %v, got %v", expected, urls)
+	}
+	home := doc.Pages[0]
+	if !reflect.DeepEqual(home.Links, []string{"https://test.com/a", "https://test.com/b"}) {
+		t.Errorf("Incorrect links: got %v", home.Links)
+	}
+	expectedAnchors := []AnchorRecord{
+		{"https://test.com/a", "A", "body"},
+		{"https://test.com/a", "More", "footer"},
+		{"https://test.com/b", "B", "nav"},
+	}
+	if !reflect.DeepEqual(home.Anchors, expectedAnchors) {
+		t.Errorf("Incorrect anchors: expected %v, got %v", expectedAnchors, home.Anchors)
+	}
+	if home.ContentHash != "abc" || home.Title != "Home" || home.Status != 200 {
+		t.Errorf("Incorrect page record: %+v", home)
+	}
+	if home.Depth == nil || *home.Depth != 0 {
+		t.Errorf("Incorrect depth for root page: %v", home.Depth)
+	}
+	if doc.Pages[1].Depth == nil || *doc.Pages[1].Depth != 1 {
+		t.Errorf("Incorrect depth for linked page: %v", doc.Pages[1].Depth)
+	}
+	if doc.Pages[2].Depth != nil {
+		t.Errorf("Incorrect depth for orphan page: expected none, got %d", *doc.Pages[2].Depth)
+	}
+}
+
+func TestLoadCrawlDocument(t *testing.T) {
+	site := createQueryTestSite(t)
+	for _, page := range site.Pages {
+		page.AddAsset("https://test.com/style.css")
+		page.Assets["https://test.com/logo.png"] = 404
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, CreateCrawlDocument(site)); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "crawl.json")
+	if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := LoadCrawlDocument(fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error loading crawl document: %v", err)
+	}
+	if !reflect.DeepEqual(doc, CreateCrawlDocument(site)) {
+		t.Errorf("Incorrect crawl document loaded: expected %+v, got %+v", CreateCrawlDocument(site), doc)
+	}
+
+	// pages can be recreated from their records
+	for _, record := range doc.Pages {
+		page, err := CreateWebPageFromRecord(record)
+		if err != nil {
+			t.Fatalf("Unexpected error creating page from record: %v", err)
+		}
+		if got := CreatePageRecord(page); !reflect.DeepEqual(got.Links, record.Links) || got.URL != record.URL || got.Title != record.Title ||
+			!reflect.DeepEqual(got.Assets, record.Assets) {
+			t.Errorf("Incorrect page created from record: expected %+v, got %+v", record, got)
+		}
+	}
+
+	// documents from an incompatible schema version are rejected
+	if err := os.WriteFile(fileName, []byte(`{"schemaVersion": "2.0", "pages": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCrawlDocument(fileName); err == nil {
+		t.Error("Missing expected error loading crawl document with an incompatible schema version")
+	}
+}
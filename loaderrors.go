@@ -0,0 +1,113 @@
+package sitemap
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Errors wrapped by the errors LoadURL returns, so failures can be classified (see ClassifyLoadError)
+var (
+	errUnsupportedType      = errors.New("unsupported content type")
+	errUnsupportedExtension = errors.New("unsupported file extension")
+	errOffsiteRedirect      = errors.New("redirected to another domain")
+	errTooManyRedirects     = fmt.Errorf("stopped after %d redirects", maxRedirects)
+	errByteBudget           = errors.New("download budget used up")
+)
+
+// LoadErrorClass is the kind of failure loading a URL
+type LoadErrorClass int
+
+const (
+	LoadErrorOther    LoadErrorClass = iota // any other failure, e.g. a page which couldn't be decoded or parsed
+	LoadErrorStatus                         // the server responded with an unsuccessful status code
+	LoadErrorTimeout                        // the request, or the whole load (see WithLoadTimeout), timed out
+	LoadErrorNetwork                        // the request failed, e.g. the DNS lookup, connection or TLS handshake
+	LoadErrorRedirect                       // a redirect loop, too many redirects or a redirect to another domain
+	LoadErrorNotHTML                        // the URL isn't an HTML document, from its content type or file extension
+)
+
+// String returns the name of the class
+func (class LoadErrorClass) String() string {
+	switch class {
+	case LoadErrorStatus:
+		return "status"
+	case LoadErrorTimeout:
+		return "timeout"
+	case LoadErrorNetwork:
+		return "network"
+	case LoadErrorRedirect:
+		return "redirect"
+	case LoadErrorNotHTML:
+		return "not-html"
+	default:
+		return "other"
+	}
+}
+
+// ClassifyLoadError returns the class of an error returned by LoadURL
+func ClassifyLoadError(err error) LoadErrorClass {
+	var statusErr *StatusError
+	var loopErr *RedirectLoopError
+	var timeoutErr interface{ Timeout() bool }
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return LoadErrorStatus
+	case errors.As(err, &loopErr) || errors.Is(err, errOffsiteRedirect) || errors.Is(err, errTooManyRedirects):
+		return LoadErrorRedirect
+	case errors.As(err, &timeoutErr) && timeoutErr.Timeout():
+		return LoadErrorTimeout
+	case errors.Is(err, errUnsupportedType) || errors.Is(err, errUnsupportedExtension):
+		return LoadErrorNotHTML
+	case errors.As(err, &urlErr):
+		return LoadErrorNetwork
+	}
+	return LoadErrorOther
+}
+
+// LoadFailure is a URL which failed to load during a crawl
+type LoadFailure struct {
+	URL        string         // URL requested
+	Class      LoadErrorClass // kind of failure
+	StatusCode int            // HTTP status code returned, for LoadErrorStatus (0 otherwise)
+	Error      string         // error from the last attempt
+	Attempts   int            // number of times loading the URL was attempted
+	Referrers  []string       // URLs of the pages linking to the URL, sorted (only set once added to a SiteMap)
+}
+
+// loadTimeoutError is returned when a load is abandoned by the crawler's watchdog (see WithLoadTimeout)
+type loadTimeoutError struct {
+	url     string
+	timeout time.Duration
+}
+
+func (e *loadTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %v loading URL (%v)", e.timeout, e.url)
+}
+
+// Timeout reports the error is a timeout
+func (e *loadTimeoutError) Timeout() bool { return true }
+
+// AddErrors records the URLs which failed to load during the crawl of the site (see Crawler.Errors), with
+// the pages in the site map linking to each, sorted by URL
+func (site *SiteMap) AddErrors(failures []LoadFailure) {
+	for _, failure := range failures {
+		failure.Referrers = site.referrers(failure.URL)
+		site.Errors = append(site.Errors, failure)
+	}
+	sort.Slice(site.Errors, func(i, j int) bool { return site.Errors[i].URL < site.Errors[j].URL })
+}
+
+// referrers returns the URLs of the pages in the site map linking to a URL, sorted
+func (site *SiteMap) referrers(urlStr string) []string {
+	referrers := make(map[string]bool)
+	for source := range site.inlinks[urlStr] {
+		if page, found := site.Pages[site.lookupKey(source)]; found {
+			referrers[page.URL.String()] = true
+		}
+	}
+	return sortedKeys(referrers)
+}
@@ -0,0 +1,89 @@
+package sitemap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClassifyLoadError(t *testing.T) {
+
+	tests := []struct {
+		err      error
+		expected LoadErrorClass
+	}{
+		{&StatusError{URL: "https://test.com", StatusCode: 500}, LoadErrorStatus},
+		{&RedirectLoopError{URL: "https://test.com"}, LoadErrorRedirect},
+		{fmt.Errorf("%w (%v) for URL (%v)", errOffsiteRedirect, "https://other.com", "https://test.com"), LoadErrorRedirect},
+		{&loadTimeoutError{"https://test.com", time.Second}, LoadErrorTimeout},
+		{fmt.Errorf("%w %v for URL (%v)", errUnsupportedType, "image/png", "https://test.com"), LoadErrorNotHTML},
+		{errors.New("failed to parse contents"), LoadErrorOther},
+	}
+	for _, test := range tests {
+		if got := ClassifyLoadError(test.err); got != test.expected {
+			t.Errorf("Incorrect class for %v: expected %v, got %v", test.err, test.expected, got)
+		}
+	}
+
+	// errors from the HTTP client
+	client := &http.Client{Timeout: time.Millisecond}
+	if _, err := client.Get("http://unknown.invalid/"); ClassifyLoadError(err) != LoadErrorNetwork && ClassifyLoadError(err) != LoadErrorTimeout {
+		t.Errorf("Incorrect class for %v: expected %v or %v, got %v", err, LoadErrorNetwork, LoadErrorTimeout, ClassifyLoadError(err))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://unknown.invalid/", nil)
+	if _, err := http.DefaultClient.Do(req); ClassifyLoadError(err) != LoadErrorTimeout {
+		t.Errorf("Incorrect class for %v: expected %v, got %v", err, LoadErrorTimeout, ClassifyLoadError(err))
+	}
+}
+
+func TestCrawlErrors(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":  {"/a", "/missing"},
+		"/a": {"/missing", "/gone"},
+	})
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	siteMap.AddErrors(crawler.Errors())
+
+	if len(siteMap.Errors) != 2 {
+		t.Fatalf("Incorrect number of errors: expected %v, got %v", 2, siteMap.Errors)
+	}
+	expected := fmt.Sprintf("{%s/gone status 404 1 [%s/a]} {%s/missing status 404 1 [%s %s/a]}", server.URL, server.URL, server.URL,
+		server.URL, server.URL)
+	got := ""
+	for i, failure := range siteMap.Errors {
+		if i != 0 {
+			got += " "
+		}
+		got += fmt.Sprintf("{%s %v %d %d %v}", failure.URL, failure.Class, failure.StatusCode, failure.Attempts, failure.Referrers)
+	}
+	if got != expected {
+		t.Errorf("Incorrect errors: expected %v, got %v", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintLoadErrors(&buf, siteMap.Errors[:1], nil); err != nil {
+		t.Fatalf("Failed to print errors: %v", err)
+	}
+	expectedReport := fmt.Sprintf("\n\n ----- URLs which failed to load (1) -----\n %s/gone: HTTP status 404, linked from %s/a\n", server.URL, server.URL)
+	if buf.String() != expectedReport {
+		t.Errorf("Incorrect errors report: expected %q, got %q", expectedReport, buf.String())
+	}
+
+	doc := CreateCrawlDocument(siteMap)
+	if len(doc.Errors) != 2 || doc.Errors[1].Class != "status" || doc.Errors[1].Status != 404 || len(doc.Errors[1].Referrers) != 2 {
+		t.Errorf("Incorrect errors in crawl document: got %+v", doc.Errors)
+	}
+}
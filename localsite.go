@@ -0,0 +1,138 @@
+package sitemap
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LocalSite serves a site from a local directory of files, such as the output of a static site generator
+// (e.g. Hugo or Jekyll's public/ or _site/ directory), so it can be mapped before it is deployed. It is an
+// http.RoundTripper, so is used as the transport of the loader's client with requests for URLs under the
+// base URL served from the directory. As on most static hosts, a directory is served by its index.html, a
+// URL without an extension by the matching .html file, and anything else is not found.
+type LocalSite struct {
+	Dir  string   // directory the site is served from
+	Base *url.URL // URL the site is deployed at, which the directory's root is served for
+}
+
+// CreateLocalSite creates a site serving the files in dir for the base URL
+func CreateLocalSite(dir string, base *url.URL) (*LocalSite, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &LocalSite{dir, base}, nil
+}
+
+// RoundTrip serves a request from the directory. Requests to other hosts fail, as only the site itself is
+// available.
+func (site *LocalSite) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.EqualFold(req.URL.Hostname(), site.Base.Hostname()) {
+		return nil, fmt.Errorf("%s is not part of the local site %s", req.URL.Redacted(), site.Base)
+	}
+	fileName, found := site.resolve(req.URL.Path)
+	if !found {
+		return site.response(req, http.StatusNotFound, "text/plain; charset=utf-8", io.NopCloser(strings.NewReader("404 page not found\n")), -1), nil
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	resp := site.response(req, http.StatusOK, contentType, file, info.Size())
+	resp.Header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	if req.Method == http.MethodHead {
+		file.Close()
+		resp.Body = http.NoBody
+	}
+	return resp, nil
+}
+
+// response creates the response to a request
+func (site *LocalSite) response(req *http.Request, status int, contentType string, body io.ReadCloser, length int64) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	if length >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: length,
+		Request:       req,
+	}
+}
+
+// resolve returns the name of the file a URL path is served from, and false if there isn't one
+func (site *LocalSite) resolve(urlPath string) (string, bool) {
+	clean := path.Clean("/" + urlPath)
+	if basePath := strings.TrimSuffix(site.Base.Path, "/"); len(basePath) != 0 {
+		if clean != basePath && !strings.HasPrefix(clean, basePath+"/") {
+			return "", false
+		}
+		clean = "/" + strings.TrimPrefix(strings.TrimPrefix(clean, basePath), "/")
+	}
+	fileName := filepath.Join(site.Dir, filepath.FromSlash(clean))
+	if info, err := os.Stat(fileName); err == nil && info.IsDir() {
+		fileName = filepath.Join(fileName, "index.html")
+	} else if err != nil && len(path.Ext(clean)) == 0 {
+		fileName += ".html"
+	}
+	if info, err := os.Stat(fileName); err != nil || info.IsDir() {
+		return "", false
+	}
+	return fileName, true
+}
+
+// PageURLs walks the directory returning the URLs of every HTML file in it, so pages which aren't linked to
+// are mapped too. An index.html file is given the URL of its directory.
+func (site *LocalSite) PageURLs() ([]string, error) {
+	var urls []string
+	err := filepath.WalkDir(site.Dir, func(fileName string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(fileName))
+		if entry.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+		rel, err := filepath.Rel(site.Dir, fileName)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if path.Base(rel) == "index.html" {
+			rel = strings.TrimSuffix(path.Dir(rel), ".")
+		}
+		pageURL := *site.Base
+		pageURL.Path = strings.TrimSuffix(path.Join("/", site.Base.Path, rel), "/")
+		pageURL.RawQuery, pageURL.Fragment = "", ""
+		urls = append(urls, pageURL.String())
+		return nil
+	})
+	return urls, err
+}
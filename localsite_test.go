@@ -0,0 +1,146 @@
+package sitemap
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createTestLocalSite creates a directory holding the files, mapped from their relative path to contents
+func createTestLocalSite(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLocalSiteRoundTrip(t *testing.T) {
+
+	dir := createTestLocalSite(t, map[string]string{
+		"index.html":      "home",
+		"about.html":      "about",
+		"blog/index.html": "blog",
+		"blog/post.html":  "post",
+		"css/site.css":    "body {}",
+	})
+	if err := os.Mkdir(filepath.Join(dir, "images"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	site, err := CreateLocalSite(dir, mustParseURL(t, "https://test.com/docs"))
+	if err != nil {
+		t.Fatalf("Failed to create local site: %v", err)
+	}
+	client := &http.Client{Transport: site}
+
+	tests := map[string]struct {
+		status      int
+		contentType string
+		body        string
+	}{
+		"/docs":              {http.StatusOK, "text/html; charset=utf-8", "home"},
+		"/docs/":             {http.StatusOK, "text/html; charset=utf-8", "home"},
+		"/docs/about":        {http.StatusOK, "text/html; charset=utf-8", "about"},
+		"/docs/about.html":   {http.StatusOK, "text/html; charset=utf-8", "about"},
+		"/docs/blog":         {http.StatusOK, "text/html; charset=utf-8", "blog"},
+		"/docs/blog/post":    {http.StatusOK, "text/html; charset=utf-8", "post"},
+		"/docs/css/site.css": {http.StatusOK, "text/css; charset=utf-8", "body {}"},
+		"/docs/images":       {http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		"/docs/missing":      {http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		"/about":             {http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		"/docs/../about":     {http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+	}
+	for path, test := range tests {
+		resp, err := client.Get("https://test.com" + path)
+		if err != nil {
+			t.Errorf("Failed to request %s: %v", path, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status || resp.Header.Get("Content-Type") != test.contentType || string(body) != test.body {
+			t.Errorf("Incorrect response for %s: expected %v %v %q, got %v %v %q", path, test.status, test.contentType,
+				test.body, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+		}
+		if resp.StatusCode == http.StatusOK && len(resp.Header.Get("Last-Modified")) == 0 {
+			t.Errorf("Incorrect response for %s: expected a Last-Modified header", path)
+		}
+	}
+
+	if _, err := client.Get("https://other.com/docs"); err == nil {
+		t.Errorf("Incorrect result requesting another host: expected an error, got none")
+	}
+	if _, err := CreateLocalSite(filepath.Join(dir, "about.html"), site.Base); err == nil {
+		t.Errorf("Incorrect result creating a site from a file: expected an error, got none")
+	}
+}
+
+func TestLocalSitePageURLs(t *testing.T) {
+
+	dir := createTestLocalSite(t, map[string]string{
+		"index.html":      "",
+		"about.htm":       "",
+		"blog/index.html": "",
+		"blog/post.html":  "",
+		"css/site.css":    "",
+	})
+	site, err := CreateLocalSite(dir, mustParseURL(t, "https://test.com/docs/"))
+	if err != nil {
+		t.Fatalf("Failed to create local site: %v", err)
+	}
+	urls, err := site.PageURLs()
+	if err != nil {
+		t.Fatalf("Failed to walk local site: %v", err)
+	}
+	expected := "[https://test.com/docs/about.htm https://test.com/docs/blog https://test.com/docs/blog/post.html https://test.com/docs]"
+	if fmt.Sprint(urls) != expected {
+		t.Errorf("Incorrect page URLs: expected %v, got %v", expected, urls)
+	}
+}
+
+func TestLocalSiteCrawl(t *testing.T) {
+
+	dir := createTestLocalSite(t, map[string]string{
+		"index.html":      `<html><head><title>Home</title></head><body><a href="/blog/">Blog</a></body></html>`,
+		"blog/index.html": `<html><head><title>Blog</title></head><body><a href="post.html">Post</a></body></html>`,
+		"blog/post.html":  `<html><head><title>Post</title></head><body><a href="https://other.com/">Other</a></body></html>`,
+		"orphan.html":     `<html><head><title>Orphan</title></head><body></body></html>`,
+	})
+	base := mustParseURL(t, "https://test.com")
+	site, err := CreateLocalSite(dir, base)
+	if err != nil {
+		t.Fatalf("Failed to create local site: %v", err)
+	}
+	urls, err := site.PageURLs()
+	if err != nil {
+		t.Fatalf("Failed to walk local site: %v", err)
+	}
+	loader := CreateDocumentLoader(CreateDocumentParser())
+	loader.Client.Transport = site
+	siteMap := CreateSiteMap(base)
+	crawler, err := CreateCrawler(base, WithThrottle(0), WithMaxPages(0), WithLoader(loader), WithSink(siteMap),
+		WithSeeds(urls...))
+	if err != nil {
+		t.Fatalf("Failed to create crawler: %v", err)
+	}
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+
+	expected := "[https://test.com https://test.com/blog https://test.com/blog/post.html https://test.com/orphan.html]"
+	if got := fmt.Sprint(sortedKeys(siteMap.Pages)); got != expected {
+		t.Errorf("Incorrect pages mapped: expected %v, got %v", expected, got)
+	}
+	if page, found := siteMap.Pages["https://test.com/blog/post.html"]; !found || page.Title != "Post" {
+		t.Errorf("Incorrect post page: expected the title %v, got %v", "Post", page)
+	}
+}
@@ -0,0 +1,99 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Logger interface used by the Crawler and DocLoader for all logging. Each method takes a message followed
+// by alternating key/value pairs for structured fields (e.g. "url", urlStr, "depth", 2).
+//
+// This is the same method set as *slog.Logger, so any slog logger can be used directly. Library users can
+// supply their own implementation to feed crawl logs into their own logging pipelines.
+//
+// The following field keys are used consistently:
+//		event		the kind of crawl event logged, one of the event constants (e.g. fetch-start)
+//		url			the URL being processed
+//		depth		the crawl depth of the URL
+//		duration	the time taken for an operation
+//		status		the HTTP status code returned
+//		error		the error which occurred
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Values of the event field logged for each crawl event, so log consumers can follow a crawl without parsing
+// the messages
+const (
+	eventFetchStart  = "fetch-start"  // a URL is about to be loaded
+	eventFetchFinish = "fetch-finish" // a page was loaded (or found unchanged since the previous crawl)
+	eventEnqueue     = "enqueue"      // a URL was queued to be loaded
+	eventSkip        = "skip"         // a URL wasn't queued (e.g. filtered or over the depth limit), or its page was discarded
+	eventError       = "error"        // a URL failed to load
+	eventHold        = "hold"         // requests to a host were held off as the server asked (e.g. with Retry-After)
+)
+
+// defaultLogger returns the logger used when none is supplied: the default slog logger, which writes
+// through the standard log package at Info level.
+func defaultLogger() Logger {
+	return slog.Default()
+}
+
+// ConfigureLogging sets up the default slog logger (used by the crawler and loader) for a log format: text,
+// writing through the standard log package (at Debug level if verbose), or json, writing every event
+// (including those only logged at Debug level) as a JSON object per line to w. With json, lines logged with
+// the standard log package are written as JSON objects too (see logLineWriter).
+func ConfigureLogging(format string, verbose bool, w io.Writer) error {
+	switch format {
+	case "text":
+		if verbose {
+			slog.SetLogLoggerLevel(slog.LevelDebug)
+		}
+	case "json":
+		handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+		slog.SetDefault(slog.New(handler))
+		log.SetFlags(0) // the handler adds the time
+		log.SetOutput(logLineWriter{handler})
+	default:
+		return fmt.Errorf("unknown log format %q (expected text or json)", format)
+	}
+	return nil
+}
+
+// logLevelPrefixes are the prefixes of lines logged with the standard log package giving their level
+var logLevelPrefixes = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"DEBUG: ", slog.LevelDebug}, {"INFO: ", slog.LevelInfo}, {"WARN: ", slog.LevelWarn},
+	{"ERROR: ", slog.LevelError}, {"FATAL: ", slog.LevelError},
+}
+
+// logLineWriter passes the lines logged with the standard log package (e.g. log.Printf) to a slog handler,
+// taking their level from a prefix such as "WARN: " (Info if there is none)
+type logLineWriter struct {
+	handler slog.Handler
+}
+
+// Write logs a line written by the standard log package
+func (w logLineWriter) Write(line []byte) (int, error) {
+	msg, level := strings.TrimSuffix(string(line), "\n"), slog.LevelInfo
+	for _, prefix := range logLevelPrefixes {
+		if strings.HasPrefix(msg, prefix.prefix) {
+			msg, level = msg[len(prefix.prefix):], prefix.level
+			break
+		}
+	}
+	if !w.handler.Enabled(context.Background(), level) {
+		return len(line), nil
+	}
+	return len(line), w.handler.Handle(context.Background(), slog.NewRecord(time.Now(), level, msg, 0))
+}
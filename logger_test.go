@@ -0,0 +1,82 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLogLineWriter(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := log.New(logLineWriter{slog.NewJSONHandler(&buf, nil)}, "", 0)
+	logger.Printf("WARN: Crawl truncated after %d pages", 10)
+	logger.Printf("Mapped site")
+	logger.Printf("DEBUG: not logged at the handler's level")
+
+	var entries []map[string]any
+	for scanner := bufio.NewScanner(&buf); scanner.Scan(); {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Incorrect number of lines logged: expected 2, got %v", entries)
+	}
+	if entries[0]["level"] != "WARN" || entries[0]["msg"] != "Crawl truncated after 10 pages" {
+		t.Errorf("Incorrect line for a warning: expected WARN with the prefix removed, got %v", entries[0])
+	}
+	if entries[1]["level"] != "INFO" || entries[1]["msg"] != "Mapped site" {
+		t.Errorf("Incorrect line without a prefix: expected INFO, got %v", entries[1])
+	}
+}
+
+func TestCrawlJSONLog(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":  {"/a", "/missing"},
+		"/a": {"/"},
+	})
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	crawlWithin(t, createTestCrawler(t, server, WithLogger(logger), WithSink(CreateSiteMap(mustParseURL(t, server.URL)))))
+
+	// each URL is queued and loaded, with the one missing failing to load
+	events := make(map[string][]string)
+	for scanner := bufio.NewScanner(&buf); scanner.Scan(); {
+		var entry struct {
+			Event string `json:"event"`
+			URL   string `json:"url"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		if path := strings.TrimPrefix(entry.URL, server.URL); len(path) == 0 && len(entry.Event) != 0 {
+			events[entry.Event] = append(events[entry.Event], "/")
+		} else if len(entry.Event) != 0 {
+			events[entry.Event] = append(events[entry.Event], path)
+		}
+	}
+	expected := map[string]string{
+		eventEnqueue:     "[/ /a /missing]",
+		eventFetchStart:  "[/ /a /missing]",
+		eventFetchFinish: "[/ /a]",
+		eventError:       "[/missing]",
+	}
+	for event, urls := range expected {
+		sort.Strings(events[event])
+		if got := fmt.Sprint(events[event]); got != urls {
+			t.Errorf("Incorrect URLs logged for %s: expected %s, got %s", event, urls, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package sitemap
+
+import (
+	"hash/fnv"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// memoryCheckInterval is how often memory use is checked when crawling with a memory threshold
+const memoryCheckInterval = time.Second
+
+// memoryMetrics are the runtime metrics used to find the memory use of the process: the total memory mapped
+// by the Go runtime, less the heap memory released back to the operating system
+var memoryMetrics = []string{"/memory/classes/total:bytes", "/memory/classes/heap/released:bytes"}
+
+// memoryInUse returns the memory (in bytes) currently used by the process, as seen by the Go runtime
+func memoryInUse() uint64 {
+	samples := []metrics.Sample{{Name: memoryMetrics[0]}, {Name: memoryMetrics[1]}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0 // not supported by this runtime
+	}
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// seenSet records the URLs the crawler has seen, with the smallest depth each was found at. URLs skipped
+// because of their depth (e.g. beyond the maximum crawl depth) are recorded as such, so they can be crawled if
+// found again nearer the start (see enqueueNewUrls). It starts as a map of the URLs themselves, which can be
+// compacted to a map of their 64 bit hashes to save memory on large crawls. The chance of two URLs having
+// the same hash (and so one being wrongly skipped) is negligible, at around 1 in 10^7 for a million URLs.
+type seenSet struct {
+	urls   map[string]int32 // depth of each URL, negated if it was skipped because of its depth
+	hashes map[uint64]int32 // as urls, by hash (nil until compacted)
+}
+
+// createSeenSet creates a set containing the supplied URLs (which were loaded by a previous crawl, so are
+// never crawled again)
+func createSeenSet(urls map[string]bool) *seenSet {
+	set := &seenSet{urls: make(map[string]int32, len(urls))}
+	for urlStr := range urls {
+		set.urls[urlStr] = 0
+	}
+	return set
+}
+
+// Add adds a URL found at a depth to the set
+func (set *seenSet) Add(urlStr string, depth int) {
+	set.record(urlStr, int32(depth))
+}
+
+// Skip adds a URL skipped because of the depth it was found at to the set
+func (set *seenSet) Skip(urlStr string, depth int) {
+	set.record(urlStr, -int32(depth))
+}
+
+// record sets the depth recorded for a URL
+func (set *seenSet) record(urlStr string, depth int32) {
+	if set.hashes != nil {
+		set.hashes[hashURL(urlStr)] = depth
+	} else {
+		set.urls[urlStr] = depth
+	}
+}
+
+// Lookup returns the depth a URL was found at and whether it was skipped because of its depth, if it is in
+// the set
+func (set *seenSet) Lookup(urlStr string) (depth int, skipped bool, found bool) {
+	var recorded int32
+	if set.hashes != nil {
+		recorded, found = set.hashes[hashURL(urlStr)]
+	} else {
+		recorded, found = set.urls[urlStr]
+	}
+	if recorded < 0 {
+		return int(-recorded), true, found
+	}
+	return int(recorded), false, found
+}
+
+// Contains checks if a URL is in the set
+func (set *seenSet) Contains(urlStr string) bool {
+	_, _, found := set.Lookup(urlStr)
+	return found
+}
+
+// Compact replaces the URLs in the set with their hashes
+func (set *seenSet) Compact() {
+	if set.hashes != nil {
+		return
+	}
+	set.hashes = make(map[uint64]int32, len(set.urls))
+	for urlStr, depth := range set.urls {
+		set.hashes[hashURL(urlStr)] = depth
+	}
+	set.urls = nil
+}
+
+// hashURL returns the 64 bit FNV-1a hash of a URL
+func hashURL(urlStr string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(urlStr))
+	return hash.Sum64()
+}
+
+// Memory use, as a fraction of the memory limit (see WithMaxMemory), at which the crawl responds
+const (
+	memorySpillLevel = 0.75 // switch to low memory mode (if not already switched by the memory threshold)
+	memoryPauseLevel = 0.9  // pause loading new pages until memory use falls
+)
+
+// monitorMemory checks the memory in use every memoryCheckInterval until done is closed (see checkMemory)
+func (c *Crawler) monitorMemory(done <-chan bool) {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	for {
+		c.checkMemory(memoryInUse())
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// checkMemory responds to the memory in use. The crawl switches to low memory mode (see LowMemory) the first
+// time it exceeds the memory threshold, or memorySpillLevel of the memory limit. Nearing the limit, new loads
+// are paused until memory use falls, and once the limit is reached (or memory use doesn't fall with nothing
+// left loading) the crawl is stopped, with the URLs not loaded recorded as for other limits.
+func (c *Crawler) checkMemory(inUse uint64) {
+	threshold := c.memoryThreshold
+	if spillAt := uint64(float64(c.maxMemory) * memorySpillLevel); spillAt > 0 && (threshold == 0 || threshold > spillAt) {
+		threshold = spillAt
+	}
+	if threshold > 0 && inUse > threshold && !c.lowMemory.Load() {
+		c.degrade(inUse, threshold)
+	}
+	if c.maxMemory == 0 || c.memoryStop.Load() {
+		return
+	}
+	pauseAt := uint64(float64(c.maxMemory) * memoryPauseLevel)
+	switch {
+	case inUse >= c.maxMemory || (inUse >= pauseAt && c.memoryPause.Load() && c.dispatched.Load() == 0):
+		c.logger.Warn("Memory limit reached, stopping the crawl", "inUse", inUse, "limit", c.maxMemory)
+		c.memoryStop.Store(true)
+	case inUse >= pauseAt:
+		if !c.memoryPause.Swap(true) {
+			c.logger.Warn("Memory use near the limit, pausing new loads", "inUse", inUse, "limit", c.maxMemory)
+		}
+		debug.FreeOSMemory() // so the next check sees the memory still in use
+	default:
+		if c.memoryPause.Swap(false) {
+			c.logger.Info("Memory use reduced, resuming loads", "inUse", inUse, "limit", c.maxMemory)
+		}
+	}
+}
+
+// degrade switches the crawl to low memory mode: the queue of URLs to load spills to disk, the set of URLs
+// seen is compacted (by enqueueNewUrls) and URLs are only passed to the loaders as they become free, rather
+// than buffered, so they stay in the disk backed queue
+func (c *Crawler) degrade(inUse uint64, threshold uint64) {
+	c.logger.Warn("Memory threshold exceeded, switching to low memory mode", "inUse", inUse, "threshold", threshold)
+	if err := c.urlQueue.SpillToDisk(c.spillDir); err != nil {
+		c.logger.Error("Failed to spill URL queue to disk", "error", err)
+	}
+	c.lowMemory.Store(true)
+	debug.FreeOSMemory()
+}
+
+// LowMemory returns true if the last crawl exceeded its memory threshold and switched to low memory mode
+func (c *Crawler) LowMemory() bool {
+	return c.lowMemory.Load()
+}
+
+// MemoryLimited returns true if the memory use of the last crawl reached its memory limit (see WithMaxMemory),
+// after which no more pages were loaded
+func (c *Crawler) MemoryLimited() bool {
+	return c.memoryStop.Load()
+}
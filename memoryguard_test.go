@@ -0,0 +1,139 @@
+package sitemap
+
+import (
+	"testing"
+)
+
+func TestSeenSetCompact(t *testing.T) {
+
+	set := createSeenSet(map[string]bool{"https://test.com/a": true})
+	set.Add("https://test.com/b", 2)
+	set.Compact()
+	set.Add("https://test.com/c", 3)
+	for _, urlStr := range []string{"https://test.com/a", "https://test.com/b", "https://test.com/c"} {
+		if !set.Contains(urlStr) {
+			t.Errorf("Incorrect result for %v: expected it to be seen", urlStr)
+		}
+	}
+	if set.Contains("https://test.com/d") || set.urls != nil {
+		t.Errorf("Incorrect compacted set: expected only hashes of the URLs added")
+	}
+}
+
+func TestSeenSetDepths(t *testing.T) {
+
+	set := createSeenSet(map[string]bool{"https://test.com/a": true})
+	set.Add("https://test.com/b", 2)
+	set.Skip("https://test.com/c", 5)
+	for _, compacted := range []bool{false, true} {
+		if compacted {
+			set.Compact()
+		}
+		tests := []struct {
+			url     string
+			depth   int
+			skipped bool
+			found   bool
+		}{
+			{"https://test.com/a", 0, false, true},
+			{"https://test.com/b", 2, false, true},
+			{"https://test.com/c", 5, true, true},
+			{"https://test.com/d", 0, false, false},
+		}
+		for _, test := range tests {
+			depth, skipped, found := set.Lookup(test.url)
+			if depth != test.depth || skipped != test.skipped || found != test.found {
+				t.Errorf("Incorrect lookup of %s (compacted %v): expected %d %v %v, got %d %v %v", test.url, compacted,
+					test.depth, test.skipped, test.found, depth, skipped, found)
+			}
+		}
+	}
+	set.Add("https://test.com/c", 3)
+	if depth, skipped, _ := set.Lookup("https://test.com/c"); depth != 3 || skipped {
+		t.Errorf("Incorrect lookup once skipped URL queued: expected depth 3 not skipped, got %d %v", depth, skipped)
+	}
+}
+
+func TestCrawlLowMemory(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":    {"/a", "/b"},
+		"/a":   {"/", "/b", "/a/1"},
+		"/b":   {"/a/2"},
+		"/a/1": {"/a"},
+		"/a/2": {},
+	})
+	defer server.Close()
+
+	// any memory use exceeds a threshold of 1 byte, so the crawl switches to low memory mode immediately
+	site := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(site), WithMemoryThreshold(1, t.TempDir()))
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if !crawler.LowMemory() {
+		t.Errorf("Incorrect mode: expected the crawl to switch to low memory mode")
+	}
+	if len(site.Pages) != 5 {
+		t.Errorf("Incorrect pages crawled: expected %v, got %v", 5, sortedKeys(site.Pages))
+	}
+}
+
+func TestCheckMemory(t *testing.T) {
+
+	server := createTestSite(map[string][]string{"/": {}})
+	defer server.Close()
+	crawler := createTestCrawler(t, server, WithMemoryThreshold(0, t.TempDir()), WithMaxMemory(1000))
+	defer crawler.urlQueue.Close()
+
+	tests := []struct {
+		inUse   uint64
+		low     bool // expected low memory mode
+		paused  bool // expected loads paused
+		stopped bool // expected crawl stopped
+	}{
+		{100, false, false, false},
+		{800, true, false, false}, // over 75% of the limit, so spills to disk
+		{950, true, true, false},  // over 90%
+		{500, true, false, false}, // memory use fell
+		{950, true, true, false},
+		{960, true, true, true}, // still over 90% with nothing loading
+	}
+	for _, test := range tests {
+		crawler.checkMemory(test.inUse)
+		if crawler.LowMemory() != test.low || crawler.memoryPause.Load() != test.paused || crawler.MemoryLimited() != test.stopped {
+			t.Errorf("Incorrect state at %d bytes: expected low memory %v, paused %v and stopped %v, got %v, %v and %v",
+				test.inUse, test.low, test.paused, test.stopped, crawler.LowMemory(), crawler.memoryPause.Load(), crawler.MemoryLimited())
+		}
+	}
+
+	crawler = createTestCrawler(t, server, WithMaxMemory(1000))
+	crawler.checkMemory(1000)
+	if !crawler.MemoryLimited() {
+		t.Errorf("Incorrect state at the limit: expected the crawl to be stopped")
+	}
+}
+
+func TestCrawlMaxMemory(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":    {"/a", "/b"},
+		"/a":   {"/", "/b", "/a/1"},
+		"/b":   {"/a/2"},
+		"/a/1": {"/a"},
+		"/a/2": {},
+	})
+	defer server.Close()
+
+	// any memory use exceeds a limit of 1 byte, so the crawl stops immediately, leaving its URLs to be resumed
+	site := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(site), WithMemoryThreshold(0, t.TempDir()), WithMaxMemory(1))
+	crawlWithin(t, crawler)
+	if !crawler.MemoryLimited() {
+		t.Errorf("Incorrect result: expected the crawl to reach its memory limit")
+	}
+	if len(site.Pages) == 5 || len(crawler.Frontier()) == 0 {
+		t.Errorf("Incorrect crawl: expected it to stop with URLs in the frontier, got pages %v and frontier %v",
+			sortedKeys(site.Pages), crawler.Frontier())
+	}
+}
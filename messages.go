@@ -0,0 +1,90 @@
+package sitemap
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the locale reports are written in unless another is requested. Its catalog must
+// contain every message, as it is used for any message missing from other catalogs.
+const DefaultLocale = "en"
+
+// localeFiles holds the message catalogs, one JSON file per locale mapping message keys to fmt formats
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Catalog holds the report messages for a single locale
+type Catalog struct {
+	Locale   string            // locale of the catalog (e.g. fr)
+	messages map[string]string // message key to fmt format string
+	fallback *Catalog          // catalog used for messages not in this one (nil for the default locale)
+}
+
+// Locales returns the locales with an embedded message catalog, in sorted order
+func Locales() []string {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		return []string{DefaultLocale}
+	}
+	var locales []string
+	for _, entry := range entries {
+		locales = append(locales, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// LoadCatalog loads the message catalog for a locale such as "fr" or "fr-CA", falling back to the base
+// language if there is no catalog for the region. An error is returned if the locale is not supported.
+func LoadCatalog(locale string) (*Catalog, error) {
+	defaultCatalog, err := readCatalog(DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if len(locale) == 0 || locale == DefaultLocale {
+		return defaultCatalog, nil
+	}
+	for _, name := range []string{locale, strings.SplitN(locale, "-", 2)[0]} {
+		if catalog, err := readCatalog(name); err == nil {
+			catalog.fallback = defaultCatalog
+			return catalog, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported locale %q, expected one of %s", locale, strings.Join(Locales(), ", "))
+}
+
+// readCatalog reads the embedded catalog for a single locale
+func readCatalog(locale string) (*Catalog, error) {
+	data, err := localeFiles.ReadFile(path.Join("locales", locale+".json"))
+	if err != nil {
+		return nil, err
+	}
+	catalog := &Catalog{Locale: locale}
+	if err := json.Unmarshal(data, &catalog.messages); err != nil {
+		return nil, fmt.Errorf("invalid message catalog for locale %s: %v", locale, err)
+	}
+	return catalog, nil
+}
+
+// Sprintf formats the message with the supplied key. A nil Catalog uses the default locale, and the key
+// itself is returned if no catalog contains the message.
+func (c *Catalog) Sprintf(key string, args ...any) string {
+	if c == nil {
+		c = defaultCatalog
+	}
+	for catalog := c; catalog != nil; catalog = catalog.fallback {
+		if format, found := catalog.messages[key]; found {
+			return fmt.Sprintf(format, args...)
+		}
+	}
+	return key
+}
+
+// defaultCatalog is the catalog used when none is supplied
+var defaultCatalog, _ = readCatalog(DefaultLocale)
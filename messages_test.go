@@ -0,0 +1,77 @@
+package sitemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Test every catalog has the same messages, with the same arguments, as the default catalog
+func TestCatalogsComplete(t *testing.T) {
+	defaultCatalog, err := LoadCatalog(DefaultLocale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, locale := range Locales() {
+		catalog, err := readCatalog(locale)
+		if err != nil {
+			t.Fatalf("Failed to read catalog %s: %v", locale, err)
+		}
+		for key, format := range defaultCatalog.messages {
+			translated, found := catalog.messages[key]
+			if !found {
+				t.Errorf("Missing message %s in catalog %s", key, locale)
+			} else if strings.Count(translated, "%") != strings.Count(format, "%") {
+				t.Errorf("Incorrect arguments for message %s in catalog %s: expected %q, got %q", key, locale, format, translated)
+			}
+		}
+		if len(catalog.messages) != len(defaultCatalog.messages) {
+			t.Errorf("Incorrect number of messages in catalog %s: expected %d, got %d", locale, len(defaultCatalog.messages), len(catalog.messages))
+		}
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	tests := map[string]string{"": "en", "en": "en", "fr": "fr", "fr_CA": "fr", "DE-at": "de"}
+	for locale, expected := range tests {
+		catalog, err := LoadCatalog(locale)
+		if err != nil {
+			t.Fatalf("Unexpected error loading locale %s: %v", locale, err)
+		}
+		if catalog.Locale != expected {
+			t.Errorf("Incorrect catalog for locale %s: expected %s, got %s", locale, expected, catalog.Locale)
+		}
+	}
+	if _, err := LoadCatalog("xx"); err == nil {
+		t.Errorf("Missing expected error for unsupported locale")
+	}
+
+	// unknown messages fall back to the default catalog, then the key
+	catalog, _ := LoadCatalog("fr")
+	delete(catalog.messages, "site.truncated")
+	if got := catalog.Sprintf("site.truncated"); got != " (truncated)" {
+		t.Errorf("Incorrect fallback message: expected %q, got %q", " (truncated)", got)
+	}
+	if got := catalog.Sprintf("no.such.message"); got != "no.such.message" {
+		t.Errorf("Incorrect missing message: expected %q, got %q", "no.such.message", got)
+	}
+}
+
+// Test reports are written in the requested language
+func TestPrintSiteLocalized(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	addPage(t, site, true, "https://test.com", "Home")
+	site.Truncated = true
+	catalog, err := LoadCatalog("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := PrintSite(&buf, "https://test.com", site, TextOptions{Version: TextVersion1, Messages: catalog}); err != nil {
+		t.Fatalf("Failed to print site: %v", err)
+	}
+	expected := "----- Plan du site web  https://test.com (incomplet) -----"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Incorrect localized header: expected %q in\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,108 @@
+package sitemap
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Longest title and meta description (in characters) search engines display in full in their results, longer
+// ones being truncated
+const (
+	MaxTitleLength       = 60
+	MaxDescriptionLength = 160
+)
+
+// MetaProblem is a problem with the titles or meta descriptions of pages
+type MetaProblem int
+
+const (
+	TitleMissing         MetaProblem = iota // the page has no title
+	TitleDuplicate                          // other pages have the same title
+	TitleTooLong                            // the title is longer than MaxTitleLength
+	DescriptionMissing                      // the page has no meta description
+	DescriptionDuplicate                    // other pages have the same meta description
+	DescriptionTooLong                      // the meta description is longer than MaxDescriptionLength
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem MetaProblem) messageKey() string {
+	switch problem {
+	case TitleMissing:
+		return "meta.title.missing"
+	case TitleDuplicate:
+		return "meta.title.duplicate"
+	case TitleTooLong:
+		return "meta.title.long"
+	case DescriptionMissing:
+		return "meta.description.missing"
+	case DescriptionDuplicate:
+		return "meta.description.duplicate"
+	default:
+		return "meta.description.long"
+	}
+}
+
+// MetaIssue is a problem with the title or meta description of one or more pages
+type MetaIssue struct {
+	Problem MetaProblem
+	Text    string   // the duplicated or too long title or description (empty if missing)
+	Pages   []string // URLs of the pages with the problem, sorted
+}
+
+// MetaAudit checks the titles and meta descriptions of the site's pages, returning the pages missing either,
+// sharing one with other pages or with one too long to be displayed in full in search results. The issues
+// are ordered by problem, then text, with the pages sharing a text grouped in a single issue. Soft 404 pages
+// are not checked.
+func (site *SiteMap) MetaAudit() []MetaIssue {
+	type issueKey struct {
+		problem MetaProblem
+		text    string
+	}
+	pages := make(map[issueKey][]string)
+	titles, descriptions := make(map[string][]string), make(map[string][]string)
+	for _, page := range site.Pages {
+		if page.Soft404 {
+			continue
+		}
+		urlStr := page.URL.String()
+		for _, field := range []struct {
+			text    string
+			texts   map[string][]string
+			missing MetaProblem
+			tooLong MetaProblem
+			max     int
+		}{
+			{page.Title, titles, TitleMissing, TitleTooLong, MaxTitleLength},
+			{page.Description, descriptions, DescriptionMissing, DescriptionTooLong, MaxDescriptionLength},
+		} {
+			if len(field.text) == 0 {
+				pages[issueKey{field.missing, ""}] = append(pages[issueKey{field.missing, ""}], urlStr)
+				continue
+			}
+			field.texts[field.text] = append(field.texts[field.text], urlStr)
+			if utf8.RuneCountInString(field.text) > field.max {
+				pages[issueKey{field.tooLong, field.text}] = append(pages[issueKey{field.tooLong, field.text}], urlStr)
+			}
+		}
+	}
+	for problem, texts := range map[MetaProblem]map[string][]string{TitleDuplicate: titles, DescriptionDuplicate: descriptions} {
+		for text, urls := range texts {
+			if len(urls) > 1 {
+				pages[issueKey{problem, text}] = urls
+			}
+		}
+	}
+
+	issues := make([]MetaIssue, 0, len(pages))
+	for key, urls := range pages {
+		sort.Strings(urls)
+		issues = append(issues, MetaIssue{key.problem, key.text, urls})
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Problem != issues[j].Problem {
+			return issues[i].Problem < issues[j].Problem
+		}
+		return issues[i].Text < issues[j].Text
+	})
+	return issues
+}
@@ -0,0 +1,72 @@
+package sitemap
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMetaDescription(t *testing.T) {
+
+	const doc = `<html><head><title>Test</title>
+<meta name="keywords" content="test">
+<meta name=" Description " content="  The first
+	description ">
+<meta name="description" content="The second description">
+</head><body></body></html>`
+	page, err := CreateDocumentParser().ParseDocument("https://test.com", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if expected := "The first description"; page.Description != expected {
+		t.Errorf("Incorrect description: expected %q, got %q", expected, page.Description)
+	}
+}
+
+func TestMetaAudit(t *testing.T) {
+
+	long := strings.Repeat("é", MaxTitleLength+1)
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	for _, page := range []struct {
+		url, title, description string
+	}{
+		{"https://test.com", "Home", "Welcome"},
+		{"https://test.com/a", "Page", "About us"},
+		{"https://test.com/b", "Page", "About us"},
+		{"https://test.com/c", "", strings.Repeat("x", MaxDescriptionLength)},
+		{"https://test.com/d", long, ""},
+		{"https://test.com/e", strings.Repeat("é", MaxTitleLength), strings.Repeat("x", MaxDescriptionLength+1)},
+		{"https://test.com/missing", "Not found", ""},
+	} {
+		webPage := createWebPage(t, page.url, page.title)
+		webPage.Description = page.description
+		webPage.Soft404 = page.url == "https://test.com/missing"
+		site.AddPage(webPage)
+	}
+
+	issues := site.MetaAudit()
+	expected := []MetaIssue{
+		{TitleMissing, "", []string{"https://test.com/c"}},
+		{TitleDuplicate, "Page", []string{"https://test.com/a", "https://test.com/b"}},
+		{TitleTooLong, long, []string{"https://test.com/d"}},
+		{DescriptionMissing, "", []string{"https://test.com/d"}},
+		{DescriptionDuplicate, "About us", []string{"https://test.com/a", "https://test.com/b"}},
+		{DescriptionTooLong, strings.Repeat("x", MaxDescriptionLength+1), []string{"https://test.com/e"}},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Incorrect issues: expected %v, got %v", expected, issues)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintMetaIssues(&buf, issues[:3], nil); err != nil {
+		t.Fatalf("Failed to print issues: %v", err)
+	}
+	expectedReport := "\n\n ----- Title and meta description problems (3) -----\n" +
+		" missing title (1 pages)\n     https://test.com/c\n" +
+		" duplicate title \"Page\" (2 pages)\n     https://test.com/a\n     https://test.com/b\n" +
+		" title longer than 60 characters: \"" + long + "\" (1 pages)\n     https://test.com/d\n"
+	if buf.String() != expectedReport {
+		t.Errorf("Incorrect report: expected %q, got %q", expectedReport, buf.String())
+	}
+}
@@ -0,0 +1,353 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Crawler when it is created by CreateCrawler. Each option validates its value,
+// returning an error if it is invalid.
+type Option func(c *Crawler) error
+
+// WithLoader sets the DocumentLoader used to load and parse pages. By default a DocLoader with a
+// DocParser is used.
+func WithLoader(loader DocumentLoader) Option {
+	return func(c *Crawler) error {
+		if loader == nil {
+			return fmt.Errorf("document loader must not be nil")
+		}
+		c.docLoader = loader
+		return nil
+	}
+}
+
+// WithClient sets the HTTP client used by the default DocLoader. It cannot be combined with WithLoader.
+func WithClient(client *http.Client) Option {
+	return func(c *Crawler) error {
+		if client == nil {
+			return fmt.Errorf("HTTP client must not be nil")
+		}
+		c.client = client
+		return nil
+	}
+}
+
+// WithSink sets the PageSink (usually a SiteMap) each crawled page is added to. This is required unless
+// pages are read using CrawlPages.
+func WithSink(sink PageSink) Option {
+	return func(c *Crawler) error {
+		if sink == nil {
+			return fmt.Errorf("page sink must not be nil")
+		}
+		c.siteMap = sink
+		return nil
+	}
+}
+
+// WithWorkers sets the number of goroutines used to load pages (= maximum number of concurrent requests)
+func WithWorkers(workers int) Option {
+	return func(c *Crawler) error {
+		if workers < 1 {
+			return fmt.Errorf("number of workers must be at least 1, got %d", workers)
+		}
+		c.numLoaders = workers
+		return nil
+	}
+}
+
+// WithParseWorkers sets the number of goroutines used to parse pages, separately from the goroutines
+// downloading them (see WithWorkers), so slow parsing of large pages doesn't hold up requests. The default,
+// 0, parses each page in the goroutine which loaded it, as do loaders which can't separate the stages (e.g.
+// RenderLoader) and stable order crawls. Pages needing further requests, e.g. to check their assets, are
+// parsed when downloaded as the requests depend on their contents (see DocLoader.Parse).
+func WithParseWorkers(workers int) Option {
+	return func(c *Crawler) error {
+		if workers < 0 {
+			return fmt.Errorf("number of parse workers must not be negative, got %d", workers)
+		}
+		c.parseWorkers = workers
+		return nil
+	}
+}
+
+// WithThrottle sets the minimum delay between starting each page load, 0 for no throttling
+func WithThrottle(delay time.Duration) Option {
+	return func(c *Crawler) error {
+		if delay < 0 {
+			return fmt.Errorf("throttle delay must not be negative, got %v", delay)
+		}
+		c.minLoadDelay = delay
+		return nil
+	}
+}
+
+// WithMaxPages limits the number of pages loaded, 0 for no limit. Only pages loaded successfully (and not
+// discarded by an OnPage callback) count towards the limit, with URLs loaded until it is reached or there
+// are none left.
+func WithMaxPages(pages int) Option {
+	return func(c *Crawler) error {
+		if pages < 0 {
+			return fmt.Errorf("maximum pages must not be negative, got %d", pages)
+		}
+		c.maxPagesToLoad = pages
+		return nil
+	}
+}
+
+// WithMaxPagesPerDepth limits the number of URLs queued for loading at each depth, 0 for no limit, so wide
+// levels of a site (e.g. thousands of category pages) don't use up the whole of a limited crawl. URLs over
+// the limit are left in the frontier (see Crawler.Frontier).
+func WithMaxPagesPerDepth(pages int) Option {
+	return func(c *Crawler) error {
+		if pages < 0 {
+			return fmt.Errorf("maximum pages per depth must not be negative, got %d", pages)
+		}
+		c.maxPerDepth = pages
+		return nil
+	}
+}
+
+// WithMaxDepth limits the depth crawled to, 0 for no limit. The depth of a URL is the smallest depth it is
+// found at, so a URL first found beyond the limit is still crawled if it is found again within it.
+func WithMaxDepth(depth int) Option {
+	return func(c *Crawler) error {
+		if depth < 0 {
+			return fmt.Errorf("maximum depth must not be negative, got %d", depth)
+		}
+		c.maxCrawlDepth = depth
+		return nil
+	}
+}
+
+// WithLoadTimeout sets the maximum time to wait for a single page to be loaded and parsed, 0 for no limit
+func WithLoadTimeout(timeout time.Duration) Option {
+	return func(c *Crawler) error {
+		if timeout < 0 {
+			return fmt.Errorf("load timeout must not be negative, got %v", timeout)
+		}
+		c.loadTimeout = timeout
+		return nil
+	}
+}
+
+// WithMaxDuration limits the time for the whole crawl, 0 for no limit
+func WithMaxDuration(duration time.Duration) Option {
+	return func(c *Crawler) error {
+		if duration < 0 {
+			return fmt.Errorf("maximum duration must not be negative, got %v", duration)
+		}
+		c.maxDuration = duration
+		return nil
+	}
+}
+
+// WithBlockCache sets the cache of URLs blocked in previous crawls, which is updated by this crawl
+func WithBlockCache(cache *BlockCache) Option {
+	return func(c *Crawler) error {
+		if cache == nil {
+			return fmt.Errorf("block cache must not be nil")
+		}
+		c.blockCache = cache
+		return nil
+	}
+}
+
+// WithProgress sets a function called with a progress snapshot every interval while crawling, and once
+// more when crawling is complete
+func WithProgress(progressFunc func(CrawlProgress), interval time.Duration) Option {
+	return func(c *Crawler) error {
+		if progressFunc == nil {
+			return fmt.Errorf("progress function must not be nil")
+		}
+		if interval <= 0 {
+			return fmt.Errorf("progress interval must be positive, got %v", interval)
+		}
+		c.progressFunc = progressFunc
+		c.progressInterval = interval
+		return nil
+	}
+}
+
+// WithLogger sets the logger used by the crawler (and the default DocLoader)
+func WithLogger(logger Logger) Option {
+	return func(c *Crawler) error {
+		if logger == nil {
+			return fmt.Errorf("logger must not be nil")
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithURLFilter sets a filter called for each newly discovered URL before it is queued for loading.
+// URLs for which the filter returns false are not crawled.
+func WithURLFilter(filter URLFilter) Option {
+	return func(c *Crawler) error {
+		if filter == nil {
+			return fmt.Errorf("URL filter must not be nil")
+		}
+		c.urlFilter = filter
+		return nil
+	}
+}
+
+// WithRootPath limits the crawl to a section of the site: only URLs whose path is the prefix, or below it, are
+// crawled (e.g. /docs crawls /docs and /docs/intro but not /docsearch). Links to other URLs on the site are
+// recorded as boundary links (see Crawler.BoundaryLinks). The start URL should be under the prefix.
+func WithRootPath(prefix string) Option {
+	return func(c *Crawler) error {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("root path must start with /, got %q", prefix)
+		}
+		c.rootPath = strings.TrimRight(prefix, "/")
+		return nil
+	}
+}
+
+// WithPriority sets the order queued URLs are loaded in, lowest score first (e.g. ScoreByDepth or ScoreByPath),
+// in place of the order they were found. When the number of pages is limited (see WithMaxPages) the limit is
+// applied as URLs are loaded, so the highest priority URLs found are the ones crawled.
+func WithPriority(score FrontierScore) Option {
+	return func(c *Crawler) error {
+		if score == nil {
+			return fmt.Errorf("priority score must not be nil")
+		}
+		c.priority = score
+		return nil
+	}
+}
+
+// WithOnPage adds callbacks called with each loaded page before it is added to the sink. Callbacks form a
+// chain, called in the order they were added, with the chain stopping at the first to discard the page.
+func WithOnPage(callbacks ...OnPageFunc) Option {
+	return func(c *Crawler) error {
+		for _, callback := range callbacks {
+			if callback == nil {
+				return fmt.Errorf("page callback must not be nil")
+			}
+		}
+		c.onPage = append(c.onPage, callbacks...)
+		return nil
+	}
+}
+
+// WithTrapLimits sets the thresholds used to detect crawl traps (runaway URL patterns such as infinite
+// calendars or pagination). A limit of 0 disables that check.
+func WithTrapLimits(limits TrapLimits) Option {
+	return func(c *Crawler) error {
+		if limits.MaxSegmentRepeats < 0 || limits.MaxDateVariants < 0 || limits.MaxPageVariants < 0 {
+			return fmt.Errorf("crawl trap limits must not be negative, got %+v", limits)
+		}
+		c.trapLimits = limits
+		return nil
+	}
+}
+
+// WithResume continues a crawl stopped by a page or time limit: crawling starts from the frontier URLs
+// (see Crawler.Frontier) rather than the start URL, and the visited URLs (see Crawler.Visited) are not
+// loaded again.
+func WithResume(frontier []FrontierURL, visited []string) Option {
+	return func(c *Crawler) error {
+		c.seeds = make([]Hyperlink, 0, len(frontier))
+		for _, link := range frontier {
+			if link.Depth < 1 {
+				return fmt.Errorf("frontier URL depth must be at least 1, got %d for %s", link.Depth, link.URL)
+			}
+			c.seeds = append(c.seeds, Hyperlink{link.URL, link.Depth})
+		}
+		c.visited = make(map[string]bool, len(visited))
+		for _, urlStr := range visited {
+			c.visited[urlStr] = true
+		}
+		return nil
+	}
+}
+
+// WithSeeds adds URLs crawling starts from as well as the start URL, at the same depth, so pages which
+// can't be reached by following links are still found (e.g. every page of a local site, see LocalSite).
+// Seeds are subject to the same filters as links found by crawling, and any already visited are not loaded.
+func WithSeeds(urls ...string) Option {
+	return func(c *Crawler) error {
+		c.extraSeeds = append(c.extraSeeds, urls...)
+		return nil
+	}
+}
+
+// WithMemoryThreshold sets the memory use (in bytes) above which the crawl switches to low memory mode rather
+// than risk running out of memory, 0 for no threshold. In low memory mode the queue of URLs to load spills to
+// a temporary file in spillDir (the default temporary directory if empty), the set of URLs seen is compacted
+// to hashes and URLs are only passed to the loaders as they become free. See Crawler.LowMemory.
+func WithMemoryThreshold(bytes uint64, spillDir string) Option {
+	return func(c *Crawler) error {
+		c.memoryThreshold, c.spillDir = bytes, spillDir
+		return nil
+	}
+}
+
+// WithMaxMemory sets a limit on the memory use (in bytes) of the crawl, 0 for no limit, so a crawl of a large
+// site stops with partial results rather than being killed for running out of memory. The limit is also set
+// as the Go runtime's soft memory limit while crawling. Nearing the limit, the crawl switches to low memory
+// mode (see WithMemoryThreshold) then pauses loading pages until memory use falls. Once it's reached, the
+// URLs still to load are left in the frontier (see Crawler.Frontier and Crawler.MemoryLimited).
+func WithMaxMemory(bytes uint64) Option {
+	return func(c *Crawler) error {
+		c.maxMemory = bytes
+		return nil
+	}
+}
+
+// WithMaxRetryAfter sets the longest requests to a host are held off for when its server asks the crawler to
+// slow down (by default DefaultMaxRetryAfter). A 429 or 503 response with a Retry-After header (or any 429)
+// holds off requests to the host for the time asked, with the URL loaded again once the hold expires rather
+// than failing (up to 3 times). A response with no X-RateLimit-Remaining (or RateLimit-Remaining) requests
+// left holds off requests to the host until its reset time. URLs on other hosts are loaded as usual. A
+// maximum of 0 ignores these headers, so rate limited URLs fail.
+func WithMaxRetryAfter(maxHold time.Duration) Option {
+	return func(c *Crawler) error {
+		if maxHold < 0 {
+			return fmt.Errorf("maximum retry after must not be negative, got %v", maxHold)
+		}
+		c.maxRetryAfter = maxHold
+		return nil
+	}
+}
+
+// WithStableOrder makes the order pages are crawled in deterministic, so crawls of an unchanged site load the
+// same pages at the same depths (even when limited by WithMaxPages or WithMaxDepth) and add them to the sink
+// in the same order. Pages are loaded one at a time, whatever the number of workers, with the links out of
+// each page queued in sorted order. A crawl stopped by WithMaxDuration is still timing dependent.
+func WithStableOrder() Option {
+	return func(c *Crawler) error {
+		c.stableOrder = true
+		return nil
+	}
+}
+
+// WithContext sets a context which stops the crawl when it is cancelled. URLs not yet being loaded are not
+// loaded (they are deferred, as when WithMaxDuration stops a crawl) and crawling completes once the loads in
+// progress finish.
+func WithContext(ctx context.Context) Option {
+	return func(c *Crawler) error {
+		if ctx == nil {
+			return fmt.Errorf("context must not be nil")
+		}
+		c.ctx = ctx
+		return nil
+	}
+}
+
+// WithTracer records each URL crawled as a trace (see Tracer), with a span for the whole of crawling the URL
+// and child spans for fetching, parsing and ingesting the page. The loader's stages are only traced if it
+// supports tracing (as DocLoader does), with the trace propagated on its requests if the tracer does so.
+func WithTracer(tracer Tracer) Option {
+	return func(c *Crawler) error {
+		if tracer == nil {
+			return fmt.Errorf("tracer must not be nil")
+		}
+		c.tracer = tracer
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+//go:build otel
+
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otelShutdownTimeout is the maximum time to wait for the spans still buffered to be exported when the
+// tracer is closed
+const otelShutdownTimeout = 10 * time.Second
+
+// OTelTracer implements the Tracer interface using OpenTelemetry, exporting spans to a collector (or any
+// tracing backend accepting OTLP) over HTTP. Spans are batched, so Close must be called once crawling is
+// complete to export the last of them. The fetch span of each URL is a client span, so a backend links it to
+// the server's span for the request when the trace is propagated.
+type OTelTracer struct {
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator // adds the trace to requests (nil if it isn't propagated)
+}
+
+// CreateOTelTracer creates a tracer exporting spans to the OTLP/HTTP endpoint with the supplied URL (e.g.
+// http://localhost:4318), or the endpoint set by the standard OTEL_EXPORTER_OTLP_* environment variables
+// if empty. If propagate is set, the W3C trace context headers (traceparent and tracestate) are added to
+// the requests made for each page, so the site's own spans join the crawler's traces.
+func CreateOTelTracer(endpoint string, propagate bool) (*OTelTracer, error) {
+	var opts []otlptracehttp.Option
+	if len(endpoint) != 0 {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "go-sitemap"))),
+	)
+	tracer := &OTelTracer{provider: provider, tracer: provider.Tracer("go-sitemap")}
+	if propagate {
+		tracer.propagator = propagation.TraceContext{}
+	}
+	return tracer, nil
+}
+
+// Start starts a span for a stage of crawling a URL. See Tracer interface for details.
+func (t *OTelTracer) Start(ctx context.Context, name string, urlStr string) (context.Context, func(err error)) {
+	kind := trace.SpanKindInternal
+	if name == spanFetch {
+		kind = trace.SpanKindClient
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attribute.String("url.full", urlStr)))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Inject adds the trace context headers for the span in ctx to a request, if the trace is propagated
+func (t *OTelTracer) Inject(ctx context.Context, header http.Header) {
+	if t.propagator != nil {
+		t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	}
+}
+
+// Close exports any spans still buffered then stops the tracer
+func (t *OTelTracer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+	defer cancel()
+	if err := t.provider.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to export traces: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build !otel
+
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// OTelTracer exports spans using OpenTelemetry. This build does not support tracing; build with -tags otel
+// to enable it (see oteltracer.go).
+type OTelTracer struct{}
+
+// CreateOTelTracer always fails, as this build does not support tracing
+func CreateOTelTracer(endpoint string, propagate bool) (*OTelTracer, error) {
+	return nil, fmt.Errorf("OpenTelemetry tracing is not supported by this build (build with -tags otel)")
+}
+
+// Start does nothing as the tracer can never be created
+func (t *OTelTracer) Start(ctx context.Context, name string, urlStr string) (context.Context, func(err error)) {
+	return ctx, func(error) {}
+}
+
+// Inject does nothing as the tracer can never be created
+func (t *OTelTracer) Inject(ctx context.Context, header http.Header) {}
+
+// Close does nothing as the tracer can never be created
+func (t *OTelTracer) Close() error {
+	return nil
+}
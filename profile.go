@@ -0,0 +1,60 @@
+package sitemap
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Profiler writes pprof profiles of a crawl for analysis with "go tool pprof": a CPU profile covering the
+// crawl, and a heap profile written as it completes (showing the memory held by the site map and crawler,
+// plus everything allocated during the crawl).
+type Profiler struct {
+	cpuFile  *os.File // CPU profile being written (nil if not requested)
+	heapPath string   // file the heap profile is written to (empty if not requested)
+}
+
+// StartProfiler starts writing a CPU profile to cpuPath, and records the file the heap profile is written to
+// when the profiler is stopped. Either path may be empty to skip that profile.
+func StartProfiler(cpuPath string, heapPath string) (*Profiler, error) {
+	profiler := &Profiler{heapPath: heapPath}
+	if len(cpuPath) == 0 {
+		return profiler, nil
+	}
+	file, err := os.Create(cpuPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+	profiler.cpuFile = file
+	return profiler, nil
+}
+
+// Stop ends the CPU profile and writes the heap profile
+func (profiler *Profiler) Stop() error {
+	if profiler.cpuFile != nil {
+		pprof.StopCPUProfile()
+		err := profiler.cpuFile.Close()
+		profiler.cpuFile = nil
+		if err != nil {
+			return fmt.Errorf("failed to write CPU profile: %w", err)
+		}
+	}
+	if len(profiler.heapPath) == 0 {
+		return nil
+	}
+	file, err := os.Create(profiler.heapPath)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile: %w", err)
+	}
+	defer file.Close()
+	runtime.GC() // so the profile shows the memory in use once the crawl completes
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		return fmt.Errorf("failed to write heap profile: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,40 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+
+	dir := t.TempDir()
+	cpuPath, heapPath := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "heap.pprof")
+	profiler, err := StartProfiler(cpuPath, heapPath)
+	if err != nil {
+		t.Fatalf("Failed to start profiler: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		_ = strings.Repeat("x", i) // something to profile
+	}
+	if err := profiler.Stop(); err != nil {
+		t.Fatalf("Failed to stop profiler: %v", err)
+	}
+	for _, path := range []string{cpuPath, heapPath} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("Incorrect profile %s: expected it to be written, got %v", filepath.Base(path), err)
+		}
+	}
+
+	// no profiles requested
+	if profiler, err = StartProfiler("", ""); err != nil {
+		t.Fatalf("Failed to start profiler with no profiles: %v", err)
+	}
+	if err := profiler.Stop(); err != nil {
+		t.Errorf("Unexpected error stopping profiler with no profiles: %v", err)
+	}
+	if _, err := StartProfiler(filepath.Join(dir, "missing", "cpu.pprof"), ""); err == nil {
+		t.Errorf("Incorrect result for a CPU profile in a missing directory: expected an error")
+	}
+}
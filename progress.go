@@ -0,0 +1,138 @@
+package sitemap
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrawlProgress is a snapshot of the progress of a crawl
+type CrawlProgress struct {
+	PagesLoaded    int           // pages loaded and parsed successfully
+	Errors         int           // URLs which failed to load (or were ignored, e.g. non-HTML content)
+	InFlight       int           // URLs currently being loaded
+	Queued         int           // URLs waiting to be loaded
+	Discovered     int           // URLs accepted for crawling so far (processed, in flight or queued)
+	Elapsed        time.Duration // time since crawling started
+	PagesPerSec    float64       // recent rate at which URLs are being processed
+	EstimatedTotal int           // estimated total number of URLs which will be processed, 0 if unknown
+	ETA            time.Duration // estimated time remaining, 0 if unknown
+	Done           bool          // true for the final snapshot, once crawling is complete
+}
+
+// Processed returns the number of URLs which have finished loading (successfully or not)
+func (p CrawlProgress) Processed() int {
+	return p.PagesLoaded + p.Errors
+}
+
+// Fraction returns the estimated fraction of the crawl which is complete, from 0 to 1. If there is no
+// estimate of the total number of URLs this is based on the work known about so far, which will usually
+// grow as crawling continues.
+func (p CrawlProgress) Fraction() float64 {
+	if p.Done {
+		return 1
+	}
+	total := p.EstimatedTotal
+	if total == 0 {
+		total = p.Processed() + p.InFlight + p.Queued
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(p.Processed()) / float64(total)
+}
+
+// ProgressBarWidth is the number of characters in the bar drawn by RenderProgressBar
+const ProgressBarWidth = 30
+
+// RenderProgressBar writes a single line progress bar for the snapshot, overwriting the current line
+// on the console. A new line is written after the final snapshot.
+func RenderProgressBar(w io.Writer, p CrawlProgress) {
+	filled := int(p.Fraction() * ProgressBarWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", ProgressBarWidth-filled)
+	eta := ""
+	if p.ETA > 0 && !p.Done {
+		eta = fmt.Sprintf(", ETA %v", p.ETA.Round(time.Second))
+	}
+	fmt.Fprintf(w, "\r\033[K[%s] %3.0f%% %d pages, %d queued, %d errors, %.1f pages/s, %v elapsed%s",
+		bar, p.Fraction()*100, p.PagesLoaded, p.Queued+p.InFlight, p.Errors, p.PagesPerSec, p.Elapsed.Round(time.Second), eta)
+	if p.Done {
+		fmt.Fprintln(w)
+	}
+}
+
+// progressSample records the progress counts at a point in time
+type progressSample struct {
+	at         time.Time
+	processed  int
+	discovered int
+}
+
+// maxProgressSamples is the number of samples used for estimates (about 30 seconds at the default
+// progress interval)
+const maxProgressSamples = 30
+
+// progressEstimator estimates the completion percentage and time remaining for a crawl using the
+// frontier size (URLs queued or in flight) along with the recent processing and discovery rates
+//
+// The discovery ratio is the number of new URLs found for each URL processed. While this is 1 or more
+// the frontier keeps growing and we can't estimate the total size of the site. Once it drops below 1
+// each URL in the frontier leads to (on average) ratio more URLs, giving an estimated remaining work of
+// frontier * (1 + ratio + ratio^2 + ...) = frontier / (1 - ratio).
+//
+// Any page limit or crawl deadline also bounds the remaining work.
+type progressEstimator struct {
+	mutex   sync.Mutex
+	samples []progressSample // recent samples, oldest first
+}
+
+// estimate fills in the rate and estimate fields of the snapshot. maxPages (0 for none) limits the number of
+// URLs discovered and deadline (zero for none) is the time by which crawling will end.
+func (e *progressEstimator) estimate(p *CrawlProgress, now time.Time, maxPages int, deadline time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if p.Done {
+		p.EstimatedTotal = p.Processed()
+		if p.Elapsed > 0 {
+			p.PagesPerSec = float64(p.Processed()) / p.Elapsed.Seconds()
+		}
+		return
+	}
+
+	// compare against the oldest sample in our window, or the crawl start if we have none
+	e.samples = append(e.samples, progressSample{now, p.Processed(), p.Discovered})
+	if len(e.samples) > maxProgressSamples {
+		e.samples = e.samples[len(e.samples)-maxProgressSamples:]
+	}
+	base := e.samples[0]
+	if base.processed == p.Processed() {
+		base = progressSample{now.Add(-p.Elapsed), 0, 0}
+	}
+	processed := p.Processed() - base.processed
+	discovered := p.Discovered - base.discovered
+	interval := now.Sub(base.at)
+	if processed <= 0 || interval <= 0 {
+		return
+	}
+	p.PagesPerSec = float64(processed) / interval.Seconds()
+
+	frontier := float64(p.Queued + p.InFlight)
+	remaining := math.Inf(1)
+	if ratio := float64(discovered) / float64(processed); ratio < 1 {
+		remaining = frontier / (1 - ratio)
+	}
+	if maxPages > 0 {
+		remaining = math.Min(remaining, frontier+float64(maxPages-p.Discovered))
+	}
+	if math.IsInf(remaining, 1) {
+		return
+	}
+	p.EstimatedTotal = p.Processed() + int(math.Ceil(remaining))
+	p.ETA = time.Duration(remaining / p.PagesPerSec * float64(time.Second))
+	if !deadline.IsZero() && now.Add(p.ETA).After(deadline) {
+		p.ETA = deadline.Sub(now)
+	}
+}
@@ -0,0 +1,78 @@
+package sitemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressFraction(t *testing.T) {
+
+	if f := (CrawlProgress{}).Fraction(); f != 0 {
+		t.Errorf("Incorrect fraction for empty progress: expected %v, got %v", 0, f)
+	}
+	if f := (CrawlProgress{PagesLoaded: 2, Errors: 1, InFlight: 1, Queued: 2}).Fraction(); f != 0.5 {
+		t.Errorf("Incorrect fraction: expected %v, got %v", 0.5, f)
+	}
+	if f := (CrawlProgress{PagesLoaded: 2, Queued: 2, EstimatedTotal: 8}).Fraction(); f != 0.25 {
+		t.Errorf("Incorrect fraction with estimated total: expected %v, got %v", 0.25, f)
+	}
+	if f := (CrawlProgress{PagesLoaded: 2, Queued: 2, Done: true}).Fraction(); f != 1 {
+		t.Errorf("Incorrect fraction when done: expected %v, got %v", 1, f)
+	}
+}
+
+func TestRenderProgressBar(t *testing.T) {
+
+	var buf bytes.Buffer
+	RenderProgressBar(&buf, CrawlProgress{PagesLoaded: 5, Queued: 4, InFlight: 1, Errors: 0, Elapsed: 3 * time.Second,
+		PagesPerSec: 2.5, ETA: 2 * time.Second})
+	got := buf.String()
+	expected := "[" + strings.Repeat("#", 15) + strings.Repeat(".", 15) + "]  50% 5 pages, 5 queued, 0 errors, 2.5 pages/s, 3s elapsed, ETA 2s"
+	if !strings.HasPrefix(got, "\r") || !strings.HasSuffix(got, expected) {
+		t.Errorf("Incorrect progress bar: expected %q, got %q", expected, got)
+	}
+
+	buf.Reset()
+	RenderProgressBar(&buf, CrawlProgress{PagesLoaded: 5, Done: true, Elapsed: time.Second})
+	if got := buf.String(); !strings.HasSuffix(got, "] 100% 5 pages, 0 queued, 0 errors, 0.0 pages/s, 1s elapsed\n") {
+		t.Errorf("Incorrect final progress bar: got %q", got)
+	}
+}
+
+func TestProgressEstimator(t *testing.T) {
+
+	var estimator progressEstimator
+	now := time.Now()
+
+	// first snapshot compares against the start of the crawl: 10 pages/s, but discovering 3 new
+	// URLs for each one processed so we can't estimate the total
+	p := CrawlProgress{PagesLoaded: 10, Queued: 20, Discovered: 31, Elapsed: time.Second}
+	estimator.estimate(&p, now, 0, time.Time{})
+	if p.PagesPerSec != 10 || p.EstimatedTotal != 0 || p.ETA != 0 {
+		t.Errorf("Incorrect estimate for growing frontier: got %+v", p)
+	}
+
+	// with a page limit the total is known
+	p = CrawlProgress{PagesLoaded: 10, Queued: 20, Discovered: 31, Elapsed: time.Second}
+	estimator.estimate(&p, now, 50, time.Time{})
+	if p.EstimatedTotal != 49 || p.ETA != 3900*time.Millisecond {
+		t.Errorf("Incorrect estimate with page limit: got %+v", p)
+	}
+
+	// 10 seconds later we've processed another 40 URLs but only discovered 20 more, so each
+	// URL in the frontier of 10 leads to (on average) another one, for 20 remaining at 4 pages/s
+	p = CrawlProgress{PagesLoaded: 50, Queued: 10, Discovered: 51, Elapsed: 11 * time.Second}
+	estimator.estimate(&p, now.Add(10*time.Second), 0, time.Time{})
+	if p.PagesPerSec != 4 || p.EstimatedTotal != 70 || p.ETA != 5*time.Second {
+		t.Errorf("Incorrect estimate for shrinking frontier: got %+v", p)
+	}
+
+	// the deadline limits the time remaining
+	p = CrawlProgress{PagesLoaded: 50, Queued: 10, Discovered: 51, Elapsed: 11 * time.Second}
+	estimator.estimate(&p, now.Add(10*time.Second), 0, now.Add(12*time.Second))
+	if p.ETA != 2*time.Second {
+		t.Errorf("Incorrect estimate with deadline: got %+v", p)
+	}
+}
@@ -0,0 +1,55 @@
+package sitemap
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sort"
+)
+
+// ProtocolGroup is the pages of a site loaded with the same HTTP protocol and TLS version
+type ProtocolGroup struct {
+	Protocol   string   // HTTP protocol (e.g. HTTP/1.1, HTTP/2.0), empty if not known
+	TLSVersion string   // TLS version (e.g. TLS 1.3), empty if loaded without TLS
+	URLs       []string // URLs of the pages, sorted
+}
+
+// responseProtocol returns the HTTP protocol and TLS version (empty if TLS wasn't used) a response was
+// received with. Note the standard transport negotiates HTTP/1.1 or HTTP/2 (h2) but not HTTP/3, so an
+// HTTP/3 edge is only seen in the Alt-Svc header of its responses.
+func responseProtocol(resp *http.Response) (string, string) {
+	if resp.TLS == nil {
+		return resp.Proto, ""
+	}
+	return resp.Proto, tls.VersionName(resp.TLS.Version)
+}
+
+// ProtocolUsage returns the pages of the site grouped by the HTTP protocol and TLS version they were loaded
+// with, the most common first. A site served consistently by its CDN or edge has a single group, so pages in
+// the other groups show where the configuration differs (e.g. paths routed to a different origin).
+func (site *SiteMap) ProtocolUsage() []ProtocolGroup {
+	groups := make(map[[2]string]*ProtocolGroup)
+	for _, page := range site.Pages {
+		key := [2]string{page.Protocol, page.TLSVersion}
+		group, found := groups[key]
+		if !found {
+			group = &ProtocolGroup{Protocol: page.Protocol, TLSVersion: page.TLSVersion}
+			groups[key] = group
+		}
+		group.URLs = append(group.URLs, page.URL.String())
+	}
+	usage := make([]ProtocolGroup, 0, len(groups))
+	for _, group := range groups {
+		sort.Strings(group.URLs)
+		usage = append(usage, *group)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if len(usage[i].URLs) != len(usage[j].URLs) {
+			return len(usage[i].URLs) > len(usage[j].URLs)
+		}
+		if usage[i].Protocol != usage[j].Protocol {
+			return usage[i].Protocol < usage[j].Protocol
+		}
+		return usage[i].TLSVersion < usage[j].TLSVersion
+	})
+	return usage
+}
@@ -0,0 +1,75 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoadURLProtocol(t *testing.T) {
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(rw, "<html><head><title>Home</title></head></html>")
+	})
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	loader := CreateDocumentLoader(CreateDocumentParser())
+	loader.Client = server.Client()
+	page, err := loader.LoadURL(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to load page: %v", err)
+	}
+	if page.Protocol != "HTTP/2.0" || page.TLSVersion != "TLS 1.3" {
+		t.Errorf("Incorrect protocol: expected HTTP/2.0 over TLS 1.3, got %v over %v", page.Protocol, page.TLSVersion)
+	}
+
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	if page, err = loader.LoadURL(plain.URL); err != nil {
+		t.Fatalf("Failed to load page: %v", err)
+	}
+	if page.Protocol != "HTTP/1.1" || len(page.TLSVersion) != 0 {
+		t.Errorf("Incorrect protocol: expected HTTP/1.1 without TLS, got %v over %v", page.Protocol, page.TLSVersion)
+	}
+}
+
+func TestProtocolUsage(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	for _, path := range []string{"", "/a", "/b", "/legacy", "/old"} {
+		page := createWebPage(t, "https://test.com"+path, path)
+		page.Protocol, page.TLSVersion = "HTTP/2.0", "TLS 1.3"
+		if strings.HasPrefix(path, "/old") || strings.HasPrefix(path, "/legacy") {
+			page.Protocol, page.TLSVersion = "HTTP/1.1", "TLS 1.2"
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	groups := site.ProtocolUsage()
+	expected := "[{HTTP/2.0 TLS 1.3 [https://test.com https://test.com/a https://test.com/b]} " +
+		"{HTTP/1.1 TLS 1.2 [https://test.com/legacy https://test.com/old]}]"
+	if got := fmt.Sprint(groups); got != expected {
+		t.Errorf("Incorrect protocol usage: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintProtocolUsage(&buf, groups, nil); err != nil {
+		t.Fatalf("Failed to print protocol usage: %v", err)
+	}
+	expected = "\n\n ----- HTTP protocols and TLS versions (2 combinations) -----\n" +
+		"      3  HTTP/2.0, TLS 1.3\n" +
+		"      2  HTTP/1.1, TLS 1.2\n" +
+		"         https://test.com/legacy\n" +
+		"         https://test.com/old\n"
+	if buf.String() != expected {
+		t.Errorf("Incorrect protocol report: expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,74 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// proxySchemes are the proxy URL schemes supported by the HTTP transport. With socks5 host names are
+// resolved locally, and with socks5h they are resolved by the proxy.
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// ProxyRotator sends requests through a list of proxies, rotating between them so each request uses the next
+// proxy in turn. This spreads a large crawl across several addresses, for sites (or CDNs in front of them)
+// rate limiting each client address. A single proxy is simply used for every request.
+type ProxyRotator struct {
+	proxies []*url.URL
+	next    atomic.Uint64
+}
+
+// CreateProxyRotator creates a rotator for the supplied proxy URLs (http://, https://, socks5:// or
+// socks5h://, optionally with a user:password), returning an error if none are supplied or any are invalid
+func CreateProxyRotator(proxies []string) (*ProxyRotator, error) {
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no proxies supplied")
+	}
+	rotator := &ProxyRotator{}
+	for _, proxy := range proxies {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s: %v", proxy, err)
+		}
+		if !proxySchemes[proxyURL.Scheme] || len(proxyURL.Host) == 0 {
+			return nil, fmt.Errorf("invalid proxy %s, expected http://, https://, socks5:// or socks5h:// and a host", proxyURL.Redacted())
+		}
+		rotator.proxies = append(rotator.proxies, proxyURL)
+	}
+	return rotator, nil
+}
+
+// LoadProxyList reads a file listing a proxy URL on each line. Blank lines and lines starting with # are
+// ignored.
+func LoadProxyList(fileName string) ([]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var proxies []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); len(line) != 0 && !strings.HasPrefix(line, "#") {
+			proxies = append(proxies, line)
+		}
+	}
+	return proxies, nil
+}
+
+// Proxy returns the proxy to send a request through, and can be used as the Proxy function of an
+// http.Transport
+func (rotator *ProxyRotator) Proxy(req *http.Request) (*url.URL, error) {
+	index := (rotator.next.Add(1) - 1) % uint64(len(rotator.proxies))
+	return rotator.proxies[index], nil
+}
+
+// String returns the proxies, with any passwords redacted so they can be logged
+func (rotator *ProxyRotator) String() string {
+	redacted := make([]string, len(rotator.proxies))
+	for i, proxy := range rotator.proxies {
+		redacted[i] = proxy.Redacted()
+	}
+	return strings.Join(redacted, ", ")
+}
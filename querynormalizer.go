@@ -0,0 +1,83 @@
+package sitemap
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultDropParams lists the query parameters commonly used for tracking, which don't change the page returned
+var DefaultDropParams = []string{"utm_*", "fbclid", "gclid", "msclkid", "sessionid", "jsessionid", "phpsessid"}
+
+// QueryNormalizer normalizes the query strings of links found on pages so the same page reached with
+// different tracking parameters (or parameter orders) is only crawled and listed once.
+// The zero value leaves query strings unchanged.
+type QueryNormalizer struct {
+	DropAll bool     // remove the whole query string
+	Drop    []string // names of parameters to remove (case insensitive), where a trailing * matches any suffix
+	Sort    bool     // sort the remaining parameters by name (then value)
+}
+
+// ParseDropParams parses a comma separated list of query parameters to drop, where "default" is
+// replaced with DefaultDropParams
+func ParseDropParams(list string) []string {
+	var params []string
+	for _, param := range strings.Split(list, ",") {
+		param = strings.TrimSpace(param)
+		if param == "default" {
+			params = append(params, DefaultDropParams...)
+		} else if len(param) != 0 {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// Normalize updates the query string of the supplied URL
+func (n *QueryNormalizer) Normalize(u *url.URL) {
+	if len(u.RawQuery) == 0 || (!n.DropAll && len(n.Drop) == 0 && !n.Sort) {
+		return
+	}
+	if n.DropAll {
+		u.RawQuery = ""
+		return
+	}
+
+	// work on the raw name=value pairs so anything we don't change keeps its original encoding
+	pairs := strings.Split(u.RawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if len(pair) != 0 && !n.dropped(pair) {
+			kept = append(kept, pair)
+		}
+	}
+	if n.Sort {
+		sort.SliceStable(kept, func(i, j int) bool {
+			ni, vi, _ := strings.Cut(kept[i], "=")
+			nj, vj, _ := strings.Cut(kept[j], "=")
+			if ni != nj {
+				return ni < nj
+			}
+			return vi < vj
+		})
+	}
+	u.RawQuery = strings.Join(kept, "&")
+}
+
+// dropped returns true if the raw name=value pair is one of the parameters to drop
+func (n *QueryNormalizer) dropped(pair string) bool {
+	name, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		name = unescaped
+	}
+	for _, drop := range n.Drop {
+		if prefix, wildcard := strings.CutSuffix(drop, "*"); wildcard {
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(name, drop) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package sitemap
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestQueryNormalizer(t *testing.T) {
+	tests := []struct {
+		normalizer QueryNormalizer
+		input      string
+		expected   string
+	}{
+		{QueryNormalizer{}, "https://test.com/a?b=2&a=1", "https://test.com/a?b=2&a=1"},
+		{QueryNormalizer{DropAll: true}, "https://test.com/a?b=2&a=1", "https://test.com/a"},
+		{QueryNormalizer{Sort: true}, "https://test.com/a?b=2&a=1&a=0", "https://test.com/a?a=0&a=1&b=2"},
+		{QueryNormalizer{Drop: []string{"utm_*", "fbclid"}}, "https://test.com/a?utm_source=x&id=3&FBCLID=y&UTM_medium=z", "https://test.com/a?id=3"},
+		{QueryNormalizer{Drop: []string{"utm_*"}}, "https://test.com/a?utm_source=x", "https://test.com/a"},
+		{QueryNormalizer{Drop: []string{"id"}, Sort: true}, "https://test.com/a?z=%2F&id=1&idx=2", "https://test.com/a?idx=2&z=%2F"},
+	}
+	for _, test := range tests {
+		u, err := url.Parse(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.normalizer.Normalize(u)
+		if u.String() != test.expected {
+			t.Errorf("Incorrect normalized URL for %s: expected %s, got %s", test.input, test.expected, u.String())
+		}
+	}
+}
+
+func TestParseDropParams(t *testing.T) {
+	got := ParseDropParams(" sid , default,")
+	expected := append([]string{"sid"}, DefaultDropParams...)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Incorrect parameters: expected %v, got %v", expected, got)
+	}
+	if got := ParseDropParams(""); len(got) != 0 {
+		t.Errorf("Incorrect parameters for empty list: expected none, got %v", got)
+	}
+}
@@ -0,0 +1,340 @@
+package sitemap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+// queryShellPrompt is shown before each command read by the query shell
+const queryShellPrompt = "> "
+
+// recordField is a field of a page record which can be used in a query condition. Numeric fields set number,
+// returning false if the value isn't known (e.g. the depth of an orphan page), and other fields set text.
+type recordField struct {
+	text   func(record *PageRecord) string
+	number func(record *PageRecord) (float64, bool)
+}
+
+// recordFields are the fields of a page record which can be queried, by name
+var recordFields = map[string]recordField{
+	"url":          {text: func(record *PageRecord) string { return record.URL }},
+	"path":         {text: recordPath},
+	"title":        {text: func(record *PageRecord) string { return record.Title }},
+	"canonical":    {text: func(record *PageRecord) string { return record.Canonical }},
+	"contenthash":  {text: func(record *PageRecord) string { return record.ContentHash }},
+	"etag":         {text: func(record *PageRecord) string { return record.ETag }},
+	"soft404":      {text: func(record *PageRecord) string { return strconv.FormatBool(record.Soft404) }},
+	"lastmodified": {text: recordLastModified},
+	"protocol":     {text: func(record *PageRecord) string { return record.Protocol }},
+	"tlsversion":   {text: func(record *PageRecord) string { return record.TLSVersion }},
+	"depth": {number: func(record *PageRecord) (float64, bool) {
+		if record.Depth == nil {
+			return 0, false
+		}
+		return float64(*record.Depth), true
+	}},
+	"links":    {number: func(record *PageRecord) (float64, bool) { return float64(len(record.Links)), true }},
+	"inlinks":  {number: func(record *PageRecord) (float64, bool) { return float64(len(record.Inlinks)), true }},
+	"pagerank": {number: func(record *PageRecord) (float64, bool) { return record.PageRank, true }},
+	"assets":   {number: func(record *PageRecord) (float64, bool) { return float64(len(record.Assets)), true }},
+}
+
+// recordCondition is a single condition of a RecordFilter, comparing a field with a value
+type recordCondition struct {
+	field  string
+	op     string // =, !=, <, <=, >, >= or contains
+	value  string
+	number float64 // value of a numeric field
+}
+
+// RecordFilter selects the page records of a crawl document matching all of its conditions. See
+// ParseRecordFilter.
+type RecordFilter []recordCondition
+
+// ParseRecordFilter parses conditions joined by "and", each a field, an operator and a value, for example
+//
+//	depth > 3 and title contains "TODO"
+//
+// Numeric fields (depth, links, inlinks, pagerank and assets) can be compared with =, !=, <, <=, > and >=,
+// and other fields (url, path, title, canonical, contenthash, etag, soft404, lastmodified, protocol and
+// tlsversion) with =, != and contains (which ignores case). Values containing spaces must be quoted. An empty
+// expression matches every record.
+func ParseRecordFilter(expr string) (RecordFilter, error) {
+	tokens, err := tokenizeQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	var filter RecordFilter
+	for len(tokens) != 0 {
+		if len(filter) != 0 {
+			if !strings.EqualFold(tokens[0], "and") {
+				return nil, fmt.Errorf("expected \"and\" before %q", tokens[0])
+			}
+			tokens = tokens[1:]
+		}
+		if len(tokens) < 3 {
+			return nil, fmt.Errorf("incomplete condition %q, expected a field, operator and value", strings.Join(tokens, " "))
+		}
+		condition := recordCondition{field: strings.ToLower(tokens[0]), op: strings.ToLower(tokens[1]), value: tokens[2]}
+		field, found := recordFields[condition.field]
+		if !found {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", tokens[0], strings.Join(sortedKeys(recordFields), ", "))
+		}
+		if field.number != nil {
+			switch condition.op {
+			case "=", "!=", "<", "<=", ">", ">=":
+			default:
+				return nil, fmt.Errorf("operator %q can't be used with numeric field %s", tokens[1], condition.field)
+			}
+			if condition.number, err = strconv.ParseFloat(condition.value, 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q for field %s", condition.value, condition.field)
+			}
+		} else if condition.op != "=" && condition.op != "!=" && condition.op != "contains" {
+			return nil, fmt.Errorf("operator %q can't be used with text field %s", tokens[1], condition.field)
+		}
+		filter = append(filter, condition)
+		tokens = tokens[3:]
+	}
+	return filter, nil
+}
+
+// Matches checks if a record matches all the conditions of the filter
+func (filter RecordFilter) Matches(record *PageRecord) bool {
+	for _, condition := range filter {
+		if !condition.matches(record) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the records of the document matching the filter, in the order they are in the document
+func (filter RecordFilter) Select(doc *CrawlDocument) []*PageRecord {
+	var records []*PageRecord
+	for i := range doc.Pages {
+		if filter.Matches(&doc.Pages[i]) {
+			records = append(records, &doc.Pages[i])
+		}
+	}
+	return records
+}
+
+// matches checks if a record matches the condition
+func (condition recordCondition) matches(record *PageRecord) bool {
+	field := recordFields[condition.field]
+	if field.number != nil {
+		value, known := field.number(record)
+		if !known {
+			return false
+		}
+		switch condition.op {
+		case "=":
+			return value == condition.number
+		case "!=":
+			return value != condition.number
+		case "<":
+			return value < condition.number
+		case "<=":
+			return value <= condition.number
+		case ">":
+			return value > condition.number
+		default:
+			return value >= condition.number
+		}
+	}
+	value := field.text(record)
+	switch condition.op {
+	case "=":
+		return value == condition.value
+	case "!=":
+		return value != condition.value
+	default:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(condition.value))
+	}
+}
+
+// tokenizeQuery splits a query into words, quoted strings (with the quotes removed) and comparison operators
+func tokenizeQuery(query string) ([]string, error) {
+	var tokens []string
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quoted string in %q", query)
+			}
+			tokens = append(tokens, string(runes[i+1:end]))
+			i = end + 1
+		case strings.ContainsRune("=!<>", r):
+			end := i + 1
+			if end < len(runes) && runes[end] == '=' {
+				end++
+			}
+			op := string(runes[i:end])
+			if op == "!" {
+				return nil, fmt.Errorf("invalid operator ! in %q, expected !=", query)
+			}
+			tokens = append(tokens, op)
+			i = end
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("=!<>\"'", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, string(runes[i:end]))
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// RunQueryShell reads commands from in, writing the results of each to out, until the input ends or quit is
+// entered. The prompt is only shown if interactive is set. Commands are:
+//
+//	pages [where <conditions>] [limit <n>]	list the matching pages as a table (see ParseRecordFilter)
+//	count [where <conditions>]				count the matching pages
+//	fields									list the fields which can be queried
+//	help									show the commands
+//	quit									leave the shell
+func RunQueryShell(doc *CrawlDocument, in io.Reader, out io.Writer, interactive bool) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			if _, err := io.WriteString(out, queryShellPrompt); err != nil {
+				return err
+			}
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+		if err := RunQueryCommand(doc, line, out); err != nil {
+			if _, err := fmt.Fprintf(out, "error: %v\n", err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// RunQueryCommand runs a single query shell command (see RunQueryShell) against the document
+func RunQueryCommand(doc *CrawlDocument, command string, out io.Writer) error {
+	verb, rest, _ := strings.Cut(strings.TrimSpace(command), " ")
+	switch strings.ToLower(verb) {
+	case "":
+		return nil
+	case "help":
+		_, err := io.WriteString(out, "commands:\n"+
+			"  pages [where <conditions>] [limit <n>]   list matching pages, e.g. pages where depth > 3 and title contains \"TODO\"\n"+
+			"  count [where <conditions>]               count matching pages\n"+
+			"  fields                                   list the fields which can be queried\n"+
+			"  quit                                     leave the shell\n")
+		return err
+	case "fields":
+		var fields []string
+		for _, name := range sortedKeys(recordFields) {
+			kind := "text"
+			if recordFields[name].number != nil {
+				kind = "number"
+			}
+			fields = append(fields, name+" ("+kind+")")
+		}
+		_, err := fmt.Fprintln(out, strings.Join(fields, ", "))
+		return err
+	case "pages", "count":
+		where, limit, err := splitQueryClauses(rest)
+		if err != nil {
+			return err
+		}
+		filter, err := ParseRecordFilter(where)
+		if err != nil {
+			return err
+		}
+		records := filter.Select(doc)
+		if strings.ToLower(verb) == "count" {
+			_, err := fmt.Fprintf(out, "%d pages\n", len(records))
+			return err
+		}
+		total := len(records)
+		if limit > 0 && limit < total {
+			records = records[:limit]
+		}
+		return printRecordTable(out, records, total)
+	default:
+		return fmt.Errorf("unknown command %q, enter help for the commands", verb)
+	}
+}
+
+// splitQueryClauses splits the rest of a pages or count command into the conditions after "where" and the
+// number of pages after "limit" (0 if there is no limit)
+func splitQueryClauses(rest string) (string, int, error) {
+	where, limit := strings.TrimSpace(rest), 0
+	if words := strings.Fields(where); len(words) >= 2 && strings.EqualFold(words[len(words)-2], "limit") {
+		var err error
+		if limit, err = strconv.Atoi(words[len(words)-1]); err != nil || limit < 1 {
+			return "", 0, fmt.Errorf("invalid limit %q, expected a positive number", words[len(words)-1])
+		}
+		where = strings.TrimSpace(where[:strings.LastIndex(strings.ToLower(where), "limit")])
+	}
+	if len(where) == 0 {
+		return "", limit, nil
+	}
+	if keyword, conditions, _ := strings.Cut(where, " "); strings.EqualFold(keyword, "where") {
+		return conditions, limit, nil
+	}
+	return "", 0, fmt.Errorf("expected where before the conditions %q", where)
+}
+
+// printRecordTable writes records as a table of their URL, depth, inlinks and title, followed by the number
+// shown out of the total matching
+func printRecordTable(out io.Writer, records []*PageRecord, total int) error {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "URL\tDEPTH\tINLINKS\tTITLE")
+	for _, record := range records {
+		depth := "-"
+		if record.Depth != nil {
+			depth = strconv.Itoa(*record.Depth)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", record.URL, depth, len(record.Inlinks), record.Title)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if len(records) < total {
+		_, err := fmt.Fprintf(out, "(%d of %d pages)\n", len(records), total)
+		return err
+	}
+	_, err := fmt.Fprintf(out, "(%d pages)\n", total)
+	return err
+}
+
+// recordPath returns the path of the URL of a record, with / for the root
+func recordPath(record *PageRecord) string {
+	if pageURL, err := url.Parse(record.URL); err == nil {
+		return urlPath(pageURL)
+	}
+	return ""
+}
+
+// recordLastModified returns when the page of a record was last modified in RFC 3339 format, empty if unknown
+func recordLastModified(record *PageRecord) string {
+	if record.LastModified == nil {
+		return ""
+	}
+	return record.LastModified.Format(time.RFC3339)
+}
@@ -0,0 +1,70 @@
+package sitemap
+
+import (
+	"strings"
+	"testing"
+)
+
+// createQueryTestDocument creates a crawl document with pages at increasing depths
+func createQueryTestDocument() *CrawlDocument {
+	depth := func(d int) *int { return &d }
+	return &CrawlDocument{Site: "http://example.com", Pages: []PageRecord{
+		{URL: "http://example.com", Title: "Home", Depth: depth(0), Links: []string{"http://example.com/a", "http://example.com/b"}},
+		{URL: "http://example.com/a", Title: "TODO: write page A", Depth: depth(1), Inlinks: []string{"http://example.com"}},
+		{URL: "http://example.com/b", Title: "Page B", Depth: depth(4), Inlinks: []string{"http://example.com"}},
+		{URL: "http://example.com/orphan", Title: "Orphan todo"},
+	}}
+}
+
+func TestParseRecordFilter(t *testing.T) {
+
+	doc := createQueryTestDocument()
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{"", []string{"http://example.com", "http://example.com/a", "http://example.com/b", "http://example.com/orphan"}},
+		{"depth>3", []string{"http://example.com/b"}},
+		{"depth <= 1 and inlinks = 1", []string{"http://example.com/a"}},
+		{`title contains "todo"`, []string{"http://example.com/a", "http://example.com/orphan"}},
+		{"path = /b", []string{"http://example.com/b"}},
+		{"title != Home AND links=0", []string{"http://example.com/a", "http://example.com/b", "http://example.com/orphan"}},
+	}
+	for _, test := range tests {
+		filter, err := ParseRecordFilter(test.expr)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", test.expr, err)
+			continue
+		}
+		var urls []string
+		for _, record := range filter.Select(doc) {
+			urls = append(urls, record.URL)
+		}
+		if strings.Join(urls, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("Incorrect pages for %q: expected %v, got %v", test.expr, test.expected, urls)
+		}
+	}
+
+	for _, expr := range []string{"status", "depth > deep", "title > a", "depth contains 1", "nosuch = 1", `title = "open`, "depth ! 1", "depth > 1 or links > 1"} {
+		if _, err := ParseRecordFilter(expr); err == nil {
+			t.Errorf("Incorrect result for %q: expected an error, got none", expr)
+		}
+	}
+}
+
+func TestRunQueryShell(t *testing.T) {
+
+	input := "count where depth >= 1\npages where title contains todo limit 1\nbogus\nquit\ncount\n"
+	var out strings.Builder
+	if err := RunQueryShell(createQueryTestDocument(), strings.NewReader(input), &out, false); err != nil {
+		t.Fatalf("Query shell failed: %v", err)
+	}
+	expected := "2 pages\n" +
+		"URL                   DEPTH  INLINKS  TITLE\n" +
+		"http://example.com/a  1      1        TODO: write page A\n" +
+		"(1 of 2 pages)\n" +
+		"error: unknown command \"bogus\", enter help for the commands\n"
+	if out.String() != expected {
+		t.Errorf("Incorrect output: expected\n%v\ngot\n%v", expected, out.String())
+	}
+}
@@ -0,0 +1,184 @@
+package sitemap
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxRetryAfter is the default longest a host is held off for when the server asks the crawler to slow
+// down (see WithMaxRetryAfter)
+const DefaultMaxRetryAfter = time.Minute
+
+// defaultRateLimitHold is how long a host is held off for after a 429 (Too Many Requests) response which
+// doesn't say when to retry
+const defaultRateLimitHold = 10 * time.Second
+
+// maxRateLimitRetries is the number of times a URL rate limited by the server is retried, after which it is
+// recorded as failed
+const maxRateLimitRetries = 3
+
+// rateLimitResetEpoch is the smallest rate limit reset taken as a Unix timestamp rather than a number of
+// seconds, as servers send either (e.g. GitHub sends a timestamp)
+const rateLimitResetEpoch = 1000000000
+
+// retryAfter returns how long a server asks for requests to be held off, from the status and headers of its
+// response. That is the Retry-After header (in seconds or as an HTTP date) of a 429 or 503 response, or the
+// reset time of the X-RateLimit-Reset (or RateLimit-Reset) header once X-RateLimit-Remaining (or
+// RateLimit-Remaining) reaches 0. A 429 response with neither is held off for defaultRateLimitHold. Returns
+// 0 if the server isn't limiting requests.
+func retryAfter(header http.Header, statusCode int, now time.Time) time.Duration {
+	limited := statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+	if value := strings.TrimSpace(header.Get("Retry-After")); limited && len(value) != 0 {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return max(time.Duration(seconds)*time.Second, 0)
+		} else if date, err := http.ParseTime(value); err == nil {
+			return max(date.Sub(now), 0)
+		}
+	}
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(strings.TrimSpace(header.Get(prefix + "Remaining")))
+		if err != nil || remaining > 0 {
+			continue
+		}
+		if reset, err := strconv.ParseInt(strings.TrimSpace(header.Get(prefix+"Reset")), 10, 64); err == nil && reset >= rateLimitResetEpoch {
+			return max(time.Unix(reset, 0).Sub(now), 0)
+		} else if err == nil {
+			return max(time.Duration(reset)*time.Second, 0)
+		}
+	}
+	if statusCode == http.StatusTooManyRequests {
+		return defaultRateLimitHold
+	}
+	return 0
+}
+
+// hostHolds records the hosts the crawler is holding off requests to as their servers asked, and the URLs on
+// each held host waiting to be loaded once its hold expires. It is safe for concurrent use.
+type hostHolds struct {
+	mutex   sync.Mutex
+	until   map[string]time.Time   // time each held host is held off until
+	waiting map[string][]Hyperlink // URLs on each held host waiting for its hold to expire
+	retries map[string]int         // number of times each URL has been retried after being rate limited
+}
+
+// Hold holds off requests to a host until the supplied time, returning false if it was already held for
+// longer
+func (h *hostHolds) Hold(host string, until time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.until == nil {
+		h.until = make(map[string]time.Time)
+	}
+	if current, found := h.until[host]; found && !current.Before(until) {
+		return false
+	}
+	h.until[host] = until
+	return true
+}
+
+// Held checks if requests to a host are being held off at the supplied time
+func (h *hostHolds) Held(host string, now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	until, found := h.until[host]
+	return found && now.Before(until)
+}
+
+// Wait adds a URL on a held host to the URLs waiting for the hold to expire
+func (h *hostHolds) Wait(host string, link Hyperlink) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.waiting == nil {
+		h.waiting = make(map[string][]Hyperlink)
+	}
+	h.waiting[host] = append(h.waiting[host], link)
+}
+
+// Retry counts a retry of a rate limited URL, returning false once it has been retried maxRateLimitRetries
+// times
+func (h *hostHolds) Retry(urlStr string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.retries == nil {
+		h.retries = make(map[string]int)
+	}
+	if h.retries[urlStr] >= maxRateLimitRetries {
+		return false
+	}
+	h.retries[urlStr]++
+	return true
+}
+
+// Release ends the holds which have expired at the supplied time (or every hold if all is set), returning
+// the URLs which were waiting for them in the order they were added
+func (h *hostHolds) Release(now time.Time, all bool) []Hyperlink {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var released []Hyperlink
+	for host, until := range h.until {
+		if all || !now.Before(until) {
+			released = append(released, h.waiting[host]...)
+			delete(h.until, host)
+			delete(h.waiting, host)
+		}
+	}
+	return released
+}
+
+// Waiting returns the number of URLs waiting for holds to expire
+func (h *hostHolds) Waiting() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	waiting := 0
+	for _, links := range h.waiting {
+		waiting += len(links)
+	}
+	return waiting
+}
+
+// holdHost holds off requests to the host of a URL for the time the server asked for, up to the crawler's
+// maximum hold (see WithMaxRetryAfter), returning the host
+func (c *Crawler) holdHost(urlStr string, delay time.Duration) string {
+	host := urlHost(urlStr)
+	delay = min(delay, c.maxRetryAfter)
+	if c.holds.Hold(host, time.Now().Add(delay)) {
+		c.logger.Warn("Server asked for requests to be held off", "event", eventHold, "url", urlStr, "host", host, "duration", delay)
+	}
+	return host
+}
+
+// holdRateLimited checks the result of loading a URL for its server asking for requests to be held off,
+// holding the URL's host (only) if so. Returns true if the URL itself was rate limited, in which case it
+// waits to be loaded again once the hold expires rather than failing (unless already retried too often).
+func (c *Crawler) holdRateLimited(load Hyperlink, page *WebPage, err error) bool {
+	if c.maxRetryAfter <= 0 {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		host := c.holdHost(load.urlStr, statusErr.RetryAfter)
+		if c.holds.Retry(load.urlStr) {
+			c.holds.Wait(host, load)
+			return true
+		}
+	} else if page != nil {
+		if delay := retryAfter(page.Header, page.StatusCode, time.Now()); delay > 0 {
+			c.holdHost(page.URL.String(), delay)
+		}
+	}
+	return false
+}
+
+// urlHost returns the lowercase host (including any port) of a URL, which is empty if it can't be parsed
+func urlHost(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,134 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status   int
+		header   map[string]string
+		expected time.Duration
+	}{
+		{429, map[string]string{"Retry-After": "120"}, 2 * time.Minute},
+		{503, map[string]string{"Retry-After": "Wed, 01 May 2024 12:00:30 GMT"}, 30 * time.Second},
+		{503, map[string]string{"Retry-After": "Wed, 01 May 2024 11:00:00 GMT"}, 0},
+		{503, nil, 0},
+		{429, nil, defaultRateLimitHold},
+		{429, map[string]string{"Retry-After": "soon"}, defaultRateLimitHold},
+		{200, map[string]string{"Retry-After": "120"}, 0},
+		{200, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "45"}, 45 * time.Second},
+		{200, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(now.Unix() + 90)}, 90 * time.Second},
+		{200, map[string]string{"X-RateLimit-Remaining": "5", "X-RateLimit-Reset": "45"}, 0},
+		{200, map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "10"}, 10 * time.Second},
+		{403, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "20"}, 20 * time.Second},
+	}
+	for _, test := range tests {
+		header := make(http.Header)
+		for name, value := range test.header {
+			header.Set(name, value)
+		}
+		if got := retryAfter(header, test.status, now); got != test.expected {
+			t.Errorf("Incorrect retry after for status %d with %v: expected %v, got %v", test.status, test.header, test.expected, got)
+		}
+	}
+}
+
+func TestHostHolds(t *testing.T) {
+
+	var holds hostHolds
+	now := time.Now()
+	if !holds.Hold("a.com", now.Add(time.Minute)) || holds.Hold("a.com", now.Add(time.Second)) {
+		t.Errorf("Incorrect result holding a host: expected only the longer hold to be applied")
+	}
+	holds.Hold("b.com", now.Add(time.Second))
+	if !holds.Held("a.com", now) || holds.Held("c.com", now) {
+		t.Errorf("Incorrect hosts held: expected only a.com and b.com")
+	}
+	holds.Wait("a.com", Hyperlink{"http://a.com/1", 2})
+	holds.Wait("b.com", Hyperlink{"http://b.com/1", 2})
+	holds.Wait("a.com", Hyperlink{"http://a.com/2", 3})
+	if waiting := holds.Waiting(); waiting != 3 {
+		t.Errorf("Incorrect URLs waiting: expected 3, got %d", waiting)
+	}
+
+	released := holds.Release(now.Add(2*time.Second), false)
+	if len(released) != 1 || released[0].urlStr != "http://b.com/1" {
+		t.Errorf("Incorrect URLs released: expected http://b.com/1, got %v", released)
+	}
+	if holds.Held("b.com", now) || !holds.Held("a.com", now) {
+		t.Errorf("Incorrect hosts held once b.com released: expected only a.com")
+	}
+	released = holds.Release(now, true)
+	if len(released) != 2 || released[0].urlStr != "http://a.com/1" || released[1].urlStr != "http://a.com/2" {
+		t.Errorf("Incorrect URLs released: expected both a.com URLs in order, got %v", released)
+	}
+
+	for i := 1; i <= maxRateLimitRetries; i++ {
+		if !holds.Retry("http://a.com/1") {
+			t.Fatalf("Incorrect result for retry %d: expected it to be allowed", i)
+		}
+	}
+	if holds.Retry("http://a.com/1") {
+		t.Errorf("Incorrect result retrying too often: expected no more retries")
+	}
+}
+
+func TestCrawlRateLimited(t *testing.T) {
+
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		requests[req.URL.Path]++
+		count := requests[req.URL.Path]
+		mutex.Unlock()
+		switch {
+		case req.URL.Path == "/limited" && count == 1:
+			rw.Header().Set("Retry-After", "3600") // capped by the maximum retry after
+			http.Error(rw, "Too many requests", http.StatusTooManyRequests)
+			return
+		case req.URL.Path == "/unavailable":
+			http.Error(rw, "Service unavailable", http.StatusServiceUnavailable) // not rate limited without Retry-After
+			return
+		case req.URL.Path == "/busy":
+			rw.Header().Set("Retry-After", "1")
+			http.Error(rw, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(rw, `<html><body><a href="/limited">Limited</a><a href="/unavailable">Unavailable</a><a href="/busy">Busy</a></body></html>`)
+	}))
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap), WithMaxRetryAfter(50*time.Millisecond))
+	crawlWithin(t, crawler)
+
+	if _, found := siteMap.Pages[server.URL+"/limited"]; !found {
+		t.Errorf("Incorrect pages: expected the rate limited page to be loaded once retried, got %v", sortedKeys(siteMap.Pages))
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	expected := map[string]int{"/": 1, "/limited": 2, "/unavailable": 1, "/busy": maxRateLimitRetries + 1}
+	for path, count := range expected {
+		if requests[path] != count {
+			t.Errorf("Incorrect requests for %s: expected %d, got %d", path, count, requests[path])
+		}
+	}
+	errors := crawler.Errors()
+	if len(errors) != 2 || errors[0].URL != server.URL+"/busy" || errors[1].URL != server.URL+"/unavailable" {
+		t.Errorf("Incorrect errors: expected the always rate limited and unavailable pages, got %v", errors)
+	}
+
+	if _, err := CreateCrawler(mustParseURL(t, server.URL), WithMaxRetryAfter(-time.Second)); err == nil {
+		t.Errorf("Incorrect result for a negative maximum retry after: expected an error")
+	}
+}
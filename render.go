@@ -0,0 +1,125 @@
+//go:build headless
+
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultRenderTimeout is the default maximum time to wait for a page to be rendered in the browser
+const DefaultRenderTimeout = 30 * time.Second
+
+// renderSettleTime is how long a page is left to run scripts once loaded before its DOM is read, so links
+// added after the load event (e.g. by a single page app fetching its content) are found
+const renderSettleTime = 500 * time.Millisecond
+
+// RenderLoader implements the DocumentLoader interface for sites whose links are added by JavaScript, loading
+// each page with a DocLoader (so its status, headers and redirects are checked as usual) then rendering it in
+// a pool of headless Chrome tabs and parsing the resulting DOM in place of the HTML served. Requests made by
+// the browser don't use the DocLoader's client, so don't go through its proxies, DNS overrides or TLS options.
+type RenderLoader struct {
+	loader  *DocLoader           // loader checking and parsing the page as served
+	timeout time.Duration        // maximum time to wait for a page to render
+	tabs    chan context.Context // pool of browser tabs, each taken by one render at a time
+
+	cancelAlloc   context.CancelFunc // stops the browser process
+	cancelBrowser context.CancelFunc // closes the browser and its tabs
+}
+
+// CreateRenderLoader starts headless Chrome (found on the path) with poolSize tabs pages are rendered in
+// concurrently, each given at most timeout to render. Certificate errors are ignored by the browser if
+// insecure is set (see TLSOptions.InsecureSkipVerify). Close must be called to stop the browser.
+func CreateRenderLoader(loader *DocLoader, poolSize int, timeout time.Duration, insecure bool) (*RenderLoader, error) {
+	if poolSize < 1 {
+		return nil, fmt.Errorf("render pool size must be at least 1, got %d", poolSize)
+	}
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.Flag("ignore-certificate-errors", insecure))
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	render := &RenderLoader{
+		loader:        loader,
+		timeout:       timeout,
+		tabs:          make(chan context.Context, poolSize),
+		cancelAlloc:   cancelAlloc,
+		cancelBrowser: cancelBrowser,
+	}
+	if err := chromedp.Run(browserCtx); err != nil {
+		render.Close()
+		return nil, fmt.Errorf("failed to start headless Chrome: %v", err)
+	}
+	for i := 0; i < poolSize; i++ {
+		tabCtx, _ := chromedp.NewContext(browserCtx) // closed with the browser
+		render.tabs <- tabCtx
+	}
+	return render, nil
+}
+
+// Close stops the browser
+func (render *RenderLoader) Close() {
+	render.cancelBrowser()
+	render.cancelAlloc()
+}
+
+// LoadURL loads a page then renders it in the browser. See DocumentLoader interface for details. Pages
+// unchanged since a previous crawl (see DocLoader.UsePrevious) aren't rendered.
+func (render *RenderLoader) LoadURL(urlStr string) (*WebPage, error) {
+	page, err := render.loader.LoadURL(urlStr)
+	if err != nil || page == nil || page.StatusCode == http.StatusNotModified {
+		return page, err
+	}
+	start := time.Now()
+	doc, err := render.render(page.URL.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to render URL %s :%v", urlStr, err)
+	}
+	rendered, err := render.loader.parser.ParseDocument(page.URL.String(), strings.NewReader(doc))
+	if err != nil || rendered == nil {
+		return nil, fmt.Errorf("failed to parse rendered contents for URL %s :%v", urlStr, err)
+	}
+	mergeRendered(page, rendered)
+	page.Soft404 = render.loader.NotFound != nil && render.loader.NotFound.Matches(page)
+	if render.loader.AssetCheck {
+		render.loader.checkAssets(page)
+	}
+	render.loader.logger.Info("Rendered page", "url", urlStr, "duration", time.Since(start))
+	return page, nil
+}
+
+// render loads a URL in a browser tab, returning the HTML of its DOM once it has settled
+func (render *RenderLoader) render(urlStr string) (string, error) {
+	tab := <-render.tabs
+	defer func() { render.tabs <- tab }()
+	ctx, cancel := context.WithTimeout(tab, render.timeout)
+	defer cancel()
+	var doc string
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(urlStr),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.Sleep(renderSettleTime),
+		chromedp.OuterHTML("html", &doc, chromedp.ByQuery),
+	)
+	return doc, err
+}
+
+// mergeRendered replaces the details of a page parsed from its HTML with those parsed from its rendered DOM,
+// keeping the details of the response it was loaded with
+func mergeRendered(page *WebPage, rendered *WebPage) {
+	page.Title = rendered.Title
+	page.InternalLinks = rendered.InternalLinks
+	page.Canonical = rendered.Canonical
+	page.TextHash = rendered.TextHash
+	page.HrefIssues = rendered.HrefIssues
+	page.ExternalLinks = rendered.ExternalLinks
+	page.Assets = rendered.Assets
+	page.Hints = rendered.Hints
+	page.Languages = rendered.Languages
+	page.Icons = rendered.Icons
+	page.Manifest = rendered.Manifest
+	page.Breadcrumbs = rendered.Breadcrumbs
+}
@@ -0,0 +1,28 @@
+//go:build !headless
+
+package sitemap
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultRenderTimeout is the default maximum time to wait for a page to be rendered in the browser
+const DefaultRenderTimeout = 30 * time.Second
+
+// RenderLoader renders pages in headless Chrome. This build does not support rendering; build with -tags
+// headless to enable it (see render.go).
+type RenderLoader struct{}
+
+// CreateRenderLoader always fails, as this build does not support rendering
+func CreateRenderLoader(loader *DocLoader, poolSize int, timeout time.Duration, insecure bool) (*RenderLoader, error) {
+	return nil, fmt.Errorf("rendering with JavaScript is not supported by this build (build with -tags headless)")
+}
+
+// Close does nothing as the loader can never be created
+func (render *RenderLoader) Close() {}
+
+// LoadURL always fails as the loader can never be created
+func (render *RenderLoader) LoadURL(urlStr string) (*WebPage, error) {
+	return nil, fmt.Errorf("rendering with JavaScript is not supported by this build")
+}
@@ -0,0 +1,60 @@
+//go:build headless
+
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestMergeRendered(t *testing.T) {
+
+	page := createWebPage(t, "https://test.com/app", "Loading")
+	page.StatusCode, page.ContentHash = http.StatusOK, "abc"
+	page.AddLink("https://test.com/noscript", Link{})
+	rendered := createWebPage(t, "https://test.com/app", "App")
+	rendered.AddLink("https://test.com/app/list", Link{})
+	rendered.AddExternalLink("https://other.com")
+
+	mergeRendered(page, rendered)
+	if page.Title != "App" || fmt.Sprint(sortedKeys(page.InternalLinks)) != "[https://test.com/app/list]" || len(page.ExternalLinks) != 1 {
+		t.Errorf("Incorrect rendered details: expected App [https://test.com/app/list], got %v %v", page.Title, sortedKeys(page.InternalLinks))
+	}
+	if page.StatusCode != http.StatusOK || page.ContentHash != "abc" {
+		t.Errorf("Incorrect response details: expected %v %v, got %v %v", http.StatusOK, "abc", page.StatusCode, page.ContentHash)
+	}
+}
+
+func TestRenderLoader(t *testing.T) {
+
+	if _, err := exec.LookPath("google-chrome"); err != nil {
+		if _, err = exec.LookPath("chromium"); err != nil {
+			t.Skip("Chrome not installed")
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Content-Type", "text/html")
+		fmt.Fprint(rw, `<html><head><title>App</title></head><body><a href="/static">Static</a><script>
+			const link = document.createElement("a"); link.href = "/dynamic"; document.body.appendChild(link);
+			</script></body></html>`)
+	}))
+	defer server.Close()
+
+	render, err := CreateRenderLoader(CreateDocumentLoader(CreateDocumentParser()), 1, 10*time.Second, false)
+	if err != nil {
+		t.Fatalf("Failed to create render loader: %v", err)
+	}
+	defer render.Close()
+	page, err := render.LoadURL(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to render page: %v", err)
+	}
+	expected := fmt.Sprintf("[%s/dynamic %s/static]", server.URL, server.URL)
+	if got := fmt.Sprint(sortedKeys(page.InternalLinks)); got != expected {
+		t.Errorf("Incorrect links: expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,119 @@
+package sitemap
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Renderer writes a site map in an output format to any writer: the console, a file or an object in cloud
+// storage (see CreateDestination)
+type Renderer interface {
+	Render(w io.Writer, site *SiteMap) error
+}
+
+// documentWriters write a crawl document in each of the output formats other than text, by format name
+var documentWriters = map[string]func(w io.Writer, doc *CrawlDocument) error{
+	"json":    WriteJSON,
+	"csv":     WriteCSV,
+	"html":    WriteHTML,
+	"sql":     WriteSQL,
+	"gexf":    WriteGEXF,
+	"graphml": WriteGraphML,
+}
+
+// DocumentFormats returns the names of the output formats written by DocumentRenderer, sorted by name
+func DocumentFormats() []string {
+	return sortedKeys(documentWriters)
+}
+
+// TextRenderer renders the plain text site map showing the link structure of the site (see PrintSite),
+// followed by the URLs which failed to load (see PrintLoadErrors) and the links out of the section of the
+// site crawled (see PrintBoundaryLinks) if there are any
+type TextRenderer struct {
+	Root    string // URL of the page the site map starts from
+	Options TextOptions
+}
+
+// Render writes the site map as plain text
+func (renderer TextRenderer) Render(w io.Writer, site *SiteMap) error {
+	if err := PrintSite(w, renderer.Root, site, renderer.Options); err != nil {
+		return err
+	}
+	if len(site.Errors) != 0 {
+		if err := PrintLoadErrors(w, site.Errors, renderer.Options.Messages); err != nil {
+			return err
+		}
+	}
+	if len(site.Boundary) == 0 {
+		return nil
+	}
+	return PrintBoundaryLinks(w, site.Boundary, renderer.Options.Messages)
+}
+
+// PagesRenderer renders the pages of a site map selected by a query as a plain text list (see PrintPages)
+type PagesRenderer struct {
+	Query    PageQuery
+	Messages *Catalog // catalog for the language headings are written in (nil for English)
+}
+
+// Render writes the pages matching the query
+func (renderer PagesRenderer) Render(w io.Writer, site *SiteMap) error {
+	pages, err := site.Query(renderer.Query)
+	if err != nil {
+		return err
+	}
+	return PrintPages(w, pages, renderer.Messages)
+}
+
+// DocumentRenderer renders a site map as a crawl document in one of the document formats (JSON, CSV, HTML,
+// SQL, GEXF or GraphML), optionally only including the pages selected by a query
+type DocumentRenderer struct {
+	Format     string      // json, csv, html, sql, gexf or graphml
+	DepthStats *DepthStats // depth statistics included, nil for those using the DefaultDeepThreshold
+	Query      PageQuery   // pages included, with the zero value including every page
+}
+
+// Render writes the crawl document for the site map
+func (renderer DocumentRenderer) Render(w io.Writer, site *SiteMap) error {
+	write, found := documentWriters[renderer.Format]
+	if !found {
+		return fmt.Errorf("unknown output format %q", renderer.Format)
+	}
+	doc := CreateCrawlDocument(site)
+	if renderer.DepthStats != nil {
+		doc.DepthStats = renderer.DepthStats
+	}
+	if renderer.Query != (PageQuery{}) {
+		pages, err := site.Query(renderer.Query)
+		if err != nil {
+			return err
+		}
+		doc.Select(pages)
+	}
+	return write(w, doc)
+}
+
+// SitemapXMLRenderer renders a sitemap.xml listing the pages of a site map (see WriteSitemapXML), optionally
+// only including the pages selected by a query
+type SitemapXMLRenderer struct {
+	LastMod time.Time     // lastmod of pages not known to have been modified (none if zero)
+	Rules   []SitemapRule // rules setting the changefreq and priority of pages (none if empty)
+	Query   PageQuery     // pages included, with the zero value including every page
+}
+
+// Render writes the sitemap.xml for the site map
+func (renderer SitemapXMLRenderer) Render(w io.Writer, site *SiteMap) error {
+	pages := site.selectPages(func(key string, page *WebPage) bool { return true })
+	if renderer.Query != (PageQuery{}) {
+		var err error
+		if pages, err = site.Query(renderer.Query); err != nil {
+			return err
+		}
+	}
+	var priorities *SitemapPriorities
+	if len(renderer.Rules) != 0 {
+		priorities = CreateSitemapPriorities(renderer.Rules, site)
+	}
+	return WriteSitemapXML(w, pages, renderer.LastMod, priorities)
+}
@@ -0,0 +1,109 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// FrontierURL is a URL found while crawling which has still to be loaded, with its crawl depth
+type FrontierURL struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// CrawlState is the progress of a crawl run over multiple invocations (e.g. to map a very large site gently
+// with a daily page quota). It is saved to a JSON file after each invocation and the next invocation resumes
+// crawling from its frontier.
+type CrawlState struct {
+	StartURL   string        `json:"startUrl"`   // URL the crawl started from
+	Day        string        `json:"day"`        // day (YYYY-MM-DD) the pages in PagesToday were loaded on
+	PagesToday int           `json:"pagesToday"` // number of pages loaded on Day
+	Visited    []string      `json:"visited"`    // URLs already loaded (or which failed to load)
+	Frontier   []FrontierURL `json:"frontier"`   // URLs found but still to be loaded
+	Pages      []PageRecord  `json:"pages"`      // pages in the site map so far
+
+	fileName string
+}
+
+// LoadCrawlState reads the state of a crawl of startURL from a file. If the file doesn't exist the state of
+// a new crawl is returned.
+func LoadCrawlState(fileName string, startURL string) (*CrawlState, error) {
+	state := &CrawlState{StartURL: startURL, fileName: fileName}
+	data, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid crawl state file %s: %v", fileName, err)
+	}
+	if state.StartURL != startURL {
+		return nil, fmt.Errorf("crawl state file %s is for a crawl of %s, not %s", fileName, state.StartURL, startURL)
+	}
+	return state, nil
+}
+
+// Save writes the crawl state back to its file. The file is replaced atomically, so a crawl interrupted
+// while saving leaves the previous state to resume from.
+func (state *CrawlState) Save() error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(state.fileName, data)
+}
+
+// Started returns true if any pages have been crawled
+func (state *CrawlState) Started() bool {
+	return len(state.Visited) != 0
+}
+
+// Complete returns true if the crawl has finished, with no URLs left to load
+func (state *CrawlState) Complete() bool {
+	return state.Started() && len(state.Frontier) == 0
+}
+
+// RemainingQuota returns the number of pages which can still be loaded today with the supplied daily quota
+func (state *CrawlState) RemainingQuota(quota int, now time.Time) int {
+	state.startDay(now)
+	return max(quota-state.PagesToday, 0)
+}
+
+// startDay resets the number of pages loaded today if the day has changed
+func (state *CrawlState) startDay(now time.Time) {
+	if day := now.Format(time.DateOnly); day != state.Day {
+		state.Day, state.PagesToday = day, 0
+	}
+}
+
+// Restore adds the pages crawled so far to a site map
+func (state *CrawlState) Restore(site *SiteMap) error {
+	for _, record := range state.Pages {
+		page, err := CreateWebPageFromRecord(record)
+		if err != nil {
+			return fmt.Errorf("invalid crawl state file %s: %v", state.fileName, err)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update records the progress made by a completed crawl, which resumed from this state, and its site map
+func (state *CrawlState) Update(site *SiteMap, crawler *Crawler, now time.Time) {
+	visited := crawler.Visited()
+	state.startDay(now)
+	state.PagesToday += len(visited) - len(state.Visited)
+	state.Visited = visited
+	state.Frontier = crawler.Frontier()
+	state.Pages = make([]PageRecord, 0, len(site.Pages))
+	for _, page := range site.Pages {
+		state.Pages = append(state.Pages, CreatePageRecord(page))
+	}
+	sort.Slice(state.Pages, func(i, j int) bool { return state.Pages[i].URL < state.Pages[j].URL })
+}
@@ -0,0 +1,170 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// crawlWithState runs a single invocation of a crawl resumed from (and saving) a state file, loading no
+// more than the pages left in the daily quota. Returns the site map and state after crawling.
+func crawlWithState(t *testing.T, server *httptest.Server, fileName string, quota int, now time.Time) (*SiteMap, *CrawlState) {
+	state, err := LoadCrawlState(fileName, server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error loading crawl state: %v", err)
+	}
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	if err := state.Restore(siteMap); err != nil {
+		t.Fatalf("Unexpected error restoring crawl state: %v", err)
+	}
+	remaining := state.RemainingQuota(quota, now)
+	if remaining == 0 || state.Complete() {
+		return siteMap, state
+	}
+	opts := []Option{WithSink(siteMap), WithMaxPages(remaining)}
+	if state.Started() {
+		opts = append(opts, WithResume(state.Frontier, state.Visited))
+	}
+	crawler := createTestCrawler(t, server, opts...)
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error crawling: %v", err)
+	}
+	state.Update(siteMap, crawler, now)
+	if err := state.Save(); err != nil {
+		t.Fatalf("Unexpected error saving crawl state: %v", err)
+	}
+	return siteMap, state
+}
+
+func TestCrawlResume(t *testing.T) {
+
+	pages := map[string][]string{
+		"/":    {"/a", "/b", "/c"},
+		"/a":   {"/", "/a/1", "/a/2"},
+		"/b":   {"/a", "/b/1"},
+		"/c":   {},
+		"/a/1": {"/b/1"},
+		"/a/2": {},
+		"/b/1": {"/"},
+	}
+	site := createTestSite(pages)
+	defer site.Close()
+
+	// count the requests for each page to check none are loaded twice
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		requests[req.URL.Path]++
+		mutex.Unlock()
+		site.Config.Handler.ServeHTTP(rw, req)
+	}))
+	defer server.Close()
+
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// first day: the quota is used up and a second run the same day loads nothing more
+	siteMap, state := crawlWithState(t, server, fileName, 3, day)
+	if len(siteMap.Pages) != 3 || state.PagesToday != 3 || state.Complete() {
+		t.Fatalf("Incorrect first crawl: expected 3 pages, got %d pages with state %+v", len(siteMap.Pages), state)
+	}
+	siteMap, state = crawlWithState(t, server, fileName, 3, day.Add(time.Hour))
+	if len(siteMap.Pages) != 3 || state.PagesToday != 3 {
+		t.Fatalf("Incorrect crawl after quota used: expected 3 pages, got %d pages with state %+v", len(siteMap.Pages), state)
+	}
+
+	// later days resume the crawl until it is complete
+	for i := 1; i <= 2; i++ {
+		siteMap, state = crawlWithState(t, server, fileName, 3, day.AddDate(0, 0, i))
+	}
+	if !state.Complete() || len(state.Visited) != len(pages) {
+		t.Fatalf("Crawl not complete: got state %+v", state)
+	}
+	var got []string
+	for _, page := range siteMap.Pages {
+		got = append(got, page.URL.Path)
+	}
+	sort.Strings(got)
+	expected := []string{"", "/a", "/a/1", "/a/2", "/b", "/b/1", "/c"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Incorrect pages crawled: expected %v, got %v", expected, got)
+	}
+	for path, count := range requests {
+		if count != 1 {
+			t.Errorf("Incorrect number of requests for %s: expected 1, got %d", path, count)
+		}
+	}
+
+	// links between pages crawled on different days are kept
+	if links := siteMap.Pages[siteMap.pageKey(server.URL+"/a/1")].InternalLinks; links[server.URL+"/b/1"] == nil {
+		t.Errorf("Incorrect links restored: expected link to /b/1, got %v", links)
+	}
+}
+
+func TestCrawlStateQuota(t *testing.T) {
+
+	state, err := LoadCrawlState(filepath.Join(t.TempDir(), "missing.json"), "http://example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error loading missing crawl state: %v", err)
+	}
+	if state.Started() || state.Complete() {
+		t.Fatalf("Incorrect new crawl state: got %+v", state)
+	}
+
+	day := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	if remaining := state.RemainingQuota(10, day); remaining != 10 {
+		t.Errorf("Incorrect remaining quota: expected 10, got %d", remaining)
+	}
+	state.PagesToday = 12
+	if remaining := state.RemainingQuota(10, day.Add(30*time.Minute)); remaining != 0 {
+		t.Errorf("Incorrect remaining quota after quota used: expected 0, got %d", remaining)
+	}
+	if remaining := state.RemainingQuota(10, day.Add(90*time.Minute)); remaining != 10 || state.PagesToday != 0 {
+		t.Errorf("Incorrect remaining quota on the next day: expected 10, got %d", remaining)
+	}
+
+	// a state file can't be used for a different site
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	state = &CrawlState{StartURL: "http://example.com", fileName: fileName}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Unexpected error saving crawl state: %v", err)
+	}
+	if _, err := LoadCrawlState(fileName, "http://example.org"); err == nil {
+		t.Errorf("Missing expected error loading crawl state for a different site")
+	}
+}
+
+func TestCrawlStateSave(t *testing.T) {
+
+	// the state file is replaced each time it is saved, leaving no temporary files behind
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "state.json")
+	state := &CrawlState{StartURL: "http://example.com", fileName: fileName}
+	for _, frontier := range []string{"http://example.com/a", "http://example.com/b"} {
+		state.Frontier = []FrontierURL{{frontier, 2}}
+		if err := state.Save(); err != nil {
+			t.Fatalf("Unexpected error saving crawl state: %v", err)
+		}
+	}
+	loaded, err := LoadCrawlState(fileName, "http://example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error loading crawl state: %v", err)
+	}
+	if fmt.Sprint(loaded.Frontier) != fmt.Sprint(state.Frontier) {
+		t.Errorf("Incorrect frontier: expected %v, got %v", state.Frontier, loaded.Frontier)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "state.json" {
+		t.Errorf("Incorrect files after saving: expected [state.json], got %v", entries)
+	}
+}
@@ -0,0 +1,26 @@
+package sitemap
+
+import (
+	"sort"
+	"strings"
+)
+
+// BoundaryLink is a link to a URL outside the section of the site crawled (see WithRootPath)
+type BoundaryLink struct {
+	URL       string   // URL linked to
+	Referrers []string // URLs of the pages linking to the URL, sorted
+}
+
+// InPathPrefix checks if a URL path is the prefix (which has no trailing /) or a path below it
+func InPathPrefix(path string, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// AddBoundaryLinks records the URLs outside the root path linked to during the crawl of the site (see
+// Crawler.BoundaryLinks), with the pages in the site map linking to each, sorted by URL
+func (site *SiteMap) AddBoundaryLinks(urls []string) {
+	for _, urlStr := range urls {
+		site.Boundary = append(site.Boundary, BoundaryLink{urlStr, site.referrers(urlStr)})
+	}
+	sort.Slice(site.Boundary, func(i, j int) bool { return site.Boundary[i].URL < site.Boundary[j].URL })
+}
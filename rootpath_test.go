@@ -0,0 +1,70 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInPathPrefix(t *testing.T) {
+
+	tests := map[string]bool{
+		"/docs":       true,
+		"/docs/intro": true,
+		"/docsearch":  false,
+		"/":           false,
+		"/blog/docs":  false,
+	}
+	for path, expected := range tests {
+		if got := InPathPrefix(path, "/docs"); got != expected {
+			t.Errorf("Incorrect result for %s: expected %v, got %v", path, expected, got)
+		}
+	}
+}
+
+func TestCrawlRootPath(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/docs":       {"/docs/intro", "/", "/docsearch"},
+		"/docs/intro": {"/docs/api", "/blog"},
+		"/docs/api":   {"/docs"},
+		"/":           {"/docs", "/blog"},
+		"/blog":       {},
+		"/docsearch":  {},
+	})
+	defer server.Close()
+
+	start := mustParseURL(t, server.URL+"/docs")
+	siteMap := CreateSiteMap(start)
+	crawler, err := CreateCrawler(start, WithLoader(CreateDocumentLoader(CreateDocumentParser())), WithSink(siteMap),
+		WithThrottle(0), WithMaxPages(0), WithRootPath("/docs/"))
+	if err != nil {
+		t.Fatalf("Failed to create crawler: %v", err)
+	}
+	if err := crawler.Crawl(); err != nil {
+		t.Fatalf("Unexpected error from crawl: %v", err)
+	}
+	siteMap.AddBoundaryLinks(crawler.BoundaryLinks())
+
+	expected := []string{server.URL + "/docs", server.URL + "/docs/api", server.URL + "/docs/intro"}
+	if got := sortedKeys(siteMap.Pages); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Incorrect pages crawled: expected %v, got %v", expected, got)
+	}
+	expectedLinks := fmt.Sprintf("[{%[1]s [%[1]s/docs]} {%[1]s/blog [%[1]s/docs/intro]} {%[1]s/docsearch [%[1]s/docs]}]", server.URL)
+	if got := fmt.Sprint(siteMap.Boundary); got != expectedLinks {
+		t.Errorf("Incorrect boundary links: expected %v, got %v", expectedLinks, got)
+	}
+
+	var buf bytes.Buffer
+	if err := (TextRenderer{start.String(), TextOptions{}}).Render(&buf, siteMap); err != nil {
+		t.Fatalf("Failed to render site map: %v", err)
+	}
+	if expected := fmt.Sprintf(" %[1]s/blog: linked from %[1]s/docs/intro\n", server.URL); !strings.Contains(buf.String(), expected) {
+		t.Errorf("Incorrect text report: expected it to contain %q, got %q", expected, buf.String())
+	}
+
+	if _, err := CreateCrawler(start, WithRootPath("docs")); err == nil {
+		t.Errorf("Incorrect result for a relative root path: expected an error")
+	}
+}
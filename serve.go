@@ -0,0 +1,257 @@
+package sitemap
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCrawlJobs is the number of crawls a CrawlServer keeps, with the oldest finished crawls (and their site
+// maps) discarded beyond this
+const maxCrawlJobs = 100
+
+// Crawl job states
+const (
+	JobRunning  = "running"
+	JobComplete = "complete"
+	JobFailed   = "failed"
+)
+
+// errServerBusy is returned when starting a crawl while the maximum number of crawls are already running
+var errServerBusy = errors.New("too many crawls in progress")
+
+// SiteCrawlFunc crawls the site with the supplied starting URL, returning the site map of the completed crawl
+type SiteCrawlFunc func(start *url.URL) (*SiteMap, error)
+
+// CrawlJob describes a crawl requested from a CrawlServer, as served by its /crawl and /status endpoints
+type CrawlJob struct {
+	ID        string     `json:"id"`
+	Site      string     `json:"site"`               // starting URL of the crawl
+	State     string     `json:"state"`              // running, complete or failed
+	Started   time.Time  `json:"started"`            // when the crawl was requested
+	Finished  *time.Time `json:"finished,omitempty"` // when the crawl completed or failed
+	Pages     int        `json:"pages"`              // pages in the site map (once complete)
+	Truncated bool       `json:"truncated"`          // set if the crawl was truncated
+	Error     string     `json:"error,omitempty"`    // why the crawl failed
+
+	site *SiteMap // site map of the completed crawl
+}
+
+// CrawlServer crawls sites on demand, serving their site maps over HTTP (see Handler) so other services
+// can request site maps. Crawls run in the background, a limited number at a time.
+type CrawlServer struct {
+	crawl      SiteCrawlFunc
+	maxRunning int             // maximum number of crawls run at once
+	allowed    map[string]bool // hosts which may be crawled, empty for any
+	logger     Logger
+
+	mutex   sync.Mutex
+	jobs    map[string]*CrawlJob
+	order   []string // IDs of the jobs, oldest first
+	running int
+}
+
+// CreateCrawlServer creates a CrawlServer running up to maxRunning crawls at once using crawl. Only the hosts
+// listed in allowed (in lower case, e.g. "example.com") may be crawled, with any host allowed if it is empty.
+func CreateCrawlServer(crawl SiteCrawlFunc, maxRunning int, allowed []string) (*CrawlServer, error) {
+	if crawl == nil {
+		return nil, fmt.Errorf("crawl server requires a crawl function")
+	} else if maxRunning < 1 {
+		return nil, fmt.Errorf("maximum running crawls must be at least 1, got %d", maxRunning)
+	}
+	server := &CrawlServer{
+		crawl:      crawl,
+		maxRunning: maxRunning,
+		allowed:    make(map[string]bool),
+		logger:     defaultLogger(),
+		jobs:       make(map[string]*CrawlJob),
+	}
+	for _, host := range allowed {
+		server.allowed[strings.ToLower(host)] = true
+	}
+	return server, nil
+}
+
+// Start starts crawling a site (a URL or domain name) in the background, returning its job. If the site is
+// already being crawled the running job is returned instead of starting another.
+func (server *CrawlServer) Start(site string) (CrawlJob, error) {
+	start, err := parseSiteURL(site)
+	if err != nil {
+		return CrawlJob{}, err
+	}
+	if len(server.allowed) != 0 && !server.allowed[strings.ToLower(start.Hostname())] {
+		return CrawlJob{}, fmt.Errorf("crawling %s is not allowed", start.Hostname())
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, id := range server.order {
+		if job := server.jobs[id]; job.State == JobRunning && job.Site == start.String() {
+			return *job, nil
+		}
+	}
+	if server.running >= server.maxRunning {
+		return CrawlJob{}, errServerBusy
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return CrawlJob{}, err
+	}
+	job := &CrawlJob{ID: hex.EncodeToString(id[:]), Site: start.String(), State: JobRunning, Started: time.Now()}
+	server.jobs[job.ID] = job
+	server.order = append(server.order, job.ID)
+	server.running++
+	server.discardOldJobs()
+	go server.run(job, start)
+	return *job, nil
+}
+
+// run crawls the site of a job, recording the result
+func (server *CrawlServer) run(job *CrawlJob, start *url.URL) {
+	server.logger.Info("Crawl requested", "url", job.Site, "id", job.ID)
+	site, err := server.crawl(start)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.running--
+	finished := time.Now()
+	job.Finished = &finished
+	if err != nil {
+		job.State, job.Error = JobFailed, err.Error()
+		server.logger.Error("Requested crawl failed", "url", job.Site, "id", job.ID, "error", err)
+		return
+	}
+	job.State, job.site = JobComplete, site
+	job.Pages, job.Truncated = len(site.Pages), site.Truncated
+	server.logger.Info("Requested crawl complete", "url", job.Site, "id", job.ID, "pages", job.Pages, "duration", finished.Sub(job.Started))
+}
+
+// discardOldJobs discards the oldest finished jobs beyond maxCrawlJobs (the mutex must be held)
+func (server *CrawlServer) discardOldJobs() {
+	kept := server.order[:0]
+	excess := len(server.order) - maxCrawlJobs
+	for _, id := range server.order {
+		if excess > 0 && server.jobs[id].State != JobRunning {
+			delete(server.jobs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	server.order = kept
+}
+
+// Job returns the job with the supplied ID, along with its site map once complete
+func (server *CrawlServer) Job(id string) (CrawlJob, *SiteMap, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	job, found := server.jobs[id]
+	if !found {
+		return CrawlJob{}, nil, false
+	}
+	return *job, job.site, true
+}
+
+// Jobs returns all the jobs kept, most recent first
+func (server *CrawlServer) Jobs() []CrawlJob {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	jobs := make([]CrawlJob, 0, len(server.order))
+	for i := len(server.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, *server.jobs[server.order[i]])
+	}
+	return jobs
+}
+
+// Handler returns the HTTP handler of the server:
+//
+//	POST /crawl?site=example.com	starts crawling a site, returning its job (202 Accepted)
+//	GET /status?id=...				the job with the ID, or every job kept if no ID is given
+//	GET /sitemap.json?id=...		the JSON crawl document of a completed crawl (as written with -format json)
+//	GET /sitemap.xml?id=...			a sitemap.xml listing every page of a completed crawl
+//
+// The site map endpoints return 409 Conflict while the crawl is still running, or if it failed.
+func (server *CrawlServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /crawl", func(w http.ResponseWriter, r *http.Request) {
+		job, err := server.Start(r.FormValue("site"))
+		if errors.Is(err, errServerBusy) {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Location", "/status?id="+job.ID)
+		server.writeJSON(w, http.StatusAccepted, job)
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		id := r.FormValue("id")
+		if len(id) == 0 {
+			server.writeJSON(w, http.StatusOK, server.Jobs())
+		} else if job, _, found := server.Job(id); found {
+			server.writeJSON(w, http.StatusOK, job)
+		} else {
+			http.Error(w, "unknown crawl "+id, http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("GET /sitemap.json", server.serveSiteMap(writeSiteMapJSON))
+	mux.HandleFunc("GET /sitemap.xml", server.serveSiteMap(sitemapXMLWriter(nil)))
+	return mux
+}
+
+// serveSiteMap returns a handler writing the site map of the completed crawl with the requested ID using write
+func (server *CrawlServer) serveSiteMap(write func(w http.ResponseWriter, site *SiteMap) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.FormValue("id")
+		job, site, found := server.Job(id)
+		if !found {
+			http.Error(w, "unknown crawl "+id, http.StatusNotFound)
+			return
+		} else if job.State != JobComplete {
+			http.Error(w, "crawl "+id+" is "+job.State, http.StatusConflict)
+			return
+		}
+		w.Header().Set("Last-Modified", job.Finished.UTC().Format(http.TimeFormat))
+		if err := write(w, site); err != nil {
+			server.logger.Debug("Failed to write site map", "id", id, "error", err)
+		}
+	}
+}
+
+// writeJSON writes a value as an indented JSON response
+func (server *CrawlServer) writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		server.logger.Debug("Failed to write response", "error", err)
+	}
+}
+
+// parseSiteURL parses a site to crawl, which is either an absolute http(s) URL or a domain name
+func parseSiteURL(site string) (*url.URL, error) {
+	site = strings.TrimSpace(site)
+	if len(site) == 0 {
+		return nil, fmt.Errorf("no site supplied")
+	}
+	if !strings.Contains(site, "://") {
+		site = "http://" + site
+	}
+	start, err := url.Parse(site)
+	if err != nil {
+		return nil, fmt.Errorf("invalid site %q: %v", site, err)
+	} else if (start.Scheme != "http" && start.Scheme != "https") || len(start.Host) == 0 {
+		return nil, fmt.Errorf("invalid site %q: expected a domain name or http(s) URL", site)
+	}
+	return start, nil
+}
@@ -0,0 +1,144 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCrawlServer(t *testing.T) {
+
+	site := createTestSite(map[string][]string{
+		"/":  {"/a", "/b"},
+		"/a": {"/"},
+		"/b": {"/a"},
+	})
+	defer site.Close()
+
+	crawl := func(start *url.URL) (*SiteMap, error) {
+		siteMap := CreateSiteMap(start)
+		if err := createTestCrawler(t, site, WithSink(siteMap)).Crawl(); err != nil {
+			return nil, err
+		}
+		return siteMap, nil
+	}
+	server, err := CreateCrawlServer(crawl, 1, nil)
+	if err != nil {
+		t.Fatalf("Failed to create crawl server: %v", err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	resp, err := http.PostForm(api.URL+"/crawl", url.Values{"site": {site.URL}})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var job CrawlJob
+	err = json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode job: %v", err)
+	}
+	if resp.StatusCode != http.StatusAccepted || job.State != JobRunning {
+		t.Fatalf("Incorrect crawl response: expected %v running, got %v %v", http.StatusAccepted, resp.StatusCode, job.State)
+	}
+
+	// poll the status until the crawl completes
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State == JobRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Get(api.URL + "/status?id=" + job.ID)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Failed to decode job: %v", err)
+		}
+	}
+	if job.State != JobComplete || job.Pages != 3 {
+		t.Fatalf("Incorrect job: expected complete with 3 pages, got %v with %v pages (%v)", job.State, job.Pages, job.Error)
+	}
+
+	resp, err = http.Get(api.URL + "/sitemap.json?id=" + job.ID)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var doc CrawlDocument
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode crawl document: %v", err)
+	}
+	if len(doc.Pages) != 3 {
+		t.Errorf("Incorrect pages served: expected %v, got %v", 3, len(doc.Pages))
+	}
+	if resp, err := http.Get(api.URL + "/sitemap.xml?id=unknown"); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Incorrect status for unknown crawl: expected %v, got %v", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestCrawlServerLimits(t *testing.T) {
+
+	release := make(chan bool)
+	crawl := func(start *url.URL) (*SiteMap, error) {
+		<-release
+		return CreateSiteMap(start), nil
+	}
+	server, err := CreateCrawlServer(crawl, 1, []string{"Example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create crawl server: %v", err)
+	}
+	defer close(release)
+
+	if _, err := server.Start("other.com"); err == nil {
+		t.Errorf("Incorrect result for host not allowed: expected an error, got none")
+	}
+	first, err := server.Start("example.com")
+	if err != nil {
+		t.Fatalf("Failed to start crawl: %v", err)
+	}
+	if again, err := server.Start("http://example.com"); err != nil || again.ID != first.ID {
+		t.Errorf("Incorrect job for site already being crawled: expected %v, got %v (%v)", first.ID, again.ID, err)
+	}
+	if _, err := server.Start("https://example.com"); err != errServerBusy {
+		t.Errorf("Incorrect result with too many crawls: expected %v, got %v", errServerBusy, err)
+	}
+
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+	if resp, err := http.Get(api.URL + "/sitemap.json?id=" + first.ID); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusConflict {
+		t.Errorf("Incorrect status for running crawl: expected %v, got %v", http.StatusConflict, resp.StatusCode)
+	}
+}
+
+func TestParseSiteURL(t *testing.T) {
+
+	tests := []struct {
+		site     string
+		expected string
+	}{
+		{"example.com", "http://example.com"},
+		{" https://example.com/docs ", "https://example.com/docs"},
+		{"ftp://example.com", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		start, err := parseSiteURL(test.site)
+		if len(test.expected) == 0 {
+			if err == nil {
+				t.Errorf("Incorrect result for %q: expected an error, got %v", test.site, start)
+			}
+		} else if err != nil || start.String() != test.expected {
+			t.Errorf("Incorrect URL for %q: expected %v, got %v (%v)", test.site, test.expected, start, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package sitemap
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize is the number of consecutive words in each shingle used to calculate a SimHash
+const shingleSize = 4
+
+// SimHash calculates a 64 bit similarity hash of some text, where similar text gives hashes differing
+// in only a few bits. The text is normalized (lower case words, ignoring punctuation and spacing) then
+// split into overlapping shingles of consecutive words. Returns 0 if the text contains no words.
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	// each shingle votes for the value of each bit
+	var votes [64]int
+	shingles := len(words) - shingleSize + 1
+	if shingles < 1 {
+		shingles = 1
+	}
+	for i := 0; i < shingles; i++ {
+		end := i + shingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		hash := fnv.New64a()
+		hash.Write([]byte(strings.Join(words[i:end], " ")))
+		value := hash.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if value&(1<<bit) != 0 {
+				votes[bit]++
+			} else {
+				votes[bit]--
+			}
+		}
+	}
+	var simHash uint64
+	for bit, vote := range votes {
+		if vote > 0 {
+			simHash |= 1 << bit
+		}
+	}
+	return simHash
+}
+
+// simHashDistance returns the number of bits which differ between 2 similarity hashes
+func simHashDistance(h1 uint64, h2 uint64) int {
+	return bits.OnesCount64(h1 ^ h2)
+}
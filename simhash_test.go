@@ -0,0 +1,49 @@
+package sitemap
+
+import (
+	"strings"
+	"testing"
+)
+
+const simHashTestText = `The quick brown fox jumps over the lazy dog while the farmer watches from
+the gate. Later that evening the fox returns to the farm looking for chickens, but the dog is awake
+and chases it back into the woods beyond the river where it spends the night.`
+
+func TestSimHash(t *testing.T) {
+	base := SimHash(simHashTestText)
+	if base == 0 {
+		t.Fatalf("Missing hash for text")
+	}
+
+	// formatting changes give an identical hash
+	if reformatted := SimHash(strings.ToUpper(strings.ReplaceAll(simHashTestText, " ", "  \n"))); reformatted != base {
+		t.Errorf("Incorrect hash for reformatted text: expected %x, got %x", base, reformatted)
+	}
+
+	// a small edit gives a similar hash, different text doesn't
+	edited := SimHash(strings.Replace(simHashTestText, "farmer", "farmer's wife", 1))
+	different := SimHash("Completely unrelated content about the annual general meeting of the committee, " +
+		"the minutes of which are published below along with the budget for next year")
+	if distance := simHashDistance(base, edited); distance > 10 {
+		t.Errorf("Incorrect distance for edited text: expected at most 10, got %d", distance)
+	}
+	if distance := simHashDistance(base, different); distance <= 10 {
+		t.Errorf("Incorrect distance for different text: expected more than 10, got %d", distance)
+	}
+	if SimHash(" ... ") != 0 {
+		t.Errorf("Incorrect hash for text with no words: expected 0")
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	parser := CreateDocumentParser()
+	parser.TextHash = true
+	page, err := parser.ParseDocument("https://test.com", strings.NewReader(`<html><head><title>Title</title><style>p {}</style></head>
+<body><p>Hello <a href="/x">world</a></p><script>var x = 1;</script><noscript>enable js</noscript></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := SimHash("Hello world"); page.TextHash != expected {
+		t.Errorf("Incorrect text hash: expected %x (for %q), got %x", expected, "Hello world", page.TextHash)
+	}
+}
@@ -1,10 +1,13 @@
-package main
+package sitemap
 
 import (
 	"fmt"
+	"math"
+	"net/http"
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 )
 
 //
@@ -25,9 +28,38 @@ import (
 // We only store internal links and the page title however this could easily be extended to add any
 // other useful information we want to crawl (list of all external links, page size etc)
 type WebPage struct {
-	URL           *url.URL        // absolute URL for this page
-	Title         string          // HTML title of this page
-	InternalLinks map[string]bool // set of internal links out of this page (set as we only want each item once)
+	URL           *url.URL          // absolute URL for this page
+	Title         string            // HTML title of this page
+	InternalLinks map[string][]Link // internal links out of this page, mapping each URL to where it is linked from
+	Canonical     string            // canonical URL declared by the page, if it differs from the page URL
+	Aliases       map[string]bool   // other URLs which refer to this page (e.g. redirected from)
+	Alternates    []string          // other content types the page is available in via content negotiation
+	ContentHash   string            // hash of the page contents (empty if not known)
+	RecheckHash   string            // hash of the contents when requested a second time (empty if not rechecked)
+	TextHash      uint64            // similarity hash (SimHash) of the page text (0 if not known)
+	StatusCode    int               // HTTP status code the page was loaded with (0 if not known, 304 if unchanged since -previous)
+	LastModified  time.Time         // when the page was last modified, from its Last-Modified (or Date) header (zero if not known)
+	ETag          string            // entity tag the page was served with (empty if none)
+	Soft404       bool              // set if the page was loaded successfully but looks like the site's not found page
+	Header        http.Header       // HTTP response headers the page was loaded with (nil if not known)
+	Protocol      string            // HTTP protocol the page was loaded with, e.g. HTTP/2.0 (empty if not known)
+	TLSVersion    string            // TLS version the page was loaded over, e.g. TLS 1.3 (empty if not TLS or not known)
+	Metadata      map[string]string // extra details extracted from the page by a MetadataExtractor (nil if none)
+	HrefIssues    []HrefIssue       // internal links on the page whose href is not in its canonical encoding
+	ExternalLinks map[string]bool   // links out of this page to other domains (nil if none)
+	Assets        map[string]int    // static assets on the domain used by the page, mapped to their status (see AddAsset)
+	Hints         []ResourceHint    // resources on the domain the page asks browsers to fetch early (preload etc)
+	Languages     map[string]string // language variants declared with hreflang, from language code to URL (nil if none)
+	Icons         []string          // icons on the domain declared by the page (rel icon or apple-touch-icon)
+	Manifest      string            // web app manifest on the domain declared by the page (empty if none)
+	Breadcrumbs   []string          // URLs of the breadcrumb trail declared in JSON-LD, from the home page down (nil if none)
+	Feeds         []string          // RSS and Atom feeds declared by the page with <link rel="alternate"> (nil if none)
+	Videos        []Video           // videos on the page, when recorded (nil if none)
+	Description   string            // content of the page's meta description (empty if none)
+	H1            []string          // text of the page's H1 headings, in the order found
+	H2            []string          // text of the page's H2 headings, when recorded
+	SchemaTypes   []string          // schema.org types of the page's structured data, when recorded (sorted)
+	WordCount     int               // number of words of visible text on the page, when recorded
 }
 
 // CreateWebPage creates a new WebPage with a given URL and page title
@@ -35,13 +67,117 @@ func CreateWebPage(newURL *url.URL, title string) *WebPage {
 	page := &WebPage{
 		URL:           newURL,
 		Title:         title,
-		InternalLinks: make(map[string]bool),
+		InternalLinks: make(map[string][]Link),
+		Aliases:       make(map[string]bool),
 	}
 	// Normalise the URL so equivilent ones match
 	page.URL.Path = strings.TrimSuffix(page.URL.Path, "/")
 	return page
 }
 
+// AddLink records a link from this page to the supplied URL
+func (page *WebPage) AddLink(urlStr string, link Link) {
+	page.InternalLinks[urlStr] = append(page.InternalLinks[urlStr], link)
+}
+
+// AddAsset records a static asset (image, script or stylesheet) used by the page. Its status is 0 until it
+// is checked, when it is set to the HTTP status of the asset or AssetFailed if it could not be requested.
+func (page *WebPage) AddAsset(urlStr string) {
+	if page.Assets == nil {
+		page.Assets = make(map[string]int)
+	}
+	if _, found := page.Assets[urlStr]; !found {
+		page.Assets[urlStr] = 0
+	}
+}
+
+// AddExternalLink records a link out of the page to another domain
+func (page *WebPage) AddExternalLink(urlStr string) {
+	if page.ExternalLinks == nil {
+		page.ExternalLinks = make(map[string]bool)
+	}
+	page.ExternalLinks[urlStr] = true
+}
+
+// LinkContext is the part of a page a link appears in, based on the elements containing it
+type LinkContext int
+
+const (
+	LinkBody   LinkContext = iota // main content of the page
+	LinkNav                       // navigation (inside <nav> or role="navigation")
+	LinkFooter                    // footer (inside <footer> or role="contentinfo")
+	LinkFeed                      // an item in an RSS or Atom feed declared by the page (see DocLoader feedLinks)
+)
+
+// String returns the name of the link context
+func (context LinkContext) String() string {
+	switch context {
+	case LinkNav:
+		return "nav"
+	case LinkFooter:
+		return "footer"
+	case LinkFeed:
+		return "feed"
+	default:
+		return "body"
+	}
+}
+
+// ParseLinkContext converts a link context name (body, nav, footer or feed) to a LinkContext
+func ParseLinkContext(name string) (LinkContext, error) {
+	switch strings.ToLower(name) {
+	case "body":
+		return LinkBody, nil
+	case "nav":
+		return LinkNav, nil
+	case "footer":
+		return LinkFooter, nil
+	case "feed":
+		return LinkFeed, nil
+	}
+	return LinkBody, fmt.Errorf("unknown link context %q (expected body, nav, footer or feed)", name)
+}
+
+// Link is a single occurrence of a link on a page. A page may link to the same URL several times, with
+// different text or from different parts of the page.
+type Link struct {
+	Text    string      // anchor text (or the alt text of a linked image), with whitespace collapsed
+	Context LinkContext // part of the page the link appears in
+}
+
+// SchemePolicy controls how the http and https variants of the same page are stored in the site map
+type SchemePolicy int
+
+const (
+	SchemeDistinct    SchemePolicy = iota // http and https variants are stored as separate pages
+	SchemePreferHTTPS                     // variants are merged into a single page using the https URL
+	SchemePreferHTTP                      // variants are merged into a single page using the http URL
+)
+
+// ParseSchemePolicy converts a policy name (distinct, https or http) into a SchemePolicy
+func ParseSchemePolicy(name string) (SchemePolicy, error) {
+	switch strings.ToLower(name) {
+	case "distinct":
+		return SchemeDistinct, nil
+	case "https":
+		return SchemePreferHTTPS, nil
+	case "http":
+		return SchemePreferHTTP, nil
+	}
+	return SchemeDistinct, fmt.Errorf("unknown scheme policy %q (expected distinct, https or http)", name)
+}
+
+// preferredScheme returns the scheme used for merged pages, or an empty string if pages are not merged
+func (policy SchemePolicy) preferredScheme() string {
+	switch policy {
+	case SchemePreferHTTPS:
+		return "https"
+	case SchemePreferHTTP:
+		return "http"
+	}
+	return ""
+}
+
 // MapTraversalNode is a structure returned for each node when traversing the site map
 type MapTraversalNode struct {
 	Page  *WebPage // the page details
@@ -55,30 +191,78 @@ type SiteMapper interface {
 	// AddPage adds a page to the site map. If the page is already present it is ignored and we return false.
 	// If the page is invalid returns an error.
 	// Note that 2 pages are considered equivilent if they refer to the same resource, even though the actual
-	// URL string may differ
+	// URL string may differ. Depending on the scheme policy, the http and https variants of a page may also be
+	// considered equivilent, in which case the links of both are merged into a single page and we return false.
+	// A page which declares a different canonical URL is stored under its canonical URL, with its own URL (and
+	// any URLs it was redirected from) recorded as aliases of the canonical page.
 	AddPage(page *WebPage) (bool, error)
 
 	// TraverseSiteMap adds the pages in the site map to the supplied channel in depth first order suitable
 	// for rendering a site map.
 	//
 	// Note that all links are returned (so a page will be returned multiple times), however the children
-	// for any page are only traversed once (at the highest level at which the page appears). See main.go comments
-	// for more details.
+	// for any page are only traversed once (at the highest level at which the page appears). See the comments
+	// in cmd/go-sitemap/main.go for more details.
 	TraverseSiteMap(ch chan<- MapTraversalNode)
+
+	// TraverseSiteMapBFS adds the pages in the site map to the supplied channel in breadth first order, so
+	// pages are grouped by depth. Each page reachable from the root page is returned once, at the lowest depth
+	// at which it appears.
+	TraverseSiteMapBFS(ch chan<- MapTraversalNode)
+}
+
+// TraversalOrder controls the order pages are traversed in when rendering a site map
+type TraversalOrder int
+
+const (
+	OrderDFS     TraversalOrder = iota // depth first, showing the link structure (see TraverseSiteMap)
+	OrderBFS                           // breadth first, grouped by depth (see TraverseSiteMapBFS)
+	OrderAlpha                         // each page once, sorted by URL
+	OrderInlinks                       // each page once, sorted by the number of pages linking to it (most first)
+)
+
+// ParseTraversalOrder converts an order name (dfs, bfs, alpha or inlinks) into a TraversalOrder
+func ParseTraversalOrder(name string) (TraversalOrder, error) {
+	switch strings.ToLower(name) {
+	case "dfs":
+		return OrderDFS, nil
+	case "bfs":
+		return OrderBFS, nil
+	case "alpha":
+		return OrderAlpha, nil
+	case "inlinks":
+		return OrderInlinks, nil
+	}
+	return OrderDFS, fmt.Errorf("unknown order %q (expected dfs, bfs, alpha or inlinks)", name)
 }
 
 // SiteMap type implements the SiteMapper interface
 type SiteMap struct {
-	Domain   string              // name of the domain/website represented
-	RootPage string              // top of the website
-	Pages    map[string]*WebPage // URL for all web pages on the site
+	Domain           string              // name of the domain/website represented
+	RootPage         string              // top of the website
+	Pages            map[string]*WebPage // URL for all web pages on the site
+	SchemePolicy     SchemePolicy        // how http and https variants of a page are stored
+	URLPolicy        URLPolicy           // normalization applied to the URLs of pages before they are stored
+	SchemeDuplicates int                 // number of http/https page pairs merged into a single page
+	Aliases          map[string]string   // alias URL to the URL of the page it refers to
+	Truncated        bool                // true if crawling stopped before all pages were loaded
+	Errors           []LoadFailure       // URLs which failed to load, sorted by URL (see AddErrors)
+	Boundary         []BoundaryLink      // links out of the section of the site crawled (see AddBoundaryLinks)
+
+	variants   map[string]bool            // every page URL added, including those merged into another page
+	inlinks    map[string]map[string]bool // URL linked to, to the set of URLs of the pages linking to it
+	canonicals map[string]string          // URL of each page added declaring a canonical URL, to that URL
 }
 
 // CreateSiteMap creates a new, empty SiteMap for the given domain
 func CreateSiteMap(start *url.URL) *SiteMap {
 	return &SiteMap{Domain: start.Host,
-		RootPage: start.String(),
-		Pages:    make(map[string]*WebPage),
+		RootPage:   start.String(),
+		Pages:      make(map[string]*WebPage),
+		Aliases:    make(map[string]string),
+		variants:   make(map[string]bool),
+		inlinks:    make(map[string]map[string]bool),
+		canonicals: make(map[string]string),
 	}
 }
 
@@ -87,11 +271,164 @@ func (site *SiteMap) AddPage(page *WebPage) (bool, error) {
 	if page == nil {
 		return false, fmt.Errorf("SiteMap: Attempt to add empty page or url to site map")
 	}
-	if _, found := site.Pages[page.URL.String()]; found {
+	if site.variants == nil {
+		// not created by CreateSiteMap
+		site.initMaps()
+	}
+	urlStr := page.URL.String()
+	if site.variants[urlStr] {
 		return false, nil
 	}
-	site.Pages[page.URL.String()] = page
-	return true, nil
+	site.variants[urlStr] = true
+
+	// store the page under its canonical URL if it has one
+	if len(page.Canonical) != 0 && page.Canonical != urlStr {
+		canonicalURL, err := url.Parse(page.Canonical)
+		if err != nil {
+			return false, fmt.Errorf("SiteMap: Invalid canonical URL %s for page %s: %v", page.Canonical, urlStr, err)
+		}
+		site.canonicals[urlStr] = page.Canonical
+		page.Aliases[urlStr] = true
+		page.URL = canonicalURL
+	}
+	site.indexLinks(page)
+
+	key := site.lookupKey(page.URL.String())
+	existing, found := site.Pages[key]
+	if !found {
+		site.Pages[key] = page
+		site.addAliases(key, page)
+		return true, nil
+	}
+
+	if existing.URL.Scheme != page.URL.Scheme {
+		// the same page under a different scheme
+		site.SchemeDuplicates++
+		if page.URL.Scheme == site.SchemePolicy.preferredScheme() {
+			existing.Aliases[existing.URL.String()] = true
+			existing.URL = page.URL
+			if len(page.Title) != 0 {
+				existing.Title = page.Title
+			}
+		}
+	}
+	mergePage(existing, page)
+	site.addAliases(key, existing)
+	return false, nil
+}
+
+// initMaps creates the maps of a site map which are nil, for a site map not created by CreateSiteMap
+func (site *SiteMap) initMaps() {
+	if site.Pages == nil {
+		site.Pages = make(map[string]*WebPage)
+	}
+	if site.Aliases == nil {
+		site.Aliases = make(map[string]string)
+	}
+	site.variants = make(map[string]bool)
+	if site.inlinks == nil {
+		site.inlinks = make(map[string]map[string]bool)
+	}
+	if site.canonicals == nil {
+		site.canonicals = make(map[string]string)
+	}
+}
+
+// AddLink adds a link to a page already in the site map (links on pages being added are included by
+// AddPage). An error is returned if the page is not in the site map.
+func (site *SiteMap) AddLink(fromURL string, toURL string, link Link) error {
+	page, found := site.Pages[site.lookupKey(fromURL)]
+	if !found {
+		return fmt.Errorf("SiteMap: Attempt to add link from page %s not in site map", fromURL)
+	}
+	page.AddLink(toURL, link)
+	site.indexLinks(page)
+	return nil
+}
+
+// indexLinks adds the links out of a page to the index of links into each page. Links are indexed by the
+// URLs found in the page, as the page they refer to may only be known once its aliases have been added.
+func (site *SiteMap) indexLinks(page *WebPage) {
+	urlStr := page.URL.String()
+	for link := range page.InternalLinks {
+		sources, found := site.inlinks[link]
+		if !found {
+			sources = make(map[string]bool)
+			site.inlinks[link] = sources
+		}
+		sources[urlStr] = true
+	}
+}
+
+// addAliases records the aliases of the page stored under key. Any page already stored under an
+// alias is merged into it, and the aliases of the merged page then refer to key. Merging adds to the
+// page's aliases, so this repeats until no more pages are merged.
+func (site *SiteMap) addAliases(key string, page *WebPage) {
+	for merged := true; merged; {
+		merged = false
+		for _, alias := range sortedKeys(page.Aliases) {
+			aliasKey := site.pageKey(alias)
+			if aliasKey == key {
+				continue
+			}
+			if aliasPage, found := site.Pages[aliasKey]; found {
+				mergePage(page, aliasPage)
+				delete(site.Pages, aliasKey)
+				for from, to := range site.Aliases {
+					if to == aliasKey {
+						site.Aliases[from] = key
+					}
+				}
+				merged = true
+			}
+			site.Aliases[aliasKey] = key
+		}
+	}
+}
+
+// mergePage adds the links and aliases from page into existing
+func mergePage(existing *WebPage, page *WebPage) {
+	for link, occurrences := range page.InternalLinks {
+		if _, found := existing.InternalLinks[link]; !found {
+			existing.InternalLinks[link] = occurrences
+		}
+	}
+	for alias := range page.Aliases {
+		existing.Aliases[alias] = true
+	}
+	if page.URL.String() != existing.URL.String() {
+		existing.Aliases[page.URL.String()] = true
+	}
+}
+
+// lookupKey returns the key of the page stored for the supplied URL, following any chain of aliases
+func (site *SiteMap) lookupKey(urlStr string) string {
+	key := site.pageKey(urlStr)
+	for seen := map[string]bool{key: true}; ; {
+		canonical, found := site.Aliases[key]
+		if !found || seen[canonical] {
+			return key
+		}
+		seen[canonical] = true
+		key = canonical
+	}
+}
+
+// pageKey returns the key used to store the page with the supplied URL, applying the URL and scheme policies
+func (site *SiteMap) pageKey(urlStr string) string {
+	scheme := site.SchemePolicy.preferredScheme()
+	if len(scheme) == 0 && site.URLPolicy == (URLPolicy{}) {
+		return urlStr
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return urlStr
+	}
+	site.URLPolicy.Apply(u, site.Domain)
+	if len(scheme) != 0 {
+		u.Scheme = scheme
+	}
+	return u.String()
 }
 
 // TraverseSiteMap adds all pages to the supplied channel in depth first order suitable for rendering
@@ -103,7 +440,7 @@ func (site *SiteMap) TraverseSiteMap(ch chan<- MapTraversalNode) {
 	expanded := make(map[*WebPage]bool)
 	minPageHeights := site.getMinimumHeights()
 	// now do the depth first traversal
-	site.doDepthFirstTraversal(ch, minPageHeights, expanded, 0, site.RootPage)
+	site.doDepthFirstTraversal(ch, minPageHeights, expanded, 0, site.lookupKey(site.RootPage))
 }
 
 func (site *SiteMap) doDepthFirstTraversal(
@@ -111,7 +448,7 @@ func (site *SiteMap) doDepthFirstTraversal(
 	minPageHeights map[string]int, 		// shortest number of links to this page by any path
 	expanded map[*WebPage]bool, 		// pages already expanded
 	height int, 						// current traversal depth
-	url string) { 						// current page
+	url string) { 						// current page (as a page key)
 	if len(url) == 0 {
 		return
 	}
@@ -136,10 +473,13 @@ func (site *SiteMap) doDepthFirstTraversal(
 			// in alphabetical order based on url
 			expanded[page] = true
 			sorted := make([]string, 0, len(page.InternalLinks))
+			keys := make(map[string]bool)
 			for nextURL := range page.InternalLinks {
-				// ignore links back to same page
-				if nextURL != url {
-					sorted = append(sorted, nextURL)
+				// ignore links back to same page, and links to more than one alias of a page
+				nextKey := site.lookupKey(nextURL)
+				if nextKey != url && !keys[nextKey] {
+					keys[nextKey] = true
+					sorted = append(sorted, nextKey)
 				}
 			}
 			sort.Strings(sorted)
@@ -150,6 +490,370 @@ func (site *SiteMap) doDepthFirstTraversal(
 	}
 }
 
+// TraverseSiteMapBFS adds all pages reachable from the root page to the supplied channel in breadth first
+// order. See SiteMapper interface for details.
+func (site *SiteMap) TraverseSiteMapBFS(ch chan<- MapTraversalNode) {
+	defer close(ch)
+	rootKey := site.lookupKey(site.RootPage)
+	if _, found := site.Pages[rootKey]; !found {
+		return
+	}
+
+	// children are visited in alphabetical order, as for the depth first traversal
+	visited := map[string]bool{rootKey: true}
+	queue := []MapTraversalNode{{site.Pages[rootKey], 0}}
+	for len(queue) != 0 {
+		next := queue[0]
+		queue = queue[1:]
+		ch <- next
+
+		var children []string
+		for link := range next.Page.InternalLinks {
+			childKey := site.lookupKey(link)
+			if _, found := site.Pages[childKey]; found && !visited[childKey] {
+				visited[childKey] = true
+				children = append(children, childKey)
+			}
+		}
+		sort.Strings(children)
+		for _, childKey := range children {
+			queue = append(queue, MapTraversalNode{site.Pages[childKey], next.Depth + 1})
+		}
+	}
+}
+
+// TraverseSiteMapOrdered adds all pages to the supplied channel in the order requested. For the alpha and
+// inlinks orders, each page reachable from the root page is returned once with its minimum depth.
+func (site *SiteMap) TraverseSiteMapOrdered(ch chan<- MapTraversalNode, order TraversalOrder) {
+	switch order {
+	case OrderBFS:
+		site.TraverseSiteMapBFS(ch)
+		return
+	case OrderAlpha, OrderInlinks:
+		break
+	default:
+		site.TraverseSiteMap(ch)
+		return
+	}
+
+	defer close(ch)
+	heights := site.getMinimumHeights()
+	nodes := make([]MapTraversalNode, 0, len(heights))
+	for key, height := range heights {
+		nodes = append(nodes, MapTraversalNode{site.Pages[key], height})
+	}
+	var inlinks map[string]int
+	if order == OrderInlinks {
+		inlinks = site.InlinkCounts()
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		url1, url2 := nodes[i].Page.URL.String(), nodes[j].Page.URL.String()
+		if inlinks != nil {
+			count1, count2 := inlinks[site.lookupKey(url1)], inlinks[site.lookupKey(url2)]
+			if count1 != count2 {
+				return count1 > count2
+			}
+		}
+		return url1 < url2
+	})
+	for _, node := range nodes {
+		ch <- node
+	}
+}
+
+// InlinkCounts returns the number of other pages in the site map linking to each page, keyed by page key.
+// Pages with no links to them are not included.
+func (site *SiteMap) InlinkCounts() map[string]int {
+	counts := make(map[string]int)
+	for key, sources := range site.Inlinks() {
+		counts[key] = len(sources)
+	}
+	return counts
+}
+
+// Inlinks returns the URLs of the other pages in the site map linking to each page (sorted), keyed by page
+// key. Pages with no links to them are not included. Links are indexed as pages are added, so links added
+// directly to a page already in the site map (rather than with SiteMap.AddLink) are not included.
+func (site *SiteMap) Inlinks() map[string][]string {
+	linked := make(map[string]map[string]bool)
+	for link, sources := range site.inlinks {
+		key := site.lookupKey(link)
+		if _, found := site.Pages[key]; !found {
+			continue
+		}
+		for source := range sources {
+			sourcePage, found := site.Pages[site.lookupKey(source)]
+			if !found || sourcePage == site.Pages[key] {
+				continue
+			}
+			if linked[key] == nil {
+				linked[key] = make(map[string]bool)
+			}
+			linked[key][sourcePage.URL.String()] = true
+		}
+	}
+	inlinks := make(map[string][]string, len(linked))
+	for key, sources := range linked {
+		inlinks[key] = sortedKeys(sources)
+	}
+	return inlinks
+}
+
+// PageRank damping factor and convergence limits used by ComputePageRank
+const (
+	pageRankDamping       = 0.85
+	pageRankTolerance     = 1e-9 // stop once the total change in scores in an iteration is below this
+	pageRankMaxIterations = 100
+)
+
+// ComputePageRank calculates the PageRank of each page over the internal link graph, keyed by page key.
+// Scores sum to 1, with higher scores for pages the site's own link structure treats as more important.
+// Each page's score is shared equally between the distinct other pages it links to, and the scores of
+// pages with no links out are shared between all pages.
+func (site *SiteMap) ComputePageRank() map[string]float64 {
+	// pages are numbered in key order so the scores calculated are always the same
+	keys := sortedKeys(site.Pages)
+	n := len(keys)
+	index := make(map[string]int, n)
+	for i, key := range keys {
+		index[key] = i
+	}
+
+	// the distinct other pages each page links to
+	outlinks := make([][]int, n)
+	for i, key := range keys {
+		linked := make(map[int]bool)
+		for _, link := range sortedKeys(site.Pages[key].InternalLinks) {
+			if j, found := index[site.lookupKey(link)]; found && j != i && !linked[j] {
+				linked[j] = true
+				outlinks[i] = append(outlinks[i], j)
+			}
+		}
+	}
+
+	ranks := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = 1 / float64(n)
+	}
+	for iteration := 0; iteration < pageRankMaxIterations; iteration++ {
+		dangling := 0.0
+		for i, rank := range ranks {
+			if len(outlinks[i]) == 0 {
+				dangling += rank
+			}
+		}
+		base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+		next := make([]float64, n)
+		for i := range next {
+			next[i] = base
+		}
+		for i, targets := range outlinks {
+			share := pageRankDamping * ranks[i] / float64(len(targets))
+			for _, j := range targets {
+				next[j] += share
+			}
+		}
+		change := 0.0
+		for i, rank := range next {
+			change += math.Abs(rank - ranks[i])
+		}
+		ranks = next
+		if change < pageRankTolerance {
+			break
+		}
+	}
+
+	scores := make(map[string]float64, n)
+	for i, key := range keys {
+		scores[key] = ranks[i]
+	}
+	return scores
+}
+
+// LinkPopularity is a page along with the number of other pages linking to it
+type LinkPopularity struct {
+	URL     string // URL of the page
+	Inlinks int    // number of other pages linking to it
+}
+
+// LinkPopularity returns every page in the site map along with the number of other pages linking to it,
+// with the most linked to pages first (and pages with the same number of links sorted by URL)
+func (site *SiteMap) LinkPopularity() []LinkPopularity {
+	counts := site.InlinkCounts()
+	pages := make([]LinkPopularity, 0, len(site.Pages))
+	for key, page := range site.Pages {
+		pages = append(pages, LinkPopularity{page.URL.String(), counts[key]})
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if pages[i].Inlinks != pages[j].Inlinks {
+			return pages[i].Inlinks > pages[j].Inlinks
+		}
+		return pages[i].URL < pages[j].URL
+	})
+	return pages
+}
+
+// URLPair is a pair of URLs found in the site map, along with a suggested canonical URL
+type URLPair struct {
+	URL       string // the URL found
+	Canonical string // the URL suggested as its canonical form
+}
+
+// QueryDuplicates returns the pages whose URL has a query string and which have identical content to the
+// page at the same URL without the query string. These suggest the query string can be dropped (or the URL
+// canonicalised). The results are sorted by URL.
+func (site *SiteMap) QueryDuplicates() []URLPair {
+	var pairs []URLPair
+	for _, page := range site.Pages {
+		if len(page.URL.RawQuery) == 0 || len(page.ContentHash) == 0 {
+			continue
+		}
+		withoutQuery := *page.URL
+		withoutQuery.RawQuery = ""
+		withoutQuery.ForceQuery = false
+		other, found := site.Pages[site.lookupKey(withoutQuery.String())]
+		if found && other != page && other.ContentHash == page.ContentHash {
+			pairs = append(pairs, URLPair{page.URL.String(), other.URL.String()})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].URL < pairs[j].URL })
+	return pairs
+}
+
+// DuplicateGroup is a group of pages with identical or near identical content
+type DuplicateGroup struct {
+	URLs      []string // URLs of the pages in the group (sorted)
+	Identical bool     // set if every page has identical content, otherwise only their text is similar
+}
+
+// DuplicateContent returns the groups of pages with identical content (the same content hash) or near
+// identical text (similarity hashes differing in at most maxDistance bits), sorted by their first URL.
+// Near identical text is only checked if maxDistance is not negative, for pages with a similarity hash.
+// This often finds misconfigured aliases, such as the same page served for many query strings.
+func (site *SiteMap) DuplicateContent(maxDistance int) []DuplicateGroup {
+
+	// first group pages with identical content
+	var clusters [][]*WebPage
+	byHash := make(map[string]int)
+	for _, page := range site.Pages {
+		if index, found := byHash[page.ContentHash]; found && len(page.ContentHash) != 0 {
+			clusters[index] = append(clusters[index], page)
+			continue
+		}
+		byHash[page.ContentHash] = len(clusters)
+		clusters = append(clusters, []*WebPage{page})
+	}
+
+	// then merge clusters with similar text, comparing each pair (using the first page of each cluster)
+	parent := make([]int, len(clusters))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	if maxDistance >= 0 {
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				h1, h2 := clusters[i][0].TextHash, clusters[j][0].TextHash
+				if h1 != 0 && h2 != 0 && simHashDistance(h1, h2) <= maxDistance {
+					parent[root(j)] = root(i)
+				}
+			}
+		}
+	}
+	merged := make(map[int][]int)
+	for i := range clusters {
+		merged[root(i)] = append(merged[root(i)], i)
+	}
+
+	var groups []DuplicateGroup
+	for _, members := range merged {
+		group := DuplicateGroup{Identical: len(members) == 1}
+		for _, index := range members {
+			for _, page := range clusters[index] {
+				group.URLs = append(group.URLs, page.URL.String())
+			}
+		}
+		if len(group.URLs) > 1 {
+			sort.Strings(group.URLs)
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].URLs[0] < groups[j].URLs[0] })
+	return groups
+}
+
+// SitemapCoverage compares the pages reachable by following links from the root page with the pages
+// listed in the site's sitemap.xml
+type SitemapCoverage struct {
+	Listed   int      // number of distinct pages listed in the sitemap.xml
+	Orphans  []string // listed in the sitemap.xml but not reachable by following links (sorted)
+	Unlisted []string // reachable by following links but missing from the sitemap.xml (sorted)
+}
+
+// CompareSitemap compares the site map with the page URLs listed in a sitemap.xml. URLs are matched
+// using the site's scheme policy and aliases (so a sitemap listing a URL which redirects to a page
+// counts as listing that page).
+func (site *SiteMap) CompareSitemap(listed []string) SitemapCoverage {
+	var coverage SitemapCoverage
+	heights := site.getMinimumHeights()
+	listedKeys := make(map[string]bool)
+	for _, urlStr := range listed {
+		key := site.lookupKey(strings.TrimSuffix(urlStr, "/"))
+		if listedKeys[key] {
+			continue
+		}
+		listedKeys[key] = true
+		if _, reachable := heights[key]; !reachable {
+			coverage.Orphans = append(coverage.Orphans, urlStr)
+		}
+	}
+	for key := range heights {
+		if !listedKeys[key] {
+			coverage.Unlisted = append(coverage.Unlisted, site.Pages[key].URL.String())
+		}
+	}
+	coverage.Listed = len(listedKeys)
+	sort.Strings(coverage.Orphans)
+	sort.Strings(coverage.Unlisted)
+	return coverage
+}
+
+// ChangedSince returns the pages which are new or have changed since a previous crawl of the site, sorted
+// by URL. Pages are matched using the site's scheme policy and aliases, and a page has changed if its
+// content hash differs or, if either crawl didn't record one, its title or links differ.
+func (site *SiteMap) ChangedSince(previous *CrawlDocument) []*WebPage {
+	records := make(map[string]*PageRecord, len(previous.Pages))
+	for i := range previous.Pages {
+		record := &previous.Pages[i]
+		records[site.lookupKey(record.URL)] = record
+		for _, alias := range record.Aliases {
+			records[site.lookupKey(alias)] = record
+		}
+	}
+	var changed []*WebPage
+	for key, page := range site.Pages {
+		if record, found := records[key]; !found || pageChanged(page, record) {
+			changed = append(changed, page)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].URL.String() < changed[j].URL.String() })
+	return changed
+}
+
+// pageChanged checks if a page differs from its record in a previous crawl
+func pageChanged(page *WebPage, record *PageRecord) bool {
+	if len(page.ContentHash) != 0 && len(record.ContentHash) != 0 {
+		return page.ContentHash != record.ContentHash
+	}
+	return page.Title != record.Title || fmt.Sprint(sortedKeys(page.InternalLinks)) != fmt.Sprint(record.Links)
+}
+
 type heightQueueEntry struct {
 	url    string
 	height int
@@ -175,11 +879,16 @@ func (site *SiteMap) getMinimumHeights() map[string]int {
 	// lifetime is very short.
 	//
 	queue := make(heightQueue, 0)
-	queue = append(queue, heightQueueEntry{site.RootPage, 0})
+	queue = append(queue, heightQueueEntry{site.lookupKey(site.RootPage), 0})
 	for len(queue) != 0 {
 		next := queue[0]  // top item from queue
 		queue = queue[1:] // pop top item
 
+		// a page can be queued more than once before it is processed, its height is the first one seen
+		if _, seen := heights[next.url]; seen {
+			continue
+		}
+
 		// get the page details and process the links
 		page, found := site.Pages[next.url]
 		if !found {
@@ -195,8 +904,9 @@ func (site *SiteMap) getMinimumHeights() map[string]int {
 		//
 		newHeight := next.height + 1
 		for child := range page.InternalLinks {
-			if _, found := heights[child]; !found {
-				queue = append(queue, heightQueueEntry{child, newHeight})
+			childKey := site.lookupKey(child)
+			if _, found := heights[childKey]; !found {
+				queue = append(queue, heightQueueEntry{childKey, newHeight})
 			}
 		}
 	}
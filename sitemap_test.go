@@ -1,7 +1,10 @@
-package main
+package sitemap
 
 import (
+	"fmt"
+	"math"
 	"net/url"
+	"reflect"
 	"testing"
 )
 
@@ -47,10 +50,10 @@ func TestSiteMap(t *testing.T) {
 	level2_1_1 := addPage(t, site, true, urlBase+"/1/1", "1_1")
 	level2_1_2 := addPage(t, site, true, urlBase+"/1/2", "1_2")
 	level2_1_3 := addPage(t, site, true, urlBase+"/1/3", "1_3")
-	level1.InternalLinks[level2_1_1.URL.String()] = true
-	level1.InternalLinks[level2_1_2.URL.String()] = true
-	level1.InternalLinks[level2_1_3.URL.String()] = true
-	level1.InternalLinks[level1.URL.String()] = true
+	level1.AddLink(level2_1_1.URL.String(), Link{})
+	level1.AddLink(level2_1_2.URL.String(), Link{})
+	level1.AddLink(level2_1_3.URL.String(), Link{})
+	level1.AddLink(level1.URL.String(), Link{})
 
 	// add some duplicate pages - these should fail to add
 	addPage(t, site, false, urlBase+"/1/2", "Duplicate")
@@ -61,23 +64,23 @@ func TestSiteMap(t *testing.T) {
 	level3_1_1_1 := addPage(t, site, true, urlBase+"/1/1/1", "1_1_1")
 	level3_1_1_2 := addPage(t, site, true, urlBase+"/1/1/2", "1_1_2")
 	level3_1_3_1 := addPage(t, site, true, urlBase+"/1/3/1", "1_3_2")
-	level2_1_1.InternalLinks[level3_1_1_1.URL.String()] = true
-	level2_1_1.InternalLinks[level3_1_1_2.URL.String()] = true
-	level2_1_3.InternalLinks[level3_1_3_1.URL.String()] = true
-	level2_1_3.InternalLinks[level3_1_1_1.URL.String()] = true // duplicate at same level
-	level2_1_3.InternalLinks[level1.URL.String()] = true       // link back to higher level (should be skipped)
-	level2_1_3.InternalLinks[level3_1_1_1.URL.String()] = true // link to same level (should be displayed)
+	level2_1_1.AddLink(level3_1_1_1.URL.String(), Link{})
+	level2_1_1.AddLink(level3_1_1_2.URL.String(), Link{})
+	level2_1_3.AddLink(level3_1_3_1.URL.String(), Link{})
+	level2_1_3.AddLink(level3_1_1_1.URL.String(), Link{}) // duplicate at same level
+	level2_1_3.AddLink(level1.URL.String(), Link{})       // link back to higher level (should be skipped)
+	level2_1_3.AddLink(level3_1_1_1.URL.String(), Link{}) // link to same level (should be displayed)
 
 	// level 4
 	// Add a child under 1_1_1 which should only appear once (as 1_1_1 should only be expanded once)
 	level4_1_1_1_1 := addPage(t, site, true, urlBase+"/1/1/1/1", "1_1_1_1")
-	level3_1_1_1.InternalLinks[level4_1_1_1_1.URL.String()] = true
+	level3_1_1_1.AddLink(level4_1_1_1_1.URL.String(), Link{})
 
 	// last level 5 which should be ignored (links back to parent level)
-	level4_1_1_1_1.InternalLinks[level3_1_3_1.URL.String()] = true
+	level4_1_1_1_1.AddLink(level3_1_3_1.URL.String(), Link{})
 
 	// write structure if test fails for debugging
-	//	PrintSite("", urlBase, site)
+	//	PrintSite(os.Stdout, urlBase, site)
 
 	// traverse the site map, fill the channel with nodes in (hopefully) correct order
 	ch := make(chan MapTraversalNode, 100)
@@ -100,6 +103,209 @@ func TestSiteMap(t *testing.T) {
 	}
 }
 
+// Test that http and https variants of a page are merged according to the scheme policy
+func TestSiteMapSchemePolicy(t *testing.T) {
+
+	URL, err := url.Parse("http://test.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := CreateSiteMap(URL)
+	site.SchemePolicy = SchemePreferHTTPS
+
+	root := addPage(t, site, true, "http://test.com", "Root")
+	httpPage := addPage(t, site, true, "http://test.com/1", "HTTP")
+	child := addPage(t, site, true, "https://test.com/1/1", "Child")
+	root.AddLink("http://test.com/1", Link{})
+	root.AddLink("https://test.com/1", Link{})
+
+	// links from the https variant should be merged into the existing page
+	httpsPage := createWebPage(t, "https://test.com/1", "HTTPS")
+	httpsPage.AddLink(child.URL.String(), Link{})
+	if added, err := site.AddPage(httpsPage); added || err != nil {
+		t.Fatalf("Unexpected result merging page: expected (false, nil), got (%v, %v)", added, err)
+	}
+	addPage(t, site, false, "https://test.com/1", "Duplicate")
+
+	if len(site.Pages) != 3 {
+		t.Fatalf("Incorrect number of pages: expected %d, got %d", 3, len(site.Pages))
+	}
+	if site.SchemeDuplicates != 1 {
+		t.Fatalf("Incorrect number of scheme duplicates: expected %d, got %d", 1, site.SchemeDuplicates)
+	}
+	if httpPage.URL.String() != "https://test.com/1" || httpPage.Title != "HTTPS" {
+		t.Fatalf("Merged page not using preferred scheme: got %s [%s]", httpPage.URL, httpPage.Title)
+	}
+
+	// the merged page should only appear once, with the links from both variants
+	ch := make(chan MapTraversalNode, 100)
+	site.TraverseSiteMap(ch)
+	assertPage(t, root, 0, <-ch)
+	assertPage(t, httpPage, 1, <-ch)
+	assertPage(t, child, 2, <-ch)
+	if _, ok := <-ch; ok {
+		t.Fatal("Channel not closed")
+	}
+}
+
+// Test that pages are merged with their aliases (from canonical links and redirects)
+func TestSiteMapAliases(t *testing.T) {
+
+	URL, err := url.Parse("https://test.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := CreateSiteMap(URL)
+
+	root := addPage(t, site, true, "https://test.com", "Root")
+	root.AddLink("https://test.com/a", Link{})
+	root.AddLink("https://test.com/b", Link{})
+	root.AddLink("https://test.com/c", Link{})
+
+	// page /c is loaded before we find out it redirects to /b
+	c := addPage(t, site, true, "https://test.com/c", "C")
+	c.AddLink("https://test.com/c/1", Link{})
+	addPage(t, site, true, "https://test.com/c/1", "C1")
+
+	// page /b, loaded after being redirected from /c
+	b := createWebPage(t, "https://test.com/b", "B")
+	b.Aliases["https://test.com/c"] = true
+	if added, err := site.AddPage(b); !added || err != nil {
+		t.Fatalf("Unexpected result adding page: expected (true, nil), got (%v, %v)", added, err)
+	}
+
+	// page /a has a canonical link to /b
+	a := createWebPage(t, "https://test.com/a", "A")
+	a.Canonical = "https://test.com/b"
+	a.AddLink("https://test.com/a/1", Link{})
+	if added, err := site.AddPage(a); added || err != nil {
+		t.Fatalf("Unexpected result adding page: expected (false, nil), got (%v, %v)", added, err)
+	}
+	addPage(t, site, true, "https://test.com/a/1", "A1")
+
+	if len(site.Pages) != 4 {
+		t.Fatalf("Incorrect number of pages: expected %d, got %d", 4, len(site.Pages))
+	}
+	if len(b.Aliases) != 2 || !b.Aliases["https://test.com/a"] || !b.Aliases["https://test.com/c"] {
+		t.Fatalf("Incorrect aliases for page: got %v", b.Aliases)
+	}
+
+	// the page should appear once with the links from all its aliases
+	ch := make(chan MapTraversalNode, 100)
+	site.TraverseSiteMap(ch)
+	assertPage(t, root, 0, <-ch)
+	assertPage(t, b, 1, <-ch)
+	assertPage(t, site.Pages["https://test.com/a/1"], 2, <-ch)
+	assertPage(t, site.Pages["https://test.com/c/1"], 2, <-ch)
+	if _, ok := <-ch; ok {
+		t.Fatal("Channel not closed")
+	}
+}
+
+func TestSiteMapLiteral(t *testing.T) {
+
+	// a site map not created by CreateSiteMap
+	site := &SiteMap{Domain: "test.com", RootPage: "https://test.com"}
+	root := createWebPage(t, "https://test.com", "Root")
+	root.AddLink("https://test.com/a", Link{})
+	add := func(page *WebPage, expected bool) {
+		if added, err := site.AddPage(page); added != expected || err != nil {
+			t.Fatalf("Unexpected result adding page %s: expected (%v, nil), got (%v, %v)", page.URL, expected, added, err)
+		}
+	}
+	add(root, true)
+	a := createWebPage(t, "https://test.com/a", "A")
+	a.Canonical = "https://test.com/b"
+	add(a, true)
+	add(createWebPage(t, "https://test.com/b", "B"), false)
+	if keys := sortedKeys(site.Pages); !reflect.DeepEqual(keys, []string{"https://test.com", "https://test.com/b"}) {
+		t.Errorf("Incorrect pages: expected [https://test.com https://test.com/b], got %v", keys)
+	}
+}
+
+func TestSiteMapAliasChain(t *testing.T) {
+
+	// /a is loaded, then /b after a redirect from /a, then /c after a redirect from /b
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	for _, page := range []struct{ url, alias string }{
+		{"https://test.com/a", ""},
+		{"https://test.com/b", "https://test.com/a"},
+		{"https://test.com/c", "https://test.com/b"},
+		{"https://test.com/x", ""},
+	} {
+		webPage := createWebPage(t, page.url, "")
+		if len(page.alias) != 0 {
+			webPage.Aliases[page.alias] = true
+		}
+		webPage.AddLink("https://test.com/x", Link{})
+		if _, err := site.AddPage(webPage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if keys := sortedKeys(site.Pages); !reflect.DeepEqual(keys, []string{"https://test.com/c", "https://test.com/x"}) {
+		t.Fatalf("Incorrect pages: expected [https://test.com/c https://test.com/x], got %v", keys)
+	}
+	for _, alias := range []string{"https://test.com/a", "https://test.com/b"} {
+		if key := site.lookupKey(alias); key != "https://test.com/c" {
+			t.Errorf("Incorrect page for alias %s: expected https://test.com/c, got %s", alias, key)
+		}
+	}
+	expected := map[string][]string{"https://test.com/x": {"https://test.com/c"}}
+	if inlinks := site.Inlinks(); !reflect.DeepEqual(inlinks, expected) {
+		t.Errorf("Incorrect inlinks: expected %v, got %v", expected, inlinks)
+	}
+	if doc := CreateCrawlDocument(site); len(doc.Pages) != 2 {
+		t.Errorf("Incorrect crawl document: expected 2 pages, got %d", len(doc.Pages))
+	}
+}
+
+func TestSiteMapQueryDuplicates(t *testing.T) {
+
+	URL, err := url.Parse("https://test.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := CreateSiteMap(URL)
+	for urlStr, hash := range map[string]string{
+		"https://test.com/a":            "1",
+		"https://test.com/a?utm=x":      "1", // same content
+		"https://test.com/a?page=2":     "2", // different content
+		"https://test.com/b?utm=x":      "3", // no page without the query string
+		"https://test.com/c":            "",  // no content hashes
+		"https://test.com/c?session=1":  "",
+		"https://test.com/a?session=12": "1", // same content
+	} {
+		page := addPage(t, site, true, urlStr, "")
+		page.ContentHash = hash
+	}
+
+	pairs := site.QueryDuplicates()
+	expected := []URLPair{
+		{"https://test.com/a?session=12", "https://test.com/a"},
+		{"https://test.com/a?utm=x", "https://test.com/a"},
+	}
+	if len(pairs) != len(expected) {
+		t.Fatalf("Incorrect query duplicates: expected %v, got %v", expected, pairs)
+	}
+	for i := range expected {
+		if pairs[i] != expected[i] {
+			t.Fatalf("Incorrect query duplicates: expected %v, got %v", expected, pairs)
+		}
+	}
+}
+
+func TestParseSchemePolicy(t *testing.T) {
+	for name, expected := range map[string]SchemePolicy{"distinct": SchemeDistinct, "HTTPS": SchemePreferHTTPS, "http": SchemePreferHTTP} {
+		if policy, err := ParseSchemePolicy(name); err != nil || policy != expected {
+			t.Errorf("Incorrect policy for %s: expected %v, got %v (%v)", name, expected, policy, err)
+		}
+	}
+	if _, err := ParseSchemePolicy("ftp"); err == nil {
+		t.Error("Missing expected error for invalid scheme policy")
+	}
+}
+
 func createWebPage(t *testing.T, rawurl string, title string) *WebPage {
 	URL, err := url.Parse(rawurl)
 	if err != nil {
@@ -139,3 +345,201 @@ func assertPage(t *testing.T, expectedPage *WebPage, expectedDepth int, got MapT
 		t.Fatalf("Next page not correct (%s): expected %v, got %v\n", expectedPage.URL, expectedPage, got.Page)
 	}
 }
+
+func TestDuplicateContent(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	pages := []struct {
+		path        string
+		contentHash string
+		textHash    uint64
+	}{
+		{"/a", "hash1", 0xff00},
+		{"/a-copy", "hash1", 0xff00},
+		{"/b", "hash2", 0xf0f0f0f0},
+		{"/b-edited", "hash3", 0xf0f0f0f3}, // 2 bits different
+		{"/c", "hash4", 0x0f0f0f0f},
+		{"/unknown", "", 0},
+		{"/unknown2", "", 0},
+	}
+	for _, p := range pages {
+		page := createWebPage(t, "https://test.com"+p.path, "")
+		page.ContentHash, page.TextHash = p.contentHash, p.textHash
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups := site.DuplicateContent(3)
+	expected := "[{[https://test.com/a https://test.com/a-copy] true} {[https://test.com/b https://test.com/b-edited] false}]"
+	if got := fmt.Sprint(groups); got != expected {
+		t.Errorf("Incorrect duplicate groups: expected %s, got %s", expected, got)
+	}
+	groups = site.DuplicateContent(-1)
+	expected = "[{[https://test.com/a https://test.com/a-copy] true}]"
+	if got := fmt.Sprint(groups); got != expected {
+		t.Errorf("Incorrect identical groups: expected %s, got %s", expected, got)
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	site.SchemePolicy = SchemePreferHTTPS
+	pages := []struct {
+		path        string
+		title       string
+		contentHash string
+	}{
+		{"", "Home", "hash1"},
+		{"/same", "Same", "hash2"},
+		{"/edited", "Edited", "hash3"},
+		{"/new", "New", "hash4"},
+		{"/renamed", "Renamed", ""},
+		{"/unhashed", "Unhashed", ""},
+		{"/moved", "Moved", "hash5"},
+	}
+	for _, p := range pages {
+		page := createWebPage(t, "https://test.com"+p.path, p.title)
+		page.ContentHash = p.contentHash
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := &CrawlDocument{Pages: []PageRecord{
+		{URL: "http://test.com", Title: "Old Home", ContentHash: "hash1"},
+		{URL: "https://test.com/same", Title: "Same", ContentHash: "hash2"},
+		{URL: "https://test.com/edited", Title: "Edited", ContentHash: "old"},
+		{URL: "https://test.com/renamed", Title: "Old Title"},
+		{URL: "https://test.com/unhashed", Title: "Unhashed", ContentHash: "hash6"},
+		{URL: "https://test.com/old", Title: "Moved", ContentHash: "hash5", Aliases: []string{"https://test.com/moved"}},
+	}}
+	var got []string
+	for _, page := range site.ChangedSince(previous) {
+		got = append(got, page.URL.Path)
+	}
+	expected := "[/edited /new /renamed]"
+	if fmt.Sprint(got) != expected {
+		t.Errorf("Incorrect changed pages: expected %s, got %v", expected, got)
+	}
+}
+
+func TestComputePageRank(t *testing.T) {
+	createSite := func(links map[string][]string) *SiteMap {
+		site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+		for path, targets := range links {
+			page := createWebPage(t, "https://test.com"+path, path)
+			for _, target := range targets {
+				page.AddLink("https://test.com"+target, Link{})
+			}
+			if _, err := site.AddPage(page); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return site
+	}
+	total := func(ranks map[string]float64) float64 {
+		sum := 0.0
+		for _, rank := range ranks {
+			sum += rank
+		}
+		return sum
+	}
+
+	// a cycle shares rank equally, with self links and duplicate links ignored
+	ranks := createSite(map[string][]string{"/a": {"/b", "/a"}, "/b": {"/c", "/c"}, "/c": {"/a"}}).ComputePageRank()
+	for key, rank := range ranks {
+		if math.Abs(rank-1.0/3) > 1e-6 {
+			t.Errorf("Incorrect PageRank for %s in cycle: expected %f, got %f", key, 1.0/3, rank)
+		}
+	}
+
+	// a hub linked to by every other page ranks highest, and pages with no links out share their rank
+	ranks = createSite(map[string][]string{
+		"/hub": {"/a"},
+		"/a":   {"/hub"},
+		"/b":   {"/hub"},
+		"/c":   {"/hub", "/missing"},
+		"/d":   {},
+	}).ComputePageRank()
+	if math.Abs(total(ranks)-1) > 1e-6 {
+		t.Errorf("Incorrect total PageRank: expected 1, got %f", total(ranks))
+	}
+	hub := ranks["https://test.com/hub"]
+	for key, rank := range ranks {
+		if key != "https://test.com/hub" && rank >= hub {
+			t.Errorf("Incorrect PageRank for %s: expected less than the hub (%f), got %f", key, hub, rank)
+		}
+	}
+	if ranks["https://test.com/a"] <= ranks["https://test.com/b"] || ranks["https://test.com/b"] != ranks["https://test.com/d"] {
+		t.Errorf("Incorrect PageRank order: got %v", ranks)
+	}
+	if len(CreateSiteMap(mustParseURL(t, "https://test.com")).ComputePageRank()) != 0 {
+		t.Errorf("Incorrect PageRank for empty site map: expected no scores")
+	}
+}
+
+// createLargeSiteMap returns a site map of the given number of pages, each linking to the home page, its 2
+// children (as a binary tree) and a page elsewhere in the site, as a site's navigation and related links do
+func createLargeSiteMap(b *testing.B, pages int) *SiteMap {
+	start, _ := url.Parse("https://test.com")
+	site := CreateSiteMap(start)
+	for i := 0; i < pages; i++ {
+		pageURL, _ := url.Parse(fmt.Sprintf("https://test.com/page/%d", i))
+		if i == 0 {
+			pageURL = start
+		}
+		page := CreateWebPage(pageURL, fmt.Sprintf("Page %d", i))
+		page.AddLink("https://test.com", Link{"Home", LinkNav})
+		for _, child := range []int{2*i + 1, 2*i + 2, (i * 7919) % pages} {
+			if child < pages && child != 0 {
+				page.AddLink(fmt.Sprintf("https://test.com/page/%d", child), Link{"Child", LinkBody})
+			}
+		}
+		if _, err := site.AddPage(page); err != nil {
+			b.Fatalf("Failed to add page %s: %v", pageURL, err)
+		}
+	}
+	return site
+}
+
+// benchmarkTraversal measures a traversal of a large site map, reading every page it returns
+func benchmarkTraversal(b *testing.B, traverse func(site *SiteMap, ch chan<- MapTraversalNode)) {
+	site := createLargeSiteMap(b, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ch := make(chan MapTraversalNode, 100)
+		go traverse(site, ch)
+		for range ch {
+		}
+	}
+}
+
+func BenchmarkTraverseSiteMap(b *testing.B) {
+	benchmarkTraversal(b, (*SiteMap).TraverseSiteMap)
+}
+
+func BenchmarkTraverseSiteMapBFS(b *testing.B) {
+	benchmarkTraversal(b, (*SiteMap).TraverseSiteMapBFS)
+}
+
+func BenchmarkTraverseSiteMapInlinks(b *testing.B) {
+	benchmarkTraversal(b, func(site *SiteMap, ch chan<- MapTraversalNode) { site.TraverseSiteMapOrdered(ch, OrderInlinks) })
+}
+
+func BenchmarkAddPage(b *testing.B) {
+	site := createLargeSiteMap(b, 1)
+	pages := make([]*WebPage, b.N)
+	for i := range pages {
+		pageURL, _ := url.Parse(fmt.Sprintf("https://test.com/page/%d", i+1))
+		pages[i] = CreateWebPage(pageURL, "Page")
+		pages[i].AddLink("https://test.com", Link{"Home", LinkNav})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for _, page := range pages {
+		if _, err := site.AddPage(page); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PageQuery selects a subset of the pages in a SiteMap. Zero values apply no restriction.
+type PageQuery struct {
+	Path      string // glob the URL path must match (see FindByPath)
+	MinDepth  int    // minimum depth of the page (the root page is at depth 0)
+	MaxDepth  int    // maximum depth of the page, 0 for no limit
+	LinkingTo string // URL the page must link to
+	Orphans   bool   // only include pages not reachable from the root page
+}
+
+// FindByPath returns the pages whose URL path matches the glob pattern, sorted by URL. In the pattern
+// * matches any characters within a path segment, ** matches any characters including / and ? matches a
+// single character within a path segment. For example /blog/** matches every page under /blog/.
+func (site *SiteMap) FindByPath(pattern string) ([]*WebPage, error) {
+	matcher, err := CompilePathGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return site.selectPages(func(key string, page *WebPage) bool {
+		return matcher.MatchString(pagePath(page))
+	}), nil
+}
+
+// PagesAtDepth returns the pages at the supplied depth (the shortest number of links from the root
+// page, which is at depth 0), sorted by URL
+func (site *SiteMap) PagesAtDepth(depth int) []*WebPage {
+	heights := site.getMinimumHeights()
+	return site.selectPages(func(key string, page *WebPage) bool {
+		height, found := heights[key]
+		return found && height == depth
+	})
+}
+
+// PagesLinkingTo returns the pages with a link to the supplied URL (or any of its aliases), sorted by URL
+func (site *SiteMap) PagesLinkingTo(urlStr string) []*WebPage {
+	target := site.lookupKey(strings.TrimSuffix(urlStr, "/"))
+	return site.selectPages(func(key string, page *WebPage) bool {
+		for link := range page.InternalLinks {
+			if site.lookupKey(link) == target && key != target {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// OrphanPages returns the pages which can't be reached by following links from the root page, sorted
+// by URL. These are usually only found through redirects, canonical links or a crawl limit.
+func (site *SiteMap) OrphanPages() []*WebPage {
+	heights := site.getMinimumHeights()
+	return site.selectPages(func(key string, page *WebPage) bool {
+		_, found := heights[key]
+		return !found
+	})
+}
+
+// Query returns the pages matching all the conditions of the query, sorted by URL
+func (site *SiteMap) Query(query PageQuery) ([]*WebPage, error) {
+	var matcher *regexp.Regexp
+	if len(query.Path) != 0 {
+		var err error
+		if matcher, err = CompilePathGlob(query.Path); err != nil {
+			return nil, err
+		}
+	}
+	if query.MinDepth < 0 || query.MaxDepth < 0 {
+		return nil, fmt.Errorf("depth limits must not be negative")
+	}
+	var linkedFrom map[string]bool
+	if len(query.LinkingTo) != 0 {
+		linkedFrom = make(map[string]bool)
+		for _, page := range site.PagesLinkingTo(query.LinkingTo) {
+			linkedFrom[page.URL.String()] = true
+		}
+	}
+	heights := site.getMinimumHeights()
+	return site.selectPages(func(key string, page *WebPage) bool {
+		height, reachable := heights[key]
+		switch {
+		case matcher != nil && !matcher.MatchString(pagePath(page)):
+			return false
+		case query.Orphans && reachable:
+			return false
+		case (query.MinDepth > 0 || query.MaxDepth > 0) && !reachable:
+			return false
+		case query.MinDepth > 0 && height < query.MinDepth:
+			return false
+		case query.MaxDepth > 0 && height > query.MaxDepth:
+			return false
+		case linkedFrom != nil && !linkedFrom[page.URL.String()]:
+			return false
+		}
+		return true
+	}), nil
+}
+
+// selectPages returns the pages for which the supplied function returns true, sorted by URL
+func (site *SiteMap) selectPages(include func(key string, page *WebPage) bool) []*WebPage {
+	var pages []*WebPage
+	for key, page := range site.Pages {
+		if include(key, page) {
+			pages = append(pages, page)
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL.String() < pages[j].URL.String() })
+	return pages
+}
+
+// pagePath returns the path of a page, with the root page having a path of /
+func pagePath(page *WebPage) string {
+	return urlPath(page.URL)
+}
+
+// urlPath returns the path of a URL, with / for the root (which is stored with an empty path)
+func urlPath(u *url.URL) string {
+	if len(u.Path) == 0 {
+		return "/"
+	}
+	return u.Path
+}
+
+// CompilePathGlob converts a path glob pattern (see FindByPath) into a regular expression
+func CompilePathGlob(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("empty path pattern")
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
@@ -0,0 +1,216 @@
+package sitemap
+
+import (
+	"fmt"
+	"testing"
+)
+
+// createQueryTestSite creates a site map of:
+//
+//	/ -> /blog, /about
+//	/blog -> /blog/2024, /about
+//	/blog/2024 -> /blog/2024/post
+//	/blog/2024/post
+//	/about
+//	/orphan (not linked to)
+func createQueryTestSite(t *testing.T) *SiteMap {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	links := map[string][]string{
+		"":                {"/blog", "/about"},
+		"/blog":           {"/blog/2024", "/about"},
+		"/blog/2024":      {"/blog/2024/post"},
+		"/blog/2024/post": {},
+		"/about":          {},
+		"/orphan":         {},
+	}
+	for path, targets := range links {
+		page := createWebPage(t, "https://test.com"+path, "Page "+path)
+		for _, target := range targets {
+			page.AddLink("https://test.com"+target, Link{})
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return site
+}
+
+// pagePaths returns the paths of the pages supplied
+func pagePaths(pages []*WebPage) string {
+	var paths []string
+	for _, page := range pages {
+		paths = append(paths, pagePath(page))
+	}
+	return fmt.Sprint(paths)
+}
+
+func TestFindByPath(t *testing.T) {
+	site := createQueryTestSite(t)
+	tests := map[string]string{
+		"/blog/**":  "[/blog/2024 /blog/2024/post]",
+		"/blog/*":   "[/blog/2024]",
+		"/blog*":    "[/blog]",
+		"/*":        "[/ /about /blog /orphan]",
+		"/":         "[/]",
+		"/a?out":    "[/about]",
+		"/missing*": "[]",
+	}
+	for pattern, expected := range tests {
+		pages, err := site.FindByPath(pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error for pattern %s: %v", pattern, err)
+		}
+		if got := pagePaths(pages); got != expected {
+			t.Errorf("Incorrect pages for pattern %s: expected %s, got %s", pattern, expected, got)
+		}
+	}
+	if _, err := site.FindByPath(""); err == nil {
+		t.Errorf("Missing expected error for empty pattern")
+	}
+}
+
+func TestPagesAtDepth(t *testing.T) {
+	site := createQueryTestSite(t)
+	expected := []string{"[/]", "[/about /blog]", "[/blog/2024]", "[/blog/2024/post]", "[]"}
+	for depth, paths := range expected {
+		if got := pagePaths(site.PagesAtDepth(depth)); got != paths {
+			t.Errorf("Incorrect pages at depth %d: expected %s, got %s", depth, paths, got)
+		}
+	}
+}
+
+func TestPagesLinkingTo(t *testing.T) {
+	site := createQueryTestSite(t)
+	if got := pagePaths(site.PagesLinkingTo("https://test.com/about/")); got != "[/ /blog]" {
+		t.Errorf("Incorrect pages linking to /about: expected %s, got %s", "[/ /blog]", got)
+	}
+	if got := pagePaths(site.PagesLinkingTo("https://test.com/orphan")); got != "[]" {
+		t.Errorf("Incorrect pages linking to /orphan: expected %s, got %s", "[]", got)
+	}
+}
+
+func TestOrphanPages(t *testing.T) {
+	site := createQueryTestSite(t)
+	if got := pagePaths(site.OrphanPages()); got != "[/orphan]" {
+		t.Errorf("Incorrect orphan pages: expected %s, got %s", "[/orphan]", got)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	site := createQueryTestSite(t)
+	tests := []struct {
+		query    PageQuery
+		expected string
+	}{
+		{PageQuery{}, "[/ /about /blog /blog/2024 /blog/2024/post /orphan]"},
+		{PageQuery{Path: "/blog/**", MinDepth: 3}, "[/blog/2024/post]"},
+		{PageQuery{MaxDepth: 1}, "[/ /about /blog]"},
+		{PageQuery{LinkingTo: "https://test.com/about", MinDepth: 1}, "[/blog]"},
+		{PageQuery{Orphans: true}, "[/orphan]"},
+	}
+	for _, test := range tests {
+		pages, err := site.Query(test.query)
+		if err != nil {
+			t.Fatalf("Unexpected error for query %+v: %v", test.query, err)
+		}
+		if got := pagePaths(pages); got != test.expected {
+			t.Errorf("Incorrect pages for query %+v: expected %s, got %s", test.query, test.expected, got)
+		}
+	}
+	if _, err := site.Query(PageQuery{MinDepth: -1}); err == nil {
+		t.Errorf("Missing expected error for negative depth")
+	}
+}
+
+// traverse returns the paths and depths of the pages traversed in the order supplied
+func traverse(site *SiteMap, order TraversalOrder) string {
+	ch := make(chan MapTraversalNode)
+	go site.TraverseSiteMapOrdered(ch, order)
+	var nodes []string
+	for node := range ch {
+		nodes = append(nodes, fmt.Sprintf("%s:%d", pagePath(node.Page), node.Depth))
+	}
+	return fmt.Sprint(nodes)
+}
+
+func TestTraversalOrders(t *testing.T) {
+	site := createQueryTestSite(t)
+	if err := site.AddLink("https://test.com/about", "https://test.com/about/team", Link{}); err != nil {
+		t.Fatal(err)
+	}
+	addPage(t, site, true, "https://test.com/about/team", "Team")
+	tests := map[TraversalOrder]string{
+		OrderDFS:     "[/:0 /about:1 /about/team:2 /blog:1 /blog/2024:2 /blog/2024/post:3]",
+		OrderBFS:     "[/:0 /about:1 /blog:1 /about/team:2 /blog/2024:2 /blog/2024/post:3]",
+		OrderAlpha:   "[/:0 /about:1 /about/team:2 /blog:1 /blog/2024:2 /blog/2024/post:3]",
+		OrderInlinks: "[/about:1 /about/team:2 /blog:1 /blog/2024:2 /blog/2024/post:3 /:0]",
+	}
+	for order, expected := range tests {
+		if got := traverse(site, order); got != expected {
+			t.Errorf("Incorrect traversal for order %v: expected %s, got %s", order, expected, got)
+		}
+	}
+}
+
+func TestInlinkCounts(t *testing.T) {
+	site := createQueryTestSite(t)
+	counts := site.InlinkCounts()
+	expected := map[string]int{
+		"https://test.com/about":          2,
+		"https://test.com/blog":           1,
+		"https://test.com/blog/2024":      1,
+		"https://test.com/blog/2024/post": 1,
+	}
+	if fmt.Sprint(counts) != fmt.Sprint(expected) {
+		t.Errorf("Incorrect inlink counts: expected %v, got %v", expected, counts)
+	}
+}
+
+func TestParseTraversalOrder(t *testing.T) {
+	for name, expected := range map[string]TraversalOrder{"dfs": OrderDFS, "BFS": OrderBFS, "alpha": OrderAlpha, "inlinks": OrderInlinks} {
+		if order, err := ParseTraversalOrder(name); err != nil || order != expected {
+			t.Errorf("Incorrect order for %s: expected %v, got %v (%v)", name, expected, order, err)
+		}
+	}
+	if _, err := ParseTraversalOrder("random"); err == nil {
+		t.Errorf("Missing expected error for unknown order")
+	}
+}
+
+func TestInlinks(t *testing.T) {
+	site := createQueryTestSite(t)
+	site.SchemePolicy = SchemePreferHTTPS
+
+	// links to an alias or another scheme count as links to the page, and a page linking to itself is ignored
+	if _, err := site.AddPage(createWebPage(t, "https://test.com/team", "Team")); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"http://test.com/about-us", "https://test.com/team"} {
+		if err := site.AddLink("https://test.com/team", link, Link{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := site.AddLink("https://test.com/about", "https://test.com/about-us", Link{}); err != nil {
+		t.Fatal(err)
+	}
+	site.Aliases[site.pageKey("https://test.com/about-us")] = "https://test.com/about"
+
+	inlinks := site.Inlinks()
+	expected := "[https://test.com https://test.com/blog https://test.com/team]"
+	if got := fmt.Sprint(inlinks["https://test.com/about"]); got != expected {
+		t.Errorf("Incorrect inlinks: expected %s, got %s", expected, got)
+	}
+	if _, found := inlinks["https://test.com/team"]; found {
+		t.Errorf("Incorrect inlinks for page only linking to itself: got %v", inlinks["https://test.com/team"])
+	}
+	if err := site.AddLink("https://test.com/missing", "https://test.com", Link{}); err == nil {
+		t.Errorf("Missing expected error adding link from page not in site map")
+	}
+
+	popularity := site.LinkPopularity()
+	expectedPopularity := "[{https://test.com/about 3} {https://test.com/blog 1} {https://test.com/blog/2024 1} " +
+		"{https://test.com/blog/2024/post 1} {https://test.com 0} {https://test.com/orphan 0} {https://test.com/team 0}]"
+	if got := fmt.Sprint(popularity); got != expectedPopularity {
+		t.Errorf("Incorrect link popularity: expected %s, got %s", expectedPopularity, got)
+	}
+}
@@ -0,0 +1,162 @@
+package sitemap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// changeFreqs are the changefreq values allowed by the sitemap protocol
+var changeFreqs = map[string]bool{
+	"always": true, "hourly": true, "daily": true, "weekly": true, "monthly": true, "yearly": true, "never": true,
+}
+
+// SitemapRule sets the changefreq and priority of the pages in a sitemap.xml whose path or click depth
+// matches it
+type SitemapRule struct {
+	Pattern    string         // path glob (see FindByPath) or depth selector (depth=N, depth>=N or depth<=N)
+	path       *regexp.Regexp // compiled path glob (nil for a depth rule)
+	MinDepth   int            // smallest click depth matched by a depth rule
+	MaxDepth   int            // largest click depth matched by a depth rule (-1 for no limit)
+	ChangeFreq string         // changefreq of the matching pages (empty to leave it to a later rule)
+	Priority   string         // priority of the matching pages, 0.0 to 1.0 (empty to leave it to a later rule)
+}
+
+// depthSelector matches the depth selector of a rule, e.g. depth>=3
+var depthSelector = regexp.MustCompile(`^depth\s*(=|>=|<=)\s*(\d+)$`)
+
+// ParseSitemapRule parses a rule written as a selector and its values separated by a colon, where the
+// selector is a path glob or a click depth (depth=N, depth>=N or depth<=N) and the values are a changefreq
+// and/or a priority separated by a comma, e.g. "/blog/**: weekly,0.6", "depth>=3: monthly" or "/: ,1.0"
+func ParseSitemapRule(rule string) (SitemapRule, error) {
+	idx := strings.LastIndex(rule, ":")
+	if idx < 0 {
+		return SitemapRule{}, fmt.Errorf("invalid sitemap rule %q, expected selector: changefreq,priority", rule)
+	}
+	parsed := SitemapRule{Pattern: strings.TrimSpace(rule[:idx]), MaxDepth: -1}
+	if match := depthSelector.FindStringSubmatch(parsed.Pattern); match != nil {
+		depth, err := strconv.Atoi(match[2])
+		if err != nil {
+			return SitemapRule{}, fmt.Errorf("invalid depth in sitemap rule %q: %v", rule, err)
+		}
+		switch match[1] {
+		case "=":
+			parsed.MinDepth, parsed.MaxDepth = depth, depth
+		case ">=":
+			parsed.MinDepth = depth
+		case "<=":
+			parsed.MaxDepth = depth
+		}
+	} else if !strings.HasPrefix(parsed.Pattern, "/") {
+		return SitemapRule{}, fmt.Errorf("invalid selector in sitemap rule %q, expected a path starting with / or a depth", rule)
+	} else {
+		var err error
+		if parsed.path, err = CompilePathGlob(parsed.Pattern); err != nil {
+			return SitemapRule{}, fmt.Errorf("invalid path in sitemap rule %q: %v", rule, err)
+		}
+	}
+
+	changeFreq, priority, _ := strings.Cut(rule[idx+1:], ",")
+	parsed.ChangeFreq = strings.ToLower(strings.TrimSpace(changeFreq))
+	parsed.Priority = strings.TrimSpace(priority)
+	if len(parsed.ChangeFreq) != 0 && !changeFreqs[parsed.ChangeFreq] {
+		return SitemapRule{}, fmt.Errorf("invalid changefreq in sitemap rule %q, expected always, hourly, daily, weekly, monthly, yearly or never", rule)
+	}
+	if len(parsed.Priority) != 0 {
+		value, err := strconv.ParseFloat(parsed.Priority, 64)
+		if err != nil || value < 0 || value > 1 {
+			return SitemapRule{}, fmt.Errorf("invalid priority in sitemap rule %q, expected a number from 0.0 to 1.0", rule)
+		}
+	}
+	if len(parsed.ChangeFreq) == 0 && len(parsed.Priority) == 0 {
+		return SitemapRule{}, fmt.Errorf("invalid sitemap rule %q, expected a changefreq or priority", rule)
+	}
+	return parsed, nil
+}
+
+// ParseSitemapRules parses a list of rules, one per line (see ParseSitemapRule). Blank lines and lines
+// starting with # are ignored.
+func ParseSitemapRules(reader io.Reader) ([]SitemapRule, error) {
+	var rules []SitemapRule
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := ParseSitemapRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// LoadSitemapRules loads a file of rules (see ParseSitemapRules)
+func LoadSitemapRules(fileName string) ([]SitemapRule, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSitemapRules(file)
+}
+
+// matches checks if a rule applies to a page with a path and click depth (-1 if not reachable)
+func (rule *SitemapRule) matches(path string, depth int) bool {
+	if rule.path != nil {
+		return rule.path.MatchString(path)
+	}
+	return depth >= 0 && depth >= rule.MinDepth && (rule.MaxDepth < 0 || depth <= rule.MaxDepth)
+}
+
+// SitemapPriorities decides the changefreq and priority of the pages written by WriteSitemapXML from rules
+// matching their path or their click depth in a site map
+type SitemapPriorities struct {
+	rules  []SitemapRule
+	depths map[string]int // click depth of each page reachable from the home page, by URL
+}
+
+// CreateSitemapPriorities creates the SitemapPriorities applying rules to the pages of a site map
+func CreateSitemapPriorities(rules []SitemapRule, site *SiteMap) *SitemapPriorities {
+	priorities := &SitemapPriorities{rules: rules, depths: make(map[string]int)}
+	for key, depth := range site.getMinimumHeights() {
+		if page, found := site.Pages[key]; found {
+			priorities.depths[page.URL.String()] = depth
+		}
+	}
+	return priorities
+}
+
+// lookup returns the changefreq and priority of a page, each from the first rule matching the page which
+// sets it (empty if none does)
+func (priorities *SitemapPriorities) lookup(page *WebPage) (changeFreq string, priority string) {
+	if priorities == nil {
+		return "", ""
+	}
+	depth, found := priorities.depths[page.URL.String()]
+	if !found {
+		depth = -1
+	}
+	for i := range priorities.rules {
+		rule := &priorities.rules[i]
+		if !rule.matches(urlPath(page.URL), depth) {
+			continue
+		}
+		if len(changeFreq) == 0 {
+			changeFreq = rule.ChangeFreq
+		}
+		if len(priority) == 0 {
+			priority = rule.Priority
+		}
+		if len(changeFreq) != 0 && len(priority) != 0 {
+			break
+		}
+	}
+	return changeFreq, priority
+}
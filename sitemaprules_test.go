@@ -0,0 +1,133 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSitemapRules(t *testing.T) {
+
+	rules, err := ParseSitemapRules(strings.NewReader(`# home page first
+depth=0: Daily,1.0
+
+/blog/**: weekly,0.6
+depth >= 3: monthly
+/about: ,0.2
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	expected := []string{"depth=0 0-0 daily 1.0", "/blog/** 0--1 weekly 0.6", "depth >= 3 3--1 monthly ", "/about 0--1  0.2"}
+	if len(rules) != len(expected) {
+		t.Fatalf("Incorrect number of rules: expected %d, got %d", len(expected), len(rules))
+	}
+	for i, rule := range rules {
+		if parsed := fmt.Sprintf("%s %d-%d %s %s", rule.Pattern, rule.MinDepth, rule.MaxDepth, rule.ChangeFreq, rule.Priority); parsed != expected[i] {
+			t.Errorf("Incorrect rule %d: expected %q, got %q", i, expected[i], parsed)
+		}
+	}
+
+	for _, invalid := range []string{"/blog/**", "blog: weekly", "/blog: sometimes", "/blog: weekly,1.5", "/blog: weekly,high", "/blog: ,", "depth>x: daily"} {
+		if _, err := ParseSitemapRules(strings.NewReader("/: daily\n" + invalid)); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("Incorrect result parsing %q: expected an error on line 2, got %v", invalid, err)
+		}
+	}
+}
+
+func TestWriteSitemapXMLPriorities(t *testing.T) {
+
+	site := createQueryTestSite(t)
+	var rules []SitemapRule
+	for _, rule := range []string{"depth=0: daily,1.0", "/blog/**: weekly", "depth<=1: ,0.8", "depth>=2: monthly,0.3", "/orphan: never"} {
+		parsed, err := ParseSitemapRule(rule)
+		if err != nil {
+			t.Fatalf("Failed to parse rule %q: %v", rule, err)
+		}
+		rules = append(rules, parsed)
+	}
+
+	var buf bytes.Buffer
+	pages := site.selectPages(func(key string, page *WebPage) bool { return true })
+	if err := WriteSitemapXML(&buf, pages, time.Time{}, CreateSitemapPriorities(rules, site)); err != nil {
+		t.Fatalf("Failed to write sitemap: %v", err)
+	}
+	var sitemap struct {
+		URLs []struct {
+			Loc        string `xml:"loc"`
+			ChangeFreq string `xml:"changefreq"`
+			Priority   string `xml:"priority"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &sitemap); err != nil {
+		t.Fatalf("Failed to parse sitemap: %v\n%s", err, buf.String())
+	}
+	expected := map[string]string{
+		"https://test.com":                "daily 1.0",
+		"https://test.com/about":          " 0.8",
+		"https://test.com/blog":           " 0.8",
+		"https://test.com/blog/2024":      "weekly 0.3",
+		"https://test.com/blog/2024/post": "weekly 0.3",
+		"https://test.com/orphan":         "never ",
+	}
+	if len(sitemap.URLs) != len(expected) {
+		t.Fatalf("Incorrect sitemap: expected %d pages, got\n%s", len(expected), buf.String())
+	}
+	for _, entry := range sitemap.URLs {
+		if values := entry.ChangeFreq + " " + entry.Priority; values != expected[entry.Loc] {
+			t.Errorf("Incorrect changefreq and priority of %s: expected %q, got %q", entry.Loc, expected[entry.Loc], values)
+		}
+	}
+	if !strings.Contains(buf.String(), "<loc>https://test.com</loc>\n    <changefreq>daily</changefreq>\n    <priority>1.0</priority>") {
+		t.Errorf("Incorrect sitemap: expected changefreq and priority after loc, got\n%s", buf.String())
+	}
+}
+
+func TestSitemapXMLRenderer(t *testing.T) {
+
+	site := createQueryTestSite(t)
+	rule, err := ParseSitemapRule("/blog/**: weekly,0.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query    PageQuery
+		expected []string
+	}{
+		{PageQuery{}, []string{"https://test.com", "https://test.com/about", "https://test.com/blog", "https://test.com/blog/2024",
+			"https://test.com/blog/2024/post", "https://test.com/orphan"}},
+		{PageQuery{Path: "/blog/**"}, []string{"https://test.com/blog/2024", "https://test.com/blog/2024/post"}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := (SitemapXMLRenderer{Rules: []SitemapRule{rule}, Query: test.query}).Render(&buf, site); err != nil {
+			t.Fatalf("Failed to render sitemap: %v", err)
+		}
+		var sitemap struct {
+			URLs []struct {
+				Loc        string `xml:"loc"`
+				ChangeFreq string `xml:"changefreq"`
+			} `xml:"url"`
+		}
+		if err := xml.Unmarshal(buf.Bytes(), &sitemap); err != nil {
+			t.Fatalf("Failed to parse sitemap: %v\n%s", err, buf.String())
+		}
+		var locs []string
+		for _, entry := range sitemap.URLs {
+			locs = append(locs, entry.Loc)
+			expected := ""
+			if strings.HasPrefix(entry.Loc, "https://test.com/blog/") {
+				expected = "weekly"
+			}
+			if entry.ChangeFreq != expected {
+				t.Errorf("Incorrect changefreq of %s: expected %q, got %q", entry.Loc, expected, entry.ChangeFreq)
+			}
+		}
+		if fmt.Sprint(locs) != fmt.Sprint(test.expected) {
+			t.Errorf("Incorrect pages in sitemap for %+v: expected %v, got %v", test.query, test.expected, locs)
+		}
+	}
+}
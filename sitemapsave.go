@@ -0,0 +1,157 @@
+package sitemap
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SiteMapSaveVersion is the version of the format site maps are saved in by SiteMap.Save. It is increased
+// whenever the saved fields change in a way older versions of LoadSiteMap can't read, and LoadSiteMap
+// rejects site maps saved in a newer version than it knows.
+const SiteMapSaveVersion = 1
+
+// siteMapFormat identifies a file as a site map saved by SiteMap.Save
+const siteMapFormat = "go-sitemap"
+
+// siteMapHeader is written at the start of a saved site map, ahead of the site map itself, so the version
+// can be checked before the rest of the file is decoded
+type siteMapHeader struct {
+	Format  string // always siteMapFormat
+	Version int    // SiteMapSaveVersion when saved
+}
+
+// savedSiteMap is the content of a saved site map: every field of the SiteMap, including the indexes built
+// as pages are added, so a loaded site map reports exactly as the crawled one would
+type savedSiteMap struct {
+	Domain           string
+	RootPage         string
+	Pages            map[string]*WebPage
+	SchemePolicy     SchemePolicy
+	URLPolicy        URLPolicy
+	SchemeDuplicates int
+	Aliases          map[string]string
+	Truncated        bool
+	Errors           []LoadFailure
+	Boundary         []BoundaryLink
+	Variants         []string            // every page URL added
+	Inlinks          map[string][]string // URL linked to, to the URLs of the pages linking to it
+	Canonicals       map[string]string   // URL of each page declaring a canonical URL, to that URL
+}
+
+// Save writes the site map in a versioned binary (gob) format, so the results of a crawl can be stored and
+// later reloaded with LoadSiteMap to be written in other formats without crawling the site again
+func (site *SiteMap) Save(w io.Writer) error {
+	saved := savedSiteMap{
+		Domain:           site.Domain,
+		RootPage:         site.RootPage,
+		Pages:            site.Pages,
+		SchemePolicy:     site.SchemePolicy,
+		URLPolicy:        site.URLPolicy,
+		SchemeDuplicates: site.SchemeDuplicates,
+		Aliases:          site.Aliases,
+		Truncated:        site.Truncated,
+		Errors:           site.Errors,
+		Boundary:         site.Boundary,
+		Variants:         sortedKeys(site.variants),
+		Inlinks:          make(map[string][]string, len(site.inlinks)),
+		Canonicals:       site.canonicals,
+	}
+	for link, sources := range site.inlinks {
+		saved.Inlinks[link] = sortedKeys(sources)
+	}
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode(siteMapHeader{siteMapFormat, SiteMapSaveVersion}); err != nil {
+		return err
+	}
+	return encoder.Encode(&saved)
+}
+
+// LoadSiteMap reads a site map written by SiteMap.Save. An error is returned if the data isn't a saved site
+// map or was saved in a newer version of the format.
+func LoadSiteMap(r io.Reader) (*SiteMap, error) {
+	decoder := gob.NewDecoder(r)
+	var header siteMapHeader
+	if err := decoder.Decode(&header); err != nil || header.Format != siteMapFormat {
+		return nil, fmt.Errorf("not a saved site map")
+	}
+	if header.Version < 1 || header.Version > SiteMapSaveVersion {
+		return nil, fmt.Errorf("unsupported saved site map version %d (expected %d or earlier)", header.Version, SiteMapSaveVersion)
+	}
+	var saved savedSiteMap
+	if err := decoder.Decode(&saved); err != nil {
+		return nil, fmt.Errorf("invalid saved site map: %v", err)
+	}
+
+	site := &SiteMap{
+		Domain:           saved.Domain,
+		RootPage:         saved.RootPage,
+		Pages:            saved.Pages,
+		SchemePolicy:     saved.SchemePolicy,
+		URLPolicy:        saved.URLPolicy,
+		SchemeDuplicates: saved.SchemeDuplicates,
+		Aliases:          saved.Aliases,
+		Truncated:        saved.Truncated,
+		Errors:           saved.Errors,
+		Boundary:         saved.Boundary,
+		variants:         make(map[string]bool, len(saved.Variants)),
+		inlinks:          make(map[string]map[string]bool, len(saved.Inlinks)),
+		canonicals:       saved.Canonicals,
+	}
+	// gob doesn't distinguish empty maps from nil ones, so recreate those the site map adds to
+	if site.Pages == nil {
+		site.Pages = make(map[string]*WebPage)
+	}
+	if site.Aliases == nil {
+		site.Aliases = make(map[string]string)
+	}
+	if site.canonicals == nil {
+		site.canonicals = make(map[string]string)
+	}
+	for _, page := range site.Pages {
+		if page.InternalLinks == nil {
+			page.InternalLinks = make(map[string][]Link)
+		}
+		if page.Aliases == nil {
+			page.Aliases = make(map[string]bool)
+		}
+	}
+	for _, urlStr := range saved.Variants {
+		site.variants[urlStr] = true
+	}
+	for link, sources := range saved.Inlinks {
+		site.inlinks[link] = make(map[string]bool, len(sources))
+		for _, source := range sources {
+			site.inlinks[link][source] = true
+		}
+	}
+	return site, nil
+}
+
+// SaveSiteMapFile saves a site map to a file (see SiteMap.Save)
+func SaveSiteMapFile(site *SiteMap, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := site.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadSiteMapFile loads a site map saved to a file (see LoadSiteMap)
+func LoadSiteMapFile(fileName string) (*SiteMap, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	site, err := LoadSiteMap(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return site, nil
+}
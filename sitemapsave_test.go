@@ -0,0 +1,85 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSiteMapSaveLoad(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "http://www.example.com"))
+	site.SchemePolicy = SchemePreferHTTPS
+	root := createWebPage(t, "http://www.example.com", "Home")
+	root.AddLink("http://www.example.com/about", Link{"About us", LinkNav})
+	root.AddLink("http://www.example.com/missing", Link{"Missing", LinkBody})
+	root.LastModified = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	root.AddAsset("http://www.example.com/logo.png")
+	about := createWebPage(t, "http://www.example.com/about-us", "About")
+	about.Canonical = "http://www.example.com/about"
+	about.AddLink("http://www.example.com", Link{"Home", LinkFooter})
+	for _, page := range []*WebPage{root, about} {
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatalf("Failed to add page: %v", err)
+		}
+	}
+	site.AddErrors([]LoadFailure{{URL: "http://www.example.com/missing", Class: LoadErrorStatus, StatusCode: 404, Attempts: 1}})
+	site.Truncated = true
+
+	var buffer bytes.Buffer
+	if err := site.Save(&buffer); err != nil {
+		t.Fatalf("Failed to save site map: %v", err)
+	}
+	loaded, err := LoadSiteMap(&buffer)
+	if err != nil {
+		t.Fatalf("Failed to load site map: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, site) {
+		t.Errorf("Incorrect site map loaded: expected %+v, got %+v", site, loaded)
+	}
+	if referrers := loaded.referrers("http://www.example.com/about"); len(referrers) != 1 || referrers[0] != "http://www.example.com" {
+		t.Errorf("Incorrect referrers in loaded site map: expected [http://www.example.com], got %v", referrers)
+	}
+	if added, _ := loaded.AddPage(createWebPage(t, "http://www.example.com/about-us", "About")); added {
+		t.Errorf("Incorrect result adding a page again to the loaded site map: expected it to be a duplicate")
+	}
+
+	fileName := filepath.Join(t.TempDir(), "site.gob")
+	if err := SaveSiteMapFile(site, fileName); err != nil {
+		t.Fatalf("Failed to save site map file: %v", err)
+	}
+	if loaded, err = LoadSiteMapFile(fileName); err != nil || len(loaded.Pages) != 2 {
+		t.Errorf("Incorrect site map loaded from file: expected 2 pages, got %v (%v)", loaded, err)
+	}
+}
+
+func TestLoadSiteMapInvalid(t *testing.T) {
+
+	newer := new(bytes.Buffer)
+	if err := gob.NewEncoder(newer).Encode(siteMapHeader{siteMapFormat, SiteMapSaveVersion + 1}); err != nil {
+		t.Fatalf("Failed to encode header: %v", err)
+	}
+	other := new(bytes.Buffer)
+	if err := gob.NewEncoder(other).Encode(siteMapHeader{"other", SiteMapSaveVersion}); err != nil {
+		t.Fatalf("Failed to encode header: %v", err)
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"newer version", newer.Bytes(), "unsupported saved site map version"},
+		{"other format", other.Bytes(), "not a saved site map"},
+		{"not gob", []byte(`{"pages": []}`), "not a saved site map"},
+		{"truncated", newer.Bytes()[:3], "not a saved site map"},
+	}
+	for _, test := range tests {
+		if _, err := LoadSiteMap(bytes.NewReader(test.data)); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Incorrect error loading %s: expected %q, got %v", test.name, test.expected, err)
+		}
+	}
+}
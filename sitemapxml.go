@@ -0,0 +1,198 @@
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxSitemapIndexDepth limits how deeply sitemap index files are followed when loading a sitemap.xml
+const maxSitemapIndexDepth = 3
+
+// xmlSitemap is either a sitemap (a urlset of page locations) or a sitemap index (a list of sitemap
+// locations), as defined by https://www.sitemaps.org/protocol.html
+type xmlSitemap struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// sitemapXMLNamespace is the XML namespace of sitemaps written by WriteSitemapXML
+const sitemapXMLNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// xhtmlNamespace is the XML namespace of the xhtml:link elements listing the language variants of a page
+const xhtmlNamespace = "http://www.w3.org/1999/xhtml"
+
+// videoNamespace is the XML namespace of the video:video elements listing the videos on a page
+const videoNamespace = "http://www.google.com/schemas/sitemap-video/1.1"
+
+// xmlURLSet is a sitemap written by WriteSitemapXML
+type xmlURLSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	XHTML   string   `xml:"xmlns:xhtml,attr,omitempty"`
+	Video   string   `xml:"xmlns:video,attr,omitempty"`
+	URLs    []xmlURL `xml:"url"`
+}
+
+// xmlURL is a single page location in a sitemap written by WriteSitemapXML
+type xmlURL struct {
+	Loc        string         `xml:"loc"`
+	LastMod    string         `xml:"lastmod,omitempty"`
+	ChangeFreq string         `xml:"changefreq,omitempty"`
+	Priority   string         `xml:"priority,omitempty"`
+	Languages  []xmlAlternate `xml:"xhtml:link"`
+	Videos     []xmlVideo     `xml:"video:video"`
+}
+
+// xmlAlternate is a language variant of a page in a sitemap written by WriteSitemapXML, as described in
+// https://developers.google.com/search/docs/specialty/international/localized-versions#sitemap
+type xmlAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	HrefLang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// xmlVideo is a video on a page in a sitemap written by WriteSitemapXML, as described in
+// https://developers.google.com/search/docs/crawling-indexing/sitemaps/video-sitemaps
+type xmlVideo struct {
+	ThumbnailLoc string `xml:"video:thumbnail_loc,omitempty"`
+	Title        string `xml:"video:title"`
+	Description  string `xml:"video:description"`
+	ContentLoc   string `xml:"video:content_loc,omitempty"`
+	PlayerLoc    string `xml:"video:player_loc,omitempty"`
+	Duration     int    `xml:"video:duration,omitempty"`
+}
+
+// LoadSitemapXML loads the page URLs listed in a sitemap.xml file, following any sitemap index files.
+// The location is either an http(s) URL or the name of a local file, and gzipped sitemaps are supported.
+func LoadSitemapXML(client *http.Client, location string) ([]string, error) {
+	var urls []string
+	if err := loadSitemapXML(client, location, 0, &urls); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// loadSitemapXML appends the URLs in a single sitemap (or sitemap index) to urls
+func loadSitemapXML(client *http.Client, location string, depth int, urls *[]string) error {
+	if depth > maxSitemapIndexDepth {
+		return fmt.Errorf("sitemap indexes nested too deeply at %s", location)
+	}
+	reader, err := openSitemap(client, location)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// sitemaps can be gzipped, whatever their content type says
+	buffered := bufio.NewReader(reader)
+	var body io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if body, err = gzip.NewReader(buffered); err != nil {
+			return fmt.Errorf("invalid gzipped sitemap %s: %v", location, err)
+		}
+	}
+	var sitemap xmlSitemap
+	if err := xml.NewDecoder(body).Decode(&sitemap); err != nil {
+		return fmt.Errorf("invalid sitemap %s: %v", location, err)
+	}
+	for _, entry := range sitemap.URLs {
+		if loc := strings.TrimSpace(entry.Loc); len(loc) != 0 {
+			*urls = append(*urls, loc)
+		}
+	}
+	for _, entry := range sitemap.Sitemaps {
+		if loc := strings.TrimSpace(entry.Loc); len(loc) != 0 {
+			if err := loadSitemapXML(client, loc, depth+1, urls); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// openSitemap opens a sitemap from an http(s) URL or a local file
+func openSitemap(client *http.Client, location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.Open(location)
+	}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{URL: location, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp.Body, nil
+}
+
+// WriteSitemapXML writes a sitemap.xml listing the supplied pages, each with a lastmod of when the page was
+// last modified or, if that's not known, the supplied time (none if it is zero), an xhtml:link for each
+// language variant declared with hreflang and a video:video for each video recorded on the page. A video
+// without a title or description is given the title of the page. Each page's changefreq and priority are
+// set from the rules of the priorities matching it (none if priorities is nil). Note the sitemap protocol
+// limits a sitemap to 50,000 URLs.
+func WriteSitemapXML(w io.Writer, pages []*WebPage, lastMod time.Time, priorities *SitemapPriorities) error {
+	urlSet := xmlURLSet{XMLNS: sitemapXMLNamespace, URLs: make([]xmlURL, 0, len(pages))}
+	for _, page := range pages {
+		entry := xmlURL{Loc: page.URL.String()}
+		if modified := page.LastModified; !modified.IsZero() {
+			entry.LastMod = modified.Format(time.RFC3339)
+		} else if !lastMod.IsZero() {
+			entry.LastMod = lastMod.Format(time.RFC3339)
+		}
+		entry.ChangeFreq, entry.Priority = priorities.lookup(page)
+		for _, variant := range page.LanguageVariants() {
+			entry.Languages = append(entry.Languages, xmlAlternate{"alternate", variant.Lang, variant.URL})
+			urlSet.XHTML = xhtmlNamespace
+		}
+		for _, video := range page.Videos {
+			entry.Videos = append(entry.Videos, createXMLVideo(page, video))
+			urlSet.Video = videoNamespace
+		}
+		urlSet.URLs = append(urlSet.URLs, entry)
+	}
+	return writeXMLDocument(w, urlSet)
+}
+
+// createXMLVideo creates the video:video entry for a video on a page
+func createXMLVideo(page *WebPage, video Video) xmlVideo {
+	entry := xmlVideo{ThumbnailLoc: video.ThumbnailURL, Title: video.Title, Description: video.Description,
+		ContentLoc: video.ContentURL, PlayerLoc: video.PlayerURL}
+	if len(entry.Title) == 0 {
+		entry.Title = page.Title
+	}
+	if len(entry.Description) == 0 {
+		entry.Description = entry.Title
+	}
+	// durations outside the range allowed by the extension (1 second to 8 hours) are left out
+	if video.Duration > 0 && video.Duration <= 28800 {
+		entry.Duration = video.Duration
+	}
+	return entry
+}
+
+// writeXMLDocument writes an XML declaration followed by a document, indented
+func writeXMLDocument(w io.Writer, document any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
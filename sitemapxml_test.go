@@ -0,0 +1,166 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSitemapXML(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(rw, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%s/pages.xml</loc></sitemap>
+  <sitemap><loc>%s/blog.xml.gz</loc></sitemap>
+</sitemapindex>`, server.URL, server.URL)
+		case "/pages.xml":
+			fmt.Fprint(rw, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://test.com/ </loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc>https://test.com/about</loc></url>
+</urlset>`)
+		case "/blog.xml.gz":
+			gz := gzip.NewWriter(rw)
+			fmt.Fprint(gz, `<urlset><url><loc>https://test.com/blog</loc></url></urlset>`)
+			gz.Close()
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer server.Close()
+
+	urls, err := LoadSitemapXML(server.Client(), server.URL+"/sitemap.xml")
+	if err != nil {
+		t.Fatalf("Unexpected error loading sitemap: %v", err)
+	}
+	expected := "[https://test.com/ https://test.com/about https://test.com/blog]"
+	if fmt.Sprint(urls) != expected {
+		t.Errorf("Incorrect sitemap URLs: expected %s, got %v", expected, urls)
+	}
+
+	if _, err := LoadSitemapXML(server.Client(), server.URL+"/missing.xml"); err == nil {
+		t.Errorf("Missing expected error for missing sitemap")
+	}
+}
+
+func TestLoadSitemapXMLFile(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	fmt.Fprint(gz, `<urlset><url><loc>https://test.com/a</loc></url></urlset>`)
+	gz.Close()
+	fileName := filepath.Join(t.TempDir(), "sitemap.xml.gz")
+	if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := LoadSitemapXML(http.DefaultClient, fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error loading sitemap: %v", err)
+	}
+	if fmt.Sprint(urls) != "[https://test.com/a]" {
+		t.Errorf("Incorrect sitemap URLs: expected %s, got %v", "[https://test.com/a]", urls)
+	}
+}
+
+func TestCompareSitemap(t *testing.T) {
+	site := createQueryTestSite(t)
+	coverage := site.CompareSitemap([]string{
+		"https://test.com/",
+		"https://test.com/about",
+		"https://test.com/about/",
+		"https://test.com/orphan",
+		"https://test.com/never-crawled",
+	})
+	if coverage.Listed != 4 {
+		t.Errorf("Incorrect number of listed pages: expected %d, got %d", 4, coverage.Listed)
+	}
+	expectedOrphans := "[https://test.com/never-crawled https://test.com/orphan]"
+	if fmt.Sprint(coverage.Orphans) != expectedOrphans {
+		t.Errorf("Incorrect orphans: expected %s, got %v", expectedOrphans, coverage.Orphans)
+	}
+	expectedUnlisted := "[https://test.com/blog https://test.com/blog/2024 https://test.com/blog/2024/post]"
+	if fmt.Sprint(coverage.Unlisted) != expectedUnlisted {
+		t.Errorf("Incorrect unlisted pages: expected %s, got %v", expectedUnlisted, coverage.Unlisted)
+	}
+}
+
+func TestWriteSitemapXML(t *testing.T) {
+	pages := []*WebPage{
+		createWebPage(t, "https://test.com", "Home"),
+		createWebPage(t, "https://test.com/a?x=1&y=2", "A"),
+	}
+	lastMod := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := WriteSitemapXML(&buf, pages, lastMod, nil); err != nil {
+		t.Fatalf("Unexpected error writing sitemap: %v", err)
+	}
+	for _, expected := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		"<loc>https://test.com/a?x=1&amp;y=2</loc>",
+		"<lastmod>2024-03-01T12:30:00Z</lastmod>",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Incorrect sitemap: expected it to contain %s, got %s", expected, buf.String())
+		}
+	}
+
+	// the sitemap written can be read back
+	fileName := filepath.Join(t.TempDir(), "sitemap.xml")
+	if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	urls, err := LoadSitemapXML(http.DefaultClient, fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error loading sitemap: %v", err)
+	}
+	expected := "[https://test.com https://test.com/a?x=1&y=2]"
+	if fmt.Sprint(urls) != expected {
+		t.Errorf("Incorrect sitemap URLs: expected %s, got %v", expected, urls)
+	}
+}
+
+func TestWriteSitemapXMLLastModified(t *testing.T) {
+	modified := createWebPage(t, "https://test.com/a", "A")
+	modified.LastModified = time.Date(2023, 11, 5, 8, 0, 0, 0, time.UTC)
+	pages := []*WebPage{modified, createWebPage(t, "https://test.com/b", "B")}
+	var buf bytes.Buffer
+	if err := WriteSitemapXML(&buf, pages, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), nil); err != nil {
+		t.Fatalf("Unexpected error writing sitemap: %v", err)
+	}
+	for _, expected := range []string{
+		"<loc>https://test.com/a</loc>\n    <lastmod>2023-11-05T08:00:00Z</lastmod>",
+		"<loc>https://test.com/b</loc>\n    <lastmod>2024-03-01T12:30:00Z</lastmod>",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Incorrect sitemap: expected it to contain %s, got %s", expected, buf.String())
+		}
+	}
+}
+
+func TestWriteSitemapXMLLanguages(t *testing.T) {
+	page := createWebPage(t, "https://test.com/en/about", "About")
+	page.AddLanguage("en", "https://test.com/en/about")
+	page.AddLanguage("DE", "https://test.de/ueber")
+	var buf bytes.Buffer
+	if err := WriteSitemapXML(&buf, []*WebPage{page}, time.Time{}, nil); err != nil {
+		t.Fatalf("Unexpected error writing sitemap: %v", err)
+	}
+	for _, expected := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">`,
+		`<xhtml:link rel="alternate" hreflang="de" href="https://test.de/ueber"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="en" href="https://test.com/en/about"></xhtml:link>`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Incorrect sitemap: expected it to contain %s, got %s", expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package sitemap
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// notFoundProbePrefix is the start of the path of the nonexistent page requested to find how a site responds
+// to missing pages. A random suffix is added so it can't exist.
+const notFoundProbePrefix = "/go-sitemap-missing-"
+
+// soft404MaxDistance is the maximum number of bits the text similarity hash of a page can differ from that
+// of the site's not found page by for the page to be treated as a soft 404. Pages with the same title as the
+// not found page can differ by up to soft404TitledDistance bits, as not found pages often include the URL
+// requested, which changes the hash of a short page considerably.
+const (
+	soft404MaxDistance    = 3
+	soft404TitledDistance = 20
+)
+
+// NotFoundSignature describes how a site responds to a request for a page which doesn't exist, so pages
+// returned with a success status but looking like its not found page (soft 404s) can be found
+type NotFoundSignature struct {
+	URL         string // nonexistent URL requested
+	RedirectURL string // URL the request was redirected to (empty if it wasn't redirected)
+	StatusCode  int    // status returned, 404 (or 410) if the site handles missing pages correctly
+	Title       string // title of the page returned
+	ContentHash string // hash of the contents of the page returned
+	TextHash    uint64 // similarity hash (SimHash) of the text of the page returned
+}
+
+// Soft checks if the site returns a success status for missing pages, so they can't be told apart from real
+// pages by their status
+func (signature *NotFoundSignature) Soft() bool {
+	return signature.StatusCode >= http.StatusOK && signature.StatusCode < http.StatusMultipleChoices
+}
+
+// Matches checks if a page loaded with a success status looks like the site's not found page, either with
+// identical contents, near identical text or the same title and similar text. The page a missing page
+// redirects to (e.g. the home page) is never matched.
+func (signature *NotFoundSignature) Matches(page *WebPage) bool {
+	if page.StatusCode < http.StatusOK || page.StatusCode >= http.StatusMultipleChoices {
+		return false
+	} else if len(signature.RedirectURL) != 0 && page.URL.String() == signature.RedirectURL {
+		return false
+	} else if len(page.ContentHash) != 0 && page.ContentHash == signature.ContentHash {
+		return true
+	}
+	if signature.TextHash == 0 || page.TextHash == 0 {
+		return false
+	}
+	maxDistance := soft404MaxDistance
+	if len(signature.Title) != 0 && page.Title == signature.Title {
+		maxDistance = soft404TitledDistance
+	}
+	return simHashDistance(signature.TextHash, page.TextHash) <= maxDistance
+}
+
+// ProbeNotFound requests a nonexistent page on the site of the supplied URL, returning the signature of the
+// response so soft 404s can be found as the site is crawled (see the notFound field)
+func (loader *DocLoader) ProbeNotFound(start *url.URL) (*NotFoundSignature, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return nil, err
+	}
+	probe := start.ResolveReference(&url.URL{Path: notFoundProbePrefix + hex.EncodeToString(suffix[:])})
+	resp, err := loader.get(probe.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %v", probe, err)
+	}
+	defer resp.Body.Close()
+
+	signature := &NotFoundSignature{URL: probe.String(), StatusCode: resp.StatusCode}
+	if final := *resp.Request.URL; final.String() != signature.URL {
+		final.Path = strings.TrimSuffix(final.Path, "/") // as stored in the site map
+		signature.RedirectURL = final.String()
+	}
+	hash := sha256.New()
+	body := io.TeeReader(resp.Body, hash)
+	parser := &DocParser{TextHash: true}
+	page, err := parser.ParseDocument(signature.URL, decodeContent(body, resp.Header.Get("Content-Type")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse contents for URL %s: %v", probe, err)
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return nil, err
+	}
+	signature.Title = page.Title
+	signature.ContentHash = hex.EncodeToString(hash.Sum(nil))
+	signature.TextHash = page.TextHash
+	return signature, nil
+}
+
+// Soft404Pages returns the pages found to be soft 404s as they were loaded, sorted by URL
+func (site *SiteMap) Soft404Pages() []*WebPage {
+	return site.selectPages(func(key string, page *WebPage) bool { return page.Soft404 })
+}
@@ -0,0 +1,99 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSoft404(t *testing.T) {
+
+	// mock server - missing pages return a 200 status with a not found page mentioning the URL requested
+	const notFound = `<HTML><HEAD><title>Not Found</title></HEAD><BODY><h1>Sorry, we couldn't find that page</h1>
+		<p>The page %s may have moved or been deleted. Try searching the site or going back to the home page,
+		where you can browse all of our products, articles and help guides.</p></BODY></HTML>`
+	mockHandler := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Content-Type", "text/html")
+		switch req.URL.Path {
+		case "/real":
+			fmt.Fprint(rw, `<HTML><HEAD><title>Real</title></HEAD><BODY><p>A real page about our products and articles.</p></BODY></HTML>`)
+		default:
+			fmt.Fprintf(rw, notFound, req.URL.Path)
+		}
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(mockHandler))
+	defer mockServer.Close()
+
+	parser := CreateDocumentParser()
+	parser.TextHash = true
+	docLoader := CreateDocumentLoader(parser)
+	signature, err := docLoader.ProbeNotFound(mustParseURL(t, mockServer.URL))
+	if err != nil {
+		t.Fatalf("Unexpected error probing for missing pages: %v", err)
+	}
+	if !signature.Soft() || signature.Title != "Not Found" || !strings.Contains(signature.URL, notFoundProbePrefix) {
+		t.Errorf("Incorrect not found signature: expected a soft 404 titled Not Found, got %+v", signature)
+	}
+
+	docLoader.NotFound = signature
+	site := CreateSiteMap(mustParseURL(t, mockServer.URL))
+	for path, expected := range map[string]bool{"/real": false, "/discontinued-product": true} {
+		page, err := docLoader.LoadURL(mockServer.URL + path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if page.Soft404 != expected {
+			t.Errorf("Incorrect soft 404 for %s: expected %v, got %v", path, expected, page.Soft404)
+		}
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := PrintSoft404Pages(&buf, signature, site.Soft404Pages(), nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Soft 404 pages (1)", "missing pages return status 200 (" + signature.URL + ")",
+		" " + mockServer.URL + "/discontinued-product [Not Found]\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Incorrect soft 404 report: expected %q in %s", line, buf.String())
+		}
+	}
+}
+
+func TestNotFoundSignatureRedirect(t *testing.T) {
+
+	// mock server - missing pages redirect to the home page
+	mockServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.Redirect(rw, req, "/", http.StatusFound)
+			return
+		}
+		rw.Header().Add("Content-Type", "text/html")
+		fmt.Fprint(rw, `<HTML><HEAD><title>Home</title></HEAD><BODY>Welcome</BODY></HTML>`)
+	}))
+	defer mockServer.Close()
+
+	docLoader := CreateDocumentLoader(CreateDocumentParser())
+	signature, err := docLoader.ProbeNotFound(mustParseURL(t, mockServer.URL))
+	if err != nil {
+		t.Fatalf("Unexpected error probing for missing pages: %v", err)
+	}
+	if signature.RedirectURL != mockServer.URL {
+		t.Errorf("Incorrect redirect: expected %s, got %s", mockServer.URL, signature.RedirectURL)
+	}
+
+	// the home page itself is never a soft 404
+	docLoader.NotFound = signature
+	page, err := docLoader.LoadURL(mockServer.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if page.Soft404 {
+		t.Errorf("Incorrect soft 404 for the home page: expected false, got true")
+	}
+}
@@ -0,0 +1,150 @@
+package sitemap
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// sqlBatchSize is the maximum number of rows inserted by each INSERT statement written by WriteSQL
+const sqlBatchSize = 500
+
+// sqlSchema creates the tables written by WriteSQL. Only types common to PostgreSQL, MySQL and SQLite are used.
+const sqlSchema = `DROP TABLE IF EXISTS links;
+DROP TABLE IF EXISTS errors;
+DROP TABLE IF EXISTS pages;
+
+CREATE TABLE pages (
+  id INTEGER NOT NULL PRIMARY KEY,
+  url TEXT NOT NULL,
+  title TEXT NOT NULL,
+  depth INTEGER,
+  outlinks INTEGER NOT NULL,
+  inlinks INTEGER NOT NULL,
+  pagerank DOUBLE PRECISION NOT NULL,
+  canonical TEXT,
+  content_hash VARCHAR(64),
+  last_modified TIMESTAMP,
+  soft404 BOOLEAN NOT NULL
+);
+
+CREATE TABLE links (
+  source_id INTEGER NOT NULL REFERENCES pages (id),
+  target_url TEXT NOT NULL,
+  target_id INTEGER REFERENCES pages (id)
+);
+
+CREATE TABLE errors (
+  url TEXT NOT NULL,
+  class VARCHAR(16) NOT NULL,
+  status INTEGER,
+  message TEXT NOT NULL,
+  attempts INTEGER NOT NULL,
+  linked_from TEXT NOT NULL
+);
+`
+
+// sqlIndexes are created once the rows have been inserted
+const sqlIndexes = `CREATE INDEX links_source ON links (source_id);
+CREATE INDEX links_target ON links (target_id);
+`
+
+// WriteSQL writes the pages in a crawl document as a SQL dump, creating (or recreating) a pages table with
+// one row per page and a links table with one row per internal link. Each page is given an id in URL order,
+// and the target_id of a link is null if its target wasn't loaded (e.g. it was beyond a crawl limit). The
+// depth is null for pages not reachable from the starting page, and last_modified is in UTC. The URLs which
+// failed to load are written to an errors table, with the status null unless the class is status and
+// linked_from listing the URLs of the pages linking to each separated by spaces.
+//
+// The dump loads into PostgreSQL, MySQL and SQLite, although MySQL must be run with the NO_BACKSLASH_ESCAPES
+// SQL mode so backslashes in strings are loaded as written.
+func WriteSQL(w io.Writer, doc *CrawlDocument) error {
+	if _, err := fmt.Fprintf(w, "-- Crawl of %s (schema version %s) written by go-sitemap\n", sqlComment(doc.Site), doc.SchemaVersion); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "-- MySQL: load with SET sql_mode = 'NO_BACKSLASH_ESCAPES'\n\n%s\nBEGIN;\n", sqlSchema); err != nil {
+		return err
+	}
+
+	ids := make(map[string]int, len(doc.Pages))
+	for i, record := range doc.Pages {
+		ids[record.URL] = i + 1
+		for _, alias := range record.Aliases {
+			ids[alias] = i + 1
+		}
+	}
+	pages := make([]string, 0, len(doc.Pages))
+	var links []string
+	for i, record := range doc.Pages {
+		depth, lastMod := "NULL", "NULL"
+		if record.Depth != nil {
+			depth = strconv.Itoa(*record.Depth)
+		}
+		if record.LastModified != nil {
+			lastMod = sqlString(record.LastModified.UTC().Format("2006-01-02 15:04:05"))
+		}
+		pages = append(pages, fmt.Sprintf("(%d, %s, %s, %s, %d, %d, %s, %s, %s, %s, %t)", i+1,
+			sqlString(record.URL), sqlString(record.Title), depth, len(record.Links), len(record.Inlinks),
+			strconv.FormatFloat(record.PageRank, 'f', 6, 64), sqlNullString(record.Canonical),
+			sqlNullString(record.ContentHash), lastMod, record.Soft404))
+		for _, link := range record.Links {
+			target := "NULL"
+			if id, found := ids[link]; found {
+				target = strconv.Itoa(id)
+			}
+			links = append(links, fmt.Sprintf("(%d, %s, %s)", i+1, sqlString(link), target))
+		}
+	}
+	if err := writeSQLInserts(w, "pages (id, url, title, depth, outlinks, inlinks, pagerank, canonical, content_hash, last_modified, soft404)", pages); err != nil {
+		return err
+	}
+	if err := writeSQLInserts(w, "links (source_id, target_url, target_id)", links); err != nil {
+		return err
+	}
+	failures := make([]string, 0, len(doc.Errors))
+	for _, record := range doc.Errors {
+		status := "NULL"
+		if record.Status != 0 {
+			status = strconv.Itoa(record.Status)
+		}
+		failures = append(failures, fmt.Sprintf("(%s, %s, %s, %s, %d, %s)", sqlString(record.URL), sqlString(record.Class),
+			status, sqlString(record.Error), record.Attempts, sqlString(strings.Join(record.Referrers, " "))))
+	}
+	if err := writeSQLInserts(w, "errors (url, class, status, message, attempts, linked_from)", failures); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "COMMIT;\n\n%s", sqlIndexes)
+	return err
+}
+
+// writeSQLInserts writes INSERT statements for the rows of a table, sqlBatchSize rows at a time
+func writeSQLInserts(w io.Writer, table string, rows []string) error {
+	for start := 0; start < len(rows); start += sqlBatchSize {
+		end := min(start+sqlBatchSize, len(rows))
+		if _, err := fmt.Fprintf(w, "INSERT INTO %s VALUES\n  %s;\n", table, strings.Join(rows[start:end], ",\n  ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlString quotes a string as a SQL literal, doubling any single quotes. NUL characters, which can't be
+// stored in a PostgreSQL text column, are removed.
+func sqlString(value string) string {
+	value = strings.ReplaceAll(value, "\x00", "")
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// sqlNullString quotes a string as a SQL literal, or returns NULL if it is empty
+func sqlNullString(value string) string {
+	if len(value) == 0 {
+		return "NULL"
+	}
+	return sqlString(value)
+}
+
+// sqlComment makes a value safe to include in a single line SQL comment
+func sqlComment(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
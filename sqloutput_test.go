@@ -0,0 +1,57 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSQL(t *testing.T) {
+	depth := 0
+	modified := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	doc := &CrawlDocument{SchemaVersion: JSONSchemaVersion, Site: "https://test.com", Pages: []PageRecord{
+		{URL: "https://test.com", Title: "Bob's page", Depth: &depth, Links: []string{"https://test.com/a", "https://test.com/missing"},
+			PageRank: 0.5, LastModified: &modified},
+		{URL: "https://test.com/about", Aliases: []string{"https://test.com/a"}, Title: "About\x00", Inlinks: []string{"https://test.com"},
+			Canonical: "https://test.com/about", Soft404: true},
+	}, Errors: []ErrorRecord{{URL: "https://test.com/missing", Class: "status", Status: 404, Error: "bad status code", Attempts: 1,
+		Referrers: []string{"https://test.com"}}, {URL: "https://test.com/slow", Class: "timeout", Error: "timed out", Attempts: 2}}}
+	var buf bytes.Buffer
+	if err := WriteSQL(&buf, doc); err != nil {
+		t.Fatalf("Failed to write SQL: %v", err)
+	}
+	dump := buf.String()
+	for _, expected := range []string{
+		"CREATE TABLE pages (",
+		"(1, 'https://test.com', 'Bob''s page', 0, 2, 0, 0.500000, NULL, NULL, '2024-03-01 11:30:00', false)",
+		"(2, 'https://test.com/about', 'About', NULL, 0, 1, 0.000000, 'https://test.com/about', NULL, NULL, true);",
+		"(1, 'https://test.com/a', 2),\n  (1, 'https://test.com/missing', NULL);",
+		"CREATE TABLE errors (",
+		"('https://test.com/missing', 'status', 404, 'bad status code', 1, 'https://test.com'),\n" +
+			"  ('https://test.com/slow', 'timeout', NULL, 'timed out', 2, '');",
+		"COMMIT;",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Incorrect SQL: expected it to contain %q, got\n%v", expected, dump)
+		}
+	}
+}
+
+func TestWriteSQLBatches(t *testing.T) {
+	doc := &CrawlDocument{}
+	for i := 0; i < sqlBatchSize+1; i++ {
+		doc.Pages = append(doc.Pages, PageRecord{URL: fmt.Sprintf("https://test.com/%d", i)})
+	}
+	var buf bytes.Buffer
+	if err := WriteSQL(&buf, doc); err != nil {
+		t.Fatalf("Failed to write SQL: %v", err)
+	}
+	if inserts := strings.Count(buf.String(), "INSERT INTO pages"); inserts != 2 {
+		t.Errorf("Incorrect number of INSERT statements: expected %v, got %v", 2, inserts)
+	}
+	if inserts := strings.Count(buf.String(), "INSERT INTO links"); inserts != 0 {
+		t.Errorf("Incorrect number of links INSERT statements: expected %v, got %v", 0, inserts)
+	}
+}
@@ -0,0 +1,253 @@
+package sitemap
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StreamManifestFile is the name of the manifest written to a stream directory
+const StreamManifestFile = "manifest.json"
+
+// streamCSVHeader is the header row of each CSV part file. Only details known as each page is loaded are
+// written, as the depth, inlinks and PageRank of a page are only known once crawling is complete.
+var streamCSVHeader = []string{"url", "title", "status", "outlinks", "lastmod", "content_hash"}
+
+// StreamPart describes a completed part file of a stream, in its manifest
+type StreamPart struct {
+	File   string `json:"file"`   // name of the file in the stream directory
+	Pages  int    `json:"pages"`  // number of pages written to the file
+	Bytes  int64  `json:"bytes"`  // size of the file
+	SHA256 string `json:"sha256"` // hash of the file's contents, so truncated or corrupted files can be found
+}
+
+// StreamManifest records the part files of a stream, and whether the crawl writing it completed. Part files
+// not listed were still being written when the crawl stopped, and may be incomplete.
+type StreamManifest struct {
+	Site          string       `json:"site"`
+	Format        string       `json:"format"`        // jsonl or csv
+	SchemaVersion string       `json:"schemaVersion"` // version of the JSON page records (jsonl only)
+	Started       time.Time    `json:"started"`
+	Updated       time.Time    `json:"updated"`
+	Complete      bool         `json:"complete"` // set once the crawl completed and every page was written
+	Pages         int          `json:"pages"`    // total pages in the part files listed
+	Parts         []StreamPart `json:"parts"`
+}
+
+// StreamWriter writes each page to a directory as it is crawled, so results aren't lost if the crawl fails.
+// Pages are written to numbered part files (part-00001.jsonl, ...) in JSON lines or CSV format, and on each
+// Flush the current part is synced to disk, closed and recorded in the manifest, with later pages written to
+// a new part. Flushing periodically (see Run) means a crash loses at most the pages since the last flush.
+type StreamWriter struct {
+	dir      string
+	manifest StreamManifest
+	mutex    sync.Mutex
+
+	// current part file (nil if no pages have been written since the last flush)
+	file      *os.File
+	hash      hash.Hash
+	csv       *csv.Writer
+	partPages int
+}
+
+// CreateStreamWriter creates a StreamWriter writing pages from a crawl of the site to dir in a format (jsonl
+// or csv). The directory is created if needed, and must not contain a previous stream.
+func CreateStreamWriter(dir string, format string, site string) (*StreamWriter, error) {
+	if format != "jsonl" && format != "csv" {
+		return nil, fmt.Errorf("unsupported stream format %q, expected jsonl or csv", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, StreamManifestFile)); err == nil {
+		return nil, fmt.Errorf("stream directory %s already contains a stream (%s)", dir, StreamManifestFile)
+	}
+	now := time.Now().UTC()
+	stream := &StreamWriter{dir: dir, manifest: StreamManifest{Site: site, Format: format, Started: now, Updated: now, Parts: []StreamPart{}}}
+	if format == "jsonl" {
+		stream.manifest.SchemaVersion = JSONSchemaVersion
+	}
+	return stream, stream.writeManifest()
+}
+
+// OnPage writes each loaded page to the stream, so can be used with WithOnPage (after any callbacks which
+// may discard pages). Pages are always kept, with write failures logged.
+func (stream *StreamWriter) OnPage(visit *PageVisit) bool {
+	if err := stream.Write(visit.Page); err != nil {
+		defaultLogger().Error("Failed to write page to stream", "url", visit.Page.URL.String(), "error", err)
+	}
+	return true
+}
+
+// Write writes a page to the current part file, starting a new part if needed
+func (stream *StreamWriter) Write(page *WebPage) error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if stream.file == nil {
+		if err := stream.startPart(); err != nil {
+			return err
+		}
+	}
+	record := CreatePageRecord(page)
+	if stream.csv != nil {
+		lastMod := ""
+		if record.LastModified != nil {
+			lastMod = record.LastModified.Format(time.RFC3339)
+		}
+		if err := stream.csv.Write([]string{record.URL, record.Title, strconv.Itoa(page.StatusCode),
+			strconv.Itoa(len(record.Links)), lastMod, record.ContentHash}); err != nil {
+			return err
+		}
+		stream.csv.Flush()
+		if err := stream.csv.Error(); err != nil {
+			return err
+		}
+	} else {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := stream.writer().Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	stream.partPages++
+	return nil
+}
+
+// Flush syncs the current part file to disk and records it in the manifest, so later pages are written to a
+// new part. Nothing is written if no pages have been written since the last flush.
+func (stream *StreamWriter) Flush() error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.finishPart()
+}
+
+// Run flushes the stream every interval until done is closed
+func (stream *StreamWriter) Run(interval time.Duration, done <-chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := stream.Flush(); err != nil {
+				defaultLogger().Error("Failed to flush stream", "dir", stream.dir, "error", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// Close flushes the stream, recording it as complete in the manifest
+func (stream *StreamWriter) Close() error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if err := stream.finishPart(); err != nil {
+		return err
+	}
+	stream.manifest.Complete = true
+	return stream.writeManifest()
+}
+
+// writer returns the writer for the current part file, which also hashes its contents
+func (stream *StreamWriter) writer() io.Writer {
+	return io.MultiWriter(stream.file, stream.hash)
+}
+
+// startPart creates the next part file (the mutex must be held)
+func (stream *StreamWriter) startPart() error {
+	name := fmt.Sprintf("part-%05d.%s", len(stream.manifest.Parts)+1, stream.manifest.Format)
+	file, err := os.OpenFile(filepath.Join(stream.dir, name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	stream.file, stream.hash, stream.partPages = file, sha256.New(), 0
+	if stream.manifest.Format == "csv" {
+		stream.csv = csv.NewWriter(stream.writer())
+		return stream.csv.Write(streamCSVHeader)
+	}
+	return nil
+}
+
+// finishPart syncs and closes the current part file, then records it in the manifest (the mutex must be held)
+func (stream *StreamWriter) finishPart() error {
+	if stream.file == nil {
+		return nil
+	}
+	file := stream.file
+	stream.file, stream.csv = nil, nil
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	stream.manifest.Parts = append(stream.manifest.Parts, StreamPart{
+		File:   filepath.Base(file.Name()),
+		Pages:  stream.partPages,
+		Bytes:  info.Size(),
+		SHA256: hex.EncodeToString(stream.hash.Sum(nil)),
+	})
+	stream.manifest.Pages += stream.partPages
+	return stream.writeManifest()
+}
+
+// writeManifest replaces the manifest atomically (the mutex must be held)
+func (stream *StreamWriter) writeManifest() error {
+	stream.manifest.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(stream.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(stream.dir, StreamManifestFile), append(data, '\n'))
+}
+
+// writeFileAtomic replaces a file atomically, writing the data to a temporary file in the same directory
+// which is synced then renamed, so the file is never left partly written if the process is interrupted
+func writeFileAtomic(fileName string, data []byte) error {
+	dirName := filepath.Dir(fileName)
+	temp, err := os.CreateTemp(dirName, filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name()) // fails once renamed
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(0o644); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), fileName); err != nil {
+		return err
+	}
+	if dir, err := os.Open(dirName); err == nil {
+		dir.Sync() // make the rename durable (not supported on every platform)
+		dir.Close()
+	}
+	return nil
+}
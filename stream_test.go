@@ -0,0 +1,117 @@
+package sitemap
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readStreamManifest reads the manifest of a stream directory
+func readStreamManifest(t *testing.T, dir string) StreamManifest {
+	data, err := os.ReadFile(filepath.Join(dir, StreamManifestFile))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest StreamManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	return manifest
+}
+
+func TestStreamWriterJSONLines(t *testing.T) {
+
+	dir := filepath.Join(t.TempDir(), "stream")
+	stream, err := CreateStreamWriter(dir, "jsonl", "https://test.com")
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+	for _, path := range []string{"", "/a"} {
+		stream.OnPage(&PageVisit{Page: createWebPage(t, "https://test.com"+path, "Page "+path)})
+	}
+	if err := stream.Flush(); err != nil {
+		t.Fatalf("Failed to flush stream: %v", err)
+	}
+
+	// the flushed part is recorded before the crawl completes, with the page being written not yet listed
+	stream.OnPage(&PageVisit{Page: createWebPage(t, "https://test.com/b", "Page /b")})
+	manifest := readStreamManifest(t, dir)
+	if manifest.Complete || manifest.Pages != 2 || len(manifest.Parts) != 1 || manifest.Parts[0].File != "part-00001.jsonl" {
+		t.Errorf("Incorrect manifest after flush: expected 1 part of 2 pages, got %+v", manifest)
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Failed to close stream: %v", err)
+	}
+	manifest = readStreamManifest(t, dir)
+	if !manifest.Complete || manifest.Pages != 3 || len(manifest.Parts) != 2 || manifest.SchemaVersion != JSONSchemaVersion {
+		t.Errorf("Incorrect manifest after close: expected 2 complete parts of 3 pages, got %+v", manifest)
+	}
+	var urls []string
+	for _, part := range manifest.Parts {
+		data, err := os.ReadFile(filepath.Join(dir, part.File))
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != part.SHA256 || int64(len(data)) != part.Bytes {
+			t.Errorf("Incorrect hash or size recorded for %v", part.File)
+		}
+		file, _ := os.Open(filepath.Join(dir, part.File))
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var record PageRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Errorf("Invalid JSON line in %v: %v", part.File, err)
+			}
+			urls = append(urls, record.URL)
+		}
+		file.Close()
+	}
+	if len(urls) != 3 || urls[2] != "https://test.com/b" {
+		t.Errorf("Incorrect pages streamed: expected 3 ending with https://test.com/b, got %v", urls)
+	}
+
+	if _, err := CreateStreamWriter(dir, "jsonl", "https://test.com"); err == nil {
+		t.Errorf("Incorrect result for directory with an existing stream: expected an error, got none")
+	}
+}
+
+func TestStreamWriterCSV(t *testing.T) {
+
+	dir := t.TempDir()
+	stream, err := CreateStreamWriter(dir, "csv", "https://test.com")
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+	page := createWebPage(t, "https://test.com/a", "Page, with a comma")
+	page.StatusCode = 200
+	page.AddLink("https://test.com/b", Link{})
+	if err := stream.Write(page); err != nil {
+		t.Fatalf("Failed to write page: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Failed to close stream: %v", err)
+	}
+	file, err := os.Open(filepath.Join(dir, "part-00001.csv"))
+	if err != nil {
+		t.Fatalf("Failed to open part: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("Incorrect CSV part: expected a header and 1 row, got %v (%v)", rows, err)
+	}
+	if expected := []string{"https://test.com/a", "Page, with a comma", "200", "1", "", ""}; fmt.Sprint(rows[1]) != fmt.Sprint(expected) {
+		t.Errorf("Incorrect CSV row: expected %v, got %v", expected, rows[1])
+	}
+
+	if _, err := CreateStreamWriter(t.TempDir(), "warc", "https://test.com"); err == nil {
+		t.Errorf("Incorrect result for unsupported format: expected an error, got none")
+	}
+}
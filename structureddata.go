@@ -0,0 +1,210 @@
+package sitemap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// schemaPrefixes are the prefixes of schema.org types written as URLs or compact IRIs, removed so each type is
+// recorded by its name (e.g. Product)
+var schemaPrefixes = []string{"https://schema.org/", "http://schema.org/", "schema:"}
+
+// schemaTypeName returns the name of a schema.org type, without any schema.org prefix (empty if it is blank)
+func schemaTypeName(schemaType string) string {
+	schemaType = strings.TrimSpace(schemaType)
+	for _, prefix := range schemaPrefixes {
+		if len(schemaType) > len(prefix) && strings.EqualFold(schemaType[:len(prefix)], prefix) {
+			return schemaType[len(prefix):]
+		}
+	}
+	return schemaType
+}
+
+// AddSchemaType records a structured data type found on the page, keeping the types sorted
+func (page *WebPage) AddSchemaType(schemaType string) {
+	name := schemaTypeName(schemaType)
+	idx := sort.SearchStrings(page.SchemaTypes, name)
+	if len(name) == 0 || (idx < len(page.SchemaTypes) && page.SchemaTypes[idx] == name) {
+		return
+	}
+	page.SchemaTypes = append(page.SchemaTypes, "")
+	copy(page.SchemaTypes[idx+1:], page.SchemaTypes[idx:])
+	page.SchemaTypes[idx] = name
+}
+
+// parseJSONLDTypes returns the types (@type values) of the objects at any level of a JSON-LD script, including
+// nested objects such as the offers of a product. Returns nil if the script isn't valid JSON.
+func parseJSONLDTypes(script string) []string {
+	var data any
+	if err := json.Unmarshal([]byte(script), &data); err != nil {
+		return nil
+	}
+	var types []string
+	var collect func(value any)
+	collect = func(value any) {
+		switch value := value.(type) {
+		case []any:
+			for _, item := range value {
+				collect(item)
+			}
+		case map[string]any:
+			switch objectTypes := value["@type"].(type) {
+			case string:
+				types = append(types, objectTypes)
+			case []any:
+				for _, objectType := range objectTypes {
+					if name, ok := objectType.(string); ok {
+						types = append(types, name)
+					}
+				}
+			}
+			for _, item := range value {
+				collect(item)
+			}
+		}
+	}
+	collect(data)
+	return types
+}
+
+// addMicrodataTypes records the types of a microdata item, from the URLs in its itemtype attribute
+func addMicrodataTypes(tag *html.Token, page *WebPage) {
+	if itemTypes, found := attrValue(tag, "itemtype"); found {
+		for _, itemType := range strings.Fields(itemTypes) {
+			page.AddSchemaType(itemType)
+		}
+	}
+}
+
+// SchemaTypeUsage is a structured data type found on pages of the site
+type SchemaTypeUsage struct {
+	Type  string // name of the type, e.g. Product
+	Pages int    // number of pages it is found on
+}
+
+// SchemaTypes returns the structured data types found on the site's pages, used by the most pages first
+// (then by name)
+func (site *SiteMap) SchemaTypes() []SchemaTypeUsage {
+	pages := make(map[string]int)
+	for _, page := range site.Pages {
+		for _, schemaType := range page.SchemaTypes {
+			pages[schemaType]++
+		}
+	}
+	usage := make([]SchemaTypeUsage, 0, len(pages))
+	for schemaType, count := range pages {
+		usage = append(usage, SchemaTypeUsage{schemaType, count})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Pages != usage[j].Pages {
+			return usage[i].Pages > usage[j].Pages
+		}
+		return usage[i].Type < usage[j].Type
+	})
+	return usage
+}
+
+// SchemaRule is the structured data types expected on the pages whose path matches a glob
+type SchemaRule struct {
+	Pattern string         // path glob (see FindByPath)
+	path    *regexp.Regexp // compiled path glob
+	Types   []string       // names of the types expected
+}
+
+// ParseSchemaRule parses a rule written as a path glob then a colon and a comma separated list of the types
+// expected on the pages matching it, e.g. "/products/**: Product, BreadcrumbList". Types may be written as
+// schema.org URLs.
+func ParseSchemaRule(rule string) (SchemaRule, error) {
+	pattern, types, found := strings.Cut(rule, ":")
+	parsed := SchemaRule{Pattern: strings.TrimSpace(pattern)}
+	if !found || !strings.HasPrefix(parsed.Pattern, "/") {
+		return SchemaRule{}, fmt.Errorf("invalid structured data rule %q, expected /path: Type, Type", rule)
+	}
+	var err error
+	if parsed.path, err = CompilePathGlob(parsed.Pattern); err != nil {
+		return SchemaRule{}, fmt.Errorf("invalid path in structured data rule %q: %v", rule, err)
+	}
+	for _, schemaType := range strings.Split(types, ",") {
+		if name := schemaTypeName(schemaType); len(name) != 0 {
+			parsed.Types = append(parsed.Types, name)
+		}
+	}
+	if len(parsed.Types) == 0 {
+		return SchemaRule{}, fmt.Errorf("invalid structured data rule %q, expected at least one type", rule)
+	}
+	return parsed, nil
+}
+
+// ParseSchemaRules parses a list of rules, one per line (see ParseSchemaRule). Blank lines and lines starting
+// with # are ignored.
+func ParseSchemaRules(reader io.Reader) ([]SchemaRule, error) {
+	var rules []SchemaRule
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := ParseSchemaRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// LoadSchemaRules loads a file of rules (see ParseSchemaRules)
+func LoadSchemaRules(fileName string) ([]SchemaRule, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSchemaRules(file)
+}
+
+// SchemaIssue is a page missing structured data types expected by the rules matching its path
+type SchemaIssue struct {
+	URL     string   // URL of the page
+	Missing []string // types expected but not found, in the order of the rules
+}
+
+// MissingSchemaTypes checks the structured data types found on each page against the types expected by every
+// rule matching its path, returning the pages missing any sorted by URL. Soft 404 pages are not checked.
+func (site *SiteMap) MissingSchemaTypes(rules []SchemaRule) []SchemaIssue {
+	var issues []SchemaIssue
+	for _, page := range site.Pages {
+		if page.Soft404 {
+			continue
+		}
+		issue := SchemaIssue{URL: page.URL.String()}
+		reported := make(map[string]bool)
+		for _, rule := range rules {
+			if !rule.path.MatchString(urlPath(page.URL)) {
+				continue
+			}
+			for _, schemaType := range rule.Types {
+				idx := sort.SearchStrings(page.SchemaTypes, schemaType)
+				found := idx < len(page.SchemaTypes) && page.SchemaTypes[idx] == schemaType
+				if !found && !reported[schemaType] {
+					issue.Missing = append(issue.Missing, schemaType)
+					reported[schemaType] = true
+				}
+			}
+		}
+		if len(issue.Missing) != 0 {
+			issues = append(issues, issue)
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].URL < issues[j].URL })
+	return issues
+}
@@ -0,0 +1,117 @@
+package sitemap
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSchemaTypes(t *testing.T) {
+
+	const doc = `<html><head><title>Test</title>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+	{"@type": "Product", "name": "Widget", "offers": {"@type": "Offer", "price": "9.99"}},
+	{"@type": ["BreadcrumbList", "https://schema.org/ItemList"]}
+]}
+</script>
+<script type="application/ld+json">{not json</script>
+</head><body>
+<div itemscope itemtype="https://schema.org/Review"><span itemprop="author">Me</span></div>
+<div itemscope itemtype="http://schema.org/Person  http://schema.org/Product"></div>
+</body></html>`
+	tests := []struct {
+		schemaTypes bool
+		expected    []string
+	}{
+		{false, nil},
+		{true, []string{"BreadcrumbList", "ItemList", "Offer", "Person", "Product", "Review"}},
+	}
+	for _, test := range tests {
+		parser := CreateDocumentParser()
+		parser.SchemaTypes = test.schemaTypes
+		page, err := parser.ParseDocument("https://test.com", strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("Failed to parse document: %v", err)
+		}
+		if !reflect.DeepEqual(page.SchemaTypes, test.expected) {
+			t.Errorf("Incorrect schema types: expected %v, got %v", test.expected, page.SchemaTypes)
+		}
+	}
+}
+
+func TestParseSchemaRules(t *testing.T) {
+
+	rules, err := ParseSchemaRules(strings.NewReader(`
+# product pages
+/products/**: Product, https://schema.org/BreadcrumbList
+
+/**: WebPage
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse structured data rules: %v", err)
+	}
+	if len(rules) != 2 || !reflect.DeepEqual(rules[0].Types, []string{"Product", "BreadcrumbList"}) || rules[1].Pattern != "/**" {
+		t.Errorf("Incorrect structured data rules: got %v", rules)
+	}
+
+	for _, rule := range []string{"/products/**", "products: Product", "/products/**: ,"} {
+		if _, err := ParseSchemaRules(strings.NewReader("/**: WebPage\n" + rule)); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("Incorrect error for rule %q: got %v", rule, err)
+		}
+	}
+}
+
+func TestMissingSchemaTypes(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	for urlStr, schemaTypes := range map[string][]string{
+		"https://test.com":            {"WebSite", "WebPage"},
+		"https://test.com/products/a": {"Product", "BreadcrumbList", "WebPage"},
+		"https://test.com/products/b": {"Product"},
+		"https://test.com/about":      nil,
+		"https://test.com/missing":    nil,
+	} {
+		page := createWebPage(t, urlStr, "")
+		for _, schemaType := range schemaTypes {
+			page.AddSchemaType(schemaType)
+		}
+		page.Soft404 = urlStr == "https://test.com/missing"
+		site.AddPage(page)
+	}
+
+	types := site.SchemaTypes()
+	expectedTypes := []SchemaTypeUsage{{"Product", 2}, {"WebPage", 2}, {"BreadcrumbList", 1}, {"WebSite", 1}}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Errorf("Incorrect schema types: expected %v, got %v", expectedTypes, types)
+	}
+
+	rules, err := ParseSchemaRules(strings.NewReader("/products/**: Product, BreadcrumbList\n/**: WebPage, Product"))
+	if err != nil {
+		t.Fatalf("Failed to parse structured data rules: %v", err)
+	}
+	issues := site.MissingSchemaTypes(rules)
+	expected := []SchemaIssue{
+		{"https://test.com", []string{"Product"}},
+		{"https://test.com/about", []string{"WebPage", "Product"}},
+		{"https://test.com/products/b", []string{"BreadcrumbList", "WebPage"}},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Incorrect structured data issues: expected %v, got %v", expected, issues)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintSchemaTypes(&buf, types[:2], issues[1:], true, nil); err != nil {
+		t.Fatalf("Failed to print structured data report: %v", err)
+	}
+	expectedReport := "\n\n ----- Structured data types (2) -----\n" +
+		" Product (2 pages)\n" +
+		" WebPage (2 pages)\n" +
+		"\n ----- Pages missing expected structured data (2) -----\n" +
+		" https://test.com/about: missing WebPage, Product\n" +
+		" https://test.com/products/b: missing BreadcrumbList, WebPage\n"
+	if buf.String() != expectedReport {
+		t.Errorf("Incorrect structured data report: expected %q, got %q", expectedReport, buf.String())
+	}
+}
@@ -0,0 +1,123 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// dataLinkAttributes are the data attributes commonly used to make elements other than <a> navigate to a URL
+// with JavaScript (e.g. clickable table rows or cards)
+var dataLinkAttributes = []string{"data-href", "data-url", "data-link"}
+
+// jsonLDLinkProperties are the JSON-LD properties whose values are taken to be URLs of related pages
+var jsonLDLinkProperties = map[string]bool{
+	"@id": true, "url": true, "item": true, "mainEntityOfPage": true, "sameAs": true, "target": true,
+	"relatedLink": true, "significantLink": true, "isPartOf": true, "hasPart": true,
+}
+
+// jsonLDLink is a URL found in JSON-LD structured data
+type jsonLDLink struct {
+	ref  string // URL as written
+	name string // name of the object the URL belongs to (empty if none)
+}
+
+// parseJSONLDLinks returns the URLs in a JSON-LD script, from the values of the properties in
+// jsonLDLinkProperties at any level, sorted by URL. Returns nil if the script isn't valid JSON.
+func parseJSONLDLinks(script string) []jsonLDLink {
+	var data any
+	if err := json.Unmarshal([]byte(script), &data); err != nil {
+		return nil
+	}
+	var links []jsonLDLink
+	var collect func(value any, name string, isLink bool)
+	collect = func(value any, name string, isLink bool) {
+		switch value := value.(type) {
+		case string:
+			if isLink {
+				links = append(links, jsonLDLink{value, name})
+			}
+		case []any:
+			for _, item := range value {
+				collect(item, name, isLink)
+			}
+		case map[string]any:
+			name, _ := value["name"].(string)
+			for property, item := range value {
+				collect(item, collapseSpace(name), jsonLDLinkProperties[property])
+			}
+		}
+	}
+	collect(data, "", false)
+	sort.SliceStable(links, func(i, j int) bool { return links[i].ref < links[j].ref })
+	return links
+}
+
+// parseMetaRefresh returns the URL a meta refresh tag's content (e.g. "5; url=/next") redirects to, or false
+// if it only reloads the page
+func parseMetaRefresh(content string) (string, bool) {
+	_, target, found := strings.Cut(content, ";")
+	if !found {
+		if _, target, found = strings.Cut(content, ","); !found {
+			return "", false
+		}
+	}
+	target = strings.TrimSpace(target)
+	if key, value, found := strings.Cut(target, "="); found && strings.EqualFold(strings.TrimSpace(key), "url") {
+		target = strings.TrimSpace(value)
+	}
+	target = strings.Trim(target, `'"`)
+	return target, len(target) != 0
+}
+
+// addJSONLDLinks records the URLs in a JSON-LD script as links
+func (p *DocParser) addJSONLDLinks(parentURL *url.URL, page *WebPage, script string, context LinkContext) error {
+	for _, link := range parseJSONLDLinks(script) {
+		if err := p.addLink(parentURL, page, link.ref, Link{link.name, context}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMetaRefresh records the target of a meta refresh tag as a link
+func (p *DocParser) addMetaRefresh(tag *html.Token, parentURL *url.URL, page *WebPage, context LinkContext) error {
+	if tag.DataAtom != atom.Meta {
+		return nil
+	}
+	httpEquiv, _ := attrValue(tag, "http-equiv")
+	content, _ := attrValue(tag, "content")
+	if target, found := parseMetaRefresh(content); found && strings.EqualFold(strings.TrimSpace(httpEquiv), "refresh") {
+		return p.addLink(parentURL, page, target, Link{"", context})
+	}
+	return nil
+}
+
+// dataLinks returns the hrefs in the data-href (and similar) attributes of an element other than a <meta>
+// or <script>, which are links using the element's text
+func dataLinks(tag *html.Token) []string {
+	if tag.DataAtom == atom.Meta || tag.DataAtom == atom.Script {
+		return nil
+	}
+	var hrefs []string
+	for _, name := range dataLinkAttributes {
+		if href, found := attrValue(tag, name); found {
+			hrefs = append(hrefs, href)
+		}
+	}
+	return hrefs
+}
+
+// isDataLinkAttribute checks if an attribute name (as read by the tokenizer) is one of dataLinkAttributes
+func isDataLinkAttribute(key []byte) bool {
+	for _, name := range dataLinkAttributes {
+		if string(key) == name {
+			return true
+		}
+	}
+	return false
+}
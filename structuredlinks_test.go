@@ -0,0 +1,76 @@
+package sitemap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONLDLinks(t *testing.T) {
+
+	script := `{"@context": "https://schema.org", "@graph": [
+		{"@type": "WebSite", "@id": "https://test.com/#site", "name": "Test", "url": "https://test.com/"},
+		{"@type": "Product", "name": " Red  Shoe ", "image": "/shoe.png", "sameAs": ["/shoes/red", "https://other.com/red"],
+			"offers": {"@type": "Offer", "url": "/shoes/red/buy", "price": 10}}]}`
+	expected := "[{/shoes/red Red Shoe} {/shoes/red/buy } {https://other.com/red Red Shoe} {https://test.com/ Test} {https://test.com/#site Test}]"
+	if got := fmt.Sprint(parseJSONLDLinks(script)); got != expected {
+		t.Errorf("Incorrect JSON-LD links: expected %v, got %v", expected, got)
+	}
+	if links := parseJSONLDLinks(`{"url": `); links != nil {
+		t.Errorf("Incorrect links for invalid JSON: expected none, got %v", links)
+	}
+}
+
+func TestParseMetaRefresh(t *testing.T) {
+
+	tests := map[string]string{
+		"5; url=/next":       "/next",
+		"0;URL='/quoted'":    "/quoted",
+		"3, https://t.com/a": "https://t.com/a",
+		"0; /bare":           "/bare",
+		"30":                 "",
+		"0; url=":            "",
+	}
+	for content, expected := range tests {
+		if got, found := parseMetaRefresh(content); got != expected || found != (len(expected) != 0) {
+			t.Errorf("Incorrect refresh target for %q: expected %q, got %q (%v)", content, expected, got, found)
+		}
+	}
+}
+
+func TestParseDocumentStructuredLinks(t *testing.T) {
+
+	doc := `<html><head><meta http-equiv="refresh" content="10; url=/moved">
+		<script type="application/ld+json">{"@type": "ItemList", "itemListElement": [
+			{"@type": "ListItem", "name": "First", "url": "/items/1"}, {"@type": "ListItem", "url": "https://other.com/2"}]}</script>
+		</head><body><nav><div data-href="/cards/a">Card A</div></nav><table><tr data-url="/rows/b"><td>Row</td></tr></table>
+		<a href="/plain">Plain</a></body></html>`
+	parser := CreateDocumentParser()
+	parser.StructuredLinks = true
+	page, err := parser.ParseDocument("https://test.com/list", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	expected := map[string]Link{
+		"https://test.com/moved":   {"", LinkBody},
+		"https://test.com/items/1": {"First", LinkBody},
+		"https://test.com/cards/a": {"Card A", LinkNav},
+		"https://test.com/rows/b":  {"Row", LinkBody},
+		"https://test.com/plain":   {"Plain", LinkBody},
+	}
+	if len(page.InternalLinks) != len(expected) {
+		t.Errorf("Incorrect links: expected %v, got %v", sortedKeys(expected), sortedKeys(page.InternalLinks))
+	}
+	for urlStr, link := range expected {
+		if links := page.InternalLinks[urlStr]; len(links) != 1 || links[0] != link {
+			t.Errorf("Incorrect link to %s: expected [%v], got %v", urlStr, link, links)
+		}
+	}
+	if !page.ExternalLinks["https://other.com/2"] {
+		t.Errorf("Incorrect external links: expected https://other.com/2, got %v", page.ExternalLinks)
+	}
+
+	if page, _ = CreateDocumentParser().ParseDocument("https://test.com/list", strings.NewReader(doc)); len(page.InternalLinks) != 1 {
+		t.Errorf("Incorrect links when not requested: expected only the href, got %v", sortedKeys(page.InternalLinks))
+	}
+}
@@ -0,0 +1,59 @@
+package sitemap
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplatePage is a page passed to an output template, in the order of the traversal of the site map. Every
+// field of the WebPage can be used directly, e.g. {{.URL}} and {{.Title}}.
+type TemplatePage struct {
+	*WebPage
+	Depth   int      // depth of the page in the traversal (from the root page)
+	Inlinks []string // URLs of the other pages in the site map linking to the page, sorted
+}
+
+// TemplateData is the data an output template is executed with
+type TemplateData struct {
+	Site  *SiteMap       // the whole site map, e.g. {{.Site.Domain}} and {{.Site.Errors}}
+	Pages []TemplatePage // pages in the traversal order
+}
+
+// templateFuncs are the functions available to output templates, in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"join":   strings.Join,
+	"repeat": strings.Repeat,
+	"indent": func(depth int) string { return strings.Repeat("    ", depth) },
+}
+
+// LoadTemplate reads an output template (see TemplateRenderer) from a file, using the text/template syntax
+func LoadTemplate(fileName string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(fileName)).Funcs(templateFuncs).ParseFiles(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// TemplateRenderer renders a site map with a user supplied text/template, so reports can be laid out in
+// any form without a new output format. The template is executed once with TemplateData.
+type TemplateRenderer struct {
+	Template *template.Template
+	Order    TraversalOrder // order of the pages passed to the template
+}
+
+// Render executes the template for the site map
+func (renderer TemplateRenderer) Render(w io.Writer, site *SiteMap) error {
+	mapChan := make(chan MapTraversalNode, 20)
+	go site.TraverseSiteMapOrdered(mapChan, renderer.Order)
+	inlinks := site.Inlinks()
+	data := TemplateData{Site: site}
+	for node := range mapChan {
+		key := site.lookupKey(node.Page.URL.String())
+		data.Pages = append(data.Pages, TemplatePage{node.Page, node.Depth, inlinks[key]})
+	}
+	return renderer.Template.Execute(w, data)
+}
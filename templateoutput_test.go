@@ -0,0 +1,75 @@
+package sitemap
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateRenderer(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "http://www.example.com"))
+	root := createWebPage(t, "http://www.example.com", "Home")
+	root.AddLink("http://www.example.com/about", Link{"About", LinkNav})
+	root.AddLink("http://www.example.com/blog", Link{"Blog", LinkNav})
+	about := createWebPage(t, "http://www.example.com/about", "About")
+	about.AddLink("http://www.example.com/blog", Link{"Blog", LinkBody})
+	blog := createWebPage(t, "http://www.example.com/blog", "Blog")
+	for _, page := range []*WebPage{root, about, blog} {
+		site.AddPage(page)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "report.tmpl")
+	tmpl := `# {{.Site.Domain}}
+{{range .Pages}}{{indent .Depth}}- {{.Title}} {{.URL}} [{{join .Inlinks ", "}}]
+{{end}}`
+	if err := os.WriteFile(fileName, []byte(tmpl), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	parsed, err := LoadTemplate(fileName)
+	if err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+
+	tests := []struct {
+		order    TraversalOrder
+		expected string
+	}{
+		{OrderDFS, `# www.example.com
+- Home http://www.example.com []
+    - About http://www.example.com/about [http://www.example.com]
+    - Blog http://www.example.com/blog [http://www.example.com, http://www.example.com/about]
+`},
+		{OrderInlinks, `# www.example.com
+    - Blog http://www.example.com/blog [http://www.example.com, http://www.example.com/about]
+    - About http://www.example.com/about [http://www.example.com]
+- Home http://www.example.com []
+`},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := (TemplateRenderer{parsed, test.order}).Render(&buf, site); err != nil {
+			t.Fatalf("Failed to render template: %v", err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("Incorrect output for order %v: expected %q, got %q", test.order, test.expected, buf.String())
+		}
+	}
+
+	if err := os.WriteFile(fileName, []byte(`{{range .Pages}}`), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if _, err := LoadTemplate(fileName); err == nil {
+		t.Errorf("Incorrect result loading an invalid template: expected an error")
+	}
+	if err := os.WriteFile(fileName, []byte(`{{.Missing}}`), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if parsed, err = LoadTemplate(fileName); err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+	if err := (TemplateRenderer{parsed, OrderDFS}).Render(&bytes.Buffer{}, site); err == nil {
+		t.Errorf("Incorrect result rendering an unknown field: expected an error")
+	}
+}
@@ -0,0 +1,785 @@
+package sitemap
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Versions of the plain text site map format written by PrintSite. Version 1 is the original layout and is
+// never changed so existing scripts parsing it keep working; new columns are only added in later versions.
+const (
+	TextVersion1 = 1 // indent URL [Title] (aliases: ...) (alternates: ...)
+	TextVersion2 = 2 // indent depth status URL [Title] (aliases: ...) (alternates: ...)
+)
+
+// TextOptions controls how the plain text site map is written by PrintSite
+type TextOptions struct {
+	Version  int            // text format version (TextVersion1 or TextVersion2)
+	Order    TraversalOrder // order pages are written in
+	Messages *Catalog       // catalog for the language headings are written in (nil for English)
+}
+
+// PrintSite writes the SiteMap contents to the supplied writer (a file or the console), using the
+// supplied text options. Pages are only indented to show the link structure for the depth first order.
+func PrintSite(w io.Writer, domain string, site *SiteMap, opts TextOptions) error {
+	version, messages := opts.Version, opts.Messages
+
+	// create a channel for the site map contents and a goroutine to populate it
+	mapChan := make(chan MapTraversalNode, 20)
+	go site.TraverseSiteMapOrdered(mapChan, opts.Order)
+	defer func() {
+		for range mapChan {
+			// drain the channel on error so the traversal completes
+		}
+	}()
+
+	// Write out the results
+	truncated := ""
+	if site.Truncated {
+		truncated = messages.Sprintf("site.truncated")
+	}
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("site.header", domain, truncated)); err != nil {
+		return err
+	}
+	if version >= TextVersion2 {
+		if _, err := fmt.Fprintf(w, "# text format %d: depth status url [title] details\n", version); err != nil {
+			return err
+		}
+	}
+	for page := range mapChan {
+		details := ""
+		if len(page.Page.Aliases) != 0 {
+			sorted := make([]string, 0, len(page.Page.Aliases))
+			for alias := range page.Page.Aliases {
+				sorted = append(sorted, alias)
+			}
+			sort.Strings(sorted)
+			details = messages.Sprintf("page.aliases", strings.Join(sorted, ", "))
+		}
+		if len(page.Page.Alternates) != 0 {
+			details += messages.Sprintf("page.alternates", strings.Join(page.Page.Alternates, ", "))
+		}
+		indent := ""
+		if opts.Order == OrderDFS {
+			indent = strings.Repeat("    ", page.Depth)
+		}
+		var err error
+		if version >= TextVersion2 {
+			status := "-"
+			if page.Page.StatusCode != 0 {
+				status = strconv.Itoa(page.Page.StatusCode)
+			}
+			_, err = fmt.Fprintf(w, "%s%d %s %s [%s]%s\n", indent, page.Depth, status, page.Page.URL, page.Page.Title, details)
+		} else {
+			_, err = fmt.Fprintf(w, "%s %s [%s]%s\n", indent, page.Page.URL, page.Page.Title, details)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintPages writes a list of selected pages to the supplied writer, with headings in the language of the
+// supplied catalog (nil for English)
+func PrintPages(w io.Writer, pages []*WebPage, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("select.header", len(pages))); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if _, err := fmt.Fprintf(w, " %s [%s]\n", page.URL, page.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintSitemapCoverage writes the report comparing the site map with the site's sitemap.xml to the
+// supplied writer, with headings in the language of the supplied catalog (nil for English)
+func PrintSitemapCoverage(w io.Writer, coverage SitemapCoverage, messages *Catalog) error {
+	sections := []struct {
+		heading string
+		urls    []string
+	}{
+		{messages.Sprintf("coverage.orphans", len(coverage.Orphans), coverage.Listed), coverage.Orphans},
+		{messages.Sprintf("coverage.unlisted", len(coverage.Unlisted)), coverage.Unlisted},
+	}
+	for _, section := range sections {
+		if _, err := fmt.Fprintf(w, "\n\n %s\n", section.heading); err != nil {
+			return err
+		}
+		for _, urlStr := range section.urls {
+			if _, err := fmt.Fprintf(w, " %s\n", urlStr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintDuplicateContent writes the report of groups of pages with identical or near identical content to the
+// supplied writer, with headings in the language of the supplied catalog (nil for English)
+func PrintDuplicateContent(w io.Writer, groups []DuplicateGroup, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("duplicates.header", len(groups))); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		kind := messages.Sprintf("duplicates.similar")
+		if group.Identical {
+			kind = messages.Sprintf("duplicates.identical")
+		}
+		if _, err := fmt.Fprintf(w, " %s:\n     %s\n", kind, strings.Join(group.URLs, "\n     ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintDepthStats writes the depth statistics report to the supplied writer, with headings in the language of
+// the supplied catalog (nil for English)
+func PrintDepthStats(w io.Writer, stats DepthStats, messages *Catalog) error {
+	lines := []string{
+		"\n\n " + messages.Sprintf("depth.header"),
+		" " + messages.Sprintf("depth.reachable", stats.Reachable, stats.Unreachable),
+		" " + messages.Sprintf("depth.distance", stats.AverageDepth, stats.MaxDepth),
+		" " + messages.Sprintf("depth.leaves", stats.LeafPages),
+		" " + messages.Sprintf("depth.perdepth"),
+	}
+	for depth, count := range stats.PagesByDepth {
+		lines = append(lines, fmt.Sprintf("     %3d: %d", depth, count))
+	}
+	lines = append(lines, " "+messages.Sprintf("depth.deep", stats.Threshold, len(stats.DeepPages)))
+	for _, page := range stats.DeepPages {
+		lines = append(lines, "     "+page)
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// PrintLinkPopularity writes the report of the most and least linked to pages (each limited to count pages)
+// to the supplied writer, with headings in the language of the supplied catalog (nil for English). The
+// pages are as returned by SiteMap.LinkPopularity, with the most linked to first.
+func PrintLinkPopularity(w io.Writer, pages []LinkPopularity, count int, messages *Catalog) error {
+	count = min(count, len(pages))
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("inlinks.most", count, len(pages))); err != nil {
+		return err
+	}
+	for _, page := range pages[:count] {
+		if _, err := fmt.Fprintf(w, " %6d  %s\n", page.Inlinks, page.URL); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("inlinks.least", count, len(pages))); err != nil {
+		return err
+	}
+	least := append([]LinkPopularity(nil), pages[len(pages)-count:]...)
+	sort.SliceStable(least, func(i, j int) bool { return least[i].Inlinks < least[j].Inlinks })
+	for _, page := range least {
+		if _, err := fmt.Fprintf(w, " %6d  %s\n", page.Inlinks, page.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintAuthRequired writes the report of URLs redirecting to a login page to the supplied writer, grouped by
+// login page, with headings in the language of the supplied catalog (nil for English)
+func PrintAuthRequired(w io.Writer, authRequired []*AuthRequiredError, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("auth.header", len(authRequired))); err != nil {
+		return err
+	}
+	byLogin := make(map[string][]string)
+	for _, authErr := range authRequired {
+		login := authErr.LoginURL
+		if u, err := url.Parse(login); err == nil {
+			u.RawQuery = "" // login pages usually include the URL to return to
+			login = u.String()
+		}
+		byLogin[login] = append(byLogin[login], authErr.URL)
+	}
+	for _, login := range sortedKeys(byLogin) {
+		if _, err := fmt.Fprintf(w, " %s:\n", login); err != nil {
+			return err
+		}
+		for _, urlStr := range byLogin[login] {
+			if _, err := fmt.Fprintf(w, "     %s\n", urlStr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintLoadErrors writes the URLs which failed to load, with why and the pages linking to them, to the supplied
+// writer with headings in the language of the supplied catalog (nil for English)
+func PrintLoadErrors(w io.Writer, failures []LoadFailure, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("loaderrors.header", len(failures))); err != nil {
+		return err
+	}
+	for _, failure := range failures {
+		details := fmt.Sprintf("%v (%s)", failure.Class, failure.Error)
+		if failure.Class == LoadErrorStatus {
+			details = messages.Sprintf("loaderrors.status", failure.StatusCode)
+		}
+		if failure.Attempts > 1 {
+			details += ", " + messages.Sprintf("loaderrors.attempts", failure.Attempts)
+		}
+		if len(failure.Referrers) != 0 {
+			details += ", " + messages.Sprintf("loaderrors.referrers", strings.Join(failure.Referrers, ", "))
+		}
+		if _, err := fmt.Fprintf(w, " %s: %s\n", failure.URL, details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintBoundaryLinks writes the links out of the section of the site crawled (see WithRootPath), with the pages
+// linking to them, to the supplied writer, with headings in the language of the supplied catalog (nil for English)
+func PrintBoundaryLinks(w io.Writer, links []BoundaryLink, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("boundary.header", len(links))); err != nil {
+		return err
+	}
+	for _, link := range links {
+		if _, err := fmt.Fprintf(w, " %s: %s\n", link.URL, messages.Sprintf("loaderrors.referrers", strings.Join(link.Referrers, ", "))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintRedirectLoops writes the report of URLs in redirect loops to the supplied writer, with headings in the
+// language of the supplied catalog (nil for English)
+func PrintRedirectLoops(w io.Writer, loops []*RedirectLoopError, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("redirects.header", len(loops))); err != nil {
+		return err
+	}
+	for _, loop := range loops {
+		if _, err := fmt.Fprintf(w, " %s:\n     %s\n", loop.URL, strings.Join(loop.Cycle, " -> ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintCacheAudit writes the report of pages with problems in their caching headers, grouped by path prefix,
+// to the supplied writer, with headings and problems in the language of the supplied catalog (nil for English)
+func PrintCacheAudit(w io.Writer, groups []CacheGroup, messages *Catalog) error {
+	count := 0
+	for _, group := range groups {
+		count += len(group.Issues)
+	}
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("cache.header", count)); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if _, err := fmt.Fprintf(w, " %s (%d):\n", group.Prefix, len(group.Issues)); err != nil {
+			return err
+		}
+		for _, issue := range group.Issues {
+			line := fmt.Sprintf("     %s: %s", messages.Sprintf(issue.Problem.messageKey()), issue.URL)
+			if len(issue.Detail) != 0 {
+				line += " (" + issue.Detail + ")"
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintAssertionViolations writes the report of pages failing assertions to the supplied writer, with
+// headings in the language of the supplied catalog (nil for English)
+func PrintAssertionViolations(w io.Writer, violations []AssertionViolation, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("assertions.header", len(violations))); err != nil {
+		return err
+	}
+	for i, violation := range violations {
+		if i == 0 || violation.Assertion != violations[i-1].Assertion {
+			if _, err := fmt.Fprintf(w, " %s:\n", violation.Assertion); err != nil {
+				return err
+			}
+		}
+		detail := messages.Sprintf("assertions.missing", violation.Pattern)
+		if len(violation.Link) != 0 {
+			detail = messages.Sprintf("assertions.link", violation.Link)
+		}
+		if _, err := fmt.Fprintf(w, "     %s (%s)\n", violation.URL, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintAuditFindings writes the audit findings (sorted by severity) to the supplied writer, along with the
+// number of findings suppressed and already known (recorded in the baseline), with headings and checks in
+// the language of the supplied catalog (nil for English)
+func PrintAuditFindings(w io.Writer, findings []Finding, suppressed int, known int, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("audit.header", len(findings), suppressed)); err != nil {
+		return err
+	}
+	if known != 0 {
+		if _, err := fmt.Fprintf(w, " %s\n", messages.Sprintf("audit.known", known)); err != nil {
+			return err
+		}
+	}
+	for i, finding := range findings {
+		if i == 0 || finding.Severity != findings[i-1].Severity {
+			if _, err := fmt.Fprintf(w, " %s:\n", messages.Sprintf("severity."+finding.Severity.String())); err != nil {
+				return err
+			}
+		}
+		message := messages.Sprintf(finding.Check)
+		if name, isAssertion := strings.CutPrefix(finding.Check, "assertion."); isAssertion {
+			message = messages.Sprintf("assertions.failed", name)
+		}
+		line := fmt.Sprintf("     %s: %s", message, finding.URL)
+		if len(finding.Detail) != 0 {
+			line += " (" + finding.Detail + ")"
+		}
+		if _, err := fmt.Fprintf(w, "%s [%s]\n", line, finding.Check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintAssets writes the static assets used by each page to the supplied writer, followed by any broken assets
+// and the pages using them, then any broken or redirected resource hints, with headings in the language of
+// the supplied catalog (nil for English)
+func PrintAssets(w io.Writer, site *SiteMap, messages *Catalog) error {
+	usage := site.AssetUsage()
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("assets.header", len(usage))); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(site.Pages) {
+		page := site.Pages[key]
+		if len(page.Assets) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, " %s:\n", page.URL); err != nil {
+			return err
+		}
+		for _, asset := range sortedKeys(page.Assets) {
+			if _, err := fmt.Fprintf(w, "     %s%s\n", asset, assetStatus(page.Assets[asset], messages)); err != nil {
+				return err
+			}
+		}
+	}
+
+	var broken []AssetUsage
+	for _, asset := range usage {
+		if asset.Broken() {
+			broken = append(broken, asset)
+		}
+	}
+	if len(broken) != 0 {
+		if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("assets.broken", len(broken))); err != nil {
+			return err
+		}
+	}
+	for _, asset := range broken {
+		if _, err := fmt.Fprintf(w, " %s%s:\n", asset.URL, assetStatus(asset.Status, messages)); err != nil {
+			return err
+		}
+		for _, page := range asset.Pages {
+			if _, err := fmt.Fprintf(w, "     %s\n", page); err != nil {
+				return err
+			}
+		}
+	}
+
+	hints := site.HintIssues()
+	if len(hints) != 0 {
+		if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("hints.header", len(hints))); err != nil {
+			return err
+		}
+	}
+	for i, hint := range hints {
+		if i == 0 || hint.Page != hints[i-1].Page {
+			if _, err := fmt.Fprintf(w, " %s:\n", hint.Page); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "     %s %s%s\n", hint.Rel, hint.URL, assetStatus(hint.Status, messages)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assetStatus returns the status of an asset to show after its URL (empty if it wasn't checked)
+func assetStatus(status int, messages *Catalog) string {
+	switch status {
+	case 0:
+		return ""
+	case AssetFailed:
+		return " (" + messages.Sprintf("assets.failed") + ")"
+	}
+	return fmt.Sprintf(" (%d)", status)
+}
+
+// PrintIconAudit writes the results of checking the site's favicon, icons and web app manifests to the supplied
+// writer, with headings and problems in the language of the supplied catalog (nil for English)
+func PrintIconAudit(w io.Writer, audit *IconAudit, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("icons.header")); err != nil {
+		return err
+	}
+	favicon := messages.Sprintf("icons.valid")
+	if !audit.FaviconValid() {
+		favicon = messages.Sprintf("icons.favicon")
+	}
+	if _, err := fmt.Fprintf(w, " %s (%s): %s\n", audit.Favicon, faviconDetail(audit), favicon); err != nil {
+		return err
+	}
+	for _, icon := range audit.Icons {
+		if !icon.Broken() {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, " %s: %s%s:\n", messages.Sprintf("icons.missing"), icon.URL, assetStatus(icon.Status, messages)); err != nil {
+			return err
+		}
+		for _, page := range icon.Pages {
+			if _, err := fmt.Fprintf(w, "     %s\n", page); err != nil {
+				return err
+			}
+		}
+	}
+	for _, manifest := range audit.Manifests {
+		if _, err := fmt.Fprintf(w, " %s%s:\n", manifest.URL, assetStatus(manifest.Status, messages)); err != nil {
+			return err
+		}
+		if len(manifest.Problems) == 0 {
+			if _, err := fmt.Fprintf(w, "     %s\n", messages.Sprintf("icons.valid")); err != nil {
+				return err
+			}
+		}
+		for i, problem := range manifest.Problems {
+			line := "     " + messages.Sprintf(problem.messageKey())
+			if len(manifest.Detail[i]) != 0 {
+				line += " (" + manifest.Detail[i] + ")"
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	for _, missing := range []struct {
+		key   string
+		pages []string
+	}{{"icons.none", audit.NoIcon}, {"manifest.page", audit.NoManifest}} {
+		if len(missing.pages) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, " %s (%d):\n", messages.Sprintf(missing.key), len(missing.pages)); err != nil {
+			return err
+		}
+		for _, page := range missing.pages {
+			if _, err := fmt.Fprintf(w, "     %s\n", page); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintLanguageGroups writes the groups of pages which are language variants of each other to the supplied
+// writer, with headings in the language of the supplied catalog (nil for English)
+func PrintLanguageGroups(w io.Writer, groups [][]LanguageVariant, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("languages.header", len(groups))); err != nil {
+		return err
+	}
+	for i, group := range groups {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		for _, variant := range group {
+			if _, err := fmt.Fprintf(w, " %-10s %s\n", variant.Lang, variant.URL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintSoft404Pages writes how the site responds to missing pages (nil if not known), followed by the pages
+// which look like its not found page, to the supplied writer with headings in the language of the supplied
+// catalog (nil for English)
+func PrintSoft404Pages(w io.Writer, notFound *NotFoundSignature, pages []*WebPage, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("soft404.header", len(pages))); err != nil {
+		return err
+	}
+	if notFound != nil {
+		line := messages.Sprintf("soft404.probe", notFound.StatusCode, notFound.URL)
+		if len(notFound.RedirectURL) != 0 {
+			line += " -> " + notFound.RedirectURL
+		}
+		if _, err := fmt.Fprintf(w, " %s\n", line); err != nil {
+			return err
+		}
+	}
+	for _, page := range pages {
+		if _, err := fmt.Fprintf(w, " %s [%s]\n", page.URL, page.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintVaryAudit writes the report of pages whose responses vary unexpectedly to the supplied writer, with
+// headings and problems in the language of the supplied catalog (nil for English)
+func PrintVaryAudit(w io.Writer, issues []VaryIssue, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("vary.header", len(issues))); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		line := fmt.Sprintf(" %s: %s (%s)", messages.Sprintf(issue.Problem.messageKey()), issue.URL, issue.Detail)
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintProtocolUsage writes the report of the HTTP protocols and TLS versions pages were loaded with to the
+// supplied writer, with headings in the language of the supplied catalog (nil for English). The groups are as
+// returned by SiteMap.ProtocolUsage, with the pages listed for all but the most common group.
+func PrintProtocolUsage(w io.Writer, groups []ProtocolGroup, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("protocols.header", len(groups))); err != nil {
+		return err
+	}
+	for i, group := range groups {
+		protocol, tlsVersion := group.Protocol, group.TLSVersion
+		if len(protocol) == 0 {
+			protocol = messages.Sprintf("protocols.unknown")
+		}
+		if len(tlsVersion) == 0 {
+			tlsVersion = messages.Sprintf("protocols.notls")
+		}
+		if _, err := fmt.Fprintf(w, " %6d  %s, %s\n", len(group.URLs), protocol, tlsVersion); err != nil {
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		for _, urlStr := range group.URLs {
+			if _, err := fmt.Fprintf(w, "         %s\n", urlStr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintDirectoryChecks writes the report of the site's directories to the supplied writer, listing those
+// exposing listings of their files or returning errors, with headings in the language of the supplied catalog
+// (nil for English)
+func PrintDirectoryChecks(w io.Writer, checks []DirectoryCheck, messages *Catalog) error {
+	var indexes int
+	var listings, failed []DirectoryCheck
+	for _, check := range checks {
+		switch {
+		case check.HasIndex():
+			indexes++
+		case check.Listing:
+			listings = append(listings, check)
+		case check.Failed():
+			failed = append(failed, check)
+		}
+	}
+	lines := []string{
+		"\n\n " + messages.Sprintf("directories.header", len(checks), indexes),
+		" " + messages.Sprintf("directories.listings", len(listings)),
+	}
+	for _, check := range listings {
+		lines = append(lines, "     "+check.URL)
+	}
+	lines = append(lines, " "+messages.Sprintf("directories.errors", len(failed)))
+	for _, check := range failed {
+		status := strconv.Itoa(check.Status)
+		if check.Status == AssetFailed {
+			status = messages.Sprintf("directories.failed")
+		}
+		lines = append(lines, fmt.Sprintf("     %s (%s)", check.URL, status))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// PrintBreadcrumbIssues writes the report of pages whose declared breadcrumb trail is inconsistent with the
+// site structure to the supplied writer, with headings and problems in the language of the supplied catalog
+// (nil for English)
+func PrintBreadcrumbIssues(w io.Writer, issues []BreadcrumbIssue, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("breadcrumbs.header", len(issues))); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		problems := make([]string, 0, len(issue.Problems))
+		for _, problem := range issue.Problems {
+			switch problem {
+			case BreadcrumbDepth:
+				problems = append(problems, messages.Sprintf(problem.messageKey(), issue.Declared, issue.Depth))
+			case BreadcrumbUnknown, BreadcrumbNoLink:
+				problems = append(problems, messages.Sprintf(problem.messageKey(), issue.Detail))
+			default:
+				problems = append(problems, messages.Sprintf(problem.messageKey()))
+			}
+		}
+		if _, err := fmt.Fprintf(w, " %s: %s\n", issue.URL, strings.Join(problems, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintMetaIssues writes the report of pages with problems with their title or meta description to the
+// supplied writer, listing the pages with each problem, with headings and problems in the language of the
+// supplied catalog (nil for English)
+func PrintMetaIssues(w io.Writer, issues []MetaIssue, messages *Catalog) error {
+	lines := []string{"\n\n " + messages.Sprintf("meta.header", len(issues))}
+	for _, issue := range issues {
+		var problem string
+		switch issue.Problem {
+		case TitleMissing, DescriptionMissing:
+			problem = messages.Sprintf(issue.Problem.messageKey())
+		case TitleTooLong:
+			problem = messages.Sprintf(issue.Problem.messageKey(), MaxTitleLength, issue.Text)
+		case DescriptionTooLong:
+			problem = messages.Sprintf(issue.Problem.messageKey(), MaxDescriptionLength, issue.Text)
+		default:
+			problem = messages.Sprintf(issue.Problem.messageKey(), issue.Text)
+		}
+		lines = append(lines, fmt.Sprintf(" %s (%s)", problem, messages.Sprintf("meta.pages", len(issue.Pages))))
+		for _, page := range issue.Pages {
+			lines = append(lines, "     "+page)
+		}
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// PrintHeadingIssues writes the report of pages with no H1 heading or more than one to the supplied writer,
+// with headings and problems in the language of the supplied catalog (nil for English)
+func PrintHeadingIssues(w io.Writer, issues []HeadingIssue, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("headings.header", len(issues))); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		problem := messages.Sprintf(issue.Problem.messageKey())
+		if issue.Problem == HeadingMultiple {
+			quoted := make([]string, len(issue.Headings))
+			for i, heading := range issue.Headings {
+				quoted[i] = strconv.Quote(heading)
+			}
+			problem = messages.Sprintf(issue.Problem.messageKey(), len(issue.Headings), strings.Join(quoted, ", "))
+		}
+		if _, err := fmt.Fprintf(w, " %s: %s\n", issue.URL, problem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintCanonicalIssues writes the report of pages with problems with their declared canonical URL to the
+// supplied writer, with headings and problems in the language of the supplied catalog (nil for English)
+func PrintCanonicalIssues(w io.Writer, issues []CanonicalIssue, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("canonical.header", len(issues))); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		problem := messages.Sprintf(issue.Problem.messageKey())
+		if issue.Problem != CanonicalOtherPage {
+			problem = messages.Sprintf(issue.Problem.messageKey(), issue.Detail)
+		}
+		if _, err := fmt.Fprintf(w, " %s -> %s (%s)\n", issue.URL, issue.Canonical, problem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintSchemaTypes writes the report of the structured data types used by the site's pages to the supplied
+// writer, followed by the pages missing types expected by the rules if any were checked, with headings in the
+// language of the supplied catalog (nil for English)
+func PrintSchemaTypes(w io.Writer, types []SchemaTypeUsage, issues []SchemaIssue, checked bool, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("schema.header", len(types))); err != nil {
+		return err
+	}
+	for _, usage := range types {
+		if _, err := fmt.Fprintf(w, " %s (%s)\n", usage.Type, messages.Sprintf("schema.pages", usage.Pages)); err != nil {
+			return err
+		}
+	}
+	if !checked {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "\n %s\n", messages.Sprintf("schema.missing.header", len(issues))); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		missing := messages.Sprintf("schema.missing", strings.Join(issue.Missing, ", "))
+		if _, err := fmt.Fprintf(w, " %s: %s\n", issue.URL, missing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintFeeds writes the report of the RSS and Atom feeds declared by the site's pages to the supplied writer,
+// with headings in the language of the supplied catalog (nil for English)
+func PrintFeeds(w io.Writer, feeds []FeedUsage, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("feeds.header", len(feeds))); err != nil {
+		return err
+	}
+	for _, feed := range feeds {
+		if _, err := fmt.Fprintf(w, " %s (%s)\n", feed.URL, messages.Sprintf("feeds.section", feed.Section, len(feed.Pages))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintHrefIssues writes the report of pages linking with hrefs not in their canonical encoding to the supplied
+// writer, with headings and problems in the language of the supplied catalog (nil for English)
+func PrintHrefIssues(w io.Writer, pages []PageHrefIssues, messages *Catalog) error {
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("encoding.header", len(pages))); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if _, err := fmt.Fprintf(w, " %s:\n", page.URL); err != nil {
+			return err
+		}
+		for _, issue := range page.Issues {
+			problems := make([]string, 0, len(issue.Problems))
+			for _, problem := range issue.Problems {
+				problems = append(problems, messages.Sprintf(problem.messageKey()))
+			}
+			if _, err := fmt.Fprintf(w, "     %s -> %s (%s)\n", issue.Href, issue.Canonical, strings.Join(problems, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PrintQueryDuplicates writes the report of URLs which return identical content with and without their query
+// string to the supplied writer, with headings in the language of the supplied catalog (nil for English)
+func PrintQueryDuplicates(w io.Writer, site *SiteMap, messages *Catalog) error {
+	duplicates := site.QueryDuplicates()
+	if _, err := fmt.Fprintf(w, "\n\n %s\n", messages.Sprintf("query.header", len(duplicates))); err != nil {
+		return err
+	}
+	for _, pair := range duplicates {
+		if _, err := fmt.Fprintf(w, " %s -> %s\n", pair.URL, pair.Canonical); err != nil {
+			return err
+		}
+	}
+	return nil
+}
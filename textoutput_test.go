@@ -0,0 +1,69 @@
+package sitemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Test the plain text output for each format version
+func TestPrintSite(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	root := createWebPage(t, "https://test.com", "Home")
+	root.AddLink("https://test.com/a", Link{})
+	root.StatusCode = 200
+	if _, err := site.AddPage(root); err != nil {
+		t.Fatal(err)
+	}
+	addPage(t, site, true, "https://test.com/a", "A")
+
+	tests := []struct {
+		version  int
+		expected []string
+	}{
+		{TextVersion1, []string{
+			" ----- Site Map for website  https://test.com -----",
+			" https://test.com [Home]",
+			"     https://test.com/a [A]",
+		}},
+		{TextVersion2, []string{
+			" ----- Site Map for website  https://test.com -----",
+			"# text format 2: depth status url [title] details",
+			"0 200 https://test.com [Home]",
+			"    1 - https://test.com/a [A]",
+		}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := PrintSite(&buf, "https://test.com", site, TextOptions{Version: test.version}); err != nil {
+			t.Fatalf("Failed to print site: %v", err)
+		}
+		got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		expected := strings.Join(test.expected, "\n")
+		if strings.Join(got, "\n") != strings.TrimSpace(expected) {
+			t.Errorf("Incorrect text output for version %d: expected\n%s\ngot\n%s", test.version, expected, buf.String())
+		}
+	}
+}
+
+func TestPrintLinkPopularity(t *testing.T) {
+	pages := []LinkPopularity{
+		{"https://test.com/a", 5},
+		{"https://test.com/b", 2},
+		{"https://test.com/c", 0},
+		{"https://test.com/d", 0},
+	}
+	var buf bytes.Buffer
+	if err := PrintLinkPopularity(&buf, pages, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\n\n ----- Most linked to pages (2 of 4) -----\n" +
+		"      5  https://test.com/a\n" +
+		"      2  https://test.com/b\n" +
+		"\n\n ----- Least linked to pages (2 of 4) -----\n" +
+		"      0  https://test.com/c\n" +
+		"      0  https://test.com/d\n"
+	if buf.String() != expected {
+		t.Errorf("Incorrect link popularity report: expected %q, got %q", expected, buf.String())
+	}
+}
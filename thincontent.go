@@ -0,0 +1,35 @@
+package sitemap
+
+import (
+	"bytes"
+	"sort"
+	"unicode"
+)
+
+// ThinPages returns the pages with fewer than a number of words of visible text, sorted by URL. Soft 404 pages
+// are not included, and nothing is returned unless the word counts of pages were recorded (minWords > 0).
+func (site *SiteMap) ThinPages(minWords int) []*WebPage {
+	if minWords <= 0 {
+		return nil
+	}
+	var pages []*WebPage
+	for _, page := range site.Pages {
+		if !page.Soft404 && page.WordCount < minWords {
+			pages = append(pages, page)
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL.String() < pages[j].URL.String() })
+	return pages
+}
+
+// countWords returns the number of words in text, where a word is a run of non-space characters including a
+// letter or digit (so punctuation on its own isn't counted)
+func countWords(text []byte) int {
+	count := 0
+	for _, field := range bytes.Fields(text) {
+		if bytes.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			count++
+		}
+	}
+	return count
+}
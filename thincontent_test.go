@@ -0,0 +1,67 @@
+package sitemap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWordCount(t *testing.T) {
+
+	const doc = `<html><head><title>Not counted</title><style>body { color: red }</style></head>
+<body><h1>Three word heading</h1>
+<p>Some <b>bold</b> text, and a <a href="/a">link</a>.</p>
+<script>var notCounted = "words in a script";</script>
+<noscript>Not counted either</noscript>
+<img src="/a.png" alt="not counted">
+</body></html>`
+	tests := []struct {
+		wordCount bool
+		expected  int
+	}{
+		{false, 0},
+		{true, 9},
+	}
+	for _, test := range tests {
+		parser := CreateDocumentParser()
+		parser.WordCount = test.wordCount
+		page, err := parser.ParseDocument("https://test.com", strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("Failed to parse document: %v", err)
+		}
+		if page.WordCount != test.expected {
+			t.Errorf("Incorrect word count: expected %v, got %v", test.expected, page.WordCount)
+		}
+	}
+}
+
+func TestThinPages(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	for urlStr, words := range map[string]int{
+		"https://test.com":         500,
+		"https://test.com/a":       99,
+		"https://test.com/b":       0,
+		"https://test.com/c":       100,
+		"https://test.com/missing": 10,
+	} {
+		page := createWebPage(t, urlStr, "")
+		page.WordCount = words
+		page.Soft404 = urlStr == "https://test.com/missing"
+		site.AddPage(page)
+	}
+	if pages := site.ThinPages(0); pages != nil {
+		t.Errorf("Incorrect thin pages with no threshold: expected none, got %v", pages)
+	}
+
+	findings := make(map[string]string)
+	for _, finding := range CollectFindings(site, AuditInputs{MinWords: 100}) {
+		if finding.Check == "content.thin" {
+			findings[finding.URL] = finding.Detail
+		}
+	}
+	expected := map[string]string{"https://test.com/a": "99", "https://test.com/b": "0"}
+	if !reflect.DeepEqual(findings, expected) {
+		t.Errorf("Incorrect thin content findings: expected %v, got %v", expected, findings)
+	}
+}
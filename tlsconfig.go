@@ -0,0 +1,50 @@
+package sitemap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configures how the TLS connections pages are loaded over are set up, for sites which can't be
+// loaded with the default configuration (e.g. internal or staging hosts)
+type TLSOptions struct {
+	CAFile             string // PEM file of CA certificates trusted as well as the system's (empty for none)
+	CertFile           string // PEM file of the client certificate presented to the server (empty for none)
+	KeyFile            string // PEM file of the private key of the client certificate
+	InsecureSkipVerify bool   // set to accept any server certificate, e.g. a self-signed one (insecure)
+}
+
+// Config returns the TLS configuration for the options, nil if they are all unset so the default is used
+func (opts TLSOptions) Config() (*tls.Config, error) {
+	if opts == (TLSOptions{}) {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if len(opts.CAFile) != 0 {
+		data, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool() // not available on this platform, so only the CA file is trusted
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in CA file %s", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if len(opts.CertFile) != 0 || len(opts.KeyFile) != 0 {
+		if len(opts.CertFile) == 0 || len(opts.KeyFile) == 0 {
+			return nil, fmt.Errorf("a client certificate requires both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,122 @@
+package sitemap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// createTLSTestSite creates a mock HTTPS server with a self-signed certificate hosting a single page
+func createTLSTestSite() *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(rw, "<html><head><title>Staging</title></head></html>")
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	return server
+}
+
+// loadWithTLSOptions loads the root page of a server with a loader using the TLS options
+func loadWithTLSOptions(t *testing.T, server *httptest.Server, opts TLSOptions) (*WebPage, error) {
+	config, err := opts.Config()
+	if err != nil {
+		t.Fatalf("Invalid TLS options: %v", err)
+	}
+	loader := CreateDocumentLoader(CreateDocumentParser())
+	loader.Client = &http.Client{Transport: CreateTransport(TransportOptions{TLS: config})}
+	return loader.LoadURL(server.URL)
+}
+
+// writePEM writes a PEM block to a file in the test's temporary directory, returning its name
+func writePEM(t *testing.T, name string, blockType string, data []byte) string {
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestTLSOptions(t *testing.T) {
+
+	server := createTLSTestSite()
+	defer server.Close()
+
+	if _, err := loadWithTLSOptions(t, server, TLSOptions{}); err == nil {
+		t.Errorf("Incorrect result for self-signed certificate: expected an error, got none")
+	}
+	if page, err := loadWithTLSOptions(t, server, TLSOptions{InsecureSkipVerify: true}); err != nil || page.Title != "Staging" {
+		t.Errorf("Incorrect result skipping verification: expected the page, got %v (%v)", page, err)
+	}
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	if page, err := loadWithTLSOptions(t, server, TLSOptions{CAFile: caFile}); err != nil || page.Title != "Staging" {
+		t.Errorf("Incorrect result trusting the server's CA: expected the page, got %v (%v)", page, err)
+	}
+
+	if _, err := (TLSOptions{CAFile: writePEM(t, "empty.pem", "NOTHING", nil)}).Config(); err == nil {
+		t.Errorf("Incorrect result for CA file without certificates: expected an error, got none")
+	}
+	if _, err := (TLSOptions{CertFile: caFile}).Config(); err == nil {
+		t.Errorf("Incorrect result for client certificate without a key: expected an error, got none")
+	}
+	if config, err := (TLSOptions{}).Config(); config != nil || err != nil {
+		t.Errorf("Incorrect config for no options: expected nil, got %v (%v)", config, err)
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+
+	var presented []string
+	server := createTLSTestSite()
+	server.Config.Handler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		for _, cert := range req.TLS.PeerCertificates {
+			presented = append(presented, cert.Subject.CommonName)
+		}
+		rw.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(rw, "<html><head><title>Staging</title></head></html>")
+	})
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "crawler"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyData, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := TLSOptions{
+		CertFile:           writePEM(t, "client.pem", "CERTIFICATE", cert),
+		KeyFile:            writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyData),
+		InsecureSkipVerify: true,
+	}
+	if _, err := loadWithTLSOptions(t, server, opts); err != nil {
+		t.Fatalf("Failed to load page with client certificate: %v", err)
+	}
+	if len(presented) != 1 || presented[0] != "crawler" {
+		t.Errorf("Incorrect client certificates presented: expected [crawler], got %v", presented)
+	}
+}
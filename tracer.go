@@ -0,0 +1,53 @@
+package sitemap
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer records the stages of crawling each URL as spans in a distributed tracing system, so the load a
+// crawl puts on a site shows up alongside the site's own traces (see WithTracer and OTelTracer)
+type Tracer interface {
+
+	// Start starts a span named name for a stage of crawling a URL, as a child of any span in ctx. It returns
+	// a context holding the new span and a function ending it, which is passed the error the stage failed
+	// with (nil if it succeeded).
+	Start(ctx context.Context, name string, urlStr string) (context.Context, func(err error))
+
+	// Inject adds the headers propagating the span in ctx (if any) to an outbound request, so the requests
+	// made by the crawler are part of its traces. Tracers which don't propagate traces add nothing.
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Names of the spans recorded for each URL crawled. Loading a URL is traced by spanCrawl, with the other
+// spans as its children.
+const (
+	spanCrawl  = "crawl.url"    // the whole of crawling a URL, from being dispatched until its result is recorded
+	spanFetch  = "crawl.fetch"  // downloading the page (including any redirects)
+	spanParse  = "crawl.parse"  // parsing the page, including any further requests made for it (e.g. its assets)
+	spanIngest = "crawl.ingest" // adding the page to the site map (or other page sink)
+)
+
+// tracerKey is the context key the tracer used for a crawl is stored under
+type tracerKey struct{}
+
+// withTracer returns a context holding a tracer, used to create spans by startSpan
+func withTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// startSpan starts a span using the tracer in ctx, returning the context holding the span and the function
+// ending it (see Tracer.Start). Without a tracer, ctx is returned unchanged with a function doing nothing.
+func startSpan(ctx context.Context, name string, urlStr string) (context.Context, func(err error)) {
+	if tracer, ok := ctx.Value(tracerKey{}).(Tracer); ok {
+		return tracer.Start(ctx, name, urlStr)
+	}
+	return ctx, func(error) {}
+}
+
+// injectTrace adds the headers propagating the span in ctx to a request using the tracer in ctx (if any)
+func injectTrace(ctx context.Context, header http.Header) {
+	if tracer, ok := ctx.Value(tracerKey{}).(Tracer); ok {
+		tracer.Inject(ctx, header)
+	}
+}
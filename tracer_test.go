@@ -0,0 +1,89 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RecordingTracer is a Tracer recording each span ended, propagating the name of the current span in the
+// X-Test-Span header
+type RecordingTracer struct {
+	mutex sync.Mutex
+	spans []string // spans ended, as "parent > name url (error)"
+}
+
+type recordingSpanKey struct{}
+
+func (t *RecordingTracer) Start(ctx context.Context, name string, urlStr string) (context.Context, func(err error)) {
+	parent, _ := ctx.Value(recordingSpanKey{}).(string)
+	return context.WithValue(ctx, recordingSpanKey{}, name), func(err error) {
+		span := fmt.Sprintf("%s > %s %s", parent, name, urlStr)
+		if err != nil {
+			span += " (error)"
+		}
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		t.spans = append(t.spans, span)
+	}
+}
+
+func (t *RecordingTracer) Inject(ctx context.Context, header http.Header) {
+	if span, ok := ctx.Value(recordingSpanKey{}).(string); ok {
+		header.Set("X-Test-Span", span)
+	}
+}
+
+func TestCrawlTracing(t *testing.T) {
+
+	var mutex sync.Mutex
+	propagated := make(map[string]string) // span propagated on the request for each path
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		propagated[req.URL.Path] = req.Header.Get("X-Test-Span")
+		mutex.Unlock()
+		if req.URL.Path != "/" {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(rw, `<html><body><a href="/missing">Missing</a></body></html>`)
+	}))
+	defer server.Close()
+
+	for _, parseWorkers := range []int{0, 2} {
+		tracer := &RecordingTracer{}
+		siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+		crawler := createTestCrawler(t, server, WithSink(siteMap), WithTracer(tracer), WithParseWorkers(parseWorkers))
+		crawlWithin(t, crawler)
+
+		expected := []string{
+			" > crawl.url " + server.URL,
+			" > crawl.url " + server.URL + "/missing (error)",
+			"crawl.url > crawl.fetch " + server.URL,
+			"crawl.url > crawl.fetch " + server.URL + "/missing (error)",
+			"crawl.url > crawl.ingest " + server.URL,
+			"crawl.url > crawl.parse " + server.URL,
+		}
+		sort.Strings(tracer.spans)
+		if strings.Join(tracer.spans, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Incorrect spans with %d parse workers: expected %q, got %q", parseWorkers, expected, tracer.spans)
+		}
+		mutex.Lock()
+		for _, path := range []string{"/", "/missing"} {
+			if span := propagated[path]; span != spanFetch {
+				t.Errorf("Incorrect span propagated for %s with %d parse workers: expected %s, got %q", path, parseWorkers, spanFetch, span)
+			}
+		}
+		mutex.Unlock()
+	}
+
+	if _, err := CreateCrawler(mustParseURL(t, server.URL), WithTracer(nil)); err == nil {
+		t.Errorf("Incorrect result for a nil tracer: expected an error")
+	}
+}
@@ -0,0 +1,54 @@
+package sitemap
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// DefaultIdleConnTimeout is how long an idle connection to a server is kept open for reuse by default
+const DefaultIdleConnTimeout = 90 * time.Second
+
+// TransportOptions configures the HTTP transport pages are loaded with
+type TransportOptions struct {
+	Workers         int           // number of concurrent loads, which is the number of idle connections kept per host by default
+	MaxIdlePerHost  int           // idle connections kept open to each host for reuse (0 for the number of workers)
+	IdleConnTimeout time.Duration // how long idle connections are kept open (0 for DefaultIdleConnTimeout)
+	NoKeepAlives    bool          // set to open a new connection for every request
+	NoHTTP2         bool          // set to only use HTTP/1.1, even with servers supporting HTTP/2
+	Proxies         *ProxyRotator // proxies requests are sent through (nil to use the environment, see ProxyRotator)
+	TLS             *tls.Config   // TLS configuration (nil for the default, see TLSOptions)
+	Resolver        *DNSResolver  // resolver for the host names connected to (nil to look up every connection)
+}
+
+// CreateTransport creates the HTTP transport for the options. Unlike the default transport, which only keeps
+// 2 idle connections per host, a connection is kept open for each worker so a crawl with many workers reuses
+// its connections rather than opening (and TLS handshaking) a new one for most requests. HTTP/2 is used with
+// servers supporting it, multiplexing requests over a single connection.
+func CreateTransport(opts TransportOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = max(opts.Workers, 1)
+	if opts.MaxIdlePerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdlePerHost
+	}
+	transport.MaxIdleConns = max(transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	if opts.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = opts.NoKeepAlives
+	if opts.Proxies != nil {
+		transport.Proxy = opts.Proxies.Proxy
+	}
+	if opts.TLS != nil {
+		transport.TLSClientConfig = opts.TLS
+	}
+	if opts.Resolver != nil {
+		transport.DialContext = opts.Resolver.DialContext
+	}
+	if opts.NoHTTP2 {
+		// a non-nil empty map disables HTTP/2, see the net/http documentation
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return transport
+}
@@ -0,0 +1,82 @@
+package sitemap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCreateTransport(t *testing.T) {
+
+	if transport := CreateTransport(TransportOptions{Workers: 8}); transport.MaxIdleConnsPerHost != 8 {
+		t.Errorf("Incorrect idle connections per host: expected %v, got %v", 8, transport.MaxIdleConnsPerHost)
+	}
+	if transport := CreateTransport(TransportOptions{Workers: 8, MaxIdlePerHost: 3}); transport.MaxIdleConnsPerHost != 3 {
+		t.Errorf("Incorrect idle connections per host: expected %v, got %v", 3, transport.MaxIdleConnsPerHost)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, req.Proto)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	for _, noHTTP2 := range []bool{false, true} {
+		transport := CreateTransport(TransportOptions{NoHTTP2: noHTTP2, TLS: &tls.Config{InsecureSkipVerify: true}})
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			t.Fatalf("Failed to request page: %v", err)
+		}
+		resp.Body.Close()
+		if expected := map[bool]string{false: "HTTP/2.0", true: "HTTP/1.1"}[noHTTP2]; resp.Proto != expected {
+			t.Errorf("Incorrect protocol with NoHTTP2 %v: expected %v, got %v", noHTTP2, expected, resp.Proto)
+		}
+	}
+}
+
+func TestTransportConnectionReuse(t *testing.T) {
+
+	var connections atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, "ok")
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	// each worker makes a series of requests, which should reuse one connection each
+	const workers, requests = 6, 5
+	for _, noKeepAlives := range []bool{false, true} {
+		connections.Store(0)
+		client := &http.Client{Transport: CreateTransport(TransportOptions{Workers: workers, NoKeepAlives: noKeepAlives})}
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range requests {
+					if resp, err := client.Get(server.URL); err == nil {
+						io.Copy(io.Discard, resp.Body)
+						resp.Body.Close()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if got := connections.Load(); noKeepAlives && got != workers*requests {
+			t.Errorf("Incorrect connections without keep-alives: expected %v, got %v", workers*requests, got)
+		} else if !noKeepAlives && got > workers {
+			t.Errorf("Incorrect connections with keep-alives: expected at most %v, got %v", workers, got)
+		}
+	}
+}
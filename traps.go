@@ -0,0 +1,200 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TrapLimits are the thresholds used to detect crawl traps: URL patterns which generate an unbounded number
+// of URLs (e.g. calendars linking to the next month forever), which would otherwise be crawled until the
+// page limit is reached. A limit of 0 disables that check.
+type TrapLimits struct {
+	MaxSegmentRepeats int // maximum times a single path segment may appear in a URL (e.g. /a/b/a/b/a/b)
+	MaxDateVariants   int // maximum URLs crawled differing only by the dates in them (e.g. /events/2024/03)
+	MaxPageVariants   int // maximum URLs crawled differing only by a page number or offset (e.g. ?page=12)
+}
+
+// DefaultTrapLimits are the crawl trap thresholds used unless WithTrapLimits is supplied
+var DefaultTrapLimits = TrapLimits{MaxSegmentRepeats: 3, MaxDateVariants: 100, MaxPageVariants: 200}
+
+// TrapKind is the type of crawl trap a URL was found in
+type TrapKind int
+
+const (
+	TrapRepeatedSegment TrapKind = iota // a path segment repeated too many times
+	TrapDates                           // too many URLs differing only by date
+	TrapPagination                      // too many URLs differing only by page number
+)
+
+// String returns a description of the kind of crawl trap
+func (kind TrapKind) String() string {
+	switch kind {
+	case TrapRepeatedSegment:
+		return "repeated path segment"
+	case TrapDates:
+		return "calendar dates"
+	default:
+		return "pagination"
+	}
+}
+
+// CrawlTrap is a URL pattern detected as a crawl trap, along with the number of URLs skipped because of it
+type CrawlTrap struct {
+	Kind    TrapKind // type of trap
+	Pattern string   // URL pattern, with the parts which vary replaced by {date} or {n} (or the repeated segment)
+	Skipped int      // number of URLs matching the pattern which were not crawled
+}
+
+// datePattern matches dates in a URL: a year and month (optionally followed by a day) separated by - or /.
+// A year on its own isn't matched, as it can't be told apart from a numeric ID (e.g. /item/2048).
+var datePattern = regexp.MustCompile(`\b(19|20|21)\d{2}[-/](0?[1-9]|1[0-2])([-/](0?[1-9]|[12]\d|3[01]))?\b`)
+
+// pageSegmentPattern matches page numbers in a URL path (e.g. /page/12 or /p/12)
+var pageSegmentPattern = regexp.MustCompile(`/(page|p)/\d+(/|$)`)
+
+// paginationParams are the query parameters taken to be page numbers or offsets when numeric
+var paginationParams = map[string]bool{
+	"page": true, "p": true, "pg": true, "pagenum": true, "offset": true, "start": true, "from": true, "skip": true,
+}
+
+// TrapDetector detects URLs in crawl traps. It is not safe for concurrent use.
+type TrapDetector struct {
+	limits   TrapLimits
+	variants map[string]int        // number of URLs seen for each date or pagination pattern
+	traps    map[string]*CrawlTrap // traps detected, keyed by kind and pattern
+}
+
+// CreateTrapDetector creates a detector for crawl traps using the supplied limits
+func CreateTrapDetector(limits TrapLimits) *TrapDetector {
+	return &TrapDetector{limits: limits, variants: make(map[string]int), traps: make(map[string]*CrawlTrap)}
+}
+
+// Check records a URL about to be crawled and checks if it is in a crawl trap, returning the trap (nil if
+// none). The trap returned is new if its Skipped count is 1.
+func (d *TrapDetector) Check(u *url.URL) *CrawlTrap {
+	if d.limits.MaxSegmentRepeats > 0 {
+		if segment, count := mostRepeatedSegment(u.Path); count > d.limits.MaxSegmentRepeats {
+			return d.skip(TrapRepeatedSegment, u.Host+" "+segment)
+		}
+	}
+	if d.limits.MaxDateVariants > 0 {
+		if pattern := dateTrapPattern(u); len(pattern) != 0 {
+			if d.variants[pattern]++; d.variants[pattern] > d.limits.MaxDateVariants {
+				return d.skip(TrapDates, pattern)
+			}
+		}
+	}
+	if d.limits.MaxPageVariants > 0 {
+		if pattern := paginationTrapPattern(u); len(pattern) != 0 {
+			if d.variants[pattern]++; d.variants[pattern] > d.limits.MaxPageVariants {
+				return d.skip(TrapPagination, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Traps returns the crawl traps detected, sorted by pattern
+func (d *TrapDetector) Traps() []CrawlTrap {
+	traps := make([]CrawlTrap, 0, len(d.traps))
+	for _, trap := range d.traps {
+		traps = append(traps, *trap)
+	}
+	sort.Slice(traps, func(i, j int) bool {
+		if traps[i].Pattern != traps[j].Pattern {
+			return traps[i].Pattern < traps[j].Pattern
+		}
+		return traps[i].Kind < traps[j].Kind
+	})
+	return traps
+}
+
+// skip records a URL skipped because of a crawl trap
+func (d *TrapDetector) skip(kind TrapKind, pattern string) *CrawlTrap {
+	key := fmt.Sprintf("%d %s", kind, pattern)
+	trap, found := d.traps[key]
+	if !found {
+		trap = &CrawlTrap{Kind: kind, Pattern: pattern}
+		d.traps[key] = trap
+	}
+	trap.Skipped++
+	return trap
+}
+
+// mostRepeatedSegment returns the path segment appearing most often in a path, and how often it appears
+func mostRepeatedSegment(urlPath string) (string, int) {
+	counts := make(map[string]int)
+	mostRepeated, maxCount := "", 0
+	for _, segment := range strings.Split(urlPath, "/") {
+		if len(segment) == 0 {
+			continue
+		}
+		counts[segment]++
+		if counts[segment] > maxCount || (counts[segment] == maxCount && segment < mostRepeated) {
+			mostRepeated, maxCount = segment, counts[segment]
+		}
+	}
+	return mostRepeated, maxCount
+}
+
+// dateTrapPattern returns the URL with any dates in its path or query replaced by {date}, or an empty
+// string if it has no dates
+func dateTrapPattern(u *url.URL) string {
+	pattern := datePattern.ReplaceAllString(u.Path, "{date}")
+	query := datePattern.ReplaceAllString(sortedQuery(u), "{date}")
+	if pattern == u.Path && query == sortedQuery(u) {
+		return ""
+	}
+	return u.Host + pattern + query
+}
+
+// paginationTrapPattern returns the URL with any page numbers in its path or query replaced by {n}, or an
+// empty string if it has no page numbers
+func paginationTrapPattern(u *url.URL) string {
+	found := false
+	pattern := pageSegmentPattern.ReplaceAllStringFunc(u.Path, func(match string) string {
+		found = true
+		return pageSegmentPattern.ReplaceAllString(match, "/$1/{n}$2")
+	})
+	values := u.Query()
+	for name, params := range values {
+		if paginationParams[strings.ToLower(name)] {
+			for i, value := range params {
+				if isNumber(value) {
+					found = true
+					params[i] = "{n}"
+				}
+			}
+		}
+	}
+	if !found {
+		return ""
+	}
+	query := ""
+	if len(values) != 0 {
+		query = "?" + strings.ReplaceAll(values.Encode(), "%7Bn%7D", "{n}")
+	}
+	return u.Host + pattern + query
+}
+
+// sortedQuery returns the query string of a URL with its parameters sorted (including the ?), or an empty
+// string if it has none
+func sortedQuery(u *url.URL) string {
+	if len(u.RawQuery) == 0 {
+		return ""
+	}
+	return "?" + u.Query().Encode()
+}
+
+// isNumber checks if a string is a (non-empty) sequence of digits
+func isNumber(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(value) != 0
+}
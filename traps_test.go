@@ -0,0 +1,62 @@
+package sitemap
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTrapPatterns(t *testing.T) {
+	tests := []struct {
+		url        string
+		dates      string
+		pagination string
+	}{
+		{"https://test.com/about", "", ""},
+		{"https://test.com/events/2024/03/15", "test.com/events/{date}", ""},
+		{"https://test.com/cal?month=2024-03&view=grid", "test.com/cal?month={date}&view=grid", ""},
+		{"https://test.com/blog/page/12", "", "test.com/blog/page/{n}"},
+		{"https://test.com/list?sort=name&page=3", "", "test.com/list?page={n}&sort=name"},
+		{"https://test.com/list?page=last", "", ""},
+		{"https://test.com/products/12345", "", ""},
+		{"https://test.com/item/2048", "", ""},
+		{"https://test.com/archive/2024/05", "test.com/archive/{date}", ""},
+		{"https://test.com/item/2048/13", "", ""},
+	}
+	for _, test := range tests {
+		u := mustParseURL(t, test.url)
+		if got := dateTrapPattern(u); got != test.dates {
+			t.Errorf("Incorrect date pattern for %s: expected %q, got %q", test.url, test.dates, got)
+		}
+		if got := paginationTrapPattern(u); got != test.pagination {
+			t.Errorf("Incorrect pagination pattern for %s: expected %q, got %q", test.url, test.pagination, got)
+		}
+	}
+}
+
+func TestTrapDetector(t *testing.T) {
+	detector := CreateTrapDetector(TrapLimits{MaxSegmentRepeats: 2, MaxDateVariants: 2, MaxPageVariants: 0})
+	urls := []string{
+		"https://test.com/a/b/a/b",
+		"https://test.com/a/b/a/b/a",
+		"https://test.com/a/b/a/b/a/b",
+		"https://test.com/cal/2024-01",
+		"https://test.com/cal/2024-02",
+		"https://test.com/cal/2024-03",
+		"https://test.com/cal/2024-04",
+		"https://test.com/list?page=1000",
+	}
+	var skipped []string
+	for _, urlStr := range urls {
+		if trap := detector.Check(mustParseURL(t, urlStr)); trap != nil {
+			skipped = append(skipped, urlStr)
+		}
+	}
+	expected := "[https://test.com/a/b/a/b/a https://test.com/a/b/a/b/a/b https://test.com/cal/2024-03 https://test.com/cal/2024-04]"
+	if fmt.Sprint(skipped) != expected {
+		t.Errorf("Incorrect URLs skipped: expected %s, got %v", expected, skipped)
+	}
+	expected = "[{repeated path segment test.com a 2} {calendar dates test.com/cal/{date} 2}]"
+	if got := fmt.Sprint(detector.Traps()); got != expected {
+		t.Errorf("Incorrect crawl traps: expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,158 @@
+package sitemap
+
+import (
+	"sort"
+	"strings"
+)
+
+// EncodingProblem is a way the href of a link differs from its canonical (RFC 3986) encoding. Hrefs with
+// these problems are usually template bugs, and can create duplicate URLs for the same page.
+type EncodingProblem int
+
+const (
+	EncodingUnencodedSpace     EncodingProblem = iota // a space which should be encoded as %20
+	EncodingUnencodedNonASCII                         // a non-ASCII character which should be percent-encoded
+	EncodingUnencodedCharacter                        // another character which is not allowed in a URL
+	EncodingLowercaseEscape                           // a percent-encoding with lower case hex digits (e.g. %2f)
+	EncodingEscapedUnreserved                         // an unreserved character which should not be encoded (e.g. %41)
+	EncodingInvalidEscape                             // a % not followed by 2 hex digits
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem EncodingProblem) messageKey() string {
+	switch problem {
+	case EncodingUnencodedSpace:
+		return "encoding.space"
+	case EncodingUnencodedNonASCII:
+		return "encoding.nonascii"
+	case EncodingUnencodedCharacter:
+		return "encoding.character"
+	case EncodingLowercaseEscape:
+		return "encoding.lowercase"
+	case EncodingEscapedUnreserved:
+		return "encoding.unreserved"
+	default:
+		return "encoding.invalid"
+	}
+}
+
+// HrefIssue is an internal link whose href is not in its canonical encoding
+type HrefIssue struct {
+	Href      string            // href as written in the page
+	Canonical string            // href in its canonical encoding
+	Problems  []EncodingProblem // ways the href differs from its canonical encoding, in the order first found
+}
+
+// PageHrefIssues is a page along with the internal links on it whose href is not in its canonical encoding
+type PageHrefIssues struct {
+	URL    string      // URL of the page
+	Issues []HrefIssue // links with encoding issues, each href once in the order first found
+}
+
+// HrefIssues returns the pages in the site map with internal links whose href is not in its canonical
+// encoding, sorted by URL
+func (site *SiteMap) HrefIssues() []PageHrefIssues {
+	var pages []PageHrefIssues
+	for _, page := range site.Pages {
+		if len(page.HrefIssues) == 0 {
+			continue
+		}
+		entry := PageHrefIssues{URL: page.URL.String()}
+		seen := make(map[string]bool)
+		for _, issue := range page.HrefIssues {
+			if !seen[issue.Href] {
+				seen[issue.Href] = true
+				entry.Issues = append(entry.Issues, issue)
+			}
+		}
+		pages = append(pages, entry)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL < pages[j].URL })
+	return pages
+}
+
+// CheckHrefEncoding compares a href with its canonical encoding, returning the issue found or nil if the
+// href is already in canonical form. Any fragment is ignored.
+func CheckHrefEncoding(href string) *HrefIssue {
+	issue := &HrefIssue{Href: href}
+	found := make(map[EncodingProblem]bool)
+	addProblem := func(problem EncodingProblem) {
+		if !found[problem] {
+			found[problem] = true
+			issue.Problems = append(issue.Problems, problem)
+		}
+	}
+
+	ref, fragment, hasFragment := strings.Cut(href, "#")
+	var canonical strings.Builder
+	for i := 0; i < len(ref); i++ {
+		c := ref[i]
+		switch {
+		case c == '%' && i+2 < len(ref) && isHex(ref[i+1]) && isHex(ref[i+2]):
+			decoded := unhex(ref[i+1])<<4 | unhex(ref[i+2])
+			if isUnreserved(decoded) {
+				addProblem(EncodingEscapedUnreserved)
+				canonical.WriteByte(decoded)
+			} else {
+				if ref[i+1:i+3] != strings.ToUpper(ref[i+1:i+3]) {
+					addProblem(EncodingLowercaseEscape)
+				}
+				canonical.WriteString("%" + strings.ToUpper(ref[i+1:i+3]))
+			}
+			i += 2
+		case c == '%':
+			addProblem(EncodingInvalidEscape)
+			canonical.WriteString("%25")
+		case c == ' ':
+			addProblem(EncodingUnencodedSpace)
+			canonical.WriteString("%20")
+		case c >= 0x80:
+			addProblem(EncodingUnencodedNonASCII)
+			writeEscaped(&canonical, c)
+		case c < 0x20 || c == 0x7f || strings.IndexByte(`"<>\^`+"`{|}", c) >= 0:
+			addProblem(EncodingUnencodedCharacter)
+			writeEscaped(&canonical, c)
+		default:
+			canonical.WriteByte(c)
+		}
+	}
+	if len(issue.Problems) == 0 {
+		return nil
+	}
+	issue.Canonical = canonical.String()
+	if hasFragment {
+		issue.Canonical += "#" + fragment
+	}
+	return issue
+}
+
+// writeEscaped writes a byte percent-encoded
+func writeEscaped(w *strings.Builder, c byte) {
+	const hexDigits = "0123456789ABCDEF"
+	w.WriteByte('%')
+	w.WriteByte(hexDigits[c>>4])
+	w.WriteByte(hexDigits[c&0x0f])
+}
+
+// isHex checks if a character is a hex digit
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// unhex returns the value of a hex digit
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// isUnreserved checks if a character is unreserved in a URL (RFC 3986 section 2.3), so never needs encoding
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,69 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckHrefEncoding(t *testing.T) {
+	tests := []struct {
+		href      string
+		canonical string
+		problems  []EncodingProblem
+	}{
+		{"/about", "", nil},
+		{"/caf%C3%A9?q=a%2Fb#top", "", nil},
+		{"/a b/c d", "/a%20b/c%20d", []EncodingProblem{EncodingUnencodedSpace}},
+		{"/café", "/caf%C3%A9", []EncodingProblem{EncodingUnencodedNonASCII}},
+		{"/a|b<c>", "/a%7Cb%3Cc%3E", []EncodingProblem{EncodingUnencodedCharacter}},
+		{"/a%2fb%c3%A9", "/a%2Fb%C3%A9", []EncodingProblem{EncodingLowercaseEscape}},
+		{"/%41bout%7e", "/About~", []EncodingProblem{EncodingEscapedUnreserved}},
+		{"/100%", "/100%25", []EncodingProblem{EncodingInvalidEscape}},
+		{"/a b%2f#x y", "/a%20b%2F#x y", []EncodingProblem{EncodingUnencodedSpace, EncodingLowercaseEscape}},
+	}
+	for _, test := range tests {
+		issue := CheckHrefEncoding(test.href)
+		if test.problems == nil {
+			if issue != nil {
+				t.Errorf("Incorrect issue for canonical href %s: expected none, got %+v", test.href, issue)
+			}
+			continue
+		}
+		if issue == nil {
+			t.Errorf("Missing expected issue for href %s", test.href)
+		} else if issue.Canonical != test.canonical || fmt.Sprint(issue.Problems) != fmt.Sprint(test.problems) {
+			t.Errorf("Incorrect issue for href %s: expected %s %v, got %s %v", test.href, test.canonical, test.problems,
+				issue.Canonical, issue.Problems)
+		}
+	}
+}
+
+func TestHrefIssuesReport(t *testing.T) {
+	doc := `<html><body>
+		<a href="/a b">A</a> <a href="/a b">A again</a> <a href="/a%2fb">B</a> <a href="/fine">Fine</a>
+		<a href="http://other.com/x y">External</a>
+	</body></html>`
+	page, err := CreateDocumentParser().ParseDocument("https://test.com", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	if _, err := site.AddPage(page); err != nil {
+		t.Fatal(err)
+	}
+	addPage(t, site, true, "https://test.com/fine", "Fine")
+
+	var buf bytes.Buffer
+	if err := PrintHrefIssues(&buf, site.HrefIssues(), nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\n\n ----- Pages linking with hrefs not in canonical encoding (1) -----\n" +
+		" https://test.com:\n" +
+		"     /a b -> /a%20b (unencoded space)\n" +
+		"     /a%2fb -> /a%2Fb (lower case percent-encoding)\n"
+	if buf.String() != expected {
+		t.Errorf("Incorrect encoding report: expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,180 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// HostPolicy controls which hosts are part of the site being crawled, and which of the www and apex (bare
+// domain) hosts the URLs of its pages use
+type HostPolicy int
+
+const (
+	HostAsFound    HostPolicy = iota // the www and apex hosts are the same site, with URLs kept as found
+	HostPreferApex                   // the www and apex hosts are the same site, with URLs rewritten to the apex host
+	HostPreferWWW                    // the www and apex hosts are the same site, with URLs rewritten to the www host
+	HostExact                        // only the host crawled is part of the site
+)
+
+// ParseHostPolicy converts a policy name (empty, apex, www or exact) into a HostPolicy
+func ParseHostPolicy(name string) (HostPolicy, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return HostAsFound, nil
+	case "apex":
+		return HostPreferApex, nil
+	case "www":
+		return HostPreferWWW, nil
+	case "exact":
+		return HostExact, nil
+	}
+	return HostAsFound, fmt.Errorf("unknown canonical host %q (expected apex, www or exact)", name)
+}
+
+// URLPolicy is the normalization applied to the URLs of the site being crawled, so equivalent URLs are loaded
+// once and stored as the same page. The zero value applies no normalization beyond that always applied to
+// links (see DocParser), with the www and apex hosts treated as the same site.
+type URLPolicy struct {
+	Host          HostPolicy // which hosts are part of the site, and which of the www and apex hosts is used
+	PreferHTTPS   bool       // rewrite http URLs on the site to https
+	LowercaseHost bool       // lowercase host names (of every URL, as they are case insensitive)
+	LowercasePath bool       // lowercase the paths of URLs on the site (for case insensitive servers, e.g. IIS)
+	Escapes       bool       // decode needlessly percent-encoded characters, uppercasing the hex digits of the rest
+}
+
+// ParseURLPolicy creates a policy from the canonical host (see ParseHostPolicy) and a comma separated list of
+// normalizations: https, lowercase-host, lowercase-path and escapes
+func ParseURLPolicy(host string, normalizations string) (URLPolicy, error) {
+	hostPolicy, err := ParseHostPolicy(host)
+	if err != nil {
+		return URLPolicy{}, err
+	}
+	policy := URLPolicy{Host: hostPolicy}
+	for _, name := range strings.Split(normalizations, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "https":
+			policy.PreferHTTPS = true
+		case "lowercase-host":
+			policy.LowercaseHost = true
+		case "lowercase-path":
+			policy.LowercasePath = true
+		case "escapes":
+			policy.Escapes = true
+		default:
+			return URLPolicy{}, fmt.Errorf("unknown normalization %q (expected https, lowercase-host, lowercase-path or escapes)", name)
+		}
+	}
+	return policy, nil
+}
+
+// SameHost checks if 2 hosts are the same site. Unless the policy is HostExact, example.com and
+// www.example.com are the same site (see sameHost).
+func (policy URLPolicy) SameHost(h1 string, h2 string) bool {
+	if policy.Host == HostExact {
+		return strings.EqualFold(h1, h2)
+	}
+	return sameHost(h1, h2)
+}
+
+// sameSite checks if a URL is on the same site (and port) as the parent URL
+func (policy URLPolicy) sameSite(u *url.URL, parent *url.URL) bool {
+	if !policy.SameHost(u.Host, parent.Host) {
+		return false // different domain
+	}
+	return len(u.Port()) == 0 || u.Port() == parent.Port()
+}
+
+// Apply normalizes an http or https URL in place. Only the host name of URLs not on the site with the host
+// siteHost (whose port is ignored) is normalized.
+func (policy URLPolicy) Apply(u *url.URL, siteHost string) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	if policy.LowercaseHost {
+		u.Host = strings.ToLower(u.Host)
+	}
+	if !policy.SameHost(u.Hostname(), (&url.URL{Host: siteHost}).Hostname()) {
+		return
+	}
+	if policy.PreferHTTPS && u.Scheme == "http" {
+		u.Scheme = "https"
+		if u.Port() == "80" {
+			u.Host = u.Hostname()
+		}
+	}
+	hostname, port := u.Hostname(), u.Port()
+	hasWWW := strings.HasPrefix(strings.ToLower(hostname), "www.")
+	if policy.Host == HostPreferApex && hasWWW {
+		hostname = hostname[len("www."):]
+	} else if policy.Host == HostPreferWWW && !hasWWW {
+		hostname = "www." + hostname
+	}
+	if u.Host = hostname; len(port) != 0 {
+		u.Host += ":" + port
+	}
+	if policy.LowercasePath {
+		u.Path, u.RawPath = strings.ToLower(u.Path), strings.ToLower(u.RawPath)
+	}
+	if policy.Escapes {
+		escaped := normalizeEscapes(u.EscapedPath())
+		if path, err := url.PathUnescape(escaped); err == nil {
+			u.Path, u.RawPath = path, escaped
+		}
+		u.RawQuery = normalizeEscapes(u.RawQuery)
+	}
+}
+
+// normalizeEscapes decodes the percent-encoded unreserved characters (letters, digits, '-', '.', '_' and '~')
+// in an escaped URL component, which never need encoding, and uppercases the hex digits of other escapes
+func normalizeEscapes(escaped string) string {
+	if !strings.Contains(escaped, "%") {
+		return escaped
+	}
+	var result strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '%' || i+2 >= len(escaped) || !isHex(escaped[i+1]) || !isHex(escaped[i+2]) {
+			result.WriteByte(escaped[i])
+			continue
+		}
+		if decoded := unhex(escaped[i+1])<<4 | unhex(escaped[i+2]); isUnreserved(decoded) {
+			result.WriteByte(decoded)
+		} else {
+			result.WriteString("%" + strings.ToUpper(escaped[i+1:i+3]))
+		}
+		i += 2
+	}
+	return result.String()
+}
+
+// ASCIIHost converts an internationalized domain name in a host (which may include a port) to its ASCII
+// (punycode) form, so hosts written either way compare equal, e.g. bücher.de becomes xn--bcher-kva.de.
+// Hosts which are already ASCII, or aren't valid domain names, are returned unchanged.
+func ASCIIHost(host string) string {
+	if isASCII(host) {
+		return host
+	}
+	hostname, port := host, ""
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		hostname, port = host[:i], host[i:]
+	}
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return host
+	}
+	return ascii + port
+}
+
+// isASCII checks if a string contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
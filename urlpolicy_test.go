@@ -0,0 +1,132 @@
+package sitemap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseURLPolicy(t *testing.T) {
+
+	policy, err := ParseURLPolicy("WWW", "https, lowercase-path,escapes")
+	expected := URLPolicy{Host: HostPreferWWW, PreferHTTPS: true, LowercasePath: true, Escapes: true}
+	if err != nil || policy != expected {
+		t.Errorf("Incorrect policy: expected %+v, got %+v (%v)", expected, policy, err)
+	}
+	if policy, err = ParseURLPolicy("", ""); err != nil || policy != (URLPolicy{}) {
+		t.Errorf("Incorrect default policy: expected %+v, got %+v (%v)", URLPolicy{}, policy, err)
+	}
+	if _, err = ParseURLPolicy("bare", ""); err == nil {
+		t.Errorf("Incorrect result for unknown canonical host: expected an error")
+	}
+	if _, err = ParseURLPolicy("", "https,uppercase"); err == nil {
+		t.Errorf("Incorrect result for unknown normalization: expected an error")
+	}
+}
+
+func TestURLPolicyApply(t *testing.T) {
+
+	tests := []struct {
+		policy   URLPolicy
+		url      string
+		expected string
+	}{
+		{URLPolicy{}, "http://WWW.Test.com/A%7eB", "http://WWW.Test.com/A%7eB"},
+		{URLPolicy{Host: HostPreferApex}, "http://www.test.com:8080/a", "http://test.com:8080/a"},
+		{URLPolicy{Host: HostPreferApex}, "http://test.com/a", "http://test.com/a"},
+		{URLPolicy{Host: HostPreferWWW}, "http://test.com/a", "http://www.test.com/a"},
+		{URLPolicy{Host: HostPreferWWW}, "http://other.com/a", "http://other.com/a"},
+		{URLPolicy{PreferHTTPS: true}, "http://test.com:80/a?b=1", "https://test.com/a?b=1"},
+		{URLPolicy{PreferHTTPS: true}, "http://other.com/a", "http://other.com/a"},
+		{URLPolicy{Host: HostExact, PreferHTTPS: true}, "http://www.test.com/a", "http://www.test.com/a"},
+		{URLPolicy{LowercaseHost: true}, "http://OTHER.com/A", "http://other.com/A"},
+		{URLPolicy{LowercaseHost: true, LowercasePath: true}, "http://Test.COM/Docs/Page", "http://test.com/docs/page"},
+		{URLPolicy{Escapes: true}, "http://test.com/%7euser/a%2fb%c3%a9?q=%41%2b", "http://test.com/~user/a%2Fb%C3%A9?q=A%2B"},
+		{URLPolicy{PreferHTTPS: true}, "ftp://test.com/a", "ftp://test.com/a"},
+	}
+	for _, test := range tests {
+		u := mustParseURL(t, test.url)
+		test.policy.Apply(u, "test.com")
+		if u.String() != test.expected {
+			t.Errorf("Incorrect URL for %s with %+v: expected %s, got %s", test.url, test.policy, test.expected, u)
+		}
+	}
+}
+
+func TestParseDocumentURLPolicy(t *testing.T) {
+
+	doc := `<html><body><a href="http://www.test.com/About">About</a><a href="https://test.com/Docs/%7eGuide">Guide</a>
+		<a href="http://other.com/Page">Other</a></body></html>`
+	parser := CreateDocumentParser()
+	parser.Policy = URLPolicy{Host: HostPreferApex, PreferHTTPS: true, LowercasePath: true, Escapes: true}
+	page, err := parser.ParseDocument("https://test.com/", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	expected := []string{"https://test.com/about", "https://test.com/docs/~guide"}
+	if got := sortedKeys(page.InternalLinks); strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Incorrect links: expected %v, got %v", expected, got)
+	}
+	if !page.ExternalLinks["http://other.com/Page"] {
+		t.Errorf("Incorrect external links: expected http://other.com/Page, got %v", page.ExternalLinks)
+	}
+
+	parser.Policy = URLPolicy{Host: HostExact}
+	if page, _ = parser.ParseDocument("https://test.com/", strings.NewReader(doc)); len(page.InternalLinks) != 1 {
+		t.Errorf("Incorrect links with exact host: expected only the link to the host crawled, got %v", sortedKeys(page.InternalLinks))
+	}
+	if !page.ExternalLinks["http://www.test.com/About"] {
+		t.Errorf("Incorrect external links with exact host: expected http://www.test.com/About, got %v", page.ExternalLinks)
+	}
+}
+
+func TestSiteMapURLPolicy(t *testing.T) {
+
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	site.URLPolicy = URLPolicy{Host: HostPreferApex, PreferHTTPS: true}
+	for _, urlStr := range []string{"https://test.com/a", "http://www.test.com/a", "https://test.com/b"} {
+		if _, err := site.AddPage(createWebPage(t, urlStr, "Page")); err != nil {
+			t.Fatalf("Failed to add page %s: %v", urlStr, err)
+		}
+	}
+	if len(site.Pages) != 2 {
+		t.Errorf("Incorrect number of pages: expected 2, got %v", sortedKeys(site.Pages))
+	}
+	if _, found := site.Pages["https://test.com/a"]; !found {
+		t.Errorf("Incorrect pages: expected https://test.com/a, got %v", sortedKeys(site.Pages))
+	}
+}
+
+func TestInternationalizedHosts(t *testing.T) {
+
+	tests := map[string]string{
+		"bücher.de":          "xn--bcher-kva.de",
+		"www.BÜCHER.de:8080": "www.xn--bcher-kva.de:8080",
+		"xn--bcher-kva.de":   "xn--bcher-kva.de",
+		"Example.com":        "Example.com",
+	}
+	for host, expected := range tests {
+		if got := ASCIIHost(host); got != expected {
+			t.Errorf("Incorrect ASCII host for %s: expected %s, got %s", host, expected, got)
+		}
+	}
+	if !sameHost("www.bücher.de", "xn--bcher-kva.de") {
+		t.Errorf("Incorrect result comparing Unicode and punycode hosts: expected same host")
+	}
+
+	doc := `<html><body><a href="https://bücher.de/neu">Neu</a><a href="https://www.xn--bcher-kva.de/alt">Alt</a>
+		<a href="https://b%C3%BCcher.de/neu">Neu</a><a href="https://bücher.com/">Other</a></body></html>`
+	page, err := CreateDocumentParser().ParseDocument("https://xn--bcher-kva.de/", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	expected := []string{"https://www.xn--bcher-kva.de/alt", "https://xn--bcher-kva.de/neu"}
+	if got := sortedKeys(page.InternalLinks); strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("Incorrect links: expected %v, got %v", expected, got)
+	}
+	if links := page.InternalLinks["https://xn--bcher-kva.de/neu"]; len(links) != 2 {
+		t.Errorf("Incorrect links to the Unicode host: expected 2, got %v", links)
+	}
+	if !page.ExternalLinks["https://xn--bcher-kva.com"] {
+		t.Errorf("Incorrect external links: expected https://xn--bcher-kva.com, got %v", page.ExternalLinks)
+	}
+}
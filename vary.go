@@ -0,0 +1,109 @@
+package sitemap
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// VaryProblem is a sign that a page is served differently to different clients (or different requests),
+// which often indicates A/B testing or broken caching affecting crawlers
+type VaryProblem int
+
+const (
+	VaryInconsistent VaryProblem = iota // different contents returned for identical requests
+	VaryAll                             // Vary: *, so the response can never be reused by a cache
+	VaryUserAgent                       // varies by User-Agent, so crawlers may be served different content
+	VaryCookie                          // varies by Cookie, so content depends on client state
+	VaryUnusual                         // varies by a header other than the usual content negotiation headers
+)
+
+// messageKey returns the key of the message describing the problem in a Catalog
+func (problem VaryProblem) messageKey() string {
+	switch problem {
+	case VaryInconsistent:
+		return "vary.inconsistent"
+	case VaryAll:
+		return "vary.all"
+	case VaryUserAgent:
+		return "vary.useragent"
+	case VaryCookie:
+		return "vary.cookie"
+	default:
+		return "vary.unusual"
+	}
+}
+
+// expectedVary are the (lower case) headers a response is expected to vary by for content negotiation
+var expectedVary = map[string]bool{
+	"accept": true, "accept-encoding": true, "accept-language": true, "origin": true,
+}
+
+// VaryIssue is a page whose responses vary unexpectedly
+type VaryIssue struct {
+	URL     string      // URL of the page
+	Problem VaryProblem // problem found
+	Detail  string      // Vary header, or the hashes of the differing contents
+}
+
+// CheckVary checks the Vary header of a response, returning the suspicious problems found (none if it only
+// varies by the usual content negotiation headers)
+func CheckVary(header http.Header) []VaryProblem {
+	var problems []VaryProblem
+	seen := make(map[VaryProblem]bool)
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			problem := VaryUnusual
+			switch name = strings.ToLower(strings.TrimSpace(name)); {
+			case len(name) == 0 || expectedVary[name]:
+				continue
+			case name == "*":
+				problem = VaryAll
+			case name == "user-agent":
+				problem = VaryUserAgent
+			case name == "cookie":
+				problem = VaryCookie
+			}
+			if !seen[problem] {
+				seen[problem] = true
+				problems = append(problems, problem)
+			}
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i] < problems[j] })
+	return problems
+}
+
+// VaryAudit returns the pages whose contents differed between identical requests (for pages requested a
+// second time) or with suspicious Vary headers, sorted by URL
+func (site *SiteMap) VaryAudit() []VaryIssue {
+	var issues []VaryIssue
+	for _, page := range site.Pages {
+		if len(page.RecheckHash) != 0 && page.RecheckHash != page.ContentHash {
+			detail := shortHash(page.ContentHash) + " != " + shortHash(page.RecheckHash)
+			issues = append(issues, VaryIssue{page.URL.String(), VaryInconsistent, detail})
+		}
+		if page.Header == nil {
+			continue
+		}
+		detail := "Vary: " + strings.Join(page.Header.Values("Vary"), ", ")
+		for _, problem := range CheckVary(page.Header) {
+			issues = append(issues, VaryIssue{page.URL.String(), problem, detail})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].URL != issues[j].URL {
+			return issues[i].URL < issues[j].URL
+		}
+		return issues[i].Problem < issues[j].Problem
+	})
+	return issues
+}
+
+// shortHash returns the start of a content hash, enough to tell different contents apart in a report
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,67 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckVary(t *testing.T) {
+	tests := map[string][]VaryProblem{
+		"":                                 nil,
+		"Accept-Encoding, Accept-Language": nil,
+		"*":                                {VaryAll},
+		"accept-encoding,User-Agent":       {VaryUserAgent},
+		"Cookie, X-Experiment, X-Device":   {VaryCookie, VaryUnusual},
+	}
+	for vary, expected := range tests {
+		header := make(http.Header)
+		if len(vary) != 0 {
+			header.Set("Vary", vary)
+		}
+		if got := CheckVary(header); fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("Incorrect vary problems for %q: expected %v, got %v", vary, expected, got)
+		}
+	}
+}
+
+func TestVaryAudit(t *testing.T) {
+	site := CreateSiteMap(mustParseURL(t, "https://test.com"))
+	pages := map[string]struct {
+		vary    string
+		hash    string
+		recheck string
+	}{
+		"":        {"Accept-Encoding", "aaaaaaaaaaaaaaaa", "aaaaaaaaaaaaaaaa"},
+		"/offers": {"User-Agent", "bbbbbbbbbbbbbbbb", "cccccccccccccccc"},
+		"/about":  {"", "dddd", ""},
+	}
+	for path, details := range pages {
+		page := createWebPage(t, "https://test.com"+path, path)
+		page.Header = make(http.Header)
+		page.Header.Set("Vary", details.vary)
+		page.ContentHash, page.RecheckHash = details.hash, details.recheck
+		if _, err := site.AddPage(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	issues := site.VaryAudit()
+	expected := "[{https://test.com/offers 0 bbbbbbbbbbbb != cccccccccccc} {https://test.com/offers 2 Vary: User-Agent}]"
+	if got := fmt.Sprint(issues); got != expected {
+		t.Errorf("Incorrect vary issues: expected %s, got %s", expected, got)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintVaryAudit(&buf, issues, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Pages varying between requests (2)",
+		" different contents for identical requests: https://test.com/offers (bbbbbbbbbbbb != cccccccccccc)",
+		" varies by user agent: https://test.com/offers (Vary: User-Agent)"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Incorrect vary report: expected %q in %s", line, buf.String())
+		}
+	}
+}
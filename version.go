@@ -0,0 +1,79 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the release version of the application. Release builds set this with
+//
+//	go build -ldflags "-X github.com/markamb/go-sitemap.Version=v1.2.3" ./cmd/go-sitemap
+//
+// otherwise the module version recorded in the build info is used (when installed with go install).
+var Version = ""
+
+// ReleasesURL is the GitHub API endpoint returning the latest published release
+const ReleasesURL = "https://api.github.com/repos/markamb/go-sitemap/releases/latest"
+
+// BuildInfo describes the build of the running binary
+type BuildInfo struct {
+	Version   string // release version, or "(devel)" if unknown
+	GoVersion string // Go toolchain used
+	Revision  string // VCS revision built from (empty if unknown)
+	Time      string // time of the VCS revision (empty if unknown)
+	Modified  bool   // set if built with uncommitted changes
+}
+
+// ReadBuildInfo returns the build details of the running binary
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.Time = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	return info
+}
+
+// LatestRelease returns the tag of the latest release from the GitHub releases API endpoint supplied
+func LatestRelease(client *http.Client, releasesURL string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status code %d (%s) from %s", resp.StatusCode, resp.Status, releasesURL)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("no release tag returned from %s", releasesURL)
+	}
+	return release.TagName, nil
+}
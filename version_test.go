@@ -0,0 +1,30 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLatestRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/latest" {
+			http.NotFound(rw, req)
+			return
+		}
+		fmt.Fprint(rw, `{"tag_name": "v1.4.0", "name": "Release 1.4.0"}`)
+	}))
+	defer server.Close()
+
+	latest, err := LatestRelease(server.Client(), server.URL+"/latest")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if latest != "v1.4.0" {
+		t.Errorf("Incorrect latest release: expected %s, got %s", "v1.4.0", latest)
+	}
+	if _, err := LatestRelease(server.Client(), server.URL+"/missing"); err == nil {
+		t.Errorf("Missing expected error for bad status")
+	}
+}
@@ -0,0 +1,205 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Video is a video on a page, from a <video> element, an embedded player or JSON-LD VideoObject structured
+// data. Details which aren't known are left empty.
+type Video struct {
+	ContentURL   string // URL of the video file (empty if only a player is known)
+	PlayerURL    string // URL of a player for the video, e.g. a YouTube embed (empty if none)
+	ThumbnailURL string // URL of a thumbnail image of the video
+	Title        string
+	Description  string
+	Duration     int // length of the video in seconds
+}
+
+// videoPlayer is a host of embedded video players, with the path its player URLs start with
+type videoPlayer struct {
+	host string // host of the player, matching subdomains too
+	path string
+}
+
+// videoPlayers are the common video hosts whose embedded players (in an <iframe> or <embed>) are recorded
+// as videos
+var videoPlayers = []videoPlayer{
+	{"youtube.com", "/embed/"}, {"youtube-nocookie.com", "/embed/"}, {"player.vimeo.com", "/video/"},
+	{"dailymotion.com", "/embed/video/"}, {"fast.wistia.net", "/embed/"}, {"players.brightcove.net", "/"},
+	{"loom.com", "/embed/"},
+}
+
+// isVideoPlayer checks if a URL is the player of a video on a common video host
+func isVideoPlayer(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, player := range videoPlayers {
+		if (host == player.host || strings.HasSuffix(host, "."+player.host)) && strings.HasPrefix(u.Path, player.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// youTubeThumbnail returns the thumbnail YouTube serves for the video of an embedded player (empty if the
+// player isn't a YouTube embed of a single video)
+func youTubeThumbnail(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if !strings.HasSuffix(host, "youtube.com") && !strings.HasSuffix(host, "youtube-nocookie.com") {
+		return ""
+	}
+	id := strings.TrimPrefix(u.Path, "/embed/")
+	if len(id) == 0 || strings.Contains(id, "/") || id == "videoseries" {
+		return ""
+	}
+	return "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"
+}
+
+// AddVideo records a video on the page. A video with the content or player URL of one already recorded
+// fills in the details of that one which aren't known, and videos with neither URL are ignored.
+func (page *WebPage) AddVideo(video Video) {
+	if len(video.ContentURL) == 0 && len(video.PlayerURL) == 0 {
+		return
+	}
+	for i := range page.Videos {
+		existing := &page.Videos[i]
+		if (len(video.ContentURL) != 0 && video.ContentURL == existing.ContentURL) ||
+			(len(video.PlayerURL) != 0 && video.PlayerURL == existing.PlayerURL) {
+			for _, field := range []struct{ to, from *string }{
+				{&existing.ContentURL, &video.ContentURL}, {&existing.PlayerURL, &video.PlayerURL},
+				{&existing.ThumbnailURL, &video.ThumbnailURL}, {&existing.Title, &video.Title},
+				{&existing.Description, &video.Description},
+			} {
+				if len(*field.to) == 0 {
+					*field.to = *field.from
+				}
+			}
+			if existing.Duration == 0 {
+				existing.Duration = video.Duration
+			}
+			return
+		}
+	}
+	page.Videos = append(page.Videos, video)
+}
+
+// videoURL resolves the URL of a video, its player or thumbnail, which may be on any domain, returning an
+// empty string if it isn't an http(s) URL
+func videoURL(parentURL *url.URL, ref string) string {
+	resolved, err := parentURL.Parse(strings.TrimSpace(ref))
+	if err != nil || len(ref) == 0 || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return ""
+	}
+	return resolved.String()
+}
+
+// addVideo records the video of a <video> element or embedded player. A <video> is only added once it ends,
+// as the URL of the video may be in a <source> inside it.
+func (s *documentScanner) addVideo(tag *html.Token) {
+	switch tag.DataAtom {
+	case atom.Video:
+		src, _ := attrValue(tag, "src")
+		poster, _ := attrValue(tag, "poster")
+		title, _ := attrValue(tag, "title")
+		s.video = &Video{ContentURL: videoURL(s.parentURL, src), ThumbnailURL: videoURL(s.parentURL, poster), Title: collapseSpace(title)}
+	case atom.Source:
+		if src, found := attrValue(tag, "src"); found && s.video != nil && len(s.video.ContentURL) == 0 {
+			s.video.ContentURL = videoURL(s.parentURL, src)
+		}
+	case atom.Iframe, atom.Embed:
+		src, _ := attrValue(tag, "src")
+		if player, err := url.Parse(videoURL(s.parentURL, src)); err == nil && isVideoPlayer(player) {
+			s.page.AddVideo(Video{PlayerURL: player.String(), ThumbnailURL: youTubeThumbnail(player), Title: frameTitle(tag)})
+		}
+	}
+}
+
+// isoDuration matches an ISO 8601 duration of days, hours, minutes and seconds, e.g. PT1M30S
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)(?:\.\d+)?S)?)?$`)
+
+// parseISODuration returns the number of seconds in an ISO 8601 duration, or 0 if it isn't valid
+func parseISODuration(duration string) int {
+	match := isoDuration.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(duration)))
+	if match == nil {
+		return 0
+	}
+	seconds := 0
+	for i, unit := range []int{86400, 3600, 60, 1} {
+		value, _ := strconv.Atoi(match[i+1])
+		seconds += value * unit
+	}
+	return seconds
+}
+
+// parseJSONLDVideos returns the videos described by the VideoObjects in a JSON-LD script, with their URLs as
+// written. Returns nil if the script has none or isn't valid JSON.
+func parseJSONLDVideos(script string) []Video {
+	var data any
+	if err := json.Unmarshal([]byte(script), &data); err != nil {
+		return nil
+	}
+	var videos []Video
+	var collect func(value any)
+	collect = func(value any) {
+		switch value := value.(type) {
+		case []any:
+			for _, item := range value {
+				collect(item)
+			}
+		case map[string]any:
+			if hasJSONLDType(value, "VideoObject") {
+				duration, _ := value["duration"].(string)
+				videos = append(videos, Video{
+					ContentURL:   jsonLDString(value["contentUrl"]),
+					PlayerURL:    jsonLDString(value["embedUrl"]),
+					ThumbnailURL: jsonLDString(value["thumbnailUrl"]),
+					Title:        collapseSpace(jsonLDString(value["name"])),
+					Description:  collapseSpace(jsonLDString(value["description"])),
+					Duration:     parseISODuration(duration),
+				})
+				return
+			}
+			for _, item := range value {
+				collect(item)
+			}
+		}
+	}
+	collect(data)
+	return videos
+}
+
+// jsonLDString returns the value of a JSON-LD property which is text or a URL, taking the first of a list
+// and the url (or @id) of an object, e.g. an ImageObject thumbnail
+func jsonLDString(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case []any:
+		if len(value) != 0 {
+			return jsonLDString(value[0])
+		}
+	case map[string]any:
+		if ref, found := value["url"]; found {
+			return jsonLDString(ref)
+		}
+		return jsonLDString(value["@id"])
+	}
+	return ""
+}
+
+// addJSONLDVideos records the videos described in a JSON-LD script, filling in the details of videos on the
+// page with the same URL
+func (p *DocParser) addJSONLDVideos(parentURL *url.URL, page *WebPage, script string) {
+	for _, video := range parseJSONLDVideos(script) {
+		video.ContentURL = videoURL(parentURL, video.ContentURL)
+		video.PlayerURL = videoURL(parentURL, video.PlayerURL)
+		video.ThumbnailURL = videoURL(parentURL, video.ThumbnailURL)
+		page.AddVideo(video)
+	}
+}
@@ -0,0 +1,115 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseVideos(t *testing.T) {
+
+	const doc = `<html><head><title>Videos</title>
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+	{"@type": "WebPage", "name": "Videos"},
+	{"@type": "VideoObject", "name": "Launch  event", "description": "The launch", "duration": "PT1M30S",
+	 "contentUrl": "/media/launch.mp4", "thumbnailUrl": [{"@type": "ImageObject", "url": "/media/launch.jpg"}]},
+	{"@type": "VideoObject", "name": "Elsewhere", "embedUrl": "https://player.vimeo.com/video/42", "uploadDate": "2024-01-01"}
+]}</script>
+</head><body>
+<video poster="/media/launch.png" controls><source src="/media/launch.mp4" type="video/mp4"><source src="/media/launch.webm"></video>
+<video src="https://cdn.example.org/clip.mp4" title="Clip"></video>
+<video></video>
+<iframe src="https://www.youtube.com/embed/abc123?rel=0" title="Demo"></iframe>
+<iframe src="https://player.vimeo.com/video/42"></iframe>
+<iframe src="https://maps.example.org/embed/map"></iframe>
+</body></html>`
+	parser := CreateDocumentParser()
+	parser.Videos = true
+	page, err := parser.ParseDocument("https://test.com/videos", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	expected := []Video{
+		{ContentURL: "https://test.com/media/launch.mp4", ThumbnailURL: "https://test.com/media/launch.jpg", Title: "Launch event",
+			Description: "The launch", Duration: 90},
+		{PlayerURL: "https://player.vimeo.com/video/42", Title: "Elsewhere"},
+		{ContentURL: "https://cdn.example.org/clip.mp4", Title: "Clip"},
+		{PlayerURL: "https://www.youtube.com/embed/abc123?rel=0", ThumbnailURL: "https://i.ytimg.com/vi/abc123/hqdefault.jpg", Title: "Demo"},
+	}
+	if !reflect.DeepEqual(page.Videos, expected) {
+		t.Errorf("Incorrect videos: expected %+v, got %+v", expected, page.Videos)
+	}
+
+	page, err = CreateDocumentParser().ParseDocument("https://test.com/videos", strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	if page.Videos != nil {
+		t.Errorf("Incorrect videos: expected none unless requested, got %+v", page.Videos)
+	}
+}
+
+func TestParseISODuration(t *testing.T) {
+
+	tests := map[string]int{
+		"PT1M30S": 90, "PT2H": 7200, "P1DT1S": 86401, "pt45.5s": 45, "PT": 0, "90": 0, "": 0, "P1Y": 0,
+	}
+	for duration, expected := range tests {
+		if seconds := parseISODuration(duration); seconds != expected {
+			t.Errorf("Incorrect duration of %q: expected %d, got %d", duration, expected, seconds)
+		}
+	}
+}
+
+func TestWriteSitemapXMLVideos(t *testing.T) {
+
+	page := createWebPage(t, "https://test.com/videos", "Videos")
+	page.AddVideo(Video{ContentURL: "https://test.com/launch.mp4", ThumbnailURL: "https://test.com/launch.jpg", Duration: 30000})
+	page.AddVideo(Video{ContentURL: "https://test.com/launch.mp4", Title: "Launch", Duration: 90})
+	page.AddVideo(Video{PlayerURL: "https://www.youtube.com/embed/abc123", Title: "Demo", Description: "A demo"})
+	page.AddVideo(Video{Title: "No URL"})
+	other := createWebPage(t, "https://test.com/about", "About")
+
+	var buf bytes.Buffer
+	if err := WriteSitemapXML(&buf, []*WebPage{page, other}, time.Time{}, nil); err != nil {
+		t.Fatalf("Failed to write sitemap: %v", err)
+	}
+	if !strings.Contains(buf.String(), `xmlns:video="`+videoNamespace+`"`) {
+		t.Errorf("Incorrect sitemap: expected the video namespace, got\n%s", buf.String())
+	}
+	type video struct {
+		Thumbnail   string `xml:"thumbnail_loc"`
+		Title       string `xml:"title"`
+		Description string `xml:"description"`
+		Content     string `xml:"content_loc"`
+		Player      string `xml:"player_loc"`
+		Duration    int    `xml:"duration"`
+	}
+	var sitemap struct {
+		URLs []struct {
+			Loc    string  `xml:"loc"`
+			Videos []video `xml:"video"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &sitemap); err != nil {
+		t.Fatalf("Failed to parse sitemap: %v\n%s", err, buf.String())
+	}
+	expected := []video{
+		{Thumbnail: "https://test.com/launch.jpg", Title: "Launch", Description: "Launch", Content: "https://test.com/launch.mp4"},
+		{Title: "Demo", Description: "A demo", Player: "https://www.youtube.com/embed/abc123"},
+	}
+	if len(sitemap.URLs) != 2 || !reflect.DeepEqual(sitemap.URLs[0].Videos, expected) || len(sitemap.URLs[1].Videos) != 0 {
+		t.Errorf("Incorrect sitemap videos: expected %+v on the first page only, got %+v", expected, sitemap.URLs)
+	}
+
+	buf.Reset()
+	if err := WriteSitemapXML(&buf, []*WebPage{other}, time.Time{}, nil); err != nil {
+		t.Fatalf("Failed to write sitemap: %v", err)
+	}
+	if strings.Contains(buf.String(), "video") {
+		t.Errorf("Incorrect sitemap: expected no video namespace without videos, got\n%s", buf.String())
+	}
+}
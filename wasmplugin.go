@@ -0,0 +1,223 @@
+//go:build wasmplugins
+
+package sitemap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Limits applied to WASM plugins so a misbehaving plugin can't take down the crawl
+const (
+	wasmMemoryLimitPages = 1024            // maximum memory (in 64KiB pages) for a plugin
+	wasmCallTimeout      = 5 * time.Second // maximum time for a single call into a plugin
+	wasmMaxResultSize    = 1024 * 1024     // maximum size of the metadata returned by a plugin
+)
+
+// WasmPlugin is a URL filter and/or metadata extractor implemented by a WebAssembly module, providing a
+// safe and portable way to extend the crawler without recompiling it. Plugins are run in a sandbox with
+// no access to the file system or network, and limited memory and time for each call.
+//
+// A plugin module exports its memory plus the following functions, where strings are passed as a pointer
+// and length in the plugin's memory:
+//
+//	alloc(size i32) i32                        allocate memory for a value passed to the plugin (required)
+//	free(ptr i32, size i32)                    release memory allocated with alloc (optional)
+//	filter_url(ptr i32, len i32, depth i32) i32
+//	                                           URLFilter: return 0 to skip the URL (optional)
+//	extract(ptr i32, len i32) i64              MetadataExtractor: passed the page's JSON PageRecord, returns
+//	                                           the pointer and length (ptr<<32 | len) of a JSON object of
+//	                                           string metadata, or 0 for none (optional)
+//
+// WASI is available so modules built with TinyGo or Rust (wasm32-wasi) can be used. Modules are single
+// threaded, so calls into a plugin are serialized.
+type WasmPlugin struct {
+	name     string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	module   api.Module
+	alloc    api.Function
+	free     api.Function  // nil if not exported
+	filter   api.Function  // nil if not exported
+	extract  api.Function  // nil if not exported
+	timeout  time.Duration // maximum time for each call into the plugin
+	logger   Logger
+	mutex    sync.Mutex
+}
+
+// LoadWasmPlugin compiles and instantiates the WebAssembly module in the supplied file
+func LoadWasmPlugin(fileName string) (*WasmPlugin, error) {
+	wasm, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	config := wazero.NewRuntimeConfig().WithMemoryLimitPages(wasmMemoryLimitPages).WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to load WASM plugin %s: %v", fileName, err)
+	}
+	plugin := &WasmPlugin{
+		name:     fileName,
+		runtime:  runtime,
+		compiled: compiled,
+		timeout:  wasmCallTimeout,
+		logger:   defaultLogger(),
+	}
+	if err := plugin.instantiate(ctx); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to load WASM plugin %s: %v", fileName, err)
+	}
+	if plugin.alloc == nil || plugin.module.Memory() == nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("WASM plugin %s must export memory and an alloc function", fileName)
+	}
+	if plugin.filter == nil && plugin.extract == nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("WASM plugin %s exports neither filter_url nor extract", fileName)
+	}
+	return plugin, nil
+}
+
+// instantiate creates a new instance of the plugin's compiled module, replacing the current instance
+func (plugin *WasmPlugin) instantiate(ctx context.Context) error {
+	module, err := plugin.runtime.InstantiateModule(ctx, plugin.compiled,
+		wazero.NewModuleConfig().WithName(filepath.Base(plugin.name)).WithStartFunctions("_initialize"))
+	if err != nil {
+		return err
+	}
+	plugin.module = module
+	plugin.alloc = module.ExportedFunction("alloc")
+	plugin.free = module.ExportedFunction("free")
+	plugin.filter = module.ExportedFunction("filter_url")
+	plugin.extract = module.ExportedFunction("extract")
+	return nil
+}
+
+// restartIfTimedOut replaces the plugin's instance if the call using ctx timed out. The runtime closes an
+// instance when a call into it is stopped, so without this every later call would fail. If the plugin
+// can't be restarted its calls keep failing, so URLs are crawled without it and no metadata is extracted.
+func (plugin *WasmPlugin) restartIfTimedOut(ctx context.Context) {
+	if ctx.Err() == nil {
+		return
+	}
+	restartCtx, cancel := context.WithTimeout(context.Background(), plugin.timeout)
+	defer cancel()
+	plugin.module.Close(restartCtx)
+	if err := plugin.instantiate(restartCtx); err != nil {
+		plugin.logger.Error("WASM plugin disabled as it couldn't be restarted after a call timed out", "plugin", plugin.name, "error", err)
+		return
+	}
+	plugin.logger.Warn("WASM plugin restarted after a call timed out", "plugin", plugin.name)
+}
+
+// HasFilter returns true if the plugin implements a URL filter
+func (plugin *WasmPlugin) HasFilter() bool {
+	return plugin.filter != nil
+}
+
+// HasExtractor returns true if the plugin implements a metadata extractor
+func (plugin *WasmPlugin) HasExtractor() bool {
+	return plugin.extract != nil
+}
+
+// FilterURL calls the plugin's URL filter. See URLFilter for details. URLs are crawled if the plugin fails.
+func (plugin *WasmPlugin) FilterURL(u *url.URL, depth int) bool {
+	plugin.mutex.Lock()
+	defer plugin.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), plugin.timeout)
+	defer cancel()
+	defer plugin.restartIfTimedOut(ctx) // after any memory is released
+
+	urlStr := u.String()
+	ptr, err := plugin.write(ctx, []byte(urlStr))
+	if err != nil {
+		plugin.logger.Warn("WASM plugin failed", "plugin", plugin.name, "url", urlStr, "error", err)
+		return true
+	}
+	defer plugin.release(ctx, ptr, len(urlStr))
+	results, err := plugin.filter.Call(ctx, uint64(ptr), uint64(len(urlStr)), uint64(depth))
+	if err != nil {
+		plugin.logger.Warn("WASM plugin failed", "plugin", plugin.name, "url", urlStr, "error", err)
+		return true
+	}
+	return uint32(results[0]) != 0
+}
+
+// ExtractMetadata calls the plugin's metadata extractor with the page's PageRecord. See MetadataExtractor.
+func (plugin *WasmPlugin) ExtractMetadata(page *WebPage) (map[string]string, error) {
+	record, err := json.Marshal(CreatePageRecord(page))
+	if err != nil {
+		return nil, err
+	}
+	plugin.mutex.Lock()
+	defer plugin.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), plugin.timeout)
+	defer cancel()
+	defer plugin.restartIfTimedOut(ctx) // after any memory is released
+
+	ptr, err := plugin.write(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	defer plugin.release(ctx, ptr, len(record))
+	results, err := plugin.extract.Call(ctx, uint64(ptr), uint64(len(record)))
+	if err != nil {
+		return nil, err
+	}
+	resultPtr, resultLen := uint32(results[0]>>32), uint32(results[0])
+	if resultLen == 0 {
+		return nil, nil
+	}
+	defer plugin.release(ctx, resultPtr, int(resultLen))
+	if resultLen > wasmMaxResultSize {
+		return nil, fmt.Errorf("metadata too large (%d bytes)", resultLen)
+	}
+	data, ok := plugin.module.Memory().Read(resultPtr, resultLen)
+	if !ok {
+		return nil, fmt.Errorf("metadata out of range of plugin memory")
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+	return metadata, nil
+}
+
+// Close releases the plugin
+func (plugin *WasmPlugin) Close() error {
+	return plugin.runtime.Close(context.Background())
+}
+
+// write copies data into memory allocated in the plugin, returning its address
+func (plugin *WasmPlugin) write(ctx context.Context, data []byte) (uint32, error) {
+	results, err := plugin.alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(results[0])
+	if !plugin.module.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("allocated memory out of range of plugin memory")
+	}
+	return ptr, nil
+}
+
+// release frees memory in the plugin, if it supports it
+func (plugin *WasmPlugin) release(ctx context.Context, ptr uint32, size int) {
+	if plugin.free != nil {
+		plugin.free.Call(ctx, uint64(ptr), uint64(size))
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !wasmplugins
+
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// WasmPlugin is a URL filter and metadata extractor implemented by a WebAssembly module. This build does
+// not support WASM plugins; build with -tags wasmplugins to enable them (see wasmplugin.go).
+type WasmPlugin struct{}
+
+// LoadWasmPlugin always fails, as this build does not support WASM plugins
+func LoadWasmPlugin(fileName string) (*WasmPlugin, error) {
+	return nil, fmt.Errorf("cannot load %s: WASM plugins are not supported by this build (build with -tags wasmplugins)", fileName)
+}
+
+// HasFilter returns false as the plugin can never be loaded
+func (plugin *WasmPlugin) HasFilter() bool { return false }
+
+// HasExtractor returns false as the plugin can never be loaded
+func (plugin *WasmPlugin) HasExtractor() bool { return false }
+
+// FilterURL allows every URL as the plugin can never be loaded
+func (plugin *WasmPlugin) FilterURL(u *url.URL, depth int) bool { return true }
+
+// ExtractMetadata returns no metadata as the plugin can never be loaded
+func (plugin *WasmPlugin) ExtractMetadata(page *WebPage) (map[string]string, error) { return nil, nil }
+
+// Close does nothing as the plugin can never be loaded
+func (plugin *WasmPlugin) Close() error { return nil }
@@ -0,0 +1,257 @@
+//go:build wasmplugins
+
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// WebAssembly value types and instructions used by the test modules
+const (
+	wasmI32 = 0x7f
+	wasmI64 = 0x7e
+
+	wasmUnreachable = 0x00
+	wasmLoop        = 0x03
+	wasmIf          = 0x04
+	wasmEnd         = 0x0b
+	wasmBr          = 0x0c
+	wasmLocalGet    = 0x20
+	wasmGlobalGet   = 0x23
+	wasmGlobalSet   = 0x24
+	wasmI32Const    = 0x41
+	wasmI64Const    = 0x42
+	wasmI32GtU      = 0x4b
+	wasmI32LeU      = 0x4d
+	wasmI32Add      = 0x6a
+)
+
+// wasmFunc is an exported function of a test module, with its signature and instructions (without the
+// final end)
+type wasmFunc struct {
+	name    string
+	params  []byte
+	results []byte
+	code    []byte
+}
+
+// wasmAlloc is an alloc function for test modules, a bump allocator which never frees anything:
+//
+//	(func (param $size i32) (result i32)
+//	  global.get $next
+//	  (global.set $next (i32.add (global.get $next) (local.get $size))))
+var wasmAlloc = wasmFunc{"alloc", []byte{wasmI32}, []byte{wasmI32},
+	[]byte{wasmGlobalGet, 0, wasmGlobalGet, 0, wasmLocalGet, 0, wasmI32Add, wasmGlobalSet, 0}}
+
+// wasmHang is the instructions of a function which never returns: (loop br 0) unreachable
+var wasmHang = []byte{wasmLoop, 0x40, wasmBr, 0, wasmEnd, wasmUnreachable}
+
+// appendLEB128 appends a signed LEB128 value, which is also the encoding of unsigned values below 2^6
+func appendLEB128(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// appendULEB128 appends an unsigned LEB128 value
+func appendULEB128(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendVector appends a vector: its length then its (already encoded) items
+func appendVector(b []byte, items ...[]byte) []byte {
+	b = appendULEB128(b, uint64(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+// appendBytes appends a vector of bytes (e.g. value types, or a name): its length then the bytes
+func appendBytes(b []byte, bytes string) []byte {
+	return append(appendULEB128(b, uint64(len(bytes))), bytes...)
+}
+
+// buildWasmModule assembles a module exporting one page of memory, a mutable i32 global (used by
+// wasmAlloc) starting at 2048 and the supplied functions, with data at address 1024
+func buildWasmModule(funcs []wasmFunc, data string) []byte {
+	section := func(module []byte, id byte, content []byte) []byte {
+		return append(appendULEB128(append(module, id), uint64(len(content))), content...)
+	}
+	var types, indices, exports, bodies [][]byte
+	exports = append(exports, append(appendBytes(nil, "memory"), 0x02, 0))
+	for i, f := range funcs {
+		types = append(types, appendBytes(appendBytes([]byte{0x60}, string(f.params)), string(f.results)))
+		indices = append(indices, appendULEB128(nil, uint64(i)))
+		exports = append(exports, append(appendBytes(nil, f.name), 0x00, byte(i)))
+		body := append(append([]byte{0}, f.code...), wasmEnd) // no locals
+		bodies = append(bodies, append(appendULEB128(nil, uint64(len(body))), body...))
+	}
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = section(module, 1, appendVector(nil, types...))
+	module = section(module, 3, appendVector(nil, indices...))
+	module = section(module, 5, appendVector(nil, []byte{0x00, 1}))
+	module = section(module, 6, appendVector(nil, append(appendLEB128([]byte{wasmI32, 1, wasmI32Const}, 2048), wasmEnd)))
+	module = section(module, 7, appendVector(nil, exports...))
+	module = section(module, 10, appendVector(nil, bodies...))
+	segment := appendBytes(append(appendLEB128([]byte{0x00, wasmI32Const}, 1024), wasmEnd), data)
+	return section(module, 11, appendVector(nil, segment))
+}
+
+// loadTestWasmPlugin writes a module assembled by buildWasmModule to a file and loads it as a plugin
+func loadTestWasmPlugin(t *testing.T, funcs []wasmFunc, data string) (*WasmPlugin, error) {
+	fileName := filepath.Join(t.TempDir(), "plugin.wasm")
+	if err := os.WriteFile(fileName, buildWasmModule(funcs, data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	plugin, err := LoadWasmPlugin(fileName)
+	if err == nil {
+		t.Cleanup(func() { plugin.Close() })
+	}
+	return plugin, err
+}
+
+// wasmExtract returns an extract function returning the pointer and length of a result
+func wasmExtract(ptr, length uint32) wasmFunc {
+	return wasmFunc{"extract", []byte{wasmI32, wasmI32}, []byte{wasmI64},
+		appendLEB128([]byte{wasmI64Const}, int64(ptr)<<32|int64(length))}
+}
+
+func TestWasmPlugin(t *testing.T) {
+
+	// filters out URLs longer than 20 characters, and extracts the metadata at 1024
+	const metadata = `{"lang":"en"}`
+	filter := wasmFunc{"filter_url", []byte{wasmI32, wasmI32, wasmI32}, []byte{wasmI32},
+		[]byte{wasmLocalGet, 1, wasmI32Const, 20, wasmI32LeU}}
+	plugin, err := loadTestWasmPlugin(t, []wasmFunc{wasmAlloc, filter, wasmExtract(1024, uint32(len(metadata)))}, metadata)
+	if err != nil {
+		t.Fatalf("Failed to load plugin: %v", err)
+	}
+	if !plugin.HasFilter() || !plugin.HasExtractor() {
+		t.Errorf("Incorrect plugin functions: expected a filter and extractor, got %v %v", plugin.HasFilter(), plugin.HasExtractor())
+	}
+	for urlStr, expected := range map[string]bool{
+		"https://test.com/a":             true,
+		"https://test.com/too/long/path": false,
+	} {
+		if crawl := plugin.FilterURL(mustParseURL(t, urlStr), 1); crawl != expected {
+			t.Errorf("Incorrect filter result for %s: expected %v, got %v", urlStr, expected, crawl)
+		}
+	}
+	extracted, err := plugin.ExtractMetadata(createWebPage(t, "https://test.com/a", "Page A"))
+	if expected := map[string]string{"lang": "en"}; err != nil || !reflect.DeepEqual(extracted, expected) {
+		t.Errorf("Incorrect metadata: expected (%v, nil), got (%v, %v)", expected, extracted, err)
+	}
+
+	// an extractor returning no metadata
+	plugin, err = loadTestWasmPlugin(t, []wasmFunc{wasmAlloc, wasmExtract(0, 0)}, "")
+	if err != nil {
+		t.Fatalf("Failed to load plugin: %v", err)
+	}
+	if extracted, err := plugin.ExtractMetadata(createWebPage(t, "https://test.com/a", "")); extracted != nil || err != nil {
+		t.Errorf("Incorrect result with no metadata: expected (nil, nil), got (%v, %v)", extracted, err)
+	}
+}
+
+func TestWasmPluginInvalid(t *testing.T) {
+	tests := []struct {
+		funcs    []wasmFunc
+		expected string
+	}{
+		{[]wasmFunc{wasmExtract(0, 0)}, "alloc"},
+		{[]wasmFunc{wasmAlloc}, "neither filter_url nor extract"},
+	}
+	for _, test := range tests {
+		if _, err := loadTestWasmPlugin(t, test.funcs, ""); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Incorrect error loading an invalid plugin: expected %q, got %v", test.expected, err)
+		}
+	}
+
+	fileName := filepath.Join(t.TempDir(), "plugin.wasm")
+	os.WriteFile(fileName, []byte("not wasm"), 0644)
+	if _, err := LoadWasmPlugin(fileName); err == nil {
+		t.Errorf("Missing expected error loading a file which isn't a WASM module")
+	}
+}
+
+func TestWasmPluginInvalidResult(t *testing.T) {
+	tests := []struct {
+		ptr, length uint32
+		data        string
+		expected    string
+	}{
+		{1024, wasmMaxResultSize + 1, "", "too large"},
+		{65530, 100, "", "out of range"},
+		{1024, 9, "not json!", "invalid metadata"},
+	}
+	for _, test := range tests {
+		plugin, err := loadTestWasmPlugin(t, []wasmFunc{wasmAlloc, wasmExtract(test.ptr, test.length)}, test.data)
+		if err != nil {
+			t.Fatalf("Failed to load plugin: %v", err)
+		}
+		if _, err := plugin.ExtractMetadata(createWebPage(t, "https://test.com/a", "")); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Incorrect error for result (%d, %d): expected %q, got %v", test.ptr, test.length, test.expected, err)
+		}
+	}
+}
+
+func TestWasmPluginTimeout(t *testing.T) {
+
+	// the filter skips the starting page and hangs for other URLs, and the extractor hangs for records over
+	// 1000 bytes
+	hangIf := func(condition ...byte) []byte {
+		return append(append(condition, wasmIf, 0x40), append(wasmHang, wasmEnd)...)
+	}
+	filter := wasmFunc{"filter_url", []byte{wasmI32, wasmI32, wasmI32}, []byte{wasmI32},
+		append(hangIf(wasmLocalGet, 2), wasmI32Const, 0)}
+	extract := wasmFunc{"extract", []byte{wasmI32, wasmI32}, []byte{wasmI64},
+		append(hangIf(append(appendLEB128([]byte{wasmLocalGet, 1, wasmI32Const}, 1000), wasmI32GtU)...), wasmI64Const, 0)}
+	plugin, err := loadTestWasmPlugin(t, []wasmFunc{wasmAlloc, filter, extract}, "")
+	if err != nil {
+		t.Fatalf("Failed to load plugin: %v", err)
+	}
+	plugin.timeout = 50 * time.Millisecond
+
+	// URLs are crawled if the filter doesn't return in time, and the plugin is restarted for later calls
+	start := time.Now()
+	if !plugin.FilterURL(mustParseURL(t, "https://test.com/a"), 1) {
+		t.Errorf("URL skipped when the filter timed out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Filter not stopped after timeout: took %v", elapsed)
+	}
+	if plugin.FilterURL(mustParseURL(t, "https://test.com"), 0) {
+		t.Errorf("Incorrect filter result after a timeout: expected false, got true")
+	}
+	if !plugin.FilterURL(mustParseURL(t, "https://test.com/a"), 1) {
+		t.Errorf("URL skipped when the filter timed out again")
+	}
+	if plugin.FilterURL(mustParseURL(t, "https://test.com"), 0) {
+		t.Errorf("Incorrect filter result after a second timeout: expected false, got true")
+	}
+
+	start = time.Now()
+	if _, err := plugin.ExtractMetadata(createWebPage(t, "https://test.com/a", strings.Repeat("x", 2000))); err == nil {
+		t.Errorf("Missing expected error when the extractor timed out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Extractor not stopped after timeout: took %v", elapsed)
+	}
+	if extracted, err := plugin.ExtractMetadata(createWebPage(t, "https://test.com/a", "")); extracted != nil || err != nil {
+		t.Errorf("Incorrect result after the extractor timed out: expected (nil, nil), got (%v, %v)", extracted, err)
+	}
+}
@@ -0,0 +1,56 @@
+package sitemap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WorkTracker counts the items of work outstanding in the crawler's pipeline (URLs found but not yet
+// deduplicated, queued, loading, or pages waiting to be added to the sink), signalling once none remain.
+// As the pipeline's channels form a loop, no single stage can detect the crawl is complete on its own.
+//
+// Like a sync.WaitGroup, an item must be added before the work it represents is handed on, and new items
+// produced by a piece of work (e.g. the links out of a page) must be added before that work is done, so the
+// count can only reach zero once all work is complete. Unlike a WaitGroup, it can't be reused once finished.
+type WorkTracker struct {
+	pending  atomic.Int64
+	finished chan struct{}
+	once     sync.Once
+}
+
+// CreateWorkTracker creates a tracker with no work outstanding. It only finishes once work has been added
+// and then all done.
+func CreateWorkTracker() *WorkTracker {
+	return &WorkTracker{finished: make(chan struct{})}
+}
+
+// Add adds n items of work
+func (w *WorkTracker) Add(n int) {
+	w.update(int64(n))
+}
+
+// Done marks an item of work as done
+func (w *WorkTracker) Done() {
+	w.update(-1)
+}
+
+// Pending returns the number of items of work outstanding
+func (w *WorkTracker) Pending() int64 {
+	return w.pending.Load()
+}
+
+// Finished returns a channel closed once all work is done
+func (w *WorkTracker) Finished() <-chan struct{} {
+	return w.finished
+}
+
+// update adjusts the count of work outstanding, closing the finished channel when it reaches zero
+func (w *WorkTracker) update(delta int64) {
+	pending := w.pending.Add(delta)
+	if pending < 0 {
+		panic("WorkTracker: negative count of work outstanding")
+	}
+	if pending == 0 && delta < 0 {
+		w.once.Do(func() { close(w.finished) })
+	}
+}
@@ -0,0 +1,144 @@
+package sitemap
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkTracker(t *testing.T) {
+
+	work := CreateWorkTracker()
+	work.Add(2)
+	work.Done()
+	work.Add(1) // new work found before the last item is done
+	work.Done()
+	select {
+	case <-work.Finished():
+		t.Fatalf("Work tracker finished with %d items outstanding", work.Pending())
+	default:
+	}
+	work.Done()
+	select {
+	case <-work.Finished():
+	default:
+		t.Fatalf("Work tracker not finished with no items outstanding")
+	}
+	if pending := work.Pending(); pending != 0 {
+		t.Errorf("Incorrect pending count: expected 0, got %d", pending)
+	}
+}
+
+func TestWorkTrackerConcurrent(t *testing.T) {
+
+	// each item of work adds 2 more, until 1000 items have been done
+	work := CreateWorkTracker()
+	var mutex sync.Mutex
+	done := 0
+	var process func()
+	process = func() {
+		mutex.Lock()
+		done++
+		more := done <= 500
+		mutex.Unlock()
+		if more {
+			work.Add(2)
+			go process()
+			go process()
+		}
+		work.Done()
+	}
+	work.Add(1)
+	go process()
+	select {
+	case <-work.Finished():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Work tracker not finished with %d items outstanding", work.Pending())
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if done != 1001 {
+		t.Errorf("Incorrect items done: expected 1001, got %d", done)
+	}
+}
+
+func TestWorkTrackerNegative(t *testing.T) {
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Incorrect result marking more work done than added: expected a panic")
+		}
+	}()
+	CreateWorkTracker().Done()
+}
+
+// crawlWithin runs a crawl, failing the test if it doesn't complete within 10 seconds
+func crawlWithin(t *testing.T, crawler *Crawler) {
+	t.Helper()
+	result := make(chan error, 1)
+	go func() { result <- crawler.Crawl() }()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Unexpected error from crawl: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Crawl did not complete")
+	}
+}
+
+func TestCrawlCompletion(t *testing.T) {
+
+	server := createTestSite(map[string][]string{
+		"/":      {"/a", "/b", "/a", "/missing"},
+		"/a":     {"/", "/a/1", "/b"},
+		"/b":     {"/a"},
+		"/a/1":   {"/a/1/x"},
+		"/a/1/x": {},
+		"/seed":  {},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected int
+	}{
+		{"whole site", nil, 5},
+		{"max depth", []Option{WithMaxDepth(2)}, 3},
+		{"start page only", []Option{WithMaxDepth(1)}, 1},
+		{"page limit", []Option{WithMaxPages(2)}, 2},
+		{"pages discarded", []Option{WithOnPage(func(visit *PageVisit) bool { return false })}, 0},
+		{"links filtered", []Option{WithURLFilter(func(u *url.URL, depth int) bool { return depth == 1 })}, 1},
+		{"stopped", []Option{WithMaxDuration(time.Nanosecond)}, 0},
+		{"failed seeds", []Option{WithSeeds(server.URL+"/gone", server.URL+"/missing", server.URL+"/seed"), WithMaxDepth(1)}, 2},
+	}
+	for _, test := range tests {
+		siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+		crawler := createTestCrawler(t, server, append(test.opts, WithSink(siteMap))...)
+		crawlWithin(t, crawler)
+		if len(siteMap.Pages) != test.expected {
+			t.Errorf("Incorrect pages for %s: expected %d, got %v", test.name, test.expected, sortedKeys(siteMap.Pages))
+		}
+		if pending := crawler.work.Pending(); pending != 0 {
+			t.Errorf("Incorrect work outstanding for %s: expected 0, got %d", test.name, pending)
+		}
+	}
+}
+
+func TestCrawlNoPages(t *testing.T) {
+
+	server := createTestSite(map[string][]string{})
+	defer server.Close()
+
+	siteMap := CreateSiteMap(mustParseURL(t, server.URL))
+	crawler := createTestCrawler(t, server, WithSink(siteMap))
+	crawlWithin(t, crawler)
+	if len(siteMap.Pages) != 0 {
+		t.Errorf("Incorrect pages: expected none, got %v", sortedKeys(siteMap.Pages))
+	}
+	if errors := crawler.Errors(); len(errors) != 1 || errors[0].StatusCode != 404 {
+		t.Errorf("Incorrect errors: expected the start page not found, got %v", errors)
+	}
+}